@@ -0,0 +1,284 @@
+package replaypcd
+
+/*
+	This file implements a replay camera which plays back point clouds and images captured by the
+	data manager from .capture files in a local directory, so recordings can be replayed without a
+	network connection.
+
+	Example configuration:
+	{
+      "type": "camera",
+	  "model": "replay_local",
+      "name": "my-replay",
+      "attributes": {
+        "source": "lidar",
+        "capture_dir": "/home/user/.viam/capture",
+        "time_interval": {
+          "start": "2024-01-01T12:00:00Z",
+          "end": "2024-01-01T12:30:00Z"
+        },
+        "playback_mode": "realtime",
+        "loop": true
+      }
+    }
+*/
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/data"
+	"go.viam.com/rdk/gostream"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/utils"
+)
+
+const (
+	nextPointCloudMethod = "NextPointCloud"
+	readImageMethod      = "ReadImage"
+)
+
+// localModel is the model of a replay camera which reads from local capture files.
+var localModel = resource.DefaultModelFamily.WithModel("replay_local")
+
+func init() {
+	resource.RegisterComponent(camera.API, localModel, resource.Registration[camera.Camera, *LocalConfig]{
+		Constructor: newLocalCamera,
+	})
+}
+
+// LocalConfig describes how to configure the local replay camera.
+type LocalConfig struct {
+	Source        string       `json:"source"`
+	CaptureDir    string       `json:"capture_dir"`
+	Interval      TimeInterval `json:"time_interval,omitempty"`
+	PlaybackMode  string       `json:"playback_mode,omitempty"`
+	PlaybackSpeed float64      `json:"playback_speed,omitempty"`
+	Loop          bool         `json:"loop,omitempty"`
+}
+
+// Validate checks that the config attributes are valid for a local replay camera.
+func (cfg *LocalConfig) Validate(path string) ([]string, error) {
+	if cfg.Source == "" {
+		return nil, resource.NewConfigValidationFieldRequiredError(path, "source")
+	}
+	if cfg.CaptureDir == "" {
+		return nil, resource.NewConfigValidationFieldRequiredError(path, "capture_dir")
+	}
+	if _, _, err := cfg.Interval.parse(); err != nil {
+		return nil, resource.NewConfigValidationError(path, err)
+	}
+	replayConfig := cfg.replayConfig()
+	if err := replayConfig.Validate(); err != nil {
+		return nil, resource.NewConfigValidationError(path, err)
+	}
+	return nil, nil
+}
+
+func (cfg *LocalConfig) replayConfig() data.ReplayConfig {
+	return data.ReplayConfig{
+		Mode:  data.ReplayMode(cfg.PlaybackMode),
+		Speed: cfg.PlaybackSpeed,
+		Loop:  cfg.Loop,
+	}
+}
+
+// parse returns the start and end of the interval, either of which may be zero if unset.
+func (interval TimeInterval) parse() (time.Time, time.Time, error) {
+	var start, end time.Time
+	var err error
+	if interval.Start != "" {
+		if start, err = time.Parse(timeFormat, interval.Start); err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid time format for start time (UTC), use RFC3339")
+		}
+	}
+	if interval.End != "" {
+		if end, err = time.Parse(timeFormat, interval.End); err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid time format for end time (UTC), use RFC3339")
+		}
+	}
+	if !start.IsZero() && !end.IsZero() && start.After(end) {
+		return time.Time{}, time.Time{}, errors.New("invalid config, end time (UTC) must be after start time (UTC)")
+	}
+	return start, end, nil
+}
+
+// localCamera is a camera model that plays back point clouds and images captured to local files.
+type localCamera struct {
+	resource.Named
+	resource.AlwaysRebuild
+	logger logging.Logger
+
+	source string
+	replay *data.CaptureReplay
+	stream gostream.VideoStream
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func newLocalCamera(
+	ctx context.Context, deps resource.Dependencies, conf resource.Config, logger logging.Logger,
+) (camera.Camera, error) {
+	newConf, err := resource.NativeConfig[*LocalConfig](conf)
+	if err != nil {
+		return nil, err
+	}
+	return makeLocalCamera(conf.ResourceName(), newConf, clock.New(), logger)
+}
+
+// makeLocalCamera is separate from newLocalCamera so tests can control the playback clock.
+func makeLocalCamera(name resource.Name, conf *LocalConfig, clk clock.Clock, logger logging.Logger) (*localCamera, error) {
+	start, end, err := conf.Interval.parse()
+	if err != nil {
+		return nil, err
+	}
+	readings, err := data.ReadCaptureDir(conf.CaptureDir, camera.Named(conf.Source), start, end, logger)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read capture files from %s", conf.CaptureDir)
+	}
+	replay, err := data.NewCaptureReplay(readings, conf.replayConfig(), clk)
+	if err != nil {
+		return nil, err
+	}
+	if !replay.HasMethod(nextPointCloudMethod) && !replay.HasMethod(readImageMethod) {
+		return nil, errors.Errorf("no point clouds or images captured for %q in %s", conf.Source, conf.CaptureDir)
+	}
+
+	cam := &localCamera{
+		Named:  name.AsNamed(),
+		logger: logger,
+		source: conf.Source,
+		replay: replay,
+	}
+	cam.stream = gostream.NewEmbeddedVideoStreamFromReader(gostream.VideoReaderFunc(
+		func(ctx context.Context) (image.Image, func(), error) {
+			img, _, err := cam.nextImage(ctx)
+			return img, func() {}, err
+		}))
+	return cam, nil
+}
+
+// next returns the next captured reading for the method and attaches its timestamps to the response.
+func (cam *localCamera) next(ctx context.Context, method string) (data.ReplayReading, error) {
+	cam.mu.Lock()
+	closed := cam.closed
+	cam.mu.Unlock()
+	if closed {
+		return data.ReplayReading{}, errors.New("session closed")
+	}
+
+	reading, err := cam.replay.Next(method)
+	if err != nil {
+		if errors.Is(err, data.ErrEndOfCaptures) {
+			return data.ReplayReading{}, ErrEndOfDataset
+		}
+		return data.ReplayReading{}, err
+	}
+	if err := addGRPCMetadata(ctx,
+		timestamppb.New(reading.TimeRequested), timestamppb.New(reading.TimeReceived)); err != nil {
+		return data.ReplayReading{}, err
+	}
+	return reading, nil
+}
+
+func (cam *localCamera) nextImage(ctx context.Context) (image.Image, time.Time, error) {
+	if !cam.replay.HasMethod(readImageMethod) {
+		return nil, time.Time{}, errors.Errorf("no images captured for %q", cam.source)
+	}
+	reading, err := cam.next(ctx, readImageMethod)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	var mimeType string
+	switch reading.Metadata.GetFileExtension() {
+	case ".jpeg", ".jpg":
+		mimeType = utils.MimeTypeJPEG
+	case ".png":
+		mimeType = utils.MimeTypePNG
+	default:
+		return nil, time.Time{}, errors.Errorf("cannot replay images with file extension %q", reading.Metadata.GetFileExtension())
+	}
+	sd, err := reading.SensorData()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	img, err := rimage.DecodeImage(ctx, sd.GetBinary(), mimeType)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return img, reading.TimeRequested, nil
+}
+
+// NextPointCloud returns the captured point cloud.
+func (cam *localCamera) NextPointCloud(ctx context.Context) (pointcloud.PointCloud, error) {
+	if !cam.replay.HasMethod(nextPointCloudMethod) {
+		return nil, errors.Errorf("no point clouds captured for %q", cam.source)
+	}
+	reading, err := cam.next(ctx, nextPointCloudMethod)
+	if err != nil {
+		return nil, err
+	}
+	sd, err := reading.SensorData()
+	if err != nil {
+		return nil, err
+	}
+	return pointcloud.ReadPCD(bytes.NewReader(sd.GetBinary()))
+}
+
+// Images returns the captured image.
+func (cam *localCamera) Images(ctx context.Context) ([]camera.NamedImage, resource.ResponseMetadata, error) {
+	img, capturedAt, err := cam.nextImage(ctx)
+	if err != nil {
+		return nil, resource.ResponseMetadata{}, err
+	}
+	return []camera.NamedImage{{Image: img, SourceName: cam.source}}, resource.ResponseMetadata{CapturedAt: capturedAt}, nil
+}
+
+// Stream returns a stream of the captured images.
+func (cam *localCamera) Stream(ctx context.Context, errHandlers ...gostream.ErrorHandler) (gostream.VideoStream, error) {
+	if !cam.replay.HasMethod(readImageMethod) {
+		return nil, errors.Errorf("no images captured for %q", cam.source)
+	}
+	return cam.stream, nil
+}
+
+// Properties returns which kinds of captured data the camera can replay.
+func (cam *localCamera) Properties(ctx context.Context) (camera.Properties, error) {
+	props := camera.Properties{
+		SupportsPCD: cam.replay.HasMethod(nextPointCloudMethod),
+	}
+	if cam.replay.HasMethod(readImageMethod) {
+		props.ImageType = camera.ColorStream
+	}
+	return props, nil
+}
+
+// DoCommand accepts {"restart": true} to rewind playback to the beginning of the recording.
+func (cam *localCamera) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	if restart, ok := cmd["restart"].(bool); ok && restart {
+		cam.replay.Restart()
+		return map[string]interface{}{"restart": true}, nil
+	}
+	return nil, resource.ErrDoUnimplemented
+}
+
+// Close stops the local replay camera.
+func (cam *localCamera) Close(ctx context.Context) error {
+	cam.mu.Lock()
+	cam.closed = true
+	cam.mu.Unlock()
+	cam.replay.Close()
+	return cam.stream.Close(ctx)
+}
@@ -0,0 +1,172 @@
+package replaypcd
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	v1 "go.viam.com/api/app/datasync/v1"
+	"go.viam.com/test"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/data"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/utils"
+)
+
+var localTestStart = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// writeLocalCapture writes binary readings the way the camera collectors do, one per second.
+func writeLocalCapture(t *testing.T, dir, method string, params map[string]string, readings ...[]byte) {
+	t.Helper()
+	md := data.BuildCaptureMetadata(camera.API, "lidar", method, params, nil, nil)
+	f, err := data.NewCaptureFile(dir, md)
+	test.That(t, err, test.ShouldBeNil)
+	for i, reading := range readings {
+		requested := localTestStart.Add(time.Duration(i) * time.Second)
+		test.That(t, f.WriteNext(&v1.SensorData{
+			Metadata: &v1.SensorMetadata{
+				TimeRequested: timestamppb.New(requested),
+				TimeReceived:  timestamppb.New(requested.Add(time.Millisecond)),
+			},
+			Data: &v1.SensorData_Binary{Binary: reading},
+		}), test.ShouldBeNil)
+	}
+	test.That(t, f.Close(), test.ShouldBeNil)
+}
+
+// pcdWithPoints returns a PCD holding n points.
+func pcdWithPoints(t *testing.T, n int) []byte {
+	t.Helper()
+	pc := pointcloud.New()
+	for i := 0; i < n; i++ {
+		test.That(t, pc.Set(pointcloud.NewVector(float64(i), 0, 0), nil), test.ShouldBeNil)
+	}
+	var buf bytes.Buffer
+	test.That(t, pointcloud.ToPCD(pc, &buf, pointcloud.PCDBinary), test.ShouldBeNil)
+	return buf.Bytes()
+}
+
+// pngOfWidth returns a PNG which is w pixels wide.
+func pngOfWidth(t *testing.T, w int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, 2))
+	img.Set(0, 0, color.NRGBA{R: 255, A: 255})
+	out, err := rimage.EncodeImage(context.Background(), img, utils.MimeTypePNG)
+	test.That(t, err, test.ShouldBeNil)
+	return out
+}
+
+func TestLocalCameraValidate(t *testing.T) {
+	path := "path"
+	cfg := LocalConfig{Source: "lidar", CaptureDir: "/tmp/capture"}
+	_, err := cfg.Validate(path)
+	test.That(t, err, test.ShouldBeNil)
+
+	cfg.CaptureDir = ""
+	_, err = cfg.Validate(path)
+	test.That(t, err, test.ShouldBeError, resource.NewConfigValidationFieldRequiredError(path, "capture_dir"))
+
+	cfg = LocalConfig{Source: "lidar", CaptureDir: "/tmp/capture", PlaybackMode: "backwards"}
+	_, err = cfg.Validate(path)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	cfg = LocalConfig{Source: "lidar", CaptureDir: "/tmp/capture", Interval: TimeInterval{Start: "yesterday"}}
+	_, err = cfg.Validate(path)
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestLocalCamera(t *testing.T) {
+	ctx := context.Background()
+	logger := logging.NewTestLogger(t)
+
+	t.Run("point clouds", func(t *testing.T) {
+		dir := t.TempDir()
+		writeLocalCapture(t, dir, nextPointCloudMethod, nil, pcdWithPoints(t, 1), pcdWithPoints(t, 2), pcdWithPoints(t, 3))
+
+		cam, err := makeLocalCamera(camera.Named("replay"), &LocalConfig{Source: "lidar", CaptureDir: dir}, clock.NewMock(), logger)
+		test.That(t, err, test.ShouldBeNil)
+
+		props, err := cam.Properties(ctx)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, props.SupportsPCD, test.ShouldBeTrue)
+		test.That(t, props.ImageType, test.ShouldEqual, camera.UnspecifiedStream)
+
+		for i := 1; i <= 3; i++ {
+			pc, err := cam.NextPointCloud(ctx)
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, pc.Size(), test.ShouldEqual, i)
+		}
+		_, err = cam.NextPointCloud(ctx)
+		test.That(t, err, test.ShouldBeError, ErrEndOfDataset)
+
+		_, _, err = cam.Images(ctx)
+		test.That(t, err, test.ShouldNotBeNil)
+		_, err = cam.Stream(ctx)
+		test.That(t, err, test.ShouldNotBeNil)
+
+		_, err = cam.DoCommand(ctx, map[string]interface{}{"restart": true})
+		test.That(t, err, test.ShouldBeNil)
+		pc, err := cam.NextPointCloud(ctx)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, pc.Size(), test.ShouldEqual, 1)
+
+		test.That(t, cam.Close(ctx), test.ShouldBeNil)
+		_, err = cam.NextPointCloud(ctx)
+		test.That(t, err, test.ShouldNotBeNil)
+	})
+
+	t.Run("images in realtime", func(t *testing.T) {
+		dir := t.TempDir()
+		writeLocalCapture(t, dir, readImageMethod, map[string]string{"mime_type": utils.MimeTypePNG},
+			pngOfWidth(t, 1), pngOfWidth(t, 2), pngOfWidth(t, 3))
+
+		clk := clock.NewMock()
+		cam, err := makeLocalCamera(camera.Named("replay"), &LocalConfig{
+			Source:       "lidar",
+			CaptureDir:   dir,
+			PlaybackMode: string(data.ReplayModeRealtime),
+		}, clk, logger)
+		test.That(t, err, test.ShouldBeNil)
+		defer func() {
+			test.That(t, cam.Close(ctx), test.ShouldBeNil)
+		}()
+
+		props, err := cam.Properties(ctx)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, props.SupportsPCD, test.ShouldBeFalse)
+		test.That(t, props.ImageType, test.ShouldEqual, camera.ColorStream)
+
+		imgs, md, err := cam.Images(ctx)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, len(imgs), test.ShouldEqual, 1)
+		test.That(t, imgs[0].SourceName, test.ShouldEqual, "lidar")
+		test.That(t, imgs[0].Image.Bounds().Dx(), test.ShouldEqual, 1)
+		test.That(t, md.CapturedAt, test.ShouldEqual, localTestStart)
+
+		clk.Add(1500 * time.Millisecond)
+		stream, err := cam.Stream(ctx)
+		test.That(t, err, test.ShouldBeNil)
+		img, release, err := stream.Next(ctx)
+		test.That(t, err, test.ShouldBeNil)
+		release()
+		test.That(t, img.Bounds().Dx(), test.ShouldEqual, 2)
+
+		clk.Add(time.Second)
+		_, _, err = cam.Images(ctx)
+		test.That(t, err, test.ShouldBeError, ErrEndOfDataset)
+	})
+
+	t.Run("no data", func(t *testing.T) {
+		_, err := makeLocalCamera(camera.Named("replay"), &LocalConfig{Source: "lidar", CaptureDir: t.TempDir()}, clock.NewMock(), logger)
+		test.That(t, err, test.ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,122 @@
+package transformpipeline
+
+import (
+	"context"
+	"image"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/gostream"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/rimage/transform"
+	"go.viam.com/rdk/utils"
+	"go.viam.com/rdk/vision/objectdetection"
+)
+
+// the outputs of the motion transform.
+const (
+	motionOutputMask  = "mask"
+	motionOutputBoxes = "boxes"
+)
+
+// motionConfig is the attribute struct for the motion transform.
+type motionConfig struct {
+	Method          string  `json:"method,omitempty"`
+	LearningRate    float64 `json:"learning_rate,omitempty"`
+	Threshold       float64 `json:"threshold,omitempty"`
+	Gaussians       int     `json:"gaussians,omitempty"`
+	BackgroundRatio float64 `json:"background_ratio,omitempty"`
+	MinArea         int     `json:"min_area_px,omitempty"`
+	// Output is "mask", the default, for the foreground mask, or "boxes" for the image overlaid with the
+	// moving regions.
+	Output string `json:"output,omitempty"`
+}
+
+// motionSource learns the background of the frames of a static camera, and returns what moves in each.
+type motionSource struct {
+	stream gostream.VideoStream
+	// only one of subtractor and detector is set, depending on the output.
+	subtractor *objectdetection.BackgroundSubtractor
+	detector   objectdetection.Detector
+}
+
+func newMotionTransform(
+	ctx context.Context,
+	source gostream.VideoSource,
+	am utils.AttributeMap,
+) (gostream.VideoSource, camera.ImageType, error) {
+	conf, err := resource.TransformAttributeMap[*motionConfig](am)
+	if err != nil {
+		return nil, camera.UnspecifiedStream, err
+	}
+	detectorConf := &objectdetection.MotionDetectorConfig{
+		Method:          conf.Method,
+		LearningRate:    conf.LearningRate,
+		Threshold:       conf.Threshold,
+		Gaussians:       conf.Gaussians,
+		BackgroundRatio: conf.BackgroundRatio,
+		MinArea:         conf.MinArea,
+	}
+	props, err := propsFromVideoSource(ctx, source)
+	if err != nil {
+		return nil, camera.UnspecifiedStream, err
+	}
+	var cameraModel transform.PinholeCameraModel
+	cameraModel.PinholeCameraIntrinsics = props.IntrinsicParams
+	if props.DistortionParams != nil {
+		cameraModel.Distortion = props.DistortionParams
+	}
+
+	motion := &motionSource{stream: gostream.NewEmbeddedVideoStream(source)}
+	switch conf.Output {
+	case "", motionOutputMask:
+		motion.subtractor, err = objectdetection.NewBackgroundSubtractor(&objectdetection.BackgroundSubtractorConfig{
+			Method:          conf.Method,
+			LearningRate:    conf.LearningRate,
+			Threshold:       conf.Threshold,
+			Gaussians:       conf.Gaussians,
+			BackgroundRatio: conf.BackgroundRatio,
+		})
+	case motionOutputBoxes:
+		motion.detector, err = objectdetection.NewMotionDetector(detectorConf)
+	default:
+		err = errors.Errorf("motion output must be %q or %q, got %q", motionOutputMask, motionOutputBoxes, conf.Output)
+	}
+	if err != nil {
+		return nil, camera.UnspecifiedStream, err
+	}
+	src, err := camera.NewVideoSourceFromReader(ctx, motion, &cameraModel, camera.ColorStream)
+	if err != nil {
+		return nil, camera.UnspecifiedStream, err
+	}
+	return src, camera.ColorStream, err
+}
+
+// Read returns the foreground mask of the next image, or the image overlaid with its moving regions.
+func (ms *motionSource) Read(ctx context.Context) (image.Image, func(), error) {
+	ctx, span := trace.StartSpan(ctx, "camera::transformpipeline::motion::Read")
+	defer span.End()
+	img, release, err := ms.stream.Next(ctx)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not get next source image")
+	}
+	if ms.subtractor != nil {
+		return ms.subtractor.Apply(img), release, nil
+	}
+	dets, err := ms.detector(ctx, img)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not detect motion")
+	}
+	res, err := objectdetection.Overlay(img, dets)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not overlay motion")
+	}
+	return res, release, nil
+}
+
+// Close closes the underlying stream.
+func (ms *motionSource) Close(ctx context.Context) error {
+	return ms.stream.Close(ctx)
+}
@@ -0,0 +1,77 @@
+package transformpipeline
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/pion/mediadevices/pkg/prop"
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/gostream"
+	"go.viam.com/rdk/utils"
+)
+
+// movingSquareSource returns a source of frames of a square moving right by 5 pixels each frame, after the
+// given number of frames with no square.
+func movingSquareSource(still int) gostream.VideoSource {
+	i := 0
+	return gostream.NewVideoSource(gostream.VideoReaderFunc(func(ctx context.Context) (image.Image, func(), error) {
+		img := image.NewRGBA(image.Rect(0, 0, 100, 50))
+		draw.Draw(img, img.Bounds(), &image.Uniform{color.RGBA{20, 40, 60, 255}}, image.Point{}, draw.Src)
+		if i >= still {
+			x := 5 * (i - still)
+			draw.Draw(img, image.Rect(x, 20, x+10, 30), &image.Uniform{color.White}, image.Point{}, draw.Src)
+		}
+		i++
+		return img, func() {}, nil
+	}), prop.Video{})
+}
+
+func TestMotionTransform(t *testing.T) {
+	ctx := context.Background()
+
+	src, stream, err := newMotionTransform(ctx, movingSquareSource(3), utils.AttributeMap{"threshold": 30})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, stream, test.ShouldEqual, camera.ColorStream)
+	for i := 0; i < 3; i++ {
+		img, _, err := camera.ReadImage(ctx, src)
+		test.That(t, err, test.ShouldBeNil)
+		mask, ok := img.(*image.Gray)
+		test.That(t, ok, test.ShouldBeTrue)
+		test.That(t, mask.GrayAt(2, 25).Y, test.ShouldEqual, 0)
+	}
+	img, _, err := camera.ReadImage(ctx, src)
+	test.That(t, err, test.ShouldBeNil)
+	mask := img.(*image.Gray)
+	test.That(t, mask.GrayAt(2, 25).Y, test.ShouldEqual, 255)
+	test.That(t, mask.GrayAt(12, 25).Y, test.ShouldEqual, 0)
+	test.That(t, mask.GrayAt(50, 10).Y, test.ShouldEqual, 0)
+	test.That(t, src.Close(ctx), test.ShouldBeNil)
+
+	src, _, err = newMotionTransform(ctx, movingSquareSource(1), utils.AttributeMap{"output": "boxes", "min_area_px": 20})
+	test.That(t, err, test.ShouldBeNil)
+	img, _, err = camera.ReadImage(ctx, src)
+	test.That(t, err, test.ShouldBeNil)
+	r, g, b, _ := img.At(5, 20).RGBA()
+	test.That(t, []uint32{r >> 8, g >> 8, b >> 8}, test.ShouldResemble, []uint32{20, 40, 60})
+	img, _, err = camera.ReadImage(ctx, src)
+	test.That(t, err, test.ShouldBeNil)
+	// the moving square is boxed, and the background is left as is.
+	r, g, b, _ = img.At(5, 20).RGBA()
+	test.That(t, r>>8, test.ShouldBeGreaterThan, 200)
+	test.That(t, g>>8, test.ShouldBeLessThan, 200)
+	test.That(t, b>>8, test.ShouldBeLessThan, 200)
+	r, g, b, _ = img.At(80, 45).RGBA()
+	test.That(t, []uint32{r >> 8, g >> 8, b >> 8}, test.ShouldResemble, []uint32{20, 40, 60})
+	test.That(t, src.Close(ctx), test.ShouldBeNil)
+
+	_, _, err = newMotionTransform(ctx, movingSquareSource(0), utils.AttributeMap{"output": "video"})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "output")
+	_, _, err = newMotionTransform(ctx, movingSquareSource(0), utils.AttributeMap{"method": "knn"})
+	test.That(t, err, test.ShouldNotBeNil)
+}
@@ -0,0 +1,212 @@
+package transformpipeline
+
+import (
+	"context"
+	"image"
+	"sync"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+	"golang.org/x/sync/errgroup"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/rimage/transform"
+)
+
+var panoramaModel = resource.DefaultModelFamily.WithModel("panorama")
+
+func init() {
+	resource.RegisterComponent(
+		camera.API,
+		panoramaModel,
+		resource.Registration[camera.Camera, *panoramaConfig]{
+			Constructor: newPanoramaCamera,
+		})
+}
+
+// panoramaConfig specifies the cameras stitched into a panorama.
+type panoramaConfig struct {
+	// SourceCameras are in the order they are around the rig, each overlapping the ones next to it. The
+	// panorama is in the image plane of the middle one.
+	SourceCameras []string `json:"source_cameras"`
+	// Homographies are the 3x3 homographies, by row, mapping the pixels of each camera to the pixels of the
+	// middle camera. They are estimated from the first images of the cameras if not given.
+	Homographies [][]float64 `json:"homographies,omitempty"`
+	Blend        string      `json:"blend,omitempty"`
+}
+
+// Validate ensures all parts of the config are valid.
+func (cfg *panoramaConfig) Validate(path string) ([]string, error) {
+	if len(cfg.SourceCameras) < 2 {
+		return nil, resource.NewConfigValidationError(path, errors.New("a panorama needs at least 2 source_cameras"))
+	}
+	seen := make(map[string]bool, len(cfg.SourceCameras))
+	for _, name := range cfg.SourceCameras {
+		if name == "" {
+			return nil, resource.NewConfigValidationError(path, errors.New("source_cameras cannot have empty names"))
+		}
+		if seen[name] {
+			return nil, resource.NewConfigValidationError(path, errors.Errorf("camera %q is in source_cameras more than once", name))
+		}
+		seen[name] = true
+	}
+	if len(cfg.Homographies) != 0 && len(cfg.Homographies) != len(cfg.SourceCameras) {
+		return nil, resource.NewConfigValidationError(path, errors.Errorf(
+			"homographies must have one homography per source camera, %d, got %d", len(cfg.SourceCameras), len(cfg.Homographies)))
+	}
+	if _, err := cfg.homographies(); err != nil {
+		return nil, resource.NewConfigValidationError(path, err)
+	}
+	switch cfg.Blend {
+	case "", transform.PanoramaBlendFeather, transform.PanoramaBlendSeam:
+	default:
+		return nil, resource.NewConfigValidationError(path, errors.Errorf(
+			"blend must be %q or %q, got %q", transform.PanoramaBlendFeather, transform.PanoramaBlendSeam, cfg.Blend))
+	}
+	return cfg.SourceCameras, nil
+}
+
+// homographies returns the homographies of the config, or nil if they are to be estimated.
+func (cfg *panoramaConfig) homographies() ([]*transform.Homography, error) {
+	if len(cfg.Homographies) == 0 {
+		return nil, nil
+	}
+	homographies := make([]*transform.Homography, len(cfg.Homographies))
+	for i, vals := range cfg.Homographies {
+		h, err := transform.NewHomography(vals)
+		if err != nil {
+			return nil, errors.Wrapf(err, "homography of %q", cfg.SourceCameras[i])
+		}
+		if _, err := h.Inverse(); err != nil {
+			return nil, errors.Wrapf(err, "homography of %q", cfg.SourceCameras[i])
+		}
+		homographies[i] = h
+	}
+	return homographies, nil
+}
+
+// panoramaSource stitches the images of its cameras into a panorama.
+type panoramaSource struct {
+	cameras      []camera.Camera
+	homographies []*transform.Homography
+	blend        string
+	logger       logging.Logger
+
+	mu sync.Mutex
+	// panorama is made from the first images, and remade if the size of the images changes.
+	panorama *transform.Panorama
+	sizes    []image.Point
+}
+
+func newPanoramaCamera(
+	ctx context.Context,
+	deps resource.Dependencies,
+	conf resource.Config,
+	logger logging.Logger,
+) (camera.Camera, error) {
+	newConf, err := resource.NativeConfig[*panoramaConfig](conf)
+	if err != nil {
+		return nil, err
+	}
+	homographies, err := newConf.homographies()
+	if err != nil {
+		return nil, err
+	}
+	cameras := make([]camera.Camera, 0, len(newConf.SourceCameras))
+	for _, name := range newConf.SourceCameras {
+		cam, err := camera.FromDependencies(deps, name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "no source camera for panorama (%s)", name)
+		}
+		cameras = append(cameras, cam)
+	}
+	reader := &panoramaSource{cameras: cameras, homographies: homographies, blend: newConf.Blend, logger: logger}
+	src, err := camera.NewVideoSourceFromReader(ctx, reader, nil, camera.ColorStream)
+	if err != nil {
+		return nil, err
+	}
+	return camera.FromVideoSource(conf.ResourceName(), src, logger), nil
+}
+
+// Read returns the panorama of the next images of all the cameras.
+func (ps *panoramaSource) Read(ctx context.Context) (image.Image, func(), error) {
+	ctx, span := trace.StartSpan(ctx, "camera::transformpipeline::panorama::Read")
+	defer span.End()
+	imgs := make([]image.Image, len(ps.cameras))
+	releases := make([]func(), len(ps.cameras))
+	// the images are released once they are stitched.
+	defer func() {
+		for _, release := range releases {
+			if release != nil {
+				release()
+			}
+		}
+	}()
+	g, gctx := errgroup.WithContext(ctx)
+	for i, cam := range ps.cameras {
+		g.Go(func() error {
+			img, release, err := camera.ReadImage(gctx, cam)
+			if err != nil {
+				return errors.Wrapf(err, "could not get image from %s", cam.Name().ShortName())
+			}
+			imgs[i], releases[i] = img, release
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, nil, err
+	}
+	panorama, err := ps.panoramaOf(imgs)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, err := panorama.Stitch(imgs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, func() {}, nil
+}
+
+// panoramaOf returns the panorama of images of the sizes of the images, estimating the homographies of the
+// cameras from them if there are none in the config.
+func (ps *panoramaSource) panoramaOf(imgs []image.Image) (*transform.Panorama, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	sizes := make([]image.Point, len(imgs))
+	same := ps.panorama != nil
+	for i, img := range imgs {
+		sizes[i] = img.Bounds().Size()
+		same = same && sizes[i] == ps.sizes[i]
+	}
+	if same {
+		return ps.panorama, nil
+	}
+	homographies := ps.homographies
+	if homographies == nil {
+		var err error
+		if homographies, err = transform.EstimatePanoramaHomographies(imgs, ps.logger); err != nil {
+			return nil, errors.Wrap(err, "cannot estimate the homographies of the cameras, set them in the config")
+		}
+		estimated := make([][]float64, len(homographies))
+		for i, h := range homographies {
+			estimated[i] = make([]float64, 0, 9)
+			for row := 0; row < 3; row++ {
+				estimated[i] = append(estimated[i], h.At(row, 0), h.At(row, 1), h.At(row, 2))
+			}
+		}
+		ps.logger.Infow("estimated the homographies of the panorama, set them in the config to keep them", "homographies", estimated)
+	}
+	panorama, err := transform.NewPanorama(sizes, homographies, ps.blend)
+	if err != nil {
+		return nil, err
+	}
+	ps.panorama, ps.sizes = panorama, sizes
+	return panorama, nil
+}
+
+// Close does nothing, as the cameras of the panorama are dependencies rather than owned by it.
+func (ps *panoramaSource) Close(ctx context.Context) error {
+	return nil
+}
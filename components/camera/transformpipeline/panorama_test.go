@@ -0,0 +1,108 @@
+package transformpipeline
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/rimage/transform"
+)
+
+// panoramaTestCameras returns cameras seeing windows of a scene of random blocks, each window 100 pixels right
+// of the last and overlapping it by half.
+func panoramaTestCameras(t *testing.T) (*image.RGBA, resource.Dependencies) {
+	t.Helper()
+	const block = 8
+	rng := rand.New(rand.NewSource(5))
+	scene := image.NewRGBA(image.Rect(0, 0, 400, 160))
+	for by := 0; by < 160; by += block {
+		for bx := 0; bx < 400; bx += block {
+			v := uint8(rng.Intn(256))
+			for y := by; y < by+block; y++ {
+				for x := bx; x < bx+block; x++ {
+					scene.SetRGBA(x, y, color.RGBA{v, 255 - v, v / 2, 255})
+				}
+			}
+		}
+	}
+	deps := resource.Dependencies{}
+	for i, name := range []string{"left", "middle", "right"} {
+		img := scene.SubImage(image.Rect(100*i, 0, 100*i+200, 160))
+		deps[camera.Named(name)] = stereoTestCamera(t, name, img, nil)
+	}
+	return scene, deps
+}
+
+func TestPanoramaCamera(t *testing.T) {
+	ctx := context.Background()
+	logger := logging.NewTestLogger(t)
+	scene, deps := panoramaTestCameras(t)
+	for _, dep := range deps {
+		defer dep.Close(ctx)
+	}
+
+	// the homographies are estimated, or given as the shifts of the cameras from the middle one.
+	for _, conf := range []*panoramaConfig{
+		{SourceCameras: []string{"left", "middle", "right"}},
+		{
+			SourceCameras: []string{"left", "middle", "right"},
+			Homographies: [][]float64{
+				{1, 0, -100, 0, 1, 0, 0, 0, 1},
+				{1, 0, 0, 0, 1, 0, 0, 0, 1},
+				{1, 0, 100, 0, 1, 0, 0, 0, 1},
+			},
+			Blend: transform.PanoramaBlendSeam,
+		},
+	} {
+		cfgDeps, err := conf.Validate("path")
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, cfgDeps, test.ShouldResemble, []string{"left", "middle", "right"})
+		cam, err := newPanoramaCamera(ctx, deps, resource.Config{Name: "panorama", ConvertedAttributes: conf}, logger)
+		test.That(t, err, test.ShouldBeNil)
+
+		img, _, err := camera.ReadImage(ctx, cam)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, img.Bounds().Dx(), test.ShouldBeBetweenOrEqual, 398, 402)
+		test.That(t, img.Bounds().Dy(), test.ShouldBeBetweenOrEqual, 158, 162)
+		// the panorama starts about where the scene does, and looks like it away from the edges of the blocks.
+		for _, p := range []image.Point{{12, 20}, {150, 84}, {252, 100}, {388, 140}} {
+			want := scene.RGBAAt(p.X, p.Y)
+			r, g, _, a := img.At(p.X, p.Y).RGBA()
+			test.That(t, a>>8, test.ShouldEqual, 255)
+			test.That(t, float64(r>>8), test.ShouldAlmostEqual, float64(want.R), 8)
+			test.That(t, float64(g>>8), test.ShouldAlmostEqual, float64(want.G), 8)
+		}
+
+		imgs, _, err := cam.Images(ctx)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, imgs, test.ShouldHaveLength, 1)
+		test.That(t, imgs[0].Image.Bounds(), test.ShouldResemble, img.Bounds())
+		test.That(t, cam.Close(ctx), test.ShouldBeNil)
+	}
+}
+
+func TestPanoramaConfigValidate(t *testing.T) {
+	for _, tc := range []struct {
+		conf panoramaConfig
+		err  string
+	}{
+		{panoramaConfig{SourceCameras: []string{"left"}}, "at least 2"},
+		{panoramaConfig{SourceCameras: []string{"left", "left"}}, "more than once"},
+		{panoramaConfig{SourceCameras: []string{"left", ""}}, "empty"},
+		{panoramaConfig{SourceCameras: []string{"left", "right"}, Homographies: [][]float64{{1, 0, 0, 0, 1, 0, 0, 0, 1}}}, "one homography"},
+		{panoramaConfig{SourceCameras: []string{"left", "right"}, Homographies: [][]float64{{1}, {1}}}, "length of 9"},
+		{panoramaConfig{SourceCameras: []string{"left", "right"}, Homographies: [][]float64{make([]float64, 9), make([]float64, 9)}}, "invertible"},
+		{panoramaConfig{SourceCameras: []string{"left", "right"}, Blend: "average"}, "blend"},
+	} {
+		_, err := tc.conf.Validate("path")
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, tc.err)
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"go.viam.com/rdk/services/vision"
 	"go.viam.com/rdk/spatialmath"
 	"go.viam.com/rdk/utils"
+	"go.viam.com/rdk/vision/objectdetection"
 )
 
 // segmenterConfig is the attribute struct for segementers (their name as found in the vision service).
@@ -106,13 +107,42 @@ func (ss *segmenterSource) NextPointCloud(ctx context.Context) (pointcloud.Point
 	return mergedCloud, nil
 }
 
-// Read returns the image if the stream is valid, else error.
+// Read returns the image if the stream is valid, else error. If the segmenter is also a detector whose
+// detections have masks or keypoints, they are drawn on the image.
 func (ss *segmenterSource) Read(ctx context.Context) (image.Image, func(), error) {
 	img, release, err := ss.stream.Next(ctx)
 	if err != nil {
 		return nil, nil, fmt.Errorf("could not get next source image: %w", err)
 	}
-	return img, release, nil
+	srv, err := vision.FromRobot(ss.r, ss.segmenterName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("source_segmenter cant find vision service: %w", err)
+	}
+	props, err := srv.GetProperties(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not get vision service properties: %w", err)
+	}
+	if !props.DetectionSupported {
+		return img, release, nil
+	}
+	dets, err := srv.Detections(ctx, img, map[string]interface{}{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not get detections: %w", err)
+	}
+	instances := make([]objectdetection.Detection, 0, len(dets))
+	for _, d := range dets {
+		if objectdetection.MaskOf(d) != nil || len(objectdetection.KeypointsOf(d)) > 0 {
+			instances = append(instances, d)
+		}
+	}
+	if len(instances) == 0 {
+		return img, release, nil
+	}
+	res, err := objectdetection.Overlay(img, instances)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not overlay segmentations: %w", err)
+	}
+	return res, release, nil
 }
 
 // Close closes the underlying stream.
@@ -17,6 +17,7 @@ import (
 	"go.viam.com/rdk/testutils/inject"
 	"go.viam.com/rdk/utils"
 	"go.viam.com/rdk/vision"
+	"go.viam.com/rdk/vision/objectdetection"
 	segment "go.viam.com/rdk/vision/segmentation"
 )
 
@@ -164,3 +165,47 @@ func TestTransformSegmenterFunctionality(t *testing.T) {
 	_, isValid = pc.At(0, 1, 0)
 	test.That(t, isValid, test.ShouldBeTrue)
 }
+
+func TestTransformSegmenterReadDrawsInstances(t *testing.T) {
+	r := &inject.Robot{}
+	vizServ := &inject.VisionService{}
+	r.ResourceByNameFunc = func(n resource.Name) (resource.Resource, error) {
+		if n.Name == "fakeVizService" {
+			return vizServ, nil
+		}
+		return nil, resource.NewNotFoundError(n)
+	}
+	props := &vizservices.Properties{ObjectPCDsSupported: true}
+	vizServ.GetPropertiesFunc = func(ctx context.Context, extra map[string]interface{}) (*vizservices.Properties, error) {
+		return props, nil
+	}
+	mask := image.NewAlpha(image.Rect(600, 600, 700, 700))
+	for y := 650; y < 700; y++ {
+		for x := 650; x < 700; x++ {
+			mask.SetAlpha(x, y, color.Alpha{255})
+		}
+	}
+	vizServ.DetectionsFunc = func(ctx context.Context, img image.Image, extra map[string]interface{},
+	) ([]objectdetection.Detection, error) {
+		return []objectdetection.Detection{
+			objectdetection.NewDetection(image.Rect(0, 0, 100, 100), 0.9, "box"),
+			objectdetection.NewInstanceDetection(image.Rect(600, 600, 700, 700), 0.9, "instance", mask, nil),
+		}, nil
+	}
+	ss := &segmenterSource{stream: &streamTest{}, segmenterName: "fakeVizService", r: r}
+
+	// a segmenter which is not a detector has nothing to draw.
+	img, _, err := ss.Read(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+	red, _, _, _ := img.At(675, 675).RGBA()
+	test.That(t, red, test.ShouldEqual, 0)
+
+	// only the detection with a mask is drawn.
+	props.DetectionSupported = true
+	img, _, err = ss.Read(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+	red, _, _, _ = img.At(675, 675).RGBA()
+	test.That(t, red, test.ShouldBeGreaterThan, 0)
+	red, _, _, _ = img.At(50, 0).RGBA()
+	test.That(t, red, test.ShouldEqual, 0)
+}
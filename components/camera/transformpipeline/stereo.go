@@ -0,0 +1,257 @@
+package transformpipeline
+
+import (
+	"context"
+	"image"
+	"image/color"
+
+	"github.com/golang/geo/r3"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+	"golang.org/x/sync/errgroup"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/rimage/transform"
+	"go.viam.com/rdk/spatialmath"
+)
+
+var stereoModel = resource.DefaultModelFamily.WithModel("stereo_depth")
+
+func init() {
+	resource.RegisterComponent(
+		camera.API,
+		stereoModel,
+		resource.Registration[camera.Camera, *stereoConfig]{
+			Constructor: newStereoCamera,
+		})
+}
+
+// stereoConfig specifies the two cameras of a stereo pair, their calibration and how their images are matched.
+type stereoConfig struct {
+	LeftCamera  string `json:"left_camera"`
+	RightCamera string `json:"right_camera"`
+	// the intrinsics and distortion of each camera default to those in the properties of the camera.
+	LeftIntrinsicParams   *transform.PinholeCameraIntrinsics `json:"left_intrinsic_parameters,omitempty"`
+	LeftDistortionParams  *transform.BrownConrady            `json:"left_distortion_parameters,omitempty"`
+	RightIntrinsicParams  *transform.PinholeCameraIntrinsics `json:"right_intrinsic_parameters,omitempty"`
+	RightDistortionParams *transform.BrownConrady            `json:"right_distortion_parameters,omitempty"`
+	// RightTranslation and RightOrientation are the pose of the right camera in the frame of the left camera,
+	// in millimeters.
+	RightTranslation r3.Vector                      `json:"right_translation_mm"`
+	RightOrientation *spatialmath.OrientationConfig `json:"right_orientation,omitempty"`
+	Matching         transform.StereoMatchingConfig `json:"matching,omitempty"`
+}
+
+// Validate ensures all parts of the config are valid.
+func (cfg *stereoConfig) Validate(path string) ([]string, error) {
+	if cfg.LeftCamera == "" {
+		return nil, resource.NewConfigValidationFieldRequiredError(path, "left_camera")
+	}
+	if cfg.RightCamera == "" {
+		return nil, resource.NewConfigValidationFieldRequiredError(path, "right_camera")
+	}
+	if cfg.LeftCamera == cfg.RightCamera {
+		return nil, resource.NewConfigValidationError(path, errors.New("left_camera and right_camera must be different cameras"))
+	}
+	if cfg.RightTranslation.Norm() == 0 {
+		return nil, resource.NewConfigValidationFieldRequiredError(path, "right_translation_mm")
+	}
+	if _, err := cfg.rightPose(); err != nil {
+		return nil, resource.NewConfigValidationError(path, err)
+	}
+	for name, intrinsics := range map[string]*transform.PinholeCameraIntrinsics{
+		"left_intrinsic_parameters":  cfg.LeftIntrinsicParams,
+		"right_intrinsic_parameters": cfg.RightIntrinsicParams,
+	} {
+		if intrinsics == nil {
+			continue
+		}
+		if err := intrinsics.CheckValid(); err != nil {
+			return nil, resource.NewConfigValidationError(path, errors.Wrap(err, name))
+		}
+	}
+	if err := cfg.Matching.CheckValid(); err != nil {
+		return nil, resource.NewConfigValidationError(path, errors.Wrap(err, "matching"))
+	}
+	return []string{cfg.LeftCamera, cfg.RightCamera}, nil
+}
+
+func (cfg *stereoConfig) rightPose() (spatialmath.Pose, error) {
+	if cfg.RightOrientation == nil {
+		return spatialmath.NewPoseFromPoint(cfg.RightTranslation), nil
+	}
+	o, err := cfg.RightOrientation.ParseConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "right_orientation")
+	}
+	return spatialmath.NewPose(cfg.RightTranslation, o), nil
+}
+
+// stereoSource computes the depth of the rectified image of the left camera of a stereo pair from the
+// disparity between it and the rectified image of the right camera.
+type stereoSource struct {
+	left, right   camera.Camera
+	rectification *transform.StereoRectification
+	matching      *transform.StereoMatchingConfig
+}
+
+func newStereoCamera(
+	ctx context.Context,
+	deps resource.Dependencies,
+	conf resource.Config,
+	logger logging.Logger,
+) (camera.Camera, error) {
+	newConf, err := resource.NativeConfig[*stereoConfig](conf)
+	if err != nil {
+		return nil, err
+	}
+	left, err := camera.FromDependencies(deps, newConf.LeftCamera)
+	if err != nil {
+		return nil, errors.Wrapf(err, "no left camera for stereo pair (%s)", newConf.LeftCamera)
+	}
+	right, err := camera.FromDependencies(deps, newConf.RightCamera)
+	if err != nil {
+		return nil, errors.Wrapf(err, "no right camera for stereo pair (%s)", newConf.RightCamera)
+	}
+	leftModel, err := stereoCameraModel(ctx, left, newConf.LeftIntrinsicParams, newConf.LeftDistortionParams)
+	if err != nil {
+		return nil, errors.Wrap(err, "left camera")
+	}
+	rightModel, err := stereoCameraModel(ctx, right, newConf.RightIntrinsicParams, newConf.RightDistortionParams)
+	if err != nil {
+		return nil, errors.Wrap(err, "right camera")
+	}
+	rightPose, err := newConf.rightPose()
+	if err != nil {
+		return nil, err
+	}
+	rectification, err := transform.NewStereoRectification(leftModel, rightModel, rightPose)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid stereo calibration")
+	}
+	reader := &stereoSource{left, right, rectification, &newConf.Matching}
+	cameraModel := &transform.PinholeCameraModel{PinholeCameraIntrinsics: rectification.Rectified}
+	src, err := camera.NewVideoSourceFromReader(ctx, reader, cameraModel, camera.DepthStream)
+	if err != nil {
+		return nil, err
+	}
+	return camera.FromVideoSource(conf.ResourceName(), src, logger), nil
+}
+
+// stereoCameraModel returns the model of one of the cameras of a stereo pair, with the intrinsics and
+// distortion of the config if there are any, else those of its properties.
+func stereoCameraModel(
+	ctx context.Context,
+	cam camera.Camera,
+	intrinsics *transform.PinholeCameraIntrinsics,
+	distortion *transform.BrownConrady,
+) (*transform.PinholeCameraModel, error) {
+	model := &transform.PinholeCameraModel{PinholeCameraIntrinsics: intrinsics}
+	if distortion != nil {
+		model.Distortion = distortion
+	}
+	if intrinsics != nil && distortion != nil {
+		return model, nil
+	}
+	props, err := cam.Properties(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if model.PinholeCameraIntrinsics == nil {
+		model.PinholeCameraIntrinsics = props.IntrinsicParams
+	}
+	if model.Distortion == nil && props.DistortionParams != nil {
+		model.Distortion = props.DistortionParams
+	}
+	if model.PinholeCameraIntrinsics == nil {
+		return nil, transform.NewNoIntrinsicsError("set them in the config or the camera")
+	}
+	return model, nil
+}
+
+// rectifiedPair reads the next images of both cameras at once and rectifies them.
+func (ss *stereoSource) rectifiedPair(ctx context.Context) (*image.RGBA, *image.RGBA, error) {
+	var leftImg, rightImg *image.RGBA
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		img, release, err := camera.ReadImage(gctx, ss.left)
+		if err != nil {
+			return errors.Wrap(err, "could not get image from left camera")
+		}
+		defer release()
+		leftImg, err = ss.rectification.RectifyLeft(img)
+		return err
+	})
+	g.Go(func() error {
+		img, release, err := camera.ReadImage(gctx, ss.right)
+		if err != nil {
+			return errors.Wrap(err, "could not get image from right camera")
+		}
+		defer release()
+		rightImg, err = ss.rectification.RectifyRight(img)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return nil, nil, err
+	}
+	return leftImg, rightImg, nil
+}
+
+func (ss *stereoSource) depth(ctx context.Context) (*image.RGBA, *rimage.DepthMap, error) {
+	left, right, err := ss.rectifiedPair(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	disparity, err := transform.ComputeDisparity(left, right, ss.matching)
+	if err != nil {
+		return nil, nil, err
+	}
+	return left, disparity.ToDepthMap(ss.rectification.Rectified.Fx, ss.rectification.BaselineMM), nil
+}
+
+// Read returns the depth map of the rectified image of the left camera.
+func (ss *stereoSource) Read(ctx context.Context) (image.Image, func(), error) {
+	ctx, span := trace.StartSpan(ctx, "camera::transformpipeline::stereo::Read")
+	defer span.End()
+	_, dm, err := ss.depth(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dm, func() {}, nil
+}
+
+// NextPointCloud returns the points of the depth map in the frame of the left camera, in the colors of its
+// image.
+func (ss *stereoSource) NextPointCloud(ctx context.Context) (pointcloud.PointCloud, error) {
+	ctx, span := trace.StartSpan(ctx, "camera::transformpipeline::stereo::NextPointCloud")
+	defer span.End()
+	img, dm, err := ss.depth(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pc := pointcloud.New()
+	for y := 0; y < dm.Height(); y++ {
+		for x := 0; x < dm.Width(); x++ {
+			d := dm.GetDepth(x, y)
+			if d == 0 {
+				continue
+			}
+			px, py, pz := ss.rectification.Rectified.PixelToPoint(float64(x), float64(y), float64(d))
+			p := ss.rectification.ToLeftCamera(r3.Vector{X: px, Y: py, Z: pz})
+			c := img.RGBAAt(x, y)
+			if err := pc.Set(p, pointcloud.NewColoredData(color.NRGBA{c.R, c.G, c.B, 255})); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return pc, nil
+}
+
+// Close does nothing, as the cameras of the pair are dependencies rather than owned by it.
+func (ss *stereoSource) Close(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,153 @@
+package transformpipeline
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"github.com/golang/geo/r3"
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/gostream"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/rimage/transform"
+)
+
+// stereoTestPair returns the images of a stereo pair looking at a random texture 500mm away, with a focal
+// length of 100 pixels and a baseline of 50mm, so that the disparity is 10 pixels.
+func stereoTestPair() (*image.RGBA, *image.RGBA) {
+	const width, height, disparity = 120, 60, 10
+	rng := rand.New(rand.NewSource(3))
+	texture := make([]uint8, (width+disparity)*height)
+	for i := range texture {
+		texture[i] = uint8(rng.Intn(256))
+	}
+	left := image.NewRGBA(image.Rect(0, 0, width, height))
+	right := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			l, r := texture[y*(width+disparity)+x], texture[y*(width+disparity)+x+disparity]
+			left.SetRGBA(x, y, color.RGBA{l, l, l, 255})
+			right.SetRGBA(x, y, color.RGBA{r, r, r, 255})
+		}
+	}
+	return left, right
+}
+
+func stereoTestCamera(t *testing.T, name string, img image.Image, intrinsics *transform.PinholeCameraIntrinsics) camera.Camera {
+	t.Helper()
+	reader := gostream.VideoReaderFunc(func(ctx context.Context) (image.Image, func(), error) {
+		return img, func() {}, nil
+	})
+	src, err := camera.NewVideoSourceFromReader(
+		context.Background(), reader, &transform.PinholeCameraModel{PinholeCameraIntrinsics: intrinsics}, camera.ColorStream)
+	test.That(t, err, test.ShouldBeNil)
+	return camera.FromVideoSource(camera.Named(name), src, logging.NewTestLogger(t))
+}
+
+func TestStereoCamera(t *testing.T) {
+	ctx := context.Background()
+	logger := logging.NewTestLogger(t)
+	intrinsics := &transform.PinholeCameraIntrinsics{Width: 120, Height: 60, Fx: 100, Fy: 100, Ppx: 60, Ppy: 30}
+	leftImg, rightImg := stereoTestPair()
+	left := stereoTestCamera(t, "left", leftImg, intrinsics)
+	defer left.Close(ctx)
+	// the intrinsics of the right camera are only in the config.
+	right := stereoTestCamera(t, "right", rightImg, nil)
+	defer right.Close(ctx)
+	deps := resource.Dependencies{camera.Named("left"): left, camera.Named("right"): right}
+
+	conf := &stereoConfig{
+		LeftCamera:           "left",
+		RightCamera:          "right",
+		RightIntrinsicParams: intrinsics,
+		RightTranslation:     r3.Vector{X: 50},
+		Matching:             transform.StereoMatchingConfig{NumDisparities: 16},
+	}
+	cfgDeps, err := conf.Validate("path")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, cfgDeps, test.ShouldResemble, []string{"left", "right"})
+	cam, err := newStereoCamera(ctx, deps, resource.Config{Name: "stereo", ConvertedAttributes: conf}, logger)
+	test.That(t, err, test.ShouldBeNil)
+	defer cam.Close(ctx)
+
+	props, err := cam.Properties(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, props.ImageType, test.ShouldEqual, camera.DepthStream)
+	test.That(t, props.IntrinsicParams.Fx, test.ShouldAlmostEqual, 100)
+
+	img, _, err := camera.ReadImage(ctx, cam)
+	test.That(t, err, test.ShouldBeNil)
+	dm, ok := img.(*rimage.DepthMap)
+	test.That(t, ok, test.ShouldBeTrue)
+	good, valid := 0, 0
+	for y := 5; y < 55; y++ {
+		for x := 30; x < 115; x++ {
+			d := dm.GetDepth(x, y)
+			if d == 0 {
+				continue
+			}
+			valid++
+			if d >= 485 && d <= 515 {
+				good++
+			}
+		}
+	}
+	test.That(t, valid, test.ShouldBeGreaterThan, 50*85*3/4)
+	test.That(t, float64(good)/float64(valid), test.ShouldBeGreaterThan, 0.95)
+
+	pc, err := cam.NextPointCloud(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pc.Size(), test.ShouldBeGreaterThan, 0)
+	near := 0
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		if p.Z >= 485 && p.Z <= 515 {
+			near++
+		}
+		return true
+	})
+	test.That(t, float64(near)/float64(pc.Size()), test.ShouldBeGreaterThan, 0.95)
+
+	// the left camera has to be on the left.
+	swapped := *conf
+	swapped.RightTranslation = r3.Vector{X: -50}
+	_, err = newStereoCamera(ctx, deps, resource.Config{Name: "stereo", ConvertedAttributes: &swapped}, logger)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "swapped")
+
+	// the intrinsics of the right camera are needed from somewhere.
+	noIntrinsics := *conf
+	noIntrinsics.RightIntrinsicParams = nil
+	_, err = newStereoCamera(ctx, deps, resource.Config{Name: "stereo", ConvertedAttributes: &noIntrinsics}, logger)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "right camera")
+}
+
+func TestStereoConfigValidate(t *testing.T) {
+	valid := stereoConfig{LeftCamera: "left", RightCamera: "right", RightTranslation: r3.Vector{X: 50}}
+	_, err := valid.Validate("path")
+	test.That(t, err, test.ShouldBeNil)
+
+	for _, tc := range []struct {
+		modify func(*stereoConfig)
+		err    string
+	}{
+		{func(c *stereoConfig) { c.LeftCamera = "" }, "left_camera"},
+		{func(c *stereoConfig) { c.RightCamera = "left" }, "different"},
+		{func(c *stereoConfig) { c.RightTranslation = r3.Vector{} }, "right_translation_mm"},
+		{func(c *stereoConfig) { c.LeftIntrinsicParams = &transform.PinholeCameraIntrinsics{Width: 10} }, "left_intrinsic_parameters"},
+		{func(c *stereoConfig) { c.Matching.BlockSize = 4 }, "matching"},
+	} {
+		conf := valid
+		tc.modify(&conf)
+		_, err := conf.Validate("path")
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, tc.err)
+	}
+}
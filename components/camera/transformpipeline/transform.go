@@ -30,6 +30,7 @@ const (
 	transformTypeSegmentations   = transformType("segmentations")
 	transformTypeDepthEdges      = transformType("depth_edges")
 	transformTypeDepthPreprocess = transformType("depth_preprocess")
+	transformTypeMotion          = transformType("motion")
 )
 
 // transformRegistration holds pertinent information regarding the available transforms.
@@ -110,6 +111,11 @@ var registeredTransformConfigs = map[transformType]*transformRegistration{
 		&depthPreprocessConfig{},
 		"Applies some basic hole-filling and edge smoothing to a depth map.",
 	},
+	transformTypeMotion: {
+		string(transformTypeMotion),
+		&motionConfig{},
+		"Learns the background of a static camera and returns the mask of what moves in each image, or the image with moving regions boxed.",
+	},
 }
 
 // Transformation states the type of transformation and the attributes that are specific to the given type.
@@ -167,6 +173,8 @@ func buildTransform(
 		return newDepthEdgesTransform(ctx, source, tr.Attributes)
 	case transformTypeDepthPreprocess:
 		return newDepthPreprocessTransform(ctx, source)
+	case transformTypeMotion:
+		return newMotionTransform(ctx, source, tr.Attributes)
 	default:
 		return nil, camera.UnspecifiedStream, errors.Errorf("do not know camera transform of type %q", tr.Type)
 	}
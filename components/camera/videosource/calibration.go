@@ -0,0 +1,132 @@
+package videosource
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/gostream"
+	"go.viam.com/rdk/rimage/transform"
+	"go.viam.com/rdk/vision/fiducial"
+)
+
+const (
+	startCalibrationCommand   = "start_calibration"
+	captureCalibrationCommand = "capture_calibration_frame"
+	computeCalibrationCommand = "compute_calibration"
+)
+
+// calibrator collects frames of a calibration target from a camera and calibrates its intrinsics from them.
+type calibrator struct {
+	mu     sync.Mutex
+	target transform.CalibrationTarget
+	views  []transform.CalibrationView
+	width  int
+	height int
+}
+
+// doCommand handles the calibration commands of cmd, reading frames from src, and returns whether cmd was one.
+//
+// "start_calibration" takes a fiducial.CalibrationTargetConfig describing the target and forgets any frames
+// collected before. "capture_calibration_frame" reads a frame and keeps it if the target is found in it.
+// "compute_calibration" calibrates the camera from the frames kept, optionally writing the result to the
+// file at "path", and returns intrinsic_parameters and distortion_parameters that can be copied into the
+// camera's config, with the reprojection error of the calibration.
+func (c *calibrator) doCommand(
+	ctx context.Context, src gostream.VideoSource, cmd map[string]interface{},
+) (map[string]interface{}, bool, error) {
+	if raw, ok := cmd[startCalibrationCommand]; ok {
+		var conf fiducial.CalibrationTargetConfig
+		data, err := json.Marshal(raw)
+		if err == nil {
+			err = json.Unmarshal(data, &conf)
+		}
+		if err != nil {
+			return nil, true, errors.Wrapf(err, "%s expects a calibration target", startCalibrationCommand)
+		}
+		target, err := fiducial.NewCalibrationTarget(&conf)
+		if err != nil {
+			return nil, true, err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.target = target
+		c.views = nil
+		return map[string]interface{}{startCalibrationCommand: true}, true, nil
+	}
+	if _, ok := cmd[captureCalibrationCommand]; ok {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.target == nil {
+			return nil, true, errors.Errorf("%s must be called before %s", startCalibrationCommand, captureCalibrationCommand)
+		}
+		img, release, err := camera.ReadImage(ctx, src)
+		if err != nil {
+			return nil, true, err
+		}
+		if release != nil {
+			defer release()
+		}
+		bounds := img.Bounds()
+		if len(c.views) > 0 && (bounds.Dx() != c.width || bounds.Dy() != c.height) {
+			return nil, true, errors.Errorf("frame is %dx%d but the frames before it are %dx%d",
+				bounds.Dx(), bounds.Dy(), c.width, c.height)
+		}
+		view, err := c.target.FindCorners(img)
+		found := err == nil
+		if err != nil && !errors.Is(err, transform.ErrCornersNotFound) {
+			return nil, true, err
+		}
+		if found {
+			c.views = append(c.views, view)
+			c.width, c.height = bounds.Dx(), bounds.Dy()
+		}
+		return map[string]interface{}{
+			"found":   found,
+			"corners": len(view.ImagePoints),
+			"frames":  len(c.views),
+		}, true, nil
+	}
+	if raw, ok := cmd[computeCalibrationCommand]; ok {
+		var args struct {
+			Path       string `json:"path"`
+			EstimateK3 bool   `json:"estimate_k3"`
+		}
+		if m, ok := raw.(map[string]interface{}); ok {
+			data, err := json.Marshal(m)
+			if err == nil {
+				err = json.Unmarshal(data, &args)
+			}
+			if err != nil {
+				return nil, true, errors.Wrapf(err, "%s expects path and estimate_k3", computeCalibrationCommand)
+			}
+		}
+		c.mu.Lock()
+		views, width, height := c.views, c.width, c.height
+		c.mu.Unlock()
+		calib, err := transform.CalibratePinholeIntrinsics(views, width, height,
+			&transform.IntrinsicCalibrationOptions{EstimateK3: args.EstimateK3})
+		if err != nil {
+			return nil, true, err
+		}
+		data, err := json.MarshalIndent(calib, "", "  ")
+		if err != nil {
+			return nil, true, err
+		}
+		if args.Path != "" {
+			if err := os.WriteFile(args.Path, data, 0o644); err != nil { //nolint:gosec
+				return nil, true, err
+			}
+		}
+		var resp map[string]interface{}
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return nil, true, err
+		}
+		return resp, true, nil
+	}
+	return nil, false, nil
+}
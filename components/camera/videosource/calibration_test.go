@@ -0,0 +1,96 @@
+package videosource
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pion/mediadevices/pkg/prop"
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/gostream"
+	"go.viam.com/rdk/resource"
+)
+
+// checkerboardFrame is a frame of a checkerboard of 9 by 6 inner corners facing the camera, with squares of
+// squarePx pixels and its top left square at offset.
+func checkerboardFrame(squarePx int, offset image.Point) image.Image {
+	img := image.NewGray(image.Rect(0, 0, 640, 480))
+	for y := 0; y < 480; y++ {
+		for x := 0; x < 640; x++ {
+			img.SetGray(x, y, color.Gray{Y: 220})
+			sx, sy := (x-offset.X)/squarePx, (y-offset.Y)/squarePx
+			if x >= offset.X && y >= offset.Y && sx <= 9 && sy <= 6 && (sx+sy)%2 == 0 {
+				img.SetGray(x, y, color.Gray{Y: 30})
+			}
+		}
+	}
+	return img
+}
+
+func TestCalibrator(t *testing.T) {
+	ctx := context.Background()
+	frames := []image.Image{
+		checkerboardFrame(30, image.Pt(100, 80)),
+		image.NewGray(image.Rect(0, 0, 640, 480)),
+		checkerboardFrame(24, image.Pt(260, 250)),
+		image.NewGray(image.Rect(0, 0, 320, 240)),
+	}
+	next := 0
+	src := gostream.NewVideoSource(gostream.VideoReaderFunc(func(ctx context.Context) (image.Image, func(), error) {
+		img := frames[next%len(frames)]
+		next++
+		return img, func() {}, nil
+	}), prop.Video{})
+	defer func() {
+		test.That(t, src.Close(ctx), test.ShouldBeNil)
+	}()
+	var c calibrator
+
+	_, ok, _ := c.doCommand(ctx, src, map[string]interface{}{"other": true})
+	test.That(t, ok, test.ShouldBeFalse)
+	_, ok, err := c.doCommand(ctx, src, map[string]interface{}{captureCalibrationCommand: true})
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, err, test.ShouldNotBeNil)
+	_, _, err = c.doCommand(ctx, src, map[string]interface{}{startCalibrationCommand: map[string]interface{}{"type": "circles"}})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	_, _, err = c.doCommand(ctx, src, map[string]interface{}{
+		startCalibrationCommand: map[string]interface{}{"cols": 9, "rows": 6, "square_size_mm": 25},
+	})
+	test.That(t, err, test.ShouldBeNil)
+	for _, found := range []bool{true, false, true} {
+		resp, _, err := c.doCommand(ctx, src, map[string]interface{}{captureCalibrationCommand: true})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, resp["found"], test.ShouldEqual, found)
+	}
+	_, _, err = c.doCommand(ctx, src, map[string]interface{}{captureCalibrationCommand: true})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "320x240")
+
+	path := filepath.Join(t.TempDir(), "calibration.json")
+	resp, _, err := c.doCommand(ctx, src, map[string]interface{}{computeCalibrationCommand: map[string]interface{}{"path": path}})
+	test.That(t, err, test.ShouldBeNil)
+	intrinsics, ok := resp["intrinsic_parameters"].(map[string]interface{})
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, intrinsics["width_px"], test.ShouldEqual, 640)
+	test.That(t, resp["distortion_parameters"], test.ShouldNotBeNil)
+	test.That(t, resp["rms_error_px"], test.ShouldBeLessThan, 0.5)
+	_, err = os.Stat(path)
+	test.That(t, err, test.ShouldBeNil)
+
+	// starting again forgets the frames collected.
+	_, _, err = c.doCommand(ctx, src, map[string]interface{}{
+		startCalibrationCommand: map[string]interface{}{"cols": 9, "rows": 6, "square_size_mm": 25},
+	})
+	test.That(t, err, test.ShouldBeNil)
+	_, _, err = c.doCommand(ctx, src, map[string]interface{}{computeCalibrationCommand: true})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	cam := &monitoredWebcam{}
+	_, err = cam.DoCommand(ctx, map[string]interface{}{"other": true})
+	test.That(t, err, test.ShouldBeError, resource.ErrDoUnimplemented)
+}
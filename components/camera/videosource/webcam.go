@@ -420,6 +420,8 @@ type monitoredWebcam struct {
 	activeBackgroundWorkers sync.WaitGroup
 	logger                  logging.Logger
 	originalLogger          logging.Logger
+
+	calibrator calibrator
 }
 
 func (c *monitoredWebcam) MediaProperties(ctx context.Context) (prop.Video, error) {
@@ -652,6 +654,16 @@ func (c *monitoredWebcam) Properties(ctx context.Context) (camera.Properties, er
 	return props, nil
 }
 
+// DoCommand calibrates the intrinsics of the webcam from frames of a calibration target, as calibrator
+// describes.
+func (c *monitoredWebcam) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	resp, ok, err := c.calibrator.doCommand(ctx, c, cmd)
+	if !ok {
+		return nil, resource.ErrDoUnimplemented
+	}
+	return resp, err
+}
+
 var (
 	errClosed       = errors.New("camera has been closed")
 	errDisconnected = errors.New("camera is disconnected; please try again in a few moments")
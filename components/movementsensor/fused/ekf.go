@@ -0,0 +1,249 @@
+package fused
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// indices into the filter state vector.
+// The state is expressed in a local tangent plane anchored at the first position fix,
+// with north and east in meters and the heading measured clockwise from north like a compass.
+const (
+	stateNorth = iota
+	stateEast
+	stateHeading
+	stateSpeed
+	stateYawRate
+	stateAccel
+	stateDim
+)
+
+var errSingularInnovation = errors.New("innovation covariance is singular")
+
+// processNoise holds the continuous-time noise densities used to grow the covariance between updates.
+type processNoise struct {
+	accel   float64 // variance of the jerk driving forward acceleration, (m/s^3)^2
+	yawRate float64 // variance of the angular acceleration driving yaw rate, (rad/s^2)^2
+}
+
+// measurement is a single time-stamped observation of a subset of the state.
+type measurement struct {
+	source string
+	time   time.Time
+	// indices of the state observed, in the same order as value.
+	observes []int
+	value    []float64
+	// variance of each observed value, the measurement noise is assumed diagonal.
+	variance []float64
+}
+
+// ekf is an extended kalman filter over a planar constant-acceleration, constant-turn-rate model.
+type ekf struct {
+	x *mat.VecDense
+	p *mat.Dense
+	q processNoise
+
+	// gate is the mahalanobis distance (in standard deviations) above which a measurement is rejected.
+	gate float64
+
+	lastUpdate  time.Time
+	initialized bool
+}
+
+func newEKF(q processNoise, gate float64) *ekf {
+	f := &ekf{
+		x:    mat.NewVecDense(stateDim, nil),
+		p:    mat.NewDense(stateDim, stateDim, nil),
+		q:    q,
+		gate: gate,
+	}
+	f.reset()
+	return f
+}
+
+// reset clears the state and sets a large initial uncertainty.
+func (f *ekf) reset() {
+	f.x.Zero()
+	f.p.Zero()
+	f.p.Set(stateNorth, stateNorth, 1e6)
+	f.p.Set(stateEast, stateEast, 1e6)
+	f.p.Set(stateHeading, stateHeading, math.Pi*math.Pi)
+	f.p.Set(stateSpeed, stateSpeed, 100)
+	f.p.Set(stateYawRate, stateYawRate, 10)
+	f.p.Set(stateAccel, stateAccel, 10)
+	f.initialized = false
+	f.lastUpdate = time.Time{}
+}
+
+// predict propagates the state to time t. Measurements which arrive out of order are applied
+// at the current filter time rather than rewinding the filter.
+func (f *ekf) predict(t time.Time) {
+	if !f.initialized {
+		f.lastUpdate = t
+		f.initialized = true
+		return
+	}
+	dt := t.Sub(f.lastUpdate).Seconds()
+	if dt <= 0 {
+		return
+	}
+	f.lastUpdate = t
+
+	heading := f.x.AtVec(stateHeading)
+	speed := f.x.AtVec(stateSpeed)
+	sin, cos := math.Sincos(heading)
+
+	// the average speed over the interval is used for the distance travelled
+	dist := speed*dt + 0.5*f.x.AtVec(stateAccel)*dt*dt
+
+	f.x.SetVec(stateNorth, f.x.AtVec(stateNorth)+dist*cos)
+	f.x.SetVec(stateEast, f.x.AtVec(stateEast)+dist*sin)
+	f.x.SetVec(stateHeading, wrapAngle(heading+f.x.AtVec(stateYawRate)*dt))
+	f.x.SetVec(stateSpeed, speed+f.x.AtVec(stateAccel)*dt)
+
+	// jacobian of the transition function
+	jac := mat.NewDense(stateDim, stateDim, nil)
+	for i := 0; i < stateDim; i++ {
+		jac.Set(i, i, 1)
+	}
+	jac.Set(stateNorth, stateHeading, -dist*sin)
+	jac.Set(stateNorth, stateSpeed, dt*cos)
+	jac.Set(stateNorth, stateAccel, 0.5*dt*dt*cos)
+	jac.Set(stateEast, stateHeading, dist*cos)
+	jac.Set(stateEast, stateSpeed, dt*sin)
+	jac.Set(stateEast, stateAccel, 0.5*dt*dt*sin)
+	jac.Set(stateHeading, stateYawRate, dt)
+	jac.Set(stateSpeed, stateAccel, dt)
+
+	var p mat.Dense
+	p.Mul(jac, f.p)
+	f.p.Mul(&p, jac.T())
+
+	// discretized white noise on the highest derivative of each chain
+	dt2, dt3 := dt*dt, dt*dt*dt
+	f.p.Set(stateAccel, stateAccel, f.p.At(stateAccel, stateAccel)+f.q.accel*dt)
+	f.p.Set(stateSpeed, stateSpeed, f.p.At(stateSpeed, stateSpeed)+f.q.accel*dt3/3)
+	f.p.Set(stateSpeed, stateAccel, f.p.At(stateSpeed, stateAccel)+f.q.accel*dt2/2)
+	f.p.Set(stateAccel, stateSpeed, f.p.At(stateAccel, stateSpeed)+f.q.accel*dt2/2)
+	f.p.Set(stateYawRate, stateYawRate, f.p.At(stateYawRate, stateYawRate)+f.q.yawRate*dt)
+	f.p.Set(stateHeading, stateHeading, f.p.At(stateHeading, stateHeading)+f.q.yawRate*dt3/3)
+	f.p.Set(stateHeading, stateYawRate, f.p.At(stateHeading, stateYawRate)+f.q.yawRate*dt2/2)
+	f.p.Set(stateYawRate, stateHeading, f.p.At(stateYawRate, stateHeading)+f.q.yawRate*dt2/2)
+}
+
+// update runs the kalman correction for m. It returns the squared mahalanobis distance of the innovation
+// and whether the measurement was accepted by the outlier gate. When force is set the gate is skipped.
+func (f *ekf) update(m measurement, force bool) (float64, bool, error) {
+	f.predict(m.time)
+
+	n := len(m.observes)
+	h := mat.NewDense(n, stateDim, nil)
+	r := mat.NewDense(n, n, nil)
+	y := mat.NewVecDense(n, nil)
+	for i, idx := range m.observes {
+		h.Set(i, idx, 1)
+		r.Set(i, i, m.variance[i])
+		innovation := m.value[i] - f.x.AtVec(idx)
+		if idx == stateHeading {
+			innovation = wrapAngle(innovation)
+		}
+		y.SetVec(i, innovation)
+	}
+
+	// S = H P H^T + R
+	var ph, s mat.Dense
+	ph.Mul(f.p, h.T())
+	s.Mul(h, &ph)
+	s.Add(&s, r)
+
+	var sInv mat.Dense
+	if err := sInv.Inverse(&s); err != nil {
+		return math.NaN(), false, errSingularInnovation
+	}
+
+	var tmp mat.VecDense
+	tmp.MulVec(&sInv, y)
+	d2 := mat.Dot(y, &tmp)
+	if !force && d2 > chiSquareGate(f.gate, n) {
+		return d2, false, nil
+	}
+
+	// K = P H^T S^-1
+	var k mat.Dense
+	k.Mul(&ph, &sInv)
+
+	var dx mat.VecDense
+	dx.MulVec(&k, y)
+	f.x.AddVec(f.x, &dx)
+	f.x.SetVec(stateHeading, wrapAngle(f.x.AtVec(stateHeading)))
+
+	// joseph form keeps P symmetric and positive definite: P = (I-KH) P (I-KH)^T + K R K^T
+	ikh := mat.NewDense(stateDim, stateDim, nil)
+	ikh.Mul(&k, h)
+	for i := 0; i < stateDim; i++ {
+		for j := 0; j < stateDim; j++ {
+			v := -ikh.At(i, j)
+			if i == j {
+				v++
+			}
+			ikh.Set(i, j, v)
+		}
+	}
+	var left, krk, kr mat.Dense
+	left.Mul(ikh, f.p)
+	f.p.Mul(&left, ikh.T())
+	kr.Mul(&k, r)
+	krk.Mul(&kr, k.T())
+	f.p.Add(f.p, &krk)
+
+	return d2, true, nil
+}
+
+// setState overwrites a single state value and its variance, dropping its correlations.
+// It is used to seed the filter with the first reading of a quantity.
+func (f *ekf) setState(idx int, value, variance float64) {
+	for i := 0; i < stateDim; i++ {
+		f.p.Set(idx, i, 0)
+		f.p.Set(i, idx, 0)
+	}
+	f.x.SetVec(idx, value)
+	f.p.Set(idx, idx, variance)
+}
+
+func (f *ekf) state(idx int) float64 {
+	return f.x.AtVec(idx)
+}
+
+func (f *ekf) stddev(idx int) float64 {
+	return math.Sqrt(f.p.At(idx, idx))
+}
+
+// chiSquareGate converts a gate expressed in standard deviations into the equivalent
+// chi-square threshold for a measurement with dof degrees of freedom, keeping the
+// same rejection probability as the one dimensional case.
+func chiSquareGate(sigma float64, dof int) float64 {
+	if dof <= 1 {
+		return sigma * sigma
+	}
+	// probability mass inside sigma for a one dimensional gaussian
+	p := math.Erf(sigma / math.Sqrt2)
+	if dof == 2 {
+		return -2 * math.Log(1-p)
+	}
+	// wilson-hilferty approximation for higher degrees of freedom
+	k := float64(dof)
+	z := math.Sqrt2 * math.Erfinv(2*p-1)
+	return k * math.Pow(1-2/(9*k)+z*math.Sqrt(2/(9*k)), 3)
+}
+
+// wrapAngle wraps an angle in radians into [-pi, pi).
+func wrapAngle(a float64) float64 {
+	a = math.Mod(a+math.Pi, 2*math.Pi)
+	if a < 0 {
+		a += 2 * math.Pi
+	}
+	return a - math.Pi
+}
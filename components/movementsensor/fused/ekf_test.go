@@ -0,0 +1,129 @@
+package fused
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+func newTestEKF() *ekf {
+	return newEKF(processNoise{accel: 1, yawRate: 0.01}, defaultOutlierSigma)
+}
+
+func TestEKFConstantVelocity(t *testing.T) {
+	f := newTestEKF()
+	//nolint:gosec
+	rng := rand.New(rand.NewSource(1))
+
+	start := time.Now()
+	speed := 2.0
+	heading := math.Pi / 4
+	f.predict(start)
+	f.setState(stateNorth, 0, 1)
+	f.setState(stateEast, 0, 1)
+	f.setState(stateHeading, heading, 0.01)
+
+	rejected := 0
+	// gps at 5Hz, odometry at 20Hz, the readings are interleaved and asynchronous
+	for i := 1; i <= 400; i++ {
+		now := start.Add(time.Duration(i) * 50 * time.Millisecond)
+		dist := speed * now.Sub(start).Seconds()
+		_, accepted, err := f.update(measurement{
+			source: "odom", time: now, observes: []int{stateSpeed},
+			value: []float64{speed + rng.NormFloat64()*0.05}, variance: []float64{0.05 * 0.05},
+		}, false)
+		test.That(t, err, test.ShouldBeNil)
+		if !accepted {
+			rejected++
+		}
+
+		if i%4 == 0 {
+			gpsTime := now.Add(-10 * time.Millisecond)
+			gpsDist := speed * gpsTime.Sub(start).Seconds()
+			_, _, err = f.update(measurement{
+				source: "gps", time: gpsTime, observes: []int{stateNorth, stateEast},
+				value: []float64{
+					gpsDist*math.Cos(heading) + rng.NormFloat64(),
+					gpsDist*math.Sin(heading) + rng.NormFloat64(),
+				},
+				variance: []float64{1, 1},
+			}, false)
+			test.That(t, err, test.ShouldBeNil)
+		}
+
+		if i == 400 {
+			test.That(t, f.state(stateNorth), test.ShouldAlmostEqual, dist*math.Cos(heading), 1)
+			test.That(t, f.state(stateEast), test.ShouldAlmostEqual, dist*math.Sin(heading), 1)
+		}
+	}
+	// gaussian noise only rarely lands outside a three sigma gate
+	test.That(t, rejected, test.ShouldBeLessThan, 5)
+	test.That(t, f.state(stateSpeed), test.ShouldAlmostEqual, speed, 0.1)
+	test.That(t, f.state(stateHeading), test.ShouldAlmostEqual, heading, 0.1)
+	// fusing many readings should leave the position tighter than a single gps reading
+	test.That(t, f.stddev(stateNorth), test.ShouldBeLessThan, 1)
+}
+
+func TestEKFOutlierRejection(t *testing.T) {
+	f := newTestEKF()
+	start := time.Now()
+	f.predict(start)
+	f.setState(stateNorth, 0, 0.25)
+	f.setState(stateEast, 0, 0.25)
+	f.setState(stateSpeed, 0, 0.01)
+
+	d2, accepted, err := f.update(measurement{
+		source: "gps", time: start.Add(100 * time.Millisecond), observes: []int{stateNorth, stateEast},
+		value: []float64{0.3, -0.2}, variance: []float64{0.25, 0.25},
+	}, false)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, accepted, test.ShouldBeTrue)
+	test.That(t, d2, test.ShouldBeLessThan, chiSquareGate(defaultOutlierSigma, 2))
+
+	// a 50 meter jump is far outside the gate
+	north := f.state(stateNorth)
+	d2, accepted, err = f.update(measurement{
+		source: "gps", time: start.Add(200 * time.Millisecond), observes: []int{stateNorth, stateEast},
+		value: []float64{50, 0}, variance: []float64{0.25, 0.25},
+	}, false)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, accepted, test.ShouldBeFalse)
+	test.That(t, d2, test.ShouldBeGreaterThan, chiSquareGate(defaultOutlierSigma, 2))
+	test.That(t, f.state(stateNorth), test.ShouldAlmostEqual, north, 1e-3)
+
+	// forcing the update ignores the gate
+	_, accepted, err = f.update(measurement{
+		source: "gps", time: start.Add(300 * time.Millisecond), observes: []int{stateNorth, stateEast},
+		value: []float64{50, 0}, variance: []float64{0.25, 0.25},
+	}, true)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, accepted, test.ShouldBeTrue)
+	test.That(t, f.state(stateNorth), test.ShouldBeGreaterThan, north)
+}
+
+func TestEKFHeadingWrap(t *testing.T) {
+	f := newTestEKF()
+	start := time.Now()
+	f.predict(start)
+	f.setState(stateHeading, math.Pi-0.05, 0.01)
+
+	// a reading just across the wrap is a small innovation, not a full turn
+	_, accepted, err := f.update(measurement{
+		source: "imu", time: start.Add(10 * time.Millisecond), observes: []int{stateHeading},
+		value: []float64{-math.Pi + 0.05}, variance: []float64{0.01},
+	}, false)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, accepted, test.ShouldBeTrue)
+	test.That(t, math.Abs(f.state(stateHeading)), test.ShouldBeGreaterThan, math.Pi-0.05)
+}
+
+func TestChiSquareGate(t *testing.T) {
+	test.That(t, chiSquareGate(3, 1), test.ShouldAlmostEqual, 9)
+	test.That(t, chiSquareGate(3, 2), test.ShouldAlmostEqual, 11.83, 0.01)
+	test.That(t, chiSquareGate(3, 3), test.ShouldAlmostEqual, 14.16, 0.2)
+	test.That(t, wrapAngle(3*math.Pi/2), test.ShouldAlmostEqual, -math.Pi/2)
+	test.That(t, wrapAngle(-3*math.Pi/2), test.ShouldAlmostEqual, math.Pi/2)
+}
@@ -0,0 +1,600 @@
+// Package fused implements a movementsensor that fuses gps, imu and wheeled odometry
+// readings with an extended kalman filter.
+package fused
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/golang/geo/r3"
+	geo "github.com/kellydunn/golang-geo"
+	"github.com/pkg/errors"
+	goutils "go.viam.com/utils"
+
+	"go.viam.com/rdk/components/movementsensor"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/utils"
+)
+
+var model = resource.DefaultModelFamily.WithModel("fused")
+
+const (
+	defaultUpdateRateHz       = 10.
+	defaultOutlierSigma       = 3.
+	defaultPositionStdM       = 2.5
+	defaultHeadingStdDegs     = 5.
+	defaultYawRateStdDegsPerS = 1.
+	defaultSpeedStdMPerS      = 0.1
+	defaultAccelStdMPerS2     = 0.5
+	defaultAccelNoise         = 1.
+	defaultYawRateNoise       = 0.1
+
+	// a quantity rejected this many times in a row is assumed to have really jumped
+	// (for example a gps reacquiring an RTK fix) and the filter is re-seeded from it.
+	maxConsecutiveRejections = 10
+
+	// standard deviation of an RTK fixed and RTK float position, in meters.
+	rtkFixedStdM = 0.02
+	rtkFloatStdM = 0.5
+	// user equivalent range error, multiplied by the hdop to estimate a standalone gps position error.
+	uereM = 3.
+
+	// keys of the extra values reported by Readings and Accuracy.
+	positionStdKey = "position_std_m"
+	headingStdKey  = "heading_std_deg"
+	speedStdKey    = "speed_std_m_per_sec"
+	yawRateStdKey  = "yaw_rate_std_deg_per_sec"
+	rejectedKey    = "rejected_measurements"
+)
+
+var errNoSources = errors.New("fused movement sensor needs at least one position, imu or odometry sensor")
+
+// Config is the config of the fused movement_sensor model.
+type Config struct {
+	Position []string `json:"position,omitempty"`
+	IMU      []string `json:"imu,omitempty"`
+	Odometry []string `json:"odometry,omitempty"`
+
+	UpdateRateHz float64 `json:"update_rate_hz,omitempty"`
+	// OutlierSigma is the mahalanobis distance, in standard deviations, past which a reading is discarded.
+	OutlierSigma float64 `json:"outlier_rejection_sigma,omitempty"`
+
+	// defaults used when a sensor does not report its accuracy.
+	PositionStdM       float64 `json:"default_position_std_m,omitempty"`
+	HeadingStdDegs     float64 `json:"default_heading_std_degs,omitempty"`
+	YawRateStdDegsPerS float64 `json:"default_yaw_rate_std_degs_per_sec,omitempty"`
+	SpeedStdMPerS      float64 `json:"default_speed_std_m_per_sec,omitempty"`
+	AccelStdMPerS2     float64 `json:"default_acceleration_std_m_per_sec_per_sec,omitempty"`
+}
+
+// Validate validates the fused model's configuration.
+func (cfg *Config) Validate(path string) ([]string, error) {
+	if len(cfg.Position)+len(cfg.IMU)+len(cfg.Odometry) == 0 {
+		return nil, resource.NewConfigValidationError(path, errNoSources)
+	}
+	if cfg.UpdateRateHz < 0 {
+		return nil, resource.NewConfigValidationError(path, errors.New("update_rate_hz cannot be negative"))
+	}
+	if cfg.OutlierSigma < 0 {
+		return nil, resource.NewConfigValidationError(path, errors.New("outlier_rejection_sigma cannot be negative"))
+	}
+	var deps []string
+	deps = append(deps, cfg.Position...)
+	deps = append(deps, cfg.IMU...)
+	deps = append(deps, cfg.Odometry...)
+	return deps, nil
+}
+
+func init() {
+	resource.RegisterComponent(
+		movementsensor.API,
+		model,
+		resource.Registration[movementsensor.MovementSensor, *Config]{Constructor: newFused})
+}
+
+// source is a dependency sensor along with the quantities the filter reads from it.
+type source struct {
+	ms    movementsensor.MovementSensor
+	props *movementsensor.Properties
+	role  string
+}
+
+const (
+	rolePosition = "position"
+	roleIMU      = "imu"
+	roleOdometry = "odometry"
+)
+
+type fused struct {
+	resource.Named
+	resource.AlwaysRebuild
+	logger logging.Logger
+
+	mu     sync.Mutex
+	filter *ekf
+	conf   *Config
+
+	sources []source
+	origin  *geo.Point
+	alt     float64
+	nmeaFix int32
+
+	hasHeading  bool
+	hasMotion   bool
+	rejections  map[string]int
+	rejectedSum int
+	lastErr     movementsensor.LastError
+
+	workers *goutils.StoppableWorkers
+}
+
+func newFused(ctx context.Context, deps resource.Dependencies, conf resource.Config, logger logging.Logger) (
+	movementsensor.MovementSensor, error,
+) {
+	newConf, err := resource.NativeConfig[*Config](conf)
+	if err != nil {
+		return nil, err
+	}
+	applyDefaults(newConf)
+
+	f := &fused{
+		Named:      conf.ResourceName().AsNamed(),
+		logger:     logger,
+		conf:       newConf,
+		nmeaFix:    -1,
+		rejections: map[string]int{},
+		lastErr:    movementsensor.NewLastError(10, 5),
+		filter: newEKF(processNoise{
+			accel:   defaultAccelNoise * defaultAccelNoise,
+			yawRate: defaultYawRateNoise * defaultYawRateNoise,
+		}, newConf.OutlierSigma),
+	}
+
+	addSources := func(names []string, role string) error {
+		for _, name := range names {
+			ms, err := movementsensor.FromDependencies(deps, name)
+			if err != nil {
+				return err
+			}
+			props, err := ms.Properties(ctx, nil)
+			if err != nil {
+				return errors.Wrapf(err, "error getting properties of %s sensor %v", role, name)
+			}
+			if !supportsRole(props, role) {
+				return errors.Errorf("%s sensor %v does not report any quantity used for %s", role, name, role)
+			}
+			f.sources = append(f.sources, source{ms: ms, props: props, role: role})
+			logger.CDebugf(ctx, "using sensor %v as %s sensor", name, role)
+		}
+		return nil
+	}
+	if err := addSources(newConf.Position, rolePosition); err != nil {
+		return nil, err
+	}
+	if err := addSources(newConf.IMU, roleIMU); err != nil {
+		return nil, err
+	}
+	if err := addSources(newConf.Odometry, roleOdometry); err != nil {
+		return nil, err
+	}
+
+	interval := time.Duration(float64(time.Second) / newConf.UpdateRateHz)
+	f.workers = goutils.NewBackgroundStoppableWorkers()
+	for _, s := range f.sources {
+		s := s
+		f.workers.Add(func(ctx context.Context) {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				f.lastErr.Set(f.poll(ctx, s))
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		})
+	}
+	return f, nil
+}
+
+func applyDefaults(conf *Config) {
+	setDefault := func(v *float64, def float64) {
+		if *v == 0 {
+			*v = def
+		}
+	}
+	setDefault(&conf.UpdateRateHz, defaultUpdateRateHz)
+	setDefault(&conf.OutlierSigma, defaultOutlierSigma)
+	setDefault(&conf.PositionStdM, defaultPositionStdM)
+	setDefault(&conf.HeadingStdDegs, defaultHeadingStdDegs)
+	setDefault(&conf.YawRateStdDegsPerS, defaultYawRateStdDegsPerS)
+	setDefault(&conf.SpeedStdMPerS, defaultSpeedStdMPerS)
+	setDefault(&conf.AccelStdMPerS2, defaultAccelStdMPerS2)
+}
+
+func supportsRole(props *movementsensor.Properties, role string) bool {
+	switch role {
+	case rolePosition:
+		return props.PositionSupported
+	case roleIMU:
+		return props.CompassHeadingSupported || props.OrientationSupported ||
+			props.AngularVelocitySupported || props.LinearAccelerationSupported
+	case roleOdometry:
+		return props.LinearVelocitySupported || props.AngularVelocitySupported
+	default:
+		return false
+	}
+}
+
+// poll reads every quantity the filter uses from s and feeds them to the filter.
+// Each reading is stamped with the midpoint of the call that produced it.
+func (f *fused) poll(ctx context.Context, s source) error {
+	name := s.ms.Name().ShortName()
+	stamp := func(start time.Time) time.Time {
+		return start.Add(time.Since(start) / 2)
+	}
+
+	var acc *movementsensor.Accuracy
+	if s.role == rolePosition || s.props.CompassHeadingSupported || s.props.OrientationSupported {
+		var err error
+		acc, err = s.ms.Accuracy(ctx, nil)
+		if err != nil {
+			f.logger.CDebugf(ctx, "error getting accuracy of %v, using configured defaults: %v", name, err)
+			acc = movementsensor.UnimplementedOptionalAccuracies()
+		}
+	}
+
+	switch s.role {
+	case rolePosition:
+		start := time.Now()
+		pt, alt, err := s.ms.Position(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if pt == nil || movementsensor.IsPositionNaN(pt) || movementsensor.IsZeroPosition(pt) {
+			return nil
+		}
+		f.addPosition(name, stamp(start), pt, alt, acc)
+	case roleIMU:
+		if s.props.CompassHeadingSupported || s.props.OrientationSupported {
+			start := time.Now()
+			heading, err := readHeading(ctx, s)
+			if err != nil {
+				return err
+			}
+			variance := sqr(utils.DegToRad(f.conf.HeadingStdDegs))
+			if isValidAccuracy(acc.CompassDegreeError) {
+				variance = sqr(utils.DegToRad(float64(acc.CompassDegreeError)))
+			}
+			f.addMeasurement(measurement{
+				source: name + "_heading", time: stamp(start),
+				observes: []int{stateHeading}, value: []float64{heading}, variance: []float64{variance},
+			})
+		}
+		if s.props.AngularVelocitySupported {
+			if err := f.addYawRate(ctx, s); err != nil {
+				return err
+			}
+		}
+		if s.props.LinearAccelerationSupported {
+			start := time.Now()
+			la, err := s.ms.LinearAcceleration(ctx, nil)
+			if err != nil {
+				return err
+			}
+			f.addMeasurement(measurement{
+				source: name + "_acceleration", time: stamp(start),
+				observes: []int{stateAccel}, value: []float64{la.Y}, variance: []float64{sqr(f.conf.AccelStdMPerS2)},
+			})
+		}
+	case roleOdometry:
+		if s.props.LinearVelocitySupported {
+			start := time.Now()
+			lv, err := s.ms.LinearVelocity(ctx, nil)
+			if err != nil {
+				return err
+			}
+			f.addMeasurement(measurement{
+				source: name + "_speed", time: stamp(start),
+				observes: []int{stateSpeed}, value: []float64{lv.Y}, variance: []float64{sqr(f.conf.SpeedStdMPerS)},
+			})
+		}
+		if s.props.AngularVelocitySupported {
+			if err := f.addYawRate(ctx, s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readHeading returns the heading of s in radians clockwise from north, preferring an absolute
+// compass heading over the yaw of a relative orientation.
+func readHeading(ctx context.Context, s source) (float64, error) {
+	if s.props.CompassHeadingSupported {
+		heading, err := s.ms.CompassHeading(ctx, nil)
+		if err != nil {
+			return 0, err
+		}
+		return wrapAngle(utils.DegToRad(heading)), nil
+	}
+	ori, err := s.ms.Orientation(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	// orientations are right handed about z, headings are left handed
+	return wrapAngle(-ori.EulerAngles().Yaw), nil
+}
+
+func (f *fused) addYawRate(ctx context.Context, s source) error {
+	start := time.Now()
+	av, err := s.ms.AngularVelocity(ctx, nil)
+	if err != nil {
+		return err
+	}
+	f.addMeasurement(measurement{
+		source:   s.ms.Name().ShortName() + "_yaw_rate",
+		time:     start.Add(time.Since(start) / 2),
+		observes: []int{stateYawRate},
+		// angular velocity is right handed about z, the heading rate is left handed
+		value:    []float64{-utils.DegToRad(av.Z)},
+		variance: []float64{sqr(utils.DegToRad(f.conf.YawRateStdDegsPerS))},
+	})
+	return nil
+}
+
+func (f *fused) addPosition(name string, t time.Time, pt *geo.Point, alt float64, acc *movementsensor.Accuracy) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	std := f.conf.PositionStdM
+	switch {
+	case acc.NmeaFix == 4:
+		std = rtkFixedStdM
+	case acc.NmeaFix == 5:
+		std = rtkFloatStdM
+	case isValidAccuracy(acc.Hdop):
+		std = float64(acc.Hdop) * uereM
+	}
+	variance := std * std
+
+	f.alt = alt
+	f.nmeaFix = acc.NmeaFix
+	if f.origin == nil {
+		f.origin = pt
+		f.filter.predict(t)
+		f.filter.setState(stateNorth, 0, variance)
+		f.filter.setState(stateEast, 0, variance)
+		return
+	}
+	local := spatialmath.GeoPointToPoint(pt, f.origin).Mul(1e-3)
+	f.updateLocked(measurement{
+		source: name + "_position", time: t,
+		observes: []int{stateNorth, stateEast},
+		value:    []float64{local.Y, local.X},
+		variance: []float64{variance, variance},
+	})
+}
+
+func (f *fused) addMeasurement(m measurement) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if m.observes[0] == stateHeading && !f.hasHeading {
+		f.hasHeading = true
+		f.filter.predict(m.time)
+		f.filter.setState(stateHeading, m.value[0], m.variance[0])
+		return
+	}
+	f.updateLocked(m)
+}
+
+// updateLocked applies m to the filter, tracking outliers per source. It must be called with the mutex held.
+func (f *fused) updateLocked(m measurement) {
+	if m.observes[0] == stateSpeed || m.observes[0] == stateYawRate {
+		f.hasMotion = true
+	}
+	force := f.rejections[m.source] >= maxConsecutiveRejections
+	if force {
+		f.logger.Warnf("%v rejected %d times in a row, re-seeding the filter from it", m.source, f.rejections[m.source])
+		f.filter.predict(m.time)
+		for i, idx := range m.observes {
+			f.filter.setState(idx, m.value[i], m.variance[i])
+		}
+		f.rejections[m.source] = 0
+		return
+	}
+
+	d2, accepted, err := f.filter.update(m, false)
+	if err != nil {
+		f.logger.Debugf("error updating filter with %v: %v", m.source, err)
+		return
+	}
+	if !accepted {
+		f.rejections[m.source]++
+		f.rejectedSum++
+		f.logger.Debugf("rejecting %v reading %v as an outlier, mahalanobis distance squared %.2f", m.source, m.value, d2)
+		return
+	}
+	f.rejections[m.source] = 0
+}
+
+func (f *fused) hasRole(role string) bool {
+	for _, s := range f.sources {
+		if s.role == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fused) Position(ctx context.Context, extra map[string]interface{}) (*geo.Point, float64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.hasRole(rolePosition) {
+		return geo.NewPoint(math.NaN(), math.NaN()), math.NaN(), movementsensor.ErrMethodUnimplementedPosition
+	}
+	if f.origin == nil {
+		return geo.NewPoint(math.NaN(), math.NaN()), math.NaN(), f.lastErr.Get()
+	}
+	north, east := f.filter.state(stateNorth), f.filter.state(stateEast)
+	bearing := utils.RadToDeg(math.Atan2(east, north))
+	return f.origin.PointAtDistanceAndBearing(math.Hypot(north, east)*1e-3, bearing), f.alt, f.lastErr.Get()
+}
+
+func (f *fused) Orientation(ctx context.Context, extra map[string]interface{}) (spatialmath.Orientation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.hasHeading {
+		return spatialmath.NewOrientationVector(), movementsensor.ErrMethodUnimplementedOrientation
+	}
+	return &spatialmath.OrientationVector{OZ: 1, Theta: -f.filter.state(stateHeading)}, f.lastErr.Get()
+}
+
+func (f *fused) CompassHeading(ctx context.Context, extra map[string]interface{}) (float64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.hasHeading {
+		return math.NaN(), movementsensor.ErrMethodUnimplementedCompassHeading
+	}
+	heading := utils.RadToDeg(f.filter.state(stateHeading))
+	if heading < 0 {
+		heading += 360
+	}
+	return heading, f.lastErr.Get()
+}
+
+func (f *fused) LinearVelocity(ctx context.Context, extra map[string]interface{}) (r3.Vector, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.properties().LinearVelocitySupported || (!f.hasMotion && f.origin == nil) {
+		return r3.Vector{X: math.NaN(), Y: math.NaN(), Z: math.NaN()}, movementsensor.ErrMethodUnimplementedLinearVelocity
+	}
+	return r3.Vector{Y: f.filter.state(stateSpeed)}, f.lastErr.Get()
+}
+
+func (f *fused) AngularVelocity(ctx context.Context, extra map[string]interface{}) (spatialmath.AngularVelocity, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.properties().AngularVelocitySupported || (!f.hasMotion && !f.hasHeading) {
+		return spatialmath.AngularVelocity{X: math.NaN(), Y: math.NaN(), Z: math.NaN()},
+			movementsensor.ErrMethodUnimplementedAngularVelocity
+	}
+	return spatialmath.AngularVelocity{Z: -utils.RadToDeg(f.filter.state(stateYawRate))}, f.lastErr.Get()
+}
+
+func (f *fused) LinearAcceleration(ctx context.Context, extra map[string]interface{}) (r3.Vector, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.properties().LinearAccelerationSupported || (!f.hasMotion && f.origin == nil) {
+		return r3.Vector{X: math.NaN(), Y: math.NaN(), Z: math.NaN()}, movementsensor.ErrMethodUnimplementedLinearAcceleration
+	}
+	return r3.Vector{Y: f.filter.state(stateAccel)}, f.lastErr.Get()
+}
+
+// accuracyMapLocked reports the filter's standard deviations. It must be called with the mutex held.
+func (f *fused) accuracyMapLocked() map[string]float32 {
+	return map[string]float32{
+		positionStdKey: float32(math.Hypot(f.filter.stddev(stateNorth), f.filter.stddev(stateEast))),
+		headingStdKey:  float32(utils.RadToDeg(f.filter.stddev(stateHeading))),
+		speedStdKey:    float32(f.filter.stddev(stateSpeed)),
+		yawRateStdKey:  float32(utils.RadToDeg(f.filter.stddev(stateYawRate))),
+		rejectedKey:    float32(f.rejectedSum),
+	}
+}
+
+func (f *fused) Accuracy(ctx context.Context, extra map[string]interface{}) (*movementsensor.Accuracy, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	acc := movementsensor.UnimplementedOptionalAccuracies()
+	acc.AccuracyMap = f.accuracyMapLocked()
+	acc.NmeaFix = f.nmeaFix
+	if f.hasHeading {
+		acc.CompassDegreeError = acc.AccuracyMap[headingStdKey]
+	}
+	return acc, nil
+}
+
+func (f *fused) Properties(ctx context.Context, extra map[string]interface{}) (*movementsensor.Properties, error) {
+	return f.properties(), nil
+}
+
+// properties reports the quantities the filter can estimate from the configured sources: a heading needs an
+// imu reporting one, and each rate needs a source reporting it or, for speed and yaw rate, the position and
+// heading it can be derived from.
+func (f *fused) properties() *movementsensor.Properties {
+	props := &movementsensor.Properties{PositionSupported: f.hasRole(rolePosition)}
+	props.LinearVelocitySupported = props.PositionSupported
+	for _, s := range f.sources {
+		if s.role == roleIMU && (s.props.CompassHeadingSupported || s.props.OrientationSupported) {
+			props.OrientationSupported = true
+			props.CompassHeadingSupported = true
+			props.AngularVelocitySupported = true
+		}
+		if s.role == roleOdometry && s.props.LinearVelocitySupported {
+			props.LinearVelocitySupported = true
+		}
+		if s.props.AngularVelocitySupported {
+			props.AngularVelocitySupported = true
+		}
+		if s.role == roleIMU && s.props.LinearAccelerationSupported {
+			props.LinearAccelerationSupported = true
+		}
+	}
+	return props
+}
+
+func (f *fused) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
+	readings, err := movementsensor.DefaultAPIReadings(ctx, f, extra)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for k, v := range f.accuracyMapLocked() {
+		readings[k] = float64(v)
+	}
+	return readings, nil
+}
+
+func (f *fused) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	if _, ok := cmd["reset"]; ok {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.filter.reset()
+		f.origin = nil
+		f.hasHeading = false
+		f.hasMotion = false
+		f.rejections = map[string]int{}
+		f.rejectedSum = 0
+		return map[string]interface{}{"reset": true}, nil
+	}
+	return nil, resource.ErrDoUnimplemented
+}
+
+func (f *fused) Close(context.Context) error {
+	// we do not close the movement sensors that this driver depends on.
+	f.workers.Stop()
+	return nil
+}
+
+func isValidAccuracy(v float32) bool {
+	return v > 0 && !math.IsNaN(float64(v))
+}
+
+func sqr(v float64) float64 {
+	return v * v
+}
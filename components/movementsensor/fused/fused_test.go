@@ -0,0 +1,238 @@
+package fused
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/golang/geo/r3"
+	geo "github.com/kellydunn/golang-geo"
+	"go.viam.com/test"
+	"go.viam.com/utils/testutils"
+
+	"go.viam.com/rdk/components/movementsensor"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/testutils/inject"
+)
+
+var testOrigin = geo.NewPoint(40.7, -73.98)
+
+func setupGPS(name string, pt *geo.Point, fix int32) *inject.MovementSensor {
+	ms := inject.NewMovementSensor(name)
+	ms.PropertiesFunc = func(ctx context.Context, extra map[string]interface{}) (*movementsensor.Properties, error) {
+		return &movementsensor.Properties{PositionSupported: true}, nil
+	}
+	ms.PositionFunc = func(ctx context.Context, extra map[string]interface{}) (*geo.Point, float64, error) {
+		return pt, 12, nil
+	}
+	ms.AccuracyFunc = func(ctx context.Context, extra map[string]interface{}) (*movementsensor.Accuracy, error) {
+		return &movementsensor.Accuracy{Hdop: 0.8, Vdop: 1, NmeaFix: fix, CompassDegreeError: float32(math.NaN())}, nil
+	}
+	return ms
+}
+
+func setupIMU(name string, heading float64) *inject.MovementSensor {
+	ms := inject.NewMovementSensor(name)
+	ms.PropertiesFunc = func(ctx context.Context, extra map[string]interface{}) (*movementsensor.Properties, error) {
+		return &movementsensor.Properties{
+			CompassHeadingSupported:     true,
+			AngularVelocitySupported:    true,
+			LinearAccelerationSupported: true,
+		}, nil
+	}
+	ms.CompassHeadingFunc = func(ctx context.Context, extra map[string]interface{}) (float64, error) {
+		return heading, nil
+	}
+	ms.AngularVelocityFunc = func(ctx context.Context, extra map[string]interface{}) (spatialmath.AngularVelocity, error) {
+		return spatialmath.AngularVelocity{}, nil
+	}
+	ms.LinearAccelerationFunc = func(ctx context.Context, extra map[string]interface{}) (r3.Vector, error) {
+		return r3.Vector{}, nil
+	}
+	ms.AccuracyFunc = func(ctx context.Context, extra map[string]interface{}) (*movementsensor.Accuracy, error) {
+		return movementsensor.UnimplementedOptionalAccuracies(), nil
+	}
+	return ms
+}
+
+func setupOdometry(name string, speed float64) *inject.MovementSensor {
+	ms := inject.NewMovementSensor(name)
+	ms.PropertiesFunc = func(ctx context.Context, extra map[string]interface{}) (*movementsensor.Properties, error) {
+		return &movementsensor.Properties{LinearVelocitySupported: true, AngularVelocitySupported: true}, nil
+	}
+	ms.LinearVelocityFunc = func(ctx context.Context, extra map[string]interface{}) (r3.Vector, error) {
+		return r3.Vector{Y: speed}, nil
+	}
+	ms.AngularVelocityFunc = func(ctx context.Context, extra map[string]interface{}) (spatialmath.AngularVelocity, error) {
+		return spatialmath.AngularVelocity{}, nil
+	}
+	return ms
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Config{}
+	_, err := cfg.Validate("path")
+	test.That(t, err, test.ShouldBeError, resource.NewConfigValidationError("path", errNoSources))
+
+	cfg = Config{Position: []string{"gps"}, IMU: []string{"imu"}, Odometry: []string{"odom"}}
+	deps, err := cfg.Validate("path")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldResemble, []string{"gps", "imu", "odom"})
+
+	cfg.UpdateRateHz = -1
+	_, err = cfg.Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestFusedSensor(t *testing.T) {
+	ctx := context.Background()
+	logger := logging.NewTestLogger(t)
+
+	gps := setupGPS("gps", testOrigin, 4)
+	imu := setupIMU("imu", 90)
+	odom := setupOdometry("odom", 0)
+	deps := resource.Dependencies{
+		movementsensor.Named("gps"):  gps,
+		movementsensor.Named("imu"):  imu,
+		movementsensor.Named("odom"): odom,
+	}
+	conf := resource.Config{
+		Name:  "fused",
+		Model: model,
+		API:   movementsensor.API,
+		ConvertedAttributes: &Config{
+			Position:     []string{"gps"},
+			IMU:          []string{"imu"},
+			Odometry:     []string{"odom"},
+			UpdateRateHz: 50,
+		},
+	}
+
+	ms, err := newFused(ctx, deps, conf, logger)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, ms.Close(ctx), test.ShouldBeNil)
+	}()
+
+	props, err := ms.Properties(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, props, test.ShouldResemble, &movementsensor.Properties{
+		PositionSupported:           true,
+		OrientationSupported:        true,
+		CompassHeadingSupported:     true,
+		LinearVelocitySupported:     true,
+		AngularVelocitySupported:    true,
+		LinearAccelerationSupported: true,
+	})
+
+	testutils.WaitForAssertion(t, func(tb testing.TB) {
+		tb.Helper()
+		pos, alt, err := ms.Position(ctx, nil)
+		test.That(tb, err, test.ShouldBeNil)
+		test.That(tb, pos.Lat(), test.ShouldAlmostEqual, testOrigin.Lat(), 1e-6)
+		test.That(tb, pos.Lng(), test.ShouldAlmostEqual, testOrigin.Lng(), 1e-6)
+		test.That(tb, alt, test.ShouldEqual, 12)
+
+		heading, err := ms.CompassHeading(ctx, nil)
+		test.That(tb, err, test.ShouldBeNil)
+		test.That(tb, heading, test.ShouldAlmostEqual, 90, 1)
+	})
+
+	ori, err := ms.Orientation(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, ori.OrientationVectorDegrees().Theta, test.ShouldAlmostEqual, -90, 1)
+
+	vel, err := ms.LinearVelocity(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, vel.Y, test.ShouldAlmostEqual, 0, 0.05)
+
+	acc, err := ms.Accuracy(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, acc.NmeaFix, test.ShouldEqual, 4)
+	test.That(t, acc.AccuracyMap[positionStdKey], test.ShouldBeLessThan, 0.1)
+	test.That(t, acc.CompassDegreeError, test.ShouldBeLessThan, defaultHeadingStdDegs)
+
+	readings, err := ms.Readings(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, readings, test.ShouldContainKey, "position")
+	test.That(t, readings, test.ShouldContainKey, positionStdKey)
+
+	resp, err := ms.DoCommand(ctx, map[string]interface{}{"reset": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp["reset"], test.ShouldBeTrue)
+}
+
+func TestFusedRejectsGPSJump(t *testing.T) {
+	ctx := context.Background()
+	logger := logging.NewTestLogger(t)
+
+	gps := setupGPS("gps", testOrigin, 4)
+	deps := resource.Dependencies{movementsensor.Named("gps"): gps}
+	conf := resource.Config{
+		Name:                "fused",
+		Model:               model,
+		API:                 movementsensor.API,
+		ConvertedAttributes: &Config{Position: []string{"gps"}, UpdateRateHz: 100},
+	}
+
+	ms, err := newFused(ctx, deps, conf, logger)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, ms.Close(ctx), test.ShouldBeNil)
+	}()
+
+	// a position alone gives the speed, but neither a heading nor an acceleration
+	props, err := ms.Properties(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, props, test.ShouldResemble, &movementsensor.Properties{
+		PositionSupported:       true,
+		LinearVelocitySupported: true,
+	})
+
+	testutils.WaitForAssertion(t, func(tb testing.TB) {
+		tb.Helper()
+		acc, err := ms.Accuracy(ctx, nil)
+		test.That(tb, err, test.ShouldBeNil)
+		test.That(tb, acc.AccuracyMap[positionStdKey], test.ShouldBeLessThan, 0.05)
+	})
+	_, err = ms.LinearAcceleration(ctx, nil)
+	test.That(t, err, test.ShouldBeError, movementsensor.ErrMethodUnimplementedLinearAcceleration)
+
+	// a single 100m glitch is rejected and does not move the estimate
+	glitch := testOrigin.PointAtDistanceAndBearing(0.1, 0)
+	gps.Mu.Lock()
+	gps.PositionFunc = func(ctx context.Context, extra map[string]interface{}) (*geo.Point, float64, error) {
+		gps.PositionFunc = func(ctx context.Context, extra map[string]interface{}) (*geo.Point, float64, error) {
+			return testOrigin, 12, nil
+		}
+		return glitch, 12, nil
+	}
+	gps.Mu.Unlock()
+
+	testutils.WaitForAssertion(t, func(tb testing.TB) {
+		tb.Helper()
+		acc, err := ms.Accuracy(ctx, nil)
+		test.That(tb, err, test.ShouldBeNil)
+		test.That(tb, acc.AccuracyMap[rejectedKey], test.ShouldBeGreaterThanOrEqualTo, 1)
+	})
+	time.Sleep(50 * time.Millisecond)
+	pos, _, err := ms.Position(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pos.GreatCircleDistance(testOrigin)*1e3, test.ShouldBeLessThan, 0.5)
+
+	// a persistent jump re-seeds the filter after repeated rejections
+	gps.Mu.Lock()
+	gps.PositionFunc = func(ctx context.Context, extra map[string]interface{}) (*geo.Point, float64, error) {
+		return glitch, 12, nil
+	}
+	gps.Mu.Unlock()
+	testutils.WaitForAssertion(t, func(tb testing.TB) {
+		tb.Helper()
+		pos, _, err := ms.Position(ctx, nil)
+		test.That(tb, err, test.ShouldBeNil)
+		test.That(tb, pos.GreatCircleDistance(glitch)*1e3, test.ShouldBeLessThan, 0.5)
+	})
+}
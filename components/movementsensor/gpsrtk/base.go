@@ -0,0 +1,327 @@
+package gpsrtk
+
+/*
+	This file implements an RTK base station. The base reads the RTCM3 corrections produced by a
+	local receiver (for example a ZED-F9P in survey-in or fixed mode) and serves them to rovers over
+	a built-in NTRIP caster, so a site does not need an internet connection or a third party caster.
+	Receivers commonly interleave NMEA sentences with the RTCM3 frames on the same port, so the
+	NMEA sentences are split off and used to report the position of the base.
+
+	Example configuration:
+	{
+      "type": "movement_sensor",
+	  "model": "gps-rtk-base-serial",
+      "name": "my-rtk-base"
+      "attributes": {
+        "serial_path": "serial-path",
+        "serial_baud_rate": 115200,
+        "caster_address": ":2101",
+        "caster_mountpoint": "BASE",
+        "caster_username": "usr",
+        "caster_password": "pwd"
+      },
+      "depends_on": [],
+    }
+*/
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"time"
+
+	"github.com/golang/geo/r3"
+	geo "github.com/kellydunn/golang-geo"
+	"go.uber.org/multierr"
+	"go.viam.com/utils"
+
+	"go.viam.com/rdk/components/movementsensor"
+	"go.viam.com/rdk/components/movementsensor/gpsutils"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/spatialmath"
+)
+
+var rtkBaseModel = resource.DefaultModelFamily.WithModel("gps-rtk-base-serial")
+
+// BaseConfig is used for converting the attributes of an RTK base station.
+type BaseConfig struct {
+	SerialPath     string `json:"serial_path"`
+	SerialBaudRate int    `json:"serial_baud_rate,omitempty"`
+
+	CasterAddress    string `json:"caster_address,omitempty"`
+	CasterMountpoint string `json:"caster_mountpoint"`
+	CasterUser       string `json:"caster_username,omitempty"`
+	CasterPass       string `json:"caster_password,omitempty"`
+
+	CorrectionTimeoutSec float64 `json:"correction_timeout_sec,omitempty"`
+}
+
+// Validate ensures all parts of the config are valid.
+func (cfg *BaseConfig) Validate(path string) ([]string, error) {
+	if cfg.SerialPath == "" {
+		return nil, resource.NewConfigValidationFieldRequiredError(path, "serial_path")
+	}
+	if cfg.CasterMountpoint == "" {
+		return nil, resource.NewConfigValidationFieldRequiredError(path, "caster_mountpoint")
+	}
+	if cfg.CasterPass != "" && cfg.CasterUser == "" {
+		return nil, resource.NewConfigValidationFieldRequiredError(path, "caster_username")
+	}
+	return nil, nil
+}
+
+func init() {
+	resource.RegisterComponent(
+		movementsensor.API,
+		rtkBaseModel,
+		resource.Registration[movementsensor.MovementSensor, *BaseConfig]{
+			Constructor: newRTKBase,
+		})
+}
+
+// rtkBase is a movementsensor which serves the corrections of a local receiver over NTRIP.
+type rtkBase struct {
+	resource.Named
+	resource.AlwaysRebuild
+	logger logging.Logger
+
+	workers *utils.StoppableWorkers
+
+	dev               io.ReadCloser
+	nmea              *nmeaSplitter
+	cachedData        *gpsutils.CachedData
+	caster            *gpsutils.NtripCaster
+	rtcm              *gpsutils.RTCMMonitor
+	correctionTimeout time.Duration
+}
+
+func newRTKBase(
+	ctx context.Context,
+	deps resource.Dependencies,
+	conf resource.Config,
+	logger logging.Logger,
+) (movementsensor.MovementSensor, error) {
+	newConf, err := resource.NativeConfig[*BaseConfig](conf)
+	if err != nil {
+		return nil, err
+	}
+	baud := newConf.SerialBaudRate
+	if baud == 0 {
+		baud = 38400
+		logger.CInfo(ctx, "serial_baud_rate using default baud rate 38400")
+	}
+	dev, err := newSerialCorrectionWriter(newConf.SerialPath, uint(baud))
+	if err != nil {
+		return nil, err
+	}
+	return makeRTKBase(ctx, conf.ResourceName(), newConf, dev, logger)
+}
+
+// makeRTKBase is separate from newRTKBase, above, so tests can replay a recorded byte stream
+// instead of reading from a serial port.
+func makeRTKBase(
+	ctx context.Context,
+	name resource.Name,
+	conf *BaseConfig,
+	dev io.ReadCloser,
+	logger logging.Logger,
+) (movementsensor.MovementSensor, error) {
+	timeout := time.Duration(conf.CorrectionTimeoutSec * float64(time.Second))
+	if timeout == 0 {
+		timeout = defaultCorrectionTimeoutSec * time.Second
+	}
+
+	caster, err := gpsutils.NewNtripCaster(&gpsutils.CasterConfig{
+		CasterAddress:    conf.CasterAddress,
+		CasterMountpoint: conf.CasterMountpoint,
+		CasterUser:       conf.CasterUser,
+		CasterPass:       conf.CasterPass,
+	}, logger)
+	if err != nil {
+		return nil, multierr.Combine(err, dev.Close())
+	}
+
+	b := &rtkBase{
+		Named:             name.AsNamed(),
+		logger:            logger,
+		dev:               dev,
+		nmea:              newNMEASplitter(),
+		caster:            caster,
+		rtcm:              gpsutils.NewRTCMMonitor(),
+		correctionTimeout: timeout,
+	}
+	b.cachedData = gpsutils.NewCachedData(b.nmea, logger)
+	b.workers = utils.NewBackgroundStoppableWorkers(b.readCorrections)
+	return b, nil
+}
+
+// readCorrections decodes the frames coming from the receiver and broadcasts them to the caster's clients.
+func (b *rtkBase) readCorrections(cancelCtx context.Context) {
+	decoder := gpsutils.NewRTCMDecoder(b.dev)
+	decoder.NonRTCM = b.nmea.writer(cancelCtx)
+
+	crcErrors := 0
+	for cancelCtx.Err() == nil {
+		frame, err := decoder.Next()
+		if err != nil {
+			if cancelCtx.Err() == nil {
+				b.logger.CErrorf(cancelCtx, "stopped reading corrections from the base receiver: %v", err)
+			}
+			return
+		}
+		b.rtcm.Record(frame, time.Now())
+		if n := decoder.CRCErrors(); n != crcErrors {
+			b.rtcm.RecordCRCErrors(n - crcErrors)
+			crcErrors = n
+		}
+		if arp, ok := b.rtcm.StationARP(); ok && (frame.MessageType == 1005 || frame.MessageType == 1006) {
+			loc, _ := arp.Location()
+			b.caster.SetLocation(loc)
+		}
+		b.caster.Broadcast(frame)
+	}
+}
+
+// Position returns the position reported by the receiver's NMEA sentences, falling back to the
+// antenna reference point the receiver is broadcasting.
+func (b *rtkBase) Position(ctx context.Context, extra map[string]interface{}) (*geo.Point, float64, error) {
+	position, alt, err := b.cachedData.Position(ctx, extra)
+	if err == nil && !movementsensor.IsPositionNaN(position) {
+		return position, alt, nil
+	}
+	if arp, ok := b.rtcm.StationARP(); ok {
+		loc, arpAlt := arp.Location()
+		return loc, arpAlt, nil
+	}
+	return geo.NewPoint(math.NaN(), math.NaN()), math.NaN(), err
+}
+
+// LinearVelocity passthrough.
+func (b *rtkBase) LinearVelocity(ctx context.Context, extra map[string]interface{}) (r3.Vector, error) {
+	return b.cachedData.LinearVelocity(ctx, extra)
+}
+
+// LinearAcceleration passthrough.
+func (b *rtkBase) LinearAcceleration(ctx context.Context, extra map[string]interface{}) (r3.Vector, error) {
+	return b.cachedData.LinearAcceleration(ctx, extra)
+}
+
+// AngularVelocity passthrough.
+func (b *rtkBase) AngularVelocity(ctx context.Context, extra map[string]interface{}) (spatialmath.AngularVelocity, error) {
+	return b.cachedData.AngularVelocity(ctx, extra)
+}
+
+// CompassHeading passthrough.
+func (b *rtkBase) CompassHeading(ctx context.Context, extra map[string]interface{}) (float64, error) {
+	return b.cachedData.CompassHeading(ctx, extra)
+}
+
+// Orientation passthrough.
+func (b *rtkBase) Orientation(ctx context.Context, extra map[string]interface{}) (spatialmath.Orientation, error) {
+	return b.cachedData.Orientation(ctx, extra)
+}
+
+// Properties passthrough.
+func (b *rtkBase) Properties(ctx context.Context, extra map[string]interface{}) (*movementsensor.Properties, error) {
+	return b.cachedData.Properties(ctx, extra)
+}
+
+// Accuracy returns the accuracy of the receiver along with the health of the corrections it produces.
+func (b *rtkBase) Accuracy(ctx context.Context, extra map[string]interface{}) (*movementsensor.Accuracy, error) {
+	acc, err := b.cachedData.Accuracy(ctx, extra)
+	if err != nil {
+		return nil, err
+	}
+	if acc.AccuracyMap == nil {
+		acc.AccuracyMap = map[string]float32{}
+	}
+	for k, v := range b.rtcm.Status(time.Now()).AccuracyMap() {
+		acc.AccuracyMap[k] = v
+	}
+	return acc, nil
+}
+
+// Readings returns the default readings along with the correction stream and caster status.
+func (b *rtkBase) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
+	readings, err := movementsensor.DefaultAPIReadings(ctx, b, extra)
+	if err != nil {
+		return nil, err
+	}
+	status := b.rtcm.Status(time.Now())
+	for k, v := range status.Readings() {
+		readings[k] = v
+	}
+	readings[correctionsStaleKey] = status.IsStale(b.correctionTimeout)
+	readings["ntrip_clients"] = b.caster.NumClients()
+	return readings, nil
+}
+
+// Close shuts down the caster and the connection to the receiver.
+func (b *rtkBase) Close(ctx context.Context) error {
+	// closing the device first unblocks the decoder so the workers can stop.
+	err := b.dev.Close()
+	b.workers.Stop()
+	return multierr.Combine(err, b.caster.Close(ctx), b.cachedData.Close(ctx))
+}
+
+// nmeaSplitter collects the bytes of a mixed RTCM3 and NMEA stream which are not part of an RTCM3
+// frame and hands complete NMEA sentences to a CachedData. It implements gpsutils.DataReader.
+type nmeaSplitter struct {
+	lines chan string
+}
+
+func newNMEASplitter() *nmeaSplitter {
+	return &nmeaSplitter{lines: make(chan string)}
+}
+
+// Messages is part of the DataReader interface.
+func (s *nmeaSplitter) Messages() chan string {
+	return s.lines
+}
+
+// Close is part of the DataReader interface. The underlying device is owned by the base.
+func (s *nmeaSplitter) Close() error {
+	return nil
+}
+
+func (s *nmeaSplitter) writer(ctx context.Context) io.Writer {
+	return &nmeaLineWriter{ctx: ctx, lines: s.lines}
+}
+
+// maximum length of an NMEA sentence, anything longer is binary noise.
+const maxNMEALen = 256
+
+type nmeaLineWriter struct {
+	ctx   context.Context
+	lines chan string
+	buf   []byte
+}
+
+func (w *nmeaLineWriter) Write(p []byte) (int, error) {
+	for _, c := range p {
+		if c == '$' {
+			w.buf = w.buf[:0]
+		}
+		w.buf = append(w.buf, c)
+		if len(w.buf) > maxNMEALen {
+			w.buf = w.buf[:0]
+			continue
+		}
+		if c != '\n' {
+			continue
+		}
+		line := string(bytes.TrimSpace(w.buf))
+		w.buf = w.buf[:0]
+		if len(line) == 0 || line[0] != '$' {
+			continue
+		}
+		select {
+		case <-w.ctx.Done():
+			return 0, w.ctx.Err()
+		case w.lines <- line:
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,122 @@
+package gpsrtk
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"go.viam.com/test"
+	"go.viam.com/utils/testutils"
+
+	"go.viam.com/rdk/components/movementsensor"
+	"go.viam.com/rdk/components/movementsensor/gpsutils"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/utils"
+)
+
+func TestValidateRTKBase(t *testing.T) {
+	path := "path"
+	validConfig := BaseConfig{
+		SerialPath:       "some-path",
+		CasterMountpoint: "BASE",
+		CasterUser:       "user",
+		CasterPass:       "pwd",
+	}
+	t.Run("valid config", func(t *testing.T) {
+		cfg := validConfig
+		_, err := cfg.Validate(path)
+		test.That(t, err, test.ShouldBeNil)
+	})
+
+	t.Run("missing serial path", func(t *testing.T) {
+		cfg := validConfig
+		cfg.SerialPath = ""
+		_, err := cfg.Validate(path)
+		test.That(t, err, test.ShouldBeError,
+			resource.NewConfigValidationFieldRequiredError(path, "serial_path"))
+	})
+
+	t.Run("missing mountpoint", func(t *testing.T) {
+		cfg := validConfig
+		cfg.CasterMountpoint = ""
+		_, err := cfg.Validate(path)
+		test.That(t, err, test.ShouldBeError,
+			resource.NewConfigValidationFieldRequiredError(path, "caster_mountpoint"))
+	})
+
+	t.Run("password without username", func(t *testing.T) {
+		cfg := validConfig
+		cfg.CasterUser = ""
+		_, err := cfg.Validate(path)
+		test.That(t, err, test.ShouldBeError,
+			resource.NewConfigValidationFieldRequiredError(path, "caster_username"))
+	})
+}
+
+func TestRTKBaseReplay(t *testing.T) {
+	logger := logging.NewTestLogger(t)
+	ctx := context.Background()
+
+	recorded, err := os.ReadFile(utils.ResolveFile("components/movementsensor/gpsutils/data/rtcm3_recorded.bin"))
+	test.That(t, err, test.ShouldBeNil)
+
+	// the pipe stays open after the recording is written, like a serial port would.
+	devReader, devWriter := io.Pipe()
+	conf := &BaseConfig{
+		SerialPath:       "unused",
+		CasterAddress:    "127.0.0.1:0",
+		CasterMountpoint: "BASE",
+	}
+	ms, err := makeRTKBase(ctx, movementsensor.Named("base"), conf, devReader, logger)
+	test.That(t, err, test.ShouldBeNil)
+	base := ms.(*rtkBase)
+
+	ntripInfo, err := gpsutils.NewNtripInfo(&gpsutils.NtripConfig{
+		NtripURL:             "http://" + base.caster.Addr().String(),
+		NtripConnectAttempts: 1,
+		NtripMountpoint:      "BASE",
+	}, logger)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, ntripInfo.Connect(ctx, logger), test.ShouldBeNil)
+	stream, err := ntripInfo.GetStreamFromMountPoint(ctx, logger)
+	test.That(t, err, test.ShouldBeNil)
+	testutils.WaitForAssertion(t, func(tb testing.TB) {
+		tb.Helper()
+		test.That(tb, base.caster.NumClients(), test.ShouldEqual, 1)
+	})
+
+	go func() {
+		//nolint:errcheck
+		devWriter.Write(recorded)
+	}()
+
+	// the rover receives every intact frame and none of the NMEA sentences.
+	received := gpsutils.NewRTCMDecoder(stream)
+	for _, expected := range []int{1005, 1077, 1087, 1019, 1230, 1006} {
+		frame, err := received.Next()
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, frame.MessageType, test.ShouldEqual, expected)
+	}
+	test.That(t, received.SkippedBytes(), test.ShouldEqual, 0)
+
+	testutils.WaitForAssertion(t, func(tb testing.TB) {
+		tb.Helper()
+		pos, _, err := base.Position(ctx, nil)
+		test.That(tb, err, test.ShouldBeNil)
+		test.That(tb, pos.Lat(), test.ShouldAlmostEqual, 37.391098, 1e-5)
+		test.That(tb, pos.Lng(), test.ShouldAlmostEqual, -122.037826, 1e-5)
+	})
+
+	readings, err := base.Readings(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, readings["rtcm_message_count"], test.ShouldEqual, 6)
+	test.That(t, readings["rtcm_crc_errors"], test.ShouldBeGreaterThanOrEqualTo, 1)
+	test.That(t, readings[correctionsStaleKey], test.ShouldBeFalse)
+	test.That(t, readings["ntrip_clients"], test.ShouldEqual, 1)
+
+	test.That(t, stream.Close(), test.ShouldBeNil)
+	test.That(t, ntripInfo.Close(ctx), test.ShouldBeNil)
+	test.That(t, base.Close(ctx), test.ShouldBeNil)
+}
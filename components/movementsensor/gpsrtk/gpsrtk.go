@@ -22,8 +22,8 @@ import (
 	"io"
 	"math"
 	"sync"
+	"time"
 
-	"github.com/go-gnss/rtcm/rtcm3"
 	"github.com/golang/geo/r3"
 	geo "github.com/kellydunn/golang-geo"
 	"go.viam.com/utils"
@@ -35,6 +35,12 @@ import (
 	"go.viam.com/rdk/spatialmath"
 )
 
+const (
+	defaultCorrectionTimeoutSec = 10
+	rtkFixedQuality             = 4
+	correctionsStaleKey         = "rtcm_corrections_stale"
+)
+
 // gpsrtk is an nmea movementsensor model that can intake RTK correction data.
 type gpsrtk struct {
 	resource.Named
@@ -56,13 +62,22 @@ type gpsrtk struct {
 	wbaud            int
 	isVirtualBase    bool
 	vrs              *gpsutils.VRS
+
+	rtcm              *gpsutils.RTCMMonitor
+	correctionTimeout time.Duration
 }
 
 func (g *gpsrtk) start() error {
 	if g.workers != nil {
 		return errors.New("do not call start() twice on the same object")
 	}
-	g.workers = utils.NewBackgroundStoppableWorkers(g.receiveAndWriteCorrectionData)
+	if g.rtcm == nil {
+		g.rtcm = gpsutils.NewRTCMMonitor()
+	}
+	if g.correctionTimeout == 0 {
+		g.correctionTimeout = defaultCorrectionTimeoutSec * time.Second
+	}
+	g.workers = utils.NewBackgroundStoppableWorkers(g.receiveAndWriteCorrectionData, g.monitorCorrections)
 	return nil
 }
 
@@ -112,7 +127,7 @@ func (g *gpsrtk) connectAndParseSourceTable(cancelCtx context.Context) error {
 	return nil
 }
 
-func (g *gpsrtk) getStream(cancelCtx context.Context) (*rtcm3.Scanner, error) {
+func (g *gpsrtk) getStream(cancelCtx context.Context) (*gpsutils.RTCMDecoder, error) {
 	var streamSource io.Reader
 
 	if g.isVirtualBase {
@@ -131,8 +146,7 @@ func (g *gpsrtk) getStream(cancelCtx context.Context) (*rtcm3.Scanner, error) {
 		}
 	}
 	reader := io.TeeReader(streamSource, g.correctionWriter)
-	scanner := rtcm3.NewScanner(reader)
-	return &scanner, nil
+	return gpsutils.NewRTCMDecoder(reader), nil
 }
 
 // receiveAndWriteCorrectionData connects to the NTRIP receiver and sends the correction stream to
@@ -148,27 +162,78 @@ func (g *gpsrtk) receiveAndWriteCorrectionData(cancelCtx context.Context) {
 
 	// While we're supposed to keep running, (re)connect to the caster.
 	for !g.isClosed && cancelCtx.Err() == nil {
-		scanner, err := g.getStream(cancelCtx)
+		decoder, err := g.getStream(cancelCtx)
 		if err != nil {
 			g.logger.Errorf("unable to get NTRIP stream! Aborting: %w", err)
 			return
 		}
 
+		crcErrors := 0
 		for err == nil { // Keep checking our connection until it fails and needs to reconnect
 			if g.isClosed || cancelCtx.Err() != nil {
 				return
 			}
 
-			// Calling NextMessage() reads from the scanner until a valid message is found, and
-			// returns that. We don't care about the message: we care that the scanner is able to
-			// read messages at all! So, focus on whether the scanner had errors (which indicate we
-			// need to reconnect to the mount point), and not the message itself.
-			_, err = scanner.NextMessage()
+			// Calling Next() reads from the decoder until a valid frame is found. The bytes have
+			// already been forwarded to the receiver by the TeeReader; the frame is only recorded so
+			// we can report on the health of the correction stream. Errors from the decoder
+			// indicate we need to reconnect to the mount point.
+			var frame gpsutils.RTCMFrame
+			frame, err = decoder.Next()
+			if err == nil {
+				g.rtcm.Record(frame, time.Now())
+			}
+			if n := decoder.CRCErrors(); n != crcErrors {
+				g.rtcm.RecordCRCErrors(n - crcErrors)
+				crcErrors = n
+			}
 		}
 		g.logger.Debugf("no longer connected to NTRIP scanner: %s", err)
 	}
 }
 
+// monitorCorrections periodically checks the age of the last correction frame and the fix quality,
+// so that a stalled correction stream is reported before the position degrades.
+func (g *gpsrtk) monitorCorrections(cancelCtx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	wasStale := false
+	lastFix := -1
+	for {
+		select {
+		case <-cancelCtx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		status := g.rtcm.Status(time.Now())
+		stale := status.IsStale(g.correctionTimeout)
+		switch {
+		case stale && !wasStale && status.MessageCount > 0:
+			g.logger.CWarnf(cancelCtx, "no RTCM corrections received for %v, the RTK fix will degrade", status.Age.Round(time.Second))
+		case !stale && wasStale:
+			g.logger.CInfo(cancelCtx, "RTCM corrections resumed")
+		}
+		wasStale = stale
+
+		fix := g.cachedData.GetCommonReadings(cancelCtx).FixValue
+		if lastFix == rtkFixedQuality && fix != rtkFixedQuality {
+			g.logger.CWarnf(cancelCtx, "lost RTK fixed solution, fix quality is now %d, correction age is %v",
+				fix, status.Age.Round(time.Millisecond))
+		}
+		lastFix = fix
+	}
+}
+
+// correctionStatus returns the status of the correction stream, or false if no stream has been started.
+func (g *gpsrtk) correctionStatus() (gpsutils.RTCMStatus, bool) {
+	if g.rtcm == nil {
+		return gpsutils.RTCMStatus{}, false
+	}
+	return g.rtcm.Status(time.Now()), true
+}
+
 // Most of the movementsensor functions here don't have mutex locks since g.cachedData is protected by
 // it's own mutex and not having mutex around g.err is alright.
 
@@ -257,7 +322,19 @@ func (g *gpsrtk) Accuracy(ctx context.Context, extra map[string]interface{}) (*m
 		return nil, lastError
 	}
 
-	return g.cachedData.Accuracy(ctx, extra)
+	acc, err := g.cachedData.Accuracy(ctx, extra)
+	if err != nil {
+		return nil, err
+	}
+	if status, ok := g.correctionStatus(); ok {
+		if acc.AccuracyMap == nil {
+			acc.AccuracyMap = map[string]float32{}
+		}
+		for k, v := range status.AccuracyMap() {
+			acc.AccuracyMap[k] = v
+		}
+	}
+	return acc, nil
 }
 
 // Readings will use the default MovementSensor Readings if not provided.
@@ -272,6 +349,13 @@ func (g *gpsrtk) Readings(ctx context.Context, extra map[string]interface{}) (ma
 	readings["fix"] = commonReadings.FixValue
 	readings["satellites_in_use"] = commonReadings.SatsInUse
 
+	if status, ok := g.correctionStatus(); ok {
+		for k, v := range status.Readings() {
+			readings[k] = v
+		}
+		readings[correctionsStaleKey] = status.IsStale(g.correctionTimeout)
+	}
+
 	return readings, nil
 }
 
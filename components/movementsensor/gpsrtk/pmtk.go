@@ -6,6 +6,7 @@ package gpsrtk
 import (
 	"context"
 	"io"
+	"time"
 
 	"go.uber.org/multierr"
 
@@ -29,6 +30,8 @@ type I2CConfig struct {
 	NtripMountpoint      string `json:"ntrip_mountpoint,omitempty"`
 	NtripPass            string `json:"ntrip_password,omitempty"`
 	NtripUser            string `json:"ntrip_username,omitempty"`
+
+	CorrectionTimeoutSec float64 `json:"correction_timeout_sec,omitempty"`
 }
 
 // Validate ensures all parts of the config are valid.
@@ -101,6 +104,9 @@ func makeRTKI2C(
 		Named:  conf.ResourceName().AsNamed(),
 		logger: logger,
 		err:    movementsensor.NewLastError(1, 1),
+
+		rtcm:              gpsutils.NewRTCMMonitor(),
+		correctionTimeout: time.Duration(newConf.CorrectionTimeoutSec * float64(time.Second)),
 	}
 
 	ntripConfig := &gpsutils.NtripConfig{
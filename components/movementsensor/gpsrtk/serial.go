@@ -36,6 +36,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"time"
 
 	slib "github.com/jacobsa/go-serial/serial"
 	"go.uber.org/multierr"
@@ -58,6 +59,8 @@ type SerialConfig struct {
 	NtripMountpoint      string `json:"ntrip_mountpoint,omitempty"`
 	NtripPass            string `json:"ntrip_password,omitempty"`
 	NtripUser            string `json:"ntrip_username,omitempty"`
+
+	CorrectionTimeoutSec float64 `json:"correction_timeout_sec,omitempty"`
 }
 
 // Validate ensures all parts of the config are valid.
@@ -97,6 +100,9 @@ func newRTKSerial(
 		Named:  conf.ResourceName().AsNamed(),
 		logger: logger,
 		err:    movementsensor.NewLastError(1, 1),
+
+		rtcm:              gpsutils.NewRTCMMonitor(),
+		correctionTimeout: time.Duration(newConf.CorrectionTimeoutSec * float64(time.Second)),
 	}
 
 	if newConf.SerialPath != "" {
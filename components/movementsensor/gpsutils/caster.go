@@ -0,0 +1,222 @@
+// Package gpsutils contains GPS-related code shared between multiple components. This file
+// implements a minimal NTRIP 2.0 caster which serves the corrections of a single local base station.
+package gpsutils
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	geo "github.com/kellydunn/golang-geo"
+	goutils "go.viam.com/utils"
+
+	"go.viam.com/rdk/logging"
+)
+
+// number of frames buffered per client before frames are dropped for that client.
+const casterClientBuffer = 64
+
+// CasterConfig is used for converting the attributes of a built-in NTRIP caster.
+type CasterConfig struct {
+	CasterAddress    string `json:"caster_address,omitempty"`
+	CasterMountpoint string `json:"caster_mountpoint"`
+	CasterUser       string `json:"caster_username,omitempty"`
+	CasterPass       string `json:"caster_password,omitempty"`
+}
+
+// DefaultCasterAddress is the address a caster listens on when none is configured. 2101 is the
+// port registered for NTRIP.
+const DefaultCasterAddress = ":2101"
+
+// NtripCaster serves RTCM3 frames from a single mountpoint to any number of NTRIP clients.
+type NtripCaster struct {
+	mountpoint string
+	username   string
+	password   string
+	logger     logging.Logger
+
+	listener net.Listener
+	server   *http.Server
+	workers  *goutils.StoppableWorkers
+
+	mu       sync.Mutex
+	clients  map[chan []byte]struct{}
+	location *geo.Point
+	types    map[int]struct{}
+}
+
+// NewNtripCaster starts a caster listening on the configured address.
+func NewNtripCaster(cfg *CasterConfig, logger logging.Logger) (*NtripCaster, error) {
+	if cfg.CasterMountpoint == "" {
+		return nil, errors.New("NTRIP caster expected a non-empty mountpoint")
+	}
+	addr := cfg.CasterAddress
+	if addr == "" {
+		addr = DefaultCasterAddress
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &NtripCaster{
+		mountpoint: cfg.CasterMountpoint,
+		username:   cfg.CasterUser,
+		password:   cfg.CasterPass,
+		logger:     logger,
+		listener:   listener,
+		clients:    map[chan []byte]struct{}{},
+		types:      map[int]struct{}{},
+	}
+	c.server = &http.Server{
+		Handler:           c,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	c.workers = goutils.NewBackgroundStoppableWorkers(func(ctx context.Context) {
+		if err := c.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.CErrorf(ctx, "NTRIP caster stopped serving: %v", err)
+		}
+	})
+	logger.Infof("NTRIP caster serving mountpoint %q on %s", c.mountpoint, listener.Addr())
+	return c, nil
+}
+
+// Addr returns the address the caster is listening on.
+func (c *NtripCaster) Addr() net.Addr {
+	return c.listener.Addr()
+}
+
+// SetLocation sets the base station location advertised in the sourcetable.
+func (c *NtripCaster) SetLocation(pt *geo.Point) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.location = pt
+}
+
+// NumClients returns the number of clients currently streaming corrections.
+func (c *NtripCaster) NumClients() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.clients)
+}
+
+// Broadcast sends a frame to every connected client. Clients which are not keeping up miss the frame
+// rather than slowing down the others.
+func (c *NtripCaster) Broadcast(frame RTCMFrame) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.types[frame.MessageType] = struct{}{}
+	for client := range c.clients {
+		select {
+		case client <- frame.Raw:
+		default:
+			c.logger.Debug("NTRIP caster client is not keeping up, dropping a frame")
+		}
+	}
+}
+
+func (c *NtripCaster) sourcetable() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lat, lng := 0., 0.
+	if c.location != nil {
+		lat, lng = c.location.Lat(), c.location.Lng()
+	}
+	types := make([]string, 0, len(c.types))
+	for t := range c.types {
+		types = append(types, fmt.Sprint(t))
+	}
+	authentication := "N"
+	if c.username != "" {
+		authentication = "B"
+	}
+	// STR;mountpoint;identifier;format;format-details;carrier;nav-system;network;country;latitude;longitude;
+	// nmea;solution;generator;compression;authentication;fee;bitrate;misc
+	return fmt.Sprintf("STR;%s;%s;RTCM 3;%s;2;GNSS;viam;;%.4f;%.4f;0;0;viam-rdk;none;%s;N;0;\r\nENDSOURCETABLE\r\n",
+		c.mountpoint, c.mountpoint, strings.Join(types, ","), lat, lng, authentication)
+}
+
+func (c *NtripCaster) authorized(r *http.Request) bool {
+	if c.username == "" {
+		return true
+	}
+	user, pass, ok := r.BasicAuth()
+	return ok &&
+		subtle.ConstantTimeCompare([]byte(user), []byte(c.username)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(pass), []byte(c.password)) == 1
+}
+
+// ServeHTTP implements http.Handler, serving the sourcetable at the root and the stream at the mountpoint.
+func (c *NtripCaster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Ntrip-Version", "Ntrip/2.0")
+	w.Header().Set("Server", "NTRIP viam-rdk caster")
+
+	switch strings.TrimPrefix(r.URL.Path, "/") {
+	case "":
+		w.Header().Set("Content-Type", "gnss/sourcetable")
+		w.WriteHeader(http.StatusOK)
+		//nolint:errcheck
+		w.Write([]byte(c.sourcetable()))
+		return
+	case c.mountpoint:
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	if !c.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="`+c.mountpoint+`"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	frames := make(chan []byte, casterClientBuffer)
+	c.mu.Lock()
+	c.clients[frames] = struct{}{}
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.clients, frames)
+		c.mu.Unlock()
+	}()
+	c.logger.CDebugf(r.Context(), "NTRIP client %s connected to %s", r.RemoteAddr, c.mountpoint)
+
+	w.Header().Set("Content-Type", "gnss/data")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			c.logger.CDebugf(r.Context(), "NTRIP client %s disconnected", r.RemoteAddr)
+			return
+		case frame := <-frames:
+			if _, err := w.Write(frame); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// Close stops the caster and disconnects every client.
+func (c *NtripCaster) Close(ctx context.Context) error {
+	err := c.server.Close()
+	c.workers.Stop()
+	return err
+}
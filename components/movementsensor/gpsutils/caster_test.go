@@ -0,0 +1,149 @@
+package gpsutils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	geo "github.com/kellydunn/golang-geo"
+	"go.viam.com/test"
+	"go.viam.com/utils/testutils"
+
+	"go.viam.com/rdk/logging"
+)
+
+func TestNtripCaster(t *testing.T) {
+	logger := logging.NewTestLogger(t)
+	ctx := context.Background()
+
+	_, err := NewNtripCaster(&CasterConfig{}, logger)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	caster, err := NewNtripCaster(&CasterConfig{
+		CasterAddress:    "127.0.0.1:0",
+		CasterMountpoint: "BASE",
+		CasterUser:       "user",
+		CasterPass:       "pwd",
+	}, logger)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, caster.Close(ctx), test.ShouldBeNil)
+	}()
+	caster.SetLocation(geo.NewPoint(40.7, -74))
+	url := "http://" + caster.Addr().String()
+
+	t.Run("sourcetable", func(t *testing.T) {
+		ntripInfo, err := NewNtripInfo(&NtripConfig{
+			NtripURL:             url,
+			NtripConnectAttempts: 1,
+			NtripMountpoint:      "BASE",
+			NtripUser:            "user",
+			NtripPass:            "pwd",
+		}, logger)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, ntripInfo.Connect(ctx, logger), test.ShouldBeNil)
+		defer func() {
+			test.That(t, ntripInfo.Close(ctx), test.ShouldBeNil)
+		}()
+
+		st, err := ntripInfo.ParseSourcetable(logger)
+		test.That(t, err, test.ShouldBeNil)
+		str, ok := st.HasStream("BASE")
+		test.That(t, ok, test.ShouldBeTrue)
+		test.That(t, str.Latitude, test.ShouldAlmostEqual, 40.7, 1e-4)
+		test.That(t, str.Authentication, test.ShouldEqual, "B")
+		isVRS, err := HasVRSStream(st, "BASE")
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, isVRS, test.ShouldBeFalse)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		resp, err := http.Get(url + "/BASE")
+		test.That(t, err, test.ShouldBeNil)
+		defer resp.Body.Close()
+		test.That(t, resp.StatusCode, test.ShouldEqual, http.StatusUnauthorized)
+
+		resp, err = http.Get(url + "/OTHER")
+		test.That(t, err, test.ShouldBeNil)
+		defer resp.Body.Close()
+		test.That(t, resp.StatusCode, test.ShouldEqual, http.StatusNotFound)
+	})
+
+	t.Run("replay recorded stream", func(t *testing.T) {
+		ntripInfo, err := NewNtripInfo(&NtripConfig{
+			NtripURL:             url,
+			NtripConnectAttempts: 1,
+			NtripMountpoint:      "BASE",
+			NtripUser:            "user",
+			NtripPass:            "pwd",
+		}, logger)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, ntripInfo.Connect(ctx, logger), test.ShouldBeNil)
+		defer func() {
+			test.That(t, ntripInfo.Close(ctx), test.ShouldBeNil)
+		}()
+
+		stream, err := ntripInfo.GetStreamFromMountPoint(ctx, logger)
+		test.That(t, err, test.ShouldBeNil)
+
+		testutils.WaitForAssertion(t, func(tb testing.TB) {
+			tb.Helper()
+			test.That(tb, caster.NumClients(), test.ShouldEqual, 1)
+		})
+
+		var sent [][]byte
+		decoder := NewRTCMDecoder(bytes.NewReader(readRecordedStream(t)))
+		for {
+			frame, err := decoder.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			test.That(t, err, test.ShouldBeNil)
+			caster.Broadcast(frame)
+			sent = append(sent, frame.Raw)
+		}
+
+		received := NewRTCMDecoder(stream)
+		for _, raw := range sent {
+			frame, err := received.Next()
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, frame.Raw, test.ShouldResemble, raw)
+		}
+		test.That(t, received.SkippedBytes(), test.ShouldEqual, 0)
+		test.That(t, stream.Close(), test.ShouldBeNil)
+
+		testutils.WaitForAssertion(t, func(tb testing.TB) {
+			tb.Helper()
+			test.That(tb, caster.NumClients(), test.ShouldEqual, 0)
+		})
+		// frames broadcast without any clients are simply dropped.
+		caster.Broadcast(RTCMFrame{MessageType: 1230, Raw: sent[0]})
+	})
+
+	t.Run("close disconnects clients", func(t *testing.T) {
+		other, err := NewNtripCaster(&CasterConfig{CasterAddress: "127.0.0.1:0", CasterMountpoint: "BASE"}, logger)
+		test.That(t, err, test.ShouldBeNil)
+		resp, err := http.Get("http://" + other.Addr().String() + "/BASE")
+		test.That(t, err, test.ShouldBeNil)
+		defer resp.Body.Close()
+		test.That(t, resp.StatusCode, test.ShouldEqual, http.StatusOK)
+		test.That(t, resp.Header.Get("Content-Type"), test.ShouldEqual, "gnss/data")
+
+		done := make(chan struct{})
+		go func() {
+			//nolint:errcheck
+			io.Copy(io.Discard, resp.Body)
+			close(done)
+		}()
+		test.That(t, other.Close(ctx), test.ShouldBeNil)
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("client was not disconnected when the caster closed")
+		}
+	})
+}
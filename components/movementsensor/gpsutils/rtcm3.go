@@ -0,0 +1,390 @@
+// Package gpsutils contains GPS-related code shared between multiple components. This file
+// decodes RTCM3 correction frames and keeps track of the health of a correction stream.
+package gpsutils
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	geo "github.com/kellydunn/golang-geo"
+)
+
+// RTCM3 framing, see RTCM standard 10403.3 section 4.
+const (
+	rtcmPreamble      = 0xD3
+	rtcmHeaderLen     = 3
+	rtcmCRCLen        = 3
+	rtcmMaxPayloadLen = 1023
+	crc24qPoly        = 0x1864CFB
+)
+
+var (
+	// ErrRTCMPayloadTooLong is returned when trying to encode a payload which does not fit in a frame.
+	ErrRTCMPayloadTooLong = errors.New("rtcm3 payload longer than 1023 bytes")
+	errRTCMShortPayload   = errors.New("rtcm3 payload too short to hold a message number")
+)
+
+var crc24qTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		crc := uint32(i) << 16
+		for j := 0; j < 8; j++ {
+			crc <<= 1
+			if crc&0x1000000 != 0 {
+				crc ^= crc24qPoly
+			}
+		}
+		table[i] = crc & 0xFFFFFF
+	}
+	return table
+}()
+
+// CRC24Q computes the 24 bit Qualcomm CRC used to protect RTCM3 frames.
+func CRC24Q(data []byte) uint32 {
+	var crc uint32
+	for _, b := range data {
+		crc = ((crc << 8) & 0xFFFFFF) ^ crc24qTable[byte(crc>>16)^b]
+	}
+	return crc
+}
+
+// EncodeRTCMFrame wraps an RTCM3 message payload in a frame with a header and CRC.
+func EncodeRTCMFrame(payload []byte) ([]byte, error) {
+	if len(payload) > rtcmMaxPayloadLen {
+		return nil, ErrRTCMPayloadTooLong
+	}
+	frame := make([]byte, 0, rtcmHeaderLen+len(payload)+rtcmCRCLen)
+	frame = append(frame, rtcmPreamble, byte(len(payload)>>8)&0x03, byte(len(payload)))
+	frame = append(frame, payload...)
+	crc := CRC24Q(frame)
+	return append(frame, byte(crc>>16), byte(crc>>8), byte(crc)), nil
+}
+
+// RTCMFrame is a single CRC validated RTCM3 message.
+type RTCMFrame struct {
+	MessageType int
+	// StationID is the reference station ID for observation and station messages, or -1
+	// for messages which do not carry one (ephemerides, for example).
+	StationID int
+	Payload   []byte
+	// Raw is the complete frame, including the header and CRC, as it appeared on the wire.
+	Raw []byte
+}
+
+// RTCMDecoder reads RTCM3 frames from a byte stream. Bytes which are not part of a valid frame
+// are skipped, and frames which fail their CRC check are counted and dropped.
+type RTCMDecoder struct {
+	r *bufio.Reader
+
+	// NonRTCM, when set, receives every byte that is not part of a valid RTCM3 frame. This lets a
+	// receiver that interleaves NMEA sentences with its corrections be read from a single port.
+	NonRTCM io.Writer
+
+	crcErrors    int
+	skippedBytes int
+}
+
+// NewRTCMDecoder returns a decoder reading from r.
+func NewRTCMDecoder(r io.Reader) *RTCMDecoder {
+	return &RTCMDecoder{r: bufio.NewReaderSize(r, rtcmHeaderLen+rtcmMaxPayloadLen+rtcmCRCLen)}
+}
+
+// CRCErrors returns the number of frames which were dropped because their CRC did not match.
+func (d *RTCMDecoder) CRCErrors() int {
+	return d.crcErrors
+}
+
+// SkippedBytes returns the number of bytes read which were not part of a valid frame.
+func (d *RTCMDecoder) SkippedBytes() int {
+	return d.skippedBytes
+}
+
+func (d *RTCMDecoder) skip(b byte) {
+	d.skippedBytes++
+	if d.NonRTCM != nil {
+		//nolint:errcheck
+		d.NonRTCM.Write([]byte{b})
+	}
+}
+
+// Next blocks until the next valid frame has been read, or the underlying reader returns an error.
+func (d *RTCMDecoder) Next() (RTCMFrame, error) {
+	for {
+		b, err := d.r.ReadByte()
+		if err != nil {
+			return RTCMFrame{}, err
+		}
+		if b != rtcmPreamble {
+			d.skip(b)
+			continue
+		}
+
+		header, err := d.r.Peek(rtcmHeaderLen - 1)
+		if err != nil {
+			return RTCMFrame{}, err
+		}
+		// the six bits following the preamble are reserved and must be zero.
+		if header[0]&0xFC != 0 {
+			d.skip(b)
+			continue
+		}
+		length := int(header[0]&0x03)<<8 | int(header[1])
+
+		rest, err := d.r.Peek(rtcmHeaderLen - 1 + length + rtcmCRCLen)
+		if err != nil {
+			return RTCMFrame{}, err
+		}
+		raw := make([]byte, 0, 1+len(rest))
+		raw = append(raw, b)
+		raw = append(raw, rest...)
+
+		body := raw[:rtcmHeaderLen+length]
+		crc := uint32(raw[len(raw)-3])<<16 | uint32(raw[len(raw)-2])<<8 | uint32(raw[len(raw)-1])
+		if CRC24Q(body) != crc {
+			// this was either a corrupted frame or a preamble byte inside other data, so resync on
+			// the next byte rather than discarding the whole candidate frame.
+			d.crcErrors++
+			d.skip(b)
+			continue
+		}
+		if _, err := d.r.Discard(len(rest)); err != nil {
+			return RTCMFrame{}, err
+		}
+
+		payload := raw[rtcmHeaderLen : rtcmHeaderLen+length]
+		frame := RTCMFrame{Payload: payload, Raw: raw, MessageType: -1, StationID: -1}
+		if len(payload) >= 2 {
+			frame.MessageType = int(getBits(payload, 0, 12))
+			if hasStationID(frame.MessageType) && len(payload) >= 3 {
+				frame.StationID = int(getBits(payload, 12, 12))
+			}
+		}
+		return frame, nil
+	}
+}
+
+// hasStationID reports whether the 12 bits after the message number of this message type hold a
+// reference station ID.
+func hasStationID(msgType int) bool {
+	switch {
+	case msgType >= 1001 && msgType <= 1012: // legacy GPS and GLONASS observations, station ARP and antenna
+	case msgType == 1013 || msgType == 1029 || msgType == 1033 || msgType == 1230:
+	case msgType >= 1071 && msgType <= 1137: // multiple signal messages for every constellation
+	default:
+		return false
+	}
+	return true
+}
+
+// getBits returns the unsigned big-endian value of n bits of data starting at bit offset pos.
+func getBits(data []byte, pos, n int) uint64 {
+	var v uint64
+	for i := pos; i < pos+n; i++ {
+		v = v<<1 | uint64(data[i/8]>>(7-uint(i%8))&1)
+	}
+	return v
+}
+
+// getSignedBits returns the two's complement value of n bits of data starting at bit offset pos.
+func getSignedBits(data []byte, pos, n int) int64 {
+	v := getBits(data, pos, n)
+	if v&(1<<(n-1)) != 0 {
+		return int64(v) - int64(1)<<n
+	}
+	return int64(v)
+}
+
+// StationARP is the antenna reference point of a reference station, as reported by message 1005 or 1006.
+type StationARP struct {
+	StationID int
+	// ECEF coordinates in meters.
+	X, Y, Z float64
+	// AntennaHeight in meters, only reported by message 1006.
+	AntennaHeight float64
+}
+
+// DecodeStationARP decodes the antenna reference point from a 1005 or 1006 message payload.
+func DecodeStationARP(payload []byte) (StationARP, error) {
+	if len(payload) < 2 {
+		return StationARP{}, errRTCMShortPayload
+	}
+	msgType := int(getBits(payload, 0, 12))
+	wantLen := 19
+	if msgType == 1006 {
+		wantLen = 21
+	} else if msgType != 1005 {
+		return StationARP{}, fmt.Errorf("rtcm3 message %d does not contain a station ARP", msgType)
+	}
+	if len(payload) < wantLen {
+		return StationARP{}, fmt.Errorf("rtcm3 message %d payload is %d bytes, expected %d", msgType, len(payload), wantLen)
+	}
+
+	arp := StationARP{
+		StationID: int(getBits(payload, 12, 12)),
+		X:         float64(getSignedBits(payload, 34, 38)) * 1e-4,
+		Y:         float64(getSignedBits(payload, 74, 38)) * 1e-4,
+		Z:         float64(getSignedBits(payload, 114, 38)) * 1e-4,
+	}
+	if msgType == 1006 {
+		arp.AntennaHeight = float64(getBits(payload, 152, 16)) * 1e-4
+	}
+	return arp, nil
+}
+
+// Location converts the ECEF coordinates of the ARP to a WGS84 latitude, longitude and altitude in meters.
+func (arp StationARP) Location() (*geo.Point, float64) {
+	const (
+		a  = 6378137.0         // WGS84 semi-major axis
+		f  = 1 / 298.257223563 // WGS84 flattening
+		b  = a * (1 - f)
+		e2 = f * (2 - f)
+		ep = (a*a - b*b) / (b * b)
+	)
+	p := math.Hypot(arp.X, arp.Y)
+	theta := math.Atan2(arp.Z*a, p*b)
+	sinT, cosT := math.Sincos(theta)
+	lat := math.Atan2(arp.Z+ep*b*sinT*sinT*sinT, p-e2*a*cosT*cosT*cosT)
+	lng := math.Atan2(arp.Y, arp.X)
+	sinLat := math.Sin(lat)
+	n := a / math.Sqrt(1-e2*sinLat*sinLat)
+	alt := p/math.Cos(lat) - n
+	return geo.NewPoint(lat*180/math.Pi, lng*180/math.Pi), alt
+}
+
+// RTCMStatus is a snapshot of the health of a correction stream.
+type RTCMStatus struct {
+	MessageCount int
+	CRCErrors    int
+	// MessageTypes counts the frames received per message type.
+	MessageTypes map[int]int
+	StationID    int
+	LastMessage  time.Time
+	// Age is the time since the last frame was received, or -1 if none has been.
+	Age time.Duration
+}
+
+// RTCMMonitor records the frames of a correction stream so that stalls can be detected and
+// reported alongside the position.
+type RTCMMonitor struct {
+	mu           sync.Mutex
+	messageCount int
+	crcErrors    int
+	messageTypes map[int]int
+	stationID    int
+	lastMessage  time.Time
+	arp          *StationARP
+}
+
+// NewRTCMMonitor returns a monitor which has not seen any frames.
+func NewRTCMMonitor() *RTCMMonitor {
+	return &RTCMMonitor{messageTypes: map[int]int{}, stationID: -1}
+}
+
+// Record stores a frame received at t.
+func (m *RTCMMonitor) Record(frame RTCMFrame, t time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messageCount++
+	m.messageTypes[frame.MessageType]++
+	if frame.StationID >= 0 {
+		m.stationID = frame.StationID
+	}
+	if t.After(m.lastMessage) {
+		m.lastMessage = t
+	}
+	if frame.MessageType == 1005 || frame.MessageType == 1006 {
+		if arp, err := DecodeStationARP(frame.Payload); err == nil {
+			m.arp = &arp
+		}
+	}
+}
+
+// RecordCRCErrors adds count frames which failed their CRC check.
+func (m *RTCMMonitor) RecordCRCErrors(count int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.crcErrors += count
+}
+
+// StationARP returns the last antenna reference point received, if any.
+func (m *RTCMMonitor) StationARP() (StationARP, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.arp == nil {
+		return StationARP{}, false
+	}
+	return *m.arp, true
+}
+
+// Status returns the state of the stream as of now.
+func (m *RTCMMonitor) Status(now time.Time) RTCMStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	types := make(map[int]int, len(m.messageTypes))
+	for k, v := range m.messageTypes {
+		types[k] = v
+	}
+	age := time.Duration(-1)
+	if !m.lastMessage.IsZero() {
+		age = now.Sub(m.lastMessage)
+	}
+	return RTCMStatus{
+		MessageCount: m.messageCount,
+		CRCErrors:    m.crcErrors,
+		MessageTypes: types,
+		StationID:    m.stationID,
+		LastMessage:  m.lastMessage,
+		Age:          age,
+	}
+}
+
+// IsStale reports whether no frame has been received within timeout. A stream which has never
+// delivered a frame is stale.
+func (s RTCMStatus) IsStale(timeout time.Duration) bool {
+	return s.Age < 0 || s.Age > timeout
+}
+
+// Readings returns the status in a form suitable for a sensor's readings.
+func (s RTCMStatus) Readings() map[string]interface{} {
+	types := make([]int, 0, len(s.MessageTypes))
+	for k := range s.MessageTypes {
+		types = append(types, k)
+	}
+	sort.Ints(types)
+	typeList := make([]interface{}, 0, len(types))
+	for _, t := range types {
+		typeList = append(typeList, t)
+	}
+
+	age := math.NaN()
+	if s.Age >= 0 {
+		age = s.Age.Seconds()
+	}
+	return map[string]interface{}{
+		"rtcm_message_count":      s.MessageCount,
+		"rtcm_crc_errors":         s.CRCErrors,
+		"rtcm_message_types":      typeList,
+		"rtcm_station_id":         s.StationID,
+		"rtcm_correction_age_sec": age,
+	}
+}
+
+// AccuracyMap returns the status as entries for a movement sensor's accuracy map.
+func (s RTCMStatus) AccuracyMap() map[string]float32 {
+	age := float32(math.NaN())
+	if s.Age >= 0 {
+		age = float32(s.Age.Seconds())
+	}
+	return map[string]float32{
+		"rtcm_correction_age_sec": age,
+		"rtcm_crc_errors":         float32(s.CRCErrors),
+	}
+}
@@ -0,0 +1,156 @@
+package gpsutils
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/utils"
+)
+
+const recordedStreamPath = "components/movementsensor/gpsutils/data/rtcm3_recorded.bin"
+
+func readRecordedStream(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile(utils.ResolveFile(recordedStreamPath))
+	test.That(t, err, test.ShouldBeNil)
+	return data
+}
+
+func TestCRC24Q(t *testing.T) {
+	// check value of the CRC-24/LTE-A (CRC24Q) parameters
+	test.That(t, CRC24Q([]byte("123456789")), test.ShouldEqual, 0xCDE703)
+	test.That(t, CRC24Q(nil), test.ShouldEqual, 0)
+}
+
+func TestEncodeRTCMFrame(t *testing.T) {
+	// message 1230 from station 1234, with no signals
+	payload := []byte{0x4C, 0xE4, 0xD2, 0x00}
+	raw, err := EncodeRTCMFrame(payload)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, raw[0], test.ShouldEqual, rtcmPreamble)
+	test.That(t, len(raw), test.ShouldEqual, len(payload)+6)
+
+	frame, err := NewRTCMDecoder(bytes.NewReader(raw)).Next()
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, frame.MessageType, test.ShouldEqual, 1230)
+	test.That(t, frame.StationID, test.ShouldEqual, 1234)
+	test.That(t, frame.Payload, test.ShouldResemble, payload)
+	test.That(t, frame.Raw, test.ShouldResemble, raw)
+
+	_, err = EncodeRTCMFrame(make([]byte, rtcmMaxPayloadLen+1))
+	test.That(t, err, test.ShouldBeError, ErrRTCMPayloadTooLong)
+}
+
+func TestDecodeRecordedStream(t *testing.T) {
+	var nonRTCM bytes.Buffer
+	decoder := NewRTCMDecoder(bytes.NewReader(readRecordedStream(t)))
+	decoder.NonRTCM = &nonRTCM
+
+	var frames []RTCMFrame
+	for {
+		frame, err := decoder.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		test.That(t, err, test.ShouldBeNil)
+		frames = append(frames, frame)
+	}
+
+	types := make([]int, 0, len(frames))
+	for _, f := range frames {
+		types = append(types, f.MessageType)
+	}
+	// the corrupted copy of 1087 is dropped, the intact one that follows it is kept.
+	test.That(t, types, test.ShouldResemble, []int{1005, 1077, 1087, 1019, 1230, 1006})
+	test.That(t, decoder.CRCErrors(), test.ShouldBeGreaterThanOrEqualTo, 1)
+
+	// ephemerides carry a satellite ID where other messages carry a station ID.
+	test.That(t, frames[3].StationID, test.ShouldEqual, -1)
+	for _, i := range []int{0, 1, 2, 4, 5} {
+		test.That(t, frames[i].StationID, test.ShouldBeGreaterThanOrEqualTo, 0)
+	}
+
+	// the NMEA sentences interleaved with the frames are passed through untouched.
+	test.That(t, nonRTCM.String(), test.ShouldContainSubstring,
+		"$GPGGA,172814.0,3723.46587704,N,12202.26957864,W,4,6,1.2,18.893,M,-25.669,M,2.0,0031*")
+	test.That(t, decoder.SkippedBytes(), test.ShouldEqual, nonRTCM.Len())
+}
+
+func TestDecodeStationARP(t *testing.T) {
+	// ECEF coordinates of a point on the equator at the prime meridian, 100m above the ellipsoid.
+	payload := make([]byte, 21)
+	putBits(payload, 0, 12, 1006)
+	putBits(payload, 12, 12, 42)
+	putBits(payload, 34, 38, uint64(int64(63782370000)))
+	putBits(payload, 74, 38, 0)
+	putBits(payload, 114, 38, 0)
+	putBits(payload, 152, 16, 15000)
+
+	arp, err := DecodeStationARP(payload)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, arp.StationID, test.ShouldEqual, 42)
+	test.That(t, arp.X, test.ShouldAlmostEqual, 6378237.0)
+	test.That(t, arp.AntennaHeight, test.ShouldAlmostEqual, 1.5)
+
+	loc, alt := arp.Location()
+	test.That(t, loc.Lat(), test.ShouldAlmostEqual, 0, 1e-9)
+	test.That(t, loc.Lng(), test.ShouldAlmostEqual, 0, 1e-9)
+	test.That(t, alt, test.ShouldAlmostEqual, 100, 1e-3)
+
+	// negative coordinates are two's complement.
+	negX := int64(-10000)
+	putBits(payload, 34, 38, uint64(negX)&(1<<38-1))
+	arp, err = DecodeStationARP(payload)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, arp.X, test.ShouldAlmostEqual, -1)
+
+	_, err = DecodeStationARP(payload[:10])
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = DecodeStationARP([]byte{0x4C, 0xE4, 0xD2, 0x00})
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestRTCMMonitor(t *testing.T) {
+	m := NewRTCMMonitor()
+	now := time.Now()
+
+	status := m.Status(now)
+	test.That(t, status.Age, test.ShouldBeLessThan, 0)
+	test.That(t, status.IsStale(time.Hour), test.ShouldBeTrue)
+	test.That(t, math.IsNaN(status.Readings()["rtcm_correction_age_sec"].(float64)), test.ShouldBeTrue)
+
+	m.Record(RTCMFrame{MessageType: 1077, StationID: 7}, now.Add(-3*time.Second))
+	m.Record(RTCMFrame{MessageType: 1077, StationID: 7}, now.Add(-2*time.Second))
+	m.Record(RTCMFrame{MessageType: 1019, StationID: -1}, now.Add(-2*time.Second))
+	m.RecordCRCErrors(2)
+
+	status = m.Status(now)
+	test.That(t, status.MessageCount, test.ShouldEqual, 3)
+	test.That(t, status.CRCErrors, test.ShouldEqual, 2)
+	test.That(t, status.StationID, test.ShouldEqual, 7)
+	test.That(t, status.MessageTypes, test.ShouldResemble, map[int]int{1077: 2, 1019: 1})
+	test.That(t, status.Age, test.ShouldEqual, 2*time.Second)
+	test.That(t, status.IsStale(time.Second), test.ShouldBeTrue)
+	test.That(t, status.IsStale(5*time.Second), test.ShouldBeFalse)
+
+	readings := status.Readings()
+	test.That(t, readings["rtcm_message_types"], test.ShouldResemble, []interface{}{1019, 1077})
+	test.That(t, readings["rtcm_correction_age_sec"], test.ShouldEqual, 2.)
+	test.That(t, status.AccuracyMap()["rtcm_crc_errors"], test.ShouldEqual, 2)
+}
+
+// putBits is the inverse of getBits.
+func putBits(data []byte, pos, n int, v uint64) {
+	for i := 0; i < n; i++ {
+		bit := byte(v>>(n-1-i)) & 1
+		idx := pos + i
+		data[idx/8] = data[idx/8]&^(1<<(7-uint(idx%8))) | bit<<(7-uint(idx%8))
+	}
+}
@@ -0,0 +1,322 @@
+package replay
+
+/*
+	This file implements a replay movement sensor which plays back data captured by the data manager
+	from .capture files in a local directory, so recordings can be replayed without a network
+	connection.
+
+	Example configuration:
+	{
+      "type": "movement_sensor",
+	  "model": "replay_local",
+      "name": "my-replay",
+      "attributes": {
+        "source": "gps",
+        "capture_dir": "/home/user/.viam/capture",
+        "time_interval": {
+          "start": "2024-01-01T12:00:00Z",
+          "end": "2024-01-01T12:30:00Z"
+        },
+        "playback_mode": "accelerated",
+        "playback_speed": 2,
+        "loop": true
+      }
+    }
+*/
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/golang/geo/r3"
+	geo "github.com/kellydunn/golang-geo"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"go.viam.com/rdk/components/movementsensor"
+	"go.viam.com/rdk/data"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/spatialmath"
+)
+
+// localModel is the model of a replay movement sensor which reads from local capture files.
+var localModel = resource.DefaultModelFamily.WithModel("replay_local")
+
+func init() {
+	resource.RegisterComponent(movementsensor.API, localModel, resource.Registration[movementsensor.MovementSensor, *LocalConfig]{
+		Constructor: newLocalReplayMovementSensor,
+	})
+}
+
+// LocalConfig describes how to configure the local replay movement sensor.
+type LocalConfig struct {
+	Source        string       `json:"source"`
+	CaptureDir    string       `json:"capture_dir"`
+	Interval      TimeInterval `json:"time_interval,omitempty"`
+	PlaybackMode  string       `json:"playback_mode,omitempty"`
+	PlaybackSpeed float64      `json:"playback_speed,omitempty"`
+	Loop          bool         `json:"loop,omitempty"`
+}
+
+// Validate checks that the config attributes are valid for a local replay movement sensor.
+func (cfg *LocalConfig) Validate(path string) ([]string, error) {
+	if cfg.Source == "" {
+		return nil, resource.NewConfigValidationFieldRequiredError(path, "source")
+	}
+	if cfg.CaptureDir == "" {
+		return nil, resource.NewConfigValidationFieldRequiredError(path, "capture_dir")
+	}
+	if _, _, err := cfg.Interval.parse(); err != nil {
+		return nil, resource.NewConfigValidationError(path, err)
+	}
+	replayConfig := cfg.replayConfig()
+	if err := replayConfig.Validate(); err != nil {
+		return nil, resource.NewConfigValidationError(path, err)
+	}
+	return nil, nil
+}
+
+func (cfg *LocalConfig) replayConfig() data.ReplayConfig {
+	return data.ReplayConfig{
+		Mode:  data.ReplayMode(cfg.PlaybackMode),
+		Speed: cfg.PlaybackSpeed,
+		Loop:  cfg.Loop,
+	}
+}
+
+// parse returns the start and end of the interval, either of which may be zero if unset.
+func (interval TimeInterval) parse() (time.Time, time.Time, error) {
+	var start, end time.Time
+	var err error
+	if interval.Start != "" {
+		if start, err = time.Parse(timeFormat, interval.Start); err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid time format for start time (UTC), use RFC3339")
+		}
+	}
+	if interval.End != "" {
+		if end, err = time.Parse(timeFormat, interval.End); err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid time format for end time (UTC), use RFC3339")
+		}
+	}
+	if !start.IsZero() && !end.IsZero() && start.After(end) {
+		return time.Time{}, time.Time{}, errors.New("invalid config, end time (UTC) must be after start time (UTC)")
+	}
+	return start, end, nil
+}
+
+// localReplayMovementSensor is a movement sensor model that plays back movement sensor data
+// captured to local files.
+type localReplayMovementSensor struct {
+	resource.Named
+	resource.AlwaysRebuild
+	logger logging.Logger
+
+	replay     *data.CaptureReplay
+	properties movementsensor.Properties
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func newLocalReplayMovementSensor(
+	ctx context.Context,
+	deps resource.Dependencies,
+	conf resource.Config,
+	logger logging.Logger,
+) (movementsensor.MovementSensor, error) {
+	newConf, err := resource.NativeConfig[*LocalConfig](conf)
+	if err != nil {
+		return nil, err
+	}
+	return makeLocalReplayMovementSensor(conf.ResourceName(), newConf, clock.New(), logger)
+}
+
+// makeLocalReplayMovementSensor is separate from newLocalReplayMovementSensor so tests can control the playback clock.
+func makeLocalReplayMovementSensor(
+	name resource.Name,
+	conf *LocalConfig,
+	clk clock.Clock,
+	logger logging.Logger,
+) (*localReplayMovementSensor, error) {
+	start, end, err := conf.Interval.parse()
+	if err != nil {
+		return nil, err
+	}
+	readings, err := data.ReadCaptureDir(conf.CaptureDir, movementsensor.Named(conf.Source), start, end, logger)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read capture files from %s", conf.CaptureDir)
+	}
+	replay, err := data.NewCaptureReplay(readings, conf.replayConfig(), clk)
+	if err != nil {
+		return nil, err
+	}
+
+	ms := &localReplayMovementSensor{
+		Named:  name.AsNamed(),
+		logger: logger,
+		replay: replay,
+	}
+	for _, m := range methodList {
+		if err := ms.setProperty(m, replay.HasMethod(string(m))); err != nil {
+			return nil, err
+		}
+	}
+	if ms.properties == (movementsensor.Properties{}) {
+		return nil, errors.Errorf("%s for %q in %s", errMessageNoDataAvailable, conf.Source, conf.CaptureDir)
+	}
+	return ms, nil
+}
+
+func (ms *localReplayMovementSensor) setProperty(m method, supported bool) error {
+	switch m {
+	case position:
+		ms.properties.PositionSupported = supported
+	case linearVelocity:
+		ms.properties.LinearVelocitySupported = supported
+	case angularVelocity:
+		ms.properties.AngularVelocitySupported = supported
+	case linearAcceleration:
+		ms.properties.LinearAccelerationSupported = supported
+	case compassHeading:
+		ms.properties.CompassHeadingSupported = supported
+	case orientation:
+		ms.properties.OrientationSupported = supported
+	default:
+		return errors.New("can't set property, invalid method: " + string(m))
+	}
+	return nil
+}
+
+// next returns the next captured reading for the method and attaches its timestamps to the
+// response. unimplemented is returned when no data was captured for the method.
+func (ms *localReplayMovementSensor) next(
+	ctx context.Context, m method, supported bool, unimplemented error,
+) (*structpb.Struct, error) {
+	ms.mu.Lock()
+	closed := ms.closed
+	ms.mu.Unlock()
+	if closed {
+		return nil, errSessionClosed
+	}
+	if !supported {
+		return nil, unimplemented
+	}
+
+	reading, err := ms.replay.Next(string(m))
+	if err != nil {
+		if errors.Is(err, data.ErrEndOfCaptures) {
+			return nil, ErrEndOfDataset
+		}
+		return nil, err
+	}
+	if err := addGRPCMetadata(ctx,
+		timestamppb.New(reading.TimeRequested), timestamppb.New(reading.TimeReceived)); err != nil {
+		return nil, errors.Wrapf(err, "adding GRPC metadata failed")
+	}
+	sd, err := reading.SensorData()
+	if err != nil {
+		return nil, err
+	}
+	return sd.GetStruct(), nil
+}
+
+// Position returns the captured position.
+func (ms *localReplayMovementSensor) Position(ctx context.Context, extra map[string]interface{}) (*geo.Point, float64, error) {
+	data, err := ms.next(ctx, position, ms.properties.PositionSupported,
+		movementsensor.ErrMethodUnimplementedPosition)
+	if err != nil {
+		return nil, 0, err
+	}
+	return positionFromStruct(data)
+}
+
+// LinearVelocity returns the captured linear velocity.
+func (ms *localReplayMovementSensor) LinearVelocity(ctx context.Context, extra map[string]interface{}) (r3.Vector, error) {
+	data, err := ms.next(ctx, linearVelocity, ms.properties.LinearVelocitySupported,
+		movementsensor.ErrMethodUnimplementedLinearVelocity)
+	if err != nil {
+		return r3.Vector{}, err
+	}
+	return vectorFromStruct(data, "linear_velocity")
+}
+
+// AngularVelocity returns the captured angular velocity.
+func (ms *localReplayMovementSensor) AngularVelocity(ctx context.Context, extra map[string]interface{}) (
+	spatialmath.AngularVelocity, error,
+) {
+	data, err := ms.next(ctx, angularVelocity, ms.properties.AngularVelocitySupported,
+		movementsensor.ErrMethodUnimplementedAngularVelocity)
+	if err != nil {
+		return spatialmath.AngularVelocity{}, err
+	}
+	vec, err := vectorFromStruct(data, "angular_velocity")
+	return spatialmath.AngularVelocity(vec), err
+}
+
+// LinearAcceleration returns the captured linear acceleration.
+func (ms *localReplayMovementSensor) LinearAcceleration(ctx context.Context, extra map[string]interface{}) (r3.Vector, error) {
+	data, err := ms.next(ctx, linearAcceleration, ms.properties.LinearAccelerationSupported,
+		movementsensor.ErrMethodUnimplementedLinearAcceleration)
+	if err != nil {
+		return r3.Vector{}, err
+	}
+	return vectorFromStruct(data, "linear_acceleration")
+}
+
+// CompassHeading returns the captured compass heading.
+func (ms *localReplayMovementSensor) CompassHeading(ctx context.Context, extra map[string]interface{}) (float64, error) {
+	data, err := ms.next(ctx, compassHeading, ms.properties.CompassHeadingSupported,
+		movementsensor.ErrMethodUnimplementedCompassHeading)
+	if err != nil {
+		return 0, err
+	}
+	return compassHeadingFromStruct(data)
+}
+
+// Orientation returns the captured orientation.
+func (ms *localReplayMovementSensor) Orientation(ctx context.Context, extra map[string]interface{}) (spatialmath.Orientation, error) {
+	data, err := ms.next(ctx, orientation, ms.properties.OrientationSupported,
+		movementsensor.ErrMethodUnimplementedOrientation)
+	if err != nil {
+		return nil, err
+	}
+	return orientationFromStruct(data)
+}
+
+// Properties returns the methods which have captured data.
+func (ms *localReplayMovementSensor) Properties(ctx context.Context, extra map[string]interface{}) (*movementsensor.Properties, error) {
+	props := ms.properties
+	return &props, nil
+}
+
+// Accuracy is currently not defined for replay movement sensors.
+func (ms *localReplayMovementSensor) Accuracy(ctx context.Context, extra map[string]interface{}) (*movementsensor.Accuracy, error) {
+	return movementsensor.UnimplementedOptionalAccuracies(), nil
+}
+
+// Readings returns the next reading of every method with captured data.
+func (ms *localReplayMovementSensor) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
+	return movementsensor.DefaultAPIReadings(ctx, ms, extra)
+}
+
+// DoCommand accepts {"restart": true} to rewind playback to the beginning of the recording.
+func (ms *localReplayMovementSensor) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	if restart, ok := cmd["restart"].(bool); ok && restart {
+		ms.replay.Restart()
+		return map[string]interface{}{"restart": true}, nil
+	}
+	return nil, resource.ErrDoUnimplemented
+}
+
+// Close stops the local replay movement sensor.
+func (ms *localReplayMovementSensor) Close(ctx context.Context) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.closed = true
+	ms.replay.Close()
+	return nil
+}
@@ -0,0 +1,192 @@
+package replay
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	v1 "go.viam.com/api/app/datasync/v1"
+	commonpb "go.viam.com/api/common/v1"
+	pb "go.viam.com/api/component/movementsensor/v1"
+	"go.viam.com/test"
+	"go.viam.com/utils/protoutils"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"go.viam.com/rdk/components/movementsensor"
+	"go.viam.com/rdk/data"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+)
+
+var localTestStart = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// writeLocalCapture writes readings the way the movement sensor collectors do, one per second.
+func writeLocalCapture(t *testing.T, dir, componentName string, m method, readings ...interface{}) {
+	t.Helper()
+	md := data.BuildCaptureMetadata(movementsensor.API, componentName, string(m), nil, nil, nil)
+	f, err := data.NewCaptureFile(dir, md)
+	test.That(t, err, test.ShouldBeNil)
+	for i, reading := range readings {
+		pbReading, err := protoutils.StructToStructPbIgnoreOmitEmpty(reading)
+		test.That(t, err, test.ShouldBeNil)
+		requested := localTestStart.Add(time.Duration(i) * time.Second)
+		test.That(t, f.WriteNext(&v1.SensorData{
+			Metadata: &v1.SensorMetadata{
+				TimeRequested: timestamppb.New(requested),
+				TimeReceived:  timestamppb.New(requested.Add(time.Millisecond)),
+			},
+			Data: &v1.SensorData_Struct{Struct: pbReading},
+		}), test.ShouldBeNil)
+	}
+	test.That(t, f.Close(), test.ShouldBeNil)
+}
+
+func TestLocalReplayValidate(t *testing.T) {
+	path := "path"
+	validConfig := LocalConfig{Source: "gps", CaptureDir: "/tmp/capture"}
+
+	cfg := validConfig
+	deps, err := cfg.Validate(path)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldBeEmpty)
+
+	cfg = validConfig
+	cfg.Source = ""
+	_, err = cfg.Validate(path)
+	test.That(t, err, test.ShouldBeError, resource.NewConfigValidationFieldRequiredError(path, "source"))
+
+	cfg = validConfig
+	cfg.CaptureDir = ""
+	_, err = cfg.Validate(path)
+	test.That(t, err, test.ShouldBeError, resource.NewConfigValidationFieldRequiredError(path, "capture_dir"))
+
+	cfg = validConfig
+	cfg.Interval = TimeInterval{Start: "2024-01-01T13:00:00Z", End: "2024-01-01T12:00:00Z"}
+	_, err = cfg.Validate(path)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	cfg = validConfig
+	cfg.PlaybackMode = string(data.ReplayModeAccelerated)
+	_, err = cfg.Validate(path)
+	test.That(t, err, test.ShouldNotBeNil)
+	cfg.PlaybackSpeed = 5
+	_, err = cfg.Validate(path)
+	test.That(t, err, test.ShouldBeNil)
+}
+
+func TestLocalReplayMovementSensor(t *testing.T) {
+	ctx := context.Background()
+	logger := logging.NewTestLogger(t)
+	dir := t.TempDir()
+
+	writeLocalCapture(t, dir, "gps", position,
+		pb.GetPositionResponse{Coordinate: &commonpb.GeoPoint{Latitude: 40.1, Longitude: -73.1}, AltitudeM: 10},
+		pb.GetPositionResponse{Coordinate: &commonpb.GeoPoint{Latitude: 40.2, Longitude: -73.2}, AltitudeM: 20},
+		pb.GetPositionResponse{Coordinate: &commonpb.GeoPoint{Latitude: 40.3, Longitude: -73.3}, AltitudeM: 30},
+	)
+	writeLocalCapture(t, dir, "gps", compassHeading,
+		pb.GetCompassHeadingResponse{Value: 90},
+		pb.GetCompassHeadingResponse{Value: 180},
+		pb.GetCompassHeadingResponse{Value: 270},
+	)
+	writeLocalCapture(t, dir, "gps", linearVelocity,
+		pb.GetLinearVelocityResponse{LinearVelocity: &commonpb.Vector3{X: 1, Y: 2, Z: 3}},
+	)
+	writeLocalCapture(t, dir, "imu", orientation,
+		pb.GetOrientationResponse{Orientation: &commonpb.Orientation{OZ: 1, Theta: 45}},
+	)
+
+	t.Run("no data", func(t *testing.T) {
+		_, err := makeLocalReplayMovementSensor(movementsensor.Named("replay"),
+			&LocalConfig{Source: "missing", CaptureDir: dir}, clock.NewMock(), logger)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, errMessageNoDataAvailable)
+	})
+
+	t.Run("stepped", func(t *testing.T) {
+		ms, err := makeLocalReplayMovementSensor(movementsensor.Named("replay"),
+			&LocalConfig{Source: "gps", CaptureDir: dir}, clock.NewMock(), logger)
+		test.That(t, err, test.ShouldBeNil)
+
+		props, err := ms.Properties(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, props, test.ShouldResemble, &movementsensor.Properties{
+			PositionSupported:       true,
+			CompassHeadingSupported: true,
+			LinearVelocitySupported: true,
+		})
+
+		for i := 1; i <= 3; i++ {
+			pt, alt, err := ms.Position(ctx, nil)
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, pt.Lat(), test.ShouldAlmostEqual, 40+0.1*float64(i))
+			test.That(t, pt.Lng(), test.ShouldAlmostEqual, -73-0.1*float64(i))
+			test.That(t, alt, test.ShouldAlmostEqual, 10*float64(i))
+		}
+		_, _, err = ms.Position(ctx, nil)
+		test.That(t, err, test.ShouldBeError, ErrEndOfDataset)
+
+		heading, err := ms.CompassHeading(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, heading, test.ShouldEqual, 90)
+
+		vel, err := ms.LinearVelocity(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, vel.Y, test.ShouldEqual, 2)
+
+		_, err = ms.Orientation(ctx, nil)
+		test.That(t, err, test.ShouldBeError, movementsensor.ErrMethodUnimplementedOrientation)
+
+		resp, err := ms.DoCommand(ctx, map[string]interface{}{"restart": true})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, resp["restart"], test.ShouldBeTrue)
+		pt, _, err := ms.Position(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, pt.Lat(), test.ShouldAlmostEqual, 40.1)
+
+		test.That(t, ms.Close(ctx), test.ShouldBeNil)
+		_, _, err = ms.Position(ctx, nil)
+		test.That(t, err, test.ShouldBeError, errSessionClosed)
+	})
+
+	t.Run("accelerated with interval and loop", func(t *testing.T) {
+		clk := clock.NewMock()
+		ms, err := makeLocalReplayMovementSensor(movementsensor.Named("replay"), &LocalConfig{
+			Source:     "gps",
+			CaptureDir: dir,
+			Interval: TimeInterval{
+				Start: localTestStart.Add(time.Second).Format(timeFormat),
+			},
+			PlaybackMode:  string(data.ReplayModeAccelerated),
+			PlaybackSpeed: 2,
+			Loop:          true,
+		}, clk, logger)
+		test.That(t, err, test.ShouldBeNil)
+
+		// the linear velocity reading is outside of the interval.
+		props, err := ms.Properties(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, props.LinearVelocitySupported, test.ShouldBeFalse)
+
+		heading, err := ms.CompassHeading(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, heading, test.ShouldEqual, 180)
+
+		clk.Add(500 * time.Millisecond)
+		heading, err = ms.CompassHeading(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, heading, test.ShouldEqual, 270)
+
+		readings, err := ms.Readings(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, readings["compass"], test.ShouldEqual, 270)
+		test.That(t, readings["altitude"], test.ShouldEqual, 30)
+
+		// past the end of the recording playback starts over.
+		clk.Add(time.Second)
+		heading, err = ms.CompassHeading(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, heading, test.ShouldEqual, 180)
+	})
+}
@@ -216,19 +216,7 @@ func (replay *replayMovementSensor) Position(ctx context.Context, extra map[stri
 	if err != nil {
 		return nil, 0, err
 	}
-
-	coordStruct, ok := data.GetFields()["coordinate"]
-	if !ok {
-		return nil, 0, errBadData
-	}
-	altitude, ok := data.GetFields()["altitude_m"]
-	if !ok {
-		return nil, 0, errBadData
-	}
-	return geo.NewPoint(
-			coordStruct.GetStructValue().GetFields()["latitude"].GetNumberValue(),
-			coordStruct.GetStructValue().GetFields()["longitude"].GetNumberValue()),
-		altitude.GetNumberValue(), nil
+	return positionFromStruct(data)
 }
 
 // LinearVelocity returns the next linear velocity from the cache in the form of an r3.Vector.
@@ -247,12 +235,7 @@ func (replay *replayMovementSensor) LinearVelocity(ctx context.Context, extra ma
 	if err != nil {
 		return r3.Vector{}, err
 	}
-	data, ok := dataStruct.GetFields()["linear_velocity"]
-	if !ok {
-		return r3.Vector{}, errBadData
-	}
-
-	return structToVector(data.GetStructValue()), nil
+	return vectorFromStruct(dataStruct, "linear_velocity")
 }
 
 // AngularVelocity returns the next angular velocity from the cache in the form of a spatialmath.AngularVelocity (r3.Vector).
@@ -273,16 +256,8 @@ func (replay *replayMovementSensor) AngularVelocity(ctx context.Context, extra m
 	if err != nil {
 		return spatialmath.AngularVelocity{}, err
 	}
-	data, ok := dataStruct.GetFields()["angular_velocity"]
-	if !ok {
-		return spatialmath.AngularVelocity{}, errBadData
-	}
-
-	return spatialmath.AngularVelocity{
-		X: data.GetStructValue().GetFields()["x"].GetNumberValue(),
-		Y: data.GetStructValue().GetFields()["y"].GetNumberValue(),
-		Z: data.GetStructValue().GetFields()["z"].GetNumberValue(),
-	}, nil
+	vec, err := vectorFromStruct(dataStruct, "angular_velocity")
+	return spatialmath.AngularVelocity(vec), err
 }
 
 // LinearAcceleration returns the next linear acceleration from the cache in the form of an r3.Vector.
@@ -301,12 +276,7 @@ func (replay *replayMovementSensor) LinearAcceleration(ctx context.Context, extr
 	if err != nil {
 		return r3.Vector{}, err
 	}
-	data, ok := dataStruct.GetFields()["linear_acceleration"]
-	if !ok {
-		return r3.Vector{}, errBadData
-	}
-
-	return structToVector(data.GetStructValue()), nil
+	return vectorFromStruct(dataStruct, "linear_acceleration")
 }
 
 // CompassHeading returns the next compass heading from the cache as a float64.
@@ -325,12 +295,7 @@ func (replay *replayMovementSensor) CompassHeading(ctx context.Context, extra ma
 	if err != nil {
 		return 0., err
 	}
-	value, ok := data.GetFields()["value"]
-	if !ok {
-		return 0, errBadData
-	}
-
-	return value.GetNumberValue(), nil
+	return compassHeadingFromStruct(data)
 }
 
 // Orientation returns the next orientation from the cache as a spatialmath.Orientation created from a spatialmath.OrientationVector.
@@ -349,17 +314,7 @@ func (replay *replayMovementSensor) Orientation(ctx context.Context, extra map[s
 	if err != nil {
 		return nil, err
 	}
-	data, ok := dataStruct.GetFields()["orientation"]
-	if !ok {
-		return nil, errBadData
-	}
-
-	return &spatialmath.OrientationVectorDegrees{
-		OX:    data.GetStructValue().GetFields()["o_x"].GetNumberValue(),
-		OY:    data.GetStructValue().GetFields()["o_y"].GetNumberValue(),
-		OZ:    data.GetStructValue().GetFields()["o_z"].GetNumberValue(),
-		Theta: data.GetStructValue().GetFields()["theta"].GetNumberValue(),
-	}, nil
+	return orientationFromStruct(dataStruct)
 }
 
 // Properties returns the available properties for the given replay movement sensor.
@@ -661,3 +616,50 @@ func structToVector(data *structpb.Struct) r3.Vector {
 		Z: data.GetFields()["z"].GetNumberValue(),
 	}
 }
+
+// The functions below convert the structs captured by the movement sensor collectors back into
+// the values returned by the movement sensor API.
+
+func positionFromStruct(data *structpb.Struct) (*geo.Point, float64, error) {
+	coordStruct, ok := data.GetFields()["coordinate"]
+	if !ok {
+		return nil, 0, errBadData
+	}
+	altitude, ok := data.GetFields()["altitude_m"]
+	if !ok {
+		return nil, 0, errBadData
+	}
+	return geo.NewPoint(
+			coordStruct.GetStructValue().GetFields()["latitude"].GetNumberValue(),
+			coordStruct.GetStructValue().GetFields()["longitude"].GetNumberValue()),
+		altitude.GetNumberValue(), nil
+}
+
+func vectorFromStruct(data *structpb.Struct, field string) (r3.Vector, error) {
+	value, ok := data.GetFields()[field]
+	if !ok {
+		return r3.Vector{}, errBadData
+	}
+	return structToVector(value.GetStructValue()), nil
+}
+
+func compassHeadingFromStruct(data *structpb.Struct) (float64, error) {
+	value, ok := data.GetFields()["value"]
+	if !ok {
+		return 0, errBadData
+	}
+	return value.GetNumberValue(), nil
+}
+
+func orientationFromStruct(data *structpb.Struct) (spatialmath.Orientation, error) {
+	value, ok := data.GetFields()["orientation"]
+	if !ok {
+		return nil, errBadData
+	}
+	return &spatialmath.OrientationVectorDegrees{
+		OX:    value.GetStructValue().GetFields()["o_x"].GetNumberValue(),
+		OY:    value.GetStructValue().GetFields()["o_y"].GetNumberValue(),
+		OZ:    value.GetStructValue().GetFields()["o_z"].GetNumberValue(),
+		Theta: value.GetStructValue().GetFields()["theta"].GetNumberValue(),
+	}, nil
+}
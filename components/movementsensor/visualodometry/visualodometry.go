@@ -0,0 +1,390 @@
+// Package visualodometry implements an odometry estimate from the features a camera on a base tracks across
+// its images, for bases without wheel encoders.
+package visualodometry
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/golang/geo/r3"
+	geo "github.com/kellydunn/golang-geo"
+	"github.com/pkg/errors"
+	goutils "go.viam.com/utils"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/components/movementsensor"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/rimage/transform"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/utils"
+)
+
+// Model is the name of the visual odometry model of a movementsensor component.
+var Model = resource.DefaultModelFamily.WithModel("visual-odometry")
+
+const (
+	// ModeMono tracks the color images of the camera, scaling how far it moved by the speed of the base.
+	ModeMono = "mono"
+	// ModeRGBD tracks the color images of the camera with their depths, which give how far it moved.
+	ModeRGBD = "rgbd"
+
+	defaultTimeIntervalMSecs = 200
+	mmToM                    = 1e-3
+	mToKm                    = 1e-3
+	returnRelative           = "return_relative_pos_m"
+	resetCmd                 = "reset"
+)
+
+// cameraToBase is the pose of the camera on the base, facing forward and level, taking its x right, y down and
+// z forward to the x right, y forward and z up of the base.
+var cameraToBase = spatialmath.NewPoseFromOrientation(&spatialmath.R4AA{Theta: -math.Pi / 2, RX: 1})
+
+// Config is the config for a visual odometry MovementSensor. The camera must face forward along the base and
+// be level.
+type Config struct {
+	Camera string `json:"camera"`
+	Mode   string `json:"mode,omitempty"`
+	// SpeedSensor is a movement sensor whose linear velocity gives how far the base moved in mono mode, since
+	// a single camera only sees which way it moved.
+	SpeedSensor       string                             `json:"speed_sensor,omitempty"`
+	CameraParameters  *transform.PinholeCameraIntrinsics `json:"intrinsic_parameters,omitempty"`
+	TimeIntervalMSecs float64                            `json:"time_interval_msecs,omitempty"`
+}
+
+// Validate ensures all parts of the config are valid.
+func (cfg *Config) Validate(path string) ([]string, error) {
+	if cfg.Camera == "" {
+		return nil, resource.NewConfigValidationFieldRequiredError(path, "camera")
+	}
+	deps := []string{cfg.Camera}
+	switch cfg.Mode {
+	case "", ModeMono:
+		if cfg.SpeedSensor == "" {
+			return nil, resource.NewConfigValidationFieldRequiredError(path, "speed_sensor")
+		}
+		deps = append(deps, cfg.SpeedSensor)
+	case ModeRGBD:
+	default:
+		return nil, resource.NewConfigValidationError(path, errors.Errorf(
+			"mode must be %q or %q, got %q", ModeMono, ModeRGBD, cfg.Mode))
+	}
+	if cfg.CameraParameters != nil {
+		if err := cfg.CameraParameters.CheckValid(); err != nil {
+			return nil, resource.NewConfigValidationError(path, err)
+		}
+	}
+	if cfg.TimeIntervalMSecs < 0 {
+		return nil, resource.NewConfigValidationError(path, errors.New("time_interval_msecs cannot be negative"))
+	}
+	return deps, nil
+}
+
+type visualOdometry struct {
+	resource.Named
+	resource.AlwaysRebuild
+
+	cam          camera.Camera
+	speedSensor  movementsensor.MovementSensor
+	mode         string
+	timeInterval time.Duration
+
+	// trackMu is held while a frame is tracked, which is too slow to hold mu for.
+	trackMu   sync.Mutex
+	tracker   *transform.VisualOdometry
+	lastFrame time.Time
+
+	mu sync.Mutex
+	// pose is of the base in the frame of the base where it started, in mm.
+	pose            spatialmath.Pose
+	linearVelocity  r3.Vector
+	angularVelocity spatialmath.AngularVelocity
+	originCoord     *geo.Point
+
+	workers *goutils.StoppableWorkers
+	logger  logging.Logger
+}
+
+func init() {
+	resource.RegisterComponent(
+		movementsensor.API,
+		Model,
+		resource.Registration[movementsensor.MovementSensor, *Config]{Constructor: newVisualOdometry})
+}
+
+// newVisualOdometry returns a new visual odometry movement sensor defined by the given config.
+func newVisualOdometry(
+	ctx context.Context,
+	deps resource.Dependencies,
+	conf resource.Config,
+	logger logging.Logger,
+) (movementsensor.MovementSensor, error) {
+	vo, err := newVisualOdometryFromConfig(ctx, deps, conf, logger)
+	if err != nil {
+		return nil, err
+	}
+	vo.trackPosition()
+	return vo, nil
+}
+
+// newVisualOdometryFromConfig returns a visual odometry movement sensor which does not track the camera yet.
+func newVisualOdometryFromConfig(
+	ctx context.Context,
+	deps resource.Dependencies,
+	conf resource.Config,
+	logger logging.Logger,
+) (*visualOdometry, error) {
+	newConf, err := resource.NativeConfig[*Config](conf)
+	if err != nil {
+		return nil, err
+	}
+	vo := &visualOdometry{
+		Named:        conf.ResourceName().AsNamed(),
+		mode:         newConf.Mode,
+		timeInterval: time.Duration(newConf.TimeIntervalMSecs * float64(time.Millisecond)),
+		pose:         spatialmath.NewZeroPose(),
+		originCoord:  geo.NewPoint(0, 0),
+		logger:       logger,
+	}
+	if vo.mode == "" {
+		vo.mode = ModeMono
+	}
+	if vo.timeInterval == 0 {
+		vo.timeInterval = defaultTimeIntervalMSecs * time.Millisecond
+	}
+	if vo.cam, err = camera.FromDependencies(deps, newConf.Camera); err != nil {
+		return nil, err
+	}
+	if vo.mode == ModeMono {
+		if vo.speedSensor, err = movementsensor.FromDependencies(deps, newConf.SpeedSensor); err != nil {
+			return nil, err
+		}
+	}
+
+	intrinsics := newConf.CameraParameters
+	if intrinsics == nil {
+		props, err := vo.cam.Properties(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot get the intrinsics of camera %q", newConf.Camera)
+		}
+		intrinsics = props.IntrinsicParams
+	}
+	if intrinsics == nil {
+		return nil, errors.Errorf("camera %q has no intrinsic parameters, set intrinsic_parameters in the config", newConf.Camera)
+	}
+	if vo.tracker, err = transform.NewVisualOdometry(intrinsics, logger); err != nil {
+		return nil, err
+	}
+	return vo, nil
+}
+
+// trackPosition tracks the camera in the background, updating the position, orientation, linear velocity and
+// angular velocity of the base from each of its frames.
+func (vo *visualOdometry) trackPosition() {
+	vo.workers = goutils.NewBackgroundStoppableWorkers(func(ctx context.Context) {
+		ticker := time.NewTicker(vo.timeInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if err := vo.update(ctx, now); err != nil && !errors.Is(err, context.Canceled) {
+					vo.logger.CError(ctx, err)
+				}
+			}
+		}
+	})
+}
+
+// update tracks the next frame of the camera, taken at about now, and moves the base by how far the camera
+// moved since the last frame.
+func (vo *visualOdometry) update(ctx context.Context, now time.Time) error {
+	vo.trackMu.Lock()
+	defer vo.trackMu.Unlock()
+
+	img, depth, err := vo.readFrame(ctx)
+	if err != nil {
+		return err
+	}
+	var speedMMPerSec float64
+	if vo.speedSensor != nil {
+		vel, err := vo.speedSensor.LinearVelocity(ctx, nil)
+		if err != nil {
+			return errors.Wrapf(err, "cannot get the speed of the base from %q", vo.speedSensor.Name().ShortName())
+		}
+		speedMMPerSec = vel.Norm() / mmToM
+	}
+	motion, err := vo.tracker.Track(img, depth)
+	if errors.Is(err, transform.ErrOdometryLost) {
+		// the base is taken to not have moved while the camera was lost, and is tracked again from this frame.
+		vo.logger.CWarnw(ctx, "visual odometry lost track of the camera", "error", err)
+		motion = spatialmath.NewZeroPose()
+	} else if err != nil {
+		return err
+	}
+	var dt float64
+	if !vo.lastFrame.IsZero() {
+		dt = now.Sub(vo.lastFrame).Seconds()
+	}
+	vo.lastFrame = now
+	if vo.mode == ModeMono {
+		// the camera only sees which way it moved, so it is scaled by how far the base moved.
+		motion = spatialmath.NewPose(motion.Point().Mul(speedMMPerSec*dt), motion.Orientation())
+	}
+	motion = spatialmath.Compose(spatialmath.Compose(cameraToBase, motion), spatialmath.PoseInverse(cameraToBase))
+
+	vo.mu.Lock()
+	defer vo.mu.Unlock()
+	vo.pose = spatialmath.Compose(vo.pose, motion)
+	if dt > 0 {
+		vo.linearVelocity = motion.Point().Mul(mmToM / dt)
+		angVel := spatialmath.OrientationToAngularVel(motion.Orientation(), dt)
+		vo.angularVelocity = spatialmath.AngularVelocity{
+			X: utils.RadToDeg(angVel.X),
+			Y: utils.RadToDeg(angVel.Y),
+			Z: utils.RadToDeg(angVel.Z),
+		}
+	}
+	return nil
+}
+
+// readFrame returns the color image of the camera, and its depth in rgbd mode.
+func (vo *visualOdometry) readFrame(ctx context.Context) (image.Image, *rimage.DepthMap, error) {
+	imgs, _, err := vo.cam.Images(ctx)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "cannot get images from %q", vo.cam.Name().ShortName())
+	}
+	var color image.Image
+	var depth *rimage.DepthMap
+	for _, named := range imgs {
+		if isDepth(named.Image) {
+			if depth == nil && vo.mode == ModeRGBD {
+				if depth, err = rimage.ConvertImageToDepthMap(ctx, named.Image); err != nil {
+					return nil, nil, err
+				}
+			}
+		} else if color == nil {
+			color = named.Image
+		}
+	}
+	if color == nil {
+		return nil, nil, errors.Errorf("camera %q returned no color image", vo.cam.Name().ShortName())
+	}
+	if depth == nil && vo.mode == ModeRGBD {
+		return nil, nil, errors.Errorf("camera %q returned no depth image", vo.cam.Name().ShortName())
+	}
+	return color, depth, nil
+}
+
+func isDepth(img image.Image) bool {
+	switch img := img.(type) {
+	case *rimage.DepthMap, *image.Gray16:
+		return true
+	case *rimage.LazyEncodedImage:
+		return img.MIMEType() == utils.MimeTypeRawDepth
+	default:
+		return false
+	}
+}
+
+func (vo *visualOdometry) Position(ctx context.Context, extra map[string]interface{}) (*geo.Point, float64, error) {
+	vo.mu.Lock()
+	defer vo.mu.Unlock()
+	pos := vo.pose.Point().Mul(mmToM)
+
+	if relative, ok := extra[returnRelative]; ok {
+		if relative.(bool) {
+			return geo.NewPoint(pos.Y, pos.X), pos.Z, nil
+		}
+	}
+
+	distance := math.Hypot(pos.X, pos.Y)
+	heading := utils.RadToDeg(math.Atan2(pos.X, pos.Y))
+	return vo.originCoord.PointAtDistanceAndBearing(distance*mToKm, heading), pos.Z, nil
+}
+
+func (vo *visualOdometry) Orientation(ctx context.Context, extra map[string]interface{}) (spatialmath.Orientation, error) {
+	vo.mu.Lock()
+	defer vo.mu.Unlock()
+	return vo.pose.Orientation(), nil
+}
+
+func (vo *visualOdometry) LinearVelocity(ctx context.Context, extra map[string]interface{}) (r3.Vector, error) {
+	vo.mu.Lock()
+	defer vo.mu.Unlock()
+	return vo.linearVelocity, nil
+}
+
+func (vo *visualOdometry) AngularVelocity(ctx context.Context, extra map[string]interface{}) (spatialmath.AngularVelocity, error) {
+	vo.mu.Lock()
+	defer vo.mu.Unlock()
+	return vo.angularVelocity, nil
+}
+
+func (vo *visualOdometry) LinearAcceleration(ctx context.Context, extra map[string]interface{}) (r3.Vector, error) {
+	return r3.Vector{}, movementsensor.ErrMethodUnimplementedLinearAcceleration
+}
+
+func (vo *visualOdometry) CompassHeading(ctx context.Context, extra map[string]interface{}) (float64, error) {
+	return 0, movementsensor.ErrMethodUnimplementedCompassHeading
+}
+
+func (vo *visualOdometry) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
+	readings, err := movementsensor.DefaultAPIReadings(ctx, vo, extra)
+	if err != nil {
+		return nil, err
+	}
+
+	vo.mu.Lock()
+	defer vo.mu.Unlock()
+	pos := vo.pose.Point().Mul(mmToM)
+	readings["position_meters_X"] = pos.X
+	readings["position_meters_Y"] = pos.Y
+
+	return readings, nil
+}
+
+func (vo *visualOdometry) Accuracy(ctx context.Context, extra map[string]interface{}) (*movementsensor.Accuracy, error) {
+	return movementsensor.UnimplementedOptionalAccuracies(), nil
+}
+
+func (vo *visualOdometry) Properties(ctx context.Context, extra map[string]interface{}) (*movementsensor.Properties, error) {
+	return &movementsensor.Properties{
+		LinearVelocitySupported:  true,
+		AngularVelocitySupported: true,
+		OrientationSupported:     true,
+		PositionSupported:        true,
+	}, nil
+}
+
+// DoCommand resets the position and orientation of the base to where it is with {"reset": true}.
+func (vo *visualOdometry) DoCommand(ctx context.Context, req map[string]interface{}) (map[string]interface{}, error) {
+	resp := make(map[string]interface{})
+	if reset, ok := req[resetCmd].(bool); ok && reset {
+		vo.trackMu.Lock()
+		vo.tracker.Reset()
+		vo.lastFrame = time.Time{}
+		vo.trackMu.Unlock()
+
+		vo.mu.Lock()
+		vo.pose = spatialmath.NewZeroPose()
+		vo.linearVelocity = r3.Vector{}
+		vo.angularVelocity = spatialmath.AngularVelocity{}
+		vo.mu.Unlock()
+		resp[resetCmd] = fmt.Sprintf("resetting position to where %q is", vo.Name().ShortName())
+	}
+	return resp, nil
+}
+
+func (vo *visualOdometry) Close(ctx context.Context) error {
+	if vo.workers != nil {
+		vo.workers.Stop()
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package visualodometry
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/golang/geo/r3"
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/components/movementsensor"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/rimage/transform"
+	"go.viam.com/rdk/testutils/inject"
+)
+
+const (
+	cameraName      = "camera"
+	speedSensorName = "speed"
+	stepMM          = 50.
+	stepTurn        = 2 * math.Pi / 180
+	interval        = 200 * time.Millisecond
+)
+
+var testIntrinsics = &transform.PinholeCameraIntrinsics{Width: 320, Height: 240, Fx: 200, Fy: 200, Ppx: 160, Ppy: 120}
+
+// renderRoom returns what a forward facing camera on a base at pos, turned right by yaw radians, sees in a
+// room of walls of random gray blocks, and the depth of it. pos is x right and z forward of the camera.
+func renderRoom(pos r3.Vector, yaw float64) (*image.Gray, *rimage.DepthMap) {
+	const block = 150.
+	room := r3.Vector{X: 1000, Y: 600, Z: 3000}
+	shade := func(i, j, wall int) uint8 {
+		h := uint32(i*73856093) ^ uint32(j*19349663) ^ uint32(wall*83492791)
+		h ^= h >> 13
+		h *= 0x5bd1e995
+		h ^= h >> 15
+		return uint8(h)
+	}
+	sin, cos := math.Sin(yaw), math.Cos(yaw)
+	img := image.NewGray(image.Rect(0, 0, testIntrinsics.Width, testIntrinsics.Height))
+	depth := rimage.NewEmptyDepthMap(testIntrinsics.Width, testIntrinsics.Height)
+	for y := 0; y < testIntrinsics.Height; y++ {
+		for x := 0; x < testIntrinsics.Width; x++ {
+			dc := r3.Vector{X: (float64(x) - testIntrinsics.Ppx) / testIntrinsics.Fx, Y: (float64(y) - testIntrinsics.Ppy) / testIntrinsics.Fy, Z: 1}
+			d := r3.Vector{X: cos*dc.X + sin*dc.Z, Y: dc.Y, Z: -sin*dc.X + cos*dc.Z}
+			dist, wall := math.Inf(1), 0
+			for i, hit := range []float64{
+				(math.Copysign(room.X, d.X) - pos.X) / d.X,
+				(math.Copysign(room.Y, d.Y) - pos.Y) / d.Y,
+				(room.Z - pos.Z) / d.Z,
+			} {
+				if hit > 0 && hit < dist {
+					dist, wall = hit, i
+				}
+			}
+			p := pos.Add(d.Mul(dist))
+			u, v := [3]float64{p.Y, p.X, p.X}[wall], [3]float64{p.Z, p.Z, p.Y}[wall]
+			img.SetGray(x, y, color.Gray{shade(int(math.Floor(u/block)), int(math.Floor(v/block)), wall)})
+			depth.Set(x, y, rimage.Depth(dist))
+		}
+	}
+	return img, depth
+}
+
+// setupDependencies returns a camera on a base which moves forward and turns right on each of its frames,
+// and a movement sensor giving the speed of the base.
+func setupDependencies(t *testing.T, withIntrinsics bool) resource.Dependencies {
+	t.Helper()
+	var imgs []camera.NamedImage
+	pos, yaw := r3.Vector{}, 0.
+	frames := make([][]camera.NamedImage, 0, 5)
+	for i := 0; i < 5; i++ {
+		img, depth := renderRoom(pos, yaw)
+		frames = append(frames, []camera.NamedImage{{Image: img, SourceName: "color"}, {Image: depth, SourceName: "depth"}})
+		yaw += stepTurn
+		pos = pos.Add(r3.Vector{X: math.Sin(yaw) * stepMM, Z: math.Cos(yaw) * stepMM})
+	}
+	cam := inject.NewCamera(cameraName)
+	cam.ImagesFunc = func(ctx context.Context) ([]camera.NamedImage, resource.ResponseMetadata, error) {
+		if len(frames) > 0 {
+			imgs, frames = frames[0], frames[1:]
+		}
+		return imgs, resource.ResponseMetadata{}, nil
+	}
+	cam.PropertiesFunc = func(ctx context.Context) (camera.Properties, error) {
+		if !withIntrinsics {
+			return camera.Properties{}, nil
+		}
+		return camera.Properties{IntrinsicParams: testIntrinsics}, nil
+	}
+	speed := inject.NewMovementSensor(speedSensorName)
+	speed.LinearVelocityFunc = func(ctx context.Context, extra map[string]interface{}) (r3.Vector, error) {
+		return r3.Vector{Y: stepMM * mmToM / interval.Seconds()}, nil
+	}
+	return resource.Dependencies{camera.Named(cameraName): cam, movementsensor.Named(speedSensorName): speed}
+}
+
+func TestVisualOdometry(t *testing.T) {
+	ctx := context.Background()
+	logger := logging.NewTestLogger(t)
+	for _, mode := range []string{ModeRGBD, ModeMono} {
+		t.Run(mode, func(t *testing.T) {
+			conf := resource.Config{
+				Name:                "vo",
+				ConvertedAttributes: &Config{Camera: cameraName, Mode: mode, SpeedSensor: speedSensorName},
+			}
+			vo, err := newVisualOdometryFromConfig(ctx, setupDependencies(t, true), conf, logger)
+			test.That(t, err, test.ShouldBeNil)
+
+			start := time.Now()
+			for i := 0; i < 5; i++ {
+				test.That(t, vo.update(ctx, start.Add(time.Duration(i)*interval)), test.ShouldBeNil)
+			}
+
+			// the base turned right and moved forward 4 times.
+			var want r3.Vector
+			for i := 1; i <= 4; i++ {
+				want = want.Add(r3.Vector{X: math.Sin(float64(i)*stepTurn) * stepMM, Y: math.Cos(float64(i)*stepTurn) * stepMM})
+			}
+			pt, alt, err := vo.Position(ctx, map[string]interface{}{returnRelative: true})
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, pt.Lng(), test.ShouldAlmostEqual, want.X*mmToM, 0.02)
+			test.That(t, pt.Lat(), test.ShouldAlmostEqual, want.Y*mmToM, 0.02)
+			test.That(t, alt, test.ShouldAlmostEqual, 0, 0.02)
+			geoPt, _, err := vo.Position(ctx, nil)
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, geoPt.Lat(), test.ShouldBeGreaterThan, 0)
+
+			o, err := vo.Orientation(ctx, nil)
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, o.EulerAngles().Yaw, test.ShouldAlmostEqual, -4*stepTurn, 0.5*math.Pi/180)
+			test.That(t, o.EulerAngles().Roll, test.ShouldAlmostEqual, 0, 0.5*math.Pi/180)
+
+			linVel, err := vo.LinearVelocity(ctx, nil)
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, linVel.Y, test.ShouldAlmostEqual, stepMM*mmToM/interval.Seconds(), 0.05)
+			test.That(t, linVel.Z, test.ShouldAlmostEqual, 0, 0.05)
+			angVel, err := vo.AngularVelocity(ctx, nil)
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, angVel.Z, test.ShouldAlmostEqual, -2/interval.Seconds(), 1.5)
+
+			readings, err := vo.Readings(ctx, nil)
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, readings["position_meters_X"], test.ShouldAlmostEqual, pt.Lng())
+
+			resp, err := vo.DoCommand(ctx, map[string]interface{}{resetCmd: true})
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, resp, test.ShouldContainKey, resetCmd)
+			pt, _, err = vo.Position(ctx, map[string]interface{}{returnRelative: true})
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, pt.Lat(), test.ShouldEqual, 0)
+			test.That(t, pt.Lng(), test.ShouldEqual, 0)
+			test.That(t, vo.Close(ctx), test.ShouldBeNil)
+		})
+	}
+}
+
+func TestNewVisualOdometry(t *testing.T) {
+	ctx := context.Background()
+	logger := logging.NewTestLogger(t)
+	conf := resource.Config{Name: "vo", ConvertedAttributes: &Config{Camera: cameraName, Mode: ModeRGBD}}
+
+	ms, err := newVisualOdometry(ctx, setupDependencies(t, true), conf, logger)
+	test.That(t, err, test.ShouldBeNil)
+	props, err := ms.Properties(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, props.PositionSupported, test.ShouldBeTrue)
+	test.That(t, props.CompassHeadingSupported, test.ShouldBeFalse)
+	_, err = ms.LinearAcceleration(ctx, nil)
+	test.That(t, err, test.ShouldBeError, movementsensor.ErrMethodUnimplementedLinearAcceleration)
+	test.That(t, ms.Close(ctx), test.ShouldBeNil)
+
+	// the intrinsics of the camera are needed, from it or from the config.
+	_, err = newVisualOdometry(ctx, setupDependencies(t, false), conf, logger)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "intrinsic_parameters")
+	conf.ConvertedAttributes = &Config{Camera: cameraName, Mode: ModeRGBD, CameraParameters: testIntrinsics}
+	ms, err = newVisualOdometry(ctx, setupDependencies(t, false), conf, logger)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, ms.Close(ctx), test.ShouldBeNil)
+
+	// a mono camera without depth cannot be tracked in rgbd mode.
+	cam := inject.NewCamera(cameraName)
+	cam.ImagesFunc = func(ctx context.Context) ([]camera.NamedImage, resource.ResponseMetadata, error) {
+		img, _ := renderRoom(r3.Vector{}, 0)
+		return []camera.NamedImage{{Image: img}}, resource.ResponseMetadata{}, nil
+	}
+	vo, err := newVisualOdometryFromConfig(ctx, resource.Dependencies{camera.Named(cameraName): cam}, conf, logger)
+	test.That(t, err, test.ShouldBeNil)
+	err = vo.update(ctx, time.Now())
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "no depth image")
+}
+
+func TestValidateConfig(t *testing.T) {
+	deps, err := (&Config{Camera: cameraName, SpeedSensor: speedSensorName}).Validate("path")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldResemble, []string{cameraName, speedSensorName})
+	deps, err = (&Config{Camera: cameraName, Mode: ModeRGBD}).Validate("path")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldResemble, []string{cameraName})
+
+	for _, tc := range []struct {
+		conf Config
+		err  string
+	}{
+		{Config{SpeedSensor: speedSensorName}, "camera"},
+		{Config{Camera: cameraName, Mode: ModeMono}, "speed_sensor"},
+		{Config{Camera: cameraName, Mode: "stereo"}, "mode"},
+		{Config{Camera: cameraName, Mode: ModeRGBD, CameraParameters: &transform.PinholeCameraIntrinsics{}}, "Invalid size"},
+		{Config{Camera: cameraName, Mode: ModeRGBD, TimeIntervalMSecs: -1}, "negative"},
+	} {
+		_, err := tc.conf.Validate("path")
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, tc.err)
+	}
+}
@@ -0,0 +1,179 @@
+// Package fiducial implements a pose tracker which finds fiducial markers in the images of a camera.
+package fiducial
+
+import (
+	"context"
+	"slices"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/components/posetracker"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/rimage/transform"
+	"go.viam.com/rdk/vision/fiducial"
+)
+
+var model = resource.DefaultModelFamily.WithModel("fiducial")
+
+// Config is used for converting the pose tracker attributes.
+type Config struct {
+	CameraName string `json:"camera_name"`
+	// Family is a built in family or the path of an AprilTag family's C source or an OpenCV dictionary.
+	Family string `json:"family"`
+	// TagSizeMM is the width of the outside of the black border of the markers.
+	TagSizeMM float64 `json:"tag_size_mm"`
+	// TagSizesMM overrides the size of the markers with the given IDs.
+	TagSizesMM map[string]float64 `json:"tag_sizes_mm,omitempty"`
+	MaxHamming *int               `json:"max_hamming,omitempty"`
+	// The intrinsics and distortion of the camera default to its properties.
+	CameraParameters     *transform.PinholeCameraIntrinsics `json:"intrinsic_parameters,omitempty"`
+	DistortionParameters *transform.BrownConrady            `json:"distortion_parameters,omitempty"`
+}
+
+// Validate ensures all parts of the config are valid.
+func (cfg *Config) Validate(path string) ([]string, error) {
+	if cfg.CameraName == "" {
+		return nil, resource.NewConfigValidationFieldRequiredError(path, "camera_name")
+	}
+	if cfg.Family == "" {
+		return nil, resource.NewConfigValidationFieldRequiredError(path, "family")
+	}
+	if cfg.TagSizeMM <= 0 {
+		return nil, resource.NewConfigValidationError(path, errors.New("tag_size_mm must be positive"))
+	}
+	for id, size := range cfg.TagSizesMM {
+		if _, err := strconv.Atoi(id); err != nil {
+			return nil, resource.NewConfigValidationError(path, errors.Errorf("tag_sizes_mm has invalid tag ID %q", id))
+		}
+		if size <= 0 {
+			return nil, resource.NewConfigValidationError(path, errors.Errorf("size of tag %s must be positive", id))
+		}
+	}
+	if cfg.CameraParameters != nil {
+		if err := cfg.CameraParameters.CheckValid(); err != nil {
+			return nil, resource.NewConfigValidationError(path, err)
+		}
+	}
+	return []string{cfg.CameraName}, nil
+}
+
+func init() {
+	resource.RegisterComponent(
+		posetracker.API,
+		model,
+		resource.Registration[posetracker.PoseTracker, *Config]{Constructor: newPoseTracker})
+}
+
+type poseTracker struct {
+	resource.Named
+	resource.AlwaysRebuild
+	resource.TriviallyCloseable
+	cam        camera.Camera
+	cameraName string
+	detector   *fiducial.Detector
+	conf       *Config
+	logger     logging.Logger
+}
+
+func newPoseTracker(
+	ctx context.Context,
+	deps resource.Dependencies,
+	conf resource.Config,
+	logger logging.Logger,
+) (posetracker.PoseTracker, error) {
+	newConf, err := resource.NativeConfig[*Config](conf)
+	if err != nil {
+		return nil, err
+	}
+	cam, err := camera.FromDependencies(deps, newConf.CameraName)
+	if err != nil {
+		return nil, err
+	}
+	family, err := fiducial.LoadFamily(newConf.Family)
+	if err != nil {
+		return nil, err
+	}
+	maxHamming := -1
+	if newConf.MaxHamming != nil {
+		maxHamming = *newConf.MaxHamming
+	}
+	detector, err := fiducial.NewDetector(family, maxHamming)
+	if err != nil {
+		return nil, err
+	}
+	return &poseTracker{
+		Named:      conf.ResourceName().AsNamed(),
+		cam:        cam,
+		cameraName: newConf.CameraName,
+		detector:   detector,
+		conf:       newConf,
+		logger:     logger,
+	}, nil
+}
+
+// cameraModel returns the intrinsics and distortion of the camera, preferring those in the config.
+func (pt *poseTracker) cameraModel(ctx context.Context) (*transform.PinholeCameraIntrinsics, transform.Distorter, error) {
+	var distortion transform.Distorter
+	if pt.conf.DistortionParameters != nil {
+		distortion = pt.conf.DistortionParameters
+	}
+	if pt.conf.CameraParameters != nil {
+		return pt.conf.CameraParameters, distortion, nil
+	}
+	props, err := pt.cam.Properties(ctx)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to get properties from %s", pt.cameraName)
+	}
+	if props.IntrinsicParams == nil {
+		return nil, nil, transform.NewNoIntrinsicsError(
+			"camera " + pt.cameraName + " has no intrinsics and none are configured")
+	}
+	if distortion == nil {
+		distortion = props.DistortionParams
+	}
+	return props.IntrinsicParams, distortion, nil
+}
+
+// Poses returns the poses of the markers in the latest image of the camera, in its frame, named by their
+// IDs. If bodyNames is not empty only markers with those IDs are returned.
+func (pt *poseTracker) Poses(
+	ctx context.Context, bodyNames []string, extra map[string]interface{},
+) (posetracker.BodyToPoseInFrame, error) {
+	intrinsics, distortion, err := pt.cameraModel(ctx)
+	if err != nil {
+		return nil, err
+	}
+	img, release, err := camera.ReadImage(ctx, pt.cam)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get image from %s", pt.cameraName)
+	}
+	defer release()
+
+	poses := posetracker.BodyToPoseInFrame{}
+	for _, det := range pt.detector.Detect(img) {
+		name := strconv.Itoa(det.ID)
+		if len(bodyNames) > 0 && !slices.Contains(bodyNames, name) {
+			continue
+		}
+		size := pt.conf.TagSizeMM
+		if s, ok := pt.conf.TagSizesMM[name]; ok {
+			size = s
+		}
+		pose, _, err := fiducial.EstimatePose(det.Corners, size, intrinsics, distortion)
+		if err != nil {
+			pt.logger.CDebugw(ctx, "cannot estimate pose of marker", "id", det.ID, "error", err)
+			continue
+		}
+		poses[name] = referenceframe.NewPoseInFrame(pt.cameraName, pose)
+	}
+	return poses, nil
+}
+
+// Readings returns the poses of all markers in the latest image of the camera.
+func (pt *poseTracker) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
+	return posetracker.Readings(ctx, pt)
+}
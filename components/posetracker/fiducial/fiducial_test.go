@@ -0,0 +1,138 @@
+package fiducial
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/golang/geo/r3"
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/gostream"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/rimage/transform"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/testutils/inject"
+	"go.viam.com/rdk/vision/fiducial"
+)
+
+// renderMarker draws a marker 10 pixels a cell with its top left corner at x, y.
+func renderMarker(img *image.Gray, family *fiducial.Family, id, x, y int) {
+	w := family.WidthAtBorder
+	for py := 0; py < w*10; py++ {
+		for px := 0; px < w*10; px++ {
+			cx, cy := px/10, py/10
+			v := uint8(20)
+			for b := range family.BitX {
+				if family.BitX[b] == cx && family.BitY[b] == cy && family.Codes[id]>>(family.NBits()-1-b)&1 == 1 {
+					v = 230
+				}
+			}
+			img.SetGray(x+px, y+py, color.Gray{Y: v})
+		}
+	}
+}
+
+func newTestCamera(t *testing.T, props camera.Properties) *inject.Camera {
+	t.Helper()
+	family := fiducial.NewArucoOriginal()
+	img := image.NewGray(image.Rect(0, 0, 320, 240))
+	for i := range img.Pix {
+		img.Pix[i] = 230
+	}
+	// marker 37 is centered in the image and marker 600 is to its left.
+	renderMarker(img, family, 37, 125, 85)
+	renderMarker(img, family, 600, 20, 85)
+
+	cam := &inject.Camera{}
+	cam.StreamFunc = func(ctx context.Context, errHandlers ...gostream.ErrorHandler) (gostream.VideoStream, error) {
+		return gostream.NewEmbeddedVideoStreamFromReader(gostream.VideoReaderFunc(
+			func(ctx context.Context) (image.Image, func(), error) {
+				return img, func() {}, nil
+			})), nil
+	}
+	cam.PropertiesFunc = func(ctx context.Context) (camera.Properties, error) {
+		return props, nil
+	}
+	return cam
+}
+
+func TestConfigValidate(t *testing.T) {
+	cfg := &Config{CameraName: "cam", Family: fiducial.ArucoOriginal, TagSizeMM: 70}
+	deps, err := cfg.Validate("path")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldResemble, []string{"cam"})
+
+	_, err = (&Config{Family: fiducial.ArucoOriginal, TagSizeMM: 70}).Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "camera_name")
+	_, err = (&Config{CameraName: "cam", TagSizeMM: 70}).Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "family")
+	_, err = (&Config{CameraName: "cam", Family: fiducial.ArucoOriginal}).Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "tag_size_mm must be positive")
+	cfg.TagSizesMM = map[string]float64{"dock": 100}
+	_, err = cfg.Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "invalid tag ID")
+}
+
+func TestPoses(t *testing.T) {
+	intrinsics := &transform.PinholeCameraIntrinsics{Width: 320, Height: 240, Fx: 500, Fy: 500, Ppx: 160, Ppy: 120}
+	cam := newTestCamera(t, camera.Properties{IntrinsicParams: intrinsics})
+	deps := resource.Dependencies{camera.Named("cam"): cam}
+	conf := resource.Config{
+		Name: "tags",
+		ConvertedAttributes: &Config{
+			CameraName: "cam",
+			Family:     fiducial.ArucoOriginal,
+			TagSizeMM:  70,
+			TagSizesMM: map[string]float64{"600": 140},
+		},
+	}
+	pt, err := newPoseTracker(context.Background(), deps, conf, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+
+	poses, err := pt.Poses(context.Background(), nil, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, poses, test.ShouldHaveLength, 2)
+	// 70 pixels across at a focal length of 500 pixels, a 70mm marker is 500mm away.
+	test.That(t, poses["37"].Parent(), test.ShouldEqual, "cam")
+	test.That(t, spatialmath.PoseAlmostEqualEps(poses["37"].Pose(),
+		spatialmath.NewPoseFromPoint(r3.Vector{Z: 500}), 3), test.ShouldBeTrue)
+	// marker 600 is twice the size, so twice as far away.
+	test.That(t, poses["600"].Pose().Point().Z, test.ShouldAlmostEqual, 1000, 6)
+	test.That(t, poses["600"].Pose().Point().X, test.ShouldAlmostEqual, -210, 2)
+
+	poses, err = pt.Poses(context.Background(), []string{"37", "5"}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, poses, test.ShouldHaveLength, 1)
+	test.That(t, poses, test.ShouldContainKey, "37")
+
+	readings, err := pt.Readings(context.Background(), nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, readings, test.ShouldHaveLength, 2)
+
+	// without intrinsics from the camera or the config, there are no poses.
+	deps[camera.Named("cam")] = newTestCamera(t, camera.Properties{})
+	pt, err = newPoseTracker(context.Background(), deps, conf, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	_, err = pt.Poses(context.Background(), nil, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "has no intrinsics")
+	conf.ConvertedAttributes.(*Config).CameraParameters = intrinsics
+	pt, err = newPoseTracker(context.Background(), deps, conf, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	poses, err = pt.Poses(context.Background(), nil, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, poses, test.ShouldHaveLength, 2)
+
+	// an unknown family cannot be tracked.
+	conf.ConvertedAttributes.(*Config).Family = "tag36h11"
+	_, err = newPoseTracker(context.Background(), deps, conf, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldNotBeNil)
+}
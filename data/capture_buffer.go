@@ -20,6 +20,7 @@ type CaptureBuffer struct {
 	nextFile           *CaptureFile
 	lock               sync.Mutex
 	maxCaptureFileSize int64
+	options            CaptureFileOptions
 }
 
 // NewCaptureBuffer returns a new Buffer.
@@ -31,6 +32,18 @@ func NewCaptureBuffer(dir string, md *v1.DataCaptureMetadata, maxCaptureFileSize
 	}
 }
 
+// NewCaptureBufferWithOptions returns a new Buffer whose files encode their readings as described by options.
+func NewCaptureBufferWithOptions(
+	dir string,
+	md *v1.DataCaptureMetadata,
+	maxCaptureFileSize int64,
+	options CaptureFileOptions,
+) *CaptureBuffer {
+	b := NewCaptureBuffer(dir, md, maxCaptureFileSize)
+	b.options = options
+	return b
+}
+
 // Write writes item onto b. Binary sensor data is written to its own file.
 // Tabular data is written to disk in maxCaptureFileSize sized files. Files that
 // are still being written to are indicated with the extension
@@ -41,7 +54,7 @@ func (b *CaptureBuffer) Write(item *v1.SensorData) error {
 	defer b.lock.Unlock()
 
 	if item.GetBinary() != nil {
-		binFile, err := NewCaptureFile(b.Directory, b.MetaData)
+		binFile, err := NewCaptureFileWithOptions(b.Directory, b.MetaData, b.options)
 		if err != nil {
 			return err
 		}
@@ -55,7 +68,7 @@ func (b *CaptureBuffer) Write(item *v1.SensorData) error {
 	}
 
 	if b.nextFile == nil {
-		nextFile, err := NewCaptureFile(b.Directory, b.MetaData)
+		nextFile, err := NewCaptureFileWithOptions(b.Directory, b.MetaData, b.options)
 		if err != nil {
 			return err
 		}
@@ -67,7 +80,7 @@ func (b *CaptureBuffer) Write(item *v1.SensorData) error {
 		if err := b.nextFile.Close(); err != nil {
 			return err
 		}
-		nextFile, err := NewCaptureFile(b.Directory, b.MetaData)
+		nextFile, err := NewCaptureFileWithOptions(b.Directory, b.MetaData, b.options)
 		if err != nil {
 			return err
 		}
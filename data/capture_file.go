@@ -12,6 +12,7 @@ import (
 
 	"github.com/matttproud/golang_protobuf_extensions/pbutil"
 	"github.com/pkg/errors"
+	"go.uber.org/multierr"
 	v1 "go.viam.com/api/app/datasync/v1"
 	"google.golang.org/protobuf/types/known/anypb"
 
@@ -36,11 +37,13 @@ const (
 	// Non-exhaustive list of characters to strip from file paths, since not allowed
 	// on certain file systems.
 	filePathReservedChars = ":"
+	// FailedDir is a subdirectory of the capture directory that holds any files that could not be synced.
+	FailedDir = "failed"
 )
 
 // CaptureFile is the data structure containing data captured by collectors. It is backed by a file on disk containing
 // length delimited protobuf messages, where the first message is the CaptureMetadata for the file, and ensuing
-// messages contain the captured data.
+// messages contain the captured data, encoded as described by the CaptureFileOptions of the file.
 type CaptureFile struct {
 	path     string
 	lock     sync.Mutex
@@ -48,6 +51,14 @@ type CaptureFile struct {
 	writer   *bufio.Writer
 	size     int64
 	metadata *v1.DataCaptureMetadata
+	options  CaptureFileOptions
+
+	// encoder and encoded compress the readings written to compressed files, decoder reads the readings
+	// of files which aren't in the original format.
+	encoder   readingEncoder
+	encoded   *countingWriter
+	unflushed int
+	decoder   *readingDecoder
 
 	initialReadOffset int64
 	readOffset        int64
@@ -69,13 +80,20 @@ func ReadCaptureFile(f *os.File) (*CaptureFile, error) {
 	if err != nil {
 		return nil, errors.Wrapf(err, fmt.Sprintf("failed to read DataCaptureMetadata from %s", f.Name())) //nolint:govet
 	}
+	options, err := extractCaptureFileOptions(md)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read the capture file options of %s", f.Name())
+	}
 
+	writer := bufio.NewWriter(f)
 	ret := CaptureFile{
 		path:              f.Name(),
 		file:              f,
-		writer:            bufio.NewWriter(f),
+		writer:            writer,
+		encoded:           &countingWriter{w: writer},
 		size:              finfo.Size(),
 		metadata:          md,
+		options:           options,
 		initialReadOffset: int64(initOffset),
 		readOffset:        int64(initOffset),
 		writeOffset:       int64(initOffset),
@@ -86,6 +104,16 @@ func ReadCaptureFile(f *os.File) (*CaptureFile, error) {
 
 // NewCaptureFile creates a new *CaptureFile with the specified md in the specified directory.
 func NewCaptureFile(dir string, md *v1.DataCaptureMetadata) (*CaptureFile, error) {
+	return NewCaptureFileWithOptions(dir, md, CaptureFileOptions{})
+}
+
+// NewCaptureFileWithOptions creates a new *CaptureFile with the specified md in the specified directory, whose
+// readings are encoded as described by options.
+func NewCaptureFileWithOptions(dir string, md *v1.DataCaptureMetadata, options CaptureFileOptions) (*CaptureFile, error) {
+	header, err := marshalCaptureFileHeader(md, options)
+	if err != nil {
+		return nil, err
+	}
 	fileName := CaptureFilePathWithReplacedReservedChars(
 		filepath.Join(dir, getFileTimestampName()) + InProgressCaptureFileExt)
 	//nolint:gosec
@@ -95,15 +123,25 @@ func NewCaptureFile(dir string, md *v1.DataCaptureMetadata) (*CaptureFile, error
 	}
 
 	// Then write first metadata message to the file.
-	n, err := pbutil.WriteDelimited(f, md)
+	n, err := f.Write(header)
+	if err != nil {
+		return nil, err
+	}
+	writer := bufio.NewWriter(f)
+	encoded := &countingWriter{w: writer}
+	encoder, err := newReadingEncoder(options.Compression, encoded)
 	if err != nil {
 		return nil, err
 	}
 	return &CaptureFile{
 		path:              f.Name(),
-		writer:            bufio.NewWriter(f),
+		writer:            writer,
 		file:              f,
 		size:              int64(n),
+		metadata:          md,
+		options:           options,
+		encoder:           encoder,
+		encoded:           encoded,
 		initialReadOffset: int64(n),
 		readOffset:        int64(n),
 		writeOffset:       int64(n),
@@ -115,15 +153,35 @@ func (f *CaptureFile) ReadMetadata() *v1.DataCaptureMetadata {
 	return f.metadata
 }
 
-// ReadNext returns the next SensorData reading.
+// Options returns how the readings of f are encoded.
+func (f *CaptureFile) Options() CaptureFileOptions {
+	return f.options
+}
+
+// ReadNext returns the next SensorData reading. It returns ErrCorruptCaptureFile if the reading fails its
+// checksum, after which the following readings can still be read.
 func (f *CaptureFile) ReadNext() (*v1.SensorData, error) {
 	f.lock.Lock()
 	defer f.lock.Unlock()
 
+	if err := f.flushEncoder(); err != nil {
+		return nil, err
+	}
 	if err := f.writer.Flush(); err != nil {
 		return nil, err
 	}
 
+	if f.options != (CaptureFileOptions{}) {
+		if f.decoder == nil {
+			decoder, err := newReadingDecoder(f.options.Compression, f.file, f.initialReadOffset)
+			if err != nil {
+				return nil, err
+			}
+			f.decoder = decoder
+		}
+		return readReading(f.decoder.r, f.options.Checksums)
+	}
+
 	if _, err := f.file.Seek(f.readOffset, io.SeekStart); err != nil {
 		return nil, err
 	}
@@ -145,12 +203,43 @@ func (f *CaptureFile) WriteNext(data *v1.SensorData) error {
 	if _, err := f.file.Seek(f.writeOffset, 0); err != nil {
 		return err
 	}
-	n, err := pbutil.WriteDelimited(f.writer, data)
+	if f.options == (CaptureFileOptions{}) {
+		n, err := pbutil.WriteDelimited(f.writer, data)
+		if err != nil {
+			return err
+		}
+		f.size += int64(n)
+		f.writeOffset += int64(n)
+		return nil
+	}
+
+	if f.options.Compression != CaptureFileCompressionNone && f.encoder == nil {
+		return errors.Errorf("can't append to compressed capture file %s", f.path)
+	}
+	reading, err := appendReading(nil, data, f.options.Checksums)
 	if err != nil {
 		return err
 	}
-	f.size += int64(n)
-	f.writeOffset += int64(n)
+	before := f.encoded.n
+	if f.encoder == nil {
+		if _, err := f.encoded.Write(reading); err != nil {
+			return err
+		}
+	} else {
+		if _, err := f.encoder.Write(reading); err != nil {
+			return err
+		}
+		// the encoder is flushed every block of readings so that they can be recovered if the file is cut
+		// short, as the buffered writes of uncompressed files can.
+		f.unflushed += len(reading)
+		if f.unflushed >= encoderFlushBytes {
+			if err := f.flushEncoder(); err != nil {
+				return err
+			}
+		}
+	}
+	f.size += f.encoded.n - before
+	f.writeOffset += f.encoded.n - before
 	return nil
 }
 
@@ -158,14 +247,39 @@ func (f *CaptureFile) WriteNext(data *v1.SensorData) error {
 func (f *CaptureFile) Flush() error {
 	f.lock.Lock()
 	defer f.lock.Unlock()
+	if err := f.flushEncoder(); err != nil {
+		return err
+	}
 	return f.writer.Flush()
 }
 
+func (f *CaptureFile) flushEncoder() error {
+	if f.encoder == nil || f.unflushed == 0 {
+		return nil
+	}
+	before := f.encoded.n
+	if err := f.encoder.Flush(); err != nil {
+		return err
+	}
+	f.size += f.encoded.n - before
+	f.writeOffset += f.encoded.n - before
+	f.unflushed = 0
+	return nil
+}
+
 // Reset resets the read pointer of f.
 func (f *CaptureFile) Reset() {
 	f.lock.Lock()
 	defer f.lock.Unlock()
 	f.readOffset = f.initialReadOffset
+	f.closeDecoder()
+}
+
+func (f *CaptureFile) closeDecoder() {
+	if f.decoder != nil {
+		f.decoder.close()
+		f.decoder = nil
+	}
 }
 
 // Size returns the size of the file.
@@ -184,6 +298,13 @@ func (f *CaptureFile) GetPath() string {
 func (f *CaptureFile) Close() error {
 	f.lock.Lock()
 	defer f.lock.Unlock()
+	f.closeDecoder()
+	if f.encoder != nil {
+		if err := f.encoder.Close(); err != nil {
+			return err
+		}
+		f.encoder = nil
+	}
 	if err := f.writer.Flush(); err != nil {
 		return err
 	}
@@ -201,6 +322,7 @@ func (f *CaptureFile) Close() error {
 func (f *CaptureFile) Delete() error {
 	f.lock.Lock()
 	defer f.lock.Unlock()
+	f.closeDecoder()
 	if err := f.file.Close(); err != nil {
 		return err
 	}
@@ -318,6 +440,57 @@ func SensorDataFromCaptureFile(f *CaptureFile) ([]*v1.SensorData, error) {
 	return ret, nil
 }
 
+// RecoverCaptureFile salvages the readings of the capture file at path, which may have been cut short or
+// corrupted, such as by a power cut while it was being written. The complete readings which pass their
+// checksum are written to a new completed capture file in the same directory with the same metadata and
+// options, and the file at path is removed. It returns how many readings were recovered and how many
+// failed their checksum.
+func RecoverCaptureFile(path string) (recovered, corrupted int, err error) {
+	//nolint:gosec
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() {
+		//nolint:errcheck
+		f.Close()
+	}()
+	captureFile, err := ReadCaptureFile(f)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer captureFile.closeDecoder()
+
+	var recoveredFile *CaptureFile
+	for {
+		next, err := captureFile.ReadNext()
+		if errors.Is(err, ErrCorruptCaptureFile) {
+			corrupted++
+			continue
+		}
+		// anything after a reading which can't be read, such as one which was cut short, is lost.
+		if err != nil {
+			break
+		}
+		if recoveredFile == nil {
+			recoveredFile, err = NewCaptureFileWithOptions(filepath.Dir(path), captureFile.ReadMetadata(), captureFile.Options())
+			if err != nil {
+				return 0, corrupted, err
+			}
+		}
+		if err := recoveredFile.WriteNext(next); err != nil {
+			return 0, corrupted, multierr.Combine(err, recoveredFile.Delete())
+		}
+		recovered++
+	}
+	if recoveredFile != nil {
+		if err := recoveredFile.Close(); err != nil {
+			return 0, corrupted, err
+		}
+	}
+	return recovered, corrupted, os.Remove(path)
+}
+
 // CaptureFilePathWithReplacedReservedChars returns the filepath with substitutions
 // for reserved characters.
 func CaptureFilePathWithReplacedReservedChars(filepath string) string {
@@ -0,0 +1,248 @@
+package data
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+	v1 "go.viam.com/api/app/datasync/v1"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// CaptureFileCompression is how the readings of a capture file are compressed.
+type CaptureFileCompression string
+
+// The compressions of capture file readings.
+const (
+	CaptureFileCompressionNone CaptureFileCompression = ""
+	CaptureFileCompressionGzip CaptureFileCompression = "gzip"
+	CaptureFileCompressionZstd CaptureFileCompression = "zstd"
+)
+
+// CaptureFileOptions describes how the readings of a capture file are encoded. The zero value is the
+// original format of uncompressed length delimited readings, which every reader understands.
+type CaptureFileOptions struct {
+	// Compression compresses the readings as a single stream which is flushed every encoderFlushBytes
+	// of readings and on Flush, so that all flushed readings can be recovered from a file which was cut short.
+	Compression CaptureFileCompression
+	// Checksums follows every reading with its CRC-32C, so that corrupted readings are detected.
+	Checksums bool
+}
+
+// ErrCorruptCaptureFile is returned when reading a capture file reading which fails its checksum.
+var ErrCorruptCaptureFile = errors.New("capture file reading failed its checksum")
+
+// The options of a capture file are recorded in a field of its metadata which the DataCaptureMetadata
+// proto doesn't define, so that they aren't mistaken for any of its fields and are never uploaded.
+const (
+	captureFileOptionsField protowire.Number = 10000
+	compressionField        protowire.Number = 1
+	checksumField           protowire.Number = 2
+	checksumCRC32C                           = 1
+	checksumSize                             = 4
+)
+
+// encoderFlushBytes is how many bytes of readings are compressed between flushes of the stream. Flushing
+// more often makes the readings more robust to power cuts, but compresses them less.
+const encoderFlushBytes = 32 * 1024
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// marshalCaptureFileHeader returns md with options recorded in it, length delimited.
+func marshalCaptureFileHeader(md *v1.DataCaptureMetadata, options CaptureFileOptions) ([]byte, error) {
+	b, err := proto.Marshal(md)
+	if err != nil {
+		return nil, err
+	}
+	if options != (CaptureFileOptions{}) {
+		var encoded []byte
+		if options.Compression != CaptureFileCompressionNone {
+			encoded = protowire.AppendTag(encoded, compressionField, protowire.BytesType)
+			encoded = protowire.AppendString(encoded, string(options.Compression))
+		}
+		if options.Checksums {
+			encoded = protowire.AppendTag(encoded, checksumField, protowire.VarintType)
+			encoded = protowire.AppendVarint(encoded, checksumCRC32C)
+		}
+		b = protowire.AppendTag(b, captureFileOptionsField, protowire.BytesType)
+		b = protowire.AppendBytes(b, encoded)
+	}
+	return append(protowire.AppendVarint(nil, uint64(len(b))), b...), nil
+}
+
+// extractCaptureFileOptions removes the options recorded in md and returns them.
+func extractCaptureFileOptions(md *v1.DataCaptureMetadata) (CaptureFileOptions, error) {
+	var options CaptureFileOptions
+	var rest []byte
+	unknown := md.ProtoReflect().GetUnknown()
+	for len(unknown) > 0 {
+		num, typ, n := protowire.ConsumeTag(unknown)
+		if n < 0 {
+			return options, protowire.ParseError(n)
+		}
+		fieldLen := protowire.ConsumeFieldValue(num, typ, unknown[n:])
+		if fieldLen < 0 {
+			return options, protowire.ParseError(fieldLen)
+		}
+		field := unknown[:n+fieldLen]
+		unknown = unknown[n+fieldLen:]
+		if num != captureFileOptionsField || typ != protowire.BytesType {
+			rest = append(rest, field...)
+			continue
+		}
+		encoded, _ := protowire.ConsumeBytes(field[n:])
+		for len(encoded) > 0 {
+			num, typ, n := protowire.ConsumeTag(encoded)
+			if n < 0 {
+				return options, protowire.ParseError(n)
+			}
+			encoded = encoded[n:]
+			switch {
+			case num == compressionField && typ == protowire.BytesType:
+				v, n := protowire.ConsumeString(encoded)
+				if n < 0 {
+					return options, protowire.ParseError(n)
+				}
+				options.Compression = CaptureFileCompression(v)
+				encoded = encoded[n:]
+			case num == checksumField && typ == protowire.VarintType:
+				v, n := protowire.ConsumeVarint(encoded)
+				if n < 0 {
+					return options, protowire.ParseError(n)
+				}
+				if v != checksumCRC32C {
+					return options, errors.Errorf("unsupported capture file checksum %d", v)
+				}
+				options.Checksums = true
+				encoded = encoded[n:]
+			default:
+				n := protowire.ConsumeFieldValue(num, typ, encoded)
+				if n < 0 {
+					return options, protowire.ParseError(n)
+				}
+				encoded = encoded[n:]
+			}
+		}
+	}
+	md.ProtoReflect().SetUnknown(rest)
+	switch options.Compression {
+	case CaptureFileCompressionNone, CaptureFileCompressionGzip, CaptureFileCompressionZstd:
+		return options, nil
+	default:
+		return options, errors.Errorf("unsupported capture file compression %q", options.Compression)
+	}
+}
+
+// readingEncoder compresses the readings written to a capture file.
+type readingEncoder interface {
+	io.Writer
+	Flush() error
+	Close() error
+}
+
+func newReadingEncoder(compression CaptureFileCompression, w io.Writer) (readingEncoder, error) {
+	switch compression {
+	case CaptureFileCompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CaptureFileCompressionZstd:
+		return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	default:
+		return nil, nil
+	}
+}
+
+// readingDecoder decompresses the readings read from a capture file.
+type readingDecoder struct {
+	r     *bufio.Reader
+	close func()
+}
+
+func newReadingDecoder(compression CaptureFileCompression, f *os.File, offset int64) (*readingDecoder, error) {
+	section := bufio.NewReader(io.NewSectionReader(f, offset, math.MaxInt64-offset))
+	switch compression {
+	case CaptureFileCompressionGzip:
+		zr, err := gzip.NewReader(section)
+		if err != nil {
+			return nil, err
+		}
+		return &readingDecoder{r: bufio.NewReader(zr), close: func() {
+			//nolint:errcheck
+			zr.Close()
+		}}, nil
+	case CaptureFileCompressionZstd:
+		zr, err := zstd.NewReader(section, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return &readingDecoder{r: bufio.NewReader(zr), close: zr.Close}, nil
+	default:
+		return &readingDecoder{r: section, close: func() {}}, nil
+	}
+}
+
+// appendReading appends data length delimited, followed by its checksum if checksums is true.
+func appendReading(b []byte, data *v1.SensorData, checksums bool) ([]byte, error) {
+	msg, err := proto.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	b = protowire.AppendVarint(b, uint64(len(msg)))
+	b = append(b, msg...)
+	if checksums {
+		b = binary.LittleEndian.AppendUint32(b, crc32.Checksum(msg, crc32cTable))
+	}
+	return b, nil
+}
+
+// readReading reads a reading written by appendReading. It returns io.EOF if there are no more readings
+// and io.ErrUnexpectedEOF if the last reading was cut short.
+func readReading(r *bufio.Reader, checksums bool) (*v1.SensorData, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	// the reading is read without allocating size upfront, as a corrupted size could be huge.
+	msg, err := io.ReadAll(io.LimitReader(r, int64(min(size, math.MaxInt64))))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(msg)) != size {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if checksums {
+		var checksum [checksumSize]byte
+		if _, err := io.ReadFull(r, checksum[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if binary.LittleEndian.Uint32(checksum[:]) != crc32.Checksum(msg, crc32cTable) {
+			return nil, ErrCorruptCaptureFile
+		}
+	}
+	var data v1.SensorData
+	if err := proto.Unmarshal(msg, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
@@ -1,10 +1,15 @@
 package data
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	v1 "go.viam.com/api/app/datasync/v1"
 	"go.viam.com/test"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/structpb"
 
 	"go.viam.com/rdk/protoutils"
@@ -174,3 +179,133 @@ func TestReadCorruptedFile(t *testing.T) {
 	test.That(t, err, test.ShouldBeNil)
 	test.That(t, len(sd), test.ShouldEqual, numReadings)
 }
+
+func TestCaptureFileOptions(t *testing.T) {
+	md := &v1.DataCaptureMetadata{
+		ComponentName: "bumper",
+		MethodName:    "Readings",
+		Type:          v1.DataType_DATA_TYPE_TABULAR_SENSOR,
+		Tags:          []string{"tagA"},
+	}
+	reading := func(i int) *v1.SensorData {
+		s, err := structpb.NewStruct(map[string]interface{}{"pressed": i%2 == 0, "temperature_celsius": 21.5})
+		test.That(t, err, test.ShouldBeNil)
+		return &v1.SensorData{Metadata: &v1.SensorMetadata{}, Data: &v1.SensorData_Struct{Struct: s}}
+	}
+	numReadings := 200
+
+	var uncompressedSize int64
+	for _, options := range []CaptureFileOptions{
+		{},
+		{Checksums: true},
+		{Compression: CaptureFileCompressionGzip},
+		{Compression: CaptureFileCompressionZstd, Checksums: true},
+	} {
+		t.Run(fmt.Sprintf("%+v", options), func(t *testing.T) {
+			f, err := NewCaptureFileWithOptions(t.TempDir(), md, options)
+			test.That(t, err, test.ShouldBeNil)
+			for i := 0; i < numReadings; i++ {
+				test.That(t, f.WriteNext(reading(i)), test.ShouldBeNil)
+			}
+			test.That(t, f.Close(), test.ShouldBeNil)
+			path := strings.TrimSuffix(f.GetPath(), InProgressCaptureFileExt) + CompletedCaptureFileExt
+
+			//nolint:gosec
+			file, err := os.Open(path)
+			test.That(t, err, test.ShouldBeNil)
+			defer file.Close()
+			read, err := ReadCaptureFile(file)
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, read.Options(), test.ShouldResemble, options)
+			// the options are not part of the metadata which is uploaded.
+			test.That(t, proto.Equal(read.ReadMetadata(), md), test.ShouldBeTrue)
+
+			sd, err := SensorDataFromCaptureFile(read)
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, sd, test.ShouldHaveLength, numReadings)
+			for i, d := range sd {
+				test.That(t, proto.Equal(d, reading(i)), test.ShouldBeTrue)
+			}
+
+			if options == (CaptureFileOptions{}) {
+				uncompressedSize = read.Size()
+			}
+			if options.Compression != CaptureFileCompressionNone {
+				test.That(t, read.Size(), test.ShouldBeLessThan, uncompressedSize/2)
+			}
+		})
+	}
+}
+
+func TestRecoverCaptureFile(t *testing.T) {
+	md := &v1.DataCaptureMetadata{Type: v1.DataType_DATA_TYPE_TABULAR_SENSOR}
+	numReadings := 50
+	writeFile := func(t *testing.T, options CaptureFileOptions) (string, []int64) {
+		t.Helper()
+		f, err := NewCaptureFileWithOptions(t.TempDir(), md, options)
+		test.That(t, err, test.ShouldBeNil)
+		// the file is flushed after every reading, but never closed, as when the power is cut.
+		var ends []int64
+		for i := 0; i < numReadings; i++ {
+			s, err := structpb.NewStruct(map[string]interface{}{"i": i})
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, f.WriteNext(&v1.SensorData{Data: &v1.SensorData_Struct{Struct: s}}), test.ShouldBeNil)
+			test.That(t, f.Flush(), test.ShouldBeNil)
+			ends = append(ends, f.Size())
+		}
+		return f.GetPath(), ends
+	}
+	recoveredReadings := func(t *testing.T, dir string) []*v1.SensorData {
+		t.Helper()
+		paths, err := filepath.Glob(filepath.Join(dir, "*"))
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, paths, test.ShouldHaveLength, 1)
+		test.That(t, filepath.Ext(paths[0]), test.ShouldEqual, CompletedCaptureFileExt)
+		sd, err := SensorDataFromCaptureFilePath(paths[0])
+		test.That(t, err, test.ShouldBeNil)
+		return sd
+	}
+
+	for _, compression := range []CaptureFileCompression{
+		CaptureFileCompressionNone, CaptureFileCompressionGzip, CaptureFileCompressionZstd,
+	} {
+		t.Run("cut short "+string(compression), func(t *testing.T) {
+			options := CaptureFileOptions{Compression: compression, Checksums: true}
+			path, ends := writeFile(t, options)
+			// the last reading is partially written.
+			test.That(t, os.Truncate(path, ends[numReadings-2]+3), test.ShouldBeNil)
+
+			recovered, corrupted, err := RecoverCaptureFile(path)
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, recovered, test.ShouldEqual, numReadings-1)
+			test.That(t, corrupted, test.ShouldEqual, 0)
+			sd := recoveredReadings(t, filepath.Dir(path))
+			test.That(t, sd, test.ShouldHaveLength, numReadings-1)
+			test.That(t, sd[numReadings-2].GetStruct().AsMap()["i"], test.ShouldEqual, numReadings-2)
+		})
+	}
+
+	t.Run("corrupted reading", func(t *testing.T) {
+		path, ends := writeFile(t, CaptureFileOptions{Checksums: true})
+		contents, err := os.ReadFile(path)
+		test.That(t, err, test.ShouldBeNil)
+		// flip a bit in the value of the tenth reading.
+		contents[ends[9]-checksumSize-1] ^= 1
+		test.That(t, os.WriteFile(path, contents, 0o600), test.ShouldBeNil)
+
+		//nolint:gosec
+		f, err := os.Open(path)
+		test.That(t, err, test.ShouldBeNil)
+		defer f.Close()
+		captureFile, err := ReadCaptureFile(f)
+		test.That(t, err, test.ShouldBeNil)
+		_, err = SensorDataFromCaptureFile(captureFile)
+		test.That(t, err, test.ShouldBeError, ErrCorruptCaptureFile)
+
+		recovered, corrupted, err := RecoverCaptureFile(path)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, recovered, test.ShouldEqual, numReadings-1)
+		test.That(t, corrupted, test.ShouldEqual, 1)
+		test.That(t, recoveredReadings(t, filepath.Dir(path)), test.ShouldHaveLength, numReadings-1)
+	})
+}
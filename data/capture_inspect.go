@@ -0,0 +1,110 @@
+package data
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "go.viam.com/api/app/datasync/v1"
+)
+
+// CaptureFileReport describes the readings of a capture file and the problems found reading them.
+type CaptureFileReport struct {
+	Path     string
+	Size     int64
+	Metadata *v1.DataCaptureMetadata
+	Options  CaptureFileOptions
+	// Readings is how many readings were read.
+	Readings int
+	// Start is when the earliest reading was requested and End when the latest reading was received.
+	Start time.Time
+	End   time.Time
+	// Corrupted is how many readings failed their checksum and were skipped.
+	Corrupted int
+	// Truncated is whether the file ends part way through a reading, as files which are still being
+	// written or which were cut short by a power cut do.
+	Truncated bool
+	// Err is why the file couldn't be read to its end, such as its metadata or a reading not being valid.
+	Err error
+}
+
+// Valid returns whether every reading of the file was read.
+func (r CaptureFileReport) Valid() bool {
+	return r.Err == nil && r.Corrupted == 0 && !r.Truncated
+}
+
+// InspectCaptureFile reads every reading of the capture file at path, calling fn with the metadata of the
+// file and each reading which can be read if fn isn't nil. Unlike SensorDataFromCaptureFilePath, readings
+// which fail their checksum are skipped and the problems found reading the file are reported rather than
+// returned. The returned error is only ever an error returned by fn, which stops the file from being read
+// further.
+func InspectCaptureFile(path string, fn func(*v1.DataCaptureMetadata, *v1.SensorData) error) (CaptureFileReport, error) {
+	report := CaptureFileReport{Path: path}
+	//nolint:gosec
+	f, err := os.Open(path)
+	if err != nil {
+		report.Err = err
+		return report, nil
+	}
+	defer func() {
+		// the file is only read from, closing it directly avoids the rename done by CaptureFile.Close.
+		//nolint:errcheck
+		f.Close()
+	}()
+	captureFile, err := ReadCaptureFile(f)
+	if err != nil {
+		report.Err = err
+		return report, nil
+	}
+	defer captureFile.closeDecoder()
+	report.Size = captureFile.Size()
+	report.Metadata = captureFile.ReadMetadata()
+	report.Options = captureFile.Options()
+
+	for {
+		next, err := captureFile.ReadNext()
+		switch {
+		case errors.Is(err, ErrCorruptCaptureFile):
+			report.Corrupted++
+			continue
+		case errors.Is(err, io.EOF):
+			return report, nil
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			report.Truncated = true
+			return report, nil
+		case err != nil:
+			report.Err = errors.Wrapf(err, "failed to read reading %d", report.Readings+report.Corrupted)
+			return report, nil
+		}
+
+		report.Readings++
+		if requested := next.GetMetadata().GetTimeRequested(); requested != nil &&
+			(report.Start.IsZero() || requested.AsTime().Before(report.Start)) {
+			report.Start = requested.AsTime()
+		}
+		if received := next.GetMetadata().GetTimeReceived(); received != nil && received.AsTime().After(report.End) {
+			report.End = received.AsTime()
+		}
+		if fn != nil {
+			if err := fn(report.Metadata, next); err != nil {
+				return report, err
+			}
+		}
+	}
+}
+
+// BinaryReadingFileName returns a name for the file a binary reading captured with md is extracted to,
+// made of its component, method and the time it was requested, so that the readings of a capture file sort
+// in the order they were captured.
+func BinaryReadingFileName(md *v1.DataCaptureMetadata, data *v1.SensorData) string {
+	ext := md.GetFileExtension()
+	if ext == "" {
+		ext = ".bin"
+	}
+	requested := data.GetMetadata().GetTimeRequested().AsTime().UTC().Format("20060102T150405.000000000Z")
+	name := fmt.Sprintf("%s_%s_%s%s", md.GetComponentName(), md.GetMethodName(), requested, ext)
+	return CaptureFilePathWithReplacedReservedChars(strings.ReplaceAll(name, "/", "_"))
+}
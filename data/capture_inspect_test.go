@@ -0,0 +1,102 @@
+package data
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "go.viam.com/api/app/datasync/v1"
+	"go.viam.com/test"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestInspectCaptureFile(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	md := &v1.DataCaptureMetadata{ComponentName: "arm1", MethodName: "EndPosition", Type: v1.DataType_DATA_TYPE_TABULAR_SENSOR}
+	numReadings := 10
+	writeFile := func(t *testing.T, options CaptureFileOptions) (*CaptureFile, []int64) {
+		t.Helper()
+		f, err := NewCaptureFileWithOptions(t.TempDir(), md, options)
+		test.That(t, err, test.ShouldBeNil)
+		var ends []int64
+		for i := 0; i < numReadings; i++ {
+			s, err := structpb.NewStruct(map[string]interface{}{"i": i})
+			test.That(t, err, test.ShouldBeNil)
+			requested := start.Add(time.Duration(i) * time.Second)
+			test.That(t, f.WriteNext(&v1.SensorData{
+				Metadata: &v1.SensorMetadata{
+					TimeRequested: timestamppb.New(requested),
+					TimeReceived:  timestamppb.New(requested.Add(time.Millisecond)),
+				},
+				Data: &v1.SensorData_Struct{Struct: s},
+			}), test.ShouldBeNil)
+			test.That(t, f.Flush(), test.ShouldBeNil)
+			ends = append(ends, f.Size())
+		}
+		return f, ends
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		f, _ := writeFile(t, CaptureFileOptions{Compression: CaptureFileCompressionZstd, Checksums: true})
+		test.That(t, f.Close(), test.ShouldBeNil)
+		path := strings.TrimSuffix(f.GetPath(), InProgressCaptureFileExt) + CompletedCaptureFileExt
+		var read []int
+		report, err := InspectCaptureFile(path, func(fileMD *v1.DataCaptureMetadata, sd *v1.SensorData) error {
+			test.That(t, fileMD.GetComponentName(), test.ShouldEqual, "arm1")
+			read = append(read, int(sd.GetStruct().AsMap()["i"].(float64)))
+			return nil
+		})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, report.Valid(), test.ShouldBeTrue)
+		test.That(t, report.Readings, test.ShouldEqual, numReadings)
+		test.That(t, read, test.ShouldHaveLength, numReadings)
+		test.That(t, report.Options.Compression, test.ShouldEqual, CaptureFileCompressionZstd)
+		test.That(t, report.Start, test.ShouldEqual, start)
+		test.That(t, report.End, test.ShouldEqual, start.Add(time.Duration(numReadings-1)*time.Second+time.Millisecond))
+	})
+
+	t.Run("corrupted and cut short", func(t *testing.T) {
+		f, ends := writeFile(t, CaptureFileOptions{Checksums: true})
+		path := f.GetPath()
+		contents, err := os.ReadFile(path)
+		test.That(t, err, test.ShouldBeNil)
+		// flip a bit in the value of the third reading and cut the last reading short.
+		contents[ends[2]-checksumSize-1] ^= 1
+		test.That(t, os.WriteFile(path, contents[:ends[numReadings-2]+3], 0o600), test.ShouldBeNil)
+
+		report, err := InspectCaptureFile(path, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, report.Valid(), test.ShouldBeFalse)
+		test.That(t, report.Readings, test.ShouldEqual, numReadings-2)
+		test.That(t, report.Corrupted, test.ShouldEqual, 1)
+		test.That(t, report.Truncated, test.ShouldBeTrue)
+		test.That(t, report.Err, test.ShouldBeNil)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		report, err := InspectCaptureFile("/nonexistent/a.capture", nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, report.Err, test.ShouldNotBeNil)
+		test.That(t, report.Metadata, test.ShouldBeNil)
+
+		f, _ := writeFile(t, CaptureFileOptions{})
+		path := f.GetPath()
+		errStop := errors.New("stop")
+		report, err = InspectCaptureFile(path, func(*v1.DataCaptureMetadata, *v1.SensorData) error { return errStop })
+		test.That(t, err, test.ShouldEqual, errStop)
+		test.That(t, report.Readings, test.ShouldEqual, 1)
+	})
+}
+
+func TestBinaryReadingFileName(t *testing.T) {
+	md := &v1.DataCaptureMetadata{ComponentName: "cam", MethodName: "ReadImage", FileExtension: ".jpeg"}
+	sd := &v1.SensorData{Metadata: &v1.SensorMetadata{
+		TimeRequested: timestamppb.New(time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)),
+	}}
+	test.That(t, BinaryReadingFileName(md, sd), test.ShouldEqual, "cam_ReadImage_20240102T030405.000000006Z.jpeg")
+	md.FileExtension = ""
+	test.That(t, BinaryReadingFileName(md, sd), test.ShouldEqual, "cam_ReadImage_20240102T030405.000000006Z.bin")
+}
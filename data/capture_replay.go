@@ -0,0 +1,371 @@
+package data
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/pkg/errors"
+	v1 "go.viam.com/api/app/datasync/v1"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+)
+
+// ReplayMode determines how a CaptureReplay advances through the captured readings.
+type ReplayMode string
+
+const (
+	// ReplayModeStepped returns the next captured reading every time one is requested, regardless
+	// of how much time has passed.
+	ReplayModeStepped ReplayMode = "stepped"
+	// ReplayModeRealtime returns the reading that was captured at the same offset from the start of
+	// the recording as the time that has passed since playback started.
+	ReplayModeRealtime ReplayMode = "realtime"
+	// ReplayModeAccelerated is the same as ReplayModeRealtime, but time passes faster (or slower)
+	// by the configured speed factor.
+	ReplayModeAccelerated ReplayMode = "accelerated"
+)
+
+// ErrEndOfCaptures is returned when a CaptureReplay which does not loop has played back all of its readings.
+var ErrEndOfCaptures = errors.New("reached end of captured data")
+
+// ReplayConfig describes how captured readings are played back.
+type ReplayConfig struct {
+	Mode  ReplayMode
+	Speed float64
+	Loop  bool
+}
+
+// Validate ensures the playback parameters are valid.
+func (cfg *ReplayConfig) Validate() error {
+	switch cfg.Mode {
+	case "", ReplayModeStepped, ReplayModeRealtime:
+	case ReplayModeAccelerated:
+		if cfg.Speed <= 0 {
+			return errors.New("accelerated playback requires a speed greater than 0")
+		}
+	default:
+		return errors.Errorf("unknown playback mode %q, expected one of %q, %q or %q",
+			cfg.Mode, ReplayModeStepped, ReplayModeRealtime, ReplayModeAccelerated)
+	}
+	return nil
+}
+
+// ReplayReading is a single reading read back from a capture file.
+type ReplayReading struct {
+	TimeRequested time.Time
+	TimeReceived  time.Time
+	Metadata      *v1.DataCaptureMetadata
+	// Data is the reading. It is nil for the binary readings returned by ReadCaptureDir, such as images and
+	// point clouds, which SensorData reads back from their capture file instead of holding in memory.
+	Data *v1.SensorData
+
+	source *captureSource
+	index  int
+}
+
+// SensorData returns the reading, reading it back from its capture file if it is not held in Data.
+func (r ReplayReading) SensorData() (*v1.SensorData, error) {
+	if r.source == nil {
+		return r.Data, nil
+	}
+	return r.source.read(r.index)
+}
+
+// ReadCaptureDir reads the readings captured from the component out of every completed capture file in
+// dir and its subdirectories, other than the files sync failed to upload. Files which cannot be read are
+// logged and skipped. Readings are grouped by method and sorted by the time they were requested. Readings
+// requested outside of [start, end] are dropped; a zero time leaves that side of the interval open.
+func ReadCaptureDir(
+	dir string, component resource.Name, start, end time.Time, logger logging.Logger,
+) (map[string][]ReplayReading, error) {
+	readings := map[string][]ReplayReading{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == FailedDir && path != dir {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || filepath.Ext(path) != CompletedCaptureFileExt {
+			return nil
+		}
+		method, fileReadings, err := readCaptureFileReadings(path, component, start, end)
+		if err != nil {
+			logger.Warnw("skipping capture file which cannot be read", "path", path, "error", err)
+			return nil
+		}
+		if len(fileReadings) != 0 {
+			readings[method] = append(readings[method], fileReadings...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for method := range readings {
+		sort.SliceStable(readings[method], func(i, j int) bool {
+			return readings[method][i].TimeRequested.Before(readings[method][j].TimeRequested)
+		})
+	}
+	return readings, nil
+}
+
+// readCaptureFileReadings returns the method and the readings of the capture file at path if it was
+// captured from the component.
+func readCaptureFileReadings(path string, component resource.Name, start, end time.Time) (string, []ReplayReading, error) {
+	captureFile, f, err := openCaptureFileForReading(path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer closeCaptureFileForReading(captureFile, f)
+
+	md := captureFile.ReadMetadata()
+	if md.GetComponentType() != component.API.String() || md.GetComponentName() != component.ShortName() {
+		return "", nil, nil
+	}
+	source := &captureSource{path: path}
+	var readings []ReplayReading
+	for index := 0; ; index++ {
+		sd, err := captureFile.ReadNext()
+		if err != nil {
+			if errors.Is(err, ErrCorruptCaptureFile) {
+				// the readings after one which fails its checksum can still be read.
+				continue
+			}
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+			return "", nil, err
+		}
+		requested := sd.GetMetadata().GetTimeRequested().AsTime()
+		if (!start.IsZero() && requested.Before(start)) || (!end.IsZero() && requested.After(end)) {
+			continue
+		}
+		reading := ReplayReading{
+			TimeRequested: requested,
+			TimeReceived:  sd.GetMetadata().GetTimeReceived().AsTime(),
+			Metadata:      md,
+		}
+		if sd.GetBinary() != nil {
+			reading.source, reading.index = source, index
+		} else {
+			reading.Data = sd
+		}
+		readings = append(readings, reading)
+	}
+	return md.GetMethodName(), readings, nil
+}
+
+func openCaptureFileForReading(path string) (*CaptureFile, *os.File, error) {
+	//nolint:gosec
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	captureFile, err := ReadCaptureFile(f)
+	if err != nil {
+		//nolint:errcheck
+		f.Close()
+		return nil, nil, err
+	}
+	return captureFile, f, nil
+}
+
+// closeCaptureFileForReading closes a capture file which was only read from. Closing the file directly
+// avoids the rename done by CaptureFile.Close.
+func closeCaptureFileForReading(captureFile *CaptureFile, f *os.File) {
+	captureFile.Reset()
+	//nolint:errcheck
+	f.Close()
+}
+
+// captureSource reads the binary readings of a capture file back as they are played back. Playback mostly
+// moves forward, so the file is kept open after the last reading read rather than read from the start.
+type captureSource struct {
+	path string
+
+	mu          sync.Mutex
+	captureFile *CaptureFile
+	file        *os.File
+	// next is the index of the reading captureFile reads next.
+	next int
+}
+
+func (s *captureSource) read(index int) (*v1.SensorData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.captureFile == nil || index < s.next {
+		s.closeLocked()
+		captureFile, f, err := openCaptureFileForReading(s.path)
+		if err != nil {
+			return nil, err
+		}
+		s.captureFile, s.file, s.next = captureFile, f, 0
+	}
+	for ; s.next < index; s.next++ {
+		if _, err := s.captureFile.ReadNext(); err != nil && !errors.Is(err, ErrCorruptCaptureFile) {
+			s.closeLocked()
+			return nil, errors.Wrapf(err, "failed to read %s", s.path)
+		}
+	}
+	sd, err := s.captureFile.ReadNext()
+	if err != nil {
+		s.closeLocked()
+		return nil, errors.Wrapf(err, "failed to read %s", s.path)
+	}
+	s.next++
+	return sd, nil
+}
+
+func (s *captureSource) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closeLocked()
+}
+
+func (s *captureSource) closeLocked() {
+	if s.captureFile != nil {
+		closeCaptureFileForReading(s.captureFile, s.file)
+		s.captureFile, s.file = nil, nil
+	}
+}
+
+// CaptureReplay plays back readings read from capture files. All methods share a single playback
+// clock so that readings of different methods stay in sync with each other.
+type CaptureReplay struct {
+	mode  ReplayMode
+	speed float64
+	loop  bool
+	clock clock.Clock
+
+	mu       sync.Mutex
+	readings map[string][]ReplayReading
+	next     map[string]int
+	started  time.Time
+	first    time.Time
+	last     time.Time
+}
+
+// NewCaptureReplay returns a CaptureReplay which plays back the given readings, which must be
+// sorted by the time they were requested. Playback starts immediately.
+func NewCaptureReplay(readings map[string][]ReplayReading, cfg ReplayConfig, clk clock.Clock) (*CaptureReplay, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	r := &CaptureReplay{
+		mode:     cfg.Mode,
+		speed:    cfg.Speed,
+		loop:     cfg.Loop,
+		clock:    clk,
+		readings: readings,
+	}
+	if r.mode == "" {
+		r.mode = ReplayModeStepped
+	}
+	if r.mode == ReplayModeRealtime {
+		r.speed = 1
+	}
+
+	for _, methodReadings := range readings {
+		if len(methodReadings) == 0 {
+			continue
+		}
+		if r.first.IsZero() || methodReadings[0].TimeRequested.Before(r.first) {
+			r.first = methodReadings[0].TimeRequested
+		}
+		if end := methodReadings[len(methodReadings)-1].TimeRequested; end.After(r.last) {
+			r.last = end
+		}
+	}
+	r.Restart()
+	return r, nil
+}
+
+// Close closes the capture files binary readings are being read back from.
+func (r *CaptureReplay) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, methodReadings := range r.readings {
+		for _, reading := range methodReadings {
+			if reading.source != nil {
+				reading.source.close()
+			}
+		}
+	}
+}
+
+// HasMethod returns whether any readings were captured for the method.
+func (r *CaptureReplay) HasMethod(method string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.readings[method]) != 0
+}
+
+// Restart rewinds playback to the beginning of the recording.
+func (r *CaptureReplay) Restart() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.next = map[string]int{}
+	r.started = r.clock.Now()
+}
+
+// Next returns the next reading of the method according to the playback mode.
+func (r *CaptureReplay) Next(method string) (ReplayReading, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	methodReadings := r.readings[method]
+	if len(methodReadings) == 0 {
+		return ReplayReading{}, errors.Errorf("no captured data for method %s", method)
+	}
+
+	if r.mode == ReplayModeStepped {
+		i := r.next[method]
+		if i >= len(methodReadings) {
+			if !r.loop {
+				return ReplayReading{}, ErrEndOfCaptures
+			}
+			i = 0
+		}
+		r.next[method] = i + 1
+		return methodReadings[i], nil
+	}
+
+	playhead, err := r.playhead()
+	if err != nil {
+		return ReplayReading{}, err
+	}
+	// find the last reading requested at or before the playhead. Before the first reading of a
+	// method is due, the first reading is returned.
+	i := sort.Search(len(methodReadings), func(i int) bool {
+		return methodReadings[i].TimeRequested.After(playhead)
+	})
+	if i > 0 {
+		i--
+	}
+	return methodReadings[i], nil
+}
+
+// playhead returns the time within the recording that playback has reached. It assumes the lock is held.
+func (r *CaptureReplay) playhead() (time.Time, error) {
+	elapsed := time.Duration(float64(r.clock.Since(r.started)) * r.speed)
+	duration := r.last.Sub(r.first)
+	if elapsed > duration {
+		if !r.loop {
+			return time.Time{}, ErrEndOfCaptures
+		}
+		if duration == 0 {
+			elapsed = 0
+		} else {
+			elapsed %= duration
+		}
+	}
+	return r.first.Add(elapsed), nil
+}
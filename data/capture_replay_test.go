@@ -0,0 +1,220 @@
+package data
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	v1 "go.viam.com/api/app/datasync/v1"
+	"go.viam.com/test"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+)
+
+var replayEpoch = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+var replayMovementSensorAPI = resource.APINamespaceRDK.WithComponentType("movement_sensor")
+
+// writeTestCaptureFile writes one completed capture file containing a reading at each of the
+// given offsets from replayEpoch. Each reading holds its offset in seconds.
+func writeTestCaptureFile(t *testing.T, dir, componentName, method string, offsets ...time.Duration) {
+	t.Helper()
+	writeTestCaptureFileWithAPI(t, dir, replayMovementSensorAPI, componentName, method, offsets...)
+}
+
+func writeTestCaptureFileWithAPI(t *testing.T, dir string, api resource.API, componentName, method string, offsets ...time.Duration) {
+	t.Helper()
+	md := BuildCaptureMetadata(api, componentName, method, nil, nil, nil)
+	test.That(t, os.MkdirAll(dir, 0o700), test.ShouldBeNil)
+	f, err := NewCaptureFile(dir, md)
+	test.That(t, err, test.ShouldBeNil)
+	for _, offset := range offsets {
+		reading, err := structpb.NewStruct(map[string]interface{}{"offset": offset.Seconds()})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, f.WriteNext(&v1.SensorData{
+			Metadata: &v1.SensorMetadata{
+				TimeRequested: timestamppb.New(replayEpoch.Add(offset)),
+				TimeReceived:  timestamppb.New(replayEpoch.Add(offset + time.Millisecond)),
+			},
+			Data: &v1.SensorData_Struct{Struct: reading},
+		}), test.ShouldBeNil)
+	}
+	test.That(t, f.Close(), test.ShouldBeNil)
+}
+
+func offsetOf(t *testing.T, reading ReplayReading) float64 {
+	t.Helper()
+	return reading.Data.GetStruct().GetFields()["offset"].GetNumberValue()
+}
+
+func TestReadCaptureDir(t *testing.T) {
+	logger := logging.NewTestLogger(t)
+	gps := resource.NewName(replayMovementSensorAPI, "gps")
+	dir := t.TempDir()
+	writeTestCaptureFile(t, filepath.Join(dir, "a"), "gps", "Position", 2*time.Second, 3*time.Second)
+	writeTestCaptureFile(t, filepath.Join(dir, "b"), "gps", "Position", 0, time.Second)
+	writeTestCaptureFile(t, filepath.Join(dir, "b"), "gps", "CompassHeading", time.Second)
+	writeTestCaptureFile(t, filepath.Join(dir, "c"), "imu", "Orientation", time.Second)
+	// a sensor of another API which shares the name of the movement sensor is not read.
+	writeTestCaptureFileWithAPI(t, filepath.Join(dir, "d"), resource.APINamespaceRDK.WithComponentType("sensor"),
+		"gps", "Position", 5*time.Second)
+	// nor are the files sync failed to upload, or files which cannot be read.
+	writeTestCaptureFile(t, filepath.Join(dir, FailedDir, "a"), "gps", "Position", 6*time.Second)
+	test.That(t, os.WriteFile(filepath.Join(dir, "a", "unreadable"+CompletedCaptureFileExt), []byte("?"), 0o600), test.ShouldBeNil)
+
+	readings, err := ReadCaptureDir(dir, gps, time.Time{}, time.Time{}, logger)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(readings), test.ShouldEqual, 2)
+	test.That(t, len(readings["Position"]), test.ShouldEqual, 4)
+	for i, reading := range readings["Position"] {
+		test.That(t, offsetOf(t, reading), test.ShouldEqual, float64(i))
+		test.That(t, reading.Metadata.GetComponentName(), test.ShouldEqual, "gps")
+	}
+	test.That(t, len(readings["CompassHeading"]), test.ShouldEqual, 1)
+
+	readings, err = ReadCaptureDir(dir, gps, replayEpoch.Add(time.Second), replayEpoch.Add(2*time.Second), logger)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(readings["Position"]), test.ShouldEqual, 2)
+	test.That(t, offsetOf(t, readings["Position"][0]), test.ShouldEqual, 1)
+	test.That(t, offsetOf(t, readings["Position"][1]), test.ShouldEqual, 2)
+
+	readings, err = ReadCaptureDir(dir, resource.NewName(replayMovementSensorAPI, "missing"), time.Time{}, time.Time{}, logger)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, readings, test.ShouldBeEmpty)
+
+	_, err = ReadCaptureDir(filepath.Join(dir, "does-not-exist"), gps, time.Time{}, time.Time{}, logger)
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestReadCaptureDirBinary(t *testing.T) {
+	dir := t.TempDir()
+	lidar := resource.NewName(resource.APINamespaceRDK.WithComponentType("camera"), "lidar")
+	// both uncompressed files and compressed files, whose readings can only be read in order, are read back.
+	for file, options := range []CaptureFileOptions{{}, {Compression: CaptureFileCompressionZstd, Checksums: true}} {
+		md := BuildCaptureMetadata(lidar.API, lidar.Name, nextPointCloud, nil, nil, nil)
+		fileDir := filepath.Join(dir, strconv.Itoa(file))
+		test.That(t, os.MkdirAll(fileDir, 0o700), test.ShouldBeNil)
+		f, err := NewCaptureFileWithOptions(fileDir, md, options)
+		test.That(t, err, test.ShouldBeNil)
+		for i := 0; i < 3; i++ {
+			offset := time.Duration(3*file+i) * time.Second
+			test.That(t, f.WriteNext(&v1.SensorData{
+				Metadata: &v1.SensorMetadata{TimeRequested: timestamppb.New(replayEpoch.Add(offset))},
+				Data:     &v1.SensorData_Binary{Binary: []byte(offset.String())},
+			}), test.ShouldBeNil)
+		}
+		test.That(t, f.Close(), test.ShouldBeNil)
+	}
+
+	readings, err := ReadCaptureDir(dir, lidar, time.Time{}, time.Time{}, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	clouds := readings[nextPointCloud]
+	test.That(t, clouds, test.ShouldHaveLength, 6)
+	replay, err := NewCaptureReplay(readings, ReplayConfig{}, clock.NewMock())
+	test.That(t, err, test.ShouldBeNil)
+	defer replay.Close()
+
+	// binary readings are only read from their files when requested, in any order.
+	for _, i := range []int{0, 1, 2, 5, 4, 3, 0} {
+		test.That(t, clouds[i].Data, test.ShouldBeNil)
+		sd, err := clouds[i].SensorData()
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, string(sd.GetBinary()), test.ShouldEqual, clouds[i].TimeRequested.Sub(replayEpoch).String())
+	}
+}
+
+func TestCaptureReplay(t *testing.T) {
+	dir := t.TempDir()
+	writeTestCaptureFile(t, dir, "gps", "Position", 0, time.Second, 2*time.Second, 4*time.Second)
+	readings, err := ReadCaptureDir(dir, resource.NewName(replayMovementSensorAPI, "gps"), time.Time{}, time.Time{}, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+
+	t.Run("invalid config", func(t *testing.T) {
+		_, err := NewCaptureReplay(readings, ReplayConfig{Mode: "rewind"}, clock.NewMock())
+		test.That(t, err, test.ShouldNotBeNil)
+		_, err = NewCaptureReplay(readings, ReplayConfig{Mode: ReplayModeAccelerated}, clock.NewMock())
+		test.That(t, err, test.ShouldNotBeNil)
+	})
+
+	t.Run("stepped", func(t *testing.T) {
+		replay, err := NewCaptureReplay(readings, ReplayConfig{}, clock.NewMock())
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, replay.HasMethod("Position"), test.ShouldBeTrue)
+		test.That(t, replay.HasMethod("Orientation"), test.ShouldBeFalse)
+		_, err = replay.Next("Orientation")
+		test.That(t, err, test.ShouldNotBeNil)
+
+		for _, expected := range []float64{0, 1, 2, 4} {
+			reading, err := replay.Next("Position")
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, offsetOf(t, reading), test.ShouldEqual, expected)
+		}
+		_, err = replay.Next("Position")
+		test.That(t, err, test.ShouldBeError, ErrEndOfCaptures)
+
+		replay.Restart()
+		reading, err := replay.Next("Position")
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, offsetOf(t, reading), test.ShouldEqual, 0)
+	})
+
+	t.Run("stepped loop", func(t *testing.T) {
+		replay, err := NewCaptureReplay(readings, ReplayConfig{Mode: ReplayModeStepped, Loop: true}, clock.NewMock())
+		test.That(t, err, test.ShouldBeNil)
+		for _, expected := range []float64{0, 1, 2, 4, 0, 1} {
+			reading, err := replay.Next("Position")
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, offsetOf(t, reading), test.ShouldEqual, expected)
+		}
+	})
+
+	t.Run("realtime", func(t *testing.T) {
+		clk := clock.NewMock()
+		replay, err := NewCaptureReplay(readings, ReplayConfig{Mode: ReplayModeRealtime, Speed: 10}, clk)
+		test.That(t, err, test.ShouldBeNil)
+
+		// the speed only applies to accelerated playback.
+		for _, step := range []struct {
+			advance  time.Duration
+			expected float64
+		}{
+			{0, 0},
+			{500 * time.Millisecond, 0},
+			{500 * time.Millisecond, 1},
+			{1500 * time.Millisecond, 2},
+			{time.Second, 2},
+			{500 * time.Millisecond, 4},
+		} {
+			clk.Add(step.advance)
+			reading, err := replay.Next("Position")
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, offsetOf(t, reading), test.ShouldEqual, step.expected)
+		}
+		clk.Add(time.Second)
+		_, err = replay.Next("Position")
+		test.That(t, err, test.ShouldBeError, ErrEndOfCaptures)
+	})
+
+	t.Run("accelerated loop", func(t *testing.T) {
+		clk := clock.NewMock()
+		replay, err := NewCaptureReplay(readings, ReplayConfig{Mode: ReplayModeAccelerated, Speed: 4, Loop: true}, clk)
+		test.That(t, err, test.ShouldBeNil)
+
+		clk.Add(500 * time.Millisecond)
+		reading, err := replay.Next("Position")
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, offsetOf(t, reading), test.ShouldEqual, 2)
+
+		// 6 seconds into a 4 second recording is 2 seconds into the second loop.
+		clk.Add(time.Second)
+		reading, err = replay.Next("Position")
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, offsetOf(t, reading), test.ShouldEqual, 2)
+	})
+}
@@ -0,0 +1,133 @@
+package data
+
+import (
+	"sync"
+	"time"
+
+	v1 "go.viam.com/api/app/datasync/v1"
+)
+
+// TriggeredCaptureBuffer is a CaptureBufferedWriter which keeps the most recent SensorData in an in memory
+// ring buffer and only writes data to disk around trigger events. When a trigger fires, the data captured
+// during its pre-roll is written out, followed by everything captured until its post-roll has elapsed.
+// Each trigger writes to its own CaptureBufferedWriter so that its data can be told apart, typically by
+// tagging the capture metadata with the trigger name.
+type TriggeredCaptureBuffer struct {
+	dir       string
+	preRoll   time.Duration
+	newTarget func(trigger string) CaptureBufferedWriter
+
+	lock    sync.Mutex
+	ring    []*v1.SensorData
+	targets map[string]CaptureBufferedWriter
+	// active is the target of the window being recorded, or nil if no trigger has fired recently.
+	active        CaptureBufferedWriter
+	activeTrigger string
+	windowEnd     time.Time
+}
+
+// NewTriggeredCaptureBuffer returns a TriggeredCaptureBuffer which holds on to the data captured during the
+// last preRoll, and which creates the target of a trigger with newTarget the first time it fires.
+func NewTriggeredCaptureBuffer(
+	dir string,
+	preRoll time.Duration,
+	newTarget func(trigger string) CaptureBufferedWriter,
+) *TriggeredCaptureBuffer {
+	return &TriggeredCaptureBuffer{
+		dir:       dir,
+		preRoll:   preRoll,
+		newTarget: newTarget,
+		targets:   map[string]CaptureBufferedWriter{},
+	}
+}
+
+// Write writes item to the target of the current window if there is one, otherwise it adds item to the
+// ring buffer, dropping anything which was requested longer than the pre-roll before it.
+func (b *TriggeredCaptureBuffer) Write(item *v1.SensorData) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	requested := item.GetMetadata().GetTimeRequested().AsTime()
+	if b.active != nil {
+		if !requested.After(b.windowEnd) {
+			return b.active.Write(item)
+		}
+		// The window is over, so its file is completed rather than left in progress until the next one.
+		active := b.active
+		b.active = nil
+		if err := active.Flush(); err != nil {
+			return err
+		}
+	}
+
+	b.ring = append(b.ring, item)
+	oldest := requested.Add(-b.preRoll)
+	dropped := 0
+	for dropped < len(b.ring) && b.ring[dropped].GetMetadata().GetTimeRequested().AsTime().Before(oldest) {
+		dropped++
+	}
+	b.ring = b.ring[dropped:]
+	return nil
+}
+
+// Trigger opens a window of data around at: the data in the ring buffer requested at most preRoll before
+// at is written to the target of trigger, and so is all data requested up to postRoll after at. If the
+// window of the same trigger is already being recorded, it is extended to postRoll after at instead; the
+// window of a different trigger is completed, and the data from then on goes to the target of trigger.
+func (b *TriggeredCaptureBuffer) Trigger(trigger string, at time.Time, preRoll, postRoll time.Duration) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	end := at.Add(postRoll)
+	if b.active != nil {
+		if b.activeTrigger == trigger {
+			if end.After(b.windowEnd) {
+				b.windowEnd = end
+			}
+			return nil
+		}
+		active := b.active
+		b.active = nil
+		if err := active.Flush(); err != nil {
+			return err
+		}
+	}
+
+	target, ok := b.targets[trigger]
+	if !ok {
+		target = b.newTarget(trigger)
+		b.targets[trigger] = target
+	}
+	oldest := at.Add(-preRoll)
+	ring := b.ring
+	b.ring = nil
+	b.active = target
+	b.activeTrigger = trigger
+	b.windowEnd = end
+	for _, item := range ring {
+		if item.GetMetadata().GetTimeRequested().AsTime().Before(oldest) {
+			continue
+		}
+		if err := target.Write(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush flushes the targets of every trigger which has fired. Data in the ring buffer is not written.
+func (b *TriggeredCaptureBuffer) Flush() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for _, target := range b.targets {
+		if err := target.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Path returns the path to the directory containing the backing data capture files.
+func (b *TriggeredCaptureBuffer) Path() string {
+	return b.dir
+}
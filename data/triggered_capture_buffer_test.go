@@ -0,0 +1,90 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	v1 "go.viam.com/api/app/datasync/v1"
+	"go.viam.com/test"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type recordingWriter struct {
+	items   []*v1.SensorData
+	flushes int
+}
+
+func (w *recordingWriter) Write(item *v1.SensorData) error {
+	w.items = append(w.items, item)
+	return nil
+}
+
+func (w *recordingWriter) Flush() error {
+	w.flushes++
+	return nil
+}
+
+func (w *recordingWriter) Path() string {
+	return ""
+}
+
+func TestTriggeredCaptureBuffer(t *testing.T) {
+	start := time.Now()
+	reading := func(secs int) *v1.SensorData {
+		return &v1.SensorData{Metadata: &v1.SensorMetadata{
+			TimeRequested: timestamppb.New(start.Add(time.Duration(secs) * time.Second)),
+		}}
+	}
+	requested := func(items []*v1.SensorData) []int {
+		var secs []int
+		for _, item := range items {
+			secs = append(secs, int(item.GetMetadata().GetTimeRequested().AsTime().Sub(start)/time.Second))
+		}
+		return secs
+	}
+
+	targets := map[string]*recordingWriter{}
+	b := NewTriggeredCaptureBuffer("/some/dir", 3*time.Second, func(trigger string) CaptureBufferedWriter {
+		targets[trigger] = &recordingWriter{}
+		return targets[trigger]
+	})
+	test.That(t, b.Path(), test.ShouldEqual, "/some/dir")
+
+	// nothing is written until a trigger fires.
+	for secs := 0; secs < 10; secs++ {
+		test.That(t, b.Write(reading(secs)), test.ShouldBeNil)
+	}
+	test.That(t, b.Flush(), test.ShouldBeNil)
+	test.That(t, targets, test.ShouldBeEmpty)
+
+	// the pre-roll of the trigger is written from the ring buffer, which only holds the pre-roll of the buffer.
+	test.That(t, b.Trigger("bump", start.Add(9*time.Second), 2*time.Second, 2*time.Second), test.ShouldBeNil)
+	test.That(t, requested(targets["bump"].items), test.ShouldResemble, []int{7, 8, 9})
+
+	// the window is extended by the trigger firing again, then closed by the first reading past it.
+	for secs := 10; secs < 20; secs++ {
+		test.That(t, b.Write(reading(secs)), test.ShouldBeNil)
+		if secs == 10 {
+			test.That(t, b.Trigger("bump", start.Add(10*time.Second), 2*time.Second, 2*time.Second), test.ShouldBeNil)
+		}
+	}
+	test.That(t, requested(targets["bump"].items), test.ShouldResemble, []int{7, 8, 9, 10, 11, 12})
+	test.That(t, targets["bump"].flushes, test.ShouldEqual, 1)
+
+	// each trigger writes to its own target.
+	test.That(t, b.Trigger("manual", start.Add(19*time.Second), 10*time.Second, 0), test.ShouldBeNil)
+	test.That(t, requested(targets["manual"].items), test.ShouldResemble, []int{16, 17, 18, 19})
+	test.That(t, b.Write(reading(20)), test.ShouldBeNil)
+	test.That(t, len(targets["manual"].items), test.ShouldEqual, 4)
+
+	// a different trigger firing while a window is open completes that window and starts its own.
+	test.That(t, b.Trigger("bump", start.Add(21*time.Second), 0, 5*time.Second), test.ShouldBeNil)
+	test.That(t, b.Write(reading(21)), test.ShouldBeNil)
+	test.That(t, b.Trigger("manual", start.Add(22*time.Second), 0, time.Second), test.ShouldBeNil)
+	test.That(t, targets["bump"].flushes, test.ShouldEqual, 2)
+	for secs := 22; secs < 30; secs++ {
+		test.That(t, b.Write(reading(secs)), test.ShouldBeNil)
+	}
+	test.That(t, requested(targets["bump"].items), test.ShouldResemble, []int{7, 8, 9, 10, 11, 12, 21})
+	test.That(t, requested(targets["manual"].items), test.ShouldResemble, []int{16, 17, 18, 19, 22, 23})
+}
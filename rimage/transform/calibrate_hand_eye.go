@@ -0,0 +1,206 @@
+package transform
+
+import (
+	"math"
+
+	"github.com/golang/geo/r3"
+	"github.com/pkg/errors"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/num/quat"
+
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/utils"
+)
+
+// minHandEyeSamples is how many samples a hand-eye calibration needs, as two motions between them about
+// different axes are needed to determine the pose of the camera.
+const minHandEyeSamples = 3
+
+// HandEyeSetup is how a camera calibrated against an arm is mounted.
+type HandEyeSetup string
+
+const (
+	// EyeInHand is a camera mounted on the end effector of an arm, looking at a target fixed in the workcell.
+	EyeInHand HandEyeSetup = "eye_in_hand"
+	// EyeToHand is a camera fixed in the workcell, looking at a target held by the end effector of an arm.
+	EyeToHand HandEyeSetup = "eye_to_hand"
+)
+
+// HandEyeSample is the pose of the end effector of an arm relative to its base, as its EndPosition returns,
+// and the pose of the calibration target relative to the camera at the same time.
+type HandEyeSample struct {
+	EndPosition spatialmath.Pose
+	TargetPose  spatialmath.Pose
+}
+
+// HandEyeCalibration is the result of a hand-eye calibration.
+type HandEyeCalibration struct {
+	Setup HandEyeSetup
+	// CameraPose is the pose of the camera relative to the end effector of the arm for EyeInHand, or
+	// relative to the base of the arm for EyeToHand.
+	CameraPose spatialmath.Pose
+	// TargetPose is the pose of the target relative to the base of the arm for EyeInHand, or relative to
+	// the end effector for EyeToHand, as the samples agree on it.
+	TargetPose spatialmath.Pose
+	// TranslationErrorsMM and RotationErrorsDeg are how far the target is from TargetPose according to each
+	// sample and CameraPose, which is how well each sample agrees with the calibration.
+	TranslationErrorsMM []float64
+	RotationErrorsDeg   []float64
+	// RMSTranslationErrorMM and RMSRotationErrorDeg are the root mean square of those errors.
+	RMSTranslationErrorMM float64
+	RMSRotationErrorDeg   float64
+}
+
+// CalibrateHandEye solves for the pose of a camera relative to the arm it is mounted on or watching from
+// samples of the arm and the target in different poses, by the method of Park and Martin, "Robot Sensor
+// Calibration: Solving AX = XB on the Euclidean Group". The motions of the arm between samples must rotate
+// about at least two different axes, and samples which rotate it more calibrate it better.
+func CalibrateHandEye(samples []HandEyeSample, setup HandEyeSetup) (*HandEyeCalibration, error) {
+	if setup != EyeInHand && setup != EyeToHand {
+		return nil, errors.Errorf("unknown hand-eye setup %q, which must be %s or %s", setup, EyeInHand, EyeToHand)
+	}
+	if len(samples) < minHandEyeSamples {
+		return nil, errors.Errorf("need at least %d samples to calibrate, have %d", minHandEyeSamples, len(samples))
+	}
+	// hands are the poses of the end effector relative to the base, or the other way around for EyeToHand,
+	// so that in either setup hands[i] * camera * samples[i].TargetPose is the same pose of the target.
+	hands := make([]spatialmath.Pose, len(samples))
+	for i, s := range samples {
+		if s.EndPosition == nil || s.TargetPose == nil {
+			return nil, errors.Errorf("sample %d is missing a pose", i)
+		}
+		hands[i] = s.EndPosition
+		if setup == EyeToHand {
+			hands[i] = spatialmath.PoseInverse(s.EndPosition)
+		}
+	}
+
+	// every pair of samples gives a motion of the hand a and of the target seen from the camera b, which
+	// satisfy a * camera = camera * b.
+	type motion struct{ a, b spatialmath.Pose }
+	var motions []motion
+	for i := range samples {
+		for j := i + 1; j < len(samples); j++ {
+			motions = append(motions, motion{
+				a: spatialmath.PoseBetween(hands[j], hands[i]),
+				b: spatialmath.PoseBetweenInverse(samples[i].TargetPose, samples[j].TargetPose),
+			})
+		}
+	}
+
+	// the rotation axes of the motions are related by the rotation of the camera, found by fitting them.
+	m := mat.NewDense(3, 3, nil)
+	for _, mo := range motions {
+		alpha := spatialmath.QuatToR3AA(mo.a.Orientation().Quaternion())
+		beta := spatialmath.QuatToR3AA(mo.b.Orientation().Quaternion())
+		var outer mat.Dense
+		outer.Outer(1,
+			mat.NewVecDense(3, []float64{beta.X, beta.Y, beta.Z}),
+			mat.NewVecDense(3, []float64{alpha.X, alpha.Y, alpha.Z}))
+		m.Add(m, &outer)
+	}
+	var svd mat.SVD
+	if !svd.Factorize(m, mat.SVDFull) {
+		return nil, errors.New("cannot factorize hand-eye rotation system")
+	}
+	if values := svd.Values(nil); values[1] < 1e-3*values[0] {
+		return nil, errors.New("motions between the samples must rotate about at least two different axes")
+	}
+	var u, v, r mat.Dense
+	svd.UTo(&u)
+	svd.VTo(&v)
+	r.Mul(&v, u.T())
+	if mat.Det(&r) < 0 {
+		for i := 0; i < 3; i++ {
+			v.Set(i, 2, -v.At(i, 2))
+		}
+		r.Mul(&v, u.T())
+	}
+	rotation, err := spatialmath.NewRotationMatrix(mat.DenseCopyOf(r.T()).RawMatrix().Data)
+	if err != nil {
+		return nil, err
+	}
+
+	// then the translation of the camera solves (Ra - I) t = R tb - ta for every motion in least squares.
+	lhs := mat.NewDense(3*len(motions), 3, nil)
+	rhs := mat.NewVecDense(3*len(motions), nil)
+	for k, mo := range motions {
+		ra := mo.a.Orientation().RotationMatrix()
+		rhsK := rotate(rotation, mo.b.Point()).Sub(mo.a.Point())
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				// a spatialmath.RotationMatrix is the transpose of the rotation of its orientation.
+				value := ra.At(j, i)
+				if i == j {
+					value--
+				}
+				lhs.Set(3*k+i, j, value)
+			}
+		}
+		rhs.SetVec(3*k, rhsK.X)
+		rhs.SetVec(3*k+1, rhsK.Y)
+		rhs.SetVec(3*k+2, rhsK.Z)
+	}
+	var translation mat.VecDense
+	if err := translation.SolveVec(lhs, rhs); err != nil {
+		return nil, errors.Wrap(err, "cannot solve for the translation of the camera")
+	}
+	camera := spatialmath.NewPose(
+		r3.Vector{X: translation.AtVec(0), Y: translation.AtVec(1), Z: translation.AtVec(2)},
+		rotation,
+	)
+
+	// each sample puts the target somewhere, and the target is where they agree it is on average.
+	targets := make([]spatialmath.Pose, len(samples))
+	var sumPoint r3.Vector
+	var sumQuat quat.Number
+	for i, s := range samples {
+		targets[i] = spatialmath.Compose(spatialmath.Compose(hands[i], camera), s.TargetPose)
+		sumPoint = sumPoint.Add(targets[i].Point())
+		q := targets[i].Orientation().Quaternion()
+		if i > 0 && quatDot(q, targets[0].Orientation().Quaternion()) < 0 {
+			q = quat.Scale(-1, q)
+		}
+		sumQuat = quat.Add(sumQuat, q)
+	}
+	meanQuat := spatialmath.Quaternion(quat.Scale(1/quat.Abs(sumQuat), sumQuat))
+	result := &HandEyeCalibration{
+		Setup:      setup,
+		CameraPose: camera,
+		TargetPose: spatialmath.NewPose(sumPoint.Mul(1/float64(len(samples))), &meanQuat),
+	}
+	sumSqTranslation, sumSqRotation := 0.0, 0.0
+	for _, target := range targets {
+		translationErr := target.Point().Sub(result.TargetPose.Point()).Norm()
+		rotationErr := utils.RadToDeg(spatialmath.QuatToR3AA(
+			spatialmath.OrientationBetween(result.TargetPose.Orientation(), target.Orientation()).Quaternion()).Norm())
+		result.TranslationErrorsMM = append(result.TranslationErrorsMM, translationErr)
+		result.RotationErrorsDeg = append(result.RotationErrorsDeg, rotationErr)
+		sumSqTranslation += translationErr * translationErr
+		sumSqRotation += rotationErr * rotationErr
+	}
+	result.RMSTranslationErrorMM = math.Sqrt(sumSqTranslation / float64(len(samples)))
+	result.RMSRotationErrorDeg = math.Sqrt(sumSqRotation / float64(len(samples)))
+	return result, nil
+}
+
+func quatDot(a, b quat.Number) float64 {
+	return a.Real*b.Real + a.Imag*b.Imag + a.Jmag*b.Jmag + a.Kmag*b.Kmag
+}
+
+// LinkConfig returns the frame of the camera with the given name for the robot config, relative to parent.
+// For EyeInHand, parent is the name of the arm, whose frame is its end effector. For EyeToHand, parent is
+// the frame of the base of the arm, which is the name of the arm followed by "_origin".
+func (c *HandEyeCalibration) LinkConfig(id, parent string) (*referenceframe.LinkConfig, error) {
+	orientation, err := spatialmath.NewOrientationConfig(c.CameraPose.Orientation().OrientationVectorDegrees())
+	if err != nil {
+		return nil, err
+	}
+	return &referenceframe.LinkConfig{
+		ID:          id,
+		Translation: c.CameraPose.Point(),
+		Orientation: orientation,
+		Parent:      parent,
+	}, nil
+}
@@ -0,0 +1,129 @@
+package transform
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/spatialmath"
+)
+
+// handEyeEndPositions are poses of an end effector pointing down at a workcell from different angles.
+var handEyeEndPositions = []spatialmath.Pose{
+	spatialmath.NewPose(r3.Vector{X: 400, Y: 0, Z: 500}, orientationFromDegrees(180, 0, 0)),
+	spatialmath.NewPose(r3.Vector{X: 450, Y: 80, Z: 480}, orientationFromDegrees(165, 10, 20)),
+	spatialmath.NewPose(r3.Vector{X: 380, Y: -60, Z: 520}, orientationFromDegrees(-170, -15, -30)),
+	spatialmath.NewPose(r3.Vector{X: 420, Y: 40, Z: 450}, orientationFromDegrees(175, 20, 60)),
+	spatialmath.NewPose(r3.Vector{X: 350, Y: -20, Z: 550}, orientationFromDegrees(160, -5, -70)),
+}
+
+// handEyeSamples returns samples of an arm at handEyeEndPositions with the camera at camera relative to the
+// end effector, or to the base, looking at the target at target relative to the base, or to the end
+// effector, with noise of the given size added to the poses of the target.
+func handEyeSamples(setup HandEyeSetup, camera, target spatialmath.Pose, noiseMM, noiseDeg float64) []HandEyeSample {
+	noise := rand.New(rand.NewSource(1))
+	var samples []HandEyeSample
+	for _, end := range handEyeEndPositions {
+		var targetPose spatialmath.Pose
+		if setup == EyeInHand {
+			targetPose = spatialmath.Compose(spatialmath.PoseInverse(spatialmath.Compose(end, camera)), target)
+		} else {
+			targetPose = spatialmath.Compose(spatialmath.PoseInverse(camera), spatialmath.Compose(end, target))
+		}
+		jitter := spatialmath.NewPose(
+			r3.Vector{X: noise.NormFloat64() * noiseMM, Y: noise.NormFloat64() * noiseMM, Z: noise.NormFloat64() * noiseMM},
+			orientationFromDegrees(noise.NormFloat64()*noiseDeg, noise.NormFloat64()*noiseDeg, noise.NormFloat64()*noiseDeg),
+		)
+		samples = append(samples, HandEyeSample{EndPosition: end, TargetPose: spatialmath.Compose(targetPose, jitter)})
+	}
+	return samples
+}
+
+func TestCalibrateHandEye(t *testing.T) {
+	t.Run("eye in hand", func(t *testing.T) {
+		// a camera to the side of the flange looking along it.
+		camera := spatialmath.NewPose(r3.Vector{X: 60, Y: -20, Z: 40}, orientationFromDegrees(0, 0, 90))
+		target := spatialmath.NewPose(r3.Vector{X: 420, Y: 20, Z: 0}, orientationFromDegrees(0, 0, 30))
+		calib, err := CalibrateHandEye(handEyeSamples(EyeInHand, camera, target, 0, 0), EyeInHand)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, spatialmath.PoseAlmostEqualEps(calib.CameraPose, camera, 1e-6), test.ShouldBeTrue)
+		test.That(t, spatialmath.PoseAlmostEqualEps(calib.TargetPose, target, 1e-6), test.ShouldBeTrue)
+		test.That(t, calib.RMSTranslationErrorMM, test.ShouldBeLessThan, 1e-6)
+		test.That(t, calib.RMSRotationErrorDeg, test.ShouldBeLessThan, 1e-6)
+
+		calib, err = CalibrateHandEye(handEyeSamples(EyeInHand, camera, target, 0.5, 0.2), EyeInHand)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, spatialmath.PoseAlmostCoincidentEps(calib.CameraPose, camera, 3), test.ShouldBeTrue)
+		test.That(t, spatialmath.OrientationAlmostEqualEps(calib.CameraPose.Orientation(), camera.Orientation(), 0.01),
+			test.ShouldBeTrue)
+		test.That(t, calib.TranslationErrorsMM, test.ShouldHaveLength, len(handEyeEndPositions))
+		test.That(t, calib.RMSTranslationErrorMM, test.ShouldBeBetween, 0.1, 5)
+		test.That(t, calib.RMSRotationErrorDeg, test.ShouldBeBetween, 0.01, 1)
+
+		link, err := calib.LinkConfig("cam", "arm")
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, link.Parent, test.ShouldEqual, "arm")
+		pose, err := link.Pose()
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, spatialmath.PoseAlmostEqualEps(pose, calib.CameraPose, 1e-3), test.ShouldBeTrue)
+	})
+
+	t.Run("eye to hand", func(t *testing.T) {
+		// a camera above the workcell looking down, and a target held below the flange.
+		camera := spatialmath.NewPose(r3.Vector{X: 400, Y: 600, Z: 900}, orientationFromDegrees(-150, 0, 0))
+		target := spatialmath.NewPose(r3.Vector{X: 10, Y: 0, Z: 80}, orientationFromDegrees(0, 180, 0))
+		calib, err := CalibrateHandEye(handEyeSamples(EyeToHand, camera, target, 0, 0), EyeToHand)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, spatialmath.PoseAlmostEqualEps(calib.CameraPose, camera, 1e-6), test.ShouldBeTrue)
+		test.That(t, spatialmath.PoseAlmostEqualEps(calib.TargetPose, target, 1e-6), test.ShouldBeTrue)
+	})
+
+	t.Run("invalid samples", func(t *testing.T) {
+		camera := spatialmath.NewPose(r3.Vector{X: 60}, orientationFromDegrees(0, 0, 90))
+		samples := handEyeSamples(EyeInHand, camera, spatialmath.NewZeroPose(), 0, 0)
+		_, err := CalibrateHandEye(samples, "eye_on_hand")
+		test.That(t, err, test.ShouldNotBeNil)
+		_, err = CalibrateHandEye(samples[:2], EyeInHand)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "at least 3 samples")
+
+		// turning the end effector only about its own axis does not determine the camera.
+		var turns []spatialmath.Pose
+		for _, yaw := range []float64{0, 30, 60, 90} {
+			turns = append(turns, spatialmath.NewPose(r3.Vector{X: 400, Z: 500}, orientationFromDegrees(180, 0, yaw)))
+		}
+		var samples2 []HandEyeSample
+		for _, end := range turns {
+			samples2 = append(samples2, HandEyeSample{
+				EndPosition: end,
+				TargetPose:  spatialmath.PoseInverse(spatialmath.Compose(end, camera)),
+			})
+		}
+		_, err = CalibrateHandEye(samples2, EyeInHand)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "two different axes")
+	})
+}
+
+func TestEstimateTargetPose(t *testing.T) {
+	cb := &Checkerboard{Cols: 9, Rows: 6, SquareSizeMM: 25}
+	v := boardView{r2.Point{X: 100, Y: 62.5}, orientationFromDegrees(20, -15, 10), r3.Vector{X: 30, Y: -20, Z: 480}}
+	view := CalibrationView{ObjectPoints: cb.ObjectPoints()}
+	for _, p := range view.ObjectPoints {
+		view.ImagePoints = append(view.ImagePoints, v.project(p, testCalibIntrinsics, testCalibDistortion))
+	}
+	pose, err := EstimateTargetPose(view, testCalibIntrinsics, testCalibDistortion)
+	test.That(t, err, test.ShouldBeNil)
+	// the origin of the object points is the top left corner of the board.
+	expected := spatialmath.NewPose(v.toCamera(r2.Point{}), v.orientation)
+	test.That(t, spatialmath.PoseAlmostEqualEps(pose, expected, 1e-3), test.ShouldBeTrue)
+
+	_, err = EstimateTargetPose(view, nil, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = EstimateTargetPose(CalibrationView{ObjectPoints: view.ObjectPoints[:4], ImagePoints: view.ImagePoints[:4]},
+		testCalibIntrinsics, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+}
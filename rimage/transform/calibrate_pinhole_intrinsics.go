@@ -0,0 +1,461 @@
+package transform
+
+import (
+	"image"
+	"math"
+
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
+	"github.com/pkg/errors"
+	"gonum.org/v1/gonum/mat"
+
+	"go.viam.com/rdk/spatialmath"
+)
+
+const (
+	maxCalibrationIterations = 100
+	// minViewPoints is how many corners a view must have to be calibrated with.
+	minViewPoints = 6
+)
+
+// CalibrationView is the corners of a planar calibration target found in one image.
+type CalibrationView struct {
+	// ImagePoints are the corners in the image, in pixels.
+	ImagePoints []r2.Point
+	// ObjectPoints are the positions of the same corners on the target, in mm.
+	ObjectPoints []r2.Point
+}
+
+// A CalibrationTarget is a planar target whose corners can be found in images, such as a checkerboard.
+type CalibrationTarget interface {
+	FindCorners(img image.Image) (CalibrationView, error)
+}
+
+// IntrinsicCalibrationOptions are the options of an intrinsic calibration.
+type IntrinsicCalibrationOptions struct {
+	// EstimateK3 estimates the third radial distortion coefficient too, which only lenses with strong
+	// distortion need. Otherwise it is zero.
+	EstimateK3 bool
+}
+
+// IntrinsicCalibration is the result of an intrinsic calibration.
+type IntrinsicCalibration struct {
+	Intrinsics *PinholeCameraIntrinsics `json:"intrinsic_parameters"`
+	Distortion *BrownConrady            `json:"distortion_parameters"`
+	// RMSError is the root mean square distance between the corners of every view and where the
+	// calibration projects them, in pixels.
+	RMSError float64 `json:"rms_error_px"`
+	// ViewErrors is the root mean square reprojection error of each view, in pixels.
+	ViewErrors []float64 `json:"view_errors_px"`
+	// TargetPoses are the poses of the target in each view relative to the camera, in mm.
+	TargetPoses []spatialmath.Pose `json:"-"`
+}
+
+// CalibratePinholeIntrinsics estimates the intrinsics and Brown-Conrady distortion of a camera from views of
+// a planar target in width by height images, following Zhang's method: a closed form estimate of the
+// intrinsics from the homographies of the views, refined together with the distortion and the pose of each
+// view by minimizing the reprojection error with Levenberg-Marquardt. At least 3 views from different
+// angles are needed for a reliable calibration.
+func CalibratePinholeIntrinsics(
+	views []CalibrationView, width, height int, opts *IntrinsicCalibrationOptions,
+) (*IntrinsicCalibration, error) {
+	if opts == nil {
+		opts = &IntrinsicCalibrationOptions{}
+	}
+	if width <= 0 || height <= 0 {
+		return nil, errors.Errorf("invalid image size %dx%d", width, height)
+	}
+	if len(views) < 2 {
+		return nil, errors.Errorf("need at least 2 views to calibrate, have %d", len(views))
+	}
+	homographies := make([]*mat.Dense, len(views))
+	for i, v := range views {
+		if len(v.ImagePoints) != len(v.ObjectPoints) {
+			return nil, errors.Errorf("view %d has %d image points but %d object points",
+				i, len(v.ImagePoints), len(v.ObjectPoints))
+		}
+		if len(v.ImagePoints) < minViewPoints {
+			return nil, errors.Errorf("view %d has %d points but needs at least %d", i, len(v.ImagePoints), minViewPoints)
+		}
+		h, err := planarHomography(v.ObjectPoints, v.ImagePoints)
+		if err != nil {
+			return nil, errors.Wrapf(err, "view %d", i)
+		}
+		homographies[i] = h
+	}
+
+	k, err := zhangIntrinsics(homographies, width, height)
+	if err != nil || k.Ppx < 0 || k.Ppx > float64(width) || k.Ppy < 0 || k.Ppy > float64(height) {
+		k = centeredIntrinsics(homographies, width, height)
+	}
+
+	// the parameters are the intrinsics, the distortion, and the rotation vector and translation of each view.
+	params := []float64{k.Fx, k.Fy, k.Ppx, k.Ppy, 0, 0, 0, 0, 0}
+	for _, h := range homographies {
+		rotation, translation := homographyPose(h, k)
+		params = append(params, rotation.X, rotation.Y, rotation.Z, translation.X, translation.Y, translation.Z)
+	}
+	free := make([]bool, len(params))
+	for i := range free {
+		free[i] = i != 8 || opts.EstimateK3
+	}
+	params = levenbergMarquardt(params, free, views)
+
+	result := &IntrinsicCalibration{
+		Intrinsics: &PinholeCameraIntrinsics{
+			Width: width, Height: height,
+			Fx: params[0], Fy: params[1], Ppx: params[2], Ppy: params[3],
+		},
+		Distortion: &BrownConrady{
+			RadialK1: params[4], RadialK2: params[5], RadialK3: params[8],
+			TangentialP1: params[6], TangentialP2: params[7],
+		},
+	}
+	if err := result.Intrinsics.CheckValid(); err != nil {
+		return nil, errors.Wrap(err, "calibration did not converge")
+	}
+	sumSq, n := 0.0, 0
+	for i, v := range views {
+		res := viewResiduals(params, i, v, nil)
+		viewSq := 0.0
+		for _, r := range res {
+			viewSq += r * r
+		}
+		result.ViewErrors = append(result.ViewErrors, math.Sqrt(viewSq/float64(len(v.ImagePoints))))
+		sumSq += viewSq
+		n += len(v.ImagePoints)
+		vp := params[9+6*i:]
+		result.TargetPoses = append(result.TargetPoses, spatialmath.NewPose(
+			r3.Vector{X: vp[3], Y: vp[4], Z: vp[5]},
+			spatialmath.R3ToR4(r3.Vector{X: vp[0], Y: vp[1], Z: vp[2]})))
+	}
+	result.RMSError = math.Sqrt(sumSq / float64(n))
+	return result, nil
+}
+
+// EstimateTargetPose returns the pose of a planar target relative to a camera with known intrinsics and
+// distortion, which may be nil, from a view of it. The pose is of the origin of the object points of the
+// view, with x and y along theirs and z away from the camera when it faces the camera.
+func EstimateTargetPose(view CalibrationView, intrinsics *PinholeCameraIntrinsics, distortion *BrownConrady) (spatialmath.Pose, error) {
+	if intrinsics == nil {
+		return nil, errors.New("need intrinsics to estimate the pose of a target")
+	}
+	if len(view.ImagePoints) != len(view.ObjectPoints) {
+		return nil, errors.Errorf("view has %d image points but %d object points", len(view.ImagePoints), len(view.ObjectPoints))
+	}
+	if len(view.ImagePoints) < minViewPoints {
+		return nil, errors.Errorf("view has %d points but needs at least %d", len(view.ImagePoints), minViewPoints)
+	}
+	if distortion == nil {
+		distortion = &BrownConrady{}
+	}
+	h, err := planarHomography(view.ObjectPoints, view.ImagePoints)
+	if err != nil {
+		return nil, err
+	}
+	rotation, translation := homographyPose(h, intrinsics)
+	params := []float64{
+		intrinsics.Fx, intrinsics.Fy, intrinsics.Ppx, intrinsics.Ppy,
+		distortion.RadialK1, distortion.RadialK2, distortion.TangentialP1, distortion.TangentialP2, distortion.RadialK3,
+		rotation.X, rotation.Y, rotation.Z, translation.X, translation.Y, translation.Z,
+	}
+	free := make([]bool, len(params))
+	for i := 9; i < len(free); i++ {
+		free[i] = true
+	}
+	params = levenbergMarquardt(params, free, []CalibrationView{view})
+	return spatialmath.NewPose(
+		r3.Vector{X: params[12], Y: params[13], Z: params[14]},
+		spatialmath.R3ToR4(r3.Vector{X: params[9], Y: params[10], Z: params[11]}),
+	), nil
+}
+
+// normalizingTransform returns the similarity which moves the centroid of points to the origin and scales
+// their mean distance from it to sqrt(2).
+func normalizingTransform(points []r2.Point) *mat.Dense {
+	var centroid r2.Point
+	for _, p := range points {
+		centroid = centroid.Add(p)
+	}
+	centroid = centroid.Mul(1 / float64(len(points)))
+	meanDist := 0.0
+	for _, p := range points {
+		meanDist += p.Sub(centroid).Norm()
+	}
+	meanDist /= float64(len(points))
+	s := math.Sqrt2 / math.Max(meanDist, 1e-12)
+	return mat.NewDense(3, 3, []float64{s, 0, -s * centroid.X, 0, s, -s * centroid.Y, 0, 0, 1})
+}
+
+// planarHomography estimates the homography from src to dst by the normalized direct linear transform.
+func planarHomography(src, dst []r2.Point) (*mat.Dense, error) {
+	t1, t2 := normalizingTransform(src), normalizingTransform(dst)
+	a := mat.NewDense(2*len(src), 9, nil)
+	for i := range src {
+		x := t1.At(0, 0)*src[i].X + t1.At(0, 2)
+		y := t1.At(1, 1)*src[i].Y + t1.At(1, 2)
+		u := t2.At(0, 0)*dst[i].X + t2.At(0, 2)
+		v := t2.At(1, 1)*dst[i].Y + t2.At(1, 2)
+		a.SetRow(2*i, []float64{x, y, 1, 0, 0, 0, -u * x, -u * y, -u})
+		a.SetRow(2*i+1, []float64{0, 0, 0, x, y, 1, -v * x, -v * y, -v})
+	}
+	var svd mat.SVD
+	if !svd.Factorize(a, mat.SVDFullV) {
+		return nil, errors.New("cannot factorize homography system")
+	}
+	if values := svd.Values(nil); values[7] < 1e-9*values[0] {
+		return nil, errors.New("points are degenerate, such as all on one line")
+	}
+	var v mat.Dense
+	svd.VTo(&v)
+	hn := mat.NewDense(3, 3, mat.Col(nil, 8, &v))
+	var t2inv, tmp, h mat.Dense
+	if err := t2inv.Inverse(t2); err != nil {
+		return nil, err
+	}
+	tmp.Mul(&t2inv, hn)
+	h.Mul(&tmp, t1)
+	h.Scale(1/h.At(2, 2), &h)
+	return &h, nil
+}
+
+// zhangIntrinsics estimates the intrinsics of a camera without skew in closed form from the homographies of
+// views of a plane, from "A Flexible New Technique for Camera Calibration" by Zhengyou Zhang.
+func zhangIntrinsics(homographies []*mat.Dense, width, height int) (*PinholeCameraIntrinsics, error) {
+	// pixels are scaled to about unit size around the center of the image for the sake of conditioning.
+	s := 2 / float64(width+height)
+	n := mat.NewDense(3, 3, []float64{s, 0, -s * float64(width) / 2, 0, s, -s * float64(height) / 2, 0, 0, 1})
+	v := func(h *mat.Dense, i, j int) []float64 {
+		return []float64{
+			h.At(0, i) * h.At(0, j),
+			h.At(0, i)*h.At(1, j) + h.At(1, i)*h.At(0, j),
+			h.At(1, i) * h.At(1, j),
+			h.At(2, i)*h.At(0, j) + h.At(0, i)*h.At(2, j),
+			h.At(2, i)*h.At(1, j) + h.At(1, i)*h.At(2, j),
+			h.At(2, i) * h.At(2, j),
+		}
+	}
+	a := mat.NewDense(2*len(homographies)+1, 6, nil)
+	for k, raw := range homographies {
+		var h mat.Dense
+		h.Mul(n, raw)
+		// normalize the scale of each homography so every view weighs the same.
+		h.Scale(1/math.Hypot(math.Hypot(h.At(0, 0), h.At(1, 0)), h.At(2, 0)), &h)
+		v12, v11, v22 := v(&h, 0, 1), v(&h, 0, 0), v(&h, 1, 1)
+		a.SetRow(2*k, v12)
+		for i := range v11 {
+			v11[i] -= v22[i]
+		}
+		a.SetRow(2*k+1, v11)
+	}
+	// no skew.
+	a.SetRow(2*len(homographies), []float64{0, 1, 0, 0, 0, 0})
+	var svd mat.SVD
+	if !svd.Factorize(a, mat.SVDFullV) {
+		return nil, errors.New("cannot factorize intrinsics system")
+	}
+	var vt mat.Dense
+	svd.VTo(&vt)
+	b := mat.Col(nil, 5, &vt)
+	if b[0] < 0 {
+		for i := range b {
+			b[i] = -b[i]
+		}
+	}
+	b11, b12, b22, b13, b23, b33 := b[0], b[1], b[2], b[3], b[4], b[5]
+	denom := b11*b22 - b12*b12
+	v0 := (b12*b13 - b11*b23) / denom
+	lambda := b33 - (b13*b13+v0*(b12*b13-b11*b23))/b11
+	alpha := math.Sqrt(lambda / b11)
+	beta := math.Sqrt(lambda * b11 / denom)
+	u0 := -b13 * alpha * alpha / lambda
+	if denom <= 0 || lambda <= 0 || math.IsNaN(alpha) || math.IsNaN(beta) {
+		return nil, errors.New("views do not constrain the intrinsics, such as when they all face the camera")
+	}
+	return &PinholeCameraIntrinsics{
+		Width: width, Height: height,
+		Fx: alpha / s, Fy: beta / s,
+		Ppx: u0/s + float64(width)/2, Ppy: v0/s + float64(height)/2,
+	}, nil
+}
+
+// centeredIntrinsics estimates the focal length of a camera whose principal point is at the center of the
+// image, for when the views do not constrain all the intrinsics.
+func centeredIntrinsics(homographies []*mat.Dense, width, height int) *PinholeCameraIntrinsics {
+	cx, cy := float64(width)/2, float64(height)/2
+	// with the principal point at the origin, the columns h1, h2 of each homography satisfy
+	// (h11*h12 + h21*h22)/f^2 + h31*h32 = 0 and (h11^2 + h21^2 - h12^2 - h22^2)/f^2 + h31^2 - h32^2 = 0.
+	var num, den float64
+	for _, raw := range homographies {
+		h := mat.DenseCopyOf(raw)
+		for j := 0; j < 3; j++ {
+			h.Set(0, j, h.At(0, j)-cx*h.At(2, j))
+			h.Set(1, j, h.At(1, j)-cy*h.At(2, j))
+		}
+		for _, eq := range [][2]float64{
+			{h.At(0, 0)*h.At(0, 1) + h.At(1, 0)*h.At(1, 1), h.At(2, 0) * h.At(2, 1)},
+			{
+				h.At(0, 0)*h.At(0, 0) + h.At(1, 0)*h.At(1, 0) - h.At(0, 1)*h.At(0, 1) - h.At(1, 1)*h.At(1, 1),
+				h.At(2, 0)*h.At(2, 0) - h.At(2, 1)*h.At(2, 1),
+			},
+		} {
+			num += eq[0] * eq[0]
+			den -= eq[0] * eq[1]
+		}
+	}
+	// views which all face the camera do not constrain the focal length, so it is taken to be about the size
+	// of the image unless the views suggest a plausible one.
+	f := float64(max(width, height))
+	if den > 0 && num/den > f*f/100 && num/den < 100*f*f {
+		f = math.Sqrt(num / den)
+	}
+	return &PinholeCameraIntrinsics{Width: width, Height: height, Fx: f, Fy: f, Ppx: cx, Ppy: cy}
+}
+
+// homographyPose returns the rotation vector and translation of a plane whose homography to the image is h.
+func homographyPose(h *mat.Dense, k *PinholeCameraIntrinsics) (r3.Vector, r3.Vector) {
+	var kinv, m mat.Dense
+	kinv.CloneFrom(mat.NewDense(3, 3, []float64{
+		1 / k.Fx, 0, -k.Ppx / k.Fx,
+		0, 1 / k.Fy, -k.Ppy / k.Fy,
+		0, 0, 1,
+	}))
+	m.Mul(&kinv, h)
+	c1 := r3.Vector{X: m.At(0, 0), Y: m.At(1, 0), Z: m.At(2, 0)}
+	c2 := r3.Vector{X: m.At(0, 1), Y: m.At(1, 1), Z: m.At(2, 1)}
+	t := r3.Vector{X: m.At(0, 2), Y: m.At(1, 2), Z: m.At(2, 2)}
+	scale := 2 / (c1.Norm() + c2.Norm())
+	if t.Z < 0 {
+		scale = -scale
+	}
+	c1, c2, t = c1.Mul(scale), c2.Mul(scale), t.Mul(scale)
+	c3 := c1.Cross(c2)
+	var svd mat.SVD
+	svd.Factorize(mat.NewDense(3, 3, []float64{c1.X, c2.X, c3.X, c1.Y, c2.Y, c3.Y, c1.Z, c2.Z, c3.Z}), mat.SVDFull)
+	var u, vt, r mat.Dense
+	svd.UTo(&u)
+	svd.VTo(&vt)
+	r.Mul(&u, vt.T())
+	// a spatialmath.RotationMatrix holds the inverse of the rotation its orientation composes poses with.
+	rm, err := spatialmath.NewRotationMatrix(mat.DenseCopyOf(r.T()).RawMatrix().Data)
+	if err != nil {
+		return r3.Vector{}, t
+	}
+	return rm.AxisAngles().ToR3(), t
+}
+
+// viewResiduals appends the differences between the image points of a view and where the parameters
+// project its object points.
+func viewResiduals(params []float64, i int, view CalibrationView, res []float64) []float64 {
+	distortion := &BrownConrady{
+		RadialK1: params[4], RadialK2: params[5], RadialK3: params[8],
+		TangentialP1: params[6], TangentialP2: params[7],
+	}
+	vp := params[9+6*i:]
+	rotation := spatialmath.R3ToR4(r3.Vector{X: vp[0], Y: vp[1], Z: vp[2]}).RotationMatrix()
+	for k, p := range view.ObjectPoints {
+		q := rotate(rotation, r3.Vector{X: p.X, Y: p.Y}).Add(r3.Vector{X: vp[3], Y: vp[4], Z: vp[5]})
+		x, y := distortion.Transform(q.X/q.Z, q.Y/q.Z)
+		res = append(res, params[0]*x+params[2]-view.ImagePoints[k].X, params[1]*y+params[3]-view.ImagePoints[k].Y)
+	}
+	return res
+}
+
+// rotate rotates v as composing poses with the orientation of rm does, which is by the transpose of rm.
+func rotate(rm *spatialmath.RotationMatrix, v r3.Vector) r3.Vector {
+	return r3.Vector{X: rm.Col(0).Dot(v), Y: rm.Col(1).Dot(v), Z: rm.Col(2).Dot(v)}
+}
+
+// levenbergMarquardt minimizes the reprojection error of the views over the free parameters, with a
+// jacobian by forward differences which exploits that the parameters of a view only affect its points.
+func levenbergMarquardt(params []float64, free []bool, views []CalibrationView) []float64 {
+	offsets := make([]int, len(views)+1)
+	for i, v := range views {
+		offsets[i+1] = offsets[i] + 2*len(v.ImagePoints)
+	}
+	residuals := func(p []float64) []float64 {
+		res := make([]float64, 0, offsets[len(views)])
+		for i, v := range views {
+			res = viewResiduals(p, i, v, res)
+		}
+		return res
+	}
+	cost := func(res []float64) float64 {
+		c := 0.0
+		for _, r := range res {
+			c += r * r
+		}
+		return c
+	}
+
+	res := residuals(params)
+	current := cost(res)
+	lambda := 1e-3
+	nParams := len(params)
+	for iter := 0; iter < maxCalibrationIterations; iter++ {
+		jacobian := mat.NewDense(len(res), nParams, nil)
+		for j := 0; j < nParams; j++ {
+			if !free[j] {
+				continue
+			}
+			step := 1e-6 * math.Max(1, math.Abs(params[j]))
+			perturbed := append([]float64(nil), params...)
+			perturbed[j] += step
+			if j < 9 {
+				for i, r := range residuals(perturbed) {
+					jacobian.Set(i, j, (r-res[i])/step)
+				}
+				continue
+			}
+			view := (j - 9) / 6
+			for k, r := range viewResiduals(perturbed, view, views[view], nil) {
+				row := offsets[view] + k
+				jacobian.Set(row, j, (r-res[row])/step)
+			}
+		}
+		var jtj mat.Dense
+		jtj.Mul(jacobian.T(), jacobian)
+		var jtr mat.VecDense
+		jtr.MulVec(jacobian.T(), mat.NewVecDense(len(res), res))
+
+		improved := false
+		for lambda < 1e12 {
+			damped := mat.DenseCopyOf(&jtj)
+			for j := 0; j < nParams; j++ {
+				if !free[j] {
+					damped.Set(j, j, 1)
+					continue
+				}
+				damped.Set(j, j, jtj.At(j, j)*(1+lambda)+1e-12)
+			}
+			var delta mat.VecDense
+			if err := delta.SolveVec(damped, &jtr); err != nil {
+				// an ill conditioned step, as when the views barely constrain some parameter, is still a step.
+				var condition mat.Condition
+				if !errors.As(err, &condition) {
+					lambda *= 10
+					continue
+				}
+			}
+			next := append([]float64(nil), params...)
+			for j := range next {
+				if free[j] {
+					next[j] -= delta.AtVec(j)
+				}
+			}
+			nextRes := residuals(next)
+			if nextCost := cost(nextRes); nextCost < current && !math.IsNaN(nextCost) {
+				converged := current-nextCost < 1e-12*current
+				params, res, current = next, nextRes, nextCost
+				lambda = math.Max(lambda/10, 1e-12)
+				improved = !converged
+				break
+			}
+			lambda *= 10
+		}
+		if !improved {
+			break
+		}
+	}
+	return params
+}
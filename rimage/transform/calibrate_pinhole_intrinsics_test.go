@@ -0,0 +1,123 @@
+package transform
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/spatialmath"
+)
+
+// testCalibViews are tilted by the inverses of the angles, which spreads their corners over more of the
+// image than tilting them by the angles does.
+var testCalibViews = []boardView{
+	{r2.Point{X: 100, Y: 62.5}, inverseOrientationFromDegrees(0, 0, 0), r3.Vector{Z: 500}},
+	{r2.Point{X: 100, Y: 62.5}, inverseOrientationFromDegrees(25, 0, 5), r3.Vector{X: 30, Y: 20, Z: 480}},
+	{r2.Point{X: 100, Y: 62.5}, inverseOrientationFromDegrees(-20, 20, 0), r3.Vector{X: -40, Y: 10, Z: 520}},
+	{r2.Point{X: 100, Y: 62.5}, inverseOrientationFromDegrees(0, -30, -10), r3.Vector{X: 50, Y: -30, Z: 450}},
+	{r2.Point{X: 100, Y: 62.5}, inverseOrientationFromDegrees(15, 25, 170), r3.Vector{X: -20, Y: -40, Z: 550}},
+	{r2.Point{X: 100, Y: 62.5}, inverseOrientationFromDegrees(-25, -15, 90), r3.Vector{X: 10, Y: 30, Z: 400}},
+}
+
+// inverseOrientationFromDegrees returns the inverse of orientationFromDegrees(x, y, z).
+func inverseOrientationFromDegrees(x, y, z float64) spatialmath.Orientation {
+	return spatialmath.PoseInverse(spatialmath.NewPoseFromOrientation(orientationFromDegrees(x, y, z))).Orientation()
+}
+
+func checkCalibration(t *testing.T, calib *IntrinsicCalibration, tolerancePx, toleranceDistortion float64) {
+	t.Helper()
+	k := calib.Intrinsics
+	test.That(t, k.Width, test.ShouldEqual, testCalibIntrinsics.Width)
+	test.That(t, k.Height, test.ShouldEqual, testCalibIntrinsics.Height)
+	test.That(t, k.Fx, test.ShouldAlmostEqual, testCalibIntrinsics.Fx, tolerancePx)
+	test.That(t, k.Fy, test.ShouldAlmostEqual, testCalibIntrinsics.Fy, tolerancePx)
+	test.That(t, k.Ppx, test.ShouldAlmostEqual, testCalibIntrinsics.Ppx, tolerancePx)
+	test.That(t, k.Ppy, test.ShouldAlmostEqual, testCalibIntrinsics.Ppy, tolerancePx)
+	d := calib.Distortion
+	test.That(t, d.RadialK1, test.ShouldAlmostEqual, testCalibDistortion.RadialK1, toleranceDistortion)
+	test.That(t, d.RadialK2, test.ShouldAlmostEqual, testCalibDistortion.RadialK2, 5*toleranceDistortion)
+	test.That(t, d.TangentialP1, test.ShouldAlmostEqual, testCalibDistortion.TangentialP1, toleranceDistortion/10)
+	test.That(t, d.TangentialP2, test.ShouldAlmostEqual, testCalibDistortion.TangentialP2, toleranceDistortion/10)
+	test.That(t, d.RadialK3, test.ShouldEqual, 0)
+}
+
+func TestCalibratePinholeIntrinsics(t *testing.T) {
+	cb := &Checkerboard{Cols: 9, Rows: 6, SquareSizeMM: 25}
+	width, height := testCalibIntrinsics.Width, testCalibIntrinsics.Height
+
+	t.Run("projected corners", func(t *testing.T) {
+		noise := rand.New(rand.NewSource(1))
+		var views []CalibrationView
+		for _, v := range testCalibViews {
+			view := CalibrationView{ObjectPoints: cb.ObjectPoints()}
+			for _, p := range view.ObjectPoints {
+				q := v.project(p, testCalibIntrinsics, testCalibDistortion)
+				view.ImagePoints = append(view.ImagePoints, q.Add(r2.Point{X: noise.NormFloat64() * 0.1, Y: noise.NormFloat64() * 0.1}))
+			}
+			views = append(views, view)
+		}
+		calib, err := CalibratePinholeIntrinsics(views, width, height, nil)
+		test.That(t, err, test.ShouldBeNil)
+		checkCalibration(t, calib, 2, 0.01)
+		test.That(t, calib.RMSError, test.ShouldBeLessThan, 0.15)
+		test.That(t, calib.ViewErrors, test.ShouldHaveLength, len(views))
+		test.That(t, calib.TargetPoses, test.ShouldHaveLength, len(views))
+		// the pose of the board in the first view is the pose of its top left corner.
+		test.That(t, spatialmath.PoseAlmostCoincidentEps(calib.TargetPoses[0],
+			spatialmath.NewPoseFromPoint(r3.Vector{X: -100, Y: -62.5, Z: 500}), 3), test.ShouldBeTrue)
+		for i, v := range testCalibViews {
+			want := spatialmath.NewPose(v.toCamera(r2.Point{}), v.orientation)
+			test.That(t, spatialmath.PoseAlmostCoincidentEps(calib.TargetPoses[i], want, 3), test.ShouldBeTrue)
+			test.That(t, spatialmath.OrientationAlmostEqualEps(calib.TargetPoses[i].Orientation(), v.orientation, 0.01),
+				test.ShouldBeTrue)
+		}
+
+		// views which all face the camera do not constrain the intrinsics in closed form, but still fit.
+		flat := []CalibrationView{views[0], views[0]}
+		calib, err = CalibratePinholeIntrinsics(flat, width, height, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, calib.RMSError, test.ShouldBeLessThan, 0.15)
+
+		calib, err = CalibratePinholeIntrinsics(views, width, height, &IntrinsicCalibrationOptions{EstimateK3: true})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, calib.Distortion.RadialK3, test.ShouldNotEqual, 0)
+		test.That(t, calib.RMSError, test.ShouldBeLessThan, 0.15)
+	})
+
+	t.Run("rendered checkerboards", func(t *testing.T) {
+		var views []CalibrationView
+		for _, v := range testCalibViews {
+			view, err := cb.FindCorners(renderCheckerboard(t, cb, v, testCalibIntrinsics, testCalibDistortion))
+			test.That(t, err, test.ShouldBeNil)
+			views = append(views, view)
+		}
+		calib, err := CalibratePinholeIntrinsics(views, width, height, nil)
+		test.That(t, err, test.ShouldBeNil)
+		checkCalibration(t, calib, 3, 0.02)
+		test.That(t, calib.RMSError, test.ShouldBeLessThan, 0.2)
+	})
+
+	t.Run("invalid views", func(t *testing.T) {
+		view := CalibrationView{ObjectPoints: cb.ObjectPoints(), ImagePoints: cb.ObjectPoints()}
+		_, err := CalibratePinholeIntrinsics([]CalibrationView{view}, width, height, nil)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "at least 2 views")
+		_, err = CalibratePinholeIntrinsics([]CalibrationView{view, view}, 0, height, nil)
+		test.That(t, err, test.ShouldNotBeNil)
+		short := CalibrationView{ObjectPoints: view.ObjectPoints[:4], ImagePoints: view.ImagePoints[:4]}
+		_, err = CalibratePinholeIntrinsics([]CalibrationView{view, short}, width, height, nil)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "at least 6")
+		mismatched := CalibrationView{ObjectPoints: view.ObjectPoints, ImagePoints: view.ImagePoints[:10]}
+		_, err = CalibratePinholeIntrinsics([]CalibrationView{view, mismatched}, width, height, nil)
+		test.That(t, err, test.ShouldNotBeNil)
+		// the corners of one row lie on a line.
+		line := CalibrationView{ObjectPoints: view.ObjectPoints[:9], ImagePoints: view.ImagePoints[:9]}
+		_, err = CalibratePinholeIntrinsics([]CalibrationView{view, line}, width, height, nil)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "degenerate")
+	})
+}
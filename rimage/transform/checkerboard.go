@@ -0,0 +1,497 @@
+package transform
+
+import (
+	"image"
+	"math"
+	"slices"
+
+	"github.com/golang/geo/r2"
+	"github.com/pkg/errors"
+	"gonum.org/v1/gonum/mat"
+)
+
+const (
+	// saddleSigma is the standard deviation, in pixels, of the blur before saddle points are found.
+	saddleSigma = 1.5
+	// minSaddleResponse is the weakest saddle point kept, as a fraction of the strongest.
+	minSaddleResponse = 0.15
+	// saddleRadius is how far apart, in pixels, two saddle points must be.
+	saddleRadius = 3
+	// xCornerRadius is the radius, in pixels, of the circle around which a corner must alternate between
+	// dark and light four times.
+	xCornerRadius  = 4
+	xCornerSamples = 32
+	// gridSnapTolerance is how far a corner can be from where it was predicted, as a fraction of the
+	// distance to its neighbor.
+	gridSnapTolerance = 0.3
+	// maxGridSeeds is how many of the strongest saddle points are tried as the start of a grid.
+	maxGridSeeds = 10
+	// refineIterations and refineEpsilon bound the iterations of corner refinement.
+	refineIterations = 20
+	refineEpsilon    = 0.01
+)
+
+// ErrCornersNotFound is returned when the corners of a calibration target cannot be found in an image.
+var ErrCornersNotFound = errors.New("calibration target not found in image")
+
+// Checkerboard is a calibration target of black and white squares, Cols by Rows inner corners large, whose
+// corners are the points where four squares meet.
+type Checkerboard struct {
+	Cols         int     `json:"cols"`
+	Rows         int     `json:"rows"`
+	SquareSizeMM float64 `json:"square_size_mm"`
+}
+
+// CheckValid checks that the checkerboard is large enough to calibrate with.
+func (cb *Checkerboard) CheckValid() error {
+	if cb.Cols < 2 || cb.Rows < 2 {
+		return errors.Errorf("checkerboard must have at least 2x2 inner corners, has %dx%d", cb.Cols, cb.Rows)
+	}
+	if cb.Cols*cb.Rows < 6 {
+		return errors.Errorf("checkerboard must have at least 6 inner corners, has %d", cb.Cols*cb.Rows)
+	}
+	if cb.SquareSizeMM <= 0 {
+		return errors.New("checkerboard square size must be positive")
+	}
+	return nil
+}
+
+// ObjectPoints returns the positions of the inner corners on the board, in mm, row by row from its top left
+// corner, with x to the right and y down.
+func (cb *Checkerboard) ObjectPoints() []r2.Point {
+	points := make([]r2.Point, 0, cb.Cols*cb.Rows)
+	for r := 0; r < cb.Rows; r++ {
+		for c := 0; c < cb.Cols; c++ {
+			points = append(points, r2.Point{X: float64(c) * cb.SquareSizeMM, Y: float64(r) * cb.SquareSizeMM})
+		}
+	}
+	return points
+}
+
+// FindCorners finds every inner corner of the checkerboard in img, refined to sub-pixel accuracy, and returns
+// them in the order of ObjectPoints. As a checkerboard looks the same rotated half a turn, the first corner is
+// whichever of the two candidates is further left in the image. ErrCornersNotFound is returned unless every
+// corner is found.
+func (cb *Checkerboard) FindCorners(img image.Image) (CalibrationView, error) {
+	if err := cb.CheckValid(); err != nil {
+		return CalibrationView{}, err
+	}
+	g := newGrayFloat(img)
+	blurred := g.blur(saddleSigma)
+	var candidates []saddle
+	for _, s := range findSaddles(blurred) {
+		if isXCorner(g, s.point) {
+			candidates = append(candidates, s)
+		}
+	}
+	if len(candidates) < cb.Cols*cb.Rows {
+		return CalibrationView{}, ErrCornersNotFound
+	}
+	points := make([]r2.Point, len(candidates))
+	for i, c := range candidates {
+		points[i] = c.point
+	}
+	for seed := 0; seed < min(maxGridSeeds, len(candidates)); seed++ {
+		grid := growGrid(points, seed)
+		corners, ok := cb.orderGrid(grid, points)
+		if !ok {
+			continue
+		}
+		spacing := math.Inf(1)
+		for i := 1; i < len(corners); i++ {
+			if i%cb.Cols != 0 {
+				spacing = math.Min(spacing, corners[i].Sub(corners[i-1]).Norm())
+			}
+		}
+		radius := max(2, min(5, int(spacing/4)))
+		return CalibrationView{
+			ImagePoints:  refineCorners(g.blur(0.7), corners, radius),
+			ObjectPoints: cb.ObjectPoints(),
+		}, nil
+	}
+	return CalibrationView{}, ErrCornersNotFound
+}
+
+// RefineCorners moves the given corners of a checkerboard-like pattern in img to where the image gradients
+// within radius pixels of them are most orthogonal to the directions to them, to sub-pixel accuracy.
+func RefineCorners(img image.Image, corners []r2.Point, radius int) []r2.Point {
+	return refineCorners(newGrayFloat(img).blur(0.7), corners, radius)
+}
+
+// grayFloat is a grayscale image of float intensities.
+type grayFloat struct {
+	w, h int
+	pix  []float64
+}
+
+func newGrayFloat(img image.Image) grayFloat {
+	b := img.Bounds()
+	g := grayFloat{w: b.Dx(), h: b.Dy(), pix: make([]float64, b.Dx()*b.Dy())}
+	if gray, ok := img.(*image.Gray); ok {
+		for y := 0; y < g.h; y++ {
+			for x := 0; x < g.w; x++ {
+				g.pix[y*g.w+x] = float64(gray.Pix[(y)*gray.Stride+x])
+			}
+		}
+		return g
+	}
+	for y := 0; y < g.h; y++ {
+		for x := 0; x < g.w; x++ {
+			r, gr, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			g.pix[y*g.w+x] = (0.299*float64(r) + 0.587*float64(gr) + 0.114*float64(bl)) / 257
+		}
+	}
+	return g
+}
+
+// at returns the intensity of the pixel nearest x, y within the image.
+func (g grayFloat) at(x, y int) float64 {
+	x = min(max(x, 0), g.w-1)
+	y = min(max(y, 0), g.h-1)
+	return g.pix[y*g.w+x]
+}
+
+// sample returns the intensity at x, y by bilinear interpolation.
+func (g grayFloat) sample(x, y float64) float64 {
+	x0, y0 := int(math.Floor(x)), int(math.Floor(y))
+	fx, fy := x-float64(x0), y-float64(y0)
+	return (1-fy)*((1-fx)*g.at(x0, y0)+fx*g.at(x0+1, y0)) + fy*((1-fx)*g.at(x0, y0+1)+fx*g.at(x0+1, y0+1))
+}
+
+// blur returns the image blurred by a gaussian of standard deviation sigma.
+func (g grayFloat) blur(sigma float64) grayFloat {
+	radius := int(math.Ceil(3 * sigma))
+	kernel := make([]float64, 2*radius+1)
+	sum := 0.0
+	for i := range kernel {
+		d := float64(i - radius)
+		kernel[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		sum += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+	tmp := grayFloat{w: g.w, h: g.h, pix: make([]float64, len(g.pix))}
+	for y := 0; y < g.h; y++ {
+		for x := 0; x < g.w; x++ {
+			v := 0.0
+			for i, k := range kernel {
+				v += k * g.at(x+i-radius, y)
+			}
+			tmp.pix[y*g.w+x] = v
+		}
+	}
+	out := grayFloat{w: g.w, h: g.h, pix: make([]float64, len(g.pix))}
+	for y := 0; y < g.h; y++ {
+		for x := 0; x < g.w; x++ {
+			v := 0.0
+			for i, k := range kernel {
+				v += k * tmp.at(x, y+i-radius)
+			}
+			out.pix[y*g.w+x] = v
+		}
+	}
+	return out
+}
+
+type saddle struct {
+	point    r2.Point
+	response float64
+}
+
+// findSaddles returns the saddle points of the image, where the determinant of its hessian is most negative,
+// strongest first. Where four squares of a checkerboard meet the image is a saddle, while along their edges
+// and at the outer corners of the board it is not.
+func findSaddles(g grayFloat) []saddle {
+	response := make([]float64, len(g.pix))
+	strongest := 0.0
+	for y := 1; y < g.h-1; y++ {
+		for x := 1; x < g.w-1; x++ {
+			c := g.pix[y*g.w+x]
+			ixx := g.pix[y*g.w+x+1] - 2*c + g.pix[y*g.w+x-1]
+			iyy := g.pix[(y+1)*g.w+x] - 2*c + g.pix[(y-1)*g.w+x]
+			ixy := (g.pix[(y+1)*g.w+x+1] - g.pix[(y+1)*g.w+x-1] - g.pix[(y-1)*g.w+x+1] + g.pix[(y-1)*g.w+x-1]) / 4
+			r := ixy*ixy - ixx*iyy
+			response[y*g.w+x] = r
+			strongest = math.Max(strongest, r)
+		}
+	}
+	if strongest == 0 {
+		return nil
+	}
+	var saddles []saddle
+	for y := saddleRadius; y < g.h-saddleRadius; y++ {
+		for x := saddleRadius; x < g.w-saddleRadius; x++ {
+			r := response[y*g.w+x]
+			if r < minSaddleResponse*strongest {
+				continue
+			}
+			isMax := true
+			for dy := -saddleRadius; dy <= saddleRadius && isMax; dy++ {
+				for dx := -saddleRadius; dx <= saddleRadius; dx++ {
+					other := response[(y+dy)*g.w+x+dx]
+					// ties go to the first pixel in raster order.
+					if other > r || (other == r && (dy < 0 || (dy == 0 && dx < 0))) {
+						isMax = false
+						break
+					}
+				}
+			}
+			if !isMax {
+				continue
+			}
+			// fit a parabola through the response on either side for a sub-pixel position.
+			offset := func(prev, next float64) float64 {
+				denom := prev - 2*r + next
+				if denom >= 0 {
+					return 0
+				}
+				return math.Max(-0.5, math.Min(0.5, (prev-next)/(2*denom)))
+			}
+			saddles = append(saddles, saddle{
+				point: r2.Point{
+					X: float64(x) + offset(response[y*g.w+x-1], response[y*g.w+x+1]),
+					Y: float64(y) + offset(response[(y-1)*g.w+x], response[(y+1)*g.w+x]),
+				},
+				response: r,
+			})
+		}
+	}
+	slices.SortFunc(saddles, func(a, b saddle) int {
+		switch {
+		case a.response > b.response:
+			return -1
+		case a.response < b.response:
+			return 1
+		default:
+			return 0
+		}
+	})
+	return saddles
+}
+
+// isXCorner returns whether the image around p looks like the point where four squares of a checkerboard
+// meet: dark and light four times around a circle, and the same on opposite sides of it. This rules out the
+// weaker saddles where the squares at the edge of a board meet its margin.
+func isXCorner(g grayFloat, p r2.Point) bool {
+	samples := make([]float64, xCornerSamples)
+	mean := 0.0
+	for i := range samples {
+		angle := 2 * math.Pi * float64(i) / xCornerSamples
+		samples[i] = g.sample(p.X+xCornerRadius*math.Cos(angle), p.Y+xCornerRadius*math.Sin(angle))
+		mean += samples[i] / xCornerSamples
+	}
+	changes, opposite := 0, 0
+	for i, v := range samples {
+		if (v > mean) != (samples[(i+1)%xCornerSamples] > mean) {
+			changes++
+		}
+		if (v > mean) == (samples[(i+xCornerSamples/2)%xCornerSamples] > mean) {
+			opposite++
+		}
+	}
+	return changes == 4 && opposite >= xCornerSamples*3/4
+}
+
+type gridIndex struct{ i, j int }
+
+// growGrid grows a grid of points outward from the seed point, predicting where each point's neighbors
+// should be from the points already in the grid and taking the nearest point to each prediction. It returns
+// the index of the point at each position of the grid.
+func growGrid(points []r2.Point, seed int) map[gridIndex]int {
+	// the nearest neighbor of the seed and the nearest which is not in line with it are its neighbors along
+	// either axis of the grid.
+	neighbors := make([]int, 0, len(points)-1)
+	for i := range points {
+		if i != seed {
+			neighbors = append(neighbors, i)
+		}
+	}
+	slices.SortFunc(neighbors, func(a, b int) int {
+		return cmpDistance(points[seed], points[a], points[b])
+	})
+	if len(neighbors) < 2 {
+		return nil
+	}
+	axisI := points[neighbors[0]].Sub(points[seed])
+	var axisJ r2.Point
+	for _, n := range neighbors[1:] {
+		v := points[n].Sub(points[seed])
+		if math.Abs(v.Dot(axisI))/(v.Norm()*axisI.Norm()) < 0.5 {
+			axisJ = v
+			break
+		}
+	}
+	if axisJ.Norm() == 0 {
+		return nil
+	}
+
+	grid := map[gridIndex]int{{0, 0}: seed}
+	used := map[int]bool{seed: true}
+	queue := []gridIndex{{0, 0}}
+	directions := []gridIndex{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	for len(queue) > 0 {
+		at := queue[0]
+		queue = queue[1:]
+		p := points[grid[at]]
+		for _, d := range directions {
+			next := gridIndex{at.i + d.i, at.j + d.j}
+			if _, ok := grid[next]; ok {
+				continue
+			}
+			var step r2.Point
+			if back, ok := grid[gridIndex{at.i - d.i, at.j - d.j}]; ok {
+				step = p.Sub(points[back])
+			} else if s, ok := parallelStep(grid, points, at, d); ok {
+				step = s
+			} else {
+				step = axisI.Mul(float64(d.i)).Add(axisJ.Mul(float64(d.j)))
+			}
+			predicted := p.Add(step)
+			best, bestDist := -1, gridSnapTolerance*step.Norm()
+			for k, q := range points {
+				if used[k] {
+					continue
+				}
+				if dist := q.Sub(predicted).Norm(); dist < bestDist {
+					best, bestDist = k, dist
+				}
+			}
+			if best < 0 {
+				continue
+			}
+			grid[next] = best
+			used[best] = true
+			queue = append(queue, next)
+		}
+	}
+	return grid
+}
+
+// parallelStep returns the step in direction d taken by a neighbor of at in the grid.
+func parallelStep(grid map[gridIndex]int, points []r2.Point, at, d gridIndex) (r2.Point, bool) {
+	for _, side := range []gridIndex{{d.j, d.i}, {-d.j, -d.i}} {
+		from, ok1 := grid[gridIndex{at.i + side.i, at.j + side.j}]
+		to, ok2 := grid[gridIndex{at.i + side.i + d.i, at.j + side.j + d.j}]
+		if ok1 && ok2 {
+			return points[to].Sub(points[from]), true
+		}
+	}
+	return r2.Point{}, false
+}
+
+func cmpDistance(from, a, b r2.Point) int {
+	da, db := a.Sub(from).Norm(), b.Sub(from).Norm()
+	switch {
+	case da < db:
+		return -1
+	case da > db:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// orderGrid returns the points of a grid in the order of ObjectPoints if the grid is exactly as large as the
+// checkerboard and complete.
+func (cb *Checkerboard) orderGrid(grid map[gridIndex]int, points []r2.Point) ([]r2.Point, bool) {
+	if len(grid) != cb.Cols*cb.Rows {
+		return nil, false
+	}
+	minI, minJ, maxI, maxJ := math.MaxInt, math.MaxInt, math.MinInt, math.MinInt
+	for idx := range grid {
+		minI, maxI = min(minI, idx.i), max(maxI, idx.i)
+		minJ, maxJ = min(minJ, idx.j), max(maxJ, idx.j)
+	}
+	ni, nj := maxI-minI+1, maxJ-minJ+1
+	if ni*nj != len(grid) {
+		return nil, false
+	}
+	at := func(i, j int) r2.Point { return points[grid[gridIndex{minI + i, minJ + j}]] }
+
+	// of the symmetries of the grid which make it cols by rows and keep the board facing the camera, take the
+	// one whose first axis points furthest to the right of the image.
+	var best []r2.Point
+	bestRight := math.Inf(-1)
+	for _, transpose := range []bool{false, true} {
+		cols, rows := ni, nj
+		if transpose {
+			cols, rows = nj, ni
+		}
+		if cols != cb.Cols || rows != cb.Rows {
+			continue
+		}
+		for _, flip := range [][2]bool{{false, false}, {true, false}, {false, true}, {true, true}} {
+			corners := make([]r2.Point, 0, cols*rows)
+			for r := 0; r < rows; r++ {
+				for c := 0; c < cols; c++ {
+					cc, rr := c, r
+					if flip[0] {
+						cc = cols - 1 - c
+					}
+					if flip[1] {
+						rr = rows - 1 - r
+					}
+					if transpose {
+						corners = append(corners, at(rr, cc))
+					} else {
+						corners = append(corners, at(cc, rr))
+					}
+				}
+			}
+			right := corners[cols-1].Sub(corners[0])
+			down := corners[(rows-1)*cols].Sub(corners[0])
+			if right.Cross(down) <= 0 {
+				continue
+			}
+			if rightness := right.X / right.Norm(); rightness > bestRight {
+				best, bestRight = corners, rightness
+			}
+		}
+	}
+	return best, best != nil
+}
+
+// refineCorners refines each corner to the point q which best satisfies g·(p-q) = 0 for the gradient g at
+// each point p around it, as the gradient at any point on the edges meeting at a corner is orthogonal to the
+// direction to the corner.
+func refineCorners(g grayFloat, corners []r2.Point, radius int) []r2.Point {
+	refined := make([]r2.Point, len(corners))
+	sigma := float64(radius) / 2
+	for n, corner := range corners {
+		q := corner
+		for iter := 0; iter < refineIterations; iter++ {
+			a := mat.NewSymDense(2, nil)
+			var bx, by float64
+			cx, cy := int(math.Round(q.X)), int(math.Round(q.Y))
+			for y := cy - radius; y <= cy+radius; y++ {
+				for x := cx - radius; x <= cx+radius; x++ {
+					gx := (g.at(x+1, y) - g.at(x-1, y)) / 2
+					gy := (g.at(x, y+1) - g.at(x, y-1)) / 2
+					dx, dy := float64(x)-q.X, float64(y)-q.Y
+					w := math.Exp(-(dx*dx + dy*dy) / (2 * sigma * sigma))
+					a.SetSym(0, 0, a.At(0, 0)+w*gx*gx)
+					a.SetSym(0, 1, a.At(0, 1)+w*gx*gy)
+					a.SetSym(1, 1, a.At(1, 1)+w*gy*gy)
+					bx += w * (gx*gx*float64(x) + gx*gy*float64(y))
+					by += w * (gx*gy*float64(x) + gy*gy*float64(y))
+				}
+			}
+			var next mat.VecDense
+			if err := next.SolveVec(a, mat.NewVecDense(2, []float64{bx, by})); err != nil {
+				break
+			}
+			p := r2.Point{X: next.AtVec(0), Y: next.AtVec(1)}
+			if p.Sub(corner).Norm() > float64(radius) {
+				break
+			}
+			moved := p.Sub(q).Norm()
+			q = p
+			if moved < refineEpsilon {
+				break
+			}
+		}
+		refined[n] = q
+	}
+	return refined
+}
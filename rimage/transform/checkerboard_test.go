@@ -0,0 +1,138 @@
+package transform
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/spatialmath"
+)
+
+var (
+	testCalibIntrinsics = &PinholeCameraIntrinsics{Width: 640, Height: 480, Fx: 600, Fy: 590, Ppx: 330, Ppy: 235}
+	testCalibDistortion = &BrownConrady{RadialK1: -0.15, RadialK2: 0.05, TangentialP1: 0.001, TangentialP2: -0.0005}
+)
+
+// boardView is a planar target placed in front of a camera, with its center at center on the target and the
+// given orientation and position relative to the camera.
+type boardView struct {
+	center      r2.Point
+	orientation spatialmath.Orientation
+	position    r3.Vector
+}
+
+func (v boardView) toCamera(p r2.Point) r3.Vector {
+	return spatialmath.Compose(spatialmath.NewPose(v.position, v.orientation),
+		spatialmath.NewPoseFromPoint(r3.Vector{X: p.X - v.center.X, Y: p.Y - v.center.Y})).Point()
+}
+
+func (v boardView) project(p r2.Point, k *PinholeCameraIntrinsics, d *BrownConrady) r2.Point {
+	q := v.toCamera(p)
+	x, y := d.Transform(q.X/q.Z, q.Y/q.Z)
+	return r2.Point{X: k.Fx*x + k.Ppx, Y: k.Fy*y + k.Ppy}
+}
+
+// renderCheckerboard draws the checkerboard as the camera would see it from the view, tracing rays through
+// 4x4 points of every pixel to the plane of the board, which has a square around its inner corners and a
+// white margin.
+func renderCheckerboard(t *testing.T, cb *Checkerboard, v boardView, k *PinholeCameraIntrinsics, d *BrownConrady) *image.Gray {
+	t.Helper()
+	// the homography from the plane of the board to normalized image coordinates, inverted.
+	object := []r2.Point{{X: 0, Y: 0}, {X: 100, Y: 0}, {X: 100, Y: 100}, {X: 0, Y: 100}, {X: 50, Y: 30}, {X: 20, Y: 70}}
+	normalized := make([]r2.Point, len(object))
+	for i, p := range object {
+		q := v.toCamera(p)
+		normalized[i] = r2.Point{X: q.X / q.Z, Y: q.Y / q.Z}
+	}
+	h, err := planarHomography(normalized, object)
+	test.That(t, err, test.ShouldBeNil)
+
+	img := image.NewGray(image.Rect(0, 0, k.Width, k.Height))
+	sq := cb.SquareSizeMM
+	for y := 0; y < k.Height; y++ {
+		for x := 0; x < k.Width; x++ {
+			sum := 0.0
+			for s := 0; s < 16; s++ {
+				u := (float64(x) - 0.375 + 0.25*float64(s%4) - k.Ppx) / k.Fx
+				w := (float64(y) - 0.375 + 0.25*float64(s/4) - k.Ppy) / k.Fy
+				n := r2.Point{X: u, Y: w}
+				for i := 0; i < 10; i++ {
+					dx, dy := d.Transform(n.X, n.Y)
+					n = n.Add(r2.Point{X: u - dx, Y: w - dy})
+				}
+				z := h.At(2, 0)*n.X + h.At(2, 1)*n.Y + h.At(2, 2)
+				bx := (h.At(0, 0)*n.X + h.At(0, 1)*n.Y + h.At(0, 2)) / z
+				by := (h.At(1, 0)*n.X + h.At(1, 1)*n.Y + h.At(1, 2)) / z
+				cx, cy := math.Floor(bx/sq)+1, math.Floor(by/sq)+1
+				if cx < 0 || cy < 0 || cx > float64(cb.Cols) || cy > float64(cb.Rows) || int(cx+cy)%2 == 1 {
+					sum += 220
+				} else {
+					sum += 30
+				}
+			}
+			img.SetGray(x, y, color.Gray{Y: uint8(sum / 16)})
+		}
+	}
+	return img
+}
+
+func orientationFromDegrees(x, y, z float64) spatialmath.Orientation {
+	return &spatialmath.EulerAngles{Roll: x * math.Pi / 180, Pitch: y * math.Pi / 180, Yaw: z * math.Pi / 180}
+}
+
+func TestCheckerboardFindCorners(t *testing.T) {
+	cb := &Checkerboard{Cols: 9, Rows: 6, SquareSizeMM: 25}
+	test.That(t, cb.CheckValid(), test.ShouldBeNil)
+	test.That(t, (&Checkerboard{Cols: 1, Rows: 6, SquareSizeMM: 25}).CheckValid(), test.ShouldNotBeNil)
+	test.That(t, (&Checkerboard{Cols: 9, Rows: 6}).CheckValid(), test.ShouldNotBeNil)
+
+	objectPoints := cb.ObjectPoints()
+	test.That(t, objectPoints, test.ShouldHaveLength, 54)
+	test.That(t, objectPoints[10], test.ShouldResemble, r2.Point{X: 25, Y: 25})
+
+	center := r2.Point{X: 100, Y: 62.5}
+	for _, tc := range []struct {
+		name string
+		view boardView
+	}{
+		{"facing the camera", boardView{center, orientationFromDegrees(0, 0, 0), r3.Vector{Z: 450}}},
+		{"tilted", boardView{center, orientationFromDegrees(20, -25, 10), r3.Vector{X: 20, Y: -10, Z: 500}}},
+		{"upside down", boardView{center, orientationFromDegrees(-10, 15, 175), r3.Vector{X: -30, Z: 550}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			img := renderCheckerboard(t, cb, tc.view, testCalibIntrinsics, testCalibDistortion)
+			view, err := cb.FindCorners(img)
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, view.ImagePoints, test.ShouldHaveLength, 54)
+			test.That(t, view.ObjectPoints, test.ShouldResemble, objectPoints)
+
+			// the board is found the right way up, or rotated half a turn when it is upside down.
+			sumErr := 0.0
+			for i, p := range view.ImagePoints {
+				truth := objectPoints[i]
+				if tc.name == "upside down" {
+					truth = objectPoints[len(objectPoints)-1-i]
+				}
+				err := p.Sub(tc.view.project(truth, testCalibIntrinsics, testCalibDistortion)).Norm()
+				test.That(t, err, test.ShouldBeLessThan, 0.5)
+				sumErr += err
+			}
+			test.That(t, sumErr/float64(len(view.ImagePoints)), test.ShouldBeLessThan, 0.15)
+		})
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := cb.FindCorners(image.NewGray(image.Rect(0, 0, 100, 100)))
+		test.That(t, err, test.ShouldBeError, ErrCornersNotFound)
+		// a board with more rows is not the same board.
+		img := renderCheckerboard(t, cb, boardView{center, orientationFromDegrees(0, 0, 0), r3.Vector{Z: 450}},
+			testCalibIntrinsics, testCalibDistortion)
+		_, err = (&Checkerboard{Cols: 9, Rows: 7, SquareSizeMM: 25}).FindCorners(img)
+		test.That(t, err, test.ShouldBeError, ErrCornersNotFound)
+	})
+}
@@ -0,0 +1,171 @@
+// Given samples of the end position of an arm and of a calibration target seen by a camera mounted on the arm
+// or watching it, computes the pose of the camera relative to the end effector or the base of the arm, and
+// prints and writes it as the frame of the camera for the robot config, along with how well the samples
+// agree with it. Each sample has the pose of the target relative to the camera, or an image of the target
+// for it to be found in, in which case the config needs the intrinsics of the camera and the target.
+// $./hand_eye_calibration -conf=/path/to/input/file -out=/path/to/frame.json
+//
+// An example input file:
+//
+//	{
+//	  "setup": "eye_in_hand",
+//	  "camera": "wrist-cam",
+//	  "arm": "arm",
+//	  "target": {"type": "checkerboard", "cols": 9, "rows": 6, "square_size_mm": 25},
+//	  "intrinsic_parameters": {"width_px": 640, "height_px": 480, "fx": 600, "fy": 600, "ppx": 320, "ppy": 240},
+//	  "samples": [
+//	    {"end_position": {"x": 400, "z": 500, "o_z": -1, "theta": 0}, "image": "0.png"},
+//	    {"end_position": {"x": 450, "y": 80, "z": 480, "o_x": 0.2, "o_z": -1, "theta": 20}, "image": "1.png"}
+//	  ]
+//	}
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	commonpb "go.viam.com/api/common/v1"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/rimage/transform"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/vision/fiducial"
+)
+
+// config is the input of a hand-eye calibration.
+type config struct {
+	Setup transform.HandEyeSetup `json:"setup"`
+	// Camera and Arm are the names of the camera and arm, which the frame of the camera is named after and
+	// relative to.
+	Camera string `json:"camera"`
+	Arm    string `json:"arm"`
+	// Target, Intrinsics and Distortion are needed to find the target in the images of samples.
+	Target     *fiducial.CalibrationTargetConfig  `json:"target,omitempty"`
+	Intrinsics *transform.PinholeCameraIntrinsics `json:"intrinsic_parameters,omitempty"`
+	Distortion *transform.BrownConrady            `json:"distortion_parameters,omitempty"`
+	Samples    []sampleConfig                     `json:"samples"`
+}
+
+// sampleConfig is the end position of the arm, as its EndPosition returns, and either the pose of the
+// target relative to the camera or the path of an image of it, relative to the config file.
+type sampleConfig struct {
+	EndPosition *commonpb.Pose `json:"end_position"`
+	TargetPose  *commonpb.Pose `json:"target_pose,omitempty"`
+	Image       string         `json:"image,omitempty"`
+}
+
+// result is the frame of the camera and how well the samples agree with it.
+type result struct {
+	Frame                 *referenceframe.LinkConfig `json:"frame"`
+	TargetPose            *commonpb.Pose             `json:"target_pose"`
+	TranslationErrorsMM   []float64                  `json:"translation_errors_mm"`
+	RotationErrorsDeg     []float64                  `json:"rotation_errors_deg"`
+	RMSTranslationErrorMM float64                    `json:"rms_translation_error_mm"`
+	RMSRotationErrorDeg   float64                    `json:"rms_rotation_error_deg"`
+}
+
+func main() {
+	confPtr := flag.String("conf", "", "path of configuration for hand-eye calibration")
+	outPtr := flag.String("out", "", "path to write the frame of the camera to")
+	flag.Parse()
+	logger := logging.NewLogger("hand_eye_calibration")
+	res, err := calibrate(*confPtr, logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	out, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		logger.Fatal(err)
+	}
+	logger.Infof("\n%s", out)
+	if *outPtr != "" {
+		if err := os.WriteFile(*outPtr, out, 0o644); err != nil { //nolint:gosec
+			logger.Fatal(err)
+		}
+	}
+	os.Exit(0)
+}
+
+func calibrate(confPath string, logger logging.Logger) (*result, error) {
+	data, err := os.ReadFile(confPath) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+	var conf config
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse %q", confPath)
+	}
+	if conf.Camera == "" || conf.Arm == "" {
+		return nil, errors.New("config must name the camera and the arm")
+	}
+	var target transform.CalibrationTarget
+	if conf.Target != nil {
+		if target, err = fiducial.NewCalibrationTarget(conf.Target); err != nil {
+			return nil, err
+		}
+	}
+
+	samples := make([]transform.HandEyeSample, 0, len(conf.Samples))
+	for i, s := range conf.Samples {
+		if s.EndPosition == nil {
+			return nil, errors.Errorf("sample %d has no end_position", i)
+		}
+		sample := transform.HandEyeSample{EndPosition: spatialmath.NewPoseFromProtobuf(s.EndPosition)}
+		switch {
+		case s.TargetPose != nil:
+			sample.TargetPose = spatialmath.NewPoseFromProtobuf(s.TargetPose)
+		case s.Image != "":
+			if target == nil || conf.Intrinsics == nil {
+				return nil, errors.Errorf("sample %d has an image but the config has no target or intrinsic_parameters", i)
+			}
+			path := s.Image
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(filepath.Dir(confPath), path)
+			}
+			img, err := rimage.NewImageFromFile(path)
+			if err != nil {
+				return nil, err
+			}
+			view, err := target.FindCorners(img)
+			if errors.Is(err, transform.ErrCornersNotFound) {
+				logger.Warnf("calibration target not found in %q, skipping it", s.Image)
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if sample.TargetPose, err = transform.EstimateTargetPose(view, conf.Intrinsics, conf.Distortion); err != nil {
+				return nil, errors.Wrapf(err, "sample %d", i)
+			}
+		default:
+			return nil, errors.Errorf("sample %d has neither a target_pose nor an image", i)
+		}
+		samples = append(samples, sample)
+	}
+
+	calib, err := transform.CalibrateHandEye(samples, conf.Setup)
+	if err != nil {
+		return nil, err
+	}
+	parent := conf.Arm
+	if conf.Setup == transform.EyeToHand {
+		parent = conf.Arm + "_origin"
+	}
+	frame, err := calib.LinkConfig(conf.Camera, parent)
+	if err != nil {
+		return nil, err
+	}
+	return &result{
+		Frame:                 frame,
+		TargetPose:            spatialmath.PoseToProtobuf(calib.TargetPose),
+		TranslationErrorsMM:   calib.TranslationErrorsMM,
+		RotationErrorsDeg:     calib.RotationErrorsDeg,
+		RMSTranslationErrorMM: calib.RMSTranslationErrorMM,
+		RMSRotationErrorDeg:   calib.RMSRotationErrorDeg,
+	}, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/geo/r3"
+	commonpb "go.viam.com/api/common/v1"
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/rimage/transform"
+	"go.viam.com/rdk/spatialmath"
+)
+
+func writeConfig(t *testing.T, conf *config) string {
+	t.Helper()
+	data, err := json.Marshal(conf)
+	test.That(t, err, test.ShouldBeNil)
+	path := filepath.Join(t.TempDir(), "conf.json")
+	test.That(t, os.WriteFile(path, data, 0o644), test.ShouldBeNil)
+	return path
+}
+
+func TestCalibrate(t *testing.T) {
+	logger := logging.NewTestLogger(t)
+	camera := spatialmath.NewPose(r3.Vector{X: 60, Y: -20, Z: 40}, &spatialmath.EulerAngles{Yaw: 1.5})
+	target := spatialmath.NewPose(r3.Vector{X: 420, Y: 20}, &spatialmath.EulerAngles{Yaw: 0.5})
+	conf := &config{Setup: transform.EyeInHand, Camera: "cam", Arm: "arm"}
+	for _, end := range []spatialmath.Pose{
+		spatialmath.NewPose(r3.Vector{X: 400, Z: 500}, &spatialmath.EulerAngles{Roll: 3.1}),
+		spatialmath.NewPose(r3.Vector{X: 450, Y: 80, Z: 480}, &spatialmath.EulerAngles{Roll: 2.9, Pitch: 0.2, Yaw: 0.3}),
+		spatialmath.NewPose(r3.Vector{X: 380, Y: -60, Z: 520}, &spatialmath.EulerAngles{Roll: -3, Pitch: -0.3, Yaw: -0.5}),
+		spatialmath.NewPose(r3.Vector{X: 420, Y: 40, Z: 450}, &spatialmath.EulerAngles{Roll: 3, Pitch: 0.3, Yaw: 1}),
+	} {
+		targetPose := spatialmath.Compose(spatialmath.PoseInverse(spatialmath.Compose(end, camera)), target)
+		conf.Samples = append(conf.Samples, sampleConfig{
+			EndPosition: spatialmath.PoseToProtobuf(end),
+			TargetPose:  spatialmath.PoseToProtobuf(targetPose),
+		})
+	}
+
+	res, err := calibrate(writeConfig(t, conf), logger)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res.Frame.ID, test.ShouldEqual, "cam")
+	test.That(t, res.Frame.Parent, test.ShouldEqual, "arm")
+	pose, err := res.Frame.Pose()
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, spatialmath.PoseAlmostEqualEps(pose, camera, 1e-3), test.ShouldBeTrue)
+	test.That(t, res.RMSTranslationErrorMM, test.ShouldBeLessThan, 1e-3)
+	test.That(t, res.TranslationErrorsMM, test.ShouldHaveLength, 4)
+
+	conf.Camera = ""
+	_, err = calibrate(writeConfig(t, conf), logger)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	conf.Camera = "cam"
+	conf.Samples = append(conf.Samples, sampleConfig{EndPosition: &commonpb.Pose{}, Image: "0.png"})
+	_, err = calibrate(writeConfig(t, conf), logger)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "no target or intrinsic_parameters")
+
+	conf.Samples[4] = sampleConfig{EndPosition: &commonpb.Pose{}}
+	_, err = calibrate(writeConfig(t, conf), logger)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "neither")
+}
@@ -0,0 +1,117 @@
+// Given a directory of images of a checkerboard or a ChArUco board taken from different angles, computes
+// the intrinsic parameters and distortion of the camera which took them, and prints and writes them as the
+// intrinsic_parameters and distortion_parameters of a camera config, along with the reprojection error.
+// $./intrinsic_calibration -images=/path/to/images -cols=9 -rows=6 -square_mm=25 -out=/path/to/calib.json
+// $./intrinsic_calibration -images=/path/to/images -target=charuco -cols=7 -rows=5 -square_mm=40 \
+// -marker_mm=30 -dictionary=/path/to/DICT_4X4_50.yml -out=/path/to/calib.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/rimage/transform"
+	"go.viam.com/rdk/vision/fiducial"
+)
+
+func main() {
+	imagesPtr := flag.String("images", "", "directory of images of the calibration target")
+	targetPtr := flag.String("target", "checkerboard", "type of calibration target, checkerboard or charuco")
+	colsPtr := flag.Int("cols", 9, "inner corners across a checkerboard, or squares across a ChArUco board")
+	rowsPtr := flag.Int("rows", 6, "inner corners down a checkerboard, or squares down a ChArUco board")
+	squarePtr := flag.Float64("square_mm", 25, "size of the squares of the board in mm")
+	markerPtr := flag.Float64("marker_mm", 0, "size of the markers of a ChArUco board in mm")
+	dictionaryPtr := flag.String("dictionary", "", "ChArUco marker dictionary, aruco_original or an OpenCV dictionary file")
+	k3Ptr := flag.Bool("k3", false, "also estimate the third radial distortion coefficient")
+	outPtr := flag.String("out", "", "path to write the calibration to")
+	flag.Parse()
+	logger := logging.NewLogger("intrinsic_calibration")
+
+	target, err := fiducial.NewCalibrationTarget(&fiducial.CalibrationTargetConfig{
+		Type:         *targetPtr,
+		Cols:         *colsPtr,
+		Rows:         *rowsPtr,
+		SquareSizeMM: *squarePtr,
+		MarkerSizeMM: *markerPtr,
+		Dictionary:   *dictionaryPtr,
+	})
+	if err != nil {
+		logger.Fatal(err)
+	}
+	calib, err := calibrate(*imagesPtr, target, &transform.IntrinsicCalibrationOptions{EstimateK3: *k3Ptr}, logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	out, err := json.MarshalIndent(calib, "", "  ")
+	if err != nil {
+		logger.Fatal(err)
+	}
+	logger.Infof("\n%s", out)
+	if *outPtr != "" {
+		if err := os.WriteFile(*outPtr, out, 0o644); err != nil { //nolint:gosec
+			logger.Fatal(err)
+		}
+	}
+	os.Exit(0)
+}
+
+// calibrate finds the target in each image in dir, skipping those it is not found in, and calibrates the
+// camera from the views of it.
+func calibrate(
+	dir string,
+	target transform.CalibrationTarget,
+	opts *transform.IntrinsicCalibrationOptions,
+	logger logging.Logger,
+) (*transform.IntrinsicCalibration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var views []transform.CalibrationView
+	var names []string
+	width, height := 0, 0
+	for _, e := range entries {
+		if e.IsDir() || !rimage.IsImageFile(e.Name()) {
+			continue
+		}
+		img, err := rimage.NewImageFromFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if width == 0 {
+			width, height = img.Width(), img.Height()
+		} else if img.Width() != width || img.Height() != height {
+			return nil, errors.Errorf("image %q is %dx%d but the images before it are %dx%d",
+				e.Name(), img.Width(), img.Height(), width, height)
+		}
+		view, err := target.FindCorners(img)
+		if errors.Is(err, transform.ErrCornersNotFound) {
+			logger.Warnf("calibration target not found in %q, skipping it", e.Name())
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		logger.Debugf("found %d corners in %q", len(view.ImagePoints), e.Name())
+		views = append(views, view)
+		names = append(names, e.Name())
+	}
+	if width == 0 {
+		return nil, errors.Errorf("no images found in %q", dir)
+	}
+
+	calib, err := transform.CalibratePinholeIntrinsics(views, width, height, opts)
+	if err != nil {
+		return nil, err
+	}
+	for i, viewErr := range calib.ViewErrors {
+		logger.Infof("%q reprojection error: %.3f px", names[i], viewErr)
+	}
+	return calib, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/rimage/transform"
+)
+
+// writeCheckerboard writes an image of a board with the corners of cb, facing the camera, with squares of
+// squarePx pixels and its top left square at offset.
+func writeCheckerboard(t *testing.T, path string, cb *transform.Checkerboard, squarePx int, offset image.Point) {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, 640, 480))
+	for y := 0; y < 480; y++ {
+		for x := 0; x < 640; x++ {
+			img.SetGray(x, y, color.Gray{Y: 220})
+			sx, sy := (x-offset.X)/squarePx, (y-offset.Y)/squarePx
+			if x >= offset.X && y >= offset.Y && sx <= cb.Cols && sy <= cb.Rows && (sx+sy)%2 == 0 {
+				img.SetGray(x, y, color.Gray{Y: 30})
+			}
+		}
+	}
+	test.That(t, rimage.WriteImageToFile(path, img), test.ShouldBeNil)
+}
+
+func TestCalibrate(t *testing.T) {
+	logger := logging.NewTestLogger(t)
+	cb := &transform.Checkerboard{Cols: 9, Rows: 6, SquareSizeMM: 25}
+	dir := t.TempDir()
+
+	_, err := calibrate(dir, cb, nil, logger)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "no images found")
+
+	writeCheckerboard(t, filepath.Join(dir, "0.png"), cb, 30, image.Pt(100, 80))
+	writeCheckerboard(t, filepath.Join(dir, "1.png"), cb, 24, image.Pt(260, 250))
+	// images without the board are skipped.
+	test.That(t, rimage.WriteImageToFile(filepath.Join(dir, "2.png"), image.NewGray(image.Rect(0, 0, 640, 480))),
+		test.ShouldBeNil)
+	calib, err := calibrate(dir, cb, nil, logger)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, calib.Intrinsics.Width, test.ShouldEqual, 640)
+	test.That(t, calib.Intrinsics.Height, test.ShouldEqual, 480)
+	test.That(t, calib.ViewErrors, test.ShouldHaveLength, 2)
+	test.That(t, calib.RMSError, test.ShouldBeLessThan, 0.5)
+
+	test.That(t, rimage.WriteImageToFile(filepath.Join(dir, "3.png"), image.NewGray(image.Rect(0, 0, 320, 240))),
+		test.ShouldBeNil)
+	_, err = calibrate(dir, cb, nil, logger)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "320x240")
+}
@@ -160,8 +160,12 @@ func EstimateExactHomographyFrom8Points(s1, s2 []r2.Point, normalize bool) (*Hom
 // points with the RANdom SAmple Consensus method
 // from Multiple View Geometry. Richard Hartley and Andrew Zisserman. Alg 4.4 p118.
 func EstimateHomographyRANSAC(pts1, pts2 []r2.Point, thresh float64, nMaxIteration int) (*Homography, []int, error) {
-	// test len(pts1)==len(pts2)
-	// test len(pts1) > 4
+	if len(pts1) != len(pts2) {
+		return nil, nil, errors.New("pts1 and pts2 must have the same number of points")
+	}
+	if len(pts1) < 4 {
+		return nil, nil, errors.New("at least 4 matches are needed to estimate a homography")
+	}
 	maxInliers := make([]int, 0, len(pts1))
 	finalH := mat.NewDense(3, 3, nil)
 	// RANSAC iterations
@@ -184,10 +188,10 @@ func EstimateHomographyRANSAC(pts1, pts2 []r2.Point, thresh float64, nMaxIterati
 			return nil, nil, err
 		}
 		if h != nil {
-			// compute inliers
+			// compute inliers among all the matches
 			currentInliers := make([]int, 0, len(pts1))
-			for k := 0; k < 4; k++ {
-				d := geometricDistance(s1[k], s2[k], h.matrix)
+			for k := range pts1 {
+				d := geometricDistance(pts1[k], pts2[k], h.matrix)
 				if d < 5. {
 					currentInliers = append(currentInliers, k)
 				}
@@ -0,0 +1,403 @@
+package transform
+
+import (
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/golang/geo/r2"
+	"github.com/pkg/errors"
+	"gonum.org/v1/gonum/mat"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/vision/keypoints"
+)
+
+// the ways a Panorama can blend the images where they overlap.
+const (
+	// PanoramaBlendFeather averages the overlapping images, each weighted by how far its pixels are from its
+	// edges, so that the seams fade from one image to the other.
+	PanoramaBlendFeather = "feather"
+	// PanoramaBlendSeam takes each pixel from the image it is furthest from the edges of, leaving sharp seams
+	// but no ghosts of what moved or is out of the plane of the panorama.
+	PanoramaBlendSeam = "seam"
+)
+
+const (
+	// matches of ORB descriptors differing by more bits than this are not used.
+	panoramaMaxMatchDistBits = 128
+	// the fewest matches consistent with a homography for the homography to be trusted.
+	panoramaMinInliers = 12
+	// matches further than this in pixels from where the homography maps them are outliers.
+	panoramaInlierDistPx       = 3.
+	panoramaRANSACIterations   = 2000
+	panoramaRANSACEnoughInlier = 0.8
+	// panoramas cannot be bigger than this on either side, which only homographies mapping images almost to
+	// infinity would make them.
+	maxPanoramaSidePx = 1 << 14
+)
+
+// panoramaORBConfig is how keypoints are found and described to match images. Only one layer is used since
+// the cameras of a rig see the scene at about the same scale.
+var panoramaORBConfig = keypoints.ORBConfig{
+	Layers:          1,
+	DownscaleFactor: 2,
+	FastConf: &keypoints.FASTConfig{
+		NMatchesCircle: 9,
+		NMSWinSize:     7,
+		Threshold:      10,
+		Oriented:       true,
+		Radius:         16,
+	},
+	BRIEFConf: &keypoints.BRIEFConfig{
+		N:              512,
+		UseOrientation: true,
+		PatchSize:      48,
+	},
+}
+
+// EstimateImageHomography estimates the homography mapping pixels of the src image to the pixels of the dst
+// image seeing the same points, from the matches of their ORB keypoints. The images must overlap, and what
+// they see in the overlap must be about planar or far away, or the cameras must have rotated about their
+// center between them.
+func EstimateImageHomography(src, dst *image.Gray, logger logging.Logger) (*Homography, error) {
+	sp := keypoints.GenerateSamplePairs(
+		panoramaORBConfig.BRIEFConf.Sampling, panoramaORBConfig.BRIEFConf.N, panoramaORBConfig.BRIEFConf.PatchSize)
+	descs1, kps1, err := describedKeypoints(src, sp)
+	if err != nil {
+		return nil, err
+	}
+	descs2, kps2, err := describedKeypoints(dst, sp)
+	if err != nil {
+		return nil, err
+	}
+	if len(descs1) < panoramaMinInliers || len(descs2) < panoramaMinInliers {
+		return nil, errors.Errorf("too few keypoints to match the images, %d and %d", len(descs1), len(descs2))
+	}
+	matches := keypoints.MatchDescriptors(
+		descs1, descs2, &keypoints.MatchingConfig{DoCrossCheck: true, MaxDist: panoramaMaxMatchDistBits}, logger)
+	if len(matches) < panoramaMinInliers {
+		return nil, errors.Errorf("only %d keypoints of the images match, they may not overlap", len(matches))
+	}
+	matched1, matched2, err := keypoints.GetMatchingKeyPoints(matches, kps1, kps2)
+	if err != nil {
+		return nil, err
+	}
+	pts1, pts2 := make([]r2.Point, len(matches)), make([]r2.Point, len(matches))
+	for i := range matches {
+		pts1[i] = r2.Point{X: float64(matched1[i].X), Y: float64(matched1[i].Y)}
+		pts2[i] = r2.Point{X: float64(matched2[i].X), Y: float64(matched2[i].Y)}
+	}
+	h, inliers, err := EstimateHomographyRANSAC(pts1, pts2, panoramaRANSACEnoughInlier, panoramaRANSACIterations)
+	if err != nil {
+		return nil, err
+	}
+	// the homography of the best 4 matches is refined to fit all the matches consistent with it.
+	for pass := 0; pass < 2; pass++ {
+		if len(inliers) < panoramaMinInliers {
+			return nil, errors.Errorf("only %d of %d matches of the images agree on a homography", len(inliers), len(matches))
+		}
+		in1, in2 := make([]r2.Point, len(inliers)), make([]r2.Point, len(inliers))
+		for i, k := range inliers {
+			in1[i], in2[i] = pts1[k], pts2[k]
+		}
+		if h, err = fitHomography(in1, in2); err != nil {
+			return nil, err
+		}
+		inliers = inliers[:0]
+		for k := range pts1 {
+			if h.Apply(pts1[k]).Sub(pts2[k]).Norm() < panoramaInlierDistPx {
+				inliers = append(inliers, k)
+			}
+		}
+	}
+	return h, nil
+}
+
+// describedKeypoints returns the ORB keypoints of the image far enough from its edges to be described.
+func describedKeypoints(img *image.Gray, sp *keypoints.SamplePairs) ([]keypoints.Descriptor, keypoints.KeyPoints, error) {
+	descs, kps, err := keypoints.ComputeORBKeypoints(img, sp, &panoramaORBConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	inner := img.Bounds().Inset(panoramaORBConfig.BRIEFConf.PatchSize/2 + 1)
+	n := 0
+	for i, kp := range kps {
+		if kp.In(inner) {
+			descs[n], kps[n] = descs[i], kp
+			n++
+		}
+	}
+	return descs[:n], kps[:n], nil
+}
+
+// fitHomography returns the homography mapping the src points to the dst points with the least algebraic
+// error, after normalizing both, from Multiple View Geometry. Richard Hartley and Andrew Zisserman. Alg 4.2
+// p109.
+func fitHomography(src, dst []r2.Point) (*Homography, error) {
+	norm1, _ := similarityNormalization(src)
+	norm2, denorm2 := similarityNormalization(dst)
+	a := mat.NewDense(2*len(src), 9, nil)
+	for i := range src {
+		p := applyAffine(norm1, src[i])
+		q := applyAffine(norm2, dst[i])
+		a.SetRow(2*i, []float64{p.X, p.Y, 1, 0, 0, 0, -q.X * p.X, -q.X * p.Y, -q.X})
+		a.SetRow(2*i+1, []float64{0, 0, 0, p.X, p.Y, 1, -q.Y * p.X, -q.Y * p.Y, -q.Y})
+	}
+	var svd mat.SVD
+	if ok := svd.Factorize(a, mat.SVDFull); !ok {
+		return nil, errors.New("failed to factorize the homography system")
+	}
+	var v mat.Dense
+	svd.VTo(&v)
+	hn := mat.NewDense(3, 3, mat.Col(nil, 8, &v))
+	// the homography of the normalized points is between the normalizations of src and dst.
+	var h mat.Dense
+	h.Product(denorm2, hn, norm1)
+	if h.At(2, 2) == 0 {
+		return nil, errors.New("homography maps the origin to infinity")
+	}
+	h.Scale(1/h.At(2, 2), &h)
+	return &Homography{&h}, nil
+}
+
+// similarityNormalization returns the similarity moving the points to be centered on the origin at an
+// average distance of sqrt(2) from it, and its inverse.
+func similarityNormalization(pts []r2.Point) (*mat.Dense, *mat.Dense) {
+	var center r2.Point
+	for _, p := range pts {
+		center = center.Add(p)
+	}
+	center = center.Mul(1 / float64(len(pts)))
+	var dist float64
+	for _, p := range pts {
+		dist += p.Sub(center).Norm()
+	}
+	scale := 1.
+	if dist > 0 {
+		scale = math.Sqrt2 * float64(len(pts)) / dist
+	}
+	norm := mat.NewDense(3, 3, []float64{scale, 0, -scale * center.X, 0, scale, -scale * center.Y, 0, 0, 1})
+	denorm := mat.NewDense(3, 3, []float64{1 / scale, 0, center.X, 0, 1 / scale, center.Y, 0, 0, 1})
+	return norm, denorm
+}
+
+func applyAffine(m mat.Matrix, p r2.Point) r2.Point {
+	return r2.Point{X: m.At(0, 0)*p.X + m.At(0, 1)*p.Y + m.At(0, 2), Y: m.At(1, 0)*p.X + m.At(1, 1)*p.Y + m.At(1, 2)}
+}
+
+// EstimatePanoramaHomographies estimates the homographies mapping the pixels of each image to the pixels of
+// the middle image, where each image overlaps the images next to it in the order they are given.
+func EstimatePanoramaHomographies(imgs []image.Image, logger logging.Logger) ([]*Homography, error) {
+	if len(imgs) == 0 {
+		return nil, errors.New("a panorama needs at least one image")
+	}
+	grays := make([]*image.Gray, len(imgs))
+	for i, img := range imgs {
+		grays[i] = image.NewGray(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+		draw.Draw(grays[i], grays[i].Bounds(), img, img.Bounds().Min, draw.Src)
+	}
+	ref := len(imgs) / 2
+	homographies := make([]*Homography, len(imgs))
+	homographies[ref] = &Homography{mat.NewDense(3, 3, []float64{1, 0, 0, 0, 1, 0, 0, 0, 1})}
+	// each image is mapped to its neighbor towards the middle, and on to the middle from there.
+	for i := ref - 1; i >= 0; i-- {
+		h, err := EstimateImageHomography(grays[i], grays[i+1], logger)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot match image %d to image %d", i, i+1)
+		}
+		homographies[i] = composeHomographies(homographies[i+1], h)
+	}
+	for i := ref + 1; i < len(imgs); i++ {
+		h, err := EstimateImageHomography(grays[i], grays[i-1], logger)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot match image %d to image %d", i, i-1)
+		}
+		homographies[i] = composeHomographies(homographies[i-1], h)
+	}
+	return homographies, nil
+}
+
+// composeHomographies returns the homography applying second and then first.
+func composeHomographies(first, second *Homography) *Homography {
+	var m mat.Dense
+	m.Mul(first.matrix, second.matrix)
+	m.Scale(1/m.At(2, 2), &m)
+	return &Homography{&m}
+}
+
+// Panorama stitches images into one image in the plane of a reference image, each warped into it by its
+// homography. Since that is a plane, the images together cannot see more than 180 degrees around.
+type Panorama struct {
+	bounds image.Rectangle
+	origin image.Point
+	blend  string
+	views  []panoramaView
+}
+
+// panoramaView is where one image is in the panorama.
+type panoramaView struct {
+	size image.Point
+	// bounds are the pixels of the panorama the image can cover.
+	bounds image.Rectangle
+	// toImage maps the pixels of bounds, from the corner of bounds, to the pixels of the image.
+	toImage rimage.TransformationMatrix
+}
+
+// NewPanorama returns the panorama of images of the sizes, where each homography maps the pixels of its
+// image to the pixels of the reference image. The panorama is as big as needed to hold every image, and
+// blend is PanoramaBlendFeather, the default, or PanoramaBlendSeam.
+func NewPanorama(sizes []image.Point, homographies []*Homography, blend string) (*Panorama, error) {
+	if len(sizes) == 0 {
+		return nil, errors.New("a panorama needs at least one image")
+	}
+	if len(sizes) != len(homographies) {
+		return nil, errors.Errorf("a panorama needs a homography for each of its %d images, got %d", len(sizes), len(homographies))
+	}
+	switch blend {
+	case "":
+		blend = PanoramaBlendFeather
+	case PanoramaBlendFeather, PanoramaBlendSeam:
+	default:
+		return nil, errors.Errorf("panorama blend must be %q or %q, got %q", PanoramaBlendFeather, PanoramaBlendSeam, blend)
+	}
+	// the bounds of each image are found in the plane of the reference image before moving them all so that
+	// the panorama starts at the origin.
+	planeBounds := make([]image.Rectangle, len(sizes))
+	var all image.Rectangle
+	for i, size := range sizes {
+		if size.X <= 0 || size.Y <= 0 {
+			return nil, errors.Errorf("image %d is empty", i)
+		}
+		h := homographies[i]
+		minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+		for _, corner := range []r2.Point{{X: 0, Y: 0}, {X: float64(size.X), Y: 0}, {X: 0, Y: float64(size.Y)}, {X: float64(size.X), Y: float64(size.Y)}} {
+			if h.At(2, 0)*corner.X+h.At(2, 1)*corner.Y+h.At(2, 2) <= 0 {
+				return nil, errors.Errorf("image %d reaches behind the plane of the panorama, "+
+					"the images may see too far around for one panorama", i)
+			}
+			p := h.Apply(corner)
+			minX, minY = math.Min(minX, p.X), math.Min(minY, p.Y)
+			maxX, maxY = math.Max(maxX, p.X), math.Max(maxY, p.Y)
+		}
+		planeBounds[i] = image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY)))
+		all = all.Union(planeBounds[i])
+	}
+	if all.Dx() > maxPanoramaSidePx || all.Dy() > maxPanoramaSidePx {
+		return nil, errors.Errorf("the panorama would be %dx%d, the homographies may be wrong", all.Dx(), all.Dy())
+	}
+	p := &Panorama{bounds: all.Sub(all.Min), origin: image.Point{}.Sub(all.Min), blend: blend, views: make([]panoramaView, len(sizes))}
+	for i, size := range sizes {
+		inv, err := homographies[i].Inverse()
+		if err != nil {
+			return nil, errors.Wrapf(err, "image %d", i)
+		}
+		origin := planeBounds[i].Min
+		shift := mat.NewDense(3, 3, []float64{1, 0, float64(origin.X), 0, 1, float64(origin.Y), 0, 0, 1})
+		var toImage mat.Dense
+		toImage.Mul(inv.matrix, shift)
+		p.views[i] = panoramaView{
+			size:    size,
+			bounds:  planeBounds[i].Sub(all.Min),
+			toImage: rimage.TransformationMatrix{toImage.RawRowView(0), toImage.RawRowView(1), toImage.RawRowView(2)},
+		}
+	}
+	return p, nil
+}
+
+// Bounds returns the bounds of the stitched images.
+func (p *Panorama) Bounds() image.Rectangle {
+	return p.bounds
+}
+
+// Origin returns the pixel of the panorama at the origin of the reference image.
+func (p *Panorama) Origin() image.Point {
+	return p.origin
+}
+
+// Stitch warps the images, of the sizes the panorama was made for, into the panorama and blends them. Pixels
+// no image covers are transparent.
+func (p *Panorama) Stitch(imgs []image.Image) (*image.RGBA, error) {
+	if len(imgs) != len(p.views) {
+		return nil, errors.Errorf("panorama is of %d images, got %d", len(p.views), len(imgs))
+	}
+	sums := make([]float64, 4*p.bounds.Dx()*p.bounds.Dy())
+	for i, img := range imgs {
+		view := p.views[i]
+		b := img.Bounds()
+		if b.Size() != view.size {
+			return nil, errors.Errorf("image %d is %dx%d, but the panorama was made for %dx%d",
+				i, b.Dx(), b.Dy(), view.size.X, view.size.Y)
+		}
+		src, ok := img.(*image.RGBA)
+		if !ok || b.Min != (image.Point{}) {
+			src = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+			draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+		}
+		rimage.Warp(&panoramaConnector{src: src, bounds: view.bounds, stride: p.bounds.Dx(), sums: sums, blend: p.blend}, view.toImage)
+	}
+	out := image.NewRGBA(p.bounds)
+	for i := 0; i < len(sums); i += 4 {
+		if sums[i+3] <= 0 {
+			continue
+		}
+		for c := 0; c < 3; c++ {
+			out.Pix[i+c] = uint8(math.Round(math.Min(255, math.Max(0, sums[i+c]/sums[i+3]))))
+		}
+		out.Pix[i+3] = 255
+	}
+	return out, nil
+}
+
+// panoramaConnector warps an image into the bounds of its view of the panorama, adding its colors, weighted
+// by how far they are from its edges, to the weighted sums of the colors of the panorama.
+type panoramaConnector struct {
+	src    *image.RGBA
+	bounds image.Rectangle
+	stride int
+	sums   []float64
+	blend  string
+}
+
+// Get returns the weighted color of a pixel of the image and its weight.
+func (c *panoramaConnector) Get(x, y int, buf []float64) bool {
+	b := c.src.Bounds()
+	if x < 0 || y < 0 || x >= b.Max.X || y >= b.Max.Y {
+		return false
+	}
+	w := float64(min(x+1, y+1, b.Max.X-x, b.Max.Y-y))
+	pix := c.src.Pix[c.src.PixOffset(x, y):]
+	buf[0], buf[1], buf[2], buf[3] = w*float64(pix[0]), w*float64(pix[1]), w*float64(pix[2]), w
+	return true
+}
+
+// Set adds the weighted color of a pixel of the bounds to the panorama, or replaces the color of the
+// panorama with it if it is further from the edges of its image when blending at seams.
+func (c *panoramaConnector) Set(x, y int, data []float64) {
+	// pixels mapped outside of the image have no weight, or a weight of NaN.
+	if !(data[3] > 0) {
+		return
+	}
+	i := 4 * ((c.bounds.Min.Y+y)*c.stride + c.bounds.Min.X + x)
+	sum := c.sums[i : i+4]
+	if c.blend == PanoramaBlendSeam {
+		if data[3] > sum[3] {
+			copy(sum, data)
+		}
+		return
+	}
+	for k := range sum {
+		sum[k] += data[k]
+	}
+}
+
+// OutputDims returns the size of the bounds, as the pixels are indexed by x and then y.
+func (c *panoramaConnector) OutputDims() (int, int) {
+	return c.bounds.Dx(), c.bounds.Dy()
+}
+
+// NumFields returns the 3 weighted channels of color and their weight.
+func (c *panoramaConnector) NumFields() int {
+	return 4
+}
@@ -0,0 +1,141 @@
+package transform
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"github.com/golang/geo/r2"
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/logging"
+)
+
+// panoramaTestScene returns an image of random gray blocks, with plenty of corners to match.
+func panoramaTestScene(width, height int, seed int64) *image.RGBA {
+	const block = 8
+	rng := rand.New(rand.NewSource(seed))
+	scene := image.NewRGBA(image.Rect(0, 0, width, height))
+	for by := 0; by < height; by += block {
+		for bx := 0; bx < width; bx += block {
+			v := uint8(rng.Intn(256))
+			for y := by; y < min(by+block, height); y++ {
+				for x := bx; x < min(bx+block, width); x++ {
+					scene.SetRGBA(x, y, color.RGBA{v, v / 2, 255 - v, 255})
+				}
+			}
+		}
+	}
+	return scene
+}
+
+func TestEstimatePanoramaHomographies(t *testing.T) {
+	logger := logging.NewTestLogger(t)
+	scene := panoramaTestScene(400, 160, 1)
+	// three cameras seeing windows of the scene overlapping by half, each 100 pixels right of the last.
+	offsets := []int{0, 100, 200}
+	imgs := make([]image.Image, len(offsets))
+	for i, x := range offsets {
+		imgs[i] = scene.SubImage(image.Rect(x, 0, x+200, 160))
+	}
+	homographies, err := EstimatePanoramaHomographies(imgs, logger)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, homographies, test.ShouldHaveLength, 3)
+	for i, h := range homographies {
+		for _, p := range []r2.Point{{X: 20, Y: 20}, {X: 180, Y: 30}, {X: 80, Y: 140}} {
+			q := h.Apply(p)
+			test.That(t, q.X, test.ShouldAlmostEqual, p.X+float64(offsets[i]-offsets[1]), 0.5)
+			test.That(t, q.Y, test.ShouldAlmostEqual, p.Y, 0.5)
+		}
+	}
+
+	// images which do not overlap cannot be matched.
+	_, err = EstimatePanoramaHomographies([]image.Image{imgs[0], panoramaTestScene(200, 160, 2)}, logger)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	// stitching the images gives back the scene.
+	sizes := []image.Point{{200, 160}, {200, 160}, {200, 160}}
+	for _, blend := range []string{PanoramaBlendFeather, PanoramaBlendSeam} {
+		p, err := NewPanorama(sizes, homographies, blend)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, p.Bounds().Dx(), test.ShouldBeBetweenOrEqual, 398, 402)
+		test.That(t, p.Bounds().Dy(), test.ShouldBeBetweenOrEqual, 158, 162)
+		pano, err := p.Stitch(imgs)
+		test.That(t, err, test.ShouldBeNil)
+		// the middle image is the reference, 100 pixels into the scene.
+		origin := p.Origin().Sub(image.Pt(offsets[1], 0))
+		same := 0
+		for y := 10; y < 150; y++ {
+			for x := 10; x < 390; x++ {
+				want, got := scene.RGBAAt(x, y), pano.RGBAAt(x+origin.X, y+origin.Y)
+				if absDiff(want.R, got.R) <= 8 && absDiff(want.B, got.B) <= 8 && got.A == 255 {
+					same++
+				}
+			}
+		}
+		// pixels on the edges of the blocks are blurred a little by subpixel errors.
+		test.That(t, float64(same)/(140*380), test.ShouldBeGreaterThan, 0.8)
+	}
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func TestPanorama(t *testing.T) {
+	red := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	blue := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	for i := 0; i < len(red.Pix); i += 4 {
+		copy(red.Pix[i:], []uint8{200, 0, 0, 255})
+		copy(blue.Pix[i:], []uint8{0, 0, 200, 255})
+	}
+	identity, err := NewHomography([]float64{1, 0, 0, 0, 1, 0, 0, 0, 1})
+	test.That(t, err, test.ShouldBeNil)
+	// the blue image is 10 pixels right and 5 pixels down of the red one.
+	shift, err := NewHomography([]float64{1, 0, 10, 0, 1, 5, 0, 0, 1})
+	test.That(t, err, test.ShouldBeNil)
+	sizes := []image.Point{{20, 10}, {20, 10}}
+
+	p, err := NewPanorama(sizes, []*Homography{identity, shift}, "")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, p.Bounds(), test.ShouldResemble, image.Rect(0, 0, 30, 15))
+	test.That(t, p.Origin(), test.ShouldResemble, image.Point{})
+	pano, err := p.Stitch([]image.Image{red, blue})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pano.RGBAAt(2, 2), test.ShouldResemble, color.RGBA{200, 0, 0, 255})
+	test.That(t, pano.RGBAAt(27, 12), test.ShouldResemble, color.RGBA{0, 0, 200, 255})
+	// nothing is seen at the corners outside of both images.
+	test.That(t, pano.RGBAAt(27, 2).A, test.ShouldEqual, 0)
+	test.That(t, pano.RGBAAt(2, 12).A, test.ShouldEqual, 0)
+	// the overlap is blended, more of the image the pixel is further into.
+	c := pano.RGBAAt(15, 7)
+	test.That(t, c.R, test.ShouldBeGreaterThan, 0)
+	test.That(t, c.B, test.ShouldBeGreaterThan, 0)
+	c = pano.RGBAAt(12, 6)
+	test.That(t, c.R, test.ShouldBeGreaterThan, c.B)
+
+	p, err = NewPanorama(sizes, []*Homography{identity, shift}, PanoramaBlendSeam)
+	test.That(t, err, test.ShouldBeNil)
+	pano, err = p.Stitch([]image.Image{red, blue})
+	test.That(t, err, test.ShouldBeNil)
+	c = pano.RGBAAt(15, 7)
+	test.That(t, c.R == 0 || c.B == 0, test.ShouldBeTrue)
+
+	_, err = p.Stitch([]image.Image{red})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = p.Stitch([]image.Image{red, image.NewRGBA(image.Rect(0, 0, 5, 5))})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = NewPanorama(sizes, []*Homography{identity}, "")
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = NewPanorama(sizes, []*Homography{identity, shift}, "average")
+	test.That(t, err, test.ShouldNotBeNil)
+	// a homography taking the image behind the plane of the panorama.
+	behind, err := NewHomography([]float64{1, 0, 0, 0, 1, 0, -0.1, 0, 1})
+	test.That(t, err, test.ShouldBeNil)
+	_, err = NewPanorama(sizes, []*Homography{identity, behind}, "")
+	test.That(t, err, test.ShouldNotBeNil)
+}
@@ -0,0 +1,365 @@
+package transform
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"math/bits"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/rdk/rimage"
+)
+
+// the methods of matching the pixels of rectified stereo images.
+const (
+	// StereoBlockMatching matches blocks of pixels by the sum of their absolute differences. It is fast, but
+	// leaves holes where there is little texture.
+	StereoBlockMatching = "block_matching"
+	// StereoSGM is semi-global matching of the census transforms of the images along four paths, which fills
+	// in more of the image and keeps the edges of objects sharper than block matching.
+	StereoSGM = "sgm"
+)
+
+const (
+	defaultStereoNumDisparities         = 64
+	defaultStereoBlockSize              = 5
+	defaultStereoP1                     = 4
+	defaultStereoP2                     = 32
+	defaultStereoUniquenessRatio        = 0.05
+	maxStereoBlockMatchingSize          = 15
+	maxStereoCensusSize                 = 7
+	maxStereoP2                         = 1000
+	invalidDisparity                    = float32(-1)
+	stereoInvalidLuminance       uint16 = math.MaxUint8
+)
+
+// StereoMatchingConfig is how the disparity of rectified stereo images is computed.
+type StereoMatchingConfig struct {
+	// Method is StereoSGM, the default, or StereoBlockMatching.
+	Method string `json:"method,omitempty"`
+	// disparities from MinDisparity to MinDisparity+NumDisparities-1 are searched, 0 to 63 by default.
+	MinDisparity   int `json:"min_disparity,omitempty"`
+	NumDisparities int `json:"num_disparities,omitempty"`
+	// BlockSize is the odd width of the blocks matched, or of the window of the census transform, 5 by
+	// default.
+	BlockSize int `json:"block_size,omitempty"`
+	// P1 and P2 are the penalties of semi-global matching for changes of disparity of one and of more between
+	// neighboring pixels.
+	P1 int `json:"p1,omitempty"`
+	P2 int `json:"p2,omitempty"`
+	// UniquenessRatio is how much better than any other disparity the best of a pixel must match for the
+	// pixel to have a disparity.
+	UniquenessRatio float64 `json:"uniqueness_ratio,omitempty"`
+}
+
+// CheckValid checks the config, returning an error describing the first invalid field.
+func (cfg *StereoMatchingConfig) CheckValid() error {
+	switch cfg.Method {
+	case "", StereoSGM, StereoBlockMatching:
+	default:
+		return errors.Errorf("stereo matching method must be %q or %q, got %q", StereoSGM, StereoBlockMatching, cfg.Method)
+	}
+	if cfg.MinDisparity < 0 {
+		return errors.Errorf("min_disparity cannot be negative, got %d", cfg.MinDisparity)
+	}
+	if cfg.NumDisparities < 0 {
+		return errors.Errorf("num_disparities cannot be negative, got %d", cfg.NumDisparities)
+	}
+	if cfg.BlockSize < 0 || (cfg.BlockSize != 0 && cfg.BlockSize%2 == 0) {
+		return errors.Errorf("block_size must be odd, got %d", cfg.BlockSize)
+	}
+	if cfg.Method == StereoBlockMatching && cfg.BlockSize > maxStereoBlockMatchingSize {
+		return errors.Errorf("block_size cannot be more than %d for block matching, got %d", maxStereoBlockMatchingSize, cfg.BlockSize)
+	}
+	if cfg.Method != StereoBlockMatching && cfg.BlockSize > maxStereoCensusSize {
+		return errors.Errorf("block_size cannot be more than %d for semi-global matching, got %d", maxStereoCensusSize, cfg.BlockSize)
+	}
+	if cfg.P1 < 0 || cfg.P2 < 0 || cfg.P2 > maxStereoP2 {
+		return errors.Errorf("p1 and p2 must be between 0 and %d, got %d and %d", maxStereoP2, cfg.P1, cfg.P2)
+	}
+	if cfg.P1 != 0 && cfg.P2 != 0 && cfg.P2 < cfg.P1 {
+		return errors.Errorf("p2 cannot be less than p1, got %d and %d", cfg.P2, cfg.P1)
+	}
+	if cfg.UniquenessRatio < 0 || cfg.UniquenessRatio >= 1 {
+		return errors.Errorf("uniqueness_ratio must be at least 0 and less than 1, got %v", cfg.UniquenessRatio)
+	}
+	return nil
+}
+
+// DisparityMap is the disparity in pixels of each pixel of the left of a pair of rectified stereo images,
+// which is how far to the left it is in the right image. Pixels without a disparity have a negative one.
+type DisparityMap struct {
+	Width, Height int
+	Data          []float32
+}
+
+// At returns the disparity of a pixel.
+func (dm *DisparityMap) At(x, y int) float32 {
+	return dm.Data[y*dm.Width+x]
+}
+
+// ToDepthMap returns the depth of each pixel with a disparity, for images of cameras with the focal length
+// in pixels a baseline apart.
+func (dm *DisparityMap) ToDepthMap(focalPx, baselineMM float64) *rimage.DepthMap {
+	depth := rimage.NewEmptyDepthMap(dm.Width, dm.Height)
+	for y := 0; y < dm.Height; y++ {
+		for x := 0; x < dm.Width; x++ {
+			d := float64(dm.At(x, y))
+			if d <= 0 {
+				continue
+			}
+			depth.Set(x, y, rimage.Depth(math.Min(math.Round(focalPx*baselineMM/d), float64(rimage.MaxDepth))))
+		}
+	}
+	return depth
+}
+
+// ComputeDisparity returns the disparity of the left of a pair of rectified images of the same size.
+// Transparent pixels, such as those outside of the camera in images from StereoRectification, have no
+// disparity, and neither do the leftmost MinDisparity+NumDisparities-1 columns, whose matches could be off
+// the right image.
+func ComputeDisparity(left, right image.Image, cfg *StereoMatchingConfig) (*DisparityMap, error) {
+	if cfg == nil {
+		cfg = &StereoMatchingConfig{}
+	}
+	if err := cfg.CheckValid(); err != nil {
+		return nil, err
+	}
+	if left.Bounds().Size() != right.Bounds().Size() {
+		return nil, errors.Errorf("stereo images must be the same size, got %v and %v", left.Bounds().Size(), right.Bounds().Size())
+	}
+	numDisparities := cfg.NumDisparities
+	if numDisparities == 0 {
+		numDisparities = defaultStereoNumDisparities
+	}
+	blockSize := cfg.BlockSize
+	if blockSize == 0 {
+		blockSize = defaultStereoBlockSize
+	}
+	uniqueness := cfg.UniquenessRatio
+	if uniqueness == 0 {
+		uniqueness = defaultStereoUniquenessRatio
+	}
+
+	l, lValid := stereoLuminance(left)
+	r, _ := stereoLuminance(right)
+	w, h := left.Bounds().Dx(), left.Bounds().Dy()
+	var costs []uint16
+	if cfg.Method == StereoBlockMatching {
+		costs = blockMatchingCosts(l, r, w, h, cfg.MinDisparity, numDisparities, blockSize)
+	} else {
+		p1, p2 := cfg.P1, cfg.P2
+		if p1 == 0 {
+			p1 = defaultStereoP1
+		}
+		if p2 == 0 {
+			p2 = max(defaultStereoP2, p1)
+		}
+		costs = sgmCosts(l, r, w, h, cfg.MinDisparity, numDisparities, blockSize, int32(p1), int32(p2))
+	}
+	return bestDisparities(costs, lValid, w, h, cfg.MinDisparity, numDisparities, uniqueness), nil
+}
+
+// stereoLuminance returns the luminance of each pixel of the image and whether it is opaque.
+func stereoLuminance(img image.Image) ([]uint8, []bool) {
+	bounds := img.Bounds()
+	rgba, ok := img.(*image.RGBA)
+	if !ok {
+		rgba = image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+	}
+	gray := image.NewGray(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(gray, gray.Bounds(), rgba, rgba.Bounds().Min, draw.Src)
+	valid := make([]bool, len(gray.Pix))
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			valid[y*bounds.Dx()+x] = rgba.Pix[rgba.PixOffset(x+rgba.Rect.Min.X, y+rgba.Rect.Min.Y)+3] != 0
+		}
+	}
+	return gray.Pix, valid
+}
+
+// blockMatchingCosts returns the sum of absolute differences of the block around each pixel of the left image
+// and the block at each disparity in the right image. Blocks off the right image cost the most.
+func blockMatchingCosts(l, r []uint8, w, h, minDisparity, numDisparities, blockSize int) []uint16 {
+	costs := make([]uint16, w*h*numDisparities)
+	radius := blockSize / 2
+	diff := make([]uint16, w*h)
+	rows := make([]uint32, w*h)
+	for k := 0; k < numDisparities; k++ {
+		d := minDisparity + k
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				i := y*w + x
+				if x-d < 0 {
+					diff[i] = stereoInvalidLuminance
+					continue
+				}
+				a, b := int(l[i]), int(r[i-d])
+				if a > b {
+					diff[i] = uint16(a - b)
+				} else {
+					diff[i] = uint16(b - a)
+				}
+			}
+		}
+		// the sums of the blocks are those of their rows, with blocks clamped to the image.
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				var sum uint32
+				for dx := max(0, x-radius); dx <= min(w-1, x+radius); dx++ {
+					sum += uint32(diff[y*w+dx])
+				}
+				rows[y*w+x] = sum
+			}
+		}
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				var sum uint32
+				for dy := max(0, y-radius); dy <= min(h-1, y+radius); dy++ {
+					sum += rows[dy*w+x]
+				}
+				costs[(y*w+x)*numDisparities+k] = uint16(min(sum, math.MaxUint16))
+			}
+		}
+	}
+	return costs
+}
+
+// census returns the census transform of each pixel, the bits of which of the pixels of the window around it
+// are darker than it.
+func census(img []uint8, w, h, blockSize int) []uint64 {
+	out := make([]uint64, w*h)
+	radius := blockSize / 2
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			center := img[y*w+x]
+			var c uint64
+			for dy := -radius; dy <= radius; dy++ {
+				for dx := -radius; dx <= radius; dx++ {
+					if dx == 0 && dy == 0 {
+						continue
+					}
+					xx, yy := min(max(x+dx, 0), w-1), min(max(y+dy, 0), h-1)
+					c <<= 1
+					if img[yy*w+xx] < center {
+						c |= 1
+					}
+				}
+			}
+			out[y*w+x] = c
+		}
+	}
+	return out
+}
+
+// sgmCosts returns the costs of the census transforms of the images aggregated along the four paths through
+// each pixel along the rows and columns.
+func sgmCosts(l, r []uint8, w, h, minDisparity, numDisparities, blockSize int, p1, p2 int32) []uint16 {
+	cl, cr := census(l, w, h, blockSize), census(r, w, h, blockSize)
+	maxCost := uint8(blockSize*blockSize - 1)
+	costs := make([]uint8, w*h*numDisparities)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			for k := 0; k < numDisparities; k++ {
+				d := minDisparity + k
+				if x-d < 0 {
+					costs[i*numDisparities+k] = maxCost
+					continue
+				}
+				costs[i*numDisparities+k] = uint8(bits.OnesCount64(cl[i] ^ cr[i-d]))
+			}
+		}
+	}
+	sums := make([]uint16, len(costs))
+	prev := make([]int32, numDisparities)
+	cur := make([]int32, numDisparities)
+	aggregate := func(first, step, n int) {
+		aggregateSGMPath(costs, sums, first, step, n, numDisparities, p1, p2, prev, cur)
+	}
+	for y := 0; y < h; y++ {
+		aggregate(y*w, 1, w)
+		aggregate(y*w+w-1, -1, w)
+	}
+	for x := 0; x < w; x++ {
+		aggregate(x, w, h)
+		aggregate((h-1)*w+x, -w, h)
+	}
+	return sums
+}
+
+// aggregateSGMPath adds the costs aggregated along the path of n pixels from the first by step to sums.
+func aggregateSGMPath(costs []uint8, sums []uint16, first, step, n, numDisparities int, p1, p2 int32, prev, cur []int32) {
+	for j := 0; j < n; j++ {
+		i := (first + j*step) * numDisparities
+		if j == 0 {
+			for k := 0; k < numDisparities; k++ {
+				cur[k] = int32(costs[i+k])
+			}
+		} else {
+			minPrev := prev[0]
+			for _, v := range prev[1:] {
+				minPrev = min(minPrev, v)
+			}
+			for k := 0; k < numDisparities; k++ {
+				best := min(prev[k], minPrev+p2)
+				if k > 0 {
+					best = min(best, prev[k-1]+p1)
+				}
+				if k < numDisparities-1 {
+					best = min(best, prev[k+1]+p1)
+				}
+				cur[k] = int32(costs[i+k]) + best - minPrev
+			}
+		}
+		for k := 0; k < numDisparities; k++ {
+			sums[i+k] += uint16(cur[k])
+		}
+		prev, cur = cur, prev
+	}
+}
+
+// bestDisparities returns the disparity of least cost of each valid pixel, refined to a fraction of a pixel
+// by fitting a parabola to the costs around it, if it is better than all others by the uniqueness ratio.
+func bestDisparities(costs []uint16, valid []bool, w, h, minDisparity, numDisparities int, uniqueness float64) *DisparityMap {
+	out := &DisparityMap{Width: w, Height: h, Data: make([]float32, w*h)}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			out.Data[i] = invalidDisparity
+			if !valid[i] {
+				continue
+			}
+			c := costs[i*numDisparities : (i+1)*numDisparities]
+			best := 0
+			for k, v := range c {
+				if v < c[best] {
+					best = k
+				}
+			}
+			if x < minDisparity+numDisparities-1 {
+				continue
+			}
+			second := math.MaxUint16 + 1
+			for k, v := range c {
+				if (k < best-1 || k > best+1) && int(v) < second {
+					second = int(v)
+				}
+			}
+			if float64(c[best])*(1+uniqueness) >= float64(second) {
+				continue
+			}
+			disparity := float64(minDisparity + best)
+			if best > 0 && best < numDisparities-1 {
+				c0, c1, c2 := float64(c[best-1]), float64(c[best]), float64(c[best+1])
+				if denom := c0 - 2*c1 + c2; denom > 0 {
+					disparity += (c0 - c2) / (2 * denom)
+				}
+			}
+			out.Data[i] = float32(disparity)
+		}
+	}
+	return out
+}
@@ -0,0 +1,209 @@
+package transform
+
+import (
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/golang/geo/r3"
+	"github.com/pkg/errors"
+
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/utils"
+)
+
+const (
+	// the cameras of a stereo pair have to be side by side, with their baseline no further from the x axis of
+	// the left camera than this.
+	maxStereoBaselineAngleDeg = 45.
+	// and pointing in about the same direction, rotated by no more than this from each other.
+	maxStereoRotationDeg = 30.
+)
+
+// StereoRectification warps the images of a calibrated pair of cameras into the images of a rectified pair,
+// which share their intrinsics and orientation and differ only by the baseline along their x axis, so that
+// each point is on the same row of both images and its depth is inversely proportional to the difference of
+// its columns, its disparity.
+type StereoRectification struct {
+	// Rectified are the intrinsics of both rectified images, which are the size of the left image.
+	Rectified *PinholeCameraIntrinsics
+	// BaselineMM is the distance between the cameras.
+	BaselineMM float64
+
+	// toLeft rotates points from the frame of the rectified cameras to the frame of the left camera.
+	toLeft      [3][3]float64
+	left, right stereoView
+}
+
+// stereoView is the map from each pixel of a rectified image to where it is in the image of a camera.
+type stereoView struct {
+	width, height int
+	mapX, mapY    []float32
+}
+
+// NewStereoRectification returns the rectification of the pair of cameras with the models, where rightPose
+// is the pose of the right camera in the frame of the left camera, in millimeters. Models without
+// distortion are taken to be of undistorted images.
+func NewStereoRectification(left, right *PinholeCameraModel, rightPose spatialmath.Pose) (*StereoRectification, error) {
+	if left == nil || right == nil {
+		return nil, errors.New("both cameras of a stereo pair need camera models")
+	}
+	if err := left.PinholeCameraIntrinsics.CheckValid(); err != nil {
+		return nil, errors.Wrap(err, "left camera")
+	}
+	if err := right.PinholeCameraIntrinsics.CheckValid(); err != nil {
+		return nil, errors.Wrap(err, "right camera")
+	}
+	if rightPose == nil {
+		return nil, errors.New("stereo pair needs the pose of the right camera")
+	}
+	baseline := rightPose.Point()
+	if baseline.Norm() == 0 {
+		return nil, errors.New("the cameras of a stereo pair cannot be in the same place")
+	}
+	if baseline.X <= 0 {
+		return nil, errors.Errorf("the right camera is not to the right of the left camera, it is at %v, are they swapped?", baseline)
+	}
+	if angle := utils.RadToDeg(math.Acos(baseline.X / baseline.Norm())); angle > maxStereoBaselineAngleDeg {
+		return nil, errors.Errorf("the cameras of a stereo pair must be side by side, "+
+			"but the baseline is %.1f degrees from the x axis of the left camera", angle)
+	}
+	// rightToLeft rotates points from the frame of the right camera to the frame of the left.
+	rightToLeft := rotationOf(rightPose.Orientation())
+	rightAxis := applyRotation(rightToLeft, r3.Vector{Z: 1})
+	trace := rightToLeft[0][0] + rightToLeft[1][1] + rightToLeft[2][2]
+	if angle := utils.RadToDeg(math.Acos(math.Max(-1, math.Min(1, (trace-1)/2)))); angle > maxStereoRotationDeg {
+		return nil, errors.Errorf("the cameras of a stereo pair must point the same way, but they are rotated by %.1f degrees", angle)
+	}
+
+	// the rectified cameras look halfway between the two cameras, with their x axis along the baseline.
+	ex := baseline.Normalize()
+	ey := r3.Vector{Z: 1}.Add(rightAxis).Cross(ex).Normalize()
+	ez := ex.Cross(ey)
+	toLeft := [3][3]float64{
+		{ex.X, ey.X, ez.X},
+		{ex.Y, ey.Y, ez.Y},
+		{ex.Z, ey.Z, ez.Z},
+	}
+	var toRight [3][3]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			// the rotation from the rectified frame to the right is that of the left followed by the inverse
+			// of rightToLeft.
+			for k := 0; k < 3; k++ {
+				toRight[i][j] += rightToLeft[k][i] * toLeft[k][j]
+			}
+		}
+	}
+
+	focal := math.Min(math.Min(left.Fx, left.Fy), math.Min(right.Fx, right.Fy))
+	rectified := &PinholeCameraIntrinsics{
+		Width:  left.Width,
+		Height: left.Height,
+		Fx:     focal,
+		Fy:     focal,
+		Ppx:    (left.Ppx + right.Ppx) / 2,
+		Ppy:    (left.Ppy + right.Ppy) / 2,
+	}
+	return &StereoRectification{
+		Rectified:  rectified,
+		BaselineMM: baseline.Norm(),
+		toLeft:     toLeft,
+		left:       newStereoView(rectified, toLeft, left),
+		right:      newStereoView(rectified, toRight, right),
+	}, nil
+}
+
+// rotationOf returns the matrix rotating points as the orientation does when poses are composed.
+func rotationOf(o spatialmath.Orientation) [3][3]float64 {
+	var m [3][3]float64
+	pose := spatialmath.NewPoseFromOrientation(o)
+	for j, axis := range []r3.Vector{{X: 1}, {Y: 1}, {Z: 1}} {
+		col := spatialmath.Compose(pose, spatialmath.NewPoseFromPoint(axis)).Point()
+		m[0][j], m[1][j], m[2][j] = col.X, col.Y, col.Z
+	}
+	return m
+}
+
+func applyRotation(m [3][3]float64, v r3.Vector) r3.Vector {
+	return r3.Vector{
+		X: m[0][0]*v.X + m[0][1]*v.Y + m[0][2]*v.Z,
+		Y: m[1][0]*v.X + m[1][1]*v.Y + m[1][2]*v.Z,
+		Z: m[2][0]*v.X + m[2][1]*v.Y + m[2][2]*v.Z,
+	}
+}
+
+// newStereoView maps each pixel of the rectified image to the image of the camera, whose frame points in the
+// rectified frame are rotated into by toCamera.
+func newStereoView(rectified *PinholeCameraIntrinsics, toCamera [3][3]float64, cam *PinholeCameraModel) stereoView {
+	view := stereoView{
+		width:  cam.Width,
+		height: cam.Height,
+		mapX:   make([]float32, rectified.Width*rectified.Height),
+		mapY:   make([]float32, rectified.Width*rectified.Height),
+	}
+	for v := 0; v < rectified.Height; v++ {
+		for u := 0; u < rectified.Width; u++ {
+			ray := r3.Vector{X: (float64(u) - rectified.Ppx) / rectified.Fx, Y: (float64(v) - rectified.Ppy) / rectified.Fy, Z: 1}
+			p := applyRotation(toCamera, ray)
+			i := v*rectified.Width + u
+			if p.Z <= 0 {
+				view.mapX[i], view.mapY[i] = -1, -1
+				continue
+			}
+			x, y := p.X/p.Z, p.Y/p.Z
+			if cam.Distortion != nil {
+				x, y = cam.Distortion.Transform(x, y)
+			}
+			view.mapX[i] = float32(x*cam.Fx + cam.Ppx)
+			view.mapY[i] = float32(y*cam.Fy + cam.Ppy)
+		}
+	}
+	return view
+}
+
+// RectifyLeft returns the rectified image of the left camera.
+func (sr *StereoRectification) RectifyLeft(img image.Image) (*image.RGBA, error) {
+	return sr.left.rectify(img, sr.Rectified)
+}
+
+// RectifyRight returns the rectified image of the right camera.
+func (sr *StereoRectification) RectifyRight(img image.Image) (*image.RGBA, error) {
+	return sr.right.rectify(img, sr.Rectified)
+}
+
+// ToLeftCamera returns a point in the frame of the rectified left camera in the frame of the left camera.
+func (sr *StereoRectification) ToLeftCamera(p r3.Vector) r3.Vector {
+	return applyRotation(sr.toLeft, p)
+}
+
+// rectify samples the image at the map of each rectified pixel, bilinearly. Pixels mapped outside of the
+// image are transparent black.
+func (view *stereoView) rectify(img image.Image, rectified *PinholeCameraIntrinsics) (*image.RGBA, error) {
+	bounds := img.Bounds()
+	if bounds.Dx() != view.width || bounds.Dy() != view.height {
+		return nil, errors.Errorf("image is %dx%d but the intrinsics of its camera are for %dx%d",
+			bounds.Dx(), bounds.Dy(), view.width, view.height)
+	}
+	src, ok := img.(*image.RGBA)
+	if !ok || bounds.Min != (image.Point{}) {
+		src = image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+	}
+	out := image.NewRGBA(image.Rect(0, 0, rectified.Width, rectified.Height))
+	for i := range view.mapX {
+		x, y := float64(view.mapX[i]), float64(view.mapY[i])
+		if x < 0 || y < 0 || x > float64(view.width-1) || y > float64(view.height-1) {
+			continue
+		}
+		x0, y0 := int(x), int(y)
+		x1, y1 := min(x0+1, view.width-1), min(y0+1, view.height-1)
+		fx, fy := x-float64(x0), y-float64(y0)
+		for c := 0; c < 4; c++ {
+			top := float64(src.Pix[src.PixOffset(x0, y0)+c])*(1-fx) + float64(src.Pix[src.PixOffset(x1, y0)+c])*fx
+			bottom := float64(src.Pix[src.PixOffset(x0, y1)+c])*(1-fx) + float64(src.Pix[src.PixOffset(x1, y1)+c])*fx
+			out.Pix[4*i+c] = uint8(math.Round(top*(1-fy) + bottom*fy))
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,196 @@
+package transform
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/golang/geo/r3"
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/utils"
+)
+
+// layeredStereoPair returns a rectified pair of images of a random texture at a disparity of 8 behind a square
+// of another texture at a disparity of 20.
+func layeredStereoPair(square image.Rectangle) (*image.Gray, *image.Gray) {
+	const w, h = 120, 80
+	rng := rand.New(rand.NewSource(1))
+	background, foreground := make([]uint8, 2*w*h), make([]uint8, 2*w*h)
+	for i := range background {
+		background[i], foreground[i] = uint8(rng.Intn(256)), uint8(rng.Intn(256))
+	}
+	texture := func(tex []uint8, x, y int) uint8 { return tex[y*2*w+x] }
+	left, right := image.NewGray(image.Rect(0, 0, w, h)), image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			left.Pix[y*w+x] = texture(background, x, y)
+			right.Pix[y*w+x] = texture(background, x+8, y)
+			if image.Pt(x, y).In(square) {
+				left.Pix[y*w+x] = texture(foreground, x, y)
+			}
+			if image.Pt(x+20, y).In(square) {
+				right.Pix[y*w+x] = texture(foreground, x+20, y)
+			}
+		}
+	}
+	return left, right
+}
+
+// fractionNear returns the fraction of the pixels of the region whose disparity is within half a pixel.
+func fractionNear(dm *DisparityMap, region image.Rectangle, disparity float32) float64 {
+	near := 0
+	for y := region.Min.Y; y < region.Max.Y; y++ {
+		for x := region.Min.X; x < region.Max.X; x++ {
+			if math.Abs(float64(dm.At(x, y)-disparity)) < 0.5 {
+				near++
+			}
+		}
+	}
+	return float64(near) / float64(region.Dx()*region.Dy())
+}
+
+func TestComputeDisparity(t *testing.T) {
+	square := image.Rect(50, 20, 90, 60)
+	left, right := layeredStereoPair(square)
+	for _, method := range []string{StereoSGM, StereoBlockMatching} {
+		t.Run(method, func(t *testing.T) {
+			dm, err := ComputeDisparity(left, right, &StereoMatchingConfig{Method: method, NumDisparities: 32})
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, dm.Width, test.ShouldEqual, 120)
+			test.That(t, dm.Height, test.ShouldEqual, 80)
+			// away from the edges of the square, and from the background it hides in the right image.
+			test.That(t, fractionNear(dm, square.Inset(3), 20), test.ShouldBeGreaterThan, 0.95)
+			test.That(t, fractionNear(dm, image.Rect(32, 3, 37, 77), 8), test.ShouldBeGreaterThan, 0.95)
+			test.That(t, fractionNear(dm, image.Rect(95, 3, 117, 77), 8), test.ShouldBeGreaterThan, 0.95)
+			// pixels whose match could be off the right image have no disparity.
+			test.That(t, dm.At(2, 40), test.ShouldBeLessThan, 0)
+			test.That(t, dm.At(30, 40), test.ShouldBeLessThan, 0)
+
+			depth := dm.ToDepthMap(100, 50)
+			test.That(t, depth.GetDepth(70, 40), test.ShouldEqual, 250)
+			test.That(t, depth.GetDepth(2, 40), test.ShouldEqual, 0)
+		})
+	}
+
+	_, err := ComputeDisparity(left, image.NewGray(image.Rect(0, 0, 10, 10)), nil)
+	test.That(t, err, test.ShouldNotBeNil)
+	for _, cfg := range []StereoMatchingConfig{
+		{Method: "graph_cut"},
+		{MinDisparity: -1},
+		{BlockSize: 4},
+		{BlockSize: 9},
+		{Method: StereoBlockMatching, BlockSize: 17},
+		{P1: 10, P2: 5},
+		{P2: 2000},
+		{UniquenessRatio: 1},
+	} {
+		_, err := ComputeDisparity(left, right, &cfg)
+		test.That(t, err, test.ShouldNotBeNil)
+	}
+}
+
+// renderPlane returns the image of a camera with the intrinsics at the pose in the frame of the left camera
+// of a textured plane facing the left camera at the depth.
+func renderPlane(intrinsics *PinholeCameraIntrinsics, pose spatialmath.Pose, depthMM float64) *image.Gray {
+	const cellMM = 12.
+	noise := func(i, j int) float64 {
+		return float64(uint32(i*73856093^j*19349663)%251) / 250
+	}
+	img := image.NewGray(image.Rect(0, 0, intrinsics.Width, intrinsics.Height))
+	origin := pose.Point()
+	for v := 0; v < intrinsics.Height; v++ {
+		for u := 0; u < intrinsics.Width; u++ {
+			along := r3.Vector{X: (float64(u) - intrinsics.Ppx) / intrinsics.Fx, Y: (float64(v) - intrinsics.Ppy) / intrinsics.Fy, Z: 1}
+			dir := spatialmath.Compose(pose, spatialmath.NewPoseFromPoint(along)).Point().Sub(origin)
+			p := origin.Add(dir.Mul((depthMM - origin.Z) / dir.Z))
+			// the texture is bilinearly interpolated noise on a grid.
+			gx, gy := p.X/cellMM, p.Y/cellMM
+			i, j := int(math.Floor(gx)), int(math.Floor(gy))
+			fx, fy := gx-float64(i), gy-float64(j)
+			value := noise(i, j)*(1-fx)*(1-fy) + noise(i+1, j)*fx*(1-fy) + noise(i, j+1)*(1-fx)*fy + noise(i+1, j+1)*fx*fy
+			img.SetGray(u, v, color.Gray{uint8(255 * value)})
+		}
+	}
+	return img
+}
+
+func TestStereoRectification(t *testing.T) {
+	left := &PinholeCameraModel{PinholeCameraIntrinsics: &PinholeCameraIntrinsics{
+		Width: 160, Height: 120, Fx: 150, Fy: 150, Ppx: 80, Ppy: 60,
+	}}
+	right := &PinholeCameraModel{PinholeCameraIntrinsics: &PinholeCameraIntrinsics{
+		Width: 160, Height: 120, Fx: 155, Fy: 152, Ppx: 78, Ppy: 61,
+	}}
+	// the right camera is turned slightly in, and tilted.
+	rightPose := spatialmath.NewPose(
+		r3.Vector{X: 60, Y: 2},
+		&spatialmath.EulerAngles{Roll: utils.DegToRad(1), Pitch: utils.DegToRad(-3), Yaw: utils.DegToRad(0.5)},
+	)
+	sr, err := NewStereoRectification(left, right, rightPose)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, sr.BaselineMM, test.ShouldAlmostEqual, math.Sqrt(60*60+2*2))
+	test.That(t, sr.Rectified.Fx, test.ShouldEqual, 150)
+	test.That(t, sr.Rectified.Width, test.ShouldEqual, 160)
+
+	const depthMM = 800.
+	leftImg := renderPlane(left.PinholeCameraIntrinsics, spatialmath.NewZeroPose(), depthMM)
+	rightImg := renderPlane(right.PinholeCameraIntrinsics, rightPose, depthMM)
+	leftRect, err := sr.RectifyLeft(leftImg)
+	test.That(t, err, test.ShouldBeNil)
+	rightRect, err := sr.RectifyRight(rightImg)
+	test.That(t, err, test.ShouldBeNil)
+	_, err = sr.RectifyRight(image.NewGray(image.Rect(0, 0, 10, 10)))
+	test.That(t, err, test.ShouldNotBeNil)
+
+	dm, err := ComputeDisparity(leftRect, rightRect, &StereoMatchingConfig{NumDisparities: 32})
+	test.That(t, err, test.ShouldBeNil)
+	depth := dm.ToDepthMap(sr.Rectified.Fx, sr.BaselineMM)
+	// the points of the plane are at its depth in the frame of the left camera.
+	good, total := 0, 0
+	for v := 20; v < 100; v++ {
+		for u := 40; u < 140; u++ {
+			total++
+			d := depth.GetDepth(u, v)
+			if d == 0 {
+				continue
+			}
+			x, y, z := sr.Rectified.PixelToPoint(float64(u), float64(v), float64(d))
+			if p := sr.ToLeftCamera(r3.Vector{X: x, Y: y, Z: z}); math.Abs(p.Z-depthMM) < 0.03*depthMM {
+				good++
+			}
+		}
+	}
+	test.That(t, float64(good)/float64(total), test.ShouldBeGreaterThan, 0.9)
+}
+
+func TestStereoRectificationValidation(t *testing.T) {
+	intrinsics := &PinholeCameraIntrinsics{Width: 160, Height: 120, Fx: 150, Fy: 150, Ppx: 80, Ppy: 60}
+	cam := &PinholeCameraModel{PinholeCameraIntrinsics: intrinsics}
+	for _, tc := range []struct {
+		name        string
+		left, right *PinholeCameraModel
+		pose        spatialmath.Pose
+		err         string
+	}{
+		{"no intrinsics", &PinholeCameraModel{}, cam, spatialmath.NewPoseFromPoint(r3.Vector{X: 60}), "left camera"},
+		{"no pose", cam, cam, nil, "pose"},
+		{"same place", cam, cam, spatialmath.NewZeroPose(), "same place"},
+		{"swapped", cam, cam, spatialmath.NewPoseFromPoint(r3.Vector{X: -60}), "swapped"},
+		{"vertical", cam, cam, spatialmath.NewPoseFromPoint(r3.Vector{X: 10, Y: 60}), "side by side"},
+		{
+			"rotated", cam, cam,
+			spatialmath.NewPose(r3.Vector{X: 60}, &spatialmath.EulerAngles{Yaw: utils.DegToRad(40)}),
+			"point the same way",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewStereoRectification(tc.left, tc.right, tc.pose)
+			test.That(t, err, test.ShouldNotBeNil)
+			test.That(t, err.Error(), test.ShouldContainSubstring, tc.err)
+		})
+	}
+}
@@ -0,0 +1,567 @@
+package transform
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
+	"github.com/pkg/errors"
+	"gonum.org/v1/gonum/mat"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/vision/keypoints"
+)
+
+const (
+	// the fewest matches consistent with a motion for the motion to be trusted.
+	odometryMinInliers = 12
+	// matches further than this in pixels from where the motion puts them are outliers.
+	odometryInlierDistPx = 1.5
+	// frames whose matches moved less than this many pixels, at the median, are taken as not moving, since a
+	// monocular camera cannot tell which way it moved from so little.
+	odometryMinParallaxPx    = 1.
+	odometryRANSACIterations = 300
+	odometryRefineIterations = 10
+	odometryPnPSampleSize    = 4
+)
+
+// ErrOdometryLost is returned when the motion of a camera cannot be estimated from the last two frames, such
+// as when it moved too much between them or sees too few features. Tracking starts again from the frame.
+var ErrOdometryLost = errors.New("visual odometry lost track of the camera")
+
+// VisualOdometry estimates the motion of a camera from the ORB keypoints it tracks across its frames. Frames
+// with depth give motions in mm. Frames without depth only give the direction the camera moved in, so their
+// motions are one unit long, and must be scaled from somewhere else.
+type VisualOdometry struct {
+	intrinsics *PinholeCameraIntrinsics
+	sp         *keypoints.SamplePairs
+	prev       *odometryFrame
+	logger     logging.Logger
+}
+
+// odometryFrame is a frame reduced to the keypoints visual odometry tracks.
+type odometryFrame struct {
+	descs []keypoints.Descriptor
+	// pts are the keypoints in normalized image coordinates, on the plane 1 in front of the camera.
+	pts []r2.Point
+	// depths are of the keypoints in mm, 0 where unknown, or nil for frames without depth.
+	depths []float64
+}
+
+// NewVisualOdometry returns a VisualOdometry of a camera with the given intrinsics.
+func NewVisualOdometry(intrinsics *PinholeCameraIntrinsics, logger logging.Logger) (*VisualOdometry, error) {
+	if intrinsics == nil {
+		return nil, errors.New("visual odometry needs the intrinsics of the camera")
+	}
+	if err := intrinsics.CheckValid(); err != nil {
+		return nil, err
+	}
+	return &VisualOdometry{
+		intrinsics: intrinsics,
+		sp: keypoints.GenerateSamplePairs(
+			panoramaORBConfig.BRIEFConf.Sampling, panoramaORBConfig.BRIEFConf.N, panoramaORBConfig.BRIEFConf.PatchSize),
+		logger: logger,
+	}, nil
+}
+
+// Track returns the pose of the camera when it took the frame in the frame of the camera when it took the
+// last frame, with x right, y down and z forward. The depth of the frame may be nil, and must be aligned to
+// the image if not. The pose of the first frame, and of frames after the camera was lost, is the identity.
+func (vo *VisualOdometry) Track(img image.Image, depth *rimage.DepthMap) (spatialmath.Pose, error) {
+	cur, err := vo.newFrame(img, depth)
+	if err != nil {
+		return nil, err
+	}
+	prev := vo.prev
+	vo.prev = cur
+	if prev == nil {
+		return spatialmath.NewZeroPose(), nil
+	}
+	if len(prev.descs) < odometryMinInliers || len(cur.descs) < odometryMinInliers {
+		return nil, errors.Wrapf(ErrOdometryLost, "too few keypoints to match the frames, %d and %d", len(prev.descs), len(cur.descs))
+	}
+	matches := keypoints.MatchDescriptors(
+		prev.descs, cur.descs, &keypoints.MatchingConfig{DoCrossCheck: true, MaxDist: panoramaMaxMatchDistBits}, vo.logger)
+	if len(matches) < odometryMinInliers {
+		return nil, errors.Wrapf(ErrOdometryLost, "only %d keypoints of the frames match", len(matches))
+	}
+	// the motion is estimated from the points of the last frame to the points of this one, and inverted.
+	var rot [3][3]float64
+	var trans r3.Vector
+	if prev.depths != nil {
+		rot, trans, err = vo.estimatePnP(prev, cur, matches)
+	} else {
+		rot, trans, err = vo.estimateEssential(prev, cur, matches)
+	}
+	if err != nil {
+		return nil, err
+	}
+	inv := transposeRotation(rot)
+	orientation, err := orientationOf(inv)
+	if err != nil {
+		return nil, err
+	}
+	return spatialmath.NewPose(applyRotation(inv, trans).Mul(-1), orientation), nil
+}
+
+// Reset forgets the last frame, so that the next frame is tracked from the identity.
+func (vo *VisualOdometry) Reset() {
+	vo.prev = nil
+}
+
+func (vo *VisualOdometry) newFrame(img image.Image, depth *rimage.DepthMap) (*odometryFrame, error) {
+	if img.Bounds().Dx() != vo.intrinsics.Width || img.Bounds().Dy() != vo.intrinsics.Height {
+		return nil, errors.Errorf("frame is %dx%d but the intrinsics of the camera are for %dx%d",
+			img.Bounds().Dx(), img.Bounds().Dy(), vo.intrinsics.Width, vo.intrinsics.Height)
+	}
+	if depth != nil && (depth.Width() != vo.intrinsics.Width || depth.Height() != vo.intrinsics.Height) {
+		return nil, errors.Errorf("depth of frame is %dx%d but the intrinsics of the camera are for %dx%d",
+			depth.Width(), depth.Height(), vo.intrinsics.Width, vo.intrinsics.Height)
+	}
+	gray := image.NewGray(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(gray, gray.Bounds(), img, img.Bounds().Min, draw.Src)
+	descs, kps, err := describedKeypoints(gray, vo.sp)
+	if err != nil {
+		return nil, err
+	}
+	frame := &odometryFrame{descs: descs, pts: make([]r2.Point, len(kps))}
+	for i, kp := range kps {
+		frame.pts[i] = r2.Point{
+			X: (float64(kp.X) - vo.intrinsics.Ppx) / vo.intrinsics.Fx,
+			Y: (float64(kp.Y) - vo.intrinsics.Ppy) / vo.intrinsics.Fy,
+		}
+	}
+	if depth != nil {
+		frame.depths = make([]float64, len(kps))
+		for i, kp := range kps {
+			frame.depths[i] = float64(depth.Get(kp))
+		}
+	}
+	return frame, nil
+}
+
+// inlierDist is the distance in normalized image coordinates of odometryInlierDistPx.
+func (vo *VisualOdometry) inlierDist() float64 {
+	return odometryInlierDistPx / math.Max(vo.intrinsics.Fx, vo.intrinsics.Fy)
+}
+
+// estimateEssential returns the rotation and unit translation taking points from the frame of the last camera
+// to the frame of this one, from the essential matrix of the matches, from Multiple View Geometry. Richard
+// Hartley and Andrew Zisserman. Sec 9.6 p257.
+func (vo *VisualOdometry) estimateEssential(
+	prev, cur *odometryFrame,
+	matches []keypoints.DescriptorMatch,
+) ([3][3]float64, r3.Vector, error) {
+	pts1, pts2 := make([]r2.Point, len(matches)), make([]r2.Point, len(matches))
+	parallax := make([]float64, len(matches))
+	for i, m := range matches {
+		pts1[i], pts2[i] = prev.pts[m.Idx1], cur.pts[m.Idx2]
+		parallax[i] = pts2[i].Sub(pts1[i]).Norm()
+	}
+	sort.Float64s(parallax)
+	if parallax[len(parallax)/2] < odometryMinParallaxPx/math.Max(vo.intrinsics.Fx, vo.intrinsics.Fy) {
+		return identityRotation(), r3.Vector{}, nil
+	}
+
+	// sampson distances are squared.
+	thresh := vo.inlierDist() * vo.inlierDist()
+	inliersOf := func(e *mat.Dense) ([]int, float64) {
+		inliers, cost := []int{}, 0.
+		for k := range pts1 {
+			d := sampsonDistance(e, pts1[k], pts2[k])
+			if d < thresh {
+				inliers = append(inliers, k)
+			}
+			cost += math.Min(d, thresh)
+		}
+		return inliers, cost
+	}
+	// samples are scored by how far their matches are from fitting them, rather than by how many fit them,
+	// since most motions close to the one that happened fit most of the matches.
+	rng := rand.New(rand.NewSource(1))
+	var inliers []int
+	bestCost := math.Inf(1)
+	sample1, sample2 := make([]r2.Point, 8), make([]r2.Point, 8)
+	for i := 0; i < odometryRANSACIterations; i++ {
+		for j, k := range rng.Perm(len(pts1))[:8] {
+			sample1[j], sample2[j] = pts1[k], pts2[k]
+		}
+		e, err := fitEssentialMatrix(sample1, sample2)
+		if err != nil {
+			continue
+		}
+		if current, cost := inliersOf(e); cost < bestCost {
+			inliers, bestCost = current, cost
+		}
+	}
+	// the essential matrix of the best 8 matches is refined to fit all the matches consistent with it.
+	var e *mat.Dense
+	for pass := 0; pass < 2; pass++ {
+		if len(inliers) < odometryMinInliers {
+			return [3][3]float64{}, r3.Vector{}, errors.Wrapf(
+				ErrOdometryLost, "only %d of %d matches of the frames agree on a motion", len(inliers), len(matches))
+		}
+		in1, in2 := make([]r2.Point, len(inliers)), make([]r2.Point, len(inliers))
+		for i, k := range inliers {
+			in1[i], in2[i] = pts1[k], pts2[k]
+		}
+		var err error
+		if e, err = fitEssentialMatrix(in1, in2); err != nil {
+			return [3][3]float64{}, r3.Vector{}, err
+		}
+		inliers, _ = inliersOf(e)
+	}
+
+	// of the 4 motions of the essential matrix, the one seeing the matched points in front of both cameras is
+	// the one that happened.
+	rotA, rotB, t, err := DecomposeEssentialMatrix(e)
+	if err != nil {
+		return [3][3]float64{}, r3.Vector{}, err
+	}
+	trans := r3.Vector{X: t.At(0, 0), Y: t.At(1, 0), Z: t.At(2, 0)}.Normalize()
+	var best [3][3]float64
+	var bestTrans r3.Vector
+	bestInFront := -1
+	for _, r := range []*mat.Dense{rotA, rotB} {
+		rot := toArray(r)
+		for _, candidate := range []r3.Vector{trans, trans.Mul(-1)} {
+			inFront := 0
+			for _, k := range inliers {
+				if d1, d2 := triangulatedDepths(rot, candidate, pts1[k], pts2[k]); d1 > 0 && d2 > 0 {
+					inFront++
+				}
+			}
+			if inFront > bestInFront {
+				best, bestTrans, bestInFront = rot, candidate, inFront
+			}
+		}
+	}
+	if bestInFront < len(inliers)/2 {
+		return [3][3]float64{}, r3.Vector{}, errors.Wrap(ErrOdometryLost, "no motion sees the matched points in front of the camera")
+	}
+	in1, in2 := make([]r2.Point, len(inliers)), make([]r2.Point, len(inliers))
+	for i, k := range inliers {
+		in1[i], in2[i] = pts1[k], pts2[k]
+	}
+	rot, trans := refineEssential(in1, in2, best, bestTrans)
+	return rot, trans, nil
+}
+
+// refineEssential refines the rotation and unit translation of an essential matrix to minimize the sampson
+// distances of the matches, with Gauss-Newton iterations, since fitting the matrix linearly does not.
+func refineEssential(pts1, pts2 []r2.Point, rot [3][3]float64, trans r3.Vector) ([3][3]float64, r3.Vector) {
+	const eps = 1e-7
+	residuals := func(rot [3][3]float64, trans r3.Vector) []float64 {
+		e := essentialOf(rot, trans)
+		res := make([]float64, len(pts1))
+		for k := range pts1 {
+			res[k] = signedSampsonDistance(e, pts1[k], pts2[k])
+		}
+		return res
+	}
+	// the update is a small rotation w after rot, and moving the translation by a and b along two directions
+	// perpendicular to it, keeping it one unit long.
+	update := func(rot [3][3]float64, trans r3.Vector, delta []float64) ([3][3]float64, r3.Vector) {
+		b1 := trans.Ortho()
+		b2 := trans.Cross(b1)
+		return mulRotations(rodrigues(r3.Vector{X: delta[0], Y: delta[1], Z: delta[2]}), rot),
+			trans.Add(b1.Mul(delta[3])).Add(b2.Mul(delta[4])).Normalize()
+	}
+	for iter := 0; iter < odometryRefineIterations; iter++ {
+		res := residuals(rot, trans)
+		jac := mat.NewDense(len(pts1), 5, nil)
+		for p := 0; p < 5; p++ {
+			delta := make([]float64, 5)
+			delta[p] = eps
+			moved := residuals(update(rot, trans, delta))
+			for k := range res {
+				jac.Set(k, p, (moved[k]-res[k])/eps)
+			}
+		}
+		var delta mat.VecDense
+		if err := delta.SolveVec(jac, mat.NewVecDense(len(res), res)); err != nil {
+			break
+		}
+		delta.ScaleVec(-1, &delta)
+		rot, trans = update(rot, trans, delta.RawVector().Data)
+		if mat.Norm(&delta, 2) < 1e-9 {
+			break
+		}
+	}
+	return rot, trans
+}
+
+// essentialOf returns the essential matrix of the motion rotating points by rot and then translating them by
+// trans, [trans]x rot.
+func essentialOf(rot [3][3]float64, trans r3.Vector) *mat.Dense {
+	cross := [3][3]float64{{0, -trans.Z, trans.Y}, {trans.Z, 0, -trans.X}, {-trans.Y, trans.X, 0}}
+	e := mulRotations(cross, rot)
+	return mat.NewDense(3, 3, []float64{
+		e[0][0], e[0][1], e[0][2],
+		e[1][0], e[1][1], e[1][2],
+		e[2][0], e[2][1], e[2][2],
+	})
+}
+
+// fitEssentialMatrix returns the essential matrix E with pts2ᵀ E pts1 = 0 with the least algebraic error, of
+// at least 8 matches of normalized image coordinates, with its singular values made 1, 1 and 0.
+func fitEssentialMatrix(pts1, pts2 []r2.Point) (*mat.Dense, error) {
+	a := mat.NewDense(max(len(pts1), 9), 9, nil)
+	for i := range pts1 {
+		p1, p2 := pts1[i], pts2[i]
+		a.SetRow(i, []float64{p2.X * p1.X, p2.X * p1.Y, p2.X, p2.Y * p1.X, p2.Y * p1.Y, p2.Y, p1.X, p1.Y, 1})
+	}
+	var svd mat.SVD
+	if !svd.Factorize(a, mat.SVDFull) {
+		return nil, errors.New("failed to factorize the matches")
+	}
+	var v mat.Dense
+	svd.VTo(&v)
+	e := mat.NewDense(3, 3, mat.Col(nil, 8, &v))
+
+	if !svd.Factorize(e, mat.SVDFull) {
+		return nil, errors.New("failed to factorize the essential matrix")
+	}
+	var u, vt mat.Dense
+	svd.UTo(&u)
+	svd.VTo(&vt)
+	var out mat.Dense
+	out.Product(&u, mat.NewDiagDense(3, []float64{1, 1, 0}), vt.T())
+	return &out, nil
+}
+
+// sampsonDistance is the first order approximation of the squared distance of a match from fitting the
+// essential matrix.
+func sampsonDistance(e *mat.Dense, p1, p2 r2.Point) float64 {
+	d := signedSampsonDistance(e, p1, p2)
+	return d * d
+}
+
+// signedSampsonDistance is the square root of the sampson distance, signed by the side of the epipolar line
+// the match is on.
+func signedSampsonDistance(e *mat.Dense, p1, p2 r2.Point) float64 {
+	ex1 := [3]float64{}
+	etx2 := [3]float64{}
+	x1, x2 := [3]float64{p1.X, p1.Y, 1}, [3]float64{p2.X, p2.Y, 1}
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			ex1[i] += e.At(i, j) * x1[j]
+			etx2[i] += e.At(j, i) * x2[j]
+		}
+	}
+	num := x2[0]*ex1[0] + x2[1]*ex1[1] + x2[2]*ex1[2]
+	den := ex1[0]*ex1[0] + ex1[1]*ex1[1] + etx2[0]*etx2[0] + etx2[1]*etx2[1]
+	if den == 0 {
+		return math.Inf(1)
+	}
+	return num / math.Sqrt(den)
+}
+
+// triangulatedDepths returns the depths in both cameras of the point closest to the rays of a match, where
+// points in the first camera are rotated by rot and then translated by trans into the second.
+func triangulatedDepths(rot [3][3]float64, trans r3.Vector, p1, p2 r2.Point) (float64, float64) {
+	// the rays are s1*d1 from the first camera and c2 + s2*d2 from the second, in the first camera.
+	inv := transposeRotation(rot)
+	d1 := r3.Vector{X: p1.X, Y: p1.Y, Z: 1}
+	d2 := applyRotation(inv, r3.Vector{X: p2.X, Y: p2.Y, Z: 1})
+	c2 := applyRotation(inv, trans).Mul(-1)
+	a, b, c := d1.Dot(d1), d1.Dot(d2), d2.Dot(d2)
+	denom := a*c - b*b
+	if denom < 1e-12 {
+		return 0, 0
+	}
+	s1 := (c*d1.Dot(c2) - b*d2.Dot(c2)) / denom
+	x := d1.Mul(s1)
+	return x.Z, applyRotation(rot, x).Add(trans).Z
+}
+
+// estimatePnP returns the rotation and translation in mm taking points from the frame of the last camera to
+// the frame of this one, that best project the points of the matches with depth in the last frame onto their
+// matches in this one.
+func (vo *VisualOdometry) estimatePnP(
+	prev, cur *odometryFrame,
+	matches []keypoints.DescriptorMatch,
+) ([3][3]float64, r3.Vector, error) {
+	pts3, pts2 := make([]r3.Vector, 0, len(matches)), make([]r2.Point, 0, len(matches))
+	for _, m := range matches {
+		z := prev.depths[m.Idx1]
+		if z <= 0 {
+			continue
+		}
+		p := prev.pts[m.Idx1]
+		pts3 = append(pts3, r3.Vector{X: p.X * z, Y: p.Y * z, Z: z})
+		pts2 = append(pts2, cur.pts[m.Idx2])
+	}
+	if len(pts3) < odometryMinInliers {
+		return [3][3]float64{}, r3.Vector{}, errors.Wrapf(
+			ErrOdometryLost, "only %d matched keypoints of the frames have depth", len(pts3))
+	}
+
+	thresh := vo.inlierDist()
+	inliersOf := func(rot [3][3]float64, trans r3.Vector) ([]int, float64) {
+		inliers, cost := []int{}, 0.
+		for k := range pts3 {
+			d := thresh
+			if q := applyRotation(rot, pts3[k]).Add(trans); q.Z > 0 {
+				d = math.Min(math.Hypot(q.X/q.Z-pts2[k].X, q.Y/q.Z-pts2[k].Y), thresh)
+			}
+			if d < thresh {
+				inliers = append(inliers, k)
+			}
+			cost += d * d
+		}
+		return inliers, cost
+	}
+	rng := rand.New(rand.NewSource(1))
+	var inliers []int
+	bestCost := math.Inf(1)
+	sample3, sample2 := make([]r3.Vector, odometryPnPSampleSize), make([]r2.Point, odometryPnPSampleSize)
+	for i := 0; i < odometryRANSACIterations; i++ {
+		for j, k := range rng.Perm(len(pts3))[:odometryPnPSampleSize] {
+			sample3[j], sample2[j] = pts3[k], pts2[k]
+		}
+		rot, trans, ok := refinePnP(sample3, sample2, identityRotation(), r3.Vector{})
+		if !ok {
+			continue
+		}
+		if current, cost := inliersOf(rot, trans); cost < bestCost {
+			inliers, bestCost = current, cost
+		}
+	}
+	// the motion of the best few matches is refined to fit all the matches consistent with it.
+	rot, trans := identityRotation(), r3.Vector{}
+	for pass := 0; pass < 2; pass++ {
+		if len(inliers) < odometryMinInliers {
+			return [3][3]float64{}, r3.Vector{}, errors.Wrapf(
+				ErrOdometryLost, "only %d of %d matches of the frames agree on a motion", len(inliers), len(pts3))
+		}
+		in3, in2 := make([]r3.Vector, len(inliers)), make([]r2.Point, len(inliers))
+		for i, k := range inliers {
+			in3[i], in2[i] = pts3[k], pts2[k]
+		}
+		var ok bool
+		if rot, trans, ok = refinePnP(in3, in2, rot, trans); !ok {
+			return [3][3]float64{}, r3.Vector{}, errors.Wrap(ErrOdometryLost, "the motion of the frames did not converge")
+		}
+		inliers, _ = inliersOf(rot, trans)
+	}
+	return rot, trans, nil
+}
+
+// refinePnP refines the rotation and translation taking the points into the camera to minimize the error of
+// projecting them onto their normalized image coordinates, with Gauss-Newton iterations. It returns false if
+// the points cannot constrain the motion.
+func refinePnP(pts3 []r3.Vector, pts2 []r2.Point, rot [3][3]float64, trans r3.Vector) ([3][3]float64, r3.Vector, bool) {
+	for iter := 0; iter < odometryRefineIterations; iter++ {
+		// the update is a small rotation w of the points in the camera, and a translation dt after it.
+		var jtj mat.SymDense
+		jtj.ReuseAsSym(6)
+		jtr := mat.NewVecDense(6, nil)
+		for k := range pts3 {
+			q := applyRotation(rot, pts3[k]).Add(trans)
+			if q.Z <= 0 {
+				return rot, trans, false
+			}
+			iz := 1 / q.Z
+			res := [2]float64{pts2[k].X - q.X*iz, pts2[k].Y - q.Y*iz}
+			// the derivatives of the projection by q, times the derivatives of q by w and dt.
+			dproj := [2]r3.Vector{{X: iz, Z: -q.X * iz * iz}, {Y: iz, Z: -q.Y * iz * iz}}
+			for row := 0; row < 2; row++ {
+				// q moves by w x q, so dproj·(w x q) = w·(q x dproj).
+				jw := q.Cross(dproj[row])
+				j := []float64{jw.X, jw.Y, jw.Z, dproj[row].X, dproj[row].Y, dproj[row].Z}
+				jtj.SymRankOne(&jtj, 1, mat.NewVecDense(6, j))
+				jtr.AddScaledVec(jtr, res[row], mat.NewVecDense(6, j))
+			}
+		}
+		var delta mat.VecDense
+		if err := delta.SolveVec(&jtj, jtr); err != nil {
+			return rot, trans, false
+		}
+		w := r3.Vector{X: delta.AtVec(0), Y: delta.AtVec(1), Z: delta.AtVec(2)}
+		dt := r3.Vector{X: delta.AtVec(3), Y: delta.AtVec(4), Z: delta.AtVec(5)}
+		dr := rodrigues(w)
+		rot = mulRotations(dr, rot)
+		trans = applyRotation(dr, trans).Add(dt)
+		if w.Norm() < 1e-9 && dt.Norm() < 1e-6 {
+			break
+		}
+	}
+	return rot, trans, true
+}
+
+// rodrigues returns the matrix rotating about the axis of w by its norm in radians.
+func rodrigues(w r3.Vector) [3][3]float64 {
+	theta := w.Norm()
+	if theta < 1e-12 {
+		return identityRotation()
+	}
+	k := w.Mul(1 / theta)
+	s, c := math.Sin(theta), math.Cos(theta)
+	kv := [3]float64{k.X, k.Y, k.Z}
+	cross := [3][3]float64{{0, -k.Z, k.Y}, {k.Z, 0, -k.X}, {-k.Y, k.X, 0}}
+	var m [3][3]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			m[i][j] = s*cross[i][j] + (1-c)*kv[i]*kv[j]
+			if i == j {
+				m[i][j] += c
+			}
+		}
+	}
+	return m
+}
+
+func identityRotation() [3][3]float64 {
+	return [3][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+}
+
+func transposeRotation(m [3][3]float64) [3][3]float64 {
+	var t [3][3]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			t[i][j] = m[j][i]
+		}
+	}
+	return t
+}
+
+func mulRotations(a, b [3][3]float64) [3][3]float64 {
+	var m [3][3]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				m[i][j] += a[i][k] * b[k][j]
+			}
+		}
+	}
+	return m
+}
+
+func toArray(m mat.Matrix) [3][3]float64 {
+	var a [3][3]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			a[i][j] = m.At(i, j)
+		}
+	}
+	return a
+}
+
+// orientationOf returns the orientation rotating points by the matrix when poses are composed, the inverse of
+// rotationOf.
+func orientationOf(m [3][3]float64) (spatialmath.Orientation, error) {
+	// rotation matrices compose as their transposes.
+	t := transposeRotation(m)
+	return spatialmath.NewRotationMatrix([]float64{
+		t[0][0], t[0][1], t[0][2],
+		t[1][0], t[1][1], t[1][2],
+		t[2][0], t[2][1], t[2][2],
+	})
+}
@@ -0,0 +1,117 @@
+package transform
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/golang/geo/r3"
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/spatialmath"
+)
+
+var odometryTestIntrinsics = &PinholeCameraIntrinsics{Width: 320, Height: 240, Fx: 200, Fy: 200, Ppx: 160, Ppy: 120}
+
+// renderOdometryTestRoom returns what a camera at pos, turned right by yaw radians, sees in a room of walls of
+// random gray blocks, and the depth of it.
+func renderOdometryTestRoom(intrinsics *PinholeCameraIntrinsics, pos r3.Vector, yaw float64) (*image.Gray, *rimage.DepthMap) {
+	const block = 150.
+	room := r3.Vector{X: 1000, Y: 600, Z: 3000}
+	shade := func(i, j, wall int) uint8 {
+		h := uint32(i*73856093) ^ uint32(j*19349663) ^ uint32(wall*83492791)
+		h ^= h >> 13
+		h *= 0x5bd1e995
+		h ^= h >> 15
+		return uint8(h)
+	}
+	sin, cos := math.Sin(yaw), math.Cos(yaw)
+	img := image.NewGray(image.Rect(0, 0, intrinsics.Width, intrinsics.Height))
+	depth := rimage.NewEmptyDepthMap(intrinsics.Width, intrinsics.Height)
+	for y := 0; y < intrinsics.Height; y++ {
+		for x := 0; x < intrinsics.Width; x++ {
+			dc := r3.Vector{X: (float64(x) - intrinsics.Ppx) / intrinsics.Fx, Y: (float64(y) - intrinsics.Ppy) / intrinsics.Fy, Z: 1}
+			d := r3.Vector{X: cos*dc.X + sin*dc.Z, Y: dc.Y, Z: -sin*dc.X + cos*dc.Z}
+			// the nearest wall the ray hits, of the walls at +-x, +-y and +z.
+			dist, wall := math.Inf(1), 0
+			for i, hit := range []float64{
+				(math.Copysign(room.X, d.X) - pos.X) / d.X,
+				(math.Copysign(room.Y, d.Y) - pos.Y) / d.Y,
+				(room.Z - pos.Z) / d.Z,
+			} {
+				if hit > 0 && hit < dist {
+					dist, wall = hit, i
+				}
+			}
+			p := pos.Add(d.Mul(dist))
+			u, v := [3]float64{p.Y, p.X, p.X}[wall], [3]float64{p.Z, p.Z, p.Y}[wall]
+			img.SetGray(x, y, color.Gray{shade(int(math.Floor(u/block)), int(math.Floor(v/block)), wall)})
+			depth.Set(x, y, rimage.Depth(dist))
+		}
+	}
+	return img, depth
+}
+
+func TestVisualOdometry(t *testing.T) {
+	logger := logging.NewTestLogger(t)
+	const stepMM, turn = 50., 2 * math.Pi / 180
+	for _, withDepth := range []bool{true, false} {
+		vo, err := NewVisualOdometry(odometryTestIntrinsics, logger)
+		test.That(t, err, test.ShouldBeNil)
+		// each frame is taken one turn right of and one step forward of the last.
+		pos, yaw := r3.Vector{}, 0.
+		for step := 0; step < 5; step++ {
+			img, depth := renderOdometryTestRoom(odometryTestIntrinsics, pos, yaw)
+			if !withDepth {
+				depth = nil
+			}
+			motion, err := vo.Track(img, depth)
+			test.That(t, err, test.ShouldBeNil)
+			if step == 0 {
+				test.That(t, spatialmath.PoseAlmostEqual(motion, spatialmath.NewZeroPose()), test.ShouldBeTrue)
+			} else {
+				want := r3.Vector{X: math.Sin(turn) * stepMM, Z: math.Cos(turn) * stepMM}
+				got := motion.Point()
+				// keypoints are found to the pixel, which is a few mm of motion from walls meters away.
+				if withDepth {
+					test.That(t, got.Sub(want).Norm(), test.ShouldBeLessThan, 0.25*stepMM)
+				} else {
+					test.That(t, got.Norm(), test.ShouldAlmostEqual, 1, 1e-6)
+					test.That(t, got.Sub(want.Normalize()).Norm(), test.ShouldBeLessThan, 0.2)
+				}
+				forward := spatialmath.Compose(motion, spatialmath.NewPoseFromPoint(r3.Vector{Z: 1})).Point().Sub(got)
+				test.That(t, math.Atan2(forward.X, forward.Z), test.ShouldAlmostEqual, turn, 0.3*math.Pi/180)
+				test.That(t, forward.Y, test.ShouldAlmostEqual, 0, 0.005)
+			}
+			// the camera turns right and then moves forward to where it takes the next frame.
+			yaw += turn
+			pos = pos.Add(r3.Vector{X: math.Sin(yaw) * stepMM, Z: math.Cos(yaw) * stepMM})
+		}
+	}
+}
+
+func TestVisualOdometryLost(t *testing.T) {
+	vo, err := NewVisualOdometry(odometryTestIntrinsics, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	img, depth := renderOdometryTestRoom(odometryTestIntrinsics, r3.Vector{}, 0)
+	_, err = vo.Track(img, depth)
+	test.That(t, err, test.ShouldBeNil)
+	// a frame with nothing to track loses the camera, which is tracked again from it.
+	blank := image.NewGray(img.Bounds())
+	_, err = vo.Track(blank, nil)
+	test.That(t, err, test.ShouldWrap, ErrOdometryLost)
+	motion, err := vo.Track(img, depth)
+	test.That(t, err, test.ShouldWrap, ErrOdometryLost)
+	test.That(t, motion, test.ShouldBeNil)
+	motion, err = vo.Track(img, depth)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, motion.Point().Norm(), test.ShouldBeLessThan, 1)
+
+	_, err = vo.Track(image.NewGray(image.Rect(0, 0, 10, 10)), nil)
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = NewVisualOdometry(nil, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+}
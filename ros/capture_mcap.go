@@ -0,0 +1,386 @@
+package ros
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/geo/r3"
+	"github.com/pkg/errors"
+	v1 "go.viam.com/api/app/datasync/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"go.viam.com/rdk/data"
+	"go.viam.com/rdk/pointcloud"
+)
+
+// MCAPTabularEncoding is how tabular readings are encoded in MCAP messages.
+type MCAPTabularEncoding string
+
+// The encodings of tabular readings.
+const (
+	// MCAPTabularJSON encodes readings as schemaless JSON objects.
+	MCAPTabularJSON MCAPTabularEncoding = "json"
+	// MCAPTabularProtobuf encodes readings as google.protobuf.Struct messages.
+	MCAPTabularProtobuf MCAPTabularEncoding = "protobuf"
+)
+
+// The schemas of the messages converted from capture files. Images and point clouds are written as their
+// Foxglove schemas so that Foxglove displays them.
+const (
+	structSchemaName        = "google.protobuf.Struct"
+	compressedImageSchema   = "foxglove.CompressedImage"
+	pointCloudSchema        = "foxglove.PointCloud"
+	jsonSchemaEncoding      = "jsonschema"
+	protobufSchemaEncoding  = "protobuf"
+	jsonMessageEncoding     = "json"
+	protobufMessageEncoding = "protobuf"
+)
+
+// The channel metadata recording the capture metadata of the readings on a channel.
+const (
+	metadataComponentType = "component_type"
+	metadataComponentName = "component_name"
+	metadataMethodName    = "method_name"
+	metadataFileExtension = "file_extension"
+	metadataTags          = "tags"
+)
+
+const (
+	readImage      = "ReadImage"
+	nextPointCloud = "NextPointCloud"
+	// mmPerMeter converts between the millimeters of rdk point clouds and the meters of Foxglove ones.
+	mmPerMeter = 1000
+)
+
+// The JSON schemas of the Foxglove messages which are written. They only describe the fields which are set.
+const (
+	timeJSONSchema            = `{"type":"object","properties":{"sec":{"type":"integer"},"nsec":{"type":"integer"}}}`
+	compressedImageJSONSchema = `{"type":"object","properties":{"timestamp":` + timeJSONSchema + `,` +
+		`"frame_id":{"type":"string"},"data":{"type":"string","contentEncoding":"base64"},"format":{"type":"string"}}}`
+	pointCloudJSONSchema = `{"type":"object","properties":{"timestamp":` + timeJSONSchema + `,` +
+		`"frame_id":{"type":"string"},"point_stride":{"type":"integer"},"fields":{"type":"array","items":{"type":"object",` +
+		`"properties":{"name":{"type":"string"},"offset":{"type":"integer"},"type":{"type":"integer"}}}},` +
+		`"data":{"type":"string","contentEncoding":"base64"}}}`
+)
+
+// The foxglove.NumericType values of point cloud fields.
+const (
+	numericTypeUint8   = 1
+	numericTypeInt8    = 2
+	numericTypeUint16  = 3
+	numericTypeInt16   = 4
+	numericTypeUint32  = 5
+	numericTypeInt32   = 6
+	numericTypeFloat32 = 7
+	numericTypeFloat64 = 8
+)
+
+type foxgloveTime struct {
+	Sec  int64 `json:"sec"`
+	Nsec int64 `json:"nsec"`
+}
+
+func newFoxgloveTime(t time.Time) foxgloveTime {
+	return foxgloveTime{Sec: t.Unix(), Nsec: int64(t.Nanosecond())}
+}
+
+type foxgloveCompressedImage struct {
+	Timestamp foxgloveTime `json:"timestamp"`
+	FrameID   string       `json:"frame_id"`
+	Data      []byte       `json:"data"`
+	Format    string       `json:"format"`
+}
+
+type foxglovePackedElementField struct {
+	Name   string `json:"name"`
+	Offset uint32 `json:"offset"`
+	Type   int    `json:"type"`
+}
+
+type foxglovePointCloud struct {
+	Timestamp   foxgloveTime                 `json:"timestamp"`
+	FrameID     string                       `json:"frame_id"`
+	PointStride uint32                       `json:"point_stride"`
+	Fields      []foxglovePackedElementField `json:"fields"`
+	Data        []byte                       `json:"data"`
+}
+
+// CaptureMCAPWriter writes the readings of capture files as MCAP messages on topics named
+// /<component name>/<method>. Messages are logged at the time the reading was received and published at
+// the time it was requested.
+type CaptureMCAPWriter struct {
+	w        *MCAPWriter
+	tabular  MCAPTabularEncoding
+	schemas  map[string]uint16
+	channels map[string]uint16
+	sequence map[uint16]uint32
+	skipped  int
+}
+
+// NewCaptureMCAPWriter returns a CaptureMCAPWriter writing an MCAP file to w, encoding tabular readings
+// as tabular.
+func NewCaptureMCAPWriter(w io.Writer, tabular MCAPTabularEncoding) (*CaptureMCAPWriter, error) {
+	switch tabular {
+	case MCAPTabularJSON, MCAPTabularProtobuf:
+	default:
+		return nil, errors.Errorf("unknown tabular encoding %q, expected %q or %q", tabular, MCAPTabularJSON, MCAPTabularProtobuf)
+	}
+	mw, err := NewMCAPWriter(w, "")
+	if err != nil {
+		return nil, err
+	}
+	return &CaptureMCAPWriter{
+		w:        mw,
+		tabular:  tabular,
+		schemas:  map[string]uint16{},
+		channels: map[string]uint16{},
+		sequence: map[uint16]uint32{},
+	}, nil
+}
+
+// Write writes a reading captured with md. Binary readings other than JPEG and PNG images and PCD point
+// clouds are skipped.
+func (cw *CaptureMCAPWriter) Write(md *v1.DataCaptureMetadata, sd *v1.SensorData) error {
+	requested := sd.GetMetadata().GetTimeRequested().AsTime()
+	var schemaName, messageEncoding string
+	var msg []byte
+	var err error
+	switch {
+	case sd.GetStruct() != nil:
+		schemaName, messageEncoding, msg, err = cw.encodeTabular(sd.GetStruct())
+	case md.GetFileExtension() == ".pcd":
+		schemaName, messageEncoding = pointCloudSchema, jsonMessageEncoding
+		msg, err = encodePointCloud(md, sd.GetBinary(), requested)
+	case md.GetFileExtension() == ".jpeg" || md.GetFileExtension() == ".png":
+		schemaName, messageEncoding = compressedImageSchema, jsonMessageEncoding
+		msg, err = json.Marshal(foxgloveCompressedImage{
+			Timestamp: newFoxgloveTime(requested),
+			FrameID:   md.GetComponentName(),
+			Data:      sd.GetBinary(),
+			Format:    strings.TrimPrefix(md.GetFileExtension(), "."),
+		})
+	default:
+		cw.skipped++
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to convert %s reading of %s", md.GetMethodName(), md.GetComponentName())
+	}
+
+	channelID, err := cw.channel(md, schemaName, messageEncoding)
+	if err != nil {
+		return err
+	}
+	seq := cw.sequence[channelID]
+	cw.sequence[channelID] = seq + 1
+	return cw.w.WriteMessage(channelID, seq, unixNanos(sd.GetMetadata().GetTimeReceived().AsTime()), unixNanos(requested), msg)
+}
+
+// Skipped returns how many readings were skipped as they can't be converted.
+func (cw *CaptureMCAPWriter) Skipped() int {
+	return cw.skipped
+}
+
+// Close finishes the MCAP file.
+func (cw *CaptureMCAPWriter) Close() error {
+	return cw.w.Close()
+}
+
+func (cw *CaptureMCAPWriter) encodeTabular(reading *structpb.Struct) (string, string, []byte, error) {
+	if cw.tabular == MCAPTabularProtobuf {
+		msg, err := proto.Marshal(reading)
+		return structSchemaName, protobufMessageEncoding, msg, err
+	}
+	// tabular JSON readings have no schema.
+	msg, err := protojson.Marshal(reading)
+	return "", jsonMessageEncoding, msg, err
+}
+
+// channel returns the ID of the channel of readings captured with md, writing it and its schema the first
+// time it is used.
+func (cw *CaptureMCAPWriter) channel(md *v1.DataCaptureMetadata, schemaName, messageEncoding string) (uint16, error) {
+	topic := "/" + md.GetComponentName() + "/" + md.GetMethodName()
+	if id, ok := cw.channels[topic]; ok {
+		return id, nil
+	}
+	schemaID, err := cw.schema(schemaName)
+	if err != nil {
+		return 0, err
+	}
+	metadata := map[string]string{
+		metadataComponentType: md.GetComponentType(),
+		metadataComponentName: md.GetComponentName(),
+		metadataMethodName:    md.GetMethodName(),
+	}
+	if md.GetFileExtension() != "" {
+		metadata[metadataFileExtension] = md.GetFileExtension()
+	}
+	if len(md.GetTags()) > 0 {
+		metadata[metadataTags] = strings.Join(md.GetTags(), ",")
+	}
+	id := uint16(len(cw.channels) + 1)
+	if err := cw.w.WriteChannel(MCAPChannel{
+		ID:              id,
+		SchemaID:        schemaID,
+		Topic:           topic,
+		MessageEncoding: messageEncoding,
+		Metadata:        metadata,
+	}); err != nil {
+		return 0, err
+	}
+	cw.channels[topic] = id
+	return id, nil
+}
+
+// schema returns the ID of the named schema, writing it the first time it is used. The empty name is the
+// absence of a schema.
+func (cw *CaptureMCAPWriter) schema(name string) (uint16, error) {
+	if name == "" {
+		return 0, nil
+	}
+	if id, ok := cw.schemas[name]; ok {
+		return id, nil
+	}
+	schema := MCAPSchema{ID: uint16(len(cw.schemas) + 1), Name: name}
+	switch name {
+	case structSchemaName:
+		descriptors, err := proto.Marshal(&descriptorpb.FileDescriptorSet{
+			File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(structpb.File_google_protobuf_struct_proto)},
+		})
+		if err != nil {
+			return 0, err
+		}
+		schema.Encoding, schema.Data = protobufSchemaEncoding, descriptors
+	case compressedImageSchema:
+		schema.Encoding, schema.Data = jsonSchemaEncoding, []byte(compressedImageJSONSchema)
+	case pointCloudSchema:
+		schema.Encoding, schema.Data = jsonSchemaEncoding, []byte(pointCloudJSONSchema)
+	}
+	if err := cw.w.WriteSchema(schema); err != nil {
+		return 0, err
+	}
+	cw.schemas[name] = schema.ID
+	return schema.ID, nil
+}
+
+// encodePointCloud converts a PCD point cloud to a foxglove.PointCloud with float32 coordinates in meters,
+// followed by RGBA colors if the cloud is colored.
+func encodePointCloud(md *v1.DataCaptureMetadata, pcd []byte, requested time.Time) ([]byte, error) {
+	cloud, err := pointcloud.ReadPCD(bytes.NewReader(pcd))
+	if err != nil {
+		return nil, err
+	}
+	colored := cloud.MetaData().HasColor
+	fields := []foxglovePackedElementField{
+		{Name: "x", Offset: 0, Type: numericTypeFloat32},
+		{Name: "y", Offset: 4, Type: numericTypeFloat32},
+		{Name: "z", Offset: 8, Type: numericTypeFloat32},
+	}
+	stride := uint32(12)
+	if colored {
+		for i, name := range []string{"red", "green", "blue", "alpha"} {
+			fields = append(fields, foxglovePackedElementField{Name: name, Offset: stride + uint32(i), Type: numericTypeUint8})
+		}
+		stride += 4
+	}
+	points := make([]byte, 0, int(stride)*cloud.Size())
+	cloud.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		for _, v := range []float64{p.X, p.Y, p.Z} {
+			points = binary.LittleEndian.AppendUint32(points, math.Float32bits(float32(v/mmPerMeter)))
+		}
+		if colored {
+			var r, g, b uint8
+			if d != nil && d.HasColor() {
+				r, g, b = d.RGB255()
+			}
+			points = append(points, r, g, b, math.MaxUint8)
+		}
+		return true
+	})
+	return json.Marshal(foxglovePointCloud{
+		Timestamp:   newFoxgloveTime(requested),
+		FrameID:     md.GetComponentName(),
+		PointStride: stride,
+		Fields:      fields,
+		Data:        points,
+	})
+}
+
+func unixNanos(t time.Time) uint64 {
+	if t.Before(time.Unix(0, 0)) {
+		return 0
+	}
+	return uint64(t.UnixNano())
+}
+
+// ReadCaptureFiles returns the readings of the completed capture files at paths, and of every completed
+// capture file within the directories at paths, sorted by the time they were received.
+func ReadCaptureFiles(paths ...string) ([]data.ReplayReading, error) {
+	var readings []data.ReplayReading
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || filepath.Ext(path) != data.CompletedCaptureFileExt {
+				return nil
+			}
+			fileReadings, err := readCaptureFile(path)
+			if err != nil {
+				return errors.Wrapf(err, "failed to read %s", path)
+			}
+			readings = append(readings, fileReadings...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(readings, func(i, j int) bool {
+		return readings[i].TimeReceived.Before(readings[j].TimeReceived)
+	})
+	return readings, nil
+}
+
+func readCaptureFile(path string) ([]data.ReplayReading, error) {
+	//nolint:gosec
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		// the file is only read from, closing it directly avoids the rename done by CaptureFile.Close.
+		//nolint:errcheck
+		f.Close()
+	}()
+	captureFile, err := data.ReadCaptureFile(f)
+	if err != nil {
+		return nil, err
+	}
+	sensorData, err := data.SensorDataFromCaptureFile(captureFile)
+	if err != nil {
+		return nil, err
+	}
+	readings := make([]data.ReplayReading, 0, len(sensorData))
+	for _, sd := range sensorData {
+		readings = append(readings, data.ReplayReading{
+			TimeRequested: sd.GetMetadata().GetTimeRequested().AsTime(),
+			TimeReceived:  sd.GetMetadata().GetTimeReceived().AsTime(),
+			Metadata:      captureFile.ReadMetadata(),
+			Data:          sd,
+		})
+	}
+	return readings, nil
+}
@@ -0,0 +1,386 @@
+package ros
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"slices"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4"
+	"github.com/pkg/errors"
+)
+
+// mcapMagic starts and ends every MCAP file.
+var mcapMagic = []byte{0x89, 'M', 'C', 'A', 'P', '0', '\r', '\n'}
+
+// The opcodes of the MCAP records which are written or read.
+const (
+	mcapOpHeader  = 0x01
+	mcapOpFooter  = 0x02
+	mcapOpSchema  = 0x03
+	mcapOpChannel = 0x04
+	mcapOpMessage = 0x05
+	mcapOpChunk   = 0x06
+	mcapOpDataEnd = 0x0F
+)
+
+// mcapLibrary identifies the writer of MCAP files in their header.
+const mcapLibrary = "go.viam.com/rdk/ros"
+
+// MCAPSchema describes the messages of the channels which reference it.
+type MCAPSchema struct {
+	ID       uint16
+	Name     string
+	Encoding string
+	Data     []byte
+}
+
+// MCAPChannel is a topic of MCAP messages.
+type MCAPChannel struct {
+	ID              uint16
+	SchemaID        uint16
+	Topic           string
+	MessageEncoding string
+	Metadata        map[string]string
+}
+
+// MCAPMessage is a single message of an MCAP file. Times are nanoseconds since the epoch.
+type MCAPMessage struct {
+	Channel     *MCAPChannel
+	Schema      *MCAPSchema
+	Sequence    uint32
+	LogTime     uint64
+	PublishTime uint64
+	Data        []byte
+}
+
+// MCAPWriter writes an unchunked MCAP file without a summary section, which every MCAP reader can read
+// from start to end.
+type MCAPWriter struct {
+	w   *bufio.Writer
+	buf []byte
+}
+
+// NewMCAPWriter writes the magic and header of an MCAP file with the given profile to w.
+func NewMCAPWriter(w io.Writer, profile string) (*MCAPWriter, error) {
+	mw := &MCAPWriter{w: bufio.NewWriter(w)}
+	if _, err := mw.w.Write(mcapMagic); err != nil {
+		return nil, err
+	}
+	content := appendMCAPString(nil, profile)
+	content = appendMCAPString(content, mcapLibrary)
+	if err := mw.writeRecord(mcapOpHeader, content); err != nil {
+		return nil, err
+	}
+	return mw, nil
+}
+
+// WriteSchema writes a schema record. Schema IDs must not be 0.
+func (mw *MCAPWriter) WriteSchema(schema MCAPSchema) error {
+	content := binary.LittleEndian.AppendUint16(mw.buf[:0], schema.ID)
+	content = appendMCAPString(content, schema.Name)
+	content = appendMCAPString(content, schema.Encoding)
+	content = appendMCAPBytes(content, schema.Data)
+	return mw.writeRecord(mcapOpSchema, content)
+}
+
+// WriteChannel writes a channel record, which must come after the schema it references.
+func (mw *MCAPWriter) WriteChannel(channel MCAPChannel) error {
+	content := binary.LittleEndian.AppendUint16(mw.buf[:0], channel.ID)
+	content = binary.LittleEndian.AppendUint16(content, channel.SchemaID)
+	content = appendMCAPString(content, channel.Topic)
+	content = appendMCAPString(content, channel.MessageEncoding)
+	content = appendMCAPMap(content, channel.Metadata)
+	return mw.writeRecord(mcapOpChannel, content)
+}
+
+// WriteMessage writes a message record, which must come after the channel it is on.
+func (mw *MCAPWriter) WriteMessage(channelID uint16, sequence uint32, logTime, publishTime uint64, data []byte) error {
+	content := binary.LittleEndian.AppendUint16(mw.buf[:0], channelID)
+	content = binary.LittleEndian.AppendUint32(content, sequence)
+	content = binary.LittleEndian.AppendUint64(content, logTime)
+	content = binary.LittleEndian.AppendUint64(content, publishTime)
+	content = append(content, data...)
+	return mw.writeRecord(mcapOpMessage, content)
+}
+
+// Close ends the data section, writes the footer and closing magic and flushes the file. It doesn't close
+// the underlying writer.
+func (mw *MCAPWriter) Close() error {
+	// a zero data section CRC means it wasn't calculated.
+	if err := mw.writeRecord(mcapOpDataEnd, make([]byte, 4)); err != nil {
+		return err
+	}
+	// the summary start, summary offset start and summary CRC are zero as there is no summary.
+	if err := mw.writeRecord(mcapOpFooter, make([]byte, 20)); err != nil {
+		return err
+	}
+	if _, err := mw.w.Write(mcapMagic); err != nil {
+		return err
+	}
+	return mw.w.Flush()
+}
+
+func (mw *MCAPWriter) writeRecord(op byte, content []byte) error {
+	var header [9]byte
+	header[0] = op
+	binary.LittleEndian.PutUint64(header[1:], uint64(len(content)))
+	if _, err := mw.w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := mw.w.Write(content)
+	// the buffer is reused by the next record.
+	mw.buf = content
+	return err
+}
+
+func appendMCAPString(b []byte, s string) []byte {
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+func appendMCAPBytes(b, data []byte) []byte {
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(data)))
+	return append(b, data...)
+}
+
+// appendMCAPMap appends m prefixed by its length in bytes, with its keys sorted so that files are reproducible.
+func appendMCAPMap(b []byte, m map[string]string) []byte {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	var entries []byte
+	for _, k := range keys {
+		entries = appendMCAPString(entries, k)
+		entries = appendMCAPString(entries, m[k])
+	}
+	return appendMCAPBytes(b, entries)
+}
+
+// MCAPReader reads the messages of an MCAP file in the order they were written. Chunked files compressed
+// with zstd or lz4 and unchunked files are read; indexes and the summary section are skipped.
+type MCAPReader struct {
+	r        *bufio.Reader
+	chunk    *bufio.Reader
+	schemas  map[uint16]*MCAPSchema
+	channels map[uint16]*MCAPChannel
+	done     bool
+}
+
+// NewMCAPReader reads the magic of the MCAP file read from r.
+func NewMCAPReader(r io.Reader) (*MCAPReader, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(mcapMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || !bytes.Equal(magic, mcapMagic) {
+		return nil, errors.New("not an MCAP file")
+	}
+	return &MCAPReader{
+		r:        br,
+		schemas:  map[uint16]*MCAPSchema{},
+		channels: map[uint16]*MCAPChannel{},
+	}, nil
+}
+
+// Next returns the next message, or io.EOF once the data section has been read.
+func (mr *MCAPReader) Next() (*MCAPMessage, error) {
+	for !mr.done {
+		src := mr.r
+		if mr.chunk != nil {
+			src = mr.chunk
+		}
+		op, content, err := readMCAPRecord(src)
+		if err != nil {
+			if errors.Is(err, io.EOF) && mr.chunk != nil {
+				mr.chunk = nil
+				continue
+			}
+			if errors.Is(err, io.EOF) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		msg, err := mr.handleRecord(op, content)
+		if err != nil || msg != nil {
+			return msg, err
+		}
+	}
+	return nil, io.EOF
+}
+
+func (mr *MCAPReader) handleRecord(op byte, content []byte) (*MCAPMessage, error) {
+	d := mcapDecoder{b: content}
+	switch op {
+	case mcapOpSchema:
+		schema := &MCAPSchema{ID: d.uint16(), Name: d.string(), Encoding: d.string(), Data: d.bytes()}
+		if d.err != nil {
+			return nil, errors.Wrap(d.err, "invalid MCAP schema")
+		}
+		mr.schemas[schema.ID] = schema
+	case mcapOpChannel:
+		channel := &MCAPChannel{ID: d.uint16(), SchemaID: d.uint16(), Topic: d.string(), MessageEncoding: d.string(), Metadata: d.stringMap()}
+		if d.err != nil {
+			return nil, errors.Wrap(d.err, "invalid MCAP channel")
+		}
+		mr.channels[channel.ID] = channel
+	case mcapOpMessage:
+		channelID := d.uint16()
+		msg := &MCAPMessage{Sequence: d.uint32(), LogTime: d.uint64(), PublishTime: d.uint64(), Data: d.rest()}
+		if d.err != nil {
+			return nil, errors.Wrap(d.err, "invalid MCAP message")
+		}
+		channel, ok := mr.channels[channelID]
+		if !ok {
+			return nil, errors.Errorf("MCAP message on unknown channel %d", channelID)
+		}
+		msg.Channel = channel
+		msg.Schema = mr.schemas[channel.SchemaID]
+		return msg, nil
+	case mcapOpChunk:
+		if mr.chunk != nil {
+			return nil, errors.New("MCAP chunk within a chunk")
+		}
+		chunk, err := decompressMCAPChunk(&d)
+		if err != nil {
+			return nil, err
+		}
+		mr.chunk = bufio.NewReader(bytes.NewReader(chunk))
+	case mcapOpDataEnd, mcapOpFooter:
+		mr.done = true
+	}
+	return nil, nil
+}
+
+// decompressMCAPChunk returns the records of a chunk record.
+func decompressMCAPChunk(d *mcapDecoder) ([]byte, error) {
+	// the start and end times of the messages in the chunk.
+	d.uint64()
+	d.uint64()
+	uncompressedSize := d.uint64()
+	// the CRC is of the uncompressed records and optional.
+	d.uint32()
+	compression := d.string()
+	records := d.bytes64()
+	if d.err != nil {
+		return nil, errors.Wrap(d.err, "invalid MCAP chunk")
+	}
+	var r io.Reader
+	switch compression {
+	case "":
+		return records, nil
+	case "zstd":
+		zr, err := zstd.NewReader(bytes.NewReader(records), zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	case "lz4":
+		r = lz4.NewReader(bytes.NewReader(records))
+	default:
+		return nil, errors.Errorf("unsupported MCAP chunk compression %q", compression)
+	}
+	// a corrupted size could be huge, so the records are read without allocating it upfront.
+	uncompressed, err := io.ReadAll(io.LimitReader(r, int64(min(uncompressedSize, math.MaxInt64))))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decompress MCAP chunk")
+	}
+	if uint64(len(uncompressed)) != uncompressedSize {
+		return nil, errors.New("MCAP chunk is shorter than its uncompressed size")
+	}
+	return uncompressed, nil
+}
+
+func readMCAPRecord(r *bufio.Reader) (byte, []byte, error) {
+	var header [9]byte
+	if _, err := io.ReadFull(r, header[:1]); err != nil {
+		return 0, nil, err
+	}
+	if _, err := io.ReadFull(r, header[1:]); err != nil {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+	length := binary.LittleEndian.Uint64(header[1:])
+	content, err := io.ReadAll(io.LimitReader(r, int64(min(length, math.MaxInt64))))
+	if err != nil {
+		return 0, nil, err
+	}
+	if uint64(len(content)) != length {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+	return header[0], content, nil
+}
+
+// mcapDecoder decodes the fields of a record. Once a field runs past the end of the record err is set and
+// every following field is zero.
+type mcapDecoder struct {
+	b   []byte
+	err error
+}
+
+func (d *mcapDecoder) take(n uint64) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n > uint64(len(d.b)) {
+		d.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	field := d.b[:n]
+	d.b = d.b[n:]
+	return field
+}
+
+func (d *mcapDecoder) uint16() uint16 {
+	if b := d.take(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *mcapDecoder) uint32() uint32 {
+	if b := d.take(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *mcapDecoder) uint64() uint64 {
+	if b := d.take(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (d *mcapDecoder) bytes() []byte {
+	return d.take(uint64(d.uint32()))
+}
+
+func (d *mcapDecoder) bytes64() []byte {
+	return d.take(d.uint64())
+}
+
+func (d *mcapDecoder) string() string {
+	return string(d.bytes())
+}
+
+func (d *mcapDecoder) stringMap() map[string]string {
+	entries := mcapDecoder{b: d.bytes()}
+	m := map[string]string{}
+	for d.err == nil && len(entries.b) > 0 {
+		k, v := entries.string(), entries.string()
+		if entries.err != nil {
+			d.err = entries.err
+			break
+		}
+		m[k] = v
+	}
+	return m
+}
+
+func (d *mcapDecoder) rest() []byte {
+	return d.take(uint64(len(d.b)))
+}
@@ -0,0 +1,130 @@
+// Package main converts capture files to MCAP, and MCAP files and ROS bags to capture files.
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	goutils "go.viam.com/utils"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/ros"
+)
+
+var logger = logging.NewDebugLogger("mcap_converter")
+
+// Arguments for the MCAP converter.
+type Arguments struct {
+	Input   string `flag:"0,required,usage=capture directory or file or .mcap or ROS .bag file to convert"`
+	Output  string `flag:"1,required,usage=.mcap file to write captured data to or capture directory to write MCAP or bag data to"`
+	Tabular string `flag:"tabular,default=json,usage=encoding of tabular readings written to MCAP (json or protobuf)"`
+}
+
+func main() {
+	goutils.ContextualMain(mainWithArgs, logger)
+}
+
+func mainWithArgs(ctx context.Context, args []string, logger logging.Logger) error {
+	var argsParsed Arguments
+	if err := goutils.ParseFlags(args, &argsParsed); err != nil {
+		return err
+	}
+
+	switch filepath.Ext(argsParsed.Input) {
+	case ".mcap":
+		return mcapToCaptureDir(argsParsed.Input, argsParsed.Output, logger)
+	case ".bag":
+		return bagToCaptureDir(argsParsed.Input, argsParsed.Output, logger)
+	default:
+		return captureToMCAP(argsParsed.Input, argsParsed.Output, ros.MCAPTabularEncoding(argsParsed.Tabular), logger)
+	}
+}
+
+func captureToMCAP(input, output string, tabular ros.MCAPTabularEncoding, logger logging.Logger) (err error) {
+	readings, err := ros.ReadCaptureFiles(input)
+	if err != nil {
+		return err
+	}
+	//nolint:gosec
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	w, err := ros.NewCaptureMCAPWriter(f, tabular)
+	if err != nil {
+		return err
+	}
+	for _, reading := range readings {
+		if err := w.Write(reading.Metadata, reading.Data); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	logger.Infof("wrote %d readings to %s, skipped %d which can't be converted", len(readings)-w.Skipped(), output, w.Skipped())
+	return nil
+}
+
+func mcapToCaptureDir(input, output string, logger logging.Logger) error {
+	//nolint:gosec
+	f, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	defer goutils.UncheckedErrorFunc(f.Close)
+	r, err := ros.NewMCAPSensorDataReader(f)
+	if err != nil {
+		return err
+	}
+	w := ros.NewCaptureDirWriter(output)
+	count := 0
+	for {
+		reading, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := w.Write(reading); err != nil {
+			return err
+		}
+		count++
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	logger.Infof("wrote %d readings to %s, skipped %d which can't be decoded", count, output, r.Skipped())
+	return nil
+}
+
+func bagToCaptureDir(input, output string, logger logging.Logger) error {
+	rb, err := ros.ReadBag(input)
+	if err != nil {
+		return err
+	}
+	readings, err := ros.BagReadings(rb)
+	if err != nil {
+		return err
+	}
+	w := ros.NewCaptureDirWriter(output)
+	for _, reading := range readings {
+		if err := w.Write(reading); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	logger.Infof("wrote %d readings to %s", len(readings), output)
+	return nil
+}
@@ -0,0 +1,493 @@
+package ros
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/edaniels/gobag/rosbag"
+	"github.com/pkg/errors"
+	v1 "go.viam.com/api/app/datasync/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"go.viam.com/rdk/data"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/resource"
+)
+
+// rosCompressedImage is the ROS message type of compressed images.
+const rosCompressedImage = "sensor_msgs/CompressedImage"
+
+// captureDirMaxFileSize is the size tabular capture files are written up to, the data manager's default.
+const captureDirMaxFileSize = 256 * 1024
+
+// MCAPSensorDataReader reads the messages of an MCAP file as readings which can be replayed.
+//
+// Messages written by a CaptureMCAPWriter are read back as they were captured. Messages of other topics
+// are read as if captured from a component named after their topic: foxglove.CompressedImage messages
+// as ReadImage readings of a camera, foxglove.PointCloud messages as NextPointCloud readings of a camera
+// and anything else as Readings of a sensor. Messages which aren't JSON or protobuf encoded are skipped.
+type MCAPSensorDataReader struct {
+	r        *MCAPReader
+	metadata map[uint16]*v1.DataCaptureMetadata
+	types    map[uint16]protoreflect.MessageType
+	skipped  int
+}
+
+// NewMCAPSensorDataReader returns an MCAPSensorDataReader reading the MCAP file read from r.
+func NewMCAPSensorDataReader(r io.Reader) (*MCAPSensorDataReader, error) {
+	mr, err := NewMCAPReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return &MCAPSensorDataReader{
+		r:        mr,
+		metadata: map[uint16]*v1.DataCaptureMetadata{},
+		types:    map[uint16]protoreflect.MessageType{},
+	}, nil
+}
+
+// Next returns the next reading, or io.EOF once every message has been read.
+func (sr *MCAPSensorDataReader) Next() (data.ReplayReading, error) {
+	for {
+		msg, err := sr.r.Next()
+		if err != nil {
+			return data.ReplayReading{}, err
+		}
+		fields, err := sr.decode(msg)
+		if err != nil {
+			return data.ReplayReading{}, errors.Wrapf(err, "failed to decode message %d on %s", msg.Sequence, msg.Channel.Topic)
+		}
+		if fields == nil {
+			sr.skipped++
+			continue
+		}
+		md := sr.channelMetadata(msg)
+		sd := &v1.SensorData{Metadata: &v1.SensorMetadata{
+			TimeRequested: timestamppb.New(time.Unix(0, int64(msg.PublishTime))),
+			TimeReceived:  timestamppb.New(time.Unix(0, int64(msg.LogTime))),
+		}}
+		if err := setSensorData(sd, md, fields, msg.Channel.Metadata[metadataComponentName] == ""); err != nil {
+			return data.ReplayReading{}, errors.Wrapf(err, "failed to convert message %d on %s", msg.Sequence, msg.Channel.Topic)
+		}
+		return data.ReplayReading{
+			TimeRequested: sd.GetMetadata().GetTimeRequested().AsTime(),
+			TimeReceived:  sd.GetMetadata().GetTimeReceived().AsTime(),
+			Metadata:      md,
+			Data:          sd,
+		}, nil
+	}
+}
+
+// Skipped returns how many messages were skipped as they can't be decoded.
+func (sr *MCAPSensorDataReader) Skipped() int {
+	return sr.skipped
+}
+
+// decode returns the fields of msg as decoded from JSON, or nil if its encoding isn't supported.
+func (sr *MCAPSensorDataReader) decode(msg *MCAPMessage) (map[string]interface{}, error) {
+	switch msg.Channel.MessageEncoding {
+	case jsonMessageEncoding:
+		var fields map[string]interface{}
+		if err := json.Unmarshal(msg.Data, &fields); err != nil {
+			return nil, err
+		}
+		return fields, nil
+	case protobufMessageEncoding:
+		if msg.Schema == nil {
+			return nil, nil
+		}
+		messageType, err := sr.messageType(msg.Channel.ID, msg.Schema)
+		if err != nil {
+			return nil, err
+		}
+		decoded := messageType.New().Interface()
+		if err := proto.Unmarshal(msg.Data, decoded); err != nil {
+			return nil, err
+		}
+		encoded, err := protojson.MarshalOptions{UseProtoNames: true, UseEnumNumbers: true}.Marshal(decoded)
+		if err != nil {
+			return nil, err
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(encoded, &fields); err != nil {
+			return nil, err
+		}
+		return fields, nil
+	default:
+		return nil, nil
+	}
+}
+
+// messageType returns the type of the protobuf messages on a channel, described by schema.
+func (sr *MCAPSensorDataReader) messageType(channelID uint16, schema *MCAPSchema) (protoreflect.MessageType, error) {
+	if messageType, ok := sr.types[channelID]; ok {
+		return messageType, nil
+	}
+	var messageType protoreflect.MessageType = (&structpb.Struct{}).ProtoReflect().Type()
+	if schema.Name != structSchemaName {
+		var descriptors descriptorpb.FileDescriptorSet
+		if err := proto.Unmarshal(schema.Data, &descriptors); err != nil {
+			return nil, errors.Wrapf(err, "invalid descriptors of schema %s", schema.Name)
+		}
+		files, err := protodesc.NewFiles(&descriptors)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid descriptors of schema %s", schema.Name)
+		}
+		descriptor, err := files.FindDescriptorByName(protoreflect.FullName(schema.Name))
+		if err != nil {
+			return nil, err
+		}
+		messageDescriptor, ok := descriptor.(protoreflect.MessageDescriptor)
+		if !ok {
+			return nil, errors.Errorf("schema %s is not a message", schema.Name)
+		}
+		messageType = dynamicpb.NewMessageType(messageDescriptor)
+	}
+	sr.types[channelID] = messageType
+	return messageType, nil
+}
+
+// channelMetadata returns the capture metadata of the channel of msg.
+func (sr *MCAPSensorDataReader) channelMetadata(msg *MCAPMessage) *v1.DataCaptureMetadata {
+	if md, ok := sr.metadata[msg.Channel.ID]; ok {
+		return md
+	}
+	var schemaName string
+	if msg.Schema != nil {
+		schemaName = msg.Schema.Name
+	}
+	metadata := msg.Channel.Metadata
+	var md *v1.DataCaptureMetadata
+	if metadata[metadataComponentName] != "" {
+		md = &v1.DataCaptureMetadata{
+			ComponentType: metadata[metadataComponentType],
+			ComponentName: metadata[metadataComponentName],
+			MethodName:    metadata[metadataMethodName],
+			FileExtension: metadata[metadataFileExtension],
+		}
+		if metadata[metadataTags] != "" {
+			md.Tags = strings.Split(metadata[metadataTags], ",")
+		}
+	} else {
+		md = foreignTopicMetadata(msg.Channel.Topic, schemaName)
+	}
+	md.Type = v1.DataType_DATA_TYPE_TABULAR_SENSOR
+	if schemaName == compressedImageSchema || schemaName == pointCloudSchema {
+		md.Type = v1.DataType_DATA_TYPE_BINARY_SENSOR
+		if schemaName == pointCloudSchema {
+			md.FileExtension = ".pcd"
+		}
+	}
+	sr.metadata[msg.Channel.ID] = md
+	return md
+}
+
+// foreignTopicMetadata returns the capture metadata of the messages of a topic which wasn't captured,
+// as if captured from a component named after the topic.
+func foreignTopicMetadata(topic, schemaName string) *v1.DataCaptureMetadata {
+	name := strings.ReplaceAll(strings.Trim(topic, "/"), "/", "_")
+	switch schemaName {
+	case compressedImageSchema, rosCompressedImage:
+		return &v1.DataCaptureMetadata{
+			ComponentType: resource.APINamespaceRDK.WithComponentType("camera").String(),
+			ComponentName: name,
+			MethodName:    readImage,
+		}
+	case pointCloudSchema:
+		return &v1.DataCaptureMetadata{
+			ComponentType: resource.APINamespaceRDK.WithComponentType("camera").String(),
+			ComponentName: name,
+			MethodName:    nextPointCloud,
+			FileExtension: ".pcd",
+		}
+	default:
+		return &v1.DataCaptureMetadata{
+			ComponentType: resource.APINamespaceRDK.WithComponentType("sensor").String(),
+			ComponentName: name,
+			MethodName:    "Readings",
+			FileExtension: ".dat",
+		}
+	}
+}
+
+// setSensorData sets the data of sd to the decoded fields of a message. The fields of tabular messages of
+// foreign topics are wrapped as sensor readings.
+func setSensorData(sd *v1.SensorData, md *v1.DataCaptureMetadata, fields map[string]interface{}, foreign bool) error {
+	switch {
+	case md.GetType() == v1.DataType_DATA_TYPE_BINARY_SENSOR && md.GetFileExtension() == ".pcd":
+		pcd, err := decodePointCloud(fields)
+		if err != nil {
+			return err
+		}
+		sd.Data = &v1.SensorData_Binary{Binary: pcd}
+	case md.GetType() == v1.DataType_DATA_TYPE_BINARY_SENSOR:
+		image, err := bytesField(fields["data"])
+		if err != nil {
+			return err
+		}
+		if md.GetFileExtension() == "" {
+			md.FileExtension = imageFileExtension(fields["format"])
+		}
+		sd.Data = &v1.SensorData_Binary{Binary: image}
+	default:
+		reading := fields
+		if foreign {
+			reading = map[string]interface{}{"readings": fields}
+		}
+		s, err := structpb.NewStruct(reading)
+		if err != nil {
+			return err
+		}
+		sd.Data = &v1.SensorData_Struct{Struct: s}
+	}
+	return nil
+}
+
+// imageFileExtension returns the capture file extension of images of a compressed image format, such as
+// "png" or the "bgr8; jpeg compressed bgr8" of ROS.
+func imageFileExtension(format interface{}) string {
+	if f, ok := format.(string); ok && strings.Contains(strings.ToLower(f), "png") {
+		return ".png"
+	}
+	return ".jpeg"
+}
+
+// bytesField returns the bytes of a field decoded from JSON, encoded either as base64 or as an array of bytes.
+func bytesField(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case string:
+		return base64.StdEncoding.DecodeString(v)
+	case []interface{}:
+		b := make([]byte, 0, len(v))
+		for _, e := range v {
+			n, ok := e.(float64)
+			if !ok || n < 0 || n > math.MaxUint8 {
+				return nil, errors.New("byte array field contains a value which isn't a byte")
+			}
+			b = append(b, byte(n))
+		}
+		return b, nil
+	case nil:
+		return nil, nil
+	default:
+		return nil, errors.Errorf("expected a bytes field, got %T", v)
+	}
+}
+
+// decodePointCloud converts the fields of a foxglove.PointCloud to a binary PCD point cloud in millimeters.
+// Points are colored if the cloud has red, green and blue fields.
+func decodePointCloud(fields map[string]interface{}) ([]byte, error) {
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	var fc foxglovePointCloud
+	if err := json.Unmarshal(encoded, &fc); err != nil {
+		return nil, err
+	}
+	offsets := map[string]foxglovePackedElementField{}
+	for _, f := range fc.Fields {
+		offsets[f.Name] = f
+	}
+	for _, name := range []string{"x", "y", "z"} {
+		if _, ok := offsets[name]; !ok {
+			return nil, errors.Errorf("point cloud has no %s field", name)
+		}
+	}
+	_, hasRed := offsets["red"]
+	_, hasGreen := offsets["green"]
+	_, hasBlue := offsets["blue"]
+	colored := hasRed && hasGreen && hasBlue
+	if fc.PointStride == 0 {
+		return nil, errors.New("point cloud has no point stride")
+	}
+
+	cloud := pointcloud.New()
+	for start := 0; start+int(fc.PointStride) <= len(fc.Data); start += int(fc.PointStride) {
+		point := fc.Data[start : start+int(fc.PointStride)]
+		var coords [3]float64
+		for i, name := range []string{"x", "y", "z"} {
+			v, err := readNumericField(point, offsets[name])
+			if err != nil {
+				return nil, err
+			}
+			coords[i] = v * mmPerMeter
+		}
+		d := pointcloud.NewBasicData()
+		if colored {
+			var rgb [3]uint8
+			for i, name := range []string{"red", "green", "blue"} {
+				v, err := readNumericField(point, offsets[name])
+				if err != nil {
+					return nil, err
+				}
+				// floating point colors are in [0, 1].
+				if t := offsets[name].Type; t == numericTypeFloat32 || t == numericTypeFloat64 {
+					v *= math.MaxUint8
+				}
+				rgb[i] = uint8(max(0, min(v, math.MaxUint8)))
+			}
+			d = pointcloud.NewColoredData(color.NRGBA{R: rgb[0], G: rgb[1], B: rgb[2], A: math.MaxUint8})
+		}
+		if err := cloud.Set(pointcloud.NewVector(coords[0], coords[1], coords[2]), d); err != nil {
+			return nil, err
+		}
+	}
+	var pcd bytes.Buffer
+	if err := pointcloud.ToPCD(cloud, &pcd, pointcloud.PCDBinary); err != nil {
+		return nil, err
+	}
+	return pcd.Bytes(), nil
+}
+
+// readNumericField reads a little endian field of a packed point.
+func readNumericField(point []byte, field foxglovePackedElementField) (float64, error) {
+	sizes := map[int]uint32{
+		numericTypeUint8: 1, numericTypeInt8: 1, numericTypeUint16: 2, numericTypeInt16: 2,
+		numericTypeUint32: 4, numericTypeInt32: 4, numericTypeFloat32: 4, numericTypeFloat64: 8,
+	}
+	size, ok := sizes[field.Type]
+	if !ok {
+		return 0, errors.Errorf("point cloud field %s has unknown type %d", field.Name, field.Type)
+	}
+	if uint64(field.Offset)+uint64(size) > uint64(len(point)) {
+		return 0, errors.Errorf("point cloud field %s is outside of the point", field.Name)
+	}
+	b := point[field.Offset:]
+	switch field.Type {
+	case numericTypeUint8:
+		return float64(b[0]), nil
+	case numericTypeInt8:
+		return float64(int8(b[0])), nil
+	case numericTypeUint16:
+		return float64(binary.LittleEndian.Uint16(b)), nil
+	case numericTypeInt16:
+		return float64(int16(binary.LittleEndian.Uint16(b))), nil
+	case numericTypeUint32:
+		return float64(binary.LittleEndian.Uint32(b)), nil
+	case numericTypeInt32:
+		return float64(int32(binary.LittleEndian.Uint32(b))), nil
+	case numericTypeFloat32:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b))), nil
+	default:
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+	}
+}
+
+// BagReadings returns the messages of every topic of a ROS bag as readings, sorted by the time they were
+// recorded. Like the messages of foreign MCAP topics, sensor_msgs/CompressedImage messages are read as
+// ReadImage readings of a camera and anything else as Readings of a sensor, named after their topic.
+func BagReadings(rb *rosbag.RosBag) ([]data.ReplayReading, error) {
+	topicTypes := map[string]string{}
+	for _, connection := range rb.Connections {
+		topicTypes[connection.HeaderTopic] = connection.ConnectionType
+	}
+	if err := rb.ParseTopicsToJSON("", func(int64) bool { return true }, func(string) bool { return true }, true); err != nil {
+		return nil, errors.Wrapf(err, "error while parsing bag to JSON")
+	}
+
+	var readings []data.ReplayReading
+	metadata := map[string]*v1.DataCaptureMetadata{}
+	for _, msgs := range rb.TopicsAsJSON {
+		for {
+			line, err := msgs.ReadBytes('\n')
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return nil, err
+			}
+			var message struct {
+				Meta struct {
+					Topic string `json:"topic"`
+					Secs  int64  `json:"secs"`
+					Nsecs int64  `json:"nsecs"`
+				} `json:"meta"`
+				Data map[string]interface{} `json:"data"`
+			}
+			if err := json.Unmarshal(line, &message); err != nil {
+				return nil, err
+			}
+
+			md, ok := metadata[message.Meta.Topic]
+			if !ok {
+				md = foreignTopicMetadata(message.Meta.Topic, topicTypes[message.Meta.Topic])
+				md.Type = v1.DataType_DATA_TYPE_TABULAR_SENSOR
+				if md.GetMethodName() == readImage {
+					md.Type = v1.DataType_DATA_TYPE_BINARY_SENSOR
+				}
+				metadata[message.Meta.Topic] = md
+			}
+			recorded := timestamppb.New(time.Unix(message.Meta.Secs, message.Meta.Nsecs))
+			sd := &v1.SensorData{Metadata: &v1.SensorMetadata{TimeRequested: recorded, TimeReceived: recorded}}
+			if err := setSensorData(sd, md, message.Data, true); err != nil {
+				return nil, errors.Wrapf(err, "failed to convert message on %s", message.Meta.Topic)
+			}
+			readings = append(readings, data.ReplayReading{
+				TimeRequested: recorded.AsTime(),
+				TimeReceived:  recorded.AsTime(),
+				Metadata:      md,
+				Data:          sd,
+			})
+		}
+	}
+	sort.SliceStable(readings, func(i, j int) bool {
+		return readings[i].TimeRequested.Before(readings[j].TimeRequested)
+	})
+	return readings, nil
+}
+
+// CaptureDirWriter writes readings to capture files laid out the way the data manager captures them, so
+// that they can be replayed or synced as if they were captured.
+type CaptureDirWriter struct {
+	dir     string
+	buffers map[string]*data.CaptureBuffer
+}
+
+// NewCaptureDirWriter returns a CaptureDirWriter writing to the capture directory dir.
+func NewCaptureDirWriter(dir string) *CaptureDirWriter {
+	return &CaptureDirWriter{dir: dir, buffers: map[string]*data.CaptureBuffer{}}
+}
+
+// Write writes a reading to the capture files of its component and method.
+func (cw *CaptureDirWriter) Write(reading data.ReplayReading) error {
+	md := reading.Metadata
+	dir := data.CaptureFilePathWithReplacedReservedChars(
+		filepath.Join(cw.dir, md.GetComponentType(), md.GetComponentName(), md.GetMethodName()))
+	buffer, ok := cw.buffers[dir]
+	if !ok {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return err
+		}
+		buffer = data.NewCaptureBuffer(dir, md, captureDirMaxFileSize)
+		cw.buffers[dir] = buffer
+	}
+	return buffer.Write(reading.Data)
+}
+
+// Close completes the capture files which are still being written.
+func (cw *CaptureDirWriter) Close() error {
+	for _, buffer := range cw.buffers {
+		if err := buffer.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package ros
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+	v1 "go.viam.com/api/app/datasync/v1"
+	"go.viam.com/test"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"go.viam.com/rdk/data"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/resource"
+)
+
+func testSensorData(t *testing.T, requested time.Time) *v1.SensorData {
+	t.Helper()
+	return &v1.SensorData{Metadata: &v1.SensorMetadata{
+		TimeRequested: timestamppb.New(requested),
+		TimeReceived:  timestamppb.New(requested.Add(time.Millisecond)),
+	}}
+}
+
+func readAllMCAP(t *testing.T, r io.Reader) ([]data.ReplayReading, int) {
+	t.Helper()
+	sr, err := NewMCAPSensorDataReader(r)
+	test.That(t, err, test.ShouldBeNil)
+	var readings []data.ReplayReading
+	for {
+		reading, err := sr.Next()
+		if errors.Is(err, io.EOF) {
+			return readings, sr.Skipped()
+		}
+		test.That(t, err, test.ShouldBeNil)
+		readings = append(readings, reading)
+	}
+}
+
+func TestCaptureMCAPRoundTrip(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	captureDir := t.TempDir()
+
+	armMD := &v1.DataCaptureMetadata{
+		ComponentType: "rdk:component:arm", ComponentName: "arm1", MethodName: "EndPosition",
+		Type: v1.DataType_DATA_TYPE_TABULAR_SENSOR, FileExtension: ".dat", Tags: []string{"a", "b"},
+	}
+	armBuffer := data.NewCaptureBuffer(filepath.Join(captureDir, "arm"), armMD, captureDirMaxFileSize)
+	for i := 0; i < 3; i++ {
+		reading, err := structpb.NewStruct(map[string]interface{}{"pose": map[string]interface{}{"x": float64(i)}})
+		test.That(t, err, test.ShouldBeNil)
+		sd := testSensorData(t, start.Add(time.Duration(i)*time.Second))
+		sd.Data = &v1.SensorData_Struct{Struct: reading}
+		test.That(t, writeToBuffer(armBuffer, sd), test.ShouldBeNil)
+	}
+	test.That(t, armBuffer.Flush(), test.ShouldBeNil)
+
+	cameraMD := &v1.DataCaptureMetadata{
+		ComponentType: "rdk:component:camera", ComponentName: "cam", MethodName: readImage,
+		Type: v1.DataType_DATA_TYPE_BINARY_SENSOR, FileExtension: ".jpeg",
+	}
+	image := testSensorData(t, start.Add(500*time.Millisecond))
+	image.Data = &v1.SensorData_Binary{Binary: []byte{0xff, 0xd8, 0xff}}
+	test.That(t, writeToBuffer(data.NewCaptureBuffer(filepath.Join(captureDir, "cam"), cameraMD, 0), image), test.ShouldBeNil)
+
+	cloud := pointcloud.New()
+	test.That(t, cloud.Set(pointcloud.NewVector(1000, -2000, 500), pointcloud.NewColoredData(color.NRGBA{R: 255, G: 10, B: 20, A: 255})),
+		test.ShouldBeNil)
+	var pcd bytes.Buffer
+	test.That(t, pointcloud.ToPCD(cloud, &pcd, pointcloud.PCDBinary), test.ShouldBeNil)
+	lidarMD := &v1.DataCaptureMetadata{
+		ComponentType: "rdk:component:camera", ComponentName: "lidar", MethodName: nextPointCloud,
+		Type: v1.DataType_DATA_TYPE_BINARY_SENSOR, FileExtension: ".pcd",
+	}
+	points := testSensorData(t, start.Add(1500*time.Millisecond))
+	points.Data = &v1.SensorData_Binary{Binary: pcd.Bytes()}
+	test.That(t, writeToBuffer(data.NewCaptureBuffer(filepath.Join(captureDir, "lidar"), lidarMD, 0), points), test.ShouldBeNil)
+
+	// binary readings of unknown formats can't be converted.
+	otherMD := &v1.DataCaptureMetadata{ComponentName: "cam", MethodName: "GetImages", Type: v1.DataType_DATA_TYPE_BINARY_SENSOR}
+	other := testSensorData(t, start)
+	other.Data = &v1.SensorData_Binary{Binary: []byte("?")}
+	test.That(t, writeToBuffer(data.NewCaptureBuffer(filepath.Join(captureDir, "other"), otherMD, 0), other), test.ShouldBeNil)
+
+	readings, err := ReadCaptureFiles(captureDir)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, readings, test.ShouldHaveLength, 6)
+	for i := 1; i < len(readings); i++ {
+		test.That(t, readings[i].TimeReceived.Before(readings[i-1].TimeReceived), test.ShouldBeFalse)
+	}
+
+	for _, tabular := range []MCAPTabularEncoding{MCAPTabularJSON, MCAPTabularProtobuf} {
+		t.Run(string(tabular), func(t *testing.T) {
+			var mcap bytes.Buffer
+			w, err := NewCaptureMCAPWriter(&mcap, tabular)
+			test.That(t, err, test.ShouldBeNil)
+			for _, reading := range readings {
+				test.That(t, w.Write(reading.Metadata, reading.Data), test.ShouldBeNil)
+			}
+			test.That(t, w.Close(), test.ShouldBeNil)
+			test.That(t, w.Skipped(), test.ShouldEqual, 1)
+			test.That(t, bytes.HasSuffix(mcap.Bytes(), mcapMagic), test.ShouldBeTrue)
+
+			converted, skipped := readAllMCAP(t, &mcap)
+			test.That(t, skipped, test.ShouldEqual, 0)
+			test.That(t, converted, test.ShouldHaveLength, 5)
+			var methods []string
+			for _, reading := range converted {
+				methods = append(methods, reading.Metadata.GetMethodName())
+			}
+			test.That(t, methods, test.ShouldResemble, []string{"EndPosition", readImage, "EndPosition", nextPointCloud, "EndPosition"})
+
+			arm := converted[2]
+			test.That(t, arm.Metadata.GetComponentType(), test.ShouldEqual, "rdk:component:arm")
+			test.That(t, arm.Metadata.GetComponentName(), test.ShouldEqual, "arm1")
+			test.That(t, arm.Metadata.GetTags(), test.ShouldResemble, []string{"a", "b"})
+			test.That(t, arm.Metadata.GetType(), test.ShouldEqual, v1.DataType_DATA_TYPE_TABULAR_SENSOR)
+			test.That(t, arm.TimeRequested.Equal(start.Add(time.Second)), test.ShouldBeTrue)
+			test.That(t, arm.TimeReceived.Equal(start.Add(time.Second+time.Millisecond)), test.ShouldBeTrue)
+			test.That(t, arm.Data.GetStruct().AsMap(), test.ShouldResemble, map[string]interface{}{"pose": map[string]interface{}{"x": 1.0}})
+
+			cam := converted[1]
+			test.That(t, cam.Metadata.GetType(), test.ShouldEqual, v1.DataType_DATA_TYPE_BINARY_SENSOR)
+			test.That(t, cam.Metadata.GetFileExtension(), test.ShouldEqual, ".jpeg")
+			test.That(t, cam.Data.GetBinary(), test.ShouldResemble, []byte{0xff, 0xd8, 0xff})
+
+			lidar, err := pointcloud.ReadPCD(bytes.NewReader(converted[3].Data.GetBinary()))
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, lidar.Size(), test.ShouldEqual, 1)
+			d, ok := lidar.At(1000, -2000, 500)
+			test.That(t, ok, test.ShouldBeTrue)
+			r, g, b := d.RGB255()
+			test.That(t, []uint8{r, g, b}, test.ShouldResemble, []uint8{255, 10, 20})
+
+			// the converted readings are written where the data manager would have captured them.
+			importDir := t.TempDir()
+			cw := NewCaptureDirWriter(importDir)
+			for _, reading := range converted {
+				test.That(t, cw.Write(reading), test.ShouldBeNil)
+			}
+			test.That(t, cw.Close(), test.ShouldBeNil)
+			logger := logging.NewTestLogger(t)
+			armName := resource.NewName(resource.APINamespaceRDK.WithComponentType("arm"), "arm1")
+			replay, err := data.ReadCaptureDir(filepath.Join(importDir, "rdk_component_arm"), armName, time.Time{}, time.Time{}, logger)
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, replay["EndPosition"], test.ShouldHaveLength, 3)
+			lidarName := resource.NewName(resource.APINamespaceRDK.WithComponentType("camera"), "lidar")
+			replay, err = data.ReadCaptureDir(importDir, lidarName, time.Time{}, time.Time{}, logger)
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, replay[nextPointCloud], test.ShouldHaveLength, 1)
+		})
+	}
+}
+
+func writeToBuffer(buffer *data.CaptureBuffer, sd *v1.SensorData) error {
+	if err := os.MkdirAll(buffer.Directory, 0o700); err != nil {
+		return err
+	}
+	if err := buffer.Write(sd); err != nil {
+		return err
+	}
+	return buffer.Flush()
+}
+
+func TestMCAPReaderChunks(t *testing.T) {
+	var records []byte
+	appendRecord := func(b []byte, op byte, content []byte) []byte {
+		b = append(b, op)
+		b = binary.LittleEndian.AppendUint64(b, uint64(len(content)))
+		return append(b, content...)
+	}
+	channel := func(id uint16, topic, encoding string) []byte {
+		content := binary.LittleEndian.AppendUint16(nil, id)
+		content = binary.LittleEndian.AppendUint16(content, 0)
+		content = appendMCAPString(content, topic)
+		content = appendMCAPString(content, encoding)
+		return appendMCAPMap(content, nil)
+	}
+	message := func(channelID uint16, logTime uint64, msg string) []byte {
+		content := binary.LittleEndian.AppendUint16(nil, channelID)
+		content = binary.LittleEndian.AppendUint32(content, 0)
+		content = binary.LittleEndian.AppendUint64(content, logTime)
+		content = binary.LittleEndian.AppendUint64(content, logTime)
+		return append(content, msg...)
+	}
+	records = appendRecord(records, mcapOpChannel, channel(1, "/robot/battery", "json"))
+	records = appendRecord(records, mcapOpChannel, channel(2, "/tf", "ros1"))
+	records = appendRecord(records, mcapOpMessage, message(1, 1e9, `{"voltage":12.5}`))
+	records = appendRecord(records, mcapOpMessage, message(2, 2e9, "\x00\x01"))
+
+	enc, err := zstd.NewWriter(nil)
+	test.That(t, err, test.ShouldBeNil)
+	compressed := enc.EncodeAll(records, nil)
+	chunk := binary.LittleEndian.AppendUint64(nil, 1e9)
+	chunk = binary.LittleEndian.AppendUint64(chunk, 2e9)
+	chunk = binary.LittleEndian.AppendUint64(chunk, uint64(len(records)))
+	chunk = binary.LittleEndian.AppendUint32(chunk, 0)
+	chunk = appendMCAPString(chunk, "zstd")
+	chunk = binary.LittleEndian.AppendUint64(chunk, uint64(len(compressed)))
+	chunk = append(chunk, compressed...)
+
+	file := append([]byte{}, mcapMagic...)
+	file = appendRecord(file, mcapOpHeader, appendMCAPString(appendMCAPString(nil, "ros1"), "other"))
+	file = appendRecord(file, mcapOpChunk, chunk)
+	// message indexes, the summary and anything after the data section are skipped.
+	file = appendRecord(file, 0x07, []byte{1, 2, 3})
+	file = appendRecord(file, mcapOpDataEnd, make([]byte, 4))
+	file = appendRecord(file, mcapOpMessage, []byte("not read"))
+
+	readings, skipped := readAllMCAP(t, bytes.NewReader(file))
+	test.That(t, skipped, test.ShouldEqual, 1)
+	test.That(t, readings, test.ShouldHaveLength, 1)
+	test.That(t, readings[0].Metadata.GetComponentType(), test.ShouldEqual, "rdk:component:sensor")
+	test.That(t, readings[0].Metadata.GetComponentName(), test.ShouldEqual, "robot_battery")
+	test.That(t, readings[0].Metadata.GetMethodName(), test.ShouldEqual, "Readings")
+	test.That(t, readings[0].TimeReceived.Equal(time.Unix(1, 0)), test.ShouldBeTrue)
+	test.That(t, readings[0].Data.GetStruct().AsMap(), test.ShouldResemble,
+		map[string]interface{}{"readings": map[string]interface{}{"voltage": 12.5}})
+
+	_, err = NewMCAPSensorDataReader(bytes.NewReader([]byte("not an mcap file")))
+	test.That(t, err, test.ShouldNotBeNil)
+
+	// a file cut short within its data section is an error rather than the end of the file.
+	sr, err := NewMCAPSensorDataReader(bytes.NewReader(file[:len(file)/2]))
+	test.That(t, err, test.ShouldBeNil)
+	_, err = sr.Next()
+	test.That(t, err, test.ShouldEqual, io.ErrUnexpectedEOF)
+}
@@ -87,6 +87,7 @@ type builtIn struct {
 	capture           *capture.Capture
 	sync              *datasync.Sync
 	diskSummaryLogger *diskSummaryLogger
+	captureDir        string
 }
 
 // New returns a new builtin data manager service for the given robot.
@@ -140,6 +141,36 @@ func (b *builtIn) Close(_ context.Context) error {
 	return nil
 }
 
+// DoCommand supports the following commands:
+//   - "trigger" fires the capture trigger it names, recording a window of data from the collectors it gates.
+//   - "retention_report" returns the capture files the retention policies would delete now, by directory.
+func (b *builtIn) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	if _, ok := cmd[retentionReportCommand]; ok {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		planned, err := b.sync.PlanRetention(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return retentionReport(ctx, b.captureDir, planned), nil
+	}
+	rawName, ok := cmd[triggerCommand]
+	if !ok {
+		return nil, resource.ErrDoUnimplemented
+	}
+	name, ok := rawName.(string)
+	if !ok {
+		return nil, errors.New("trigger must be the name of a capture trigger")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	recording, err := b.capture.Trigger(name)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"collectors": recording}, nil
+}
+
 // TODO: Determine desired behavior if sync is disabled. Do we wan to allow manual syncs, then?
 //       If so, how could a user cancel it?
 
@@ -192,6 +223,7 @@ func (b *builtIn) Reconfigure(ctx context.Context, deps resource.Dependencies, c
 	}
 
 	captureConfig := c.captureConfig()
+	captureConfig.Triggers = captureTriggers(c.CaptureTriggers, deps, b.logger)
 	collectorConfigsByResource, err := lookupCollectorConfigsByResource(deps, conf, captureConfig.CaptureDir, b.logger)
 	if err != nil {
 		// If this error occurs it's a resource graph error
@@ -212,6 +244,7 @@ func (b *builtIn) Reconfigure(ctx context.Context, deps resource.Dependencies, c
 	b.diskSummaryLogger.reconfigure(syncConfig.SyncPaths(), diskSummaryLogInterval)
 	b.capture.Reconfigure(ctx, collectorConfigsByResource, captureConfig)
 	b.sync.Reconfigure(ctx, syncConfig, cloudConnSvc)
+	b.captureDir = syncConfig.CaptureDir
 
 	return nil
 }
@@ -4,9 +4,12 @@ package capture
 import (
 	"context"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/benbjohnson/clock"
 	"github.com/pkg/errors"
@@ -52,11 +55,17 @@ type Capture struct {
 
 	collectorsMu sync.Mutex
 	collectors   collectors
+	triggers     map[string]Trigger
+
+	// triggerWorkers poll the conditions of the triggers, it is nil when no trigger has a condition.
+	triggerWorkers *goutils.StoppableWorkers
 
 	// captureDir is only stored on Capture so that we can detect when it changs
 	captureDir string
 	// maxCaptureFileSize is only stored on Capture so that we can detect when it changs
 	maxCaptureFileSize int64
+	// fileOptions is only stored on Capture so that we can detect when they change
+	fileOptions data.CaptureFileOptions
 }
 
 type (
@@ -71,6 +80,9 @@ type collectorAndConfig struct {
 	Resource  resource.Resource
 	Collector data.Collector
 	Config    datamanager.DataCaptureConfig
+	// Buffer holds the readings of a collector gated by triggers, it is nil for other collectors.
+	Buffer  *data.TriggeredCaptureBuffer
+	PreRoll time.Duration
 }
 
 // Identifier for a particular collector: component name, component model, component type,
@@ -84,13 +96,15 @@ func New(
 		clk:        clock,
 		logger:     logger,
 		collectors: collectors{},
+		triggers:   map[string]Trigger{},
 	}
 }
 
 func format(c datamanager.DataCaptureConfig) string {
 	return fmt.Sprintf("datamanager.DataCaptureConfig{"+
-		"Name: %s, Method: %s, CaptureFrequencyHz: %f, CaptureQueueSize: %d, AdditionalParams:	%v, Disabled: %t, Tags: %v, CaptureDirectory: %s}",
-		c.Name, c.Method, c.CaptureFrequencyHz, c.CaptureQueueSize, c.AdditionalParams, c.Disabled, c.Tags, c.CaptureDirectory)
+		"Name: %s, Method: %s, CaptureFrequencyHz: %f, CaptureQueueSize: %d, AdditionalParams:	%v, Disabled: %t, Tags: %v, CaptureDirectory: %s, "+
+		"Triggers: %v}",
+		c.Name, c.Method, c.CaptureFrequencyHz, c.CaptureQueueSize, c.AdditionalParams, c.Disabled, c.Tags, c.CaptureDirectory, c.Triggers)
 }
 
 func (c *Capture) newCollectors(collectorConfigsByResource CollectorConfigsByResource, config Config) collectors {
@@ -142,12 +156,23 @@ func (c *Capture) Reconfigure(
 
 	if c.captureDir != config.CaptureDir {
 		c.logger.Infof("capture_dir old: %s, new: %s", c.captureDir, config.CaptureDir)
+		if c.captureDir == "" {
+			// no collector has written to the capture directory yet, so any in progress file in it was
+			// interrupted, such as by a power cut.
+			c.recoverInterruptedFiles(ctx, config.CaptureDir)
+		}
+	}
+
+	if c.fileOptions != config.FileOptions {
+		c.logger.Infof("capture file options old: %+v, new: %+v", c.fileOptions, config.FileOptions)
 	}
 
 	if c.maxCaptureFileSize != config.MaximumCaptureFileSizeBytes {
 		c.logger.Infof("maximum_capture_file_size_bytes old: %d, new: %d", c.maxCaptureFileSize, config.MaximumCaptureFileSizeBytes)
 	}
 
+	// The old triggers are stopped before the collectors are locked, as firing a trigger locks them.
+	c.stopTriggers()
 	newCollectors := c.newCollectors(collectorConfigsByResource, config)
 	// If a component/method has been removed from the config, close the collector.
 	c.collectorsMu.Lock()
@@ -158,13 +183,40 @@ func (c *Capture) Reconfigure(
 		}
 	}
 	c.collectors = newCollectors
+	c.triggers = map[string]Trigger{}
+	for _, trigger := range config.Triggers {
+		c.triggers[trigger.Name] = trigger
+	}
 	c.collectorsMu.Unlock()
+	c.startTriggers(config.Triggers)
 	c.captureDir = config.CaptureDir
 	c.maxCaptureFileSize = config.MaximumCaptureFileSizeBytes
+	c.fileOptions = config.FileOptions
+}
+
+// recoverInterruptedFiles salvages the readings of the in progress data capture files under captureDir into
+// completed data capture files, so that they are synced.
+func (c *Capture) recoverInterruptedFiles(ctx context.Context, captureDir string) {
+	goutils.UncheckedError(filepath.WalkDir(captureDir, func(path string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil || d.IsDir() || filepath.Ext(path) != data.InProgressCaptureFileExt {
+			return nil
+		}
+		recovered, corrupted, err := data.RecoverCaptureFile(path)
+		if err != nil {
+			c.logger.Warnw("failed to recover interrupted data capture file", "file", path, "error", err)
+			return nil
+		}
+		c.logger.Infow("recovered interrupted data capture file", "file", path, "readings", recovered, "corrupted_readings", corrupted)
+		return nil
+	}))
 }
 
 // Close closes the capture manager.
 func (c *Capture) Close() {
+	c.stopTriggers()
 	c.FlushCollectors()
 	c.closeCollectors()
 }
@@ -183,11 +235,19 @@ func (c *Capture) initializeOrUpdateCollector(
 		return nil, err
 	}
 
+	preRoll, err := maxPreRoll(collectorConfig.Triggers, config.Triggers)
+	if err != nil {
+		return nil, err
+	}
+
 	maxFileSizeChanged := c.maxCaptureFileSize != config.MaximumCaptureFileSizeBytes
+	fileOptionsChanged := c.fileOptions != config.FileOptions
 	if storedCollectorAndConfig, ok := c.collectors[md]; ok {
 		if storedCollectorAndConfig.Config.Equals(&collectorConfig) &&
 			res == storedCollectorAndConfig.Resource &&
-			!maxFileSizeChanged {
+			storedCollectorAndConfig.PreRoll == preRoll &&
+			!maxFileSizeChanged &&
+			!fileOptionsChanged {
 			// If the attributes have not changed, do nothing and leave the existing collector.
 			return c.collectors[md], nil
 		}
@@ -224,15 +284,28 @@ func (c *Capture) initializeOrUpdateCollector(
 	if err := os.MkdirAll(targetDir, 0o700); err != nil {
 		return nil, errors.Wrapf(err, "failed to create target directory %s with 700 file permissions", targetDir)
 	}
-	// Build metadata.
-	captureMetadata := data.BuildCaptureMetadata(
-		collectorConfig.Name.API,
-		collectorConfig.Name.ShortName(),
-		collectorConfig.Method,
-		collectorConfig.AdditionalParams,
-		methodParams,
-		collectorConfig.Tags,
-	)
+	newCaptureBuffer := func(tags []string) *data.CaptureBuffer {
+		captureMetadata := data.BuildCaptureMetadata(
+			collectorConfig.Name.API,
+			collectorConfig.Name.ShortName(),
+			collectorConfig.Method,
+			collectorConfig.AdditionalParams,
+			methodParams,
+			tags,
+		)
+		return data.NewCaptureBufferWithOptions(targetDir, captureMetadata, config.MaximumCaptureFileSizeBytes, config.FileOptions)
+	}
+	var target data.CaptureBufferedWriter
+	var triggeredBuffer *data.TriggeredCaptureBuffer
+	if len(collectorConfig.Triggers) == 0 {
+		target = newCaptureBuffer(collectorConfig.Tags)
+	} else {
+		// The data recorded by a trigger is tagged with its name.
+		triggeredBuffer = data.NewTriggeredCaptureBuffer(targetDir, preRoll, func(trigger string) data.CaptureBufferedWriter {
+			return newCaptureBuffer(append(slices.Clone(collectorConfig.Tags), trigger))
+		})
+		target = triggeredBuffer
+	}
 	// Parameters to initialize collector.
 	queueSize := defaultIfZeroVal(collectorConfig.CaptureQueueSize, defaultCaptureQueueSize)
 	bufferSize := defaultIfZeroVal(collectorConfig.CaptureBufferSize, defaultCaptureBufferSize)
@@ -240,7 +313,7 @@ func (c *Capture) initializeOrUpdateCollector(
 		ComponentName: collectorConfig.Name.ShortName(),
 		Interval:      data.GetDurationFromHz(collectorConfig.CaptureFrequencyHz),
 		MethodParams:  methodParams,
-		Target:        data.NewCaptureBuffer(targetDir, captureMetadata, config.MaximumCaptureFileSizeBytes),
+		Target:        target,
 		// Set queue size to defaultCaptureQueueSize if it was not set in the config.
 		QueueSize:  queueSize,
 		BufferSize: bufferSize,
@@ -256,7 +329,111 @@ func (c *Capture) initializeOrUpdateCollector(
 		md, collectorConfigDescription(collectorConfig, targetDir, config.MaximumCaptureFileSizeBytes, queueSize, bufferSize))
 	collector.Collect()
 
-	return &collectorAndConfig{res, collector, collectorConfig}, nil
+	return &collectorAndConfig{
+		Resource:  res,
+		Collector: collector,
+		Config:    collectorConfig,
+		Buffer:    triggeredBuffer,
+		PreRoll:   preRoll,
+	}, nil
+}
+
+// maxPreRoll returns the longest pre-roll of the named triggers, which is how long a collector they gate
+// needs to keep its readings in memory for.
+func maxPreRoll(names []string, triggers []Trigger) (time.Duration, error) {
+	var preRoll time.Duration
+	for _, name := range names {
+		i := slices.IndexFunc(triggers, func(trigger Trigger) bool { return trigger.Name == name })
+		if i < 0 {
+			return 0, errors.Errorf("no capture trigger named %q", name)
+		}
+		preRoll = max(preRoll, triggers[i].PreRoll)
+	}
+	return preRoll, nil
+}
+
+// Trigger fires the trigger with the given name, recording a window of data from each collector it
+// gates. It returns the number of collectors which are recording.
+func (c *Capture) Trigger(name string) (int, error) {
+	c.collectorsMu.Lock()
+	defer c.collectorsMu.Unlock()
+	trigger, ok := c.triggers[name]
+	if !ok {
+		return 0, errors.Errorf("no capture trigger named %q", name)
+	}
+	now := c.clk.Now()
+	recording := 0
+	for md, collectorAndConfig := range c.collectors {
+		if collectorAndConfig.Buffer == nil || !slices.Contains(collectorAndConfig.Config.Triggers, name) {
+			continue
+		}
+		if err := collectorAndConfig.Buffer.Trigger(name, now, trigger.PreRoll, trigger.PostRoll); err != nil {
+			return recording, errors.Wrapf(err, "failed to record window of trigger %s for collector %s", name, md)
+		}
+		recording++
+	}
+	return recording, nil
+}
+
+// startTriggers starts polling the conditions of triggers.
+func (c *Capture) startTriggers(triggers []Trigger) {
+	var workers []func(context.Context)
+	for _, trigger := range triggers {
+		if trigger.Condition == nil {
+			continue
+		}
+		workers = append(workers, func(ctx context.Context) {
+			c.pollTrigger(ctx, trigger)
+		})
+	}
+	if len(workers) > 0 {
+		c.triggerWorkers = goutils.NewBackgroundStoppableWorkers(workers...)
+	}
+}
+
+func (c *Capture) stopTriggers() {
+	if c.triggerWorkers != nil {
+		c.triggerWorkers.Stop()
+		c.triggerWorkers = nil
+	}
+}
+
+// pollTrigger fires trigger every time its condition is met. A condition which stays met keeps extending
+// the windows being recorded.
+func (c *Capture) pollTrigger(ctx context.Context, trigger Trigger) {
+	ticker := c.clk.Ticker(trigger.PollInterval)
+	defer ticker.Stop()
+	var lastErr string
+	wasMet := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		met, err := trigger.Condition(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if err.Error() != lastErr {
+				c.logger.Warnw("failed to check capture trigger condition", "trigger", trigger.Name, "error", err)
+				lastErr = err.Error()
+			}
+			continue
+		}
+		lastErr = ""
+		if met && !wasMet {
+			c.logger.Infof("capture trigger %s fired", trigger.Name)
+		}
+		wasMet = met
+		if !met {
+			continue
+		}
+		if _, err := c.Trigger(trigger.Name); err != nil {
+			c.logger.Warnw("failed to fire capture trigger", "trigger", trigger.Name, "error", err)
+		}
+	}
 }
 
 func collectorConfigDescription(
@@ -267,9 +444,9 @@ func collectorConfigDescription(
 	bufferSize int,
 ) string {
 	return fmt.Sprintf("[CaptureFrequencyHz: %f, Tags: %v, MaximumCaptureFileSize: %s, "+
-		"CaptureBufferQueueSize: %d, CaptureBufferSize: %d, TargetDir: %s]",
+		"CaptureBufferQueueSize: %d, CaptureBufferSize: %d, TargetDir: %s, Triggers: %v]",
 		collectorConfig.CaptureFrequencyHz, collectorConfig.Tags, data.FormatBytesI64(maximumCaptureFileSizeBytes),
-		queueSize, bufferSize, targetDir,
+		queueSize, bufferSize, targetDir, collectorConfig.Triggers,
 	)
 }
 
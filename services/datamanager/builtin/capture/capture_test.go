@@ -1,11 +1,19 @@
 package capture
 
 import (
+	"context"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/benbjohnson/clock"
+	v1 "go.viam.com/api/app/datasync/v1"
 	"go.viam.com/test"
+	"google.golang.org/protobuf/types/known/structpb"
 
 	"go.viam.com/rdk/components/arm"
+	"go.viam.com/rdk/data"
+	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/services/datamanager"
 )
 
@@ -30,3 +38,27 @@ func TestDefaultIfZeroVal(t *testing.T) {
 	test.That(t, defaultIfZeroVal(nonDefaultF64, defaultValF64), test.ShouldAlmostEqual, nonDefaultF64)
 	test.That(t, defaultIfZeroVal(0, defaultValF64), test.ShouldAlmostEqual, defaultValF64)
 }
+
+func TestRecoverInterruptedFiles(t *testing.T) {
+	captureDir := t.TempDir()
+	dir := filepath.Join(captureDir, "rdk_component_arm", "arm1", "JointPositions")
+	test.That(t, os.MkdirAll(dir, 0o700), test.ShouldBeNil)
+	// a file left in progress by a previous run, such as one interrupted by a power cut.
+	f, err := data.NewCaptureFileWithOptions(dir, &v1.DataCaptureMetadata{Type: v1.DataType_DATA_TYPE_TABULAR_SENSOR},
+		data.CaptureFileOptions{Compression: data.CaptureFileCompressionGzip, Checksums: true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, f.WriteNext(&v1.SensorData{Data: &v1.SensorData_Struct{Struct: &structpb.Struct{}}}), test.ShouldBeNil)
+	test.That(t, f.Flush(), test.ShouldBeNil)
+
+	c := New(clock.New(), logging.NewTestLogger(t))
+	defer c.Close()
+	c.Reconfigure(context.Background(), CollectorConfigsByResource{}, Config{CaptureDir: captureDir})
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*"))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, paths, test.ShouldHaveLength, 1)
+	test.That(t, filepath.Ext(paths[0]), test.ShouldEqual, data.CompletedCaptureFileExt)
+	sd, err := data.SensorDataFromCaptureFilePath(paths[0])
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, sd, test.ShouldHaveLength, 1)
+}
@@ -1,5 +1,12 @@
 package capture
 
+import (
+	"context"
+	"time"
+
+	"go.viam.com/rdk/data"
+)
+
 // Config is the capture config.
 type Config struct {
 	// CaptureDisabled if set to true disables all data capture collectors
@@ -12,4 +19,26 @@ type Config struct {
 	// (.prog) files should be allowed to grow to before they are convered into .capture
 	// files
 	MaximumCaptureFileSizeBytes int64
+	// FileOptions defines how the readings of new data capture files are compressed and checksummed
+	FileOptions data.CaptureFileOptions
+	// Triggers defines the conditions which gate the collectors which reference them
+	Triggers []Trigger
+}
+
+// Trigger is a condition which records a window of data from the collectors which list it in their
+// triggers. Collectors with triggers keep their readings in memory and only write them around the moments
+// a trigger fires.
+type Trigger struct {
+	// Name identifies the trigger in collector configs and is added to the tags of the data it records.
+	Name string
+	// PreRoll is how long before the trigger fires data is recorded from.
+	PreRoll time.Duration
+	// PostRoll is how long after the trigger fires data keeps being recorded. While the condition
+	// stays met the window keeps being extended.
+	PostRoll time.Duration
+	// PollInterval is how often Condition is checked.
+	PollInterval time.Duration
+	// Condition reports whether the trigger should fire. Triggers with no condition only fire when
+	// requested through Capture.Trigger.
+	Condition func(ctx context.Context) (bool, error)
 }
@@ -0,0 +1,219 @@
+// package main inspects data capture files locally, without uploading them.
+// It exists purely as a convenience utilty for viam developers & solutions engineers.
+// Delete it if it becomes onerous to maintain.
+//
+// usage: inspect [-out dir] list|readings|extract|validate path...
+//
+//	list      prints the metadata, reading count and time range of every capture file
+//	readings  prints tabular readings as JSON lines
+//	extract   writes binary readings, such as images and point clouds, to files in -out
+//	validate  reports files which are corrupted or cut short, exiting with status 1 if there are any
+//
+// Paths may be capture files or directories, which are searched for capture files.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	v1 "go.viam.com/api/app/datasync/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"go.viam.com/rdk/data"
+)
+
+func main() {
+	out := flag.String("out", ".", "directory binary readings are extracted to")
+	flag.Parse()
+	if flag.NArg() < 2 {
+		//nolint:forbidigo
+		fmt.Printf("usage: %s [-out dir] list|readings|extract|validate path...\n", os.Args[0])
+		os.Exit(1)
+	}
+	paths, err := captureFilePaths(flag.Args()[1:])
+	if err != nil {
+		exit(err)
+	}
+
+	switch flag.Arg(0) {
+	case "list":
+		for _, path := range paths {
+			report, _ := data.InspectCaptureFile(path, nil)
+			//nolint:forbidigo
+			fmt.Println(formatReport(report))
+		}
+	case "readings":
+		for _, path := range paths {
+			if err := printReadings(path); err != nil {
+				exit(err)
+			}
+		}
+	case "extract":
+		if err := os.MkdirAll(*out, 0o700); err != nil {
+			exit(err)
+		}
+		for _, path := range paths {
+			if err := extractBinaryReadings(path, *out); err != nil {
+				exit(err)
+			}
+		}
+	case "validate":
+		invalid := 0
+		for _, path := range paths {
+			report, _ := data.InspectCaptureFile(path, nil)
+			if !report.Valid() {
+				invalid++
+			}
+			//nolint:forbidigo
+			fmt.Printf("%s: %s\n", path, formatProblems(report))
+		}
+		//nolint:forbidigo
+		fmt.Printf("%d of %d files are valid\n", len(paths)-invalid, len(paths))
+		if invalid > 0 {
+			os.Exit(1)
+		}
+	default:
+		exit(fmt.Errorf("unknown command %q, expected list, readings, extract or validate", flag.Arg(0)))
+	}
+}
+
+func exit(err error) {
+	//nolint:forbidigo
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
+
+// captureFilePaths returns the paths which are files and the completed and in progress capture files
+// within the paths which are directories.
+func captureFilePaths(roots []string) ([]string, error) {
+	var paths []string
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if path == root && !d.IsDir() {
+				paths = append(paths, path)
+				return nil
+			}
+			if ext := filepath.Ext(path); !d.IsDir() && (ext == data.CompletedCaptureFileExt || ext == data.InProgressCaptureFileExt) {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
+func formatReport(report data.CaptureFileReport) string {
+	if report.Metadata == nil {
+		return fmt.Sprintf("%s error: %s", report.Path, report.Err)
+	}
+	md := report.Metadata
+	s := fmt.Sprintf("%s component: %s %s method: %s type: %s extension: %s tags: [%s] size: %s readings: %d",
+		report.Path, md.GetComponentType(), md.GetComponentName(), md.GetMethodName(), md.GetType(),
+		md.GetFileExtension(), strings.Join(md.GetTags(), ","), data.FormatBytesI64(report.Size), report.Readings)
+	if report.Options.Compression != data.CaptureFileCompressionNone {
+		s += fmt.Sprintf(" compression: %s", report.Options.Compression)
+	}
+	if report.Options.Checksums {
+		s += " checksums: true"
+	}
+	if report.Readings > 0 {
+		s += fmt.Sprintf(" duration: %s, start: %s, end: %s", report.End.Sub(report.Start), report.Start, report.End)
+	}
+	if !report.Valid() {
+		s += " " + formatProblems(report)
+	}
+	return s
+}
+
+func formatProblems(report data.CaptureFileReport) string {
+	if report.Valid() {
+		return fmt.Sprintf("ok, %d readings", report.Readings)
+	}
+	var problems []string
+	if report.Corrupted > 0 {
+		problems = append(problems, fmt.Sprintf("%d readings failed their checksum", report.Corrupted))
+	}
+	if report.Truncated {
+		problems = append(problems, "the last reading was cut short")
+	}
+	if report.Err != nil {
+		problems = append(problems, fmt.Sprintf("error: %s", report.Err))
+	}
+	return fmt.Sprintf("invalid after reading %d readings, %s", report.Readings, strings.Join(problems, ", "))
+}
+
+// tabularReading is a tabular reading printed as a JSON line.
+type tabularReading struct {
+	Path          string          `json:"path"`
+	ComponentType string          `json:"component_type"`
+	ComponentName string          `json:"component_name"`
+	MethodName    string          `json:"method_name"`
+	TimeRequested time.Time       `json:"time_requested"`
+	TimeReceived  time.Time       `json:"time_received"`
+	Data          json.RawMessage `json:"data"`
+}
+
+func printReadings(path string) error {
+	encoder := json.NewEncoder(os.Stdout)
+	report, err := data.InspectCaptureFile(path, func(md *v1.DataCaptureMetadata, sd *v1.SensorData) error {
+		if sd.GetStruct() == nil {
+			return nil
+		}
+		reading, err := protojson.Marshal(sd.GetStruct())
+		if err != nil {
+			return err
+		}
+		return encoder.Encode(tabularReading{
+			Path:          path,
+			ComponentType: md.GetComponentType(),
+			ComponentName: md.GetComponentName(),
+			MethodName:    md.GetMethodName(),
+			TimeRequested: sd.GetMetadata().GetTimeRequested().AsTime(),
+			TimeReceived:  sd.GetMetadata().GetTimeReceived().AsTime(),
+			Data:          reading,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if !report.Valid() {
+		//nolint:forbidigo
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, formatProblems(report))
+	}
+	return nil
+}
+
+func extractBinaryReadings(path, out string) error {
+	extracted := 0
+	report, err := data.InspectCaptureFile(path, func(md *v1.DataCaptureMetadata, sd *v1.SensorData) error {
+		if sd.GetBinary() == nil {
+			return nil
+		}
+		extracted++
+		return os.WriteFile(filepath.Join(out, data.BinaryReadingFileName(md, sd)), sd.GetBinary(), 0o600)
+	})
+	if err != nil {
+		return err
+	}
+	if extracted > 0 {
+		//nolint:forbidigo
+		fmt.Printf("%s: extracted %d readings to %s\n", path, extracted, out)
+	}
+	if !report.Valid() {
+		//nolint:forbidigo
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, formatProblems(report))
+	}
+	return nil
+}
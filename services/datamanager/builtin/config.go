@@ -2,9 +2,12 @@ package builtin
 
 import (
 	"errors"
+	"fmt"
 	"runtime"
+	"slices"
 
 	"go.viam.com/rdk/components/sensor"
+	"go.viam.com/rdk/data"
 	"go.viam.com/rdk/internal/cloud"
 	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/services/datamanager/builtin/capture"
@@ -39,9 +42,13 @@ type Config struct {
 	CaptureDir string   `json:"capture_dir"`
 	Tags       []string `json:"tags"`
 	// Capture
-	CaptureDisabled             bool  `json:"capture_disabled"`
-	DeleteEveryNthWhenDiskFull  int   `json:"delete_every_nth_when_disk_full"`
-	MaximumCaptureFileSizeBytes int64 `json:"maximum_capture_file_size_bytes"`
+	CaptureDisabled             bool            `json:"capture_disabled"`
+	DeleteEveryNthWhenDiskFull  int             `json:"delete_every_nth_when_disk_full"`
+	MaximumCaptureFileSizeBytes int64           `json:"maximum_capture_file_size_bytes"`
+	CaptureTriggers             []TriggerConfig `json:"capture_triggers,omitempty"`
+	// CaptureFileCompression is one of gzip or zstd, readings are not compressed if unset
+	CaptureFileCompression string `json:"capture_file_compression,omitempty"`
+	CaptureFileChecksums   bool   `json:"capture_file_checksums,omitempty"`
 	// Sync
 	AdditionalSyncPaths    []string `json:"additional_sync_paths"`
 	FileLastModifiedMillis int      `json:"file_last_modified_millis"`
@@ -49,6 +56,11 @@ type Config struct {
 	ScheduledSyncDisabled  bool     `json:"sync_disabled"`
 	SelectiveSyncerName    string   `json:"selective_syncer_name"`
 	SyncIntervalMins       float64  `json:"sync_interval_mins"`
+	// Sync destination, the cloud if unset
+	SyncDestination *SyncDestinationConfig `json:"sync_destination,omitempty"`
+	// Retention
+	RetentionPolicies []RetentionPolicyConfig `json:"retention_policies,omitempty"`
+	RetentionDryRun   bool                    `json:"retention_dry_run,omitempty"`
 }
 
 // Validate returns components which will be depended upon weakly due to the above matcher.
@@ -68,7 +80,40 @@ func (c *Config) Validate(path string) ([]string, error) {
 	if c.DeleteEveryNthWhenDiskFull < 0 {
 		return nil, errors.New("delete_every_nth_when_disk_full can't be negative")
 	}
-	return []string{cloud.InternalServiceName.String()}, nil
+	switch data.CaptureFileCompression(c.CaptureFileCompression) {
+	case data.CaptureFileCompressionNone, data.CaptureFileCompressionGzip, data.CaptureFileCompressionZstd:
+	default:
+		return nil, fmt.Errorf("capture_file_compression must be one of %s or %s, got %q",
+			data.CaptureFileCompressionGzip, data.CaptureFileCompressionZstd, c.CaptureFileCompression)
+	}
+	if c.SyncDestination != nil {
+		if err := c.SyncDestination.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	for i := range c.RetentionPolicies {
+		if err := c.RetentionPolicies[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+	deps := []string{cloud.InternalServiceName.String()}
+	triggerNames := map[string]bool{}
+	for i := range c.CaptureTriggers {
+		if err := c.CaptureTriggers[i].Validate(); err != nil {
+			return nil, err
+		}
+		if triggerNames[c.CaptureTriggers[i].Name] {
+			return nil, fmt.Errorf("capture trigger names must be unique, %s is used more than once", c.CaptureTriggers[i].Name)
+		}
+		triggerNames[c.CaptureTriggers[i].Name] = true
+		// the conditions of triggers read from their sensor or vision service, which may not be collected from.
+		for _, dep := range []string{c.CaptureTriggers[i].Sensor, c.CaptureTriggers[i].VisionService} {
+			if dep != "" && !slices.Contains(deps, dep) {
+				deps = append(deps, dep)
+			}
+		}
+	}
+	return deps, nil
 }
 
 func (c *Config) getCaptureDir() string {
@@ -89,6 +134,10 @@ func (c *Config) captureConfig() capture.Config {
 		CaptureDir:                  c.getCaptureDir(),
 		Tags:                        c.Tags,
 		MaximumCaptureFileSizeBytes: maximumCaptureFileSizeBytes,
+		FileOptions: data.CaptureFileOptions{
+			Compression: data.CaptureFileCompression(c.CaptureFileCompression),
+			Checksums:   c.CaptureFileChecksums,
+		},
 	}
 }
 
@@ -124,6 +173,8 @@ func (c *Config) syncConfig(syncSensor sensor.Sensor, syncSensorEnabled bool, lo
 		CaptureDir:                 c.getCaptureDir(),
 		CaptureDisabled:            c.CaptureDisabled,
 		DeleteEveryNthWhenDiskFull: c.DeleteEveryNthWhenDiskFull,
+		RetentionPolicies:          retentionPolicies(c.RetentionPolicies),
+		RetentionDryRun:            c.RetentionDryRun,
 		FileLastModifiedMillis:     c.FileLastModifiedMillis,
 		MaximumNumSyncThreads:      c.MaximumNumSyncThreads,
 		ScheduledSyncDisabled:      c.ScheduledSyncDisabled,
@@ -131,5 +182,6 @@ func (c *Config) syncConfig(syncSensor sensor.Sensor, syncSensorEnabled bool, lo
 		SyncIntervalMins:           syncIntervalMins,
 		SelectiveSyncSensor:        syncSensor,
 		SelectiveSyncSensorEnabled: syncSensorEnabled,
+		Destination:                c.SyncDestination.destinationConfig(),
 	}
 }
@@ -7,6 +7,7 @@ import (
 
 	"go.viam.com/test"
 
+	"go.viam.com/rdk/data"
 	"go.viam.com/rdk/internal/cloud"
 	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/services/datamanager/builtin/capture"
@@ -21,6 +22,8 @@ var fullConfig = &Config{
 	DeleteEveryNthWhenDiskFull:  2,
 	FileLastModifiedMillis:      50000,
 	MaximumCaptureFileSizeBytes: 5,
+	CaptureFileCompression:      "zstd",
+	CaptureFileChecksums:        true,
 	MaximumNumSyncThreads:       10,
 	ScheduledSyncDisabled:       true,
 	SelectiveSyncerName:         "some name",
@@ -31,6 +34,7 @@ var fullConfig = &Config{
 func TestConfig(t *testing.T) {
 	logger := logging.NewTestLogger(t)
 	t.Run("Validate ", func(t *testing.T) {
+		above, below := 30., 5.
 		type testCase struct {
 			name   string
 			config Config
@@ -69,6 +73,71 @@ func TestConfig(t *testing.T) {
 				config: Config{DeleteEveryNthWhenDiskFull: -1},
 				err:    errors.New("delete_every_nth_when_disk_full can't be negative"),
 			},
+			{
+				name: "returns an error if a retention policy has an unknown priority",
+				config: Config{RetentionPolicies: []RetentionPolicyConfig{
+					{Resource: "cam", Priority: "low"},
+					{Resource: "bumper", Priority: "critical"},
+				}},
+				err: errors.New(`retention policy for resource "bumper" method "" priority must be one of low, normal or high, ` +
+					`got "critical"`),
+			},
+			{
+				name:   "returns an error if a retention policy has a negative max_bytes",
+				config: Config{RetentionPolicies: []RetentionPolicyConfig{{Method: "ReadImage", MaxBytes: -1}}},
+				err:    errors.New(`retention policy for resource "" method "ReadImage" max_bytes can't be negative`),
+			},
+			{
+				name:   "returns an error if CaptureFileCompression is unknown",
+				config: Config{CaptureFileCompression: "lz4"},
+				err:    errors.New(`capture_file_compression must be one of gzip or zstd, got "lz4"`),
+			},
+			{
+				name:   "returns an error if a directory sync destination has no path",
+				config: Config{SyncDestination: &SyncDestinationConfig{Type: "directory"}},
+				err:    errors.New("sync_destination of type directory requires path"),
+			},
+			{
+				name: "returns an error if an s3 sync destination endpoint is not a URL",
+				config: Config{SyncDestination: &SyncDestinationConfig{
+					Type: "s3", Endpoint: "minio.local:9000", Bucket: "robot-data", AccessKeyID: "id", SecretAccessKey: "secret",
+				}},
+				err: errors.New(`sync_destination endpoint must be an http or https URL, got "minio.local:9000"`),
+			},
+			{
+				name:   "returns an error if a sync destination has an unknown type",
+				config: Config{SyncDestination: &SyncDestinationConfig{Type: "ftp"}},
+				err:    errors.New(`sync_destination type must be one of cloud, directory or s3, got "ftp"`),
+			},
+			{
+				name: "returns the sensors and vision services of capture triggers as dependencies",
+				config: Config{CaptureTriggers: []TriggerConfig{
+					{Name: "manual", Type: triggerTypeDoCommand, PreRollSecs: 5, PostRollSecs: 5},
+					{Name: "person", Type: triggerTypeVisionDetection, VisionService: "detector", Camera: "cam", Label: "person"},
+					{Name: "hot", Type: triggerTypeSensorThreshold, Sensor: "thermometer", ReadingKey: "temperature", Above: &above},
+					{Name: "cold", Type: triggerTypeSensorThreshold, Sensor: "thermometer", ReadingKey: "temperature", Below: &below},
+				}},
+				deps: []string{cloud.InternalServiceName.String(), "detector", "thermometer"},
+			},
+			{
+				name:   "returns an error if a capture trigger has an unknown type",
+				config: Config{CaptureTriggers: []TriggerConfig{{Name: "manual", Type: "button"}}},
+				err: errors.New("capture trigger manual type must be one of sensor_threshold, vision_detection or do_command, " +
+					`got "button"`),
+			},
+			{
+				name:   "returns an error if a sensor threshold capture trigger has no threshold",
+				config: Config{CaptureTriggers: []TriggerConfig{{Name: "hot", Type: triggerTypeSensorThreshold, Sensor: "s", ReadingKey: "temp"}}},
+				err:    errors.New("capture trigger hot of type sensor_threshold requires above or below"),
+			},
+			{
+				name: "returns an error if capture trigger names are not unique",
+				config: Config{CaptureTriggers: []TriggerConfig{
+					{Name: "manual", Type: triggerTypeDoCommand},
+					{Name: "manual", Type: triggerTypeDoCommand},
+				}},
+				err: errors.New("capture trigger names must be unique, manual is used more than once"),
+			},
 		}
 
 		for _, tc := range tcs {
@@ -110,6 +179,7 @@ func TestConfig(t *testing.T) {
 				CaptureDisabled:             true,
 				CaptureDir:                  "/tmp/some/path",
 				MaximumCaptureFileSizeBytes: 5,
+				FileOptions:                 data.CaptureFileOptions{Compression: data.CaptureFileCompressionZstd, Checksums: true},
 				Tags:                        []string{"a", "b", "c"},
 			})
 		})
@@ -154,5 +224,24 @@ func TestConfig(t *testing.T) {
 				Tags:                       []string{"a", "b", "c"},
 			})
 		})
+
+		t.Run("returns a sync config with the sync destination", func(t *testing.T) {
+			c := &Config{SyncDestination: &SyncDestinationConfig{
+				Type:            "s3",
+				Endpoint:        "http://minio.local:9000",
+				Bucket:          "robot-data",
+				Prefix:          "site-a",
+				AccessKeyID:     "id",
+				SecretAccessKey: "secret",
+			}}
+			test.That(t, c.syncConfig(nil, false, logger).Destination, test.ShouldResemble, sync.DestinationConfig{
+				Type:            sync.DestinationTypeS3,
+				Endpoint:        "http://minio.local:9000",
+				Bucket:          "robot-data",
+				Prefix:          "site-a",
+				AccessKeyID:     "id",
+				SecretAccessKey: "secret",
+			})
+		})
 	})
 }
@@ -0,0 +1,110 @@
+package builtin
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	datasync "go.viam.com/rdk/services/datamanager/builtin/sync"
+)
+
+// retentionReportCommand is the DoCommand key which returns what the retention policies would delete now.
+const retentionReportCommand = "retention_report"
+
+var retentionPriorities = map[string]datasync.RetentionPriority{
+	"":       datasync.RetentionPriorityNormal,
+	"low":    datasync.RetentionPriorityLow,
+	"normal": datasync.RetentionPriorityNormal,
+	"high":   datasync.RetentionPriorityHigh,
+}
+
+// RetentionPolicyConfig describes how long and how much of the data captured from a resource method is
+// kept, and its priority when the disk is full. The first policy matching a resource method applies.
+type RetentionPolicyConfig struct {
+	// Resource and Method select what the policy applies to, everything if empty.
+	Resource    string  `json:"resource,omitempty"`
+	Method      string  `json:"method,omitempty"`
+	MaxAgeHours float64 `json:"max_age_hours,omitempty"`
+	MaxBytes    int64   `json:"max_bytes,omitempty"`
+	// Priority is one of low, normal (the default) or high. Low priority data, such as bulk camera
+	// frames, is evicted first when the disk is full, and high priority data, such as safety events, last.
+	Priority string `json:"priority,omitempty"`
+}
+
+// Validate ensures the limits and priority of the policy are valid.
+func (rc *RetentionPolicyConfig) Validate() error {
+	if rc.MaxAgeHours < 0 {
+		return fmt.Errorf("retention policy for resource %q method %q max_age_hours can't be negative", rc.Resource, rc.Method)
+	}
+	if rc.MaxBytes < 0 {
+		return fmt.Errorf("retention policy for resource %q method %q max_bytes can't be negative", rc.Resource, rc.Method)
+	}
+	if _, ok := retentionPriorities[rc.Priority]; !ok {
+		return fmt.Errorf("retention policy for resource %q method %q priority must be one of low, normal or high, got %q",
+			rc.Resource, rc.Method, rc.Priority)
+	}
+	return nil
+}
+
+func retentionPolicies(configs []RetentionPolicyConfig) []datasync.RetentionPolicy {
+	if len(configs) == 0 {
+		return nil
+	}
+	policies := make([]datasync.RetentionPolicy, 0, len(configs))
+	for _, rc := range configs {
+		policies = append(policies, datasync.RetentionPolicy{
+			Resource: rc.Resource,
+			Method:   rc.Method,
+			MaxAge:   time.Duration(rc.MaxAgeHours * float64(time.Hour)),
+			MaxBytes: rc.MaxBytes,
+			Priority: retentionPriorities[rc.Priority],
+		})
+	}
+	return policies
+}
+
+// retentionReport summarizes the files the retention policies would delete, alongside the DiskSummary of
+// the directories they are in.
+func retentionReport(ctx context.Context, captureDir string, planned []datasync.PlannedDeletion) map[string]interface{} {
+	plannedByDir := map[string][]datasync.PlannedDeletion{}
+	for _, d := range planned {
+		dir := filepath.Dir(d.Path)
+		plannedByDir[dir] = append(plannedByDir[dir], d)
+	}
+
+	var totalFiles, totalBytes int64
+	dirs := []interface{}{}
+	for _, summary := range DiskSummary(ctx, captureDir) {
+		dir := map[string]interface{}{
+			"path":            summary.Path,
+			"file_count":      summary.FileCount,
+			"file_size_bytes": summary.FileSize,
+		}
+		if summary.DataTimeRange != nil {
+			dir["data_start"] = summary.DataTimeRange.Start.Format(time.RFC3339Nano)
+			dir["data_end"] = summary.DataTimeRange.End.Format(time.RFC3339Nano)
+		}
+		var deleteBytes int64
+		deletions := []interface{}{}
+		for _, d := range plannedByDir[summary.Path] {
+			deleteBytes += d.Size
+			deletions = append(deletions, map[string]interface{}{
+				"file":   filepath.Base(d.Path),
+				"bytes":  d.Size,
+				"reason": d.Reason,
+			})
+		}
+		dir["delete_file_count"] = len(deletions)
+		dir["delete_bytes"] = deleteBytes
+		dir["deletions"] = deletions
+		totalFiles += int64(len(deletions))
+		totalBytes += deleteBytes
+		dirs = append(dirs, dir)
+	}
+	return map[string]interface{}{
+		"directories":       dirs,
+		"delete_file_count": totalFiles,
+		"delete_bytes":      totalBytes,
+	}
+}
@@ -0,0 +1,51 @@
+package builtin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.viam.com/test"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	datasync "go.viam.com/rdk/services/datamanager/builtin/sync"
+)
+
+func TestRetentionReport(t *testing.T) {
+	captureDir := t.TempDir()
+	camDir := filepath.Join(captureDir, "rdk_component_camera", "cam", "ReadImage")
+	armDir := filepath.Join(captureDir, "rdk_component_arm", "arm1", "EndPosition")
+	for _, dir := range []string{camDir, armDir} {
+		test.That(t, os.MkdirAll(dir, 0o700), test.ShouldBeNil)
+		for _, name := range []string{"2024-01-01T00_00_00Z.capture", "2024-01-02T00_00_00Z.capture"} {
+			test.That(t, os.WriteFile(filepath.Join(dir, name), make([]byte, 10), 0o600), test.ShouldBeNil)
+		}
+	}
+
+	report := retentionReport(context.Background(), captureDir, []datasync.PlannedDeletion{
+		{Path: filepath.Join(camDir, "2024-01-01T00_00_00Z.capture"), Size: 10, Reason: datasync.DeletionReasonDiskFull},
+	})
+	test.That(t, report["delete_file_count"], test.ShouldEqual, 1)
+	test.That(t, report["delete_bytes"], test.ShouldEqual, 10)
+	dirs := report["directories"].([]interface{})
+	test.That(t, len(dirs), test.ShouldEqual, 2)
+	for _, rawDir := range dirs {
+		dir := rawDir.(map[string]interface{})
+		test.That(t, dir["file_count"], test.ShouldEqual, 2)
+		test.That(t, dir["file_size_bytes"], test.ShouldEqual, 20)
+		test.That(t, dir["data_start"], test.ShouldEqual, "2024-01-01T00:00:00Z")
+		test.That(t, dir["data_end"], test.ShouldEqual, "2024-01-02T00:00:00Z")
+		if dir["path"] == camDir {
+			test.That(t, dir["deletions"], test.ShouldResemble, []interface{}{
+				map[string]interface{}{"file": "2024-01-01T00_00_00Z.capture", "bytes": int64(10), "reason": "disk_full"},
+			})
+		} else {
+			test.That(t, dir["delete_file_count"], test.ShouldEqual, 0)
+		}
+	}
+
+	// the report can be returned by DoCommand.
+	_, err := structpb.NewStruct(report)
+	test.That(t, err, test.ShouldBeNil)
+}
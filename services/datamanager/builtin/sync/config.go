@@ -56,8 +56,16 @@ type Config struct {
 	//
 	// The intent is to prevent data capture from filling up the
 	// disk if the robot is unable to sync data for a long period
-	// of time. Defaults to 5.
+	// of time. Defaults to 5. Ignored when RetentionPolicies are set.
 	DeleteEveryNthWhenDiskFull int
+	// RetentionPolicies, when set, replace deleting every Nth file when the disk is full:
+	// data capture files are deleted past the maximum age and bytes of their policy, and
+	// when the disk is full they are evicted oldest first, lowest priority first.
+	// See PlanDeletions for more info.
+	RetentionPolicies []RetentionPolicy
+	// RetentionDryRun, when true, logs the files the RetentionPolicies would delete instead
+	// of deleting them.
+	RetentionDryRun bool
 	// FileLastModifiedMillis defines the number of milliseconds that
 	// we should wait for an arbitrary file (aka a file that doesn't end in
 	// either the .prog nor the .capture file extension) before we consider
@@ -77,6 +85,8 @@ type Config struct {
 	SyncIntervalMins float64
 	// Tags defines the tags which should be applied to arbitrary files at sync time
 	Tags []string
+	// Destination defines where files are uploaded to, defaults to the cloud.
+	Destination DestinationConfig
 	// SelectiveSyncSensorEnabled when set to true, indicates that SelectiveSyncerName was non empty string
 	// (meaning that the user configured a sync sensor). This will cause data sync to NOT sync if
 	// SelectiveSyncSensor is nil (which will happen if resource graph doesn't have a resource with that name)
@@ -99,12 +109,15 @@ func (c Config) Equal(o Config) bool {
 		c.CaptureDir == o.CaptureDir &&
 		c.CaptureDisabled == o.CaptureDisabled &&
 		c.DeleteEveryNthWhenDiskFull == o.DeleteEveryNthWhenDiskFull &&
+		reflect.DeepEqual(c.RetentionPolicies, o.RetentionPolicies) &&
+		c.RetentionDryRun == o.RetentionDryRun &&
 		c.FileLastModifiedMillis == o.FileLastModifiedMillis &&
 		c.MaximumNumSyncThreads == o.MaximumNumSyncThreads &&
 		c.ScheduledSyncDisabled == o.ScheduledSyncDisabled &&
 		c.SelectiveSyncerName == o.SelectiveSyncerName &&
 		c.SyncIntervalMins == o.SyncIntervalMins &&
 		reflect.DeepEqual(c.Tags, o.Tags) &&
+		c.Destination == o.Destination &&
 		c.SelectiveSyncSensorEnabled == o.SelectiveSyncSensorEnabled &&
 		c.SelectiveSyncSensor == o.SelectiveSyncSensor
 }
@@ -133,6 +146,14 @@ func (c *Config) logDiff(o Config, logger logging.Logger) {
 			c.DeleteEveryNthWhenDiskFull, o.DeleteEveryNthWhenDiskFull)
 	}
 
+	if !reflect.DeepEqual(c.RetentionPolicies, o.RetentionPolicies) {
+		logger.Infof("retention_policies: old: %+v, new: %+v", c.RetentionPolicies, o.RetentionPolicies)
+	}
+
+	if c.RetentionDryRun != o.RetentionDryRun {
+		logger.Infof("retention_dry_run: old: %t, new: %t", c.RetentionDryRun, o.RetentionDryRun)
+	}
+
 	if c.FileLastModifiedMillis != o.FileLastModifiedMillis {
 		logger.Infof("file_last_modified_millis: old: %d, new: %d", c.FileLastModifiedMillis, o.FileLastModifiedMillis)
 	}
@@ -157,6 +178,10 @@ func (c *Config) logDiff(o Config, logger logging.Logger) {
 		logger.Infof("tags: old: %s, new: %s", strings.Join(c.Tags, " "), strings.Join(o.Tags, " "))
 	}
 
+	if c.Destination != o.Destination {
+		logger.Infof("sync_destination: old: %s, new: %s", c.Destination, o.Destination)
+	}
+
 	if c.SelectiveSyncSensorEnabled != o.SelectiveSyncSensorEnabled {
 		logger.Infof("SelectiveSyncSensorEnabled: old: %t, new: %t", c.SelectiveSyncSensorEnabled, o.SelectiveSyncSensorEnabled)
 	}
@@ -0,0 +1,275 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/benbjohnson/clock"
+	"github.com/pkg/errors"
+	goutils "go.viam.com/utils"
+	"google.golang.org/grpc/connectivity"
+
+	"go.viam.com/rdk/data"
+	"go.viam.com/rdk/logging"
+)
+
+// The types of destination sync can upload to.
+const (
+	// DestinationTypeCloud uploads to Viam's data service, it is the default.
+	DestinationTypeCloud = "cloud"
+	// DestinationTypeDirectory copies files to a directory, such as a mounted NAS path.
+	DestinationTypeDirectory = "directory"
+	// DestinationTypeS3 uploads files to an S3 compatible bucket, such as an on-prem MinIO.
+	DestinationTypeS3 = "s3"
+)
+
+// errNotRetryable marks upload errors which retrying will never fix, such as a destination refusing
+// a file, so that the file is moved to the failed directory instead.
+var errNotRetryable = errors.New("not retryable")
+
+// DestinationConfig selects where sync uploads files to.
+type DestinationConfig struct {
+	// Type is one of DestinationTypeCloud, DestinationTypeDirectory or DestinationTypeS3.
+	// Defaults to DestinationTypeCloud.
+	Type string
+	// Path is the directory the directory destination copies files to.
+	Path string
+	// Endpoint is the base URL of the S3 compatible service, such as http://minio.local:9000.
+	Endpoint string
+	// Bucket is the S3 bucket files are uploaded to, and Prefix the key prefix they are uploaded under.
+	Bucket string
+	Prefix string
+	// Region is the region requests are signed for, defaults to us-east-1.
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// String describes the destination without its secret access key, so that it can be logged.
+func (c DestinationConfig) String() string {
+	switch c.Type {
+	case DestinationTypeDirectory:
+		return fmt.Sprintf("%s %s", c.Type, c.Path)
+	case DestinationTypeS3:
+		return fmt.Sprintf("%s %s/%s/%s region: %q access key id: %q", c.Type, c.Endpoint, c.Bucket, c.Prefix, c.Region, c.AccessKeyID)
+	default:
+		return DestinationTypeCloud
+	}
+}
+
+// Destination is where sync uploads files to. Sync retries failed uploads with exponential backoff,
+// unless the error is terminal, in which case the file is moved to the failed directory. Files are
+// deleted once they are uploaded.
+type Destination interface {
+	fmt.Stringer
+	// Ready returns a channel which is closed once the destination can be uploaded to.
+	Ready() <-chan struct{}
+	// Online returns an error describing why uploads can't currently succeed, if they can't.
+	Online() error
+	// UploadDataCaptureFile uploads a data capture file, whose path relative to the sync path it is in
+	// is key, and returns how many bytes were uploaded.
+	UploadDataCaptureFile(ctx context.Context, f *data.CaptureFile, key string) (uint64, error)
+	// UploadArbitraryFile uploads a file which was not written by data capture, whose path relative to
+	// the sync path it is in is key, and returns how many bytes were uploaded.
+	UploadArbitraryFile(ctx context.Context, f *os.File, key string) (uint64, error)
+}
+
+func (s *Sync) newDestination(config Config) (Destination, error) {
+	switch config.Destination.Type {
+	case "", DestinationTypeCloud:
+		return &cloudDestination{
+			conn:                   &s.cloudConn,
+			tags:                   config.Tags,
+			fileLastModifiedMillis: config.FileLastModifiedMillis,
+			clock:                  s.clock,
+			logger:                 s.logger,
+		}, nil
+	case DestinationTypeDirectory:
+		return newDirectoryDestination(config.Destination.Path), nil
+	case DestinationTypeS3:
+		return newS3Destination(config.Destination, config.Tags, s.clock), nil
+	default:
+		return nil, errors.Errorf("unknown sync destination type %q", config.Destination.Type)
+	}
+}
+
+// destinationKey returns the path of a file relative to the sync path it is in, prefixed by the name of
+// the sync path for additional sync paths so that their files can't collide with the capture directory's.
+func destinationKey(config Config, path string) string {
+	for i, root := range config.SyncPaths() {
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if i > 0 {
+			rel = filepath.Join(filepath.Base(root), rel)
+		}
+		return filepath.ToSlash(rel)
+	}
+	return filepath.Base(path)
+}
+
+// cloudDestination uploads to Viam's data service over the cloud connection.
+type cloudDestination struct {
+	conn                   *cloudConn
+	tags                   []string
+	fileLastModifiedMillis int
+	clock                  clock.Clock
+	logger                 logging.Logger
+}
+
+func (d *cloudDestination) String() string {
+	return "the cloud"
+}
+
+func (d *cloudDestination) Ready() <-chan struct{} {
+	return d.conn.ready
+}
+
+func (d *cloudDestination) Online() error {
+	if state := d.conn.connectivityStateEnabledConn.GetState(); state != connectivity.Ready {
+		return errors.Errorf("its connection is in state: %s; waiting for it to be in state: %s", state, connectivity.Ready)
+	}
+	return nil
+}
+
+func (d *cloudDestination) UploadDataCaptureFile(ctx context.Context, f *data.CaptureFile, _ string) (uint64, error) {
+	return uploadDataCaptureFile(ctx, f, *d.conn, d.logger)
+}
+
+func (d *cloudDestination) UploadArbitraryFile(ctx context.Context, f *os.File, _ string) (uint64, error) {
+	return uploadArbitraryFile(ctx, f, *d.conn, d.tags, d.fileLastModifiedMillis, d.clock, d.logger)
+}
+
+// directoryDestination copies files to a directory, keeping their paths relative to their sync path.
+type directoryDestination struct {
+	dir   string
+	ready chan struct{}
+}
+
+func newDirectoryDestination(dir string) *directoryDestination {
+	ready := make(chan struct{})
+	close(ready)
+	return &directoryDestination{dir: dir, ready: ready}
+}
+
+func (d *directoryDestination) String() string {
+	return "directory " + d.dir
+}
+
+func (d *directoryDestination) Ready() <-chan struct{} {
+	return d.ready
+}
+
+// Online returns an error if the directory doesn't exist, which is the case of an unmounted NAS path.
+func (d *directoryDestination) Online() error {
+	info, err := os.Stat(d.dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.Errorf("%s is not a directory", d.dir)
+	}
+	return nil
+}
+
+func (d *directoryDestination) UploadDataCaptureFile(ctx context.Context, f *data.CaptureFile, key string) (uint64, error) {
+	return withCaptureFileContents(f, func(file *os.File) (uint64, error) {
+		return d.copy(ctx, file, key)
+	})
+}
+
+func (d *directoryDestination) UploadArbitraryFile(ctx context.Context, f *os.File, key string) (uint64, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return d.copy(ctx, f, key)
+}
+
+// copy writes src to key under the directory, through a temporary file so that a partially copied file is
+// never mistaken for a complete one.
+func (d *directoryDestination) copy(ctx context.Context, src io.Reader, key string) (uint64, error) {
+	if err := d.Online(); err != nil {
+		return 0, err
+	}
+	dst := filepath.Join(d.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	written, err := io.Copy(tmp, contextReader{ctx: ctx, r: src})
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), dst)
+	}
+	if err != nil {
+		goutils.UncheckedError(os.Remove(tmp.Name()))
+		return 0, err
+	}
+	return uint64(written), nil
+}
+
+// withCaptureFileContents calls upload with the file f was read from, opened anew so that its read offset
+// is at the start of the file.
+func withCaptureFileContents(f *data.CaptureFile, upload func(*os.File) (uint64, error)) (uint64, error) {
+	//nolint:gosec
+	file, err := os.Open(f.GetPath())
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		goutils.UncheckedError(file.Close())
+	}()
+	return upload(file)
+}
+
+// unavailableDestination is used when the destination can't be built, so that nothing is uploaded anywhere
+// else until the config is fixed.
+type unavailableDestination struct {
+	err error
+}
+
+func (d unavailableDestination) String() string {
+	return "an unavailable destination"
+}
+
+func (d unavailableDestination) Ready() <-chan struct{} {
+	return nil
+}
+
+func (d unavailableDestination) Online() error {
+	return d.err
+}
+
+func (d unavailableDestination) UploadDataCaptureFile(context.Context, *data.CaptureFile, string) (uint64, error) {
+	return 0, d.err
+}
+
+func (d unavailableDestination) UploadArbitraryFile(context.Context, *os.File, string) (uint64, error) {
+	return 0, d.err
+}
+
+// contextReader stops reading once its context is cancelled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
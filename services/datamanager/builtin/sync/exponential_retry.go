@@ -3,6 +3,7 @@ package sync
 import (
 	"context"
 	"errors"
+	"net"
 	"time"
 
 	"github.com/benbjohnson/clock"
@@ -10,6 +11,7 @@ import (
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
 
+	"go.viam.com/rdk/data"
 	"go.viam.com/rdk/logging"
 )
 
@@ -129,7 +131,7 @@ func (e exponentialRetry) run() (uint64, error) {
 			}
 
 			// Otherwise, try again after nextWait.
-			offline := isOfflineGRPCError(err)
+			offline := isOfflineGRPCError(err) || isNetworkError(err)
 			nextWait = getNextWait(nextWait, offline)
 			status := "online"
 			if offline {
@@ -149,6 +151,15 @@ func isOfflineGRPCError(err error) bool {
 	return errStatus.Code() == codes.Unavailable
 }
 
+// isNetworkError returns true if err is due to the destination being unreachable, such as a refused
+// connection or DNS lookup failure. Timeouts which are not from the network, such as context.DeadlineExceeded,
+// also implement net.Error, so only the errors of network operations are matched.
+func isNetworkError(err error) bool {
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	return errors.As(err, &opErr) || errors.As(err, &dnsErr)
+}
+
 func getNextWait(lastWait time.Duration, isOffline bool) time.Duration {
 	if lastWait == time.Duration(0) {
 		return time.Millisecond * time.Duration(InitialWaitTimeMillis)
@@ -169,5 +180,6 @@ func getNextWait(lastWait time.Duration, isOffline bool) time.Duration {
 // the data gets moved to the corrupted data directory and false otherwise.
 func terminalError(err error) bool {
 	errStatus := status.Convert(err)
-	return errStatus.Code() == codes.InvalidArgument || errors.Is(err, proto.Error)
+	return errStatus.Code() == codes.InvalidArgument || errors.Is(err, proto.Error) || errors.Is(err, errNotRetryable) ||
+		errors.Is(err, data.ErrCorruptCaptureFile)
 }
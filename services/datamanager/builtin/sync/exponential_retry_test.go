@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"net"
+	"net/url"
 	"os"
+	"syscall"
 	"testing"
 
 	"github.com/pkg/errors"
@@ -13,6 +16,8 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
+
+	"go.viam.com/rdk/data"
 )
 
 func TestTerminalError(t *testing.T) {
@@ -24,6 +29,8 @@ func TestTerminalError(t *testing.T) {
 	tcs := []testCase{
 		{err: proto.Error, isTerminal: true},
 		{err: status.Error(codes.InvalidArgument, ""), isTerminal: true},
+		{err: data.ErrCorruptCaptureFile, isTerminal: true},
+		{err: errNotRetryable, isTerminal: true},
 		{err: status.Error(codes.Canceled, "")},
 		{err: status.Error(codes.Unknown, "")},
 		{err: status.Error(codes.DeadlineExceeded, "")},
@@ -65,3 +72,33 @@ func TestTerminalError(t *testing.T) {
 		test.That(t, terminalError(errors.Wrapf(tc.err, "some context %s", "and more context")), test.ShouldEqual, tc.isTerminal)
 	}
 }
+
+func TestIsNetworkError(t *testing.T) {
+	type testCase struct {
+		err       error
+		isNetwork bool
+	}
+
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	noSuchHost := &net.DNSError{Err: "no such host", Name: "bucket.example.com", IsNotFound: true}
+	tcs := []testCase{
+		{err: refused, isNetwork: true},
+		{err: noSuchHost, isNetwork: true},
+		{err: &url.Error{Op: "Put", URL: "http://127.0.0.1:1", Err: refused}, isNetwork: true},
+		{err: &url.Error{Op: "Put", URL: "http://bucket.example.com", Err: noSuchHost}, isNetwork: true},
+
+		// these implement net.Error, but are not due to the destination being unreachable.
+		{err: context.DeadlineExceeded},
+		{err: os.ErrDeadlineExceeded},
+		{err: &url.Error{Op: "Put", URL: "http://127.0.0.1:1", Err: context.DeadlineExceeded}},
+
+		{err: context.Canceled},
+		{err: status.Error(codes.Unavailable, "")},
+		{err: errors.New("some new anonymous error")},
+	}
+
+	for _, tc := range tcs {
+		test.That(t, isNetworkError(tc.err), test.ShouldEqual, tc.isNetwork)
+		test.That(t, isNetworkError(errors.Wrap(tc.err, "some context")), test.ShouldEqual, tc.isNetwork)
+	}
+}
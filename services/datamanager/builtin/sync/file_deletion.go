@@ -29,8 +29,7 @@ var errAtSizeThreshold = errors.New("capture directory has reached or exceeded d
 func deleteExcessFilesOnSchedule(
 	ctx context.Context,
 	fileTracker *fileTracker,
-	captureDir string,
-	deleteEveryNth int,
+	config Config,
 	clock clock.Clock,
 	logger logging.Logger,
 ) {
@@ -49,7 +48,11 @@ func deleteExcessFilesOnSchedule(
 		case <-ctx.Done():
 			return
 		case <-t.C:
-			maybeDeleteExcessFiles(ctx, fileTracker, captureDir, deleteEveryNth, clock, logger)
+			if len(config.RetentionPolicies) > 0 {
+				applyRetention(ctx, fileTracker, config.CaptureDir, config.RetentionPolicies, config.RetentionDryRun, clock, logger)
+			} else {
+				maybeDeleteExcessFiles(ctx, fileTracker, config.CaptureDir, config.DeleteEveryNthWhenDiskFull, clock, logger)
+			}
 		}
 	}
 }
@@ -0,0 +1,275 @@
+package sync
+
+import (
+	"cmp"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/pkg/errors"
+
+	"go.viam.com/rdk/data"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/utils/diskusage"
+)
+
+// RetentionPriority orders the eviction of data capture files when the disk is full: the files of
+// lower priorities are all evicted before those of higher priorities are.
+type RetentionPriority int
+
+// The retention priorities, in eviction order. Normal is the zero value.
+const (
+	RetentionPriorityLow RetentionPriority = iota - 1
+	RetentionPriorityNormal
+	RetentionPriorityHigh
+)
+
+// The reasons a data capture file is deleted by retention.
+const (
+	DeletionReasonMaxAge   = "max_age"
+	DeletionReasonMaxBytes = "max_bytes"
+	DeletionReasonDiskFull = "disk_full"
+)
+
+// RetentionPolicy limits how long and how much of the data captured from the resource methods it matches
+// is kept on disk, and sets its priority when the disk is full.
+type RetentionPolicy struct {
+	// Resource is the short name of the resource the policy applies to, every resource if empty.
+	Resource string
+	// Method is the capture method the policy applies to, every method if empty.
+	Method string
+	// MaxAge is how long data capture files are kept for, forever if zero.
+	MaxAge time.Duration
+	// MaxBytes is how many bytes of data capture files are kept for each resource method, the oldest files
+	// being deleted first. Unlimited if zero.
+	MaxBytes int64
+	// Priority is the eviction priority of the data capture files when the disk is full.
+	Priority RetentionPriority
+}
+
+func (p RetentionPolicy) matches(resource, method string) bool {
+	return (p.Resource == "" || data.CaptureFilePathWithReplacedReservedChars(p.Resource) == resource) &&
+		(p.Method == "" || p.Method == method)
+}
+
+// policyFor returns the first policy matching the resource method whose files are in dir, which is
+// relative to the capture directory. Files no policy matches have no limits and a normal priority.
+func policyFor(policies []RetentionPolicy, dir string) RetentionPolicy {
+	// data capture writes to <capture dir>/<api>/<resource>/<method>.
+	parts := strings.Split(filepath.ToSlash(dir), "/")
+	if len(parts) != 3 {
+		return RetentionPolicy{}
+	}
+	for _, p := range policies {
+		if p.matches(parts[1], parts[2]) {
+			return p
+		}
+	}
+	return RetentionPolicy{}
+}
+
+// PlannedDeletion is a data capture file which retention deletes, along with why.
+type PlannedDeletion struct {
+	Path   string
+	Size   int64
+	Reason string
+}
+
+type retainedFile struct {
+	path    string
+	dir     string
+	size    int64
+	modTime time.Time
+	policy  RetentionPolicy
+}
+
+// PlanDeletions returns the completed data capture files under captureDir which the retention policies
+// delete, in the order they are deleted: files older than their maximum age, then the oldest files of the
+// resource methods over their maximum bytes, and then, if the disk is full as defined by
+// FSThresholdToTriggerDeletion and CaptureDirToFSUsageRatio, the oldest files of the lowest priority
+// until it no longer is.
+func PlanDeletions(
+	ctx context.Context,
+	captureDir string,
+	policies []RetentionPolicy,
+	usage diskusage.DiskUsage,
+	now time.Time,
+) ([]PlannedDeletion, error) {
+	var files []*retainedFile
+	var captureDirBytes int64
+	walk := func(path string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		captureDirBytes += info.Size()
+		if filepath.Ext(path) != data.CompletedCaptureFileExt {
+			return nil
+		}
+		dir, err := filepath.Rel(captureDir, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		files = append(files, &retainedFile{
+			path:    path,
+			dir:     dir,
+			size:    info.Size(),
+			modTime: info.ModTime(),
+			policy:  policyFor(policies, dir),
+		})
+		return nil
+	}
+	if err := filepath.WalkDir(captureDir, walk); err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(files, func(a, b *retainedFile) int { return a.modTime.Compare(b.modTime) })
+
+	var planned []PlannedDeletion
+	deleted := map[string]bool{}
+	var deletedBytes int64
+	plan := func(f *retainedFile, reason string) {
+		planned = append(planned, PlannedDeletion{Path: f.path, Size: f.size, Reason: reason})
+		deleted[f.path] = true
+		deletedBytes += f.size
+	}
+
+	for _, f := range files {
+		if f.policy.MaxAge > 0 && now.Sub(f.modTime) > f.policy.MaxAge {
+			plan(f, DeletionReasonMaxAge)
+		}
+	}
+
+	// files are visited newest first so that the oldest are the ones over the limit.
+	dirBytes := map[string]int64{}
+	var overLimit []*retainedFile
+	for i := len(files) - 1; i >= 0; i-- {
+		f := files[i]
+		if deleted[f.path] || f.policy.MaxBytes <= 0 {
+			continue
+		}
+		dirBytes[f.dir] += f.size
+		if dirBytes[f.dir] > f.policy.MaxBytes {
+			overLimit = append(overLimit, f)
+		}
+	}
+	slices.Reverse(overLimit)
+	for _, f := range overLimit {
+		plan(f, DeletionReasonMaxBytes)
+	}
+
+	toFree := bytesToFree(usage, captureDirBytes, deletedBytes)
+	if toFree <= 0 {
+		return planned, nil
+	}
+	byPriority := slices.Clone(files)
+	slices.SortStableFunc(byPriority, func(a, b *retainedFile) int { return cmp.Compare(a.policy.Priority, b.policy.Priority) })
+	for _, f := range byPriority {
+		if toFree <= 0 {
+			break
+		}
+		if deleted[f.path] {
+			continue
+		}
+		plan(f, DeletionReasonDiskFull)
+		toFree -= f.size
+	}
+	return planned, nil
+}
+
+// bytesToFree returns how many bytes need to be deleted from the capture directory for the disk to no
+// longer be considered full, after alreadyFreed bytes have been deleted.
+func bytesToFree(usage diskusage.DiskUsage, captureDirBytes, alreadyFreed int64) int64 {
+	if usage.SizeBytes == 0 {
+		return 0
+	}
+	size := float64(usage.SizeBytes)
+	used := size - float64(usage.AvailableBytes) - float64(alreadyFreed)
+	captureDirUsed := float64(captureDirBytes - alreadyFreed)
+	if used/size < FSThresholdToTriggerDeletion || captureDirUsed/size < CaptureDirToFSUsageRatio {
+		return 0
+	}
+	// the disk stops being full as soon as either threshold is no longer met.
+	return int64(min(used-FSThresholdToTriggerDeletion*size, captureDirUsed-CaptureDirToFSUsageRatio*size)) + 1
+}
+
+// applyRetention deletes the data capture files the retention policies plan to delete, or only logs them
+// when dryRun is true.
+func applyRetention(
+	ctx context.Context,
+	fileTracker *fileTracker,
+	captureDir string,
+	policies []RetentionPolicy,
+	dryRun bool,
+	clock clock.Clock,
+	logger logging.Logger,
+) {
+	start := clock.Now()
+	usage, err := diskusage.Statfs(captureDir)
+	if err != nil {
+		logger.Error(errors.Wrap(err, "error checking file system stats"))
+		return
+	}
+	planned, err := PlanDeletions(ctx, captureDir, policies, usage, start)
+	if err != nil {
+		logger.Errorw("error planning data capture file retention", "error", err)
+		return
+	}
+
+	deletedFileCount := 0
+	for _, d := range planned {
+		if dryRun {
+			logger.Infow("retention dry run, would delete", "file", d.Path, "bytes", d.Size, "reason", d.Reason)
+			continue
+		}
+		if !fileTracker.markInProgress(d.Path) {
+			logger.Debugw("Tried to mark file as in progress but lock already held", "file", d.Path)
+			continue
+		}
+		if err := os.Remove(d.Path); err != nil {
+			logger.Warnw("error deleting file", "error", err)
+			fileTracker.unmarkInProgress(d.Path)
+			continue
+		}
+		logger.Debugw("deleted file for retention", "file", d.Path, "reason", d.Reason)
+		deletedFileCount++
+	}
+	if deletedFileCount > 0 {
+		logger.Infof("%d files have been deleted by retention policies, execution time: %s", deletedFileCount, clock.Since(start))
+	}
+}
+
+// PlanRetention returns the data capture files the retention policies of the current config would delete
+// now, without deleting them. Nothing is planned when there are no retention policies.
+func (s *Sync) PlanRetention(ctx context.Context) ([]PlannedDeletion, error) {
+	s.configMu.Lock()
+	config := s.config
+	s.configMu.Unlock()
+	if len(config.RetentionPolicies) == 0 {
+		return nil, nil
+	}
+	usage, err := diskusage.Statfs(config.CaptureDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "error checking file system stats")
+	}
+	return PlanDeletions(ctx, config.CaptureDir, config.RetentionPolicies, usage, s.clock.Now())
+}
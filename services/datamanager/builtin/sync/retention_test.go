@@ -0,0 +1,83 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/utils/diskusage"
+)
+
+func TestPlanDeletions(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	captureDir := t.TempDir()
+
+	// writeFiles writes a file of size bytes every hour, the newest of which is an hour old.
+	writeFiles := func(dir string, count, size int) []string {
+		fullDir := filepath.Join(captureDir, dir)
+		test.That(t, os.MkdirAll(fullDir, 0o700), test.ShouldBeNil)
+		var paths []string
+		for i := 0; i < count; i++ {
+			age := time.Duration(count-i) * time.Hour
+			path := filepath.Join(fullDir, now.Add(-age).Format("20060102T150405")+".capture")
+			test.That(t, os.WriteFile(path, make([]byte, size), 0o600), test.ShouldBeNil)
+			test.That(t, os.Chtimes(path, now.Add(-age), now.Add(-age)), test.ShouldBeNil)
+			paths = append(paths, path)
+		}
+		return paths
+	}
+	frames := writeFiles("rdk_component_camera/cam/ReadImage", 2, 5)
+	events := writeFiles("rdk_component_sensor/bumper/Readings", 3, 10)
+	poses := writeFiles("rdk_component_arm/arm1/EndPosition", 4, 10)
+	// files being written to are never deleted.
+	test.That(t, os.WriteFile(filepath.Join(captureDir, "rdk_component_arm/arm1/EndPosition/now.prog"), make([]byte, 10), 0o600),
+		test.ShouldBeNil)
+	notFull := diskusage.DiskUsage{SizeBytes: 1e9, AvailableBytes: 9e8}
+
+	t.Run("age and size limits", func(t *testing.T) {
+		// only the first policy matching a resource method applies, so the bumper readings are kept.
+		policies := []RetentionPolicy{
+			{Resource: "cam", MaxBytes: 5},
+			{Resource: "bumper", Method: "Readings"},
+			{MaxAge: 150 * time.Minute},
+		}
+		planned, err := PlanDeletions(ctx, captureDir, policies, notFull, now)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, planned, test.ShouldResemble, []PlannedDeletion{
+			{Path: poses[0], Size: 10, Reason: DeletionReasonMaxAge},
+			{Path: poses[1], Size: 10, Reason: DeletionReasonMaxAge},
+			{Path: frames[0], Size: 5, Reason: DeletionReasonMaxBytes},
+		})
+	})
+
+	t.Run("disk full", func(t *testing.T) {
+		policies := []RetentionPolicy{
+			{Resource: "cam", Priority: RetentionPriorityLow},
+			{Resource: "bumper", Priority: RetentionPriorityHigh},
+		}
+		// the capture directory holds 90 bytes of a full 100 byte disk, so 11 bytes need to be deleted
+		// for the disk to be less than 90% full.
+		planned, err := PlanDeletions(ctx, captureDir, policies, diskusage.DiskUsage{SizeBytes: 100}, now)
+		test.That(t, err, test.ShouldBeNil)
+		// the oldest low priority files are evicted first, then the oldest normal priority ones.
+		test.That(t, planned, test.ShouldResemble, []PlannedDeletion{
+			{Path: frames[0], Size: 5, Reason: DeletionReasonDiskFull},
+			{Path: frames[1], Size: 5, Reason: DeletionReasonDiskFull},
+			{Path: poses[0], Size: 10, Reason: DeletionReasonDiskFull},
+		})
+		for _, d := range planned {
+			test.That(t, events, test.ShouldNotContain, d.Path)
+		}
+	})
+
+	t.Run("without policies", func(t *testing.T) {
+		planned, err := PlanDeletions(ctx, captureDir, nil, notFull, now)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, planned, test.ShouldBeEmpty)
+	})
+}
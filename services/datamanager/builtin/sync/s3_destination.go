@@ -0,0 +1,249 @@
+package sync
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/pkg/errors"
+
+	"go.viam.com/rdk/data"
+)
+
+const (
+	defaultS3Region = "us-east-1"
+	// s3OnlineTimeout bounds how long checking whether the bucket is reachable takes.
+	s3OnlineTimeout = 10 * time.Second
+	// s3ErrorBodyLimit is how much of an error response is included in the returned error.
+	s3ErrorBodyLimit = 512
+	amzDateFormat    = "20060102T150405Z"
+)
+
+// s3Destination uploads files to an S3 compatible bucket with path style requests signed with AWS
+// Signature Version 4, keyed by their path relative to their sync path under the configured prefix.
+type s3Destination struct {
+	endpoint        string
+	bucket          string
+	prefix          string
+	region          string
+	accessKeyID     string
+	secretAccessKey string
+	tags            []string
+	client          *http.Client
+	clock           clock.Clock
+	ready           chan struct{}
+}
+
+func newS3Destination(config DestinationConfig, tags []string, clock clock.Clock) *s3Destination {
+	region := config.Region
+	if region == "" {
+		region = defaultS3Region
+	}
+	ready := make(chan struct{})
+	close(ready)
+	return &s3Destination{
+		endpoint:        strings.TrimRight(config.Endpoint, "/"),
+		bucket:          config.Bucket,
+		prefix:          strings.Trim(config.Prefix, "/"),
+		region:          region,
+		accessKeyID:     config.AccessKeyID,
+		secretAccessKey: config.SecretAccessKey,
+		tags:            tags,
+		client:          &http.Client{},
+		clock:           clock,
+		ready:           ready,
+	}
+}
+
+func (d *s3Destination) String() string {
+	return fmt.Sprintf("s3 bucket %s at %s", d.bucket, d.endpoint)
+}
+
+func (d *s3Destination) Ready() <-chan struct{} {
+	return d.ready
+}
+
+// Online returns an error unless a HEAD request on the bucket succeeds.
+func (d *s3Destination) Online() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s3OnlineTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, d.endpoint+"/"+s3EscapePath(d.bucket), nil)
+	if err != nil {
+		return err
+	}
+	d.sign(req, emptyPayloadHash)
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	if err := resp.Body.Close(); err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("bucket %s responded %s", d.bucket, resp.Status)
+	}
+	return nil
+}
+
+func (d *s3Destination) UploadDataCaptureFile(ctx context.Context, f *data.CaptureFile, key string) (uint64, error) {
+	return withCaptureFileContents(f, func(file *os.File) (uint64, error) {
+		return d.put(ctx, file, key)
+	})
+}
+
+func (d *s3Destination) UploadArbitraryFile(ctx context.Context, f *os.File, key string) (uint64, error) {
+	return d.put(ctx, f, key)
+}
+
+// put uploads the whole of f to key. Responses which retrying can't change, such as access being denied,
+// are not retryable; server errors and throttling are.
+func (d *s3Destination) put(ctx context.Context, f *os.File, key string) (uint64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, io.NewSectionReader(f, 0, info.Size())); err != nil {
+		return 0, err
+	}
+
+	objectKey := key
+	if d.prefix != "" {
+		objectKey = d.prefix + "/" + key
+	}
+	// the body is a section reader rather than f so that the client doesn't close f.
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut,
+		d.endpoint+"/"+s3EscapePath(d.bucket)+"/"+s3EscapePath(objectKey), io.NewSectionReader(f, 0, info.Size()))
+	if err != nil {
+		return 0, errors.Wrap(errNotRetryable, err.Error())
+	}
+	req.ContentLength = info.Size()
+	if len(d.tags) > 0 {
+		req.Header.Set("X-Amz-Meta-Tags", strings.Join(d.tags, ","))
+	}
+	d.sign(req, hex.EncodeToString(hash.Sum(nil)))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		//nolint:errcheck
+		resp.Body.Close()
+	}()
+	if resp.StatusCode/100 == 2 {
+		return uint64(info.Size()), nil
+	}
+	//nolint:errcheck
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, s3ErrorBodyLimit))
+	err = errors.Errorf("PUT %s responded %s: %s", objectKey, resp.Status, strings.TrimSpace(string(body)))
+	if resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, errors.Wrap(errNotRetryable, err.Error())
+	}
+	return 0, err
+}
+
+// emptyPayloadHash is the hex encoded SHA-256 of an empty payload.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// sign adds the headers and AWS Signature Version 4 Authorization header S3 requires to req, signing
+// its host and every header already set on it.
+func (d *s3Destination) sign(req *http.Request, payloadHash string) {
+	now := d.clock.Now().UTC()
+	req.Header.Set("X-Amz-Date", now.Format(amzDateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	req.Header.Set("Authorization", signV4(req, payloadHash, d.accessKeyID, d.secretAccessKey, d.region, "s3", now))
+}
+
+// signV4 returns the AWS Signature Version 4 Authorization header of req, whose X-Amz-Date header must
+// already be set to now.
+func signV4(req *http.Request, payloadHash, accessKeyID, secretAccessKey, region, service string, now time.Time) string {
+	headers := map[string]string{"host": req.Host}
+	if req.Host == "" {
+		headers["host"] = req.URL.Host
+	}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "authorization" {
+			continue
+		}
+		trimmed := make([]string, 0, len(values))
+		for _, v := range values {
+			trimmed = append(trimmed, strings.Join(strings.Fields(v), " "))
+		}
+		headers[name] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	date := now.Format("20060102")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		now.Format(amzDateFormat),
+		scope,
+		hex.EncodeToString(canonicalRequestHash[:]),
+	}, "\n")
+
+	key := []byte("AWS4" + secretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	return fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKeyID, scope, signedHeaders, signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	//nolint:errcheck
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3EscapePath URI encodes every byte of p but the unreserved characters and slashes, as S3 signatures
+// require.
+func s3EscapePath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
@@ -18,7 +18,6 @@ import (
 	goutils "go.viam.com/utils"
 	"go.viam.com/utils/rpc"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/connectivity"
 
 	"go.viam.com/rdk/data"
 	"go.viam.com/rdk/internal/cloud"
@@ -32,7 +31,7 @@ var CheckDeleteExcessFilesInterval = 30 * time.Second
 
 const (
 	// FailedDir is a subdirectory of the capture directory that holds any files that could not be synced.
-	FailedDir = "failed"
+	FailedDir = data.FailedDir
 	// grpcConnectionTimeout defines the timeout for getting a connection with app.viam.com.
 	grpcConnectionTimeout = 10 * time.Second
 	// durationBetweenAcquireConnection defines how long to wait after a call to cloud.AcquireConnection fails
@@ -66,6 +65,7 @@ type Sync struct {
 
 	configMu sync.Mutex
 	config   Config
+	dest     Destination
 
 	configCtx        context.Context
 	configCancelFunc func()
@@ -146,15 +146,22 @@ func (s *Sync) Reconfigure(_ context.Context, config Config, cloudConnSvc cloud.
 	// wait for workers to stop
 	s.workersWg.Wait()
 
+	dest, err := s.newDestination(config)
+	if err != nil {
+		s.logger.Errorw("unable to initialize sync destination; nothing will be synced until it is fixed", "error", err)
+		dest = unavailableDestination{err: err}
+	}
+
 	// update config
 	s.configMu.Lock()
 	s.config = config
+	s.dest = dest
 	s.configMu.Unlock()
 	// reset config context
 	s.configCtx, s.configCancelFunc = context.WithCancel(context.Background())
 
 	// start workers
-	s.startWorkers(config)
+	s.startWorkers(config, dest)
 	if config.schedulerEnabled() {
 		// time.Duration loses precision at low floating point values, so turn intervalMins to milliseconds.
 		intervalMillis := 60000.0 * config.SyncIntervalMins
@@ -164,7 +171,7 @@ func (s *Sync) Reconfigure(_ context.Context, config Config, cloudConnSvc cloud.
 		tkr := s.clock.Ticker(interval)
 		s.ScheduledTicker = tkr
 		s.Scheduler = goutils.NewBackgroundStoppableWorkers(func(ctx context.Context) {
-			s.runScheduler(ctx, tkr, config)
+			s.runScheduler(ctx, tkr, config, dest)
 		})
 	} else {
 		s.logger.Info("Sync Disabled")
@@ -178,8 +185,7 @@ func (s *Sync) Reconfigure(_ context.Context, config Config, cloudConnSvc cloud.
 			deleteExcessFilesOnSchedule(
 				ctx,
 				s.fileTracker,
-				config.CaptureDir,
-				config.DeleteEveryNthWhenDiskFull,
+				config,
 				s.clock,
 				s.logger,
 			)
@@ -211,14 +217,18 @@ func (s *Sync) CloudConnReady() chan struct{} {
 // If automated sync is also enabled, calling Sync will upload the files,
 // regardless of whether or not is the scheduled time.
 func (s *Sync) Sync(ctx context.Context, _ map[string]interface{}) error {
-	select {
-	case <-s.cloudConn.ready:
-	default:
-		return errors.New("not connected to the cloud")
-	}
 	s.configMu.Lock()
 	config := s.config
+	dest := s.dest
 	s.configMu.Unlock()
+	if dest == nil {
+		return errors.New("not connected to the cloud")
+	}
+	select {
+	case <-dest.Ready():
+	default:
+		return errors.Errorf("not connected to %s", dest)
+	}
 	return s.walkDirsAndSendFilesToSync(ctx, config)
 }
 
@@ -310,17 +320,17 @@ func newCloudConn(
 
 // BEGIN sync workers
 // Assumed to be called after reconfigure is called.
-func (s *Sync) startWorkers(config Config) {
+func (s *Sync) startWorkers(config Config, dest Destination) {
 	numThreads := config.MaximumNumSyncThreads
 	s.MaxSyncThreads = numThreads
 	s.logger.Infof("starting sync worker pool of size: %d", numThreads)
 	for i := 0; i < numThreads; i++ {
 		s.workersWg.Add(1)
-		goutils.ManagedGo(func() { s.runWorker(config) }, s.workersWg.Done)
+		goutils.ManagedGo(func() { s.runWorker(config, dest) }, s.workersWg.Done)
 	}
 }
 
-func (s *Sync) runWorker(config Config) {
+func (s *Sync) runWorker(config Config, dest Destination) {
 	for {
 		if s.configCtx.Err() != nil {
 			return
@@ -329,12 +339,12 @@ func (s *Sync) runWorker(config Config) {
 		case <-s.configCtx.Done():
 			return
 		case path := <-s.filesToSync:
-			s.syncFile(config, path)
+			s.syncFile(config, dest, path)
 		}
 	}
 }
 
-func (s *Sync) syncFile(config Config, filePath string) {
+func (s *Sync) syncFile(config Config, dest Destination, filePath string) {
 	// don't sync in progress files
 	if filepath.Ext(filePath) == data.InProgressCaptureFileExt {
 		s.logger.Warn("ignoreing request to sync in progress capture file: %s", filePath)
@@ -360,13 +370,13 @@ func (s *Sync) syncFile(config Config, filePath string) {
 	}
 
 	if data.IsDataCaptureFile(f) {
-		s.syncDataCaptureFile(f, config.CaptureDir, s.logger)
+		s.syncDataCaptureFile(f, config.CaptureDir, dest, destinationKey(config, filePath), s.logger)
 	} else {
-		s.syncArbitraryFile(f, config.Tags, config.FileLastModifiedMillis, s.logger)
+		s.syncArbitraryFile(f, dest, destinationKey(config, filePath), s.logger)
 	}
 }
 
-func (s *Sync) syncDataCaptureFile(f *os.File, captureDir string, logger logging.Logger) {
+func (s *Sync) syncDataCaptureFile(f *os.File, captureDir string, dest Destination, key string, logger logging.Logger) {
 	captureFile, err := data.ReadCaptureFile(f)
 	// if you can't read the capture file's metadata field, close & move it to the failed directory
 	if err != nil {
@@ -388,7 +398,7 @@ func (s *Sync) syncDataCaptureFile(f *os.File, captureDir string, logger logging
 	retry := newExponentialRetry(s.configCtx, s.clock, s.logger, f.Name(), func(ctx context.Context) (uint64, error) {
 		msg := "error uploading data capture file %s, size: %s, md: %s"
 		errMetadata := fmt.Sprintf(msg, captureFile.GetPath(), data.FormatBytesI64(captureFile.Size()), captureFile.ReadMetadata())
-		bytesUploaded, err := uploadDataCaptureFile(ctx, captureFile, s.cloudConn, logger)
+		bytesUploaded, err := dest.UploadDataCaptureFile(ctx, captureFile, key)
 		if err != nil {
 			return 0, errors.Wrap(err, errMetadata)
 		}
@@ -409,6 +419,17 @@ func (s *Sync) syncDataCaptureFile(f *os.File, captureDir string, logger logging
 			return
 		}
 
+		// readings which fail their checksum are dropped, and the rest are synced once recovered
+		if errors.Is(err, data.ErrCorruptCaptureFile) {
+			recovered, corrupted, recoverErr := data.RecoverCaptureFile(captureFile.GetPath())
+			if recoverErr == nil {
+				logger.Warnw("recovered corrupted data capture file", "file", captureFile.GetPath(),
+					"readings", recovered, "corrupted_readings", corrupted)
+				return
+			}
+			logger.Errorw("failed to recover corrupted data capture file", "file", captureFile.GetPath(), "error", recoverErr)
+		}
+
 		// otherwise we hit a terminal error, and we should move the file to the failed directory
 		if err := moveFailedData(captureFile.GetPath(), captureDir, err, logger); err != nil {
 			logger.Error(err)
@@ -434,10 +455,10 @@ func (s *Sync) syncDataCaptureFile(f *os.File, captureDir string, logger logging
 	}
 }
 
-func (s *Sync) syncArbitraryFile(f *os.File, tags []string, fileLastModifiedMillis int, logger logging.Logger) {
+func (s *Sync) syncArbitraryFile(f *os.File, dest Destination, key string, logger logging.Logger) {
 	retry := newExponentialRetry(s.configCtx, s.clock, s.logger, f.Name(), func(ctx context.Context) (uint64, error) {
 		errMetadata := fmt.Sprintf("error uploading arbitrary file %s", f.Name())
-		bytesUploaded, err := uploadArbitraryFile(ctx, f, s.cloudConn, tags, fileLastModifiedMillis, s.clock, logger)
+		bytesUploaded, err := dest.UploadArbitraryFile(ctx, f, key)
 		if err != nil {
 			return 0, errors.Wrap(err, errMetadata)
 		}
@@ -501,7 +522,7 @@ func moveFailedData(path, parentDir string, cause error, logger logging.Logger)
 // END sync workers
 
 // BEGIN sync scheudler.
-func (s *Sync) runScheduler(ctx context.Context, tkr *clock.Ticker, config Config) {
+func (s *Sync) runScheduler(ctx context.Context, tkr *clock.Ticker, config Config, dest Destination) {
 	defer tkr.Stop()
 	var readyLogged bool
 
@@ -510,12 +531,12 @@ func (s *Sync) runScheduler(ctx context.Context, tkr *clock.Ticker, config Confi
 			return
 		}
 
-		// wait for the cloud connection to be ready
+		// wait for the destination to be ready
 		// or the scheduler to be cancelled
 		select {
 		case <-ctx.Done():
 			return
-		case <-s.cloudConn.ready:
+		case <-dest.Ready():
 			if !readyLogged {
 				readyLogged = true
 			}
@@ -526,16 +547,13 @@ func (s *Sync) runScheduler(ctx context.Context, tkr *clock.Ticker, config Confi
 			return
 		case <-tkr.C:
 			shouldSync := readyToSyncDirectories(ctx, config, s.logger)
-			state := s.cloudConn.connectivityStateEnabledConn.GetState()
-			online := state == connectivity.Ready
-			if !online {
-				s.logger.Infof("data manager: NOT syncing data to the cloud as it's cloud connection is in state: %s"+
-					"; waiting for it to be in state: %s", state, connectivity.Ready)
+			if err := dest.Online(); err != nil {
+				s.logger.Infof("data manager: NOT syncing data to %s as %s", dest, err)
 				continue
 			}
 
 			if !shouldSync {
-				s.logger.Info("data manager: NOT syncing data to the cloud as it's selective sync sensor is not ready to sync")
+				s.logger.Infof("data manager: NOT syncing data to %s as it's selective sync sensor is not ready to sync", dest)
 				continue
 			}
 
@@ -0,0 +1,67 @@
+package builtin
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	datasync "go.viam.com/rdk/services/datamanager/builtin/sync"
+)
+
+// SyncDestinationConfig describes where sync uploads files to instead of the cloud, such as a mounted NAS
+// directory or an on-prem S3 compatible bucket.
+type SyncDestinationConfig struct {
+	// Type is one of cloud (the default), directory or s3.
+	Type string `json:"type"`
+	// Path is the directory files are copied to by the directory destination.
+	Path string `json:"path,omitempty"`
+	// The S3 compatible service, such as http://minio.local:9000, bucket and key prefix files are uploaded to.
+	Endpoint        string `json:"endpoint,omitempty"`
+	Bucket          string `json:"bucket,omitempty"`
+	Prefix          string `json:"prefix,omitempty"`
+	Region          string `json:"region,omitempty"`
+	AccessKeyID     string `json:"access_key_id,omitempty"`
+	SecretAccessKey string `json:"secret_access_key,omitempty"`
+}
+
+// Validate ensures the destination is complete for its type.
+func (dc *SyncDestinationConfig) Validate() error {
+	switch dc.Type {
+	case "", datasync.DestinationTypeCloud:
+	case datasync.DestinationTypeDirectory:
+		if dc.Path == "" {
+			return errors.New("sync_destination of type directory requires path")
+		}
+	case datasync.DestinationTypeS3:
+		if dc.Endpoint == "" || dc.Bucket == "" {
+			return errors.New("sync_destination of type s3 requires endpoint and bucket")
+		}
+		if dc.AccessKeyID == "" || dc.SecretAccessKey == "" {
+			return errors.New("sync_destination of type s3 requires access_key_id and secret_access_key")
+		}
+		u, err := url.Parse(dc.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("sync_destination endpoint must be an http or https URL, got %q", dc.Endpoint)
+		}
+	default:
+		return fmt.Errorf("sync_destination type must be one of %s, %s or %s, got %q",
+			datasync.DestinationTypeCloud, datasync.DestinationTypeDirectory, datasync.DestinationTypeS3, dc.Type)
+	}
+	return nil
+}
+
+func (dc *SyncDestinationConfig) destinationConfig() datasync.DestinationConfig {
+	if dc == nil {
+		return datasync.DestinationConfig{}
+	}
+	return datasync.DestinationConfig{
+		Type:            dc.Type,
+		Path:            dc.Path,
+		Endpoint:        dc.Endpoint,
+		Bucket:          dc.Bucket,
+		Prefix:          dc.Prefix,
+		Region:          dc.Region,
+		AccessKeyID:     dc.AccessKeyID,
+		SecretAccessKey: dc.SecretAccessKey,
+	}
+}
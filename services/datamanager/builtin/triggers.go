@@ -0,0 +1,193 @@
+package builtin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/datamanager/builtin/capture"
+	"go.viam.com/rdk/services/vision"
+)
+
+// The conditions a capture trigger can fire on.
+const (
+	// triggerTypeSensorThreshold fires when a numeric reading of a sensor crosses a threshold.
+	triggerTypeSensorThreshold = "sensor_threshold"
+	// triggerTypeVisionDetection fires when a vision service detects a label in the images of a camera.
+	triggerTypeVisionDetection = "vision_detection"
+	// triggerTypeDoCommand only fires when requested with the trigger DoCommand.
+	triggerTypeDoCommand = "do_command"
+
+	// triggerCommand is the DoCommand key whose value is the name of the trigger to fire.
+	triggerCommand = "trigger"
+
+	defaultTriggerPollFrequencyHz = 1.
+)
+
+// TriggerConfig describes a capture trigger: a condition which records a window of data from the
+// collectors which list it in their `triggers`, tagged with its name.
+type TriggerConfig struct {
+	Name            string  `json:"name"`
+	Type            string  `json:"type"`
+	PreRollSecs     float64 `json:"pre_roll_secs"`
+	PostRollSecs    float64 `json:"post_roll_secs"`
+	PollFrequencyHz float64 `json:"poll_frequency_hz,omitempty"`
+
+	// The trigger fires while the reading ReadingKey of Sensor is above Above or below Below.
+	Sensor     string   `json:"sensor,omitempty"`
+	ReadingKey string   `json:"reading_key,omitempty"`
+	Above      *float64 `json:"above,omitempty"`
+	Below      *float64 `json:"below,omitempty"`
+
+	// The trigger fires while VisionService detects Label, or anything if unset, in the images of Camera
+	// with at least MinConfidence.
+	VisionService string  `json:"vision_service,omitempty"`
+	Camera        string  `json:"camera,omitempty"`
+	Label         string  `json:"label,omitempty"`
+	MinConfidence float64 `json:"min_confidence,omitempty"`
+}
+
+// Validate ensures the trigger is complete for its type.
+func (tc *TriggerConfig) Validate() error {
+	if tc.Name == "" {
+		return errors.New("capture trigger name can't be empty")
+	}
+	if tc.PreRollSecs < 0 || tc.PostRollSecs < 0 {
+		return fmt.Errorf("capture trigger %s pre_roll_secs and post_roll_secs can't be negative", tc.Name)
+	}
+	if tc.PollFrequencyHz < 0 {
+		return fmt.Errorf("capture trigger %s poll_frequency_hz can't be negative", tc.Name)
+	}
+	switch tc.Type {
+	case triggerTypeSensorThreshold:
+		if tc.Sensor == "" || tc.ReadingKey == "" {
+			return fmt.Errorf("capture trigger %s of type %s requires sensor and reading_key", tc.Name, tc.Type)
+		}
+		if tc.Above == nil && tc.Below == nil {
+			return fmt.Errorf("capture trigger %s of type %s requires above or below", tc.Name, tc.Type)
+		}
+	case triggerTypeVisionDetection:
+		if tc.VisionService == "" || tc.Camera == "" {
+			return fmt.Errorf("capture trigger %s of type %s requires vision_service and camera", tc.Name, tc.Type)
+		}
+		if tc.MinConfidence < 0 || tc.MinConfidence > 1 {
+			return fmt.Errorf("capture trigger %s min_confidence must be between 0 and 1", tc.Name)
+		}
+	case triggerTypeDoCommand:
+	default:
+		return fmt.Errorf("capture trigger %s type must be one of %s, %s or %s, got %q",
+			tc.Name, triggerTypeSensorThreshold, triggerTypeVisionDetection, triggerTypeDoCommand, tc.Type)
+	}
+	return nil
+}
+
+// captureTriggers builds the capture triggers from their configs. A trigger whose condition can't be built
+// is logged and can then only be fired with the trigger DoCommand, so that the collectors it gates keep
+// working.
+func captureTriggers(configs []TriggerConfig, deps resource.Dependencies, logger logging.Logger) []capture.Trigger {
+	triggers := make([]capture.Trigger, 0, len(configs))
+	for _, tc := range configs {
+		pollFrequencyHz := tc.PollFrequencyHz
+		if pollFrequencyHz == 0 {
+			pollFrequencyHz = defaultTriggerPollFrequencyHz
+		}
+		condition, err := triggerCondition(tc, deps)
+		if err != nil {
+			logger.Errorw("unable to initialize capture trigger condition; it will only fire on request until fixed",
+				"trigger", tc.Name, "error", err.Error())
+		}
+		triggers = append(triggers, capture.Trigger{
+			Name:         tc.Name,
+			PreRoll:      time.Duration(tc.PreRollSecs * float64(time.Second)),
+			PostRoll:     time.Duration(tc.PostRollSecs * float64(time.Second)),
+			PollInterval: time.Duration(float64(time.Second) / pollFrequencyHz),
+			Condition:    condition,
+		})
+	}
+	return triggers
+}
+
+func triggerCondition(tc TriggerConfig, deps resource.Dependencies) (func(context.Context) (bool, error), error) {
+	switch tc.Type {
+	case triggerTypeSensorThreshold:
+		s, err := readingsFromDeps(deps, tc.Sensor)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context) (bool, error) {
+			readings, err := s.Readings(ctx, nil)
+			if err != nil {
+				return false, err
+			}
+			value, err := numericReading(readings, tc.ReadingKey)
+			if err != nil {
+				return false, err
+			}
+			return (tc.Above != nil && value > *tc.Above) || (tc.Below != nil && value < *tc.Below), nil
+		}, nil
+	case triggerTypeVisionDetection:
+		visionSvc, err := vision.FromDependencies(deps, tc.VisionService)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context) (bool, error) {
+			detections, err := visionSvc.DetectionsFromCamera(ctx, tc.Camera, nil)
+			if err != nil {
+				return false, err
+			}
+			for _, d := range detections {
+				if (tc.Label == "" || d.Label() == tc.Label) && d.Score() >= tc.MinConfidence {
+					return true, nil
+				}
+			}
+			return false, nil
+		}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// readingsFromDeps returns the dependency with the given name which has readings, whatever its API.
+func readingsFromDeps(deps resource.Dependencies, name string) (resource.Sensor, error) {
+	for n, res := range deps {
+		if n.ShortName() != name {
+			continue
+		}
+		if s, ok := res.(resource.Sensor); ok {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("no resource with readings named %q", name)
+}
+
+func numericReading(readings map[string]interface{}, key string) (float64, error) {
+	switch v := readings[key].(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint32:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case nil:
+		return 0, fmt.Errorf("readings have no %q", key)
+	default:
+		return 0, fmt.Errorf("reading %q is a %T, not a number", key, v)
+	}
+}
@@ -0,0 +1,228 @@
+package builtin
+
+import (
+	"context"
+	"image"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/components/arm"
+	"go.viam.com/rdk/components/sensor"
+	"go.viam.com/rdk/data"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/datamanager"
+	datasync "go.viam.com/rdk/services/datamanager/builtin/sync"
+	"go.viam.com/rdk/services/vision"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/testutils/inject"
+	"go.viam.com/rdk/vision/objectdetection"
+)
+
+func TestTriggerCondition(t *testing.T) {
+	ctx := context.Background()
+	temperature := 20.
+	thermometer := inject.NewSensor("thermometer")
+	thermometer.ReadingsFunc = func(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
+		return map[string]interface{}{"temperature": temperature, "unit": "celsius"}, nil
+	}
+	var detections []objectdetection.Detection
+	detector := inject.NewVisionService("detector")
+	detector.DetectionsFromCameraFunc = func(
+		ctx context.Context,
+		cameraName string,
+		extra map[string]interface{},
+	) ([]objectdetection.Detection, error) {
+		return detections, nil
+	}
+	deps := resource.Dependencies{
+		sensor.Named("thermometer"): thermometer,
+		vision.Named("detector"):    detector,
+	}
+
+	t.Run("sensor threshold", func(t *testing.T) {
+		above := 30.
+		condition, err := triggerCondition(TriggerConfig{
+			Name: "hot", Type: triggerTypeSensorThreshold, Sensor: "thermometer", ReadingKey: "temperature", Above: &above,
+		}, deps)
+		test.That(t, err, test.ShouldBeNil)
+		met, err := condition(ctx)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, met, test.ShouldBeFalse)
+
+		temperature = 35
+		met, err = condition(ctx)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, met, test.ShouldBeTrue)
+
+		condition, err = triggerCondition(TriggerConfig{
+			Name: "hot", Type: triggerTypeSensorThreshold, Sensor: "thermometer", ReadingKey: "unit", Above: &above,
+		}, deps)
+		test.That(t, err, test.ShouldBeNil)
+		_, err = condition(ctx)
+		test.That(t, err, test.ShouldBeError, `reading "unit" is a string, not a number`)
+
+		_, err = triggerCondition(TriggerConfig{
+			Name: "hot", Type: triggerTypeSensorThreshold, Sensor: "barometer", ReadingKey: "pressure", Above: &above,
+		}, deps)
+		test.That(t, err, test.ShouldBeError, `no resource with readings named "barometer"`)
+	})
+
+	t.Run("vision detection", func(t *testing.T) {
+		condition, err := triggerCondition(TriggerConfig{
+			Name: "person", Type: triggerTypeVisionDetection, VisionService: "detector", Camera: "cam", Label: "person",
+			MinConfidence: 0.5,
+		}, deps)
+		test.That(t, err, test.ShouldBeNil)
+
+		box := image.Rect(0, 0, 10, 10)
+		for _, tc := range []struct {
+			detection objectdetection.Detection
+			met       bool
+		}{
+			{objectdetection.NewDetection(box, 0.9, "dog"), false},
+			{objectdetection.NewDetection(box, 0.3, "person"), false},
+			{objectdetection.NewDetection(box, 0.7, "person"), true},
+		} {
+			detections = []objectdetection.Detection{tc.detection}
+			met, err := condition(ctx)
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, met, test.ShouldEqual, tc.met)
+		}
+	})
+}
+
+func TestCaptureTriggers(t *testing.T) {
+	ctx := context.Background()
+	logger := logging.NewTestLogger(t)
+	captureDir := t.TempDir()
+
+	r := setupRobot(nil, map[resource.Name]resource.Resource{
+		arm.Named("arm1"): &inject.Arm{
+			EndPositionFunc: func(ctx context.Context, extra map[string]interface{}) (spatialmath.Pose, error) {
+				return spatialmath.NewZeroPose(), nil
+			},
+		},
+	})
+	config, deps := setupConfig(t, r, enabledTabularCollectorConfigPath)
+	for _, assocConf := range config.AssociatedAttributes {
+		methods := assocConf.(*datamanager.AssociatedConfig).CaptureMethods
+		for i := range methods {
+			methods[i].Triggers = []string{"bump"}
+		}
+	}
+	c := config.ConvertedAttributes.(*Config)
+	c.CaptureDir = captureDir
+	c.ScheduledSyncDisabled = true
+	c.CaptureTriggers = []TriggerConfig{{Name: "bump", Type: triggerTypeDoCommand, PreRollSecs: 0.1, PostRollSecs: 0.2}}
+
+	b, err := New(ctx, deps, config, datasync.NoOpCloudClientConstructor, connToConnectivityStateError, logger)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() { test.That(t, b.Close(ctx), test.ShouldBeNil) }()
+
+	// readings are only kept in memory until the trigger fires.
+	time.Sleep(300 * time.Millisecond)
+	test.That(t, getAllFileInfos(captureDir), test.ShouldBeEmpty)
+
+	_, err = b.DoCommand(ctx, map[string]interface{}{"trigger": "unknown"})
+	test.That(t, err, test.ShouldBeError, `no capture trigger named "unknown"`)
+	_, err = b.DoCommand(ctx, map[string]interface{}{"sync": true})
+	test.That(t, err, test.ShouldBeError, resource.ErrDoUnimplemented)
+
+	fired := time.Now()
+	resp, err := b.DoCommand(ctx, map[string]interface{}{"trigger": "bump"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp, test.ShouldResemble, map[string]interface{}{"collectors": 1})
+
+	// once the post-roll has elapsed, the window is written to a completed capture file.
+	time.Sleep(400 * time.Millisecond)
+	waitForCaptureFilesToExceedNFiles(captureDir, 0, logger)
+	files := getAllFilePaths(captureDir)
+	test.That(t, len(files), test.ShouldEqual, 1)
+	test.That(t, filepath.Ext(files[0]), test.ShouldEqual, data.CompletedCaptureFileExt)
+
+	f, err := os.Open(files[0])
+	test.That(t, err, test.ShouldBeNil)
+	defer f.Close()
+	captureFile, err := data.ReadCaptureFile(f)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, captureFile.ReadMetadata().GetTags(), test.ShouldResemble, []string{"bump"})
+
+	sd, err := data.SensorDataFromCaptureFilePath(files[0])
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(sd), test.ShouldBeGreaterThan, 0)
+	for _, d := range sd {
+		requested := d.GetMetadata().GetTimeRequested().AsTime()
+		test.That(t, requested, test.ShouldHappenOnOrAfter, fired.Add(-150*time.Millisecond))
+		test.That(t, requested, test.ShouldHappenOnOrBefore, fired.Add(250*time.Millisecond))
+	}
+}
+
+func TestSensorThresholdCaptureTrigger(t *testing.T) {
+	ctx := context.Background()
+	logger := logging.NewTestLogger(t)
+	captureDir := t.TempDir()
+
+	r := setupRobot(nil, map[resource.Name]resource.Resource{
+		arm.Named("arm1"): &inject.Arm{
+			EndPositionFunc: func(ctx context.Context, extra map[string]interface{}) (spatialmath.Pose, error) {
+				return spatialmath.NewZeroPose(), nil
+			},
+		},
+	})
+	config, deps := setupConfig(t, r, enabledTabularCollectorConfigPath)
+	for _, assocConf := range config.AssociatedAttributes {
+		methods := assocConf.(*datamanager.AssociatedConfig).CaptureMethods
+		for i := range methods {
+			methods[i].Triggers = []string{"hot"}
+		}
+	}
+	above := 30.
+	c := config.ConvertedAttributes.(*Config)
+	c.CaptureDir = captureDir
+	c.ScheduledSyncDisabled = true
+	c.CaptureTriggers = []TriggerConfig{{
+		Name: "hot", Type: triggerTypeSensorThreshold, Sensor: "thermometer", ReadingKey: "temperature", Above: &above,
+		PostRollSecs: 0.1, PollFrequencyHz: 20,
+	}}
+
+	// the thermometer is not collected from, so it is only in the dependencies because the trigger needs it.
+	validatedDeps, err := c.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, validatedDeps, test.ShouldContain, "thermometer")
+	var hot atomic.Bool
+	thermometer := inject.NewSensor("thermometer")
+	thermometer.ReadingsFunc = func(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
+		if hot.Load() {
+			return map[string]interface{}{"temperature": 35.}, nil
+		}
+		return map[string]interface{}{"temperature": 20.}, nil
+	}
+	deps[sensor.Named("thermometer")] = thermometer
+
+	b, err := New(ctx, deps, config, datasync.NoOpCloudClientConstructor, connToConnectivityStateError, logger)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() { test.That(t, b.Close(ctx), test.ShouldBeNil) }()
+
+	time.Sleep(300 * time.Millisecond)
+	test.That(t, getAllFileInfos(captureDir), test.ShouldBeEmpty)
+
+	// the trigger fires on its own once the reading crosses the threshold.
+	hot.Store(true)
+	time.Sleep(300 * time.Millisecond)
+	hot.Store(false)
+	waitForCaptureFilesToExceedNFiles(captureDir, 0, logger)
+	files := getAllFilePaths(captureDir)
+	test.That(t, len(files), test.ShouldBeGreaterThan, 0)
+	f, err := os.Open(files[0])
+	test.That(t, err, test.ShouldBeNil)
+	defer f.Close()
+	captureFile, err := data.ReadCaptureFile(f)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, captureFile.ReadMetadata().GetTags(), test.ShouldResemble, []string{"hot"})
+}
@@ -147,6 +147,9 @@ type DataCaptureConfig struct {
 	Disabled           bool              `json:"disabled"`
 	Tags               []string          `json:"tags,omitempty"`
 	CaptureDirectory   string            `json:"capture_directory"`
+	// Triggers are the names of the capture triggers of the data manager which gate this method. When set,
+	// readings are kept in memory and only written around the moments one of the triggers fires.
+	Triggers []string `json:"triggers,omitempty"`
 }
 
 // Equals checks if one capture config is equal to another.
@@ -159,7 +162,8 @@ func (c *DataCaptureConfig) Equals(other *DataCaptureConfig) bool {
 		c.Disabled == other.Disabled &&
 		slices.Compare(c.Tags, other.Tags) == 0 &&
 		reflect.DeepEqual(c.AdditionalParams, other.AdditionalParams) &&
-		c.CaptureDirectory == other.CaptureDirectory
+		c.CaptureDirectory == other.CaptureDirectory &&
+		slices.Compare(c.Triggers, other.Triggers) == 0
 }
 
 // ShouldSyncKey is a special key we use within a modular sensor to pass a boolean
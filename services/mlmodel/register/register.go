@@ -4,4 +4,5 @@ package register
 import (
 	// for ML model service models.
 	_ "go.viam.com/rdk/services/mlmodel"
+	_ "go.viam.com/rdk/services/mlmodel/subprocess"
 )
@@ -0,0 +1,178 @@
+// Package main is a tiny runner for the subprocess mlmodel, used in its tests. It doubles each float32 or
+// uint8 tensor it is given, returning the double of "input" as "output" and of any other tensor under its
+// own name. A tensor named "crash" makes it exit, and one named "hang" makes it never respond.
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+type tensor struct {
+	Name     string `json:"name"`
+	DataType string `json:"data_type"`
+	Shape    []int  `json:"shape"`
+	Size     int    `json:"size"`
+	SHM      string `json:"shm,omitempty"`
+}
+
+type header struct {
+	ID       uint64      `json:"id"`
+	Method   string      `json:"method,omitempty"`
+	Tensors  []tensor    `json:"tensors,omitempty"`
+	Metadata interface{} `json:"metadata,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+var metadata = map[string]interface{}{
+	"name":        "fake",
+	"type":        "doubler",
+	"description": "doubles its input",
+	"inputs":      []map[string]interface{}{{"name": "input", "data_type": "float32", "shape": []int{-1}}},
+	"outputs":     []map[string]interface{}{{"name": "output", "data_type": "float32", "shape": []int{-1}}},
+}
+
+func main() {
+	var r io.Reader = os.Stdin
+	var w io.Writer = os.Stdout
+	if path := os.Getenv("VIAM_MLMODEL_SOCKET"); path != "" {
+		l, err := net.Listen("unix", path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		conn, err := l.Accept()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		r, w = conn, conn
+	}
+	fmt.Fprintln(os.Stderr, "fake runner started")
+	if err := serve(bufio.NewReader(r), w); err != nil && err != io.EOF {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func serve(r io.Reader, w io.Writer) error {
+	threshold, err := strconv.Atoi(os.Getenv("VIAM_MLMODEL_SHM_THRESHOLD"))
+	if err != nil {
+		threshold = -1
+	}
+	for {
+		var length uint32
+		if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+			return err
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return err
+		}
+		var req header
+		if err := json.Unmarshal(data, &req); err != nil {
+			return err
+		}
+		resp := header{ID: req.ID}
+		var payload [][]byte
+		switch req.Method {
+		case "metadata":
+			resp.Metadata = metadata
+		case "infer":
+			for _, t := range req.Tensors {
+				var data []byte
+				if t.SHM != "" {
+					if data, err = os.ReadFile(t.SHM); err != nil {
+						return err
+					}
+				} else {
+					data = make([]byte, t.Size)
+					if _, err := io.ReadFull(r, data); err != nil {
+						return err
+					}
+				}
+				switch t.Name {
+				case "crash":
+					os.Exit(2)
+				case "hang":
+					time.Sleep(time.Hour)
+				case "input":
+					t.Name = "output"
+				}
+				if resp.Error == "" {
+					resp.Error = double(t.DataType, data)
+				}
+				t.SHM = ""
+				if threshold >= 0 && len(data) >= threshold {
+					f, err := os.CreateTemp(os.Getenv("VIAM_MLMODEL_SHM_DIR"), "fake-out-*")
+					if err != nil {
+						return err
+					}
+					if _, err := f.Write(data); err != nil {
+						return err
+					}
+					if err := f.Close(); err != nil {
+						return err
+					}
+					t.SHM = f.Name()
+				} else {
+					payload = append(payload, data)
+				}
+				resp.Tensors = append(resp.Tensors, t)
+			}
+			if resp.Error != "" {
+				resp.Tensors, payload = nil, nil
+			}
+		default:
+			resp.Error = fmt.Sprintf("unknown method %q", req.Method)
+		}
+		if err := write(w, &resp, payload); err != nil {
+			return err
+		}
+	}
+}
+
+// double doubles the elements of data in place, returning why it could not.
+func double(dataType string, data []byte) string {
+	switch dataType {
+	case "uint8":
+		for i := range data {
+			data[i] *= 2
+		}
+	case "float32":
+		for i := 0; i+4 <= len(data); i += 4 {
+			v := math.Float32frombits(binary.LittleEndian.Uint32(data[i:]))
+			binary.LittleEndian.PutUint32(data[i:], math.Float32bits(2*v))
+		}
+	default:
+		return fmt.Sprintf("cannot double %s tensors", dataType)
+	}
+	return ""
+}
+
+func write(w io.Writer, h *header, payload [][]byte) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(data))); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	for _, p := range payload {
+		if _, err := w.Write(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,343 @@
+package subprocess
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"gorgonia.org/tensor"
+
+	"go.viam.com/rdk/ml"
+	"go.viam.com/rdk/services/mlmodel"
+)
+
+// The protocol between the service and a runner is a sequence of frames in each direction, each of which
+// is a request from the service answered by one response from the runner, in order. A frame is
+//
+//	header length   4 bytes, unsigned little-endian
+//	header          a JSON object of that length
+//	payload         the data of the inline tensors of the header, one after the other in the order they
+//	                are listed, with no padding
+//
+// A request header is one of
+//
+//	{"id": 1, "method": "metadata"}
+//	{"id": 2, "method": "infer", "tensors": [<tensor>, ...]}
+//
+// and the response to it has the same id and either "metadata", "tensors" or "error":
+//
+//	{"id": 1, "metadata": {"name": "...", "type": "...", "description": "...",
+//	  "inputs": [<tensor info>, ...], "outputs": [<tensor info>, ...]}}
+//	{"id": 2, "tensors": [<tensor>, ...]}
+//	{"id": 2, "error": "why inference failed"}
+//
+// where a tensor info is {"name", "description", "data_type", "shape", "extra"} like mlmodel.TensorInfo, and
+// a tensor is
+//
+//	{"name": "image", "data_type": "uint8", "shape": [1, 320, 320, 3], "size": 307200}
+//
+// size is the number of bytes of its data, which is its elements in row-major order, each in little-endian
+// byte order. A tensor with "shm" set to the path of a file has its data in that file rather than the
+// payload, which is how large tensors are passed through shared memory. The service writes input tensors
+// larger than the threshold it is configured with to files in its shared memory directory, and removes
+// them once the response is read. Runners may do the same for output tensors, which the service removes
+// once it has read them. Both are passed to the runner in the environment:
+//
+//	VIAM_MLMODEL_SHM_DIR        the directory to write shared memory files to, on a tmpfs if there is one
+//	VIAM_MLMODEL_SHM_THRESHOLD  the size in bytes from which tensors should be passed in files, or -1 for never
+//
+// With the stdio transport requests are written to the stdin of the runner and responses read from its
+// stdout. With the unix transport the runner listens on the Unix socket at VIAM_MLMODEL_SOCKET and the
+// service connects to it. Anything the runner writes to stderr is logged. When the service closes stdin or
+// the connection, the runner should exit.
+
+const (
+	// EnvSocket is the environment variable with the path of the Unix socket a runner listens on.
+	EnvSocket = "VIAM_MLMODEL_SOCKET"
+	// EnvSharedMemoryDir is the environment variable with the directory for shared memory files.
+	EnvSharedMemoryDir = "VIAM_MLMODEL_SHM_DIR"
+	// EnvSharedMemoryThreshold is the environment variable with the size from which tensors are shared.
+	EnvSharedMemoryThreshold = "VIAM_MLMODEL_SHM_THRESHOLD"
+
+	methodMetadata = "metadata"
+	methodInfer    = "infer"
+
+	// maxHeaderBytes bounds the header of a frame, so that a runner writing something else to stdout fails
+	// fast rather than making the service allocate whatever its first bytes say.
+	maxHeaderBytes = 16 * 1024 * 1024
+)
+
+// header is the header of a frame in either direction.
+type header struct {
+	ID       uint64          `json:"id"`
+	Method   string          `json:"method,omitempty"`
+	Tensors  []tensorHeader  `json:"tensors,omitempty"`
+	Metadata *metadataHeader `json:"metadata,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// tensorHeader describes a tensor and where its data is.
+type tensorHeader struct {
+	Name     string `json:"name"`
+	DataType string `json:"data_type"`
+	Shape    []int  `json:"shape"`
+	Size     int    `json:"size"`
+	SHM      string `json:"shm,omitempty"`
+}
+
+// metadataHeader is the metadata of a model as runners report it.
+type metadataHeader struct {
+	Name        string             `json:"name"`
+	Type        string             `json:"type"`
+	Description string             `json:"description"`
+	Inputs      []tensorInfoHeader `json:"inputs"`
+	Outputs     []tensorInfoHeader `json:"outputs"`
+}
+
+type tensorInfoHeader struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	DataType    string                 `json:"data_type"`
+	Shape       []int                  `json:"shape"`
+	Extra       map[string]interface{} `json:"extra,omitempty"`
+}
+
+func (m *metadataHeader) toMetadata() mlmodel.MLMetadata {
+	infos := func(in []tensorInfoHeader) []mlmodel.TensorInfo {
+		out := make([]mlmodel.TensorInfo, 0, len(in))
+		for _, t := range in {
+			out = append(out, mlmodel.TensorInfo{
+				Name:        t.Name,
+				Description: t.Description,
+				DataType:    t.DataType,
+				Shape:       t.Shape,
+				Extra:       t.Extra,
+			})
+		}
+		return out
+	}
+	return mlmodel.MLMetadata{
+		ModelName:        m.Name,
+		ModelType:        m.Type,
+		ModelDescription: m.Description,
+		Inputs:           infos(m.Inputs),
+		Outputs:          infos(m.Outputs),
+	}
+}
+
+// writeFrame writes a frame with the header and the payload, which is the data of its inline tensors.
+func writeFrame(w io.Writer, h *header, payload [][]byte) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(data)))
+	if _, err := w.Write(length[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	for _, p := range payload {
+		if _, err := w.Write(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readFrame reads a frame, returning its header and the data of each of its inline tensors by index.
+func readFrame(r io.Reader) (*header, map[int][]byte, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, nil, err
+	}
+	n := binary.LittleEndian.Uint32(length[:])
+	if n > maxHeaderBytes {
+		return nil, nil, errors.Errorf("frame header of %d bytes is too large, is the runner writing something else?", n)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, nil, err
+	}
+	var h header
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, nil, errors.Wrap(err, "cannot parse frame header")
+	}
+	payload := map[int][]byte{}
+	for i, t := range h.Tensors {
+		if t.SHM != "" {
+			continue
+		}
+		if t.Size < 0 {
+			return nil, nil, errors.Errorf("tensor %q has a negative size", t.Name)
+		}
+		buf := make([]byte, t.Size)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, nil, err
+		}
+		payload[i] = buf
+	}
+	return &h, payload, nil
+}
+
+// encodeTensors returns the headers and inline data of the tensors, writing those of at least threshold
+// bytes to new files in shmDir, unless threshold is negative. The paths of the files written are returned
+// for the caller to remove, including when it fails.
+func encodeTensors(tensors ml.Tensors, shmDir string, threshold int) ([]tensorHeader, [][]byte, []string, error) {
+	headers := make([]tensorHeader, 0, len(tensors))
+	var payload [][]byte
+	var files []string
+	for name, t := range tensors {
+		data, dataType, err := tensorBytes(t)
+		if err != nil {
+			return nil, nil, files, errors.Wrapf(err, "tensor %q", name)
+		}
+		h := tensorHeader{Name: name, DataType: dataType, Shape: t.Shape(), Size: len(data)}
+		if threshold >= 0 && len(data) >= threshold {
+			f, err := os.CreateTemp(shmDir, "viam-mlmodel-in-*")
+			if err != nil {
+				return nil, nil, files, err
+			}
+			files = append(files, f.Name())
+			_, err = f.Write(data)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return nil, nil, files, err
+			}
+			h.SHM = f.Name()
+		} else {
+			payload = append(payload, data)
+		}
+		headers = append(headers, h)
+	}
+	return headers, payload, files, nil
+}
+
+// decodeTensors returns the tensors of a frame, reading those in shared memory files and removing the files
+// if they are in shmDir, which is where runners are told to put them.
+func decodeTensors(headers []tensorHeader, payload map[int][]byte, shmDir string) (ml.Tensors, error) {
+	out := ml.Tensors{}
+	for i, h := range headers {
+		data, ok := payload[i]
+		if h.SHM != "" {
+			var err error
+			data, err = os.ReadFile(filepath.Clean(h.SHM))
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot read shared memory of tensor %q", h.Name)
+			}
+			if filepath.Dir(filepath.Clean(h.SHM)) == filepath.Clean(shmDir) {
+				//nolint:errcheck,gosec
+				os.Remove(h.SHM)
+			}
+			ok = true
+		}
+		if !ok {
+			return nil, errors.Errorf("tensor %q has no data", h.Name)
+		}
+		t, err := newTensor(h, data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "tensor %q", h.Name)
+		}
+		out[h.Name] = t
+	}
+	return out, nil
+}
+
+// tensorBytes returns the data of the tensor in little-endian byte order, which is the order of the memory
+// of the tensor on all platforms the RDK supports, and the name of its data type.
+func tensorBytes(t *tensor.Dense) ([]byte, string, error) {
+	switch data := t.Data().(type) {
+	case []uint8:
+		return data, "uint8", nil
+	case []int8:
+		return sliceBytes(data), "int8", nil
+	case []uint16:
+		return sliceBytes(data), "uint16", nil
+	case []int16:
+		return sliceBytes(data), "int16", nil
+	case []uint32:
+		return sliceBytes(data), "uint32", nil
+	case []int32:
+		return sliceBytes(data), "int32", nil
+	case []uint64:
+		return sliceBytes(data), "uint64", nil
+	case []int64:
+		return sliceBytes(data), "int64", nil
+	case []float32:
+		return sliceBytes(data), "float32", nil
+	case []float64:
+		return sliceBytes(data), "float64", nil
+	default:
+		return nil, "", errors.Errorf("unsupported tensor data of type %T", data)
+	}
+}
+
+// newTensor returns a tensor of the shape and data type of the header with the data.
+func newTensor(h tensorHeader, data []byte) (*tensor.Dense, error) {
+	elements := 1
+	for _, d := range h.Shape {
+		if d < 0 {
+			return nil, errors.Errorf("invalid shape %v", h.Shape)
+		}
+		elements *= d
+	}
+	var backing interface{}
+	var size int
+	switch h.DataType {
+	case "uint8":
+		backing, size = append([]uint8(nil), data...), 1
+	case "int8":
+		backing, size = fromBytes[int8](data), 1
+	case "uint16":
+		backing, size = fromBytes[uint16](data), 2
+	case "int16":
+		backing, size = fromBytes[int16](data), 2
+	case "uint32":
+		backing, size = fromBytes[uint32](data), 4
+	case "int32":
+		backing, size = fromBytes[int32](data), 4
+	case "uint64":
+		backing, size = fromBytes[uint64](data), 8
+	case "int64":
+		backing, size = fromBytes[int64](data), 8
+	case "float32":
+		backing, size = fromBytes[float32](data), 4
+	case "float64":
+		backing, size = fromBytes[float64](data), 8
+	default:
+		return nil, errors.Errorf("unsupported data type %q", h.DataType)
+	}
+	if len(data) != elements*size {
+		return nil, fmt.Errorf("%d bytes of data do not fill a %s tensor of shape %v", len(data), h.DataType, h.Shape)
+	}
+	return tensor.New(tensor.WithShape(h.Shape...), tensor.WithBacking(backing)), nil
+}
+
+type number interface {
+	~int8 | ~uint16 | ~int16 | ~uint32 | ~int32 | ~uint64 | ~int64 | ~float32 | ~float64
+}
+
+// sliceBytes returns the memory of the slice as bytes.
+func sliceBytes[T number](s []T) []byte {
+	if len(s) == 0 {
+		return nil
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(&s[0])), len(s)*int(unsafe.Sizeof(s[0]))) //nolint:gosec
+}
+
+// fromBytes returns a copy of the data as a slice of T, with any bytes left over dropped.
+func fromBytes[T number](data []byte) []T {
+	var zero T
+	out := make([]T, len(data)/int(unsafe.Sizeof(zero)))
+	copy(sliceBytes(out), data)
+	return out
+}
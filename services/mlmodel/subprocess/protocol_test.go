@@ -0,0 +1,70 @@
+package subprocess
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"go.viam.com/test"
+	"gorgonia.org/tensor"
+
+	"go.viam.com/rdk/ml"
+)
+
+func TestProtocolRoundTrip(t *testing.T) {
+	tensors := ml.Tensors{
+		"floats": tensor.New(tensor.WithShape(2, 2), tensor.WithBacking([]float32{1, -2, 3.5, 4})),
+		"bytes":  tensor.New(tensor.WithShape(3), tensor.WithBacking([]uint8{1, 2, 255})),
+		"ints":   tensor.New(tensor.WithShape(2), tensor.WithBacking([]int64{-7, 1 << 40})),
+	}
+	shmDir := t.TempDir()
+	for _, threshold := range []int{-1, 12} {
+		headers, payload, files, err := encodeTensors(tensors, shmDir, threshold)
+		test.That(t, err, test.ShouldBeNil)
+		if threshold < 0 {
+			test.That(t, files, test.ShouldBeEmpty)
+		} else {
+			// the 16 bytes of floats and of ints are shared, the 3 bytes are not.
+			test.That(t, files, test.ShouldHaveLength, 2)
+		}
+
+		var buf bytes.Buffer
+		test.That(t, writeFrame(&buf, &header{ID: 3, Method: methodInfer, Tensors: headers}, payload), test.ShouldBeNil)
+		h, data, err := readFrame(&buf)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, h.ID, test.ShouldEqual, 3)
+		test.That(t, h.Method, test.ShouldEqual, methodInfer)
+		test.That(t, buf.Len(), test.ShouldEqual, 0)
+
+		out, err := decodeTensors(h.Tensors, data, shmDir)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, out, test.ShouldHaveLength, 3)
+		for name, want := range tensors {
+			test.That(t, out[name].Shape(), test.ShouldResemble, want.Shape())
+			test.That(t, out[name].Data(), test.ShouldResemble, want.Data())
+		}
+		// files in the shared memory directory are removed once read.
+		for _, f := range files {
+			_, err := os.Stat(f)
+			test.That(t, os.IsNotExist(err), test.ShouldBeTrue)
+		}
+	}
+}
+
+func TestDecodeTensorsErrors(t *testing.T) {
+	_, err := decodeTensors([]tensorHeader{{Name: "a", DataType: "float32", Shape: []int{2}, Size: 8}}, nil, "")
+	test.That(t, err, test.ShouldBeError)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "no data")
+
+	_, err = decodeTensors(
+		[]tensorHeader{{Name: "a", DataType: "float32", Shape: []int{3}, Size: 8}},
+		map[int][]byte{0: make([]byte, 8)}, "")
+	test.That(t, err, test.ShouldBeError)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "do not fill")
+
+	_, err = decodeTensors(
+		[]tensorHeader{{Name: "a", DataType: "complex64", Shape: []int{1}, Size: 8}},
+		map[int][]byte{0: make([]byte, 8)}, "")
+	test.That(t, err, test.ShouldBeError)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "unsupported")
+}
@@ -0,0 +1,269 @@
+package subprocess
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	goutils "go.viam.com/utils"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/services/mlmodel"
+)
+
+const (
+	// how long a runner is given to exit once its input is closed before it is killed.
+	runnerExitTimeout = 2 * time.Second
+	// how often the socket of a runner using the unix transport is tried while it starts.
+	socketDialInterval = 20 * time.Millisecond
+	// how long the output of a runner is still logged after it exits.
+	stderrDrainTimeout = 100 * time.Millisecond
+)
+
+// runner is a running runner process and the connection to it.
+type runner struct {
+	cmd    *exec.Cmd
+	logger logging.Logger
+
+	reader *bufio.Reader
+	writer *bufio.Writer
+	// closer closes the connection to the runner, which tells it to exit.
+	closer    io.Closer
+	socketDir string
+
+	// exited is closed once the process has exited, after which waitErr is how it exited.
+	exited  chan struct{}
+	waitErr error
+	// closing is set once the runner is being stopped, so its exit is expected.
+	closing atomic.Bool
+}
+
+// startRunner starts the runner of the config, connects to it and returns it along with the metadata of its
+// model, which is requested before startupTimeout to check that it speaks the protocol.
+func startRunner(
+	ctx context.Context,
+	conf *Config,
+	shmDir string,
+	threshold int,
+	logger logging.Logger,
+) (*runner, mlmodel.MLMetadata, error) {
+	//nolint:gosec
+	cmd := exec.Command(conf.ExecutablePath, conf.Args...)
+	cmd.Dir = conf.WorkingDir
+	cmd.Env = os.Environ()
+	for k, v := range conf.Env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+	}
+	cmd.Env = append(cmd.Env,
+		fmt.Sprintf("%s=%s", EnvSharedMemoryDir, shmDir),
+		fmt.Sprintf("%s=%d", EnvSharedMemoryThreshold, threshold),
+	)
+	r := &runner{cmd: cmd, logger: logger, exited: make(chan struct{})}
+
+	// the pipes are made here rather than by exec so that reading them is not raced by Wait closing them,
+	// and a runner which exits is seen as the end of its output.
+	var childEnds []*os.File
+	defer func() {
+		for _, f := range childEnds {
+			//nolint:errcheck,gosec
+			f.Close()
+		}
+	}()
+	stderrR, stderrW, err := os.Pipe()
+	if err != nil {
+		return nil, mlmodel.MLMetadata{}, err
+	}
+	childEnds = append(childEnds, stderrW)
+	cmd.Stderr = stderrW
+	var output *os.File
+	switch conf.Transport {
+	case TransportUnix:
+		r.socketDir, err = os.MkdirTemp("", "viam-mlmodel-")
+		if err != nil {
+			//nolint:errcheck,gosec
+			stderrR.Close()
+			return nil, mlmodel.MLMetadata{}, err
+		}
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", EnvSocket, filepath.Join(r.socketDir, "runner.sock")))
+		// anything written to stdout is logged like stderr.
+		cmd.Stdout = stderrW
+	default:
+		stdinR, stdinW, err := os.Pipe()
+		if err != nil {
+			//nolint:errcheck,gosec
+			stderrR.Close()
+			return nil, mlmodel.MLMetadata{}, err
+		}
+		stdoutR, stdoutW, err := os.Pipe()
+		if err != nil {
+			//nolint:errcheck,gosec
+			stderrR.Close()
+			//nolint:errcheck,gosec
+			stdinR.Close()
+			//nolint:errcheck,gosec
+			stdinW.Close()
+			return nil, mlmodel.MLMetadata{}, err
+		}
+		childEnds = append(childEnds, stdinR, stdoutW)
+		cmd.Stdin, cmd.Stdout = stdinR, stdoutW
+		r.reader, r.writer, r.closer = bufio.NewReader(stdoutR), bufio.NewWriter(stdinW), stdinW
+		output = stdoutR
+	}
+
+	if err := cmd.Start(); err != nil {
+		//nolint:errcheck,gosec
+		stderrR.Close()
+		if output != nil {
+			//nolint:errcheck,gosec
+			output.Close()
+			//nolint:errcheck,gosec
+			r.closer.Close()
+		}
+		r.removeSocketDir()
+		return nil, mlmodel.MLMetadata{}, errors.Wrapf(err, "cannot start runner %q", conf.ExecutablePath)
+	}
+	logged := make(chan struct{})
+	goutils.PanicCapturingGo(func() {
+		defer close(logged)
+		scanner := bufio.NewScanner(stderrR)
+		for scanner.Scan() {
+			logger.Infow("runner output", "line", scanner.Text())
+		}
+	})
+	goutils.PanicCapturingGo(func() {
+		r.waitErr = cmd.Wait()
+		if output != nil {
+			//nolint:errcheck,gosec
+			output.Close()
+		}
+		// children of the runner can keep its stderr open after it exits, so it is only read for a little
+		// longer to log the last of its output.
+		select {
+		case <-logged:
+		case <-time.After(stderrDrainTimeout):
+		}
+		//nolint:errcheck,gosec
+		stderrR.Close()
+		if !r.closing.Load() {
+			logger.Warnw("runner exited unexpectedly", "error", r.waitErr)
+		}
+		close(r.exited)
+	})
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(conf.StartupTimeoutSec*float64(time.Second)))
+	defer cancel()
+	if conf.Transport == TransportUnix {
+		if err := r.dial(ctx); err != nil {
+			r.stop()
+			return nil, mlmodel.MLMetadata{}, err
+		}
+	}
+	resp, _, err := r.request(ctx, &header{ID: 0, Method: methodMetadata}, nil)
+	if err == nil && resp.Error != "" {
+		err = errors.New(resp.Error)
+	}
+	if err == nil && resp.Metadata == nil {
+		err = errors.New("runner did not return metadata")
+	}
+	if err != nil {
+		r.stop()
+		return nil, mlmodel.MLMetadata{}, errors.Wrap(err, "cannot get metadata from runner")
+	}
+	return r, resp.Metadata.toMetadata(), nil
+}
+
+// dial connects to the socket of the runner, trying until it listens, exits or ctx is done.
+func (r *runner) dial(ctx context.Context) error {
+	path := filepath.Join(r.socketDir, "runner.sock")
+	var dialer net.Dialer
+	for {
+		conn, err := dialer.DialContext(ctx, "unix", path)
+		if err == nil {
+			r.reader, r.writer, r.closer = bufio.NewReader(conn), bufio.NewWriter(conn), conn
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(err, "runner did not listen on %s", path)
+		case <-r.exited:
+			return errors.Wrap(r.waitErr, "runner exited before listening")
+		case <-time.After(socketDialInterval):
+		}
+	}
+}
+
+// request sends a request to the runner and returns its response. If ctx is done first the runner is
+// killed, since there is no other way to interrupt it, which leaves it unusable.
+func (r *runner) request(ctx context.Context, req *header, payload [][]byte) (*header, map[int][]byte, error) {
+	stop := context.AfterFunc(ctx, r.kill)
+	defer stop()
+	resp, data, err := func() (*header, map[int][]byte, error) {
+		if err := writeFrame(r.writer, req, payload); err != nil {
+			return nil, nil, err
+		}
+		if err := r.writer.Flush(); err != nil {
+			return nil, nil, err
+		}
+		return readFrame(r.reader)
+	}()
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
+	}
+	if err != nil {
+		if r.hasExited() {
+			return nil, nil, errors.Wrapf(err, "runner exited: %v", r.waitErr)
+		}
+		return nil, nil, err
+	}
+	if resp.ID != req.ID {
+		return nil, nil, errors.Errorf("runner responded to request %d with the response to %d", req.ID, resp.ID)
+	}
+	return resp, data, nil
+}
+
+func (r *runner) hasExited() bool {
+	select {
+	case <-r.exited:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *runner) kill() {
+	r.closing.Store(true)
+	//nolint:errcheck,gosec
+	r.cmd.Process.Kill()
+}
+
+// stop asks the runner to exit by closing the connection to it, and kills it if it does not.
+func (r *runner) stop() {
+	r.closing.Store(true)
+	if r.closer != nil {
+		//nolint:errcheck,gosec
+		r.closer.Close()
+	}
+	select {
+	case <-r.exited:
+	case <-time.After(runnerExitTimeout):
+		r.logger.Warn("runner did not exit once its input was closed, killing it")
+		r.kill()
+		<-r.exited
+	}
+	r.removeSocketDir()
+}
+
+func (r *runner) removeSocketDir() {
+	if r.socketDir != "" {
+		//nolint:errcheck,gosec
+		os.RemoveAll(r.socketDir)
+	}
+}
@@ -0,0 +1,262 @@
+// Package subprocess implements an mlmodel service which runs models in a local executable, such as a
+// small wrapper around ONNX Runtime, llama.cpp or a Python script, so that any inference runtime can be used
+// without linking it into the RDK. The service starts the executable, the runner, and talks to it over its
+// stdin and stdout or over a Unix socket with the tensor protocol described in protocol.go. Large tensors
+// are passed through files in shared memory rather than copied through the connection. A runner which
+// crashes is restarted on the next request, waiting longer between restarts the more often it crashes.
+package subprocess
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/ml"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/mlmodel"
+)
+
+var model = resource.DefaultModelFamily.WithModel("subprocess")
+
+const (
+	// TransportStdio is the transport over the stdin and stdout of the runner.
+	TransportStdio = "stdio"
+	// TransportUnix is the transport over a Unix socket the runner listens on.
+	TransportUnix = "unix"
+
+	defaultSharedMemoryThreshold = 1 << 20
+	defaultSharedMemoryDir       = "/dev/shm"
+	defaultStartupTimeoutSec     = 30.
+
+	// restarts after the first are delayed by minRestartDelay, doubling with each crash in a row up to
+	// maxRestartDelay.
+	minRestartDelay = 250 * time.Millisecond
+	maxRestartDelay = 30 * time.Second
+)
+
+func init() {
+	resource.RegisterService(
+		mlmodel.API,
+		model,
+		resource.Registration[mlmodel.Service, *Config]{Constructor: newService},
+	)
+}
+
+// Config is the config of the subprocess mlmodel.
+type Config struct {
+	// ExecutablePath is the path of the runner, which is started with Args and with Env added to the
+	// environment of the RDK, in WorkingDir if it is set.
+	ExecutablePath string            `json:"executable_path"`
+	Args           []string          `json:"args,omitempty"`
+	Env            map[string]string `json:"env,omitempty"`
+	WorkingDir     string            `json:"working_dir,omitempty"`
+	// Transport is "stdio", the default, or "unix".
+	Transport string `json:"transport,omitempty"`
+	// SharedMemoryThresholdBytes is the size from which tensors are passed through shared memory, 1 MiB by
+	// default. Negative values pass all tensors through the connection.
+	SharedMemoryThresholdBytes int `json:"shared_memory_threshold_bytes,omitempty"`
+	// SharedMemoryDir is where shared memory files are written, /dev/shm by default if there is one, else
+	// the temporary directory.
+	SharedMemoryDir string `json:"shared_memory_dir,omitempty"`
+	// StartupTimeoutSec is how long the runner is given to start and return the metadata of its model.
+	StartupTimeoutSec float64 `json:"startup_timeout_sec,omitempty"`
+}
+
+// Validate validates the subprocess model's configuration.
+func (cfg *Config) Validate(path string) ([]string, error) {
+	if cfg.ExecutablePath == "" {
+		return nil, resource.NewConfigValidationFieldRequiredError(path, "executable_path")
+	}
+	switch cfg.Transport {
+	case "", TransportStdio, TransportUnix:
+	default:
+		return nil, resource.NewConfigValidationError(path,
+			errors.Errorf("transport must be %q or %q, got %q", TransportStdio, TransportUnix, cfg.Transport))
+	}
+	if cfg.StartupTimeoutSec < 0 {
+		return nil, resource.NewConfigValidationError(path, errors.New("startup_timeout_sec cannot be negative"))
+	}
+	return nil, nil
+}
+
+func applyDefaults(cfg *Config) {
+	if cfg.Transport == "" {
+		cfg.Transport = TransportStdio
+	}
+	switch {
+	case cfg.SharedMemoryThresholdBytes == 0:
+		cfg.SharedMemoryThresholdBytes = defaultSharedMemoryThreshold
+	case cfg.SharedMemoryThresholdBytes < 0:
+		cfg.SharedMemoryThresholdBytes = -1
+	}
+	if cfg.SharedMemoryDir == "" {
+		cfg.SharedMemoryDir = os.TempDir()
+		if info, err := os.Stat(defaultSharedMemoryDir); err == nil && info.IsDir() {
+			cfg.SharedMemoryDir = defaultSharedMemoryDir
+		}
+	}
+	if cfg.StartupTimeoutSec == 0 {
+		cfg.StartupTimeoutSec = defaultStartupTimeoutSec
+	}
+}
+
+type subprocessModel struct {
+	resource.Named
+	resource.AlwaysRebuild
+	logger logging.Logger
+	conf   *Config
+
+	// mu serializes requests to the runner, which handles one at a time.
+	mu       sync.Mutex
+	runner   *runner
+	metadata mlmodel.MLMetadata
+	nextID   uint64
+	// crashes is how many times in a row the runner has crashed or failed to start, and restartAt is when
+	// it may be started again.
+	crashes   int
+	restartAt time.Time
+	closed    bool
+}
+
+func newService(ctx context.Context, _ resource.Dependencies, conf resource.Config, logger logging.Logger) (mlmodel.Service, error) {
+	newConf, err := resource.NativeConfig[*Config](conf)
+	if err != nil {
+		return nil, err
+	}
+	return newSubprocessModel(ctx, conf.ResourceName(), newConf, logger)
+}
+
+// newSubprocessModel creates the service and starts its runner, failing if the runner does not start.
+func newSubprocessModel(ctx context.Context, name resource.Name, conf *Config, logger logging.Logger) (*subprocessModel, error) {
+	applyDefaults(conf)
+	m := &subprocessModel{Named: name.AsNamed(), logger: logger, conf: conf}
+	r, md, err := startRunner(ctx, conf, conf.SharedMemoryDir, conf.SharedMemoryThresholdBytes, logger)
+	if err != nil {
+		return nil, err
+	}
+	m.runner, m.metadata = r, md
+	return m, nil
+}
+
+// Infer sends the tensors to the runner and returns the tensors it infers from them.
+func (m *subprocessModel) Infer(ctx context.Context, tensors ml.Tensors) (ml.Tensors, error) {
+	ctx, span := trace.StartSpan(ctx, "service::mlmodel::subprocess::Infer")
+	defer span.End()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	headers, payload, files, err := encodeTensors(tensors, m.conf.SharedMemoryDir, m.conf.SharedMemoryThresholdBytes)
+	defer func() {
+		for _, f := range files {
+			//nolint:errcheck,gosec
+			os.Remove(f)
+		}
+	}()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot encode input tensors")
+	}
+	resp, data, err := m.request(ctx, &header{Method: methodInfer, Tensors: headers}, payload)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, errors.Errorf("runner failed to infer: %s", resp.Error)
+	}
+	out, err := decodeTensors(resp.Tensors, data, m.conf.SharedMemoryDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot decode output tensors")
+	}
+	return out, nil
+}
+
+// Metadata returns the metadata the runner last reported when it started.
+func (m *subprocessModel) Metadata(context.Context) (mlmodel.MLMetadata, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.metadata, nil
+}
+
+// request sends a request to the runner, restarting it first if it has exited, and returns the response.
+// A runner which fails to respond is stopped, to be restarted by the next request. Must be called with mu
+// held.
+func (m *subprocessModel) request(ctx context.Context, req *header, payload [][]byte) (*header, map[int][]byte, error) {
+	if err := m.ensureRunner(ctx); err != nil {
+		return nil, nil, err
+	}
+	m.nextID++
+	req.ID = m.nextID
+	resp, data, err := m.runner.request(ctx, req, payload)
+	if err != nil {
+		if ctx.Err() == nil {
+			m.logger.CWarnw(ctx, "runner failed to respond, it will be restarted", "error", err)
+		}
+		m.stopRunner()
+		m.crashed()
+		return nil, nil, errors.Wrap(err, "runner failed to respond")
+	}
+	m.crashes = 0
+	return resp, data, nil
+}
+
+// ensureRunner restarts the runner if it has exited, once the delay after its last crash has passed.
+func (m *subprocessModel) ensureRunner(ctx context.Context) error {
+	if m.closed {
+		return errors.New("service is closed")
+	}
+	if m.runner != nil && m.runner.hasExited() {
+		m.stopRunner()
+		m.crashed()
+	}
+	if m.runner != nil {
+		return nil
+	}
+	if wait := time.Until(m.restartAt); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	m.logger.CInfow(ctx, "restarting runner", "executable_path", m.conf.ExecutablePath)
+	r, md, err := startRunner(ctx, m.conf, m.conf.SharedMemoryDir, m.conf.SharedMemoryThresholdBytes, m.logger)
+	if err != nil {
+		m.crashed()
+		return errors.Wrap(err, "cannot restart runner")
+	}
+	m.runner, m.metadata = r, md
+	return nil
+}
+
+// crashed records that the runner crashed, delaying its next restart.
+func (m *subprocessModel) crashed() {
+	m.crashes++
+	delay := time.Duration(0)
+	if m.crashes > 1 {
+		delay = minRestartDelay << min(m.crashes-2, 16)
+		delay = min(delay, maxRestartDelay)
+	}
+	m.restartAt = time.Now().Add(delay)
+}
+
+func (m *subprocessModel) stopRunner() {
+	if m.runner != nil {
+		m.runner.stop()
+		m.runner = nil
+	}
+}
+
+// Close stops the runner.
+func (m *subprocessModel) Close(context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	m.stopRunner()
+	return nil
+}
@@ -0,0 +1,162 @@
+package subprocess
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+	"gorgonia.org/tensor"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/ml"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/mlmodel"
+	"go.viam.com/rdk/testutils"
+)
+
+func newFakeModel(t *testing.T, runnerPath string, conf *Config) *subprocessModel {
+	t.Helper()
+	conf.ExecutablePath = runnerPath
+	m, err := newSubprocessModel(context.Background(), mlmodel.Named("fake"), conf, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	t.Cleanup(func() {
+		test.That(t, m.Close(context.Background()), test.ShouldBeNil)
+	})
+	return m
+}
+
+func inferDoubled(t *testing.T, m mlmodel.Service, input []float32) {
+	t.Helper()
+	out, err := m.Infer(context.Background(), ml.Tensors{
+		"input": tensor.New(tensor.WithShape(len(input)), tensor.WithBacking(input)),
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out, test.ShouldHaveLength, 1)
+	want := make([]float32, len(input))
+	for i, v := range input {
+		want[i] = 2 * v
+	}
+	test.That(t, out["output"].Shape(), test.ShouldResemble, tensor.Shape{len(input)})
+	test.That(t, out["output"].Data(), test.ShouldResemble, want)
+}
+
+func TestSubprocessModel(t *testing.T) {
+	runnerPath := testutils.BuildTempModule(t, "services/mlmodel/subprocess/fakerunner")
+
+	for _, transport := range []string{TransportStdio, TransportUnix} {
+		t.Run(transport, func(t *testing.T) {
+			m := newFakeModel(t, runnerPath, &Config{Transport: transport})
+
+			md, err := m.Metadata(context.Background())
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, md.ModelName, test.ShouldEqual, "fake")
+			test.That(t, md.ModelType, test.ShouldEqual, "doubler")
+			test.That(t, md.Inputs, test.ShouldHaveLength, 1)
+			test.That(t, md.Inputs[0].Name, test.ShouldEqual, "input")
+			test.That(t, md.Inputs[0].DataType, test.ShouldEqual, "float32")
+			test.That(t, md.Inputs[0].Shape, test.ShouldResemble, []int{-1})
+			test.That(t, md.Outputs[0].Name, test.ShouldEqual, "output")
+
+			inferDoubled(t, m, []float32{1, 2.5, -3})
+
+			out, err := m.Infer(context.Background(), ml.Tensors{
+				"image": tensor.New(tensor.WithShape(1, 2), tensor.WithBacking([]uint8{3, 100})),
+			})
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, out["image"].Data(), test.ShouldResemble, []uint8{6, 200})
+
+			_, err = m.Infer(context.Background(), ml.Tensors{
+				"input": tensor.New(tensor.WithShape(1), tensor.WithBacking([]int32{1})),
+			})
+			test.That(t, err, test.ShouldBeError)
+			test.That(t, err.Error(), test.ShouldContainSubstring, "cannot double int32")
+			// a runner reporting an error is still usable.
+			inferDoubled(t, m, []float32{4})
+		})
+	}
+
+	t.Run("shared memory", func(t *testing.T) {
+		shmDir := t.TempDir()
+		m := newFakeModel(t, runnerPath, &Config{SharedMemoryThresholdBytes: 8, SharedMemoryDir: shmDir})
+		inferDoubled(t, m, []float32{1})
+		inferDoubled(t, m, []float32{1, 2, 3, 4, 5, 6, 7, 8})
+		// the files of both the inputs and the outputs have been removed.
+		entries, err := os.ReadDir(shmDir)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, entries, test.ShouldBeEmpty)
+	})
+
+	t.Run("restart", func(t *testing.T) {
+		m := newFakeModel(t, runnerPath, &Config{})
+		inferDoubled(t, m, []float32{1})
+
+		_, err := m.Infer(context.Background(), ml.Tensors{
+			"crash": tensor.New(tensor.WithShape(1), tensor.WithBacking([]float32{1})),
+		})
+		test.That(t, err, test.ShouldBeError)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "runner failed to respond")
+		inferDoubled(t, m, []float32{2})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		_, err = m.Infer(ctx, ml.Tensors{
+			"hang": tensor.New(tensor.WithShape(1), tensor.WithBacking([]float32{1})),
+		})
+		test.That(t, err, test.ShouldBeError)
+		test.That(t, err.Error(), test.ShouldContainSubstring, context.DeadlineExceeded.Error())
+		inferDoubled(t, m, []float32{3})
+	})
+
+	t.Run("startup failure", func(t *testing.T) {
+		conf := &Config{ExecutablePath: filepath.Join(t.TempDir(), "missing")}
+		_, err := newSubprocessModel(context.Background(), mlmodel.Named("fake"), conf, logging.NewTestLogger(t))
+		test.That(t, err, test.ShouldBeError)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "cannot start runner")
+
+		// a runner which does not speak the protocol fails the metadata handshake.
+		conf = &Config{ExecutablePath: "sh", Args: []string{"-c", "echo not a runner; sleep 10"}, StartupTimeoutSec: 1}
+		_, err = newSubprocessModel(context.Background(), mlmodel.Named("fake"), conf, logging.NewTestLogger(t))
+		test.That(t, err, test.ShouldBeError)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "cannot get metadata from runner")
+	})
+}
+
+func TestRestartDelay(t *testing.T) {
+	m := &subprocessModel{}
+	m.crashed()
+	test.That(t, time.Until(m.restartAt), test.ShouldBeLessThanOrEqualTo, 0)
+	m.crashed()
+	test.That(t, time.Until(m.restartAt), test.ShouldBeBetween, minRestartDelay/2, minRestartDelay)
+	for i := 0; i < 20; i++ {
+		m.crashed()
+	}
+	test.That(t, time.Until(m.restartAt), test.ShouldBeBetween, maxRestartDelay/2, maxRestartDelay)
+}
+
+func TestConfigValidate(t *testing.T) {
+	conf := &Config{}
+	_, err := conf.Validate("path")
+	test.That(t, err, test.ShouldBeError, resource.NewConfigValidationFieldRequiredError("path", "executable_path"))
+
+	conf = &Config{ExecutablePath: "runner", Transport: "tcp"}
+	_, err = conf.Validate("path")
+	test.That(t, err, test.ShouldBeError)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "transport")
+
+	conf = &Config{ExecutablePath: "runner", StartupTimeoutSec: -1}
+	_, err = conf.Validate("path")
+	test.That(t, err, test.ShouldBeError)
+
+	conf = &Config{ExecutablePath: "runner", Transport: TransportUnix}
+	deps, err := conf.Validate("path")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldBeEmpty)
+
+	applyDefaults(conf)
+	test.That(t, conf.SharedMemoryThresholdBytes, test.ShouldEqual, defaultSharedMemoryThreshold)
+	test.That(t, conf.SharedMemoryDir, test.ShouldNotBeEmpty)
+	test.That(t, conf.StartupTimeoutSec, test.ShouldEqual, defaultStartupTimeoutSec)
+}
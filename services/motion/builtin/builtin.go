@@ -82,10 +82,17 @@ type inputEnabledActuator interface {
 // Config describes how to configure the service; currently only used for specifying dependency on framesystem service.
 type Config struct {
 	LogFilePath string `json:"log_file_path"`
+	// ExecutionHistoryDir is where executions are logged so they survive restarts; unset keeps them in memory only.
+	ExecutionHistoryDir string `json:"execution_history_dir,omitempty"`
+	// ExecutionHistoryMaxExecutions bounds the number of executions kept per component, unbounded if unset.
+	ExecutionHistoryMaxExecutions int `json:"execution_history_max_executions,omitempty"`
 }
 
 // Validate here adds a dependency on the internal framesystem service.
 func (c *Config) Validate(path string) ([]string, error) {
+	if c.ExecutionHistoryMaxExecutions < 0 {
+		return nil, resource.NewConfigValidationError(path, errors.New("execution_history_max_executions cannot be negative"))
+	}
 	return []string{framesystem.InternalServiceName.String()}, nil
 }
 
@@ -150,7 +157,10 @@ func (ms *builtIn) Reconfigure(
 		ms.state.Stop()
 	}
 
-	state, err := state.NewState(stateTTL, stateTTLCheckInterval, ms.logger)
+	state, err := state.NewStateWithHistory(stateTTL, stateTTLCheckInterval, state.HistoryConfig{
+		Dir:           config.ExecutionHistoryDir,
+		MaxExecutions: config.ExecutionHistoryMaxExecutions,
+	}, ms.logger)
 	if err != nil {
 		return err
 	}
@@ -203,7 +213,12 @@ func (ms *builtIn) MoveOnMap(ctx context.Context, req motion.MoveOnMapReq) (moti
 	// TODO: Deprecated: remove once no motion apis use the opid system
 	operation.CancelOtherWithLabel(ctx, builtinOpLabel)
 
-	id, err := state.StartExecution(ctx, ms.state, req.ComponentName, req, ms.newMoveOnMapRequest)
+	route := &routeProgress{}
+	id, err := state.StartExecution(ctx, ms.state, req.ComponentName, req, func(
+		ctx context.Context, req motion.MoveOnMapReq, seedPlan motionplan.Plan, replanCount int,
+	) (state.PlannerExecutor, error) {
+		return ms.newMoveOnMapRequest(ctx, req, seedPlan, replanCount, route)
+	})
 	if err != nil {
 		return uuid.Nil, err
 	}
@@ -268,7 +283,12 @@ func (ms *builtIn) MoveOnGlobe(ctx context.Context, req motion.MoveOnGlobeReq) (
 	// TODO: Deprecated: remove once no motion apis use the opid system
 	operation.CancelOtherWithLabel(ctx, builtinOpLabel)
 
-	id, err := state.StartExecution(ctx, ms.state, req.ComponentName, req, ms.newMoveOnGlobeRequest)
+	route := &routeProgress{}
+	id, err := state.StartExecution(ctx, ms.state, req.ComponentName, req, func(
+		ctx context.Context, req motion.MoveOnGlobeReq, seedPlan motionplan.Plan, replanCount int,
+	) (state.PlannerExecutor, error) {
+		return ms.newMoveOnGlobeRequest(ctx, req, seedPlan, replanCount, route)
+	})
 	if err != nil {
 		return uuid.Nil, err
 	}
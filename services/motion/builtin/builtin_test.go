@@ -816,7 +816,7 @@ func TestGetTransientDetectionsSlam(t *testing.T) {
 		},
 	}
 
-	planExecutor, err := ms.(*builtIn).newMoveOnMapRequest(ctx, moveReq, nil, 0)
+	planExecutor, err := ms.(*builtIn).newMoveOnMapRequest(ctx, moveReq, nil, 0, nil)
 	test.That(t, err, test.ShouldBeNil)
 
 	mr, ok := planExecutor.(*moveRequest)
@@ -894,7 +894,7 @@ func TestGetTransientDetectionsMath(t *testing.T) {
 		},
 	}
 
-	planExecutor, err := ms.(*builtIn).newMoveOnGlobeRequest(ctx, moveReq, nil, 0)
+	planExecutor, err := ms.(*builtIn).newMoveOnGlobeRequest(ctx, moveReq, nil, 0, nil)
 	test.That(t, err, test.ShouldBeNil)
 
 	mr, ok := planExecutor.(*moveRequest)
@@ -1062,7 +1062,7 @@ func TestCheckPlan(t *testing.T) {
 	}
 
 	// construct move request
-	planExecutor, err := ms.(*builtIn).newMoveOnGlobeRequest(ctx, req, nil, 0)
+	planExecutor, err := ms.(*builtIn).newMoveOnGlobeRequest(ctx, req, nil, 0, nil)
 	test.That(t, err, test.ShouldBeNil)
 	mr, ok := planExecutor.(*moveRequest)
 	test.That(t, ok, test.ShouldBeTrue)
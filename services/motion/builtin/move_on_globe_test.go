@@ -112,7 +112,7 @@ func TestMoveOnGlobe(t *testing.T) {
 			MotionCfg:          &motion.MotionConfiguration{},
 			Extra:              extra,
 		}
-		moveRequest, err := ms.(*builtIn).newMoveOnGlobeRequest(ctx, req, nil, 0)
+		moveRequest, err := ms.(*builtIn).newMoveOnGlobeRequest(ctx, req, nil, 0, nil)
 		test.That(t, err, test.ShouldBeNil)
 		planResp, err := moveRequest.Plan(ctx)
 		test.That(t, err, test.ShouldBeError)
@@ -140,7 +140,7 @@ func TestMoveOnGlobe(t *testing.T) {
 		}
 
 		// validate that plan is actually empty
-		moveRequest, err := ms.(*builtIn).newMoveOnGlobeRequest(ctx, req, nil, 0)
+		moveRequest, err := ms.(*builtIn).newMoveOnGlobeRequest(ctx, req, nil, 0, nil)
 		test.That(t, err, test.ShouldBeNil)
 		planResp, err := moveRequest.Plan(ctx)
 		test.That(t, err, test.ShouldBeNil)
@@ -159,6 +159,88 @@ func TestMoveOnGlobe(t *testing.T) {
 		})
 		test.That(t, err, test.ShouldBeNil)
 	})
+
+	t.Run("via-points are executed as segments of one execution", func(t *testing.T) {
+		_, ms, closeFunc := CreateMoveOnGlobeTestEnvironment(ctx, t, gpsPoint, 80, nil)
+		defer closeFunc(ctx)
+
+		req := motion.MoveOnGlobeReq{
+			ComponentName:      baseResource,
+			Destination:        gpsPoint,
+			MovementSensorName: moveSensorResource,
+			MotionCfg:          &motion.MotionConfiguration{},
+			ViaPoints:          []motion.GeoViaPoint{{Point: gpsPoint}},
+			Extra:              extra,
+		}
+		executionID, err := ms.MoveOnGlobe(ctx, req)
+		test.That(t, err, test.ShouldBeNil)
+
+		timeoutCtx, timeoutFn := context.WithTimeout(ctx, time.Second*5)
+		defer timeoutFn()
+		err = motion.PollHistoryUntilSuccessOrError(timeoutCtx, ms, time.Millisecond*5, motion.PlanHistoryReq{
+			ComponentName: req.ComponentName,
+			ExecutionID:   executionID,
+			LastPlanOnly:  true,
+		})
+		test.That(t, err, test.ShouldBeNil)
+
+		ph, err := ms.PlanHistory(ctx, motion.PlanHistoryReq{ComponentName: req.ComponentName, ExecutionID: executionID})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, len(ph), test.ShouldEqual, 2)
+		test.That(t, ph[1].StatusHistory[0].State, test.ShouldEqual, motion.PlanStateSucceeded)
+		test.That(t, *ph[1].StatusHistory[0].Reason, test.ShouldEqual, "reached via-point 1 of 1")
+		test.That(t, ph[0].StatusHistory[0].State, test.ShouldEqual, motion.PlanStateSucceeded)
+		test.That(t, ph[0].StatusHistory[0].Reason, test.ShouldBeNil)
+	})
+}
+
+func TestGlobeSegmentGoals(t *testing.T) {
+	origin := geo.NewPoint(0, 0)
+	motionCfg, err := newValidatedMotionCfg(nil, requestTypeMoveOnGlobe)
+	test.That(t, err, test.ShouldBeNil)
+	valExtra, err := newValidatedExtra(nil)
+	test.That(t, err, test.ShouldBeNil)
+
+	heading := 90.
+	req := motion.MoveOnGlobeReq{
+		Destination: geo.NewPoint(0, 2e-5),
+		ViaPoints: []motion.GeoViaPoint{
+			{Point: geo.NewPoint(1e-5, 0), Heading: &heading, ToleranceMM: 100},
+			{Point: geo.NewPoint(1e-5, 1e-5)},
+		},
+	}
+	goals, err := globeSegmentGoals(req, origin, motionCfg, valExtra)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(goals), test.ShouldEqual, 3)
+
+	test.That(t, goals[0].pose.Point().Y, test.ShouldBeGreaterThan, 0)
+	test.That(t, goals[0].toleranceMM, test.ShouldEqual, 100)
+	test.That(t, goals[0].positionOnly, test.ShouldBeFalse)
+	test.That(t, goals[0].pose.Orientation().OrientationVectorDegrees().Theta, test.ShouldAlmostEqual, -90)
+
+	test.That(t, goals[1].toleranceMM, test.ShouldEqual, motionCfg.planDeviationMM)
+	test.That(t, goals[1].positionOnly, test.ShouldBeTrue)
+
+	test.That(t, goals[2].pose.Point().X, test.ShouldBeGreaterThan, 0)
+	test.That(t, goals[2].positionOnly, test.ShouldBeFalse)
+
+	route := &routeProgress{}
+	test.That(t, route.current(goals), test.ShouldResemble, goals[0])
+	resp := route.segmentReached(len(goals))
+	test.That(t, resp.NextSegment, test.ShouldBeTrue)
+	test.That(t, resp.SegmentReason, test.ShouldEqual, "reached via-point 1 of 2")
+	test.That(t, route.current(goals), test.ShouldResemble, goals[1])
+	route.segmentReached(len(goals))
+	test.That(t, route.segmentReached(len(goals)).NextSegment, test.ShouldBeFalse)
+	test.That(t, route.current(goals), test.ShouldResemble, goals[2])
+
+	req.ViaPoints[1].ToleranceMM = -1
+	_, err = globeSegmentGoals(req, origin, motionCfg, valExtra)
+	test.That(t, err, test.ShouldBeError, errors.New("ToleranceMM of via-point 1 may not be negative"))
+
+	req.ViaPoints[1] = motion.GeoViaPoint{}
+	_, err = globeSegmentGoals(req, origin, motionCfg, valExtra)
+	test.That(t, err, test.ShouldBeError, errors.New("via-point 1 cannot be nil"))
 }
 
 func TestBoundingRegionsConstraint(t *testing.T) {
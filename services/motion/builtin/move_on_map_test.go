@@ -235,7 +235,7 @@ func TestMoveOnMapStaticObs(t *testing.T) {
 		req.Obstacles = []spatialmath.Geometry{obstacleRight}
 
 		// construct move request
-		planExecutor, err := ms.(*builtIn).newMoveOnMapRequest(ctx, req, nil, 0)
+		planExecutor, err := ms.(*builtIn).newMoveOnMapRequest(ctx, req, nil, 0, nil)
 		test.That(t, err, test.ShouldBeNil)
 		mr, ok := planExecutor.(*moveRequest)
 		test.That(t, ok, test.ShouldBeTrue)
@@ -337,7 +337,7 @@ func TestMoveOnMapStaticObs(t *testing.T) {
 		req.Obstacles = []spatialmath.Geometry{obstacleTop, obstacleBottom, obstacleLeft, obstacleRight}
 
 		// construct move request
-		planExecutor, err := ms.(*builtIn).newMoveOnMapRequest(ctx, req, nil, 0)
+		planExecutor, err := ms.(*builtIn).newMoveOnMapRequest(ctx, req, nil, 0, nil)
 		test.That(t, err, test.ShouldBeNil)
 		mr, ok := planExecutor.(*moveRequest)
 		test.That(t, ok, test.ShouldBeTrue)
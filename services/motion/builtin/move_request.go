@@ -80,6 +80,9 @@ type moveRequest struct {
 
 	executeBackgroundWorkers *sync.WaitGroup
 	responseChan             chan moveResponse
+	// route is the progress of the execution through the segments of the request, numSegments long
+	route       *routeProgress
+	numSegments int
 	// replanners for the move request
 	// if we ever have to add additional instances we should figure out how to make this more scalable
 	position, obstacle *replanner
@@ -133,7 +136,11 @@ func (mr *moveRequest) Execute(ctx context.Context, plan motionplan.Plan) (state
 	defer cancelFn()
 
 	mr.start(cancelCtx, plan)
-	return mr.listen(cancelCtx)
+	resp, err := mr.listen(cancelCtx)
+	if err != nil || resp.Replan {
+		return resp, err
+	}
+	return mr.route.segmentReached(mr.numSegments), nil
 }
 
 func (mr *moveRequest) AnchorGeoPose() *spatialmath.GeoPose {
@@ -558,6 +565,7 @@ func (ms *builtIn) newMoveOnGlobeRequest(
 	req motion.MoveOnGlobeReq,
 	seedPlan motionplan.Plan,
 	replanCount int,
+	route *routeProgress,
 ) (state.PlannerExecutor, error) {
 	valExtra, err := newValidatedExtra(req.Extra)
 	if err != nil {
@@ -631,15 +639,13 @@ func (ms *builtIn) newMoveOnGlobeRequest(
 		return nil, err
 	}
 
-	// Important: GeoPointToPose will create a pose such that incrementing latitude towards north increments +Y, and incrementing
-	// longitude towards east increments +X. Heading is not taken into account. This pose must therefore be transformed based on the
-	// orientation of the base such that it is a pose relative to the base's current location.
-	goalPoseRaw := spatialmath.NewPoseFromPoint(spatialmath.GeoPointToPoint(req.Destination, origin))
-	// construct limits
-	straightlineDistance := goalPoseRaw.Point().Norm()
-	if straightlineDistance > maxTravelDistanceMM {
-		return nil, fmt.Errorf("cannot move more than %d kilometers", int(maxTravelDistanceMM*1e-6))
+	goals, err := globeSegmentGoals(req, origin, motionCfg, valExtra)
+	if err != nil {
+		return nil, err
 	}
+	goal := route.current(goals)
+	// construct limits
+	straightlineDistance := goal.pose.Point().Norm()
 
 	// Set the limits for a base if we are using diffential drive.
 	// If we are using PTG kineamtics these limits will be ignored.
@@ -664,7 +670,7 @@ func (ms *builtIn) newMoveOnGlobeRequest(
 		motionCfg,
 		ms.logger,
 		kb,
-		goalPoseRaw,
+		goal,
 		fs,
 		geomsRaw,
 		valExtra,
@@ -672,6 +678,8 @@ func (ms *builtIn) newMoveOnGlobeRequest(
 	if err != nil {
 		return nil, err
 	}
+	mr.route = route
+	mr.numSegments = len(goals)
 	mr.seedPlan = seedPlan
 	mr.replanCostFactor = valExtra.replanCostFactor
 	mr.requestType = requestTypeMoveOnGlobe
@@ -686,6 +694,7 @@ func (ms *builtIn) newMoveOnMapRequest(
 	req motion.MoveOnMapReq,
 	seedPlan motionplan.Plan,
 	replanCount int,
+	route *routeProgress,
 ) (state.PlannerExecutor, error) {
 	valExtra, err := newValidatedExtra(req.Extra)
 	if err != nil {
@@ -707,6 +716,11 @@ func (ms *builtIn) newMoveOnMapRequest(
 		return nil, errors.New("destination cannot be nil")
 	}
 
+	goals, err := mapSegmentGoals(req, motionCfg, valExtra)
+	if err != nil {
+		return nil, err
+	}
+
 	// get the SLAM Service from the slamName
 	slamSvc, ok := ms.slamServices[req.SlamName]
 	if !ok {
@@ -754,8 +768,6 @@ func (ms *builtIn) newMoveOnMapRequest(
 		return nil, err
 	}
 
-	goalPoseAdj := spatialmath.Compose(req.Destination, motion.SLAMOrientationAdjustment)
-
 	// get point cloud data in the form of bytes from pcd
 	pointCloudData, err := slam.PointCloudMapFull(ctx, slamSvc, true)
 	if err != nil {
@@ -774,7 +786,7 @@ func (ms *builtIn) newMoveOnMapRequest(
 		motionCfg,
 		ms.logger,
 		kb,
-		goalPoseAdj,
+		route.current(goals),
 		fs,
 		req.Obstacles,
 		valExtra,
@@ -782,6 +794,8 @@ func (ms *builtIn) newMoveOnMapRequest(
 	if err != nil {
 		return nil, err
 	}
+	mr.route = route
+	mr.numSegments = len(goals)
 	mr.requestType = requestTypeMoveOnMap
 	return mr, nil
 }
@@ -791,7 +805,7 @@ func (ms *builtIn) createBaseMoveRequest(
 	motionCfg *validatedMotionConfiguration,
 	logger logging.Logger,
 	kb kinematicbase.KinematicBase,
-	goalPoseInWorld spatialmath.Pose,
+	segment segmentGoal,
 	fs referenceframe.FrameSystem,
 	worldObstacles []spatialmath.Geometry,
 	valExtra validatedExtra,
@@ -859,7 +873,7 @@ func (ms *builtIn) createBaseMoveRequest(
 		return nil, err
 	}
 
-	goal := referenceframe.NewPoseInFrame(referenceframe.World, goalPoseInWorld)
+	goal := referenceframe.NewPoseInFrame(referenceframe.World, segment.pose)
 
 	gif := referenceframe.NewGeometriesInFrame(referenceframe.World, worldObstacles)
 	worldState, err := referenceframe.NewWorldState([]*referenceframe.GeometriesInFrame{gif}, nil)
@@ -905,11 +919,21 @@ func (ms *builtIn) createBaseMoveRequest(
 
 	// TODO(RSDK-8683): move this check into the motionplan package
 	atGoalCheck := func(basePose spatialmath.Pose) bool {
-		if valExtra.motionProfile == motionplan.PositionOnlyMotionProfile {
-			return spatialmath.PoseAlmostCoincidentEps(goal.Pose(), basePose, motionCfg.planDeviationMM)
+		if segment.positionOnly {
+			return spatialmath.PoseAlmostCoincidentEps(goal.Pose(), basePose, segment.toleranceMM)
 		}
 		return spatialmath.OrientationAlmostEqualEps(goal.Pose().Orientation(), basePose.Orientation(), 5) &&
-			spatialmath.PoseAlmostCoincidentEps(goal.Pose(), basePose, motionCfg.planDeviationMM)
+			spatialmath.PoseAlmostCoincidentEps(goal.Pose(), basePose, segment.toleranceMM)
+	}
+
+	// via-points without a heading are planned to without regard for the orientation of the base
+	planOptions := valExtra.extra
+	if segment.positionOnly && valExtra.motionProfile != motionplan.PositionOnlyMotionProfile {
+		planOptions = make(map[string]interface{}, len(valExtra.extra)+1)
+		for k, v := range valExtra.extra {
+			planOptions[k] = v
+		}
+		planOptions["motion_profile"] = motionplan.PositionOnlyMotionProfile
 	}
 
 	var backgroundWorkers sync.WaitGroup
@@ -925,7 +949,7 @@ func (ms *builtIn) createBaseMoveRequest(
 			StartConfiguration: currentInputs,
 			StartPose:          startPose,
 			WorldState:         worldState,
-			Options:            valExtra.extra,
+			Options:            planOptions,
 		},
 		kinematicBase:     kb,
 		replanCostFactor:  valExtra.replanCostFactor,
@@ -0,0 +1,144 @@
+package builtin
+
+import (
+	"fmt"
+	"math"
+
+	geo "github.com/kellydunn/golang-geo"
+	"github.com/pkg/errors"
+
+	"go.viam.com/rdk/motionplan"
+	"go.viam.com/rdk/services/motion"
+	"go.viam.com/rdk/services/motion/builtin/state"
+	"go.viam.com/rdk/spatialmath"
+)
+
+// segmentGoal is the goal of one segment of a MoveOnGlobe or MoveOnMap request with via-points.
+// A request without via-points has a single segment whose goal is its destination.
+type segmentGoal struct {
+	pose spatialmath.Pose
+	// toleranceMM is how close the base needs to come to pose for the goal to be reached
+	toleranceMM float64
+	// positionOnly is true if the orientation of the base is not checked when reaching the goal
+	positionOnly bool
+}
+
+// routeProgress tracks which segment of a request is being executed.
+// It is shared by every moveRequest created for an execution, so replanning resumes the current segment
+// rather than starting the route over.
+type routeProgress struct {
+	segment int
+}
+
+// segmentReached advances the route past the segment which was just reached, returning the
+// response the state should act on.
+func (rp *routeProgress) segmentReached(numSegments int) state.ExecuteResponse {
+	if rp == nil || rp.segment >= numSegments-1 {
+		return state.ExecuteResponse{}
+	}
+	rp.segment++
+	return state.ExecuteResponse{
+		NextSegment:   true,
+		SegmentReason: fmt.Sprintf("reached via-point %d of %d", rp.segment, numSegments-1),
+	}
+}
+
+// current returns the goal of the segment being executed.
+func (rp *routeProgress) current(goals []segmentGoal) segmentGoal {
+	if rp == nil || rp.segment >= len(goals) {
+		return goals[len(goals)-1]
+	}
+	return goals[rp.segment]
+}
+
+// globeSegmentGoals returns the goals of a MoveOnGlobe request relative to origin, via-points first.
+func globeSegmentGoals(
+	req motion.MoveOnGlobeReq,
+	origin *geo.Point,
+	motionCfg *validatedMotionConfiguration,
+	valExtra validatedExtra,
+) ([]segmentGoal, error) {
+	goals := make([]segmentGoal, 0, len(req.ViaPoints)+1)
+	for i, viaPoint := range req.ViaPoints {
+		if viaPoint.Point == nil {
+			return nil, errors.Errorf("via-point %d cannot be nil", i)
+		}
+		if math.IsNaN(viaPoint.Point.Lat()) || math.IsNaN(viaPoint.Point.Lng()) {
+			return nil, errors.Errorf("via-point %d may not contain NaN", i)
+		}
+		toleranceMM, err := viaPointTolerance(viaPoint.ToleranceMM, motionCfg, i)
+		if err != nil {
+			return nil, err
+		}
+		goal := segmentGoal{
+			pose:         spatialmath.NewPoseFromPoint(spatialmath.GeoPointToPoint(viaPoint.Point, origin)),
+			toleranceMM:  toleranceMM,
+			positionOnly: viaPoint.Heading == nil || valExtra.motionProfile == motionplan.PositionOnlyMotionProfile,
+		}
+		if viaPoint.Heading != nil {
+			if err := validateNotNan(*viaPoint.Heading, fmt.Sprintf("heading of via-point %d", i)); err != nil {
+				return nil, err
+			}
+			// headings are measured clockwise from north, which is a rotation in the negative direction about Z
+			goal.pose = spatialmath.NewPose(goal.pose.Point(), &spatialmath.OrientationVectorDegrees{OZ: 1, Theta: -*viaPoint.Heading})
+		}
+		goals = append(goals, goal)
+	}
+
+	// Important: GeoPointToPose will create a pose such that incrementing latitude towards north increments +Y, and incrementing
+	// longitude towards east increments +X. Heading is not taken into account. This pose must therefore be transformed based on the
+	// orientation of the base such that it is a pose relative to the base's current location.
+	goals = append(goals, segmentGoal{
+		pose:         spatialmath.NewPoseFromPoint(spatialmath.GeoPointToPoint(req.Destination, origin)),
+		toleranceMM:  motionCfg.planDeviationMM,
+		positionOnly: valExtra.motionProfile == motionplan.PositionOnlyMotionProfile,
+	})
+
+	for i, goal := range goals {
+		if goal.pose.Point().Norm() > maxTravelDistanceMM {
+			if i < len(req.ViaPoints) {
+				return nil, fmt.Errorf("cannot move more than %d kilometers to via-point %d", int(maxTravelDistanceMM*1e-6), i)
+			}
+			return nil, fmt.Errorf("cannot move more than %d kilometers", int(maxTravelDistanceMM*1e-6))
+		}
+	}
+	return goals, nil
+}
+
+// mapSegmentGoals returns the goals of a MoveOnMap request in the frame of the SLAM map, via-poses first.
+func mapSegmentGoals(
+	req motion.MoveOnMapReq,
+	motionCfg *validatedMotionConfiguration,
+	valExtra validatedExtra,
+) ([]segmentGoal, error) {
+	goals := make([]segmentGoal, 0, len(req.ViaPoses)+1)
+	for i, viaPose := range req.ViaPoses {
+		if viaPose.Pose == nil {
+			return nil, errors.Errorf("via-pose %d cannot be nil", i)
+		}
+		toleranceMM, err := viaPointTolerance(viaPose.ToleranceMM, motionCfg, i)
+		if err != nil {
+			return nil, err
+		}
+		goals = append(goals, segmentGoal{
+			pose:         spatialmath.Compose(viaPose.Pose, motion.SLAMOrientationAdjustment),
+			toleranceMM:  toleranceMM,
+			positionOnly: viaPose.PositionOnly || valExtra.motionProfile == motionplan.PositionOnlyMotionProfile,
+		})
+	}
+	return append(goals, segmentGoal{
+		pose:         spatialmath.Compose(req.Destination, motion.SLAMOrientationAdjustment),
+		toleranceMM:  motionCfg.planDeviationMM,
+		positionOnly: valExtra.motionProfile == motionplan.PositionOnlyMotionProfile,
+	}), nil
+}
+
+func viaPointTolerance(toleranceMM float64, motionCfg *validatedMotionConfiguration, i int) (float64, error) {
+	if err := validateNotNegNorNaN(toleranceMM, fmt.Sprintf("ToleranceMM of via-point %d", i)); err != nil {
+		return 0, err
+	}
+	if toleranceMM == 0 {
+		return motionCfg.planDeviationMM, nil
+	}
+	return toleranceMM, nil
+}
@@ -0,0 +1,266 @@
+package state
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	geo "github.com/kellydunn/golang-geo"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	pb "go.viam.com/api/service/motion/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/motionplan"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/motion"
+	"go.viam.com/rdk/spatialmath"
+)
+
+// HistoryFileName is the name of the execution history log within the configured directory.
+const HistoryFileName = "motion_execution_history.jsonl"
+
+// interruptedReason is the reason given to plans which were still in progress when the state was last stopped.
+const interruptedReason = "execution was interrupted before reaching a terminal state, the motion service was restarted"
+
+// HistoryConfig configures the optional on-disk log of executions, which allows PlanHistory and
+// ListPlanStatuses to return executions from before a restart.
+type HistoryConfig struct {
+	// Dir is the directory the log is written to. No log is kept if Dir is empty.
+	Dir string
+	// MaxExecutions is the maximum number of executions kept per component, in memory and on disk.
+	// Zero means executions are only purged once they are older than the TTL.
+	MaxExecutions int
+}
+
+// historyRecord is a single line of the execution history log. Exactly one of Plan and Status is set.
+type historyRecord struct {
+	// Plan is a new plan along with its in progress status, encoded as a pb.PlanWithStatus.
+	Plan json.RawMessage `json:"plan,omitempty"`
+	// Anchor is the AnchorGeoPose of Plan, which is not part of the protobuf plan.
+	Anchor *anchorRecord `json:"anchor,omitempty"`
+	// Status is a terminal status of a plan, encoded as a pb.PlanStatusWithID.
+	Status json.RawMessage `json:"status,omitempty"`
+}
+
+type anchorRecord struct {
+	Lat     float64 `json:"lat"`
+	Lng     float64 `json:"lng"`
+	Heading float64 `json:"heading"`
+}
+
+// historyLog is an append only log of execution events. It is compacted by rewriting it from the
+// state whenever executions are purged.
+type historyLog struct {
+	path   string
+	logger logging.Logger
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// openHistoryLog opens the log in dir, creating it if needed, and returns the records it already holds.
+func openHistoryLog(dir string, logger logging.Logger) (*historyLog, []historyRecord, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, nil, err
+	}
+	h := &historyLog{path: filepath.Join(dir, HistoryFileName), logger: logger}
+
+	var records []historyRecord
+	//nolint:gosec
+	existing, err := os.ReadFile(h.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(existing))
+	scanner.Buffer(nil, 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var rec historyRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// a crash while appending can leave a partial last line behind.
+			logger.Warnf("skipping unreadable line %d of motion execution history %s: %v", line, h.path, err)
+			continue
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	//nolint:gosec
+	h.file, err = os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, nil, err
+	}
+	return h, records, nil
+}
+
+func planRecord(plan motion.PlanWithMetadata, status motion.PlanStatus) (historyRecord, error) {
+	planJSON, err := protojson.Marshal(motion.PlanWithStatus{Plan: plan, StatusHistory: []motion.PlanStatus{status}}.ToProto())
+	if err != nil {
+		return historyRecord{}, err
+	}
+	rec := historyRecord{Plan: planJSON}
+	if plan.AnchorGeoPose != nil {
+		rec.Anchor = &anchorRecord{
+			Lat:     plan.AnchorGeoPose.Location().Lat(),
+			Lng:     plan.AnchorGeoPose.Location().Lng(),
+			Heading: plan.AnchorGeoPose.Heading(),
+		}
+	}
+	return rec, nil
+}
+
+func statusRecord(update stateUpdateMsg) (historyRecord, error) {
+	statusJSON, err := protojson.Marshal(motion.PlanStatusWithID{
+		PlanID:        update.planID,
+		ComponentName: update.componentName,
+		ExecutionID:   update.executionID,
+		Status:        update.planStatus,
+	}.ToProto())
+	if err != nil {
+		return historyRecord{}, err
+	}
+	return historyRecord{Status: statusJSON}, nil
+}
+
+// decode returns the new plan or the status update held by the record.
+func (rec historyRecord) decode() (*planMsg, *stateUpdateMsg, error) {
+	switch {
+	case rec.Plan != nil:
+		var pws pb.PlanWithStatus
+		if err := protojson.Unmarshal(rec.Plan, &pws); err != nil {
+			return nil, nil, err
+		}
+		plan, err := motion.PlanWithStatusFromProto(&pws)
+		if err != nil {
+			return nil, nil, err
+		}
+		if plan.Plan.Plan == nil {
+			plan.Plan.Plan = motionplan.NewSimplePlan(nil, nil)
+		}
+		if rec.Anchor != nil {
+			plan.Plan.AnchorGeoPose = spatialmath.NewGeoPose(geo.NewPoint(rec.Anchor.Lat, rec.Anchor.Lng), rec.Anchor.Heading)
+		}
+		return &planMsg{plan: plan.Plan, planStatus: plan.StatusHistory[0]}, nil, nil
+	case rec.Status != nil:
+		var pswid pb.PlanStatusWithID
+		if err := protojson.Unmarshal(rec.Status, &pswid); err != nil {
+			return nil, nil, err
+		}
+		status, err := motion.PlanStatusWithIDFromProto(&pswid)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, &stateUpdateMsg{
+			componentName: status.ComponentName,
+			executionID:   status.ExecutionID,
+			planID:        status.PlanID,
+			planStatus:    status.Status,
+		}, nil
+	default:
+		return nil, nil, errors.New("empty motion execution history record")
+	}
+}
+
+func (h *historyLog) append(records ...historyRecord) error {
+	var buf bytes.Buffer
+	for _, rec := range records {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.file == nil {
+		return errors.New("motion execution history is closed")
+	}
+	_, err := h.file.Write(buf.Bytes())
+	return err
+}
+
+// historyRecords returns the records which replay the executions held in the state.
+func historyRecords(components map[resource.Name]componentState) ([]historyRecord, error) {
+	var records []historyRecord
+	for _, cs := range components {
+		// oldest execution first, so replaying the log adds them in the same order.
+		for i := len(cs.executionIDHistory) - 1; i >= 0; i-- {
+			ex := cs.executionsByID[cs.executionIDHistory[i]]
+			for j := len(ex.history) - 1; j >= 0; j-- {
+				pws := ex.history[j]
+				statuses := pws.StatusHistory
+				rec, err := planRecord(pws.Plan, statuses[len(statuses)-1])
+				if err != nil {
+					return nil, err
+				}
+				records = append(records, rec)
+				for k := len(statuses) - 2; k >= 0; k-- {
+					rec, err := statusRecord(stateUpdateMsg{
+						componentName: ex.componentName,
+						executionID:   ex.id,
+						planID:        pws.Plan.ID,
+						planStatus:    statuses[k],
+					})
+					if err != nil {
+						return nil, err
+					}
+					records = append(records, rec)
+				}
+			}
+		}
+	}
+	return records, nil
+}
+
+// rewrite replaces the log with records, dropping purged executions. h.mu must be held, so that no
+// record appended after records were taken from the state is written to the replaced file.
+func (h *historyLog) rewrite(records []historyRecord) error {
+	if h.file == nil {
+		return errors.New("motion execution history is closed")
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(h.path), HistoryFileName+".*.tmp")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			return multierr.Combine(err, tmp.Close(), os.Remove(tmp.Name()))
+		}
+	}
+	if err := multierr.Combine(w.Flush(), tmp.Sync(), tmp.Close()); err != nil {
+		return multierr.Combine(err, os.Remove(tmp.Name()))
+	}
+
+	if err := os.Rename(tmp.Name(), h.path); err != nil {
+		return multierr.Combine(err, os.Remove(tmp.Name()))
+	}
+	// the old handle refers to the replaced file, so appends must go through a new one.
+	//nolint:gosec
+	file, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	err = h.file.Close()
+	h.file = file
+	return err
+}
+
+func (h *historyLog) close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.file == nil {
+		return nil
+	}
+	err := h.file.Close()
+	h.file = nil
+	return err
+}
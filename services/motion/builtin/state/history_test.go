@@ -0,0 +1,176 @@
+package state_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.viam.com/test"
+	"go.viam.com/utils/testutils"
+
+	"go.viam.com/rdk/components/base"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/motionplan"
+	"go.viam.com/rdk/services/motion"
+	"go.viam.com/rdk/services/motion/builtin/state"
+)
+
+func TestStateHistory(t *testing.T) {
+	logger := logging.NewTestLogger(t)
+	ctx := context.Background()
+	myBase := base.Named("mybase")
+	req := motion.MoveOnGlobeReq{ComponentName: myBase}
+
+	// replans once, then succeeds
+	replanOncePlanConstructor := func(
+		ctx context.Context,
+		_ motion.MoveOnGlobeReq,
+		_ motionplan.Plan,
+		replanCount int,
+	) (state.PlannerExecutor, error) {
+		return &testPlannerExecutor{executeFunc: func(ctx context.Context, plan motionplan.Plan) (state.ExecuteResponse, error) {
+			if err := ctx.Err(); err != nil {
+				return state.ExecuteResponse{}, err
+			}
+			return state.ExecuteResponse{Replan: replanCount == 0, ReplanReason: replanReason}, nil
+		}}, nil
+	}
+
+	successPlanConstructor := func(
+		ctx context.Context,
+		_ motion.MoveOnGlobeReq,
+		_ motionplan.Plan,
+		_ int,
+	) (state.PlannerExecutor, error) {
+		return &testPlannerExecutor{}, nil
+	}
+
+	waitForCancelPlanConstructor := func(
+		ctx context.Context,
+		_ motion.MoveOnGlobeReq,
+		_ motionplan.Plan,
+		_ int,
+	) (state.PlannerExecutor, error) {
+		return &testPlannerExecutor{executeFunc: func(ctx context.Context, plan motionplan.Plan) (state.ExecuteResponse, error) {
+			<-ctx.Done()
+			return state.ExecuteResponse{}, ctx.Err()
+		}}, nil
+	}
+
+	waitForState := func(t *testing.T, s *state.State, planState motion.PlanState) {
+		t.Helper()
+		testutils.WaitForAssertion(t, func(tb testing.TB) {
+			tb.Helper()
+			ph, err := s.PlanHistory(motion.PlanHistoryReq{ComponentName: myBase, LastPlanOnly: true})
+			test.That(tb, err, test.ShouldBeNil)
+			test.That(tb, ph[0].StatusHistory[0].State, test.ShouldEqual, planState)
+		})
+	}
+
+	dir := t.TempDir()
+	crashDir := t.TempDir()
+
+	s, err := state.NewStateWithHistory(ttl, ttlCheckInterval, state.HistoryConfig{Dir: dir, MaxExecutions: 2}, logger)
+	test.That(t, err, test.ShouldBeNil)
+
+	replannedID, err := state.StartExecution(ctx, s, myBase, req, replanOncePlanConstructor)
+	test.That(t, err, test.ShouldBeNil)
+	waitForState(t, s, motion.PlanStateSucceeded)
+
+	succeededID, err := state.StartExecution(ctx, s, myBase, req, successPlanConstructor)
+	test.That(t, err, test.ShouldBeNil)
+	waitForState(t, s, motion.PlanStateSucceeded)
+
+	inProgressID, err := state.StartExecution(ctx, s, myBase, req, waitForCancelPlanConstructor)
+	test.That(t, err, test.ShouldBeNil)
+
+	// a copy of the log taken while an execution is in progress is what a crash would leave behind,
+	// along with a partially written last line.
+	log, err := os.ReadFile(filepath.Join(dir, state.HistoryFileName))
+	test.That(t, err, test.ShouldBeNil)
+	log = append(log, []byte(`{"plan":{"plan":`)...)
+	test.That(t, os.WriteFile(filepath.Join(crashDir, state.HistoryFileName), log, 0o600), test.ShouldBeNil)
+
+	s.Stop()
+
+	t.Run("restores executions after a restart", func(t *testing.T) {
+		s, err := state.NewStateWithHistory(ttl, ttlCheckInterval, state.HistoryConfig{Dir: dir, MaxExecutions: 2}, logger)
+		test.That(t, err, test.ShouldBeNil)
+		defer s.Stop()
+
+		ph, err := s.PlanHistory(motion.PlanHistoryReq{ComponentName: myBase})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, len(ph), test.ShouldEqual, 1)
+		test.That(t, ph[0].Plan.ExecutionID, test.ShouldEqual, inProgressID)
+		test.That(t, ph[0].StatusHistory[0].State, test.ShouldEqual, motion.PlanStateStopped)
+		test.That(t, ph[0].StatusHistory[1].State, test.ShouldEqual, motion.PlanStateInProgress)
+
+		ph, err = s.PlanHistory(motion.PlanHistoryReq{ComponentName: myBase, ExecutionID: succeededID})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, ph[0].StatusHistory[0].State, test.ShouldEqual, motion.PlanStateSucceeded)
+
+		// only the most recent executions are kept.
+		_, err = s.PlanHistory(motion.PlanHistoryReq{ComponentName: myBase, ExecutionID: replannedID})
+		test.That(t, err, test.ShouldNotBeNil)
+
+		statuses, err := s.ListPlanStatuses(motion.ListPlanStatusesReq{})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, len(statuses), test.ShouldEqual, 2)
+
+		// new executions are added to the restored ones.
+		newID, err := state.StartExecution(ctx, s, myBase, req, successPlanConstructor)
+		test.That(t, err, test.ShouldBeNil)
+		waitForState(t, s, motion.PlanStateSucceeded)
+		ph, err = s.PlanHistory(motion.PlanHistoryReq{ComponentName: myBase, ExecutionID: newID})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, len(ph), test.ShouldEqual, 1)
+	})
+
+	t.Run("marks interrupted executions as failed", func(t *testing.T) {
+		s, err := state.NewStateWithHistory(ttl, ttlCheckInterval, state.HistoryConfig{Dir: crashDir}, logger)
+		test.That(t, err, test.ShouldBeNil)
+		defer s.Stop()
+
+		ph, err := s.PlanHistory(motion.PlanHistoryReq{ComponentName: myBase})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, ph[0].Plan.ExecutionID, test.ShouldEqual, inProgressID)
+		test.That(t, ph[0].StatusHistory[0].State, test.ShouldEqual, motion.PlanStateFailed)
+		test.That(t, *ph[0].StatusHistory[0].Reason, test.ShouldContainSubstring, "interrupted")
+
+		statuses, err := s.ListPlanStatuses(motion.ListPlanStatusesReq{OnlyActivePlans: true})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, statuses, test.ShouldBeEmpty)
+
+		// replans keep their reasons.
+		ph, err = s.PlanHistory(motion.PlanHistoryReq{ComponentName: myBase, ExecutionID: replannedID})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, len(ph), test.ShouldEqual, 2)
+		test.That(t, ph[0].StatusHistory[0].State, test.ShouldEqual, motion.PlanStateSucceeded)
+		test.That(t, ph[1].StatusHistory[0].State, test.ShouldEqual, motion.PlanStateFailed)
+		test.That(t, *ph[1].StatusHistory[0].Reason, test.ShouldEqual, replanReason)
+	})
+	t.Run("drops statuses of plans which can't be restored", func(t *testing.T) {
+		// the replanned execution is logged as two plans and their statuses, so the fifth line is the plan of
+		// the succeeded execution. It can't be decoded, but its status can.
+		lines := bytes.SplitAfter(log, []byte("\n"))
+		lines[4] = []byte(`{"plan":{"unknown_field":true}}` + "\n")
+		corruptDir := t.TempDir()
+		test.That(t, os.WriteFile(filepath.Join(corruptDir, state.HistoryFileName), bytes.Join(lines, nil), 0o600), test.ShouldBeNil)
+
+		s, err := state.NewStateWithHistory(ttl, ttlCheckInterval, state.HistoryConfig{Dir: corruptDir}, logger)
+		test.That(t, err, test.ShouldBeNil)
+		defer s.Stop()
+
+		_, err = s.PlanHistory(motion.PlanHistoryReq{ComponentName: myBase, ExecutionID: succeededID})
+		test.That(t, err, test.ShouldNotBeNil)
+
+		ph, err := s.PlanHistory(motion.PlanHistoryReq{ComponentName: myBase, ExecutionID: replannedID})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, len(ph), test.ShouldEqual, 2)
+		ph, err = s.PlanHistory(motion.PlanHistoryReq{ComponentName: myBase})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, ph[0].Plan.ExecutionID, test.ShouldEqual, inProgressID)
+	})
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
+	"go.uber.org/multierr"
 	"go.viam.com/utils"
 	"golang.org/x/exp/maps"
 
@@ -35,6 +36,11 @@ type ExecuteResponse struct {
 	Replan bool
 	// Set if Replan is true, describes why replanning was triggered
 	ReplanReason string
+	// If true, the Execute function reached an intermediate goal of a multi-segment request
+	// & the caller should plan the next segment
+	NextSegment bool
+	// Set if NextSegment is true, describes which intermediate goal was reached
+	SegmentReason string
 }
 
 // PlannerExecutorConstructor creates a PlannerExecutor
@@ -160,12 +166,13 @@ func (e *execution[R]) start(ctx context.Context) error {
 		defer e.cancelFunc()
 
 		lastPWE := originalPlanWithExecutor
+		// Each segment of a multi-segment request is executed as its own plan within the execution.
 		// Exit conditions of this loop:
 		// 1. The execution's context was cancelled, which happens if the state's Stop() was called or
 		// StopExecutionByResource was called for this resource
 		// 2. the execution succeeded
 		// 3. the execution failed
-		// 4. replanning failed
+		// 4. replanning or planning the next segment failed
 		for {
 			resp, err := lastPWE.executor.Execute(e.cancelCtx, lastPWE.plan.Plan)
 
@@ -180,6 +187,22 @@ func (e *execution[R]) start(ctx context.Context) error {
 				e.notifyStatePlanFailed(lastPWE.plan, err.Error(), time.Now())
 				return
 
+			// intermediate goal reached
+			case resp.NextSegment:
+				newPWE, err := e.newPlanWithExecutor(e.cancelCtx, lastPWE.plan.Plan, replanCount)
+				if err != nil {
+					msg := "failed to plan next segment for execution %s and component: %s, " +
+						"after %s, tried setting previous plan %s " +
+						"to failed due to error: %s\n"
+					e.logger.CWarnf(ctx, msg, e.id, e.componentName, resp.SegmentReason, lastPWE.plan.ID, err.Error())
+
+					e.notifyStatePlanFailed(lastPWE.plan, err.Error(), time.Now())
+					return
+				}
+
+				e.notifyStateNextSegment(lastPWE.plan, resp.SegmentReason, newPWE.plan, time.Now())
+				lastPWE = newPWE
+
 			// success
 			case !resp.Replan:
 				e.notifyStatePlanSucceeded(lastPWE.plan, time.Now())
@@ -224,10 +247,12 @@ func (e *execution[R]) notifyStateNewExecution(execution stateExecution, plan mo
 	// NOTE: We hold the lock for both updateStateNewExecution & updateStateNewPlan to ensure no readers
 	// are able to see a state where the execution exists but does not have a plan with a status.
 	e.state.updateStateNewExecution(execution)
-	e.state.updateStateNewPlan(planMsg{
+	newPlan := planMsg{
 		plan:       plan,
 		planStatus: motion.PlanStatus{State: motion.PlanStateInProgress, Timestamp: time},
-	})
+	}
+	e.state.updateStateNewPlan(newPlan)
+	e.state.recordHistory(&newPlan, nil)
 }
 
 func (e *execution[R]) notifyStateReplan(lastPlan motion.PlanWithMetadata, reason string, newPlan motion.PlanWithMetadata, time time.Time) {
@@ -235,50 +260,82 @@ func (e *execution[R]) notifyStateReplan(lastPlan motion.PlanWithMetadata, reaso
 	defer e.state.mu.Unlock()
 	// NOTE: We hold the lock for both updateStateNewExecution & updateStateNewPlan to ensure no readers
 	// are able to see a state where the old plan is failed withou a new plan in progress during replanning
-	e.state.updateStateStatusUpdate(stateUpdateMsg{
+	update := stateUpdateMsg{
 		componentName: e.componentName,
 		executionID:   e.id,
 		planID:        lastPlan.ID,
 		planStatus:    motion.PlanStatus{State: motion.PlanStateFailed, Timestamp: time, Reason: &reason},
-	})
+	}
+	e.state.updateStateStatusUpdate(update)
 
-	e.state.updateStateNewPlan(planMsg{
+	replan := planMsg{
 		plan:       newPlan,
 		planStatus: motion.PlanStatus{State: motion.PlanStateInProgress, Timestamp: time},
-	})
+	}
+	e.state.updateStateNewPlan(replan)
+	e.state.recordHistory(&replan, &update)
+}
+
+func (e *execution[R]) notifyStateNextSegment(
+	lastPlan motion.PlanWithMetadata, reason string, newPlan motion.PlanWithMetadata, time time.Time,
+) {
+	e.state.mu.Lock()
+	defer e.state.mu.Unlock()
+	// NOTE: As with replanning, the lock is held for both updates so no reader sees the execution
+	// without a plan in progress between segments
+	update := stateUpdateMsg{
+		componentName: e.componentName,
+		executionID:   e.id,
+		planID:        lastPlan.ID,
+		planStatus:    motion.PlanStatus{State: motion.PlanStateSucceeded, Timestamp: time, Reason: &reason},
+	}
+	e.state.updateStateStatusUpdate(update)
+
+	next := planMsg{
+		plan:       newPlan,
+		planStatus: motion.PlanStatus{State: motion.PlanStateInProgress, Timestamp: time},
+	}
+	e.state.updateStateNewPlan(next)
+	e.state.recordHistory(&next, &update)
 }
 
 func (e *execution[R]) notifyStatePlanFailed(plan motion.PlanWithMetadata, reason string, time time.Time) {
 	e.state.mu.Lock()
 	defer e.state.mu.Unlock()
-	e.state.updateStateStatusUpdate(stateUpdateMsg{
+	update := stateUpdateMsg{
 		componentName: e.componentName,
 		executionID:   e.id,
 		planID:        plan.ID,
 		planStatus:    motion.PlanStatus{State: motion.PlanStateFailed, Timestamp: time, Reason: &reason},
-	})
+	}
+	e.state.updateStateStatusUpdate(update)
+	e.state.recordHistory(nil, &update)
 }
 
 func (e *execution[R]) notifyStatePlanSucceeded(plan motion.PlanWithMetadata, time time.Time) {
 	e.state.mu.Lock()
 	defer e.state.mu.Unlock()
-	e.state.updateStateStatusUpdate(stateUpdateMsg{
+	update := stateUpdateMsg{
 		componentName: e.componentName,
 		executionID:   e.id,
 		planID:        plan.ID,
 		planStatus:    motion.PlanStatus{State: motion.PlanStateSucceeded, Timestamp: time},
-	})
+	}
+	e.state.updateStateStatusUpdate(update)
+	e.state.recordHistory(nil, &update)
 }
 
 func (e *execution[R]) notifyStatePlanStopped(plan motion.PlanWithMetadata, time time.Time) {
 	e.state.mu.Lock()
 	defer e.state.mu.Unlock()
-	e.state.updateStateStatusUpdate(stateUpdateMsg{
+	update := stateUpdateMsg{
 		componentName: e.componentName,
 		executionID:   e.id,
 		planID:        plan.ID,
 		planStatus:    motion.PlanStatus{State: motion.PlanStateStopped, Timestamp: time},
-	})
+	}
+	e.state.updateStateStatusUpdate(update)
+	e.state.recordHistory(nil, &update)
 }
 
 // State is the state of the builtin motion service
@@ -289,6 +346,10 @@ type State struct {
 	cancelFunc context.CancelFunc
 	logger     logging.Logger
 	ttl        time.Duration
+	// maxExecutions is the maximum number of executions kept per component, zero if unlimited.
+	maxExecutions int
+	// history is nil unless the state was created with a history directory.
+	history *historyLog
 	// mu protects the componentStateByComponent
 	mu                        sync.RWMutex
 	componentStateByComponent map[resource.Name]componentState
@@ -302,6 +363,18 @@ func NewState(
 	ttl time.Duration,
 	ttlCheckInterval time.Duration,
 	logger logging.Logger,
+) (*State, error) {
+	return NewStateWithHistory(ttl, ttlCheckInterval, HistoryConfig{}, logger)
+}
+
+// NewStateWithHistory creates a new state which, if history.Dir is set, logs executions to disk
+// and restores the executions logged by a previous state. Executions which were still in progress
+// when the previous state stopped are restored as failed.
+func NewStateWithHistory(
+	ttl time.Duration,
+	ttlCheckInterval time.Duration,
+	history HistoryConfig,
+	logger logging.Logger,
 ) (*State, error) {
 	if ttl == 0 {
 		return nil, errors.New("TTL can't be unset")
@@ -326,8 +399,15 @@ func NewState(
 		waitGroup:                 &sync.WaitGroup{},
 		componentStateByComponent: make(map[resource.Name]componentState),
 		ttl:                       ttl,
+		maxExecutions:             history.MaxExecutions,
 		logger:                    logger,
 	}
+	if history.Dir != "" {
+		if err := s.restoreHistory(history.Dir); err != nil {
+			cancelFunc()
+			return nil, errors.Wrap(err, "failed to restore motion execution history")
+		}
+	}
 	s.waitGroup.Add(1)
 	utils.ManagedGo(func() {
 		ticker := time.NewTicker(ttlCheckInterval)
@@ -392,6 +472,11 @@ func StartExecution[R any](
 func (s *State) Stop() {
 	s.cancelFunc()
 	s.waitGroup.Wait()
+	if s.history != nil {
+		if err := s.history.close(); err != nil {
+			s.logger.Errorw("failed to close motion execution history", "error", err)
+		}
+	}
 }
 
 // StopExecutionByResource stops the active execution with a given resource name in the State.
@@ -590,7 +675,12 @@ func (s *State) updateStateStatusUpdate(update stateUpdateMsg) {
 		return
 	}
 	// copy the execution
-	execution := componentExecutions.executionsByID[update.executionID]
+	execution, exists := componentExecutions.executionsByID[update.executionID]
+	if !exists || len(execution.history) == 0 {
+		err := fmt.Errorf("updated execution %s doesn't exist", update.executionID)
+		s.logger.Error(err.Error())
+		return
+	}
 	lastPlanWithStatus := execution.history[0]
 	if lastPlanWithStatus.Plan.ID != update.planID {
 		err := fmt.Errorf("status update for plan %s is not for last plan: %s", update.planID, lastPlanWithStatus.Plan.ID)
@@ -623,18 +713,39 @@ func (s *State) activeExecution(name resource.Name) (stateExecution, error) {
 
 func (s *State) purgeOlderThanTTL() error {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	purged, err := s.purge()
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if purged && s.history != nil {
+		return s.compactHistory()
+	}
+	return nil
+}
 
+// purge drops executions older than the TTL, or beyond the most recent maxExecutions, and returns whether
+// any were dropped. s.mu must be held.
+func (s *State) purge() (bool, error) {
 	purgeCutoff := time.Now().Add(-s.ttl)
+	purged := false
 
 	for resource, componentState := range s.componentStateByComponent {
 		keepIndex, err := findKeepIndex(componentState, purgeCutoff)
 		if err != nil {
-			return err
+			return false, err
 		}
 		// If there are no executions to keep, then delete the resource.
 		if keepIndex == -1 {
 			delete(s.componentStateByComponent, resource)
+			purged = true
+			continue
+		}
+		// Only the most recent execution can be in progress, so older executions can always be dropped.
+		if s.maxExecutions > 0 && keepIndex >= s.maxExecutions {
+			keepIndex = s.maxExecutions - 1
+		}
+		if keepIndex+1 == len(componentState.executionIDHistory) {
 			continue
 		}
 
@@ -646,10 +757,117 @@ func (s *State) purgeOlderThanTTL() error {
 		}
 		componentState.executionIDHistory = executionIDsToKeep
 		s.componentStateByComponent[resource] = componentState
+		purged = true
+	}
+	return purged, nil
+}
+
+// compactHistory rewrites the history log from the executions held in the state. The state is only locked
+// while the records are taken from it; appends wait for the log to be replaced so none of them are lost.
+func (s *State) compactHistory() error {
+	s.mu.Lock()
+	records, err := historyRecords(s.componentStateByComponent)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.history.mu.Lock()
+	s.mu.Unlock()
+	defer s.history.mu.Unlock()
+	return s.history.rewrite(records)
+}
+
+// restoreHistory replays the execution history log in dir into the state, then compacts the log.
+func (s *State) restoreHistory(dir string) error {
+	history, records, err := openHistoryLog(dir, s.logger)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	for _, rec := range records {
+		newPlan, update, err := rec.decode()
+		if err != nil {
+			s.logger.Warnw("skipping invalid motion execution history record", "error", err)
+			continue
+		}
+		if newPlan != nil {
+			cs, exists := s.componentStateByComponent[newPlan.plan.ComponentName]
+			if _, known := cs.executionsByID[newPlan.plan.ExecutionID]; !exists || !known {
+				s.updateStateNewExecution(stateExecution{
+					id:            newPlan.plan.ExecutionID,
+					componentName: newPlan.plan.ComponentName,
+					waitGroup:     &sync.WaitGroup{},
+					cancelFunc:    func() {},
+				})
+			}
+			s.updateStateNewPlan(*newPlan)
+			continue
+		}
+		// the plan of the execution may have been skipped above, in which case its statuses can't be applied.
+		cs, exists := s.componentStateByComponent[update.componentName]
+		if ex, known := cs.executionsByID[update.executionID]; !exists || !known || len(ex.history) == 0 {
+			s.logger.Warnw("dropping motion execution history status of unknown execution",
+				"component", update.componentName, "execution_id", update.executionID)
+			continue
+		}
+		s.updateStateStatusUpdate(*update)
+	}
+
+	// nothing is executing yet, so anything still in progress was cut short by a restart.
+	reason := interruptedReason
+	now := time.Now()
+	for name, cs := range s.componentStateByComponent {
+		ex := cs.lastExecution()
+		if ex.history[0].StatusHistory[0].State != motion.PlanStateInProgress {
+			continue
+		}
+		s.updateStateStatusUpdate(stateUpdateMsg{
+			componentName: name,
+			executionID:   ex.id,
+			planID:        ex.history[0].Plan.ID,
+			planStatus:    motion.PlanStatus{State: motion.PlanStateFailed, Timestamp: now, Reason: &reason},
+		})
+	}
+	s.history = history
+	_, err = s.purge()
+	s.mu.Unlock()
+	if err != nil {
+		return multierr.Combine(err, history.close())
+	}
+	// the log is compacted even if nothing was purged, to drop the records which could not be replayed.
+	if err := s.compactHistory(); err != nil {
+		return multierr.Combine(err, history.close())
 	}
 	return nil
 }
 
+// recordHistory appends a new plan and/or a status update to the execution history log, if there is one.
+func (s *State) recordHistory(newPlan *planMsg, update *stateUpdateMsg) {
+	if s.history == nil {
+		return
+	}
+	var records []historyRecord
+	if update != nil {
+		rec, err := statusRecord(*update)
+		if err != nil {
+			s.logger.Errorw("failed to encode motion execution history", "error", err)
+			return
+		}
+		records = append(records, rec)
+	}
+	if newPlan != nil {
+		rec, err := planRecord(newPlan.plan, newPlan.planStatus)
+		if err != nil {
+			s.logger.Errorw("failed to encode motion execution history", "error", err)
+			return
+		}
+		records = append(records, rec)
+	}
+	if err := s.history.append(records...); err != nil {
+		s.logger.Errorw("failed to write motion execution history", "error", err)
+	}
+}
+
 // findKeepIndex returns the index of the executionHistory slice which should be kept
 // after purging i.e. are after the purgeCutoff
 // returns -1 if none of the executions are after the cutoff i.e. if all need to be purged.
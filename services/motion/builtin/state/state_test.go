@@ -9,6 +9,7 @@ import (
 
 	"github.com/google/uuid"
 	"go.viam.com/test"
+	"go.viam.com/utils/testutils"
 
 	"go.viam.com/rdk/components/base"
 	"go.viam.com/rdk/logging"
@@ -770,6 +771,49 @@ func TestState(t *testing.T) {
 	// NOTE: This test is slow b/c it is testing TTL behavior (which is inherently time based)
 	// the TTL is inteinionally configured to be a very high number to decrease the risk of flakeyness on
 	// low powered or over utilized hardware.
+	t.Run("multi-segment executions have one plan per segment", func(t *testing.T) {
+		t.Parallel()
+		s, err := state.NewState(ttl, ttlCheckInterval, logger)
+		test.That(t, err, test.ShouldBeNil)
+		defer s.Stop()
+
+		segmentReason := "reached via-point 1 of 1"
+		var segment int
+		executionID, err := state.StartExecution(ctx, s, emptyReq.ComponentName, emptyReq, func(
+			ctx context.Context,
+			req motion.MoveOnGlobeReq,
+			seedplan motionplan.Plan,
+			replanCount int,
+		) (state.PlannerExecutor, error) {
+			return &testPlannerExecutor{
+				executeFunc: func(ctx context.Context, plan motionplan.Plan) (state.ExecuteResponse, error) {
+					if segment == 0 {
+						segment++
+						return state.ExecuteResponse{NextSegment: true, SegmentReason: segmentReason}, nil
+					}
+					return state.ExecuteResponse{}, nil
+				},
+			}, nil
+		})
+		test.That(t, err, test.ShouldBeNil)
+
+		var ph []motion.PlanWithStatus
+		testutils.WaitForAssertion(t, func(tb testing.TB) {
+			tb.Helper()
+			ph, err = s.PlanHistory(motion.PlanHistoryReq{ComponentName: myBase})
+			test.That(tb, err, test.ShouldBeNil)
+			test.That(tb, len(ph), test.ShouldEqual, 2)
+			test.That(tb, ph[0].StatusHistory[0].State, test.ShouldEqual, motion.PlanStateSucceeded)
+		})
+		test.That(t, ph[0].Plan.ExecutionID, test.ShouldEqual, executionID)
+		test.That(t, ph[0].StatusHistory[0].Reason, test.ShouldBeNil)
+		test.That(t, ph[1].Plan.ExecutionID, test.ShouldEqual, executionID)
+		test.That(t, len(ph[1].StatusHistory), test.ShouldEqual, 2)
+		test.That(t, ph[1].StatusHistory[0].State, test.ShouldEqual, motion.PlanStateSucceeded)
+		test.That(t, *ph[1].StatusHistory[0].Reason, test.ShouldEqual, segmentReason)
+		test.That(t, ph[1].StatusHistory[1].State, test.ShouldEqual, motion.PlanStateInProgress)
+	})
+
 	t.Run("ttl", func(t *testing.T) {
 		t.Parallel()
 		ttl := time.Millisecond * 250
@@ -151,7 +151,7 @@ func (c *client) ListPlanStatuses(ctx context.Context, req ListPlanStatusesReq)
 	}
 	pswids := make([]PlanStatusWithID, 0, len(resp.PlanStatusesWithIds))
 	for _, status := range resp.PlanStatusesWithIds {
-		pswid, err := planStatusWithIDFromProto(status)
+		pswid, err := PlanStatusWithIDFromProto(status)
 		if err != nil {
 			return nil, err
 		}
@@ -175,13 +175,13 @@ func (c *client) PlanHistory(
 	}
 	statusHistory := make([]PlanWithStatus, 0, len(resp.ReplanHistory))
 	for _, status := range resp.ReplanHistory {
-		s, err := planWithStatusFromProto(status)
+		s, err := PlanWithStatusFromProto(status)
 		if err != nil {
 			return nil, err
 		}
 		statusHistory = append(statusHistory, s)
 	}
-	pws, err := planWithStatusFromProto(resp.CurrentPlanWithStatus)
+	pws, err := PlanWithStatusFromProto(resp.CurrentPlanWithStatus)
 	if err != nil {
 		return nil, err
 	}
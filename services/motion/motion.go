@@ -73,13 +73,16 @@ type MoveOnGlobeReq struct {
 	BoundingRegions []*spatialmath.GeoGeometry
 	// Optional motion configuration
 	MotionCfg *MotionConfiguration
+	// Optional points the component should pass through, in order, before moving to the Destination.
+	// The route is driven as a single execution with one plan per segment.
+	ViaPoints []GeoViaPoint
 	Extra     map[string]interface{}
 }
 
 func (r MoveOnGlobeReq) String() string {
 	template := "motion.MoveOnGlobeReq{ComponentName: %s, " +
 		"Destination: %+v, Heading: %f, MovementSensorName: %s, " +
-		"Obstacles: %v, BoundingRegions: %v, MotionCfg: %#v, Extra: %s}"
+		"Obstacles: %v, BoundingRegions: %v, MotionCfg: %#v, ViaPoints: %v, Extra: %s}"
 	return fmt.Sprintf(template,
 		r.ComponentName,
 		r.Destination,
@@ -88,9 +91,30 @@ func (r MoveOnGlobeReq) String() string {
 		r.Obstacles,
 		r.BoundingRegions,
 		r.MotionCfg,
+		r.ViaPoints,
 		r.Extra)
 }
 
+// GeoViaPoint is an intermediate goal of a MoveOnGlobe request.
+type GeoViaPoint struct {
+	Point *geo.Point
+	// Optional heading the component should have when it reaches the point.
+	// Range [0-360] Left Hand Rule (N: 0, E: 90, S: 180, W: 270)
+	// When nil only the position of the component is checked.
+	Heading *float64
+	// Optional distance from the point, in millimeters, at which the point is reached.
+	// Defaults to the PlanDeviationMM of the request when zero.
+	ToleranceMM float64
+}
+
+func (p GeoViaPoint) String() string {
+	heading := "nil"
+	if p.Heading != nil {
+		heading = fmt.Sprintf("%f", *p.Heading)
+	}
+	return fmt.Sprintf("motion.GeoViaPoint{Point: %+v, Heading: %s, ToleranceMM: %f}", p.Point, heading, p.ToleranceMM)
+}
+
 // MoveOnMapReq describes a request to MoveOnMap.
 type MoveOnMapReq struct {
 	ComponentName resource.Name
@@ -98,21 +122,40 @@ type MoveOnMapReq struct {
 	SlamName      resource.Name
 	MotionCfg     *MotionConfiguration
 	Obstacles     []spatialmath.Geometry
-	Extra         map[string]interface{}
+	// Optional poses the component should pass through, in order, before moving to the Destination.
+	// The route is driven as a single execution with one plan per segment.
+	ViaPoses []ViaPose
+	Extra    map[string]interface{}
 }
 
 func (r MoveOnMapReq) String() string {
 	return fmt.Sprintf(
 		"motion.MoveOnMapReq{ComponentName: %s, SlamName: %s, Destination: %+v, "+
-			"MotionCfg: %#v, Obstacles: %s, Extra: %s}",
+			"MotionCfg: %#v, Obstacles: %s, ViaPoses: %v, Extra: %s}",
 		r.ComponentName,
 		r.SlamName,
 		spatialmath.PoseToProtobuf(r.Destination),
 		r.MotionCfg,
 		r.Obstacles,
+		r.ViaPoses,
 		r.Extra)
 }
 
+// ViaPose is an intermediate goal of a MoveOnMap request, with respect to the SLAM map's origin.
+type ViaPose struct {
+	Pose spatialmath.Pose
+	// When true only the position of the component is checked, not its orientation.
+	PositionOnly bool
+	// Optional distance from the pose, in millimeters, at which the pose is reached.
+	// Defaults to the PlanDeviationMM of the request when zero.
+	ToleranceMM float64
+}
+
+func (p ViaPose) String() string {
+	return fmt.Sprintf("motion.ViaPose{Pose: %+v, PositionOnly: %t, ToleranceMM: %f}",
+		spatialmath.PoseToProtobuf(p.Pose), p.PositionOnly, p.ToleranceMM)
+}
+
 // StopPlanReq describes the request to StopPlan().
 type StopPlanReq struct {
 	// ComponentName of the plan which should be stopped
@@ -75,7 +75,7 @@ func TestPlanWithStatus(t *testing.T) {
 		},
 	}
 
-	t.Run("planWithStatusFromProto", func(t *testing.T) {
+	t.Run("PlanWithStatusFromProto", func(t *testing.T) {
 		type testCase struct {
 			description string
 			input       *pb.PlanWithStatus
@@ -150,7 +150,7 @@ func TestPlanWithStatus(t *testing.T) {
 		}
 		for _, tc := range testCases {
 			t.Run(tc.description, func(t *testing.T) {
-				res, err := planWithStatusFromProto(tc.input)
+				res, err := PlanWithStatusFromProto(tc.input)
 				if tc.err != nil {
 					test.That(t, err, test.ShouldBeError, tc.err)
 				} else {
@@ -287,7 +287,7 @@ func TestPlanState(t *testing.T) {
 }
 
 func TestPlanStatusWithID(t *testing.T) {
-	t.Run("planStatusWithIDFromProto", func(t *testing.T) {
+	t.Run("PlanStatusWithIDFromProto", func(t *testing.T) {
 		type testCase struct {
 			description string
 			input       *pb.PlanStatusWithID
@@ -366,7 +366,7 @@ func TestPlanStatusWithID(t *testing.T) {
 		}
 		for _, tc := range testCases {
 			t.Run(tc.description, func(t *testing.T) {
-				res, err := planStatusWithIDFromProto(tc.input)
+				res, err := PlanStatusWithIDFromProto(tc.input)
 				if tc.err != nil {
 					test.That(t, err, test.ShouldBeError, tc.err)
 				} else {
@@ -1061,6 +1061,35 @@ func TestMoveOnGlobeReq(t *testing.T) {
 			test.That(t, math.IsNaN(res.Heading), test.ShouldBeTrue)
 		})
 	})
+
+	t.Run("via-points are sent in extra", func(t *testing.T) {
+		heading := 90.
+		mogReq := validMoveOnGlobeRequest()
+		mogReq.Extra = map[string]interface{}{"motion_profile": "position_only"}
+		mogReq.ViaPoints = []GeoViaPoint{
+			{Point: geo.NewPoint(1, 1), Heading: &heading, ToleranceMM: 100},
+			{Point: geo.NewPoint(1, 1.5)},
+		}
+		req, err := mogReq.toProto(name)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, req.Extra.AsMap()["via_points"], test.ShouldNotBeNil)
+		// the caller's extra is left untouched
+		test.That(t, mogReq.Extra, test.ShouldResemble, map[string]interface{}{"motion_profile": "position_only"})
+
+		res, err := moveOnGlobeRequestFromProto(req)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, res.Extra, test.ShouldResemble, mogReq.Extra)
+		test.That(t, res.ViaPoints, test.ShouldResemble, mogReq.ViaPoints)
+
+		mogReq.ViaPoints = []GeoViaPoint{{}}
+		_, err = mogReq.toProto(name)
+		test.That(t, err, test.ShouldBeError, errors.New("via-point 0 must provide a point"))
+
+		req.Extra, err = structpb.NewStruct(map[string]interface{}{"via_points": []interface{}{map[string]interface{}{"latitude": 1.}}})
+		test.That(t, err, test.ShouldBeNil)
+		_, err = moveOnGlobeRequestFromProto(req)
+		test.That(t, err, test.ShouldBeError, errors.New("via-point 0 must provide a latitude and longitude"))
+	})
 }
 
 func TestMoveOnMapReq(t *testing.T) {
@@ -1108,12 +1137,13 @@ func TestMoveOnMapReq(t *testing.T) {
 	t.Run("String()", func(t *testing.T) {
 		s := fmt.Sprintf(
 			"motion.MoveOnMapReq{ComponentName: %s, SlamName: %s, Destination: %+v, "+
-				"MotionCfg: %#v, Obstacles: %s, Extra: %s}",
+				"MotionCfg: %#v, Obstacles: %s, ViaPoses: %v, Extra: %s}",
 			validMoveOnMapReq.ComponentName,
 			validMoveOnMapReq.SlamName,
 			spatialmath.PoseToProtobuf(validMoveOnMapReq.Destination),
 			validMoveOnMapReq.MotionCfg,
 			validMoveOnMapReq.Obstacles,
+			validMoveOnMapReq.ViaPoses,
 			validMoveOnMapReq.Extra)
 		test.That(t, validMoveOnMapReq.String(), test.ShouldEqual, s)
 	})
@@ -1306,6 +1336,31 @@ func TestMoveOnMapReq(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("via-poses are sent in extra", func(t *testing.T) {
+		momReq := validMoveOnMapReq
+		momReq.ViaPoses = []ViaPose{
+			{Pose: spatialmath.NewPose(r3.Vector{X: 1000, Y: 2000}, &spatialmath.OrientationVectorDegrees{OZ: 1, Theta: 90})},
+			{Pose: spatialmath.NewPoseFromPoint(r3.Vector{X: 3000}), PositionOnly: true, ToleranceMM: 50},
+		}
+		req, err := momReq.toProto("bloop")
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, req.Extra.AsMap()["via_poses"], test.ShouldNotBeNil)
+
+		res, err := moveOnMapRequestFromProto(req)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, res.Extra, test.ShouldBeEmpty)
+		test.That(t, len(res.ViaPoses), test.ShouldEqual, 2)
+		for i, viaPose := range res.ViaPoses {
+			test.That(t, spatialmath.PoseAlmostEqual(viaPose.Pose, momReq.ViaPoses[i].Pose), test.ShouldBeTrue)
+			test.That(t, viaPose.PositionOnly, test.ShouldEqual, momReq.ViaPoses[i].PositionOnly)
+			test.That(t, viaPose.ToleranceMM, test.ShouldEqual, momReq.ViaPoses[i].ToleranceMM)
+		}
+
+		momReq.ViaPoses = []ViaPose{{}}
+		_, err = momReq.toProto("bloop")
+		test.That(t, err, test.ShouldBeError, errors.New("via-pose 0 must provide a pose"))
+	})
 }
 
 func TestPlanHistoryReq(t *testing.T) {
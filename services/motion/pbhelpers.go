@@ -52,8 +52,8 @@ func MoveReqFromProto(req *pb.MoveRequest) (MoveReq, error) {
 	}, nil
 }
 
-// planWithStatusFromProto converts a *pb.PlanWithStatus to a PlanWithStatus.
-func planWithStatusFromProto(pws *pb.PlanWithStatus) (PlanWithStatus, error) {
+// PlanWithStatusFromProto converts a *pb.PlanWithStatus to a PlanWithStatus.
+func PlanWithStatusFromProto(pws *pb.PlanWithStatus) (PlanWithStatus, error) {
 	if pws == nil {
 		return PlanWithStatus{}, errors.New("received nil *pb.PlanWithStatus")
 	}
@@ -96,8 +96,8 @@ func planStatusFromProto(ps *pb.PlanStatus) (PlanStatus, error) {
 	}, nil
 }
 
-// planStatusWithIDFromProto converts a *pb.PlanStatus to a PlanStatus.
-func planStatusWithIDFromProto(ps *pb.PlanStatusWithID) (PlanStatusWithID, error) {
+// PlanStatusWithIDFromProto converts a *pb.PlanStatusWithID to a PlanStatusWithID.
+func PlanStatusWithIDFromProto(ps *pb.PlanStatusWithID) (PlanStatusWithID, error) {
 	if ps == nil {
 		return PlanStatusWithID{}, errors.New("received nil *pb.PlanStatusWithID")
 	}
@@ -191,7 +191,11 @@ func planStateFromProto(ps pb.PlanState) PlanState {
 
 // toProto converts a MoveOnGlobeRequest to a *pb.MoveOnGlobeRequest.
 func (r MoveOnGlobeReq) toProto(name string) (*pb.MoveOnGlobeRequest, error) {
-	ext, err := vprotoutils.StructToStructPb(r.Extra)
+	extra, err := viaPointsToExtra(r.Extra, r.ViaPoints)
+	if err != nil {
+		return nil, err
+	}
+	ext, err := vprotoutils.StructToStructPb(extra)
 	if err != nil {
 		return nil, err
 	}
@@ -279,6 +283,10 @@ func moveOnGlobeRequestFromProto(req *pb.MoveOnGlobeRequest) (MoveOnGlobeReq, er
 	}
 	movementSensorName := rprotoutils.ResourceNameFromProto(protoMovementSensorName)
 	motionCfg := configurationFromProto(req.MotionConfiguration)
+	viaPoints, extra, err := viaPointsFromExtra(req.Extra.AsMap())
+	if err != nil {
+		return MoveOnGlobeReq{}, err
+	}
 
 	return MoveOnGlobeReq{
 		ComponentName:      componentName,
@@ -288,7 +296,8 @@ func moveOnGlobeRequestFromProto(req *pb.MoveOnGlobeRequest) (MoveOnGlobeReq, er
 		Obstacles:          obstacles,
 		MotionCfg:          motionCfg,
 		BoundingRegions:    boundingRegionGeometries,
-		Extra:              req.Extra.AsMap(),
+		ViaPoints:          viaPoints,
+		Extra:              extra,
 	}, nil
 }
 
@@ -357,18 +366,27 @@ func moveOnMapRequestFromProto(req *pb.MoveOnMapRequest) (MoveOnMapReq, error) {
 		}
 		geoms = convertedGeom
 	}
+	viaPoses, extra, err := viaPosesFromExtra(req.Extra.AsMap())
+	if err != nil {
+		return MoveOnMapReq{}, err
+	}
 	return MoveOnMapReq{
 		ComponentName: rprotoutils.ResourceNameFromProto(protoComponentName),
 		Destination:   spatialmath.NewPoseFromProtobuf(req.GetDestination()),
 		SlamName:      rprotoutils.ResourceNameFromProto(protoSlamServiceName),
 		MotionCfg:     configurationFromProto(req.MotionConfiguration),
 		Obstacles:     geoms,
-		Extra:         req.Extra.AsMap(),
+		ViaPoses:      viaPoses,
+		Extra:         extra,
 	}, nil
 }
 
 func (r MoveOnMapReq) toProto(name string) (*pb.MoveOnMapRequest, error) {
-	ext, err := vprotoutils.StructToStructPb(r.Extra)
+	extra, err := viaPosesToExtra(r.Extra, r.ViaPoses)
+	if err != nil {
+		return nil, err
+	}
+	ext, err := vprotoutils.StructToStructPb(extra)
 	if err != nil {
 		return nil, err
 	}
@@ -390,3 +408,151 @@ func (r MoveOnMapReq) toProto(name string) (*pb.MoveOnMapRequest, error) {
 
 	return req, nil
 }
+
+// The MoveOnGlobe and MoveOnMap requests have no fields for via-points, so they are sent in extra
+// under these keys.
+const (
+	viaPointsExtraKey = "via_points"
+	viaPosesExtraKey  = "via_poses"
+)
+
+// viaPointsToExtra returns a copy of extra which holds the via-points.
+func viaPointsToExtra(extra map[string]interface{}, viaPoints []GeoViaPoint) (map[string]interface{}, error) {
+	if len(viaPoints) == 0 {
+		return extra, nil
+	}
+	encoded := make([]interface{}, 0, len(viaPoints))
+	for i, p := range viaPoints {
+		if p.Point == nil {
+			return nil, errors.Errorf("via-point %d must provide a point", i)
+		}
+		point := map[string]interface{}{
+			"latitude":     p.Point.Lat(),
+			"longitude":    p.Point.Lng(),
+			"tolerance_mm": p.ToleranceMM,
+		}
+		if p.Heading != nil {
+			point["heading"] = *p.Heading
+		}
+		encoded = append(encoded, point)
+	}
+	return withExtraKey(extra, viaPointsExtraKey, encoded), nil
+}
+
+// viaPointsFromExtra returns the via-points held by extra and extra without them.
+func viaPointsFromExtra(extra map[string]interface{}) ([]GeoViaPoint, map[string]interface{}, error) {
+	raw, ok := extra[viaPointsExtraKey]
+	if !ok {
+		return nil, extra, nil
+	}
+	encoded, ok := raw.([]interface{})
+	if !ok {
+		return nil, nil, errors.Errorf("could not interpret %s field as a list", viaPointsExtraKey)
+	}
+	viaPoints := make([]GeoViaPoint, 0, len(encoded))
+	for i, e := range encoded {
+		point, ok := e.(map[string]interface{})
+		if !ok {
+			return nil, nil, errors.Errorf("could not interpret via-point %d as an object", i)
+		}
+		lat, latOK := point["latitude"].(float64)
+		lng, lngOK := point["longitude"].(float64)
+		if !latOK || !lngOK {
+			return nil, nil, errors.Errorf("via-point %d must provide a latitude and longitude", i)
+		}
+		viaPoint := GeoViaPoint{Point: geo.NewPoint(lat, lng)}
+		if heading, ok := point["heading"].(float64); ok {
+			viaPoint.Heading = &heading
+		}
+		viaPoint.ToleranceMM, _ = point["tolerance_mm"].(float64)
+		viaPoints = append(viaPoints, viaPoint)
+	}
+	return viaPoints, withoutExtraKey(extra, viaPointsExtraKey), nil
+}
+
+// viaPosesToExtra returns a copy of extra which holds the via-poses.
+func viaPosesToExtra(extra map[string]interface{}, viaPoses []ViaPose) (map[string]interface{}, error) {
+	if len(viaPoses) == 0 {
+		return extra, nil
+	}
+	encoded := make([]interface{}, 0, len(viaPoses))
+	for i, p := range viaPoses {
+		if p.Pose == nil {
+			return nil, errors.Errorf("via-pose %d must provide a pose", i)
+		}
+		pose := spatialmath.PoseToProtobuf(p.Pose)
+		encoded = append(encoded, map[string]interface{}{
+			"pose": map[string]interface{}{
+				"x":     pose.X,
+				"y":     pose.Y,
+				"z":     pose.Z,
+				"o_x":   pose.OX,
+				"o_y":   pose.OY,
+				"o_z":   pose.OZ,
+				"theta": pose.Theta,
+			},
+			"position_only": p.PositionOnly,
+			"tolerance_mm":  p.ToleranceMM,
+		})
+	}
+	return withExtraKey(extra, viaPosesExtraKey, encoded), nil
+}
+
+// viaPosesFromExtra returns the via-poses held by extra and extra without them.
+func viaPosesFromExtra(extra map[string]interface{}) ([]ViaPose, map[string]interface{}, error) {
+	raw, ok := extra[viaPosesExtraKey]
+	if !ok {
+		return nil, extra, nil
+	}
+	encoded, ok := raw.([]interface{})
+	if !ok {
+		return nil, nil, errors.Errorf("could not interpret %s field as a list", viaPosesExtraKey)
+	}
+	viaPoses := make([]ViaPose, 0, len(encoded))
+	for i, e := range encoded {
+		viaPose, ok := e.(map[string]interface{})
+		if !ok {
+			return nil, nil, errors.Errorf("could not interpret via-pose %d as an object", i)
+		}
+		pose, ok := viaPose["pose"].(map[string]interface{})
+		if !ok {
+			return nil, nil, errors.Errorf("via-pose %d must provide a pose", i)
+		}
+		field := func(name string) float64 {
+			v, _ := pose[name].(float64)
+			return v
+		}
+		p := ViaPose{Pose: spatialmath.NewPoseFromProtobuf(&commonpb.Pose{
+			X:     field("x"),
+			Y:     field("y"),
+			Z:     field("z"),
+			OX:    field("o_x"),
+			OY:    field("o_y"),
+			OZ:    field("o_z"),
+			Theta: field("theta"),
+		})}
+		p.PositionOnly, _ = viaPose["position_only"].(bool)
+		p.ToleranceMM, _ = viaPose["tolerance_mm"].(float64)
+		viaPoses = append(viaPoses, p)
+	}
+	return viaPoses, withoutExtraKey(extra, viaPosesExtraKey), nil
+}
+
+func withExtraKey(extra map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(extra)+1)
+	for k, v := range extra {
+		out[k] = v
+	}
+	out[key] = value
+	return out
+}
+
+func withoutExtraKey(extra map[string]interface{}, key string) map[string]interface{} {
+	out := make(map[string]interface{}, len(extra))
+	for k, v := range extra {
+		if k != key {
+			out[k] = v
+		}
+	}
+	return out
+}
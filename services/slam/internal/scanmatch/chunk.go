@@ -0,0 +1,19 @@
+package scanmatch
+
+import "io"
+
+// ChunkSizeBytes is the size of the chunks maps and internal states are streamed in.
+const ChunkSizeBytes = 1 * 1024 * 1024
+
+// Chunked returns a callback returning data in chunks, and io.EOF once it has all been returned.
+func Chunked(data []byte) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		if len(data) == 0 {
+			return nil, io.EOF
+		}
+		n := min(ChunkSizeBytes, len(data))
+		chunk := data[:n]
+		data = data[n:]
+		return chunk, nil
+	}
+}
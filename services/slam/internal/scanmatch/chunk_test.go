@@ -0,0 +1,29 @@
+package scanmatch
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestChunked(t *testing.T) {
+	data := bytes.Repeat([]byte{1, 2, 3}, ChunkSizeBytes)
+	next := Chunked(data)
+	var got []byte
+	for chunks := 0; ; chunks++ {
+		chunk, err := next()
+		if err != nil {
+			test.That(t, err, test.ShouldEqual, io.EOF)
+			test.That(t, chunks, test.ShouldEqual, 3)
+			break
+		}
+		test.That(t, len(chunk), test.ShouldBeLessThanOrEqualTo, ChunkSizeBytes)
+		got = append(got, chunk...)
+	}
+	test.That(t, got, test.ShouldResemble, data)
+
+	_, err := Chunked(nil)()
+	test.That(t, err, test.ShouldEqual, io.EOF)
+}
@@ -0,0 +1,230 @@
+package scanmatch
+
+import (
+	"math"
+
+	"github.com/golang/geo/r3"
+	"github.com/pkg/errors"
+	"gonum.org/v1/gonum/mat"
+)
+
+const (
+	defaultMaxIterations = 30
+	// ICP stops once an iteration moves the scan by less than this.
+	convergenceMM  = 0.1
+	convergenceRad = 1e-4
+	// fewer matched points than this cannot constrain a planar pose.
+	minCorrespondences = 3
+	// the correlative search only scores this many points of a scan, spread evenly across it.
+	maxSearchPoints = 100
+	// relative damping of the normal equations of a point-to-line ICP step.
+	damping = 1e-6
+)
+
+// ErrTooFewCorrespondences is returned when too few points of a scan are close enough to the target to match it.
+var ErrTooFewCorrespondences = errors.New("too few points of the scan are close to the points matched against")
+
+// SearchWindow is the region around the initial guess that is searched exhaustively before refining the match.
+// The zero value does not search, which is enough when the initial guess is already close.
+type SearchWindow struct {
+	LinearMM       float64
+	LinearStepMM   float64
+	AngularRad     float64
+	AngularStepRad float64
+}
+
+// MatchOptions configure a scan match.
+type MatchOptions struct {
+	// MaxCorrespondenceMM is the furthest a scan point can be from a target point to be matched with it.
+	// It is capped by the distance the target index was created for.
+	MaxCorrespondenceMM float64
+	MaxIterations       int
+	Window              SearchWindow
+}
+
+// Result is the outcome of a scan match.
+type Result struct {
+	Pose Pose2
+	// Fitness is the fraction of the scan points matched with a target point.
+	Fitness float64
+	// RMSE is the root mean square distance between the matched points, in millimeters.
+	RMSE       float64
+	Iterations int
+}
+
+// Match registers a scan against the points in target, returning the pose of the scan in the frame of target.
+// It first scores every pose of the search window around initial by how many scan points it lands near a
+// target point, as a correlative scan matcher does, then refines the best of them with point-to-point ICP.
+func Match(scan Scan, target *Index, initial Pose2, opts MatchOptions) (Result, error) {
+	if len(scan) < minCorrespondences {
+		return Result{}, ErrTooFewCorrespondences
+	}
+	maxDist := opts.MaxCorrespondenceMM
+	if maxDist <= 0 || maxDist > target.MaxDistance() {
+		maxDist = target.MaxDistance()
+	}
+	maxIterations := opts.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = defaultMaxIterations
+	}
+
+	pose := correlativeSearch(scan, target, initial, maxDist, opts.Window)
+	iterations := 0
+	for ; iterations < maxIterations; iterations++ {
+		delta, err := icpStep(scan, target, pose, maxDist)
+		if err != nil {
+			return Result{}, err
+		}
+		pose = delta.Compose(pose)
+		if math.Hypot(delta.X, delta.Y) < convergenceMM && math.Abs(delta.Theta) < convergenceRad {
+			iterations++
+			break
+		}
+	}
+
+	fitness, rmse := Score(scan, target, pose, maxDist)
+	return Result{Pose: pose, Fitness: fitness, RMSE: rmse, Iterations: iterations}, nil
+}
+
+// Score returns the fraction of the points of scan, placed at pose, within maxDistanceMM of a target point
+// and the root mean square distance of those points to their nearest target point.
+func Score(scan Scan, target *Index, pose Pose2, maxDistanceMM float64) (float64, float64) {
+	if len(scan) == 0 {
+		return 0, 0
+	}
+	matched := 0
+	sumSq := 0.
+	for _, p := range scan {
+		if _, d, ok := target.Nearest(pose.Apply(p), maxDistanceMM); ok {
+			matched++
+			sumSq += d * d
+		}
+	}
+	if matched == 0 {
+		return 0, 0
+	}
+	return float64(matched) / float64(len(scan)), math.Sqrt(sumSq / float64(matched))
+}
+
+func correlativeSearch(scan Scan, target *Index, initial Pose2, maxDist float64, window SearchWindow) Pose2 {
+	if (window.LinearMM <= 0 || window.LinearStepMM <= 0) && (window.AngularRad <= 0 || window.AngularStepRad <= 0) {
+		return initial
+	}
+	linearSteps, angularSteps := 0, 0
+	if window.LinearStepMM > 0 {
+		linearSteps = int(window.LinearMM / window.LinearStepMM)
+	}
+	if window.AngularStepRad > 0 {
+		angularSteps = int(window.AngularRad / window.AngularStepRad)
+	}
+
+	// candidates are scored on how many points land near the target, which is cheaper and more robust to a
+	// bad initial guess than running ICP from each of them.
+	if stride := (len(scan) + maxSearchPoints - 1) / maxSearchPoints; stride > 1 {
+		sparse := make(Scan, 0, maxSearchPoints)
+		for i := 0; i < len(scan); i += stride {
+			sparse = append(sparse, scan[i])
+		}
+		scan = sparse
+	}
+	best := initial
+	bestScore := countNear(scan, target, initial, maxDist/2)
+	for a := -angularSteps; a <= angularSteps; a++ {
+		theta := initial.Theta + float64(a)*window.AngularStepRad
+		sin, cos := math.Sincos(theta)
+		rotated := make(Scan, len(scan))
+		for i, p := range scan {
+			rotated[i] = r3.Vector{X: cos*p.X - sin*p.Y, Y: sin*p.X + cos*p.Y}
+		}
+		for dx := -linearSteps; dx <= linearSteps; dx++ {
+			for dy := -linearSteps; dy <= linearSteps; dy++ {
+				candidate := Pose2{
+					X: initial.X + float64(dx)*window.LinearStepMM,
+					Y: initial.Y + float64(dy)*window.LinearStepMM,
+				}
+				score := countNear(rotated, target, candidate, maxDist/2)
+				if score > bestScore {
+					bestScore = score
+					best = Pose2{X: candidate.X, Y: candidate.Y, Theta: NormalizeAngle(theta)}
+				}
+			}
+		}
+	}
+	return best
+}
+
+// countNear returns how many points of scan, placed at pose, are within maxDistanceMM of a target point.
+func countNear(scan Scan, target *Index, pose Pose2, maxDistanceMM float64) int {
+	count := 0
+	for _, p := range scan {
+		if target.within(pose.Apply(p), maxDistanceMM) {
+			count++
+		}
+	}
+	return count
+}
+
+// icpStep returns the rigid motion best aligning the scan, placed at pose, with its nearest target points.
+// Points are pulled towards the line through their nearest target point, which unlike pulling them towards
+// the point itself lets scans slide along walls. Points whose nearest target point is not on a line, such
+// as corners, are left out since they bias the alignment, unless the target has no lines at all.
+func icpStep(scan Scan, target *Index, pose Pose2, maxDist float64) (Pose2, error) {
+	type correspondence struct {
+		moved, diff, normal r3.Vector
+	}
+	var toLines, toPoints []correspondence
+	for _, p := range scan {
+		moved := pose.Apply(p)
+		q, _ := target.nearest(moved, maxDist)
+		if q == nil {
+			continue
+		}
+		c := correspondence{moved: moved, diff: moved.Sub(q.pos), normal: target.normal(q)}
+		if c.normal == (r3.Vector{}) {
+			toPoints = append(toPoints, c)
+		} else {
+			toLines = append(toLines, c)
+		}
+	}
+
+	// normal equations of the linearized least squares problem over (x, y, theta).
+	var jtj [3][3]float64
+	var jtr [3]float64
+	addRow := func(c correspondence, n r3.Vector) {
+		j := [3]float64{n.X, n.Y, c.moved.X*n.Y - c.moved.Y*n.X}
+		r := c.diff.X*n.X + c.diff.Y*n.Y
+		for a := 0; a < 3; a++ {
+			for b := 0; b < 3; b++ {
+				jtj[a][b] += j[a] * j[b]
+			}
+			jtr[a] += j[a] * r
+		}
+	}
+	switch {
+	case len(toLines) >= minCorrespondences:
+		for _, c := range toLines {
+			addRow(c, c.normal)
+		}
+	case len(toLines)+len(toPoints) >= minCorrespondences:
+		for _, c := range append(toLines, toPoints...) {
+			addRow(c, r3.Vector{X: 1})
+			addRow(c, r3.Vector{Y: 1})
+		}
+	default:
+		return Pose2{}, ErrTooFewCorrespondences
+	}
+
+	// a little damping keeps the step bounded when the scan does not constrain every direction, as in a corridor.
+	a := mat.NewDense(3, 3, nil)
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			a.Set(i, j, jtj[i][j])
+		}
+		a.Set(i, i, a.At(i, i)*(1+damping))
+	}
+	var step mat.VecDense
+	if err := step.SolveVec(a, mat.NewVecDense(3, []float64{-jtr[0], -jtr[1], -jtr[2]})); err != nil {
+		return Pose2{}, errors.Wrap(err, "cannot align scan")
+	}
+	return Pose2{X: step.AtVec(0), Y: step.AtVec(1), Theta: step.AtVec(2)}, nil
+}
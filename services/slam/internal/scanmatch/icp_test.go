@@ -0,0 +1,65 @@
+package scanmatch
+
+import (
+	"math"
+	"testing"
+
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/services/slam/internal/testhelper"
+)
+
+func TestPose2(t *testing.T) {
+	a := Pose2{X: 100, Y: -50, Theta: 0.5}
+	b := Pose2{X: 20, Y: 30, Theta: -1}
+
+	identity := a.Compose(a.Inverse())
+	test.That(t, identity.X, test.ShouldAlmostEqual, 0)
+	test.That(t, identity.Y, test.ShouldAlmostEqual, 0)
+	test.That(t, identity.Theta, test.ShouldAlmostEqual, 0)
+
+	between := a.Between(a.Compose(b))
+	test.That(t, between.X, test.ShouldAlmostEqual, b.X)
+	test.That(t, between.Y, test.ShouldAlmostEqual, b.Y)
+	test.That(t, between.Theta, test.ShouldAlmostEqual, b.Theta)
+
+	roundTrip := FromPose(a.ToPose())
+	test.That(t, roundTrip.X, test.ShouldAlmostEqual, a.X)
+	test.That(t, roundTrip.Y, test.ShouldAlmostEqual, a.Y)
+	test.That(t, roundTrip.Theta, test.ShouldAlmostEqual, a.Theta)
+
+	test.That(t, NormalizeAngle(3*math.Pi/2), test.ShouldAlmostEqual, -math.Pi/2)
+	test.That(t, NormalizeAngle(-3*math.Pi/2), test.ShouldAlmostEqual, math.Pi/2)
+}
+
+func TestMatch(t *testing.T) {
+	room := testhelper.Room()
+	opts := ScanOptions{MinRangeMM: 100, MaxRangeMM: 20000, ResolutionMM: 30}
+	reference := ScanFromCloud(testhelper.LidarCloud(room, 0, 0, 0, 720, 20000), opts)
+	target := NewIndex(500)
+	target.Add(reference...)
+
+	truth := Pose2{X: 250, Y: -120, Theta: 0.12}
+	scan := ScanFromCloud(testhelper.LidarCloud(room, truth.X, truth.Y, truth.Theta, 720, 20000), opts)
+
+	t.Run("refines a close initial guess", func(t *testing.T) {
+		res, err := Match(scan, target, Pose2{X: 200, Y: -100, Theta: 0.1}, MatchOptions{})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, res.Pose.Distance(truth), test.ShouldBeLessThan, 20)
+		test.That(t, math.Abs(res.Pose.Theta-truth.Theta), test.ShouldBeLessThan, 0.01)
+		test.That(t, res.Fitness, test.ShouldBeGreaterThan, 0.9)
+	})
+
+	t.Run("searches the window around a poor initial guess", func(t *testing.T) {
+		window := SearchWindow{LinearMM: 400, LinearStepMM: 50, AngularRad: 0.4, AngularStepRad: 0.05}
+		res, err := Match(scan, target, Pose2{}, MatchOptions{Window: window})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, res.Pose.Distance(truth), test.ShouldBeLessThan, 20)
+		test.That(t, math.Abs(res.Pose.Theta-truth.Theta), test.ShouldBeLessThan, 0.01)
+	})
+
+	t.Run("fails without points to match against", func(t *testing.T) {
+		_, err := Match(scan, NewIndex(500), Pose2{}, MatchOptions{})
+		test.That(t, err, test.ShouldBeError, ErrTooFewCorrespondences)
+	})
+}
@@ -0,0 +1,77 @@
+// Package scanmatch implements 2D scan matching of planar lidar scans, and the other helpers shared by the slam models.
+package scanmatch
+
+import (
+	"math"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/utils"
+)
+
+// Pose2 is a pose in the plane, in millimeters and radians.
+type Pose2 struct {
+	X     float64 `json:"x"`
+	Y     float64 `json:"y"`
+	Theta float64 `json:"theta"`
+}
+
+// Compose returns the pose b expressed in the frame that p is expressed in, where b is relative to p.
+func (p Pose2) Compose(b Pose2) Pose2 {
+	sin, cos := math.Sincos(p.Theta)
+	return Pose2{
+		X:     p.X + cos*b.X - sin*b.Y,
+		Y:     p.Y + sin*b.X + cos*b.Y,
+		Theta: NormalizeAngle(p.Theta + b.Theta),
+	}
+}
+
+// Inverse returns the inverse of p, such that p.Compose(p.Inverse()) is the identity.
+func (p Pose2) Inverse() Pose2 {
+	sin, cos := math.Sincos(p.Theta)
+	return Pose2{
+		X:     -cos*p.X - sin*p.Y,
+		Y:     sin*p.X - cos*p.Y,
+		Theta: NormalizeAngle(-p.Theta),
+	}
+}
+
+// Between returns the pose of b relative to p.
+func (p Pose2) Between(b Pose2) Pose2 {
+	return p.Inverse().Compose(b)
+}
+
+// Apply transforms a point expressed relative to p into the frame that p is expressed in.
+func (p Pose2) Apply(v r3.Vector) r3.Vector {
+	sin, cos := math.Sincos(p.Theta)
+	return r3.Vector{X: p.X + cos*v.X - sin*v.Y, Y: p.Y + sin*v.X + cos*v.Y}
+}
+
+// Distance returns the planar distance between the positions of p and b.
+func (p Pose2) Distance(b Pose2) float64 {
+	return math.Hypot(b.X-p.X, b.Y-p.Y)
+}
+
+// ToPose converts p to a spatialmath pose, rotating about the Z axis.
+func (p Pose2) ToPose() spatialmath.Pose {
+	return spatialmath.NewPose(
+		r3.Vector{X: p.X, Y: p.Y},
+		&spatialmath.OrientationVectorDegrees{OZ: 1, Theta: utils.RadToDeg(p.Theta)},
+	)
+}
+
+// FromPose projects a spatialmath pose onto the plane.
+func FromPose(pose spatialmath.Pose) Pose2 {
+	pt := pose.Point()
+	return Pose2{X: pt.X, Y: pt.Y, Theta: pose.Orientation().EulerAngles().Yaw}
+}
+
+// NormalizeAngle wraps an angle in radians to [-pi, pi).
+func NormalizeAngle(theta float64) float64 {
+	theta = math.Mod(theta+math.Pi, 2*math.Pi)
+	if theta < 0 {
+		theta += 2 * math.Pi
+	}
+	return theta - math.Pi
+}
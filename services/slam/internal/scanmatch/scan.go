@@ -0,0 +1,241 @@
+package scanmatch
+
+import (
+	"math"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+)
+
+// Scan is a planar lidar scan: points in the plane of the lidar, in millimeters, with a zero Z component.
+type Scan []r3.Vector
+
+// ScanOptions describe how a point cloud is turned into a scan.
+type ScanOptions struct {
+	// MinRangeMM and MaxRangeMM bound the distance from the sensor of the points that are kept,
+	// a zero MaxRangeMM keeps every point past MinRangeMM.
+	MinRangeMM float64
+	MaxRangeMM float64
+	// ResolutionMM is the side of the grid cells the scan is downsampled to, zero disables downsampling.
+	ResolutionMM float64
+	// points outside of the band between MinZMM and MaxZMM are dropped, unless MaxZMM is not greater than MinZMM.
+	MinZMM float64
+	MaxZMM float64
+}
+
+// ScanFromCloud projects a point cloud onto its XY plane, keeping the points in range.
+func ScanFromCloud(cloud pointcloud.PointCloud, opts ScanOptions) Scan {
+	scan := make(Scan, 0, cloud.Size())
+	cloud.Iterate(0, 0, func(p r3.Vector, _ pointcloud.Data) bool {
+		if opts.MaxZMM > opts.MinZMM && (p.Z < opts.MinZMM || p.Z > opts.MaxZMM) {
+			return true
+		}
+		r := math.Hypot(p.X, p.Y)
+		if r < opts.MinRangeMM || (opts.MaxRangeMM > 0 && r > opts.MaxRangeMM) {
+			return true
+		}
+		scan = append(scan, r3.Vector{X: p.X, Y: p.Y})
+		return true
+	})
+	return scan.Downsample(opts.ResolutionMM)
+}
+
+// Transform returns the points of the scan moved into the frame that pose is expressed in.
+func (s Scan) Transform(pose Pose2) Scan {
+	out := make(Scan, len(s))
+	for i, p := range s {
+		out[i] = pose.Apply(p)
+	}
+	return out
+}
+
+// Downsample keeps the centroid of the points falling in each cell of a grid with the given resolution.
+// The order of the points is kept, by first appearance of their cell.
+func (s Scan) Downsample(resolutionMM float64) Scan {
+	if resolutionMM <= 0 || len(s) == 0 {
+		return s
+	}
+	type cellSum struct {
+		sum r3.Vector
+		n   float64
+	}
+	cells := map[cellKey]*cellSum{}
+	order := make([]cellKey, 0, len(s))
+	for _, p := range s {
+		key := keyOf(p, resolutionMM)
+		c, ok := cells[key]
+		if !ok {
+			c = &cellSum{}
+			cells[key] = c
+			order = append(order, key)
+		}
+		c.sum = c.sum.Add(p)
+		c.n++
+	}
+	out := make(Scan, 0, len(order))
+	for _, key := range order {
+		c := cells[key]
+		out = append(out, c.sum.Mul(1/c.n))
+	}
+	return out
+}
+
+// ToPointCloud returns the scan as a point cloud.
+func (s Scan) ToPointCloud() (pointcloud.PointCloud, error) {
+	cloud := pointcloud.NewWithPrealloc(len(s))
+	for _, p := range s {
+		if err := cloud.Set(p, nil); err != nil {
+			return nil, err
+		}
+	}
+	return cloud, nil
+}
+
+const (
+	// normals are estimated from the points within this distance.
+	normalRadiusMM     = 150.
+	minNormalNeighbors = 3
+	// the ratio of the smallest to the largest spread of the neighbors of a point past which they are not
+	// considered to be along a line.
+	maxLinearity = 0.1
+)
+
+type cellKey struct {
+	x, y int64
+}
+
+func keyOf(p r3.Vector, size float64) cellKey {
+	return cellKey{x: int64(math.Floor(p.X / size)), y: int64(math.Floor(p.Y / size))}
+}
+
+// Index answers nearest neighbor queries within a bounded radius on a set of planar points, by
+// bucketing them in a grid whose cells are as wide as that radius. It is not safe for concurrent use,
+// since the normals of the points are estimated as they are needed.
+type Index struct {
+	cellSize float64
+	cells    map[cellKey][]*indexedPoint
+	size     int
+}
+
+type indexedPoint struct {
+	pos r3.Vector
+	// normal is the unit normal of the surface around the point, or the zero vector if the points around
+	// it are not spread along a line.
+	normal         r3.Vector
+	normalEstimate bool
+}
+
+// NewIndex returns an index for nearest neighbor queries no further than maxDistanceMM.
+func NewIndex(maxDistanceMM float64) *Index {
+	return &Index{cellSize: maxDistanceMM, cells: map[cellKey][]*indexedPoint{}}
+}
+
+// Add adds points to the index.
+func (idx *Index) Add(points ...r3.Vector) {
+	for _, p := range points {
+		key := keyOf(p, idx.cellSize)
+		idx.cells[key] = append(idx.cells[key], &indexedPoint{pos: r3.Vector{X: p.X, Y: p.Y}})
+	}
+	idx.size += len(points)
+}
+
+// Size returns the number of points in the index.
+func (idx *Index) Size() int {
+	return idx.size
+}
+
+// MaxDistance returns the largest distance a nearest neighbor can be found at.
+func (idx *Index) MaxDistance() float64 {
+	return idx.cellSize
+}
+
+// Nearest returns the closest indexed point to p within maxDistanceMM, which cannot be greater than
+// the distance the index was created for.
+func (idx *Index) Nearest(p r3.Vector, maxDistanceMM float64) (r3.Vector, float64, bool) {
+	q, d := idx.nearest(p, maxDistanceMM)
+	if q == nil {
+		return r3.Vector{}, 0, false
+	}
+	return q.pos, d, true
+}
+
+func (idx *Index) nearest(p r3.Vector, maxDistanceMM float64) (*indexedPoint, float64) {
+	best := math.Min(maxDistanceMM, idx.cellSize)
+	bestSq := best * best
+	var nearest *indexedPoint
+	idx.visit(p, func(q *indexedPoint) bool {
+		if dSq := distSq(p, q.pos); dSq <= bestSq {
+			bestSq = dSq
+			nearest = q
+		}
+		return true
+	})
+	return nearest, math.Sqrt(bestSq)
+}
+
+// within returns whether any indexed point is within maxDistanceMM of p.
+func (idx *Index) within(p r3.Vector, maxDistanceMM float64) bool {
+	maxSq := maxDistanceMM * maxDistanceMM
+	found := false
+	idx.visit(p, func(q *indexedPoint) bool {
+		found = distSq(p, q.pos) <= maxSq
+		return !found
+	})
+	return found
+}
+
+// visit calls fn with every point in the cells around p until it returns false.
+func (idx *Index) visit(p r3.Vector, fn func(q *indexedPoint) bool) {
+	key := keyOf(p, idx.cellSize)
+	for dx := int64(-1); dx <= 1; dx++ {
+		for dy := int64(-1); dy <= 1; dy++ {
+			for _, q := range idx.cells[cellKey{x: key.x + dx, y: key.y + dy}] {
+				if !fn(q) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// normal returns the normal of the surface around q, estimated from the principal axes of its neighbors.
+func (idx *Index) normal(q *indexedPoint) r3.Vector {
+	if q.normalEstimate {
+		return q.normal
+	}
+	q.normalEstimate = true
+	radius := math.Min(normalRadiusMM, idx.cellSize)
+	var neighbors []r3.Vector
+	var mean r3.Vector
+	idx.visit(q.pos, func(n *indexedPoint) bool {
+		if distSq(q.pos, n.pos) <= radius*radius {
+			neighbors = append(neighbors, n.pos)
+			mean = mean.Add(n.pos)
+		}
+		return true
+	})
+	if len(neighbors) < minNormalNeighbors {
+		return q.normal
+	}
+	mean = mean.Mul(1 / float64(len(neighbors)))
+	var xx, xy, yy float64
+	for _, n := range neighbors {
+		d := n.Sub(mean)
+		xx += d.X * d.X
+		xy += d.X * d.Y
+		yy += d.Y * d.Y
+	}
+	// eigenvalues of the covariance of the neighbors, which only describe a line if one dominates.
+	half, spread := (xx+yy)/2, math.Hypot((xx-yy)/2, xy)
+	if half+spread == 0 || (half-spread)/(half+spread) > maxLinearity {
+		return q.normal
+	}
+	axis := math.Atan2(2*xy, xx-yy) / 2
+	q.normal = r3.Vector{X: -math.Sin(axis), Y: math.Cos(axis)}
+	return q.normal
+}
+
+func distSq(a, b r3.Vector) float64 {
+	return (a.X-b.X)*(a.X-b.X) + (a.Y-b.Y)*(a.Y-b.Y)
+}
@@ -0,0 +1,76 @@
+package testhelper
+
+import (
+	"math"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+)
+
+// Wall is a segment of a simulated planar environment, in millimeters.
+type Wall struct {
+	Start, End r3.Vector
+}
+
+// Room returns the walls of a simulated 10m by 6m room with two pillars and a partition in it, whose
+// lower left corner is at (-2000, -2000). It is irregular enough for scan matching to be unambiguous.
+func Room() []Wall {
+	walls := box(-2000, -2000, 8000, 4000)
+	walls = append(walls, box(1000, 500, 1400, 900)...)
+	walls = append(walls, box(5000, -1000, 5300, -400)...)
+	walls = append(walls,
+		Wall{Start: r3.Vector{X: 3000, Y: 4000}, End: r3.Vector{X: 3000, Y: 2500}},
+		Wall{Start: r3.Vector{X: -2000, Y: 1000}, End: r3.Vector{X: -1200, Y: 1800}},
+	)
+	return walls
+}
+
+func box(minX, minY, maxX, maxY float64) []Wall {
+	corners := []r3.Vector{{X: minX, Y: minY}, {X: maxX, Y: minY}, {X: maxX, Y: maxY}, {X: minX, Y: maxY}}
+	walls := make([]Wall, 0, 4)
+	for i := range corners {
+		walls = append(walls, Wall{Start: corners[i], End: corners[(i+1)%len(corners)]})
+	}
+	return walls
+}
+
+// LidarCloud simulates the point cloud of a planar lidar with numRays evenly spaced rays at (x, y) in
+// the room, rotated by theta radians about Z. Points are in the frame of the lidar.
+func LidarCloud(walls []Wall, x, y, theta float64, numRays int, maxRangeMM float64) pointcloud.PointCloud {
+	cloud := pointcloud.NewWithPrealloc(numRays)
+	for i := 0; i < numRays; i++ {
+		angle := 2 * math.Pi * float64(i) / float64(numRays)
+		dir := r3.Vector{X: math.Cos(theta + angle), Y: math.Sin(theta + angle)}
+		nearest := math.Inf(1)
+		for _, w := range walls {
+			if d, ok := raySegment(r3.Vector{X: x, Y: y}, dir, w); ok && d < nearest {
+				nearest = d
+			}
+		}
+		if nearest > maxRangeMM {
+			continue
+		}
+		p := r3.Vector{X: nearest * math.Cos(angle), Y: nearest * math.Sin(angle)}
+		if err := cloud.Set(p, nil); err != nil {
+			panic(err)
+		}
+	}
+	return cloud
+}
+
+// raySegment returns the distance along a ray to where it crosses a wall.
+func raySegment(origin, dir r3.Vector, w Wall) (float64, bool) {
+	seg := w.End.Sub(w.Start)
+	denom := dir.X*seg.Y - dir.Y*seg.X
+	if math.Abs(denom) < 1e-12 {
+		return 0, false
+	}
+	diff := w.Start.Sub(origin)
+	t := (diff.X*seg.Y - diff.Y*seg.X) / denom
+	u := (diff.X*dir.Y - diff.Y*dir.X) / denom
+	if t <= 0 || u < 0 || u > 1 {
+		return 0, false
+	}
+	return t, true
+}
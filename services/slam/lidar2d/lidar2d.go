@@ -0,0 +1,600 @@
+// Package lidar2d implements a slam service for a planar lidar exposed as a camera point cloud.
+// Scans are matched against a submap of recent keyframes to track the lidar, keyframes form a pose
+// graph that is optimized whenever a scan matches a previously mapped area, and the map is exported
+// as a PCD point cloud. The service can also localize against a map built earlier without extending it.
+package lidar2d
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+	goutils "go.viam.com/utils"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/slam"
+	"go.viam.com/rdk/services/slam/internal/scanmatch"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/utils"
+)
+
+var model = resource.DefaultModelFamily.WithModel("lidar_2d")
+
+const (
+	defaultUpdateRateHz          = 5.
+	defaultMinRangeMM            = 100.
+	defaultMaxRangeMM            = 25000.
+	defaultResolutionMM          = 50.
+	defaultSubmapSize            = 10
+	defaultKeyframeDistanceMM    = 200.
+	defaultKeyframeAngleDegs     = 10.
+	defaultLoopClosureRadiusMM   = 2000.
+	defaultLoopClosureMinFitness = 0.8
+
+	// scans are matched to points no further than this from where they are predicted to land.
+	maxCorrespondenceMM = 500.
+	// a scan matching fewer of its points than this is not trusted to track the lidar.
+	minTrackingFitness = 0.5
+	// scans with fewer points than this in range are skipped.
+	minScanPoints = 20
+
+	// standard deviations of the relative poses measured by scan matching, used to weigh pose graph edges.
+	scanMatchStdMM  = 20.
+	scanMatchStdRad = 0.01
+
+	internalStateFileType = ".json"
+
+	mappingModeMapping    = "mapping"
+	mappingModeLocalizing = "localizing"
+	mappingModeUpdating   = "updating"
+)
+
+var (
+	// the first scan of a session is matched against an existing map anywhere near the origin of the map,
+	// in any orientation.
+	initialWindow = scanmatch.SearchWindow{
+		LinearMM:       500,
+		LinearStepMM:   100,
+		AngularRad:     math.Pi,
+		AngularStepRad: utils.DegToRad(2),
+	}
+	// loop closures correct the drift accumulated since a place was last visited.
+	loopClosureWindow = scanmatch.SearchWindow{
+		LinearMM:       500,
+		LinearStepMM:   50,
+		AngularRad:     utils.DegToRad(15),
+		AngularStepRad: utils.DegToRad(1),
+	}
+
+	errNotLocalized = errors.New("the lidar has not been localized in the map yet")
+)
+
+func init() {
+	resource.RegisterService(
+		slam.API,
+		model,
+		resource.Registration[slam.Service, *Config]{Constructor: newLidar2D},
+	)
+}
+
+// Config is the config of the lidar_2d slam model.
+type Config struct {
+	Camera string `json:"camera"`
+	// MappingMode is one of mapping (the default), localizing or updating.
+	MappingMode string `json:"mapping_mode,omitempty"`
+	// ExistingMap is a PCD map, or the internal state of a previous session, to localize in or update.
+	ExistingMap string `json:"existing_map,omitempty"`
+
+	UpdateRateHz float64 `json:"update_rate_hz,omitempty"`
+	MinRangeMM   float64 `json:"min_range_mm,omitempty"`
+	MaxRangeMM   float64 `json:"max_range_mm,omitempty"`
+	ResolutionMM float64 `json:"map_resolution_mm,omitempty"`
+
+	// SubmapSize is how many of the latest keyframes scans are matched against.
+	SubmapSize         int     `json:"submap_size,omitempty"`
+	KeyframeDistanceMM float64 `json:"keyframe_distance_mm,omitempty"`
+	KeyframeAngleDegs  float64 `json:"keyframe_angle_degs,omitempty"`
+
+	// keyframes within LoopClosureRadiusMM of a keyframe outside of the submap are matched against it,
+	// and the match is kept as a loop closure if at least LoopClosureMinFitness of the scan matches.
+	LoopClosureRadiusMM   float64 `json:"loop_closure_radius_mm,omitempty"`
+	LoopClosureMinFitness float64 `json:"loop_closure_min_fitness,omitempty"`
+}
+
+// Validate validates the lidar_2d model's configuration.
+func (cfg *Config) Validate(path string) ([]string, error) {
+	if cfg.Camera == "" {
+		return nil, resource.NewConfigValidationFieldRequiredError(path, "camera")
+	}
+	mode, err := parseMappingMode(cfg.MappingMode)
+	if err != nil {
+		return nil, resource.NewConfigValidationError(path, err)
+	}
+	if mode != slam.MappingModeNewMap && cfg.ExistingMap == "" {
+		return nil, resource.NewConfigValidationFieldRequiredError(path, "existing_map")
+	}
+	for name, v := range map[string]float64{
+		"update_rate_hz":           cfg.UpdateRateHz,
+		"min_range_mm":             cfg.MinRangeMM,
+		"max_range_mm":             cfg.MaxRangeMM,
+		"map_resolution_mm":        cfg.ResolutionMM,
+		"submap_size":              float64(cfg.SubmapSize),
+		"keyframe_distance_mm":     cfg.KeyframeDistanceMM,
+		"keyframe_angle_degs":      cfg.KeyframeAngleDegs,
+		"loop_closure_radius_mm":   cfg.LoopClosureRadiusMM,
+		"loop_closure_min_fitness": cfg.LoopClosureMinFitness,
+	} {
+		if v < 0 {
+			return nil, resource.NewConfigValidationError(path, errors.Errorf("%s cannot be negative", name))
+		}
+	}
+	if cfg.LoopClosureMinFitness > 1 {
+		return nil, resource.NewConfigValidationError(path, errors.New("loop_closure_min_fitness cannot be greater than 1"))
+	}
+	if cfg.MaxRangeMM != 0 && cfg.MaxRangeMM <= cfg.MinRangeMM {
+		return nil, resource.NewConfigValidationError(path, errors.New("max_range_mm must be greater than min_range_mm"))
+	}
+	return []string{cfg.Camera}, nil
+}
+
+func parseMappingMode(mode string) (slam.MappingMode, error) {
+	switch mode {
+	case "", mappingModeMapping:
+		return slam.MappingModeNewMap, nil
+	case mappingModeLocalizing:
+		return slam.MappingModeLocalizationOnly, nil
+	case mappingModeUpdating:
+		return slam.MappingModeUpdateExistingMap, nil
+	default:
+		return 0, errors.Errorf("mapping_mode must be one of %q, %q or %q, got %q",
+			mappingModeMapping, mappingModeLocalizing, mappingModeUpdating, mode)
+	}
+}
+
+func applyDefaults(cfg *Config) {
+	if cfg.UpdateRateHz == 0 {
+		cfg.UpdateRateHz = defaultUpdateRateHz
+	}
+	if cfg.MinRangeMM == 0 {
+		cfg.MinRangeMM = defaultMinRangeMM
+	}
+	if cfg.MaxRangeMM == 0 {
+		cfg.MaxRangeMM = defaultMaxRangeMM
+	}
+	if cfg.ResolutionMM == 0 {
+		cfg.ResolutionMM = defaultResolutionMM
+	}
+	if cfg.SubmapSize == 0 {
+		cfg.SubmapSize = defaultSubmapSize
+	}
+	if cfg.KeyframeDistanceMM == 0 {
+		cfg.KeyframeDistanceMM = defaultKeyframeDistanceMM
+	}
+	if cfg.KeyframeAngleDegs == 0 {
+		cfg.KeyframeAngleDegs = defaultKeyframeAngleDegs
+	}
+	if cfg.LoopClosureRadiusMM == 0 {
+		cfg.LoopClosureRadiusMM = defaultLoopClosureRadiusMM
+	}
+	if cfg.LoopClosureMinFitness == 0 {
+		cfg.LoopClosureMinFitness = defaultLoopClosureMinFitness
+	}
+}
+
+type lidar2D struct {
+	resource.Named
+	resource.AlwaysRebuild
+	logger logging.Logger
+
+	cam      camera.Camera
+	conf     *Config
+	mode     slam.MappingMode
+	scanOpts scanmatch.ScanOptions
+
+	mu sync.Mutex
+	// graph holds the keyframes mapped in this session, along with those of the internal state it was
+	// started from.
+	graph *poseGraph
+	// staticMap holds the points of an existing PCD map, which are never moved.
+	staticMap      scanmatch.Scan
+	staticMapIndex *scanmatch.Index
+	// submap is the index scans are tracked against, rebuilt when a keyframe is added.
+	submap *scanmatch.Index
+
+	localized       bool
+	pose            scanmatch.Pose2
+	motion          scanmatch.Pose2
+	lastLoopClosure int
+	// loopClosed is set once a loop closure is added to the graph, until update has optimized the graph.
+	loopClosed bool
+	// mapPCD caches the exported map until a keyframe is added.
+	mapPCD []byte
+
+	workers *goutils.StoppableWorkers
+}
+
+func newLidar2D(ctx context.Context, deps resource.Dependencies, conf resource.Config, logger logging.Logger) (slam.Service, error) {
+	newConf, err := resource.NativeConfig[*Config](conf)
+	if err != nil {
+		return nil, err
+	}
+	cam, err := camera.FromDependencies(deps, newConf.Camera)
+	if err != nil {
+		return nil, err
+	}
+	s, err := newService(conf.ResourceName(), cam, newConf, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := time.Duration(float64(time.Second) / s.conf.UpdateRateHz)
+	s.workers = goutils.NewBackgroundStoppableWorkers(func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var lastErr error
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			err := s.update(ctx)
+			// only changes are logged so that a lidar which is persistently failing does not flood the logs.
+			if err != nil && ctx.Err() == nil && (lastErr == nil || lastErr.Error() != err.Error()) {
+				s.logger.CWarnw(ctx, "failed to process scan", "error", err)
+			}
+			lastErr = err
+		}
+	})
+	return s, nil
+}
+
+// newService creates the service without starting to process scans, which is left to the caller.
+func newService(name resource.Name, cam camera.Camera, conf *Config, logger logging.Logger) (*lidar2D, error) {
+	applyDefaults(conf)
+	mode, err := parseMappingMode(conf.MappingMode)
+	if err != nil {
+		return nil, err
+	}
+	s := &lidar2D{
+		Named:  name.AsNamed(),
+		logger: logger,
+		cam:    cam,
+		conf:   conf,
+		mode:   mode,
+		scanOpts: scanmatch.ScanOptions{
+			MinRangeMM:   conf.MinRangeMM,
+			MaxRangeMM:   conf.MaxRangeMM,
+			ResolutionMM: conf.ResolutionMM,
+		},
+		graph:           &poseGraph{},
+		lastLoopClosure: -1,
+	}
+	if conf.ExistingMap != "" {
+		if err := s.loadExistingMap(conf.ExistingMap); err != nil {
+			return nil, errors.Wrapf(err, "cannot load existing map %v", conf.ExistingMap)
+		}
+	}
+	return s, nil
+}
+
+// loadExistingMap loads either the internal state of a previous session, which can be extended, or a PCD map.
+func (s *lidar2D) loadExistingMap(path string) error {
+	if filepath.Ext(path) == internalStateFileType {
+		//nolint:gosec
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var graph poseGraph
+		if err := json.Unmarshal(data, &graph); err != nil {
+			return err
+		}
+		if s.mode == slam.MappingModeUpdateExistingMap {
+			s.graph = &graph
+		} else {
+			s.staticMap = graph.points(s.conf.ResolutionMM)
+		}
+	} else {
+		cloud, err := pointcloud.NewFromFile(path, s.logger)
+		if err != nil {
+			return err
+		}
+		// the map is only used in the plane of the lidar.
+		s.staticMap = scanmatch.ScanFromCloud(cloud, scanmatch.ScanOptions{ResolutionMM: s.conf.ResolutionMM})
+	}
+	if len(s.staticMap) == 0 && len(s.graph.Nodes) == 0 {
+		return errors.New("map is empty")
+	}
+	if len(s.staticMap) > 0 {
+		s.staticMapIndex = scanmatch.NewIndex(maxCorrespondenceMM)
+		s.staticMapIndex.Add(s.staticMap...)
+	}
+	return nil
+}
+
+// update processes the next scan from the camera.
+func (s *lidar2D) update(ctx context.Context) error {
+	cloud, err := s.cam.NextPointCloud(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "cannot get point cloud from camera %v", s.conf.Camera)
+	}
+	scan := scanmatch.ScanFromCloud(cloud, s.scanOpts)
+	if len(scan) < minScanPoints {
+		return errors.Errorf("scan from camera %v has only %d points in range", s.conf.Camera, len(scan))
+	}
+
+	s.mu.Lock()
+	err = s.addScan(scan)
+	var graph *poseGraph
+	if err == nil && s.loopClosed {
+		s.loopClosed = false
+		graph = s.graph.clone()
+	}
+	s.mu.Unlock()
+	if err != nil || graph == nil {
+		return err
+	}
+
+	// optimizing takes a while on large maps, so a copy of the graph is optimized without blocking readers,
+	// which see the poses from before the loop closure meanwhile. Only update changes the graph, so its poses
+	// can be replaced with the optimized ones afterwards.
+	if err := graph.optimize(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, n := range graph.Nodes {
+		s.graph.Nodes[i].Pose = n.Pose
+	}
+	s.pose = graph.Nodes[len(graph.Nodes)-1].Pose
+	s.submap = nil
+	s.mapPCD = nil
+	return nil
+}
+
+// addScan tracks the lidar with a new scan, adding it to the map as a keyframe if the lidar has moved enough.
+func (s *lidar2D) addScan(scan scanmatch.Scan) error {
+	if !s.localized {
+		return s.localize(scan)
+	}
+
+	// the lidar is assumed to keep moving as it did since the previous scan.
+	predicted := s.pose.Compose(s.motion)
+	res, err := scanmatch.Match(scan, s.target(), predicted, scanmatch.MatchOptions{})
+	if err == nil && res.Fitness < minTrackingFitness {
+		err = errors.Errorf("only %.0f%% of the scan matches the map", 100*res.Fitness)
+	}
+	if err != nil {
+		s.pose = predicted
+		return errors.Wrap(err, "scan matching failed, the pose is extrapolated from the previous motion")
+	}
+	s.motion = s.pose.Between(res.Pose)
+	s.pose = res.Pose
+
+	if s.mode == slam.MappingModeLocalizationOnly {
+		return nil
+	}
+	last := s.graph.Nodes[len(s.graph.Nodes)-1].Pose
+	if last.Distance(s.pose) >= s.conf.KeyframeDistanceMM ||
+		math.Abs(scanmatch.NormalizeAngle(s.pose.Theta-last.Theta)) >= utils.DegToRad(s.conf.KeyframeAngleDegs) {
+		return s.addKeyframe(scan, s.pose)
+	}
+	return nil
+}
+
+// localize finds the pose of the first scan of the session.
+func (s *lidar2D) localize(scan scanmatch.Scan) error {
+	if s.staticMapIndex == nil && len(s.graph.Nodes) == 0 {
+		// a new map starts at the first scan.
+		s.localized = true
+		return s.addKeyframe(scan, scanmatch.Pose2{})
+	}
+	target := s.staticMapIndex
+	if target == nil {
+		target = scanmatch.NewIndex(maxCorrespondenceMM)
+		target.Add(s.graph.points(s.conf.ResolutionMM)...)
+	}
+	res, err := scanmatch.Match(scan, target, scanmatch.Pose2{}, scanmatch.MatchOptions{Window: initialWindow})
+	if err != nil {
+		return errors.Wrap(errNotLocalized, err.Error())
+	}
+	if res.Fitness < minTrackingFitness {
+		return errors.Wrapf(errNotLocalized, "only %.0f%% of the scan matches the map near its origin", 100*res.Fitness)
+	}
+	s.localized = true
+	s.pose = res.Pose
+	if s.mode == slam.MappingModeLocalizationOnly {
+		return nil
+	}
+	return s.addKeyframe(scan, s.pose)
+}
+
+// addKeyframe adds a scan to the pose graph, closing a loop with an earlier keyframe if it matches one. The
+// graph is optimized by update once a loop is closed.
+func (s *lidar2D) addKeyframe(scan scanmatch.Scan, pose scanmatch.Pose2) error {
+	s.graph.Nodes = append(s.graph.Nodes, &node{Pose: pose, Scan: scan})
+	idx := len(s.graph.Nodes) - 1
+	if idx > 0 {
+		s.graph.Edges = append(s.graph.Edges, newEdge(idx-1, idx, s.graph.Nodes[idx-1].Pose.Between(pose), false))
+	}
+	s.submap = nil
+	s.mapPCD = nil
+
+	closed, err := s.closeLoop(idx)
+	if err != nil || !closed {
+		return err
+	}
+	s.lastLoopClosure = idx
+	s.loopClosed = true
+	return nil
+}
+
+// closeLoop matches the keyframe at idx against the closest keyframe outside of the submap, adding an edge
+// between them if they match. Once a loop is closed, the next few keyframes are not matched again since
+// they would mostly add the same constraint.
+func (s *lidar2D) closeLoop(idx int) (bool, error) {
+	if s.lastLoopClosure >= 0 && idx-s.lastLoopClosure <= s.conf.SubmapSize {
+		return false, nil
+	}
+	newNode := s.graph.Nodes[idx]
+	candidate := -1
+	closest := s.conf.LoopClosureRadiusMM
+	for i := 0; i < idx-s.conf.SubmapSize; i++ {
+		if d := s.graph.Nodes[i].Pose.Distance(newNode.Pose); d <= closest {
+			candidate = i
+			closest = d
+		}
+	}
+	if candidate < 0 {
+		return false, nil
+	}
+
+	// the keyframes around the candidate are matched against rather than the candidate alone, since
+	// together they see more of the place than a single scan.
+	target := scanmatch.NewIndex(maxCorrespondenceMM)
+	for i := candidate - s.conf.SubmapSize/2; i <= candidate+s.conf.SubmapSize/2; i++ {
+		if i >= 0 && i < idx-s.conf.SubmapSize {
+			n := s.graph.Nodes[i]
+			target.Add(n.Scan.Transform(n.Pose)...)
+		}
+	}
+	res, err := scanmatch.Match(newNode.Scan, target, newNode.Pose, scanmatch.MatchOptions{Window: loopClosureWindow})
+	if err != nil {
+		if errors.Is(err, scanmatch.ErrTooFewCorrespondences) {
+			return false, nil
+		}
+		return false, err
+	}
+	if res.Fitness < s.conf.LoopClosureMinFitness {
+		return false, nil
+	}
+	s.logger.Debugf("closing loop between keyframes %d and %d, correcting drift of %.0fmm",
+		candidate, idx, res.Pose.Distance(newNode.Pose))
+	s.graph.Edges = append(s.graph.Edges, newEdge(candidate, idx, s.graph.Nodes[candidate].Pose.Between(res.Pose), true))
+	return true, nil
+}
+
+func newEdge(from, to int, measurement scanmatch.Pose2, loopClosure bool) edge {
+	return edge{
+		From:              from,
+		To:                to,
+		Measurement:       measurement,
+		TranslationWeight: 1 / (scanMatchStdMM * scanMatchStdMM),
+		RotationWeight:    1 / (scanMatchStdRad * scanMatchStdRad),
+		LoopClosure:       loopClosure,
+	}
+}
+
+// target returns the points scans are tracked against: the existing map when localizing, and otherwise
+// the latest keyframes along with any existing map.
+func (s *lidar2D) target() *scanmatch.Index {
+	if s.mode == slam.MappingModeLocalizationOnly {
+		return s.staticMapIndex
+	}
+	if s.submap != nil {
+		return s.submap
+	}
+	s.submap = scanmatch.NewIndex(maxCorrespondenceMM)
+	s.submap.Add(s.staticMap...)
+	for i := max(0, len(s.graph.Nodes)-s.conf.SubmapSize); i < len(s.graph.Nodes); i++ {
+		n := s.graph.Nodes[i]
+		s.submap.Add(n.Scan.Transform(n.Pose)...)
+	}
+	return s.submap
+}
+
+// points returns the points of every keyframe at their optimized pose, downsampled to resolutionMM.
+func (g *poseGraph) points(resolutionMM float64) scanmatch.Scan {
+	var points scanmatch.Scan
+	for _, n := range g.Nodes {
+		points = append(points, n.Scan.Transform(n.Pose)...)
+	}
+	return points.Downsample(resolutionMM)
+}
+
+// Position returns the pose of the lidar in the map.
+func (s *lidar2D) Position(ctx context.Context) (spatialmath.Pose, error) {
+	_, span := trace.StartSpan(ctx, "slam::lidar2d::Position")
+	defer span.End()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.localized {
+		return nil, errNotLocalized
+	}
+	return s.pose.ToPose(), nil
+}
+
+// PointCloudMap returns a callback function which will return the next chunk of the map as a PCD file.
+// The map is never edited, so returnEditedMap is ignored.
+func (s *lidar2D) PointCloudMap(ctx context.Context, returnEditedMap bool) (func() ([]byte, error), error) {
+	_, span := trace.StartSpan(ctx, "slam::lidar2d::PointCloudMap")
+	defer span.End()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.mapPCD == nil {
+		var points scanmatch.Scan
+		points = append(points, s.staticMap...)
+		if s.mode != slam.MappingModeLocalizationOnly {
+			points = append(points, s.graph.points(s.conf.ResolutionMM)...)
+		}
+		if len(points) == 0 {
+			return nil, errors.New("the map is empty, no scans have been processed yet")
+		}
+		cloud, err := points.Downsample(s.conf.ResolutionMM).ToPointCloud()
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := pointcloud.ToPCD(cloud, &buf, pointcloud.PCDBinary); err != nil {
+			return nil, err
+		}
+		s.mapPCD = buf.Bytes()
+	}
+	return scanmatch.Chunked(s.mapPCD), nil
+}
+
+// InternalState returns a callback function which will return the next chunk of the pose graph, serialized
+// as JSON. It can be used as the existing_map of a later session.
+func (s *lidar2D) InternalState(ctx context.Context) (func() ([]byte, error), error) {
+	_, span := trace.StartSpan(ctx, "slam::lidar2d::InternalState")
+	defer span.End()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := json.Marshal(s.graph)
+	if err != nil {
+		return nil, err
+	}
+	return scanmatch.Chunked(data), nil
+}
+
+// Properties returns the mapping mode of the slam service and the camera it reads scans from.
+func (s *lidar2D) Properties(ctx context.Context) (slam.Properties, error) {
+	_, span := trace.StartSpan(ctx, "slam::lidar2d::Properties")
+	defer span.End()
+	return slam.Properties{
+		CloudSlam:             false,
+		MappingMode:           s.mode,
+		InternalStateFileType: internalStateFileType,
+		SensorInfo:            []slam.SensorInfo{{Name: s.conf.Camera, Type: slam.SensorTypeCamera}},
+	}, nil
+}
+
+// Close stops processing scans.
+func (s *lidar2D) Close(context.Context) error {
+	// we do not close the camera that this service depends on.
+	if s.workers != nil {
+		s.workers.Stop()
+	}
+	return nil
+}
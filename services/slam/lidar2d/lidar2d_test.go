@@ -0,0 +1,267 @@
+package lidar2d
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/geo/r3"
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/slam"
+	"go.viam.com/rdk/services/slam/internal/scanmatch"
+	"go.viam.com/rdk/services/slam/internal/testhelper"
+	"go.viam.com/rdk/testutils/inject"
+)
+
+const numRays = 720
+
+// loopTrajectory drives around the room counterclockwise, turning in place at the corners, and comes back
+// to where it started.
+func loopTrajectory() []scanmatch.Pose2 {
+	corners := []r3.Vector{{X: 0, Y: 0}, {X: 6500, Y: 0}, {X: 6500, Y: 2000}, {X: 0, Y: 2000}, {X: 0, Y: 0}}
+	var poses []scanmatch.Pose2
+	theta := 0.
+	for i := 0; i+1 < len(corners); i++ {
+		from, to := corners[i], corners[i+1]
+		heading := math.Atan2(to.Y-from.Y, to.X-from.X)
+		for ; scanmatch.NormalizeAngle(heading-theta) > 1e-6; theta += math.Pi / 18 {
+			poses = append(poses, scanmatch.Pose2{X: from.X, Y: from.Y, Theta: theta})
+		}
+		theta = heading
+		length := to.Sub(from).Norm()
+		for d := 0.; d < length; d += 100 {
+			p := from.Add(to.Sub(from).Mul(d / length))
+			poses = append(poses, scanmatch.Pose2{X: p.X, Y: p.Y, Theta: theta})
+		}
+	}
+	return append(poses, scanmatch.Pose2{Theta: theta})
+}
+
+// recordedCamera returns a camera replaying the clouds a lidar would see along poses, with noisy ranges.
+func recordedCamera(t *testing.T, poses []scanmatch.Pose2) *inject.Camera {
+	t.Helper()
+	room := testhelper.Room()
+	noise := rand.New(rand.NewSource(1))
+	clouds := make([]pointcloud.PointCloud, 0, len(poses))
+	for _, p := range poses {
+		clean := testhelper.LidarCloud(room, p.X, p.Y, p.Theta, numRays, defaultMaxRangeMM)
+		noisy := pointcloud.NewWithPrealloc(clean.Size())
+		clean.Iterate(0, 0, func(pt r3.Vector, d pointcloud.Data) bool {
+			test.That(t, noisy.Set(pt.Mul(1+noise.NormFloat64()*0.002), d), test.ShouldBeNil)
+			return true
+		})
+		clouds = append(clouds, noisy)
+	}
+	next := 0
+	cam := inject.NewCamera("lidar")
+	cam.NextPointCloudFunc = func(ctx context.Context) (pointcloud.PointCloud, error) {
+		cloud := clouds[min(next, len(clouds)-1)]
+		next++
+		return cloud, nil
+	}
+	return cam
+}
+
+func newTestService(t *testing.T, cam *inject.Camera, conf *Config) *lidar2D {
+	t.Helper()
+	conf.Camera = "lidar"
+	_, err := conf.Validate("path")
+	test.That(t, err, test.ShouldBeNil)
+	s, err := newService(slam.Named("lidar_2d"), cam, conf, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	return s
+}
+
+func checkPosition(t *testing.T, s *lidar2D, truth scanmatch.Pose2, toleranceMM, toleranceDegs float64) {
+	t.Helper()
+	pose, err := s.Position(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+	estimate := scanmatch.FromPose(pose)
+	test.That(t, estimate.Distance(truth), test.ShouldBeLessThan, toleranceMM)
+	test.That(t, math.Abs(scanmatch.NormalizeAngle(estimate.Theta-truth.Theta)), test.ShouldBeLessThan,
+		toleranceDegs*math.Pi/180)
+}
+
+func TestValidate(t *testing.T) {
+	_, err := (&Config{}).Validate("path")
+	test.That(t, err, test.ShouldBeError, resource.NewConfigValidationFieldRequiredError("path", "camera"))
+
+	_, err = (&Config{Camera: "lidar", MappingMode: "exploring"}).Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "mapping_mode must be one of")
+
+	_, err = (&Config{Camera: "lidar", MappingMode: mappingModeLocalizing}).Validate("path")
+	test.That(t, err, test.ShouldBeError, resource.NewConfigValidationFieldRequiredError("path", "existing_map"))
+
+	_, err = (&Config{Camera: "lidar", SubmapSize: -1}).Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "submap_size cannot be negative")
+
+	deps, err := (&Config{Camera: "lidar"}).Validate("path")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldResemble, []string{"lidar"})
+}
+
+func TestMappingAndLocalization(t *testing.T) {
+	ctx := context.Background()
+	poses := loopTrajectory()
+	cam := recordedCamera(t, poses)
+	s := newTestService(t, cam, &Config{})
+
+	_, err := s.Position(ctx)
+	test.That(t, err, test.ShouldBeError, errNotLocalized)
+
+	for range poses {
+		test.That(t, s.update(ctx), test.ShouldBeNil)
+	}
+	checkPosition(t, s, poses[len(poses)-1], 50, 2)
+
+	loopClosures := 0
+	for _, e := range s.graph.Edges {
+		if e.LoopClosure {
+			loopClosures++
+		}
+	}
+	test.That(t, loopClosures, test.ShouldBeGreaterThan, 0)
+
+	props, err := s.Properties(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, props.MappingMode, test.ShouldEqual, slam.MappingModeNewMap)
+	test.That(t, props.SensorInfo, test.ShouldResemble, []slam.SensorInfo{{Name: "lidar", Type: slam.SensorTypeCamera}})
+
+	// the map covers the room.
+	pcd, err := slam.PointCloudMapFull(ctx, s, false)
+	test.That(t, err, test.ShouldBeNil)
+	meta, err := pointcloud.GetPCDMetaData(bytes.NewReader(pcd))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, meta.MinX, test.ShouldAlmostEqual, -2000, 100)
+	test.That(t, meta.MaxX, test.ShouldAlmostEqual, 8000, 100)
+	test.That(t, meta.MinY, test.ShouldAlmostEqual, -2000, 100)
+	test.That(t, meta.MaxY, test.ShouldAlmostEqual, 4000, 100)
+
+	internalState, err := slam.InternalStateFull(ctx, s)
+	test.That(t, err, test.ShouldBeNil)
+
+	dir := t.TempDir()
+	pcdPath := filepath.Join(dir, "map.pcd")
+	statePath := filepath.Join(dir, "map"+internalStateFileType)
+	test.That(t, os.WriteFile(pcdPath, pcd, 0o600), test.ShouldBeNil)
+	test.That(t, os.WriteFile(statePath, internalState, 0o600), test.ShouldBeNil)
+
+	// the lidar starts off the origin of the map, so it has to be found.
+	relocated := []scanmatch.Pose2{{X: 300, Y: -200, Theta: 2}, {X: 350, Y: -180, Theta: 2.05}, {X: 400, Y: -160, Theta: 2.1}}
+
+	for _, existingMap := range []string{pcdPath, statePath} {
+		t.Run("localizes in "+filepath.Ext(existingMap)+" map", func(t *testing.T) {
+			cam := recordedCamera(t, relocated)
+			s := newTestService(t, cam, &Config{MappingMode: mappingModeLocalizing, ExistingMap: existingMap})
+			for _, truth := range relocated {
+				test.That(t, s.update(ctx), test.ShouldBeNil)
+				checkPosition(t, s, truth, 50, 2)
+			}
+			test.That(t, s.graph.Nodes, test.ShouldBeEmpty)
+
+			props, err := s.Properties(ctx)
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, props.MappingMode, test.ShouldEqual, slam.MappingModeLocalizationOnly)
+		})
+	}
+
+	t.Run("updates an existing map", func(t *testing.T) {
+		cam := recordedCamera(t, relocated)
+		s := newTestService(t, cam, &Config{MappingMode: mappingModeUpdating, ExistingMap: statePath})
+		numNodes := len(s.graph.Nodes)
+		for _, truth := range relocated {
+			test.That(t, s.update(ctx), test.ShouldBeNil)
+			checkPosition(t, s, truth, 50, 2)
+		}
+		test.That(t, len(s.graph.Nodes), test.ShouldBeGreaterThan, numNodes)
+	})
+
+	t.Run("rejects scans that do not match the map", func(t *testing.T) {
+		cam := recordedCamera(t, relocated)
+		s := newTestService(t, cam, &Config{MappingMode: mappingModeLocalizing, ExistingMap: pcdPath})
+		test.That(t, s.update(ctx), test.ShouldBeNil)
+
+		cam.NextPointCloudFunc = func(ctx context.Context) (pointcloud.PointCloud, error) {
+			return testhelper.LidarCloud(nil, 0, 0, 0, numRays, defaultMaxRangeMM), nil
+		}
+		err := s.update(ctx)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "points in range")
+
+		// a lidar moved to another room no longer matches the map, and its pose is extrapolated.
+		elsewhere := []testhelper.Wall{
+			{Start: r3.Vector{X: -20000, Y: -20000}, End: r3.Vector{X: -20000, Y: 20000}},
+			{Start: r3.Vector{X: -20000, Y: 20000}, End: r3.Vector{X: -19000, Y: 20000}},
+		}
+		cam.NextPointCloudFunc = func(ctx context.Context) (pointcloud.PointCloud, error) {
+			return testhelper.LidarCloud(elsewhere, 0, 0, 0, numRays, defaultMaxRangeMM), nil
+		}
+		err = s.update(ctx)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "scan matching failed")
+		checkPosition(t, s, relocated[0], 50, 2)
+	})
+}
+
+func TestPoseGraph(t *testing.T) {
+	// a square loop whose odometry overestimates every rotation, closed by an edge back to the start.
+	truth := []scanmatch.Pose2{
+		{X: 0, Y: 0, Theta: 0},
+		{X: 1000, Y: 0, Theta: math.Pi / 2},
+		{X: 1000, Y: 1000, Theta: math.Pi},
+		{X: 0, Y: 1000, Theta: -math.Pi / 2},
+	}
+	g := &poseGraph{}
+	drifted := truth[0]
+	for i := range truth {
+		if i > 0 {
+			step := truth[i-1].Between(truth[i])
+			step.Theta += 0.05
+			drifted = drifted.Compose(step)
+			g.Edges = append(g.Edges, newEdge(i-1, i, truth[i-1].Between(truth[i]), false))
+		}
+		g.Nodes = append(g.Nodes, &node{Pose: drifted})
+	}
+	g.Edges = append(g.Edges, newEdge(len(truth)-1, 0, truth[len(truth)-1].Between(truth[0]), true))
+
+	test.That(t, g.Nodes[3].Pose.Distance(truth[3]), test.ShouldBeGreaterThan, 50)
+	test.That(t, g.optimize(), test.ShouldBeNil)
+	for i, n := range g.Nodes {
+		test.That(t, n.Pose.Distance(truth[i]), test.ShouldBeLessThan, 1)
+		test.That(t, math.Abs(scanmatch.NormalizeAngle(n.Pose.Theta-truth[i].Theta)), test.ShouldBeLessThan, 1e-3)
+	}
+
+	// a long loop around a circle is optimized without a dense hessian over every keyframe.
+	const numNodes = 2000
+	g = &poseGraph{}
+	truth = truth[:0]
+	drifted = scanmatch.Pose2{}
+	for i := 0; i < numNodes; i++ {
+		angle := 2 * math.Pi * float64(i) / numNodes
+		truth = append(truth, scanmatch.Pose2{X: 10000 * math.Sin(angle), Y: 10000 * (1 - math.Cos(angle)), Theta: angle})
+		if i > 0 {
+			step := truth[i-1].Between(truth[i])
+			step.Theta += 1e-4
+			drifted = drifted.Compose(step)
+			g.Edges = append(g.Edges, newEdge(i-1, i, truth[i-1].Between(truth[i]), false))
+		}
+		g.Nodes = append(g.Nodes, &node{Pose: drifted})
+	}
+	g.Edges = append(g.Edges, newEdge(numNodes-1, 0, truth[numNodes-1].Between(truth[0]), true))
+
+	test.That(t, g.Nodes[numNodes/2].Pose.Distance(truth[numNodes/2]), test.ShouldBeGreaterThan, 500)
+	test.That(t, g.optimize(), test.ShouldBeNil)
+	for i, n := range g.Nodes {
+		test.That(t, n.Pose.Distance(truth[i]), test.ShouldBeLessThan, 1)
+	}
+}
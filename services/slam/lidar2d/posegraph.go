@@ -0,0 +1,215 @@
+package lidar2d
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/rdk/services/slam/internal/scanmatch"
+)
+
+const (
+	maxOptimizationIterations = 20
+	// optimization stops once no pose moves by more than this, in millimeters and radians.
+	optimizationConvergence = 1e-3
+	// the first node anchors the map, so it is held in place with a very stiff prior.
+	anchorWeight = 1e9
+)
+
+// node is a keyframe of the map: a scan and the pose it was taken at.
+type node struct {
+	Pose scanmatch.Pose2 `json:"pose"`
+	Scan scanmatch.Scan  `json:"scan"`
+}
+
+// edge constrains the pose of node To relative to node From.
+type edge struct {
+	From        int             `json:"from"`
+	To          int             `json:"to"`
+	Measurement scanmatch.Pose2 `json:"measurement"`
+	// weights are the inverse variances of the translation and rotation of the measurement.
+	TranslationWeight float64 `json:"translation_weight"`
+	RotationWeight    float64 `json:"rotation_weight"`
+	LoopClosure       bool    `json:"loop_closure,omitempty"`
+}
+
+// poseGraph is the graph of keyframes whose poses are optimized when a loop closure is found.
+type poseGraph struct {
+	Nodes []*node `json:"nodes"`
+	Edges []edge  `json:"edges"`
+}
+
+// residual returns the error of an edge given the current node poses, along with its jacobians
+// with respect to the from and to poses.
+func (g *poseGraph) residual(e edge) ([3]float64, [3][3]float64, [3][3]float64) {
+	from, to := g.Nodes[e.From].Pose, g.Nodes[e.To].Pose
+	si, ci := math.Sincos(from.Theta)
+	sz, cz := math.Sincos(e.Measurement.Theta)
+	dx, dy := to.X-from.X, to.Y-from.Y
+
+	// the translation of to relative to from, rotated into the frame of the measurement.
+	relX := ci*dx + si*dy - e.Measurement.X
+	relY := -si*dx + ci*dy - e.Measurement.Y
+	res := [3]float64{
+		cz*relX + sz*relY,
+		-sz*relX + cz*relY,
+		scanmatch.NormalizeAngle(to.Theta - from.Theta - e.Measurement.Theta),
+	}
+
+	// rotation of the measurement times the rotation of from, both transposed.
+	r00, r01 := cz*ci-sz*si, cz*si+sz*ci
+	r10, r11 := -sz*ci-cz*si, -sz*si+cz*ci
+	// the same with the derivative of the rotation of from.
+	d0 := cz*(-si*dx+ci*dy) + sz*(-ci*dx-si*dy)
+	d1 := -sz*(-si*dx+ci*dy) + cz*(-ci*dx-si*dy)
+
+	a := [3][3]float64{
+		{-r00, -r01, d0},
+		{-r10, -r11, d1},
+		{0, 0, -1},
+	}
+	b := [3][3]float64{
+		{r00, r01, 0},
+		{r10, r11, 0},
+		{0, 0, 1},
+	}
+	return res, a, b
+}
+
+// optimize adjusts the poses of the nodes to best satisfy every edge with Gauss-Newton, holding the
+// first node in place.
+func (g *poseGraph) optimize() error {
+	if len(g.Nodes) < 2 {
+		return nil
+	}
+	for iteration := 0; iteration < maxOptimizationIterations; iteration++ {
+		h := newEnvelope(len(g.Nodes), g.Edges)
+		grad := make([]float64, 3*len(g.Nodes))
+		for _, e := range g.Edges {
+			res, a, b := g.residual(e)
+			weights := [3]float64{e.TranslationWeight, e.TranslationWeight, e.RotationWeight}
+			blocks := [2][3][3]float64{a, b}
+			indices := [2]int{3 * e.From, 3 * e.To}
+			for bi := range blocks {
+				for bj := range blocks {
+					for r := 0; r < 3; r++ {
+						for c := 0; c < 3; c++ {
+							// only the lower triangle of the symmetric hessian is stored.
+							if indices[bj]+c > indices[bi]+r {
+								continue
+							}
+							v := 0.
+							for k := 0; k < 3; k++ {
+								v += blocks[bi][k][r] * weights[k] * blocks[bj][k][c]
+							}
+							h.add(indices[bi]+r, indices[bj]+c, v)
+						}
+					}
+				}
+				for r := 0; r < 3; r++ {
+					for k := 0; k < 3; k++ {
+						grad[indices[bi]+r] += blocks[bi][k][r] * weights[k] * res[k]
+					}
+				}
+			}
+		}
+		for i := 0; i < 3; i++ {
+			h.add(i, i, anchorWeight)
+		}
+
+		step, err := h.solve(grad)
+		if err != nil {
+			return errors.Wrap(err, "cannot optimize the pose graph")
+		}
+		largest := 0.
+		for i, n := range g.Nodes {
+			n.Pose.X -= step[3*i]
+			n.Pose.Y -= step[3*i+1]
+			n.Pose.Theta = scanmatch.NormalizeAngle(n.Pose.Theta - step[3*i+2])
+			for k := 0; k < 3; k++ {
+				largest = math.Max(largest, math.Abs(step[3*i+k]))
+			}
+		}
+		if largest < optimizationConvergence {
+			break
+		}
+	}
+	return nil
+}
+
+// clone returns a copy of the graph whose poses can be optimized without changing the graph. The scans are
+// shared, since they are never modified.
+func (g *poseGraph) clone() *poseGraph {
+	c := &poseGraph{Nodes: make([]*node, len(g.Nodes)), Edges: append([]edge(nil), g.Edges...)}
+	for i, n := range g.Nodes {
+		c.Nodes[i] = &node{Pose: n.Pose, Scan: n.Scan}
+	}
+	return c
+}
+
+// envelope is the hessian of a pose graph, stored as the lower triangle of each row from the column of the
+// earliest node joined to the node of the row. Its Cholesky factor fills in no further than that, so a
+// trajectory takes a narrow band along the diagonal plus a row per loop closure, rather than the quadratic
+// memory and cubic time of a dense solve.
+type envelope struct {
+	first []int
+	rows  [][]float64
+}
+
+func newEnvelope(numNodes int, edges []edge) *envelope {
+	earliest := make([]int, numNodes)
+	for i := range earliest {
+		earliest[i] = i
+	}
+	for _, e := range edges {
+		from, to := min(e.From, e.To), max(e.From, e.To)
+		earliest[to] = min(earliest[to], from)
+	}
+	m := &envelope{first: make([]int, 3*numNodes), rows: make([][]float64, 3*numNodes)}
+	for r := range m.rows {
+		m.first[r] = 3 * earliest[r/3]
+		m.rows[r] = make([]float64, r-m.first[r]+1)
+	}
+	return m
+}
+
+// add adds v to the entry at row r and column c, which must not be past r.
+func (m *envelope) add(r, c int, v float64) {
+	m.rows[r][c-m.first[r]] += v
+}
+
+// solve returns x solving m x = b, factoring m in place.
+func (m *envelope) solve(b []float64) ([]float64, error) {
+	for i, row := range m.rows {
+		for j := m.first[i]; j <= i; j++ {
+			sum := row[j-m.first[i]]
+			for k := max(m.first[i], m.first[j]); k < j; k++ {
+				sum -= row[k-m.first[i]] * m.rows[j][k-m.first[j]]
+			}
+			if j < i {
+				row[j-m.first[i]] = sum / m.rows[j][j-m.first[j]]
+				continue
+			}
+			if sum <= 0 {
+				return nil, errors.Errorf("the hessian is not positive definite at row %d", i)
+			}
+			row[i-m.first[i]] = math.Sqrt(sum)
+		}
+	}
+
+	x := append([]float64(nil), b...)
+	for i, row := range m.rows {
+		for k := m.first[i]; k < i; k++ {
+			x[i] -= row[k-m.first[i]] * x[k]
+		}
+		x[i] /= row[i-m.first[i]]
+	}
+	for i := len(m.rows) - 1; i >= 0; i-- {
+		row := m.rows[i]
+		x[i] /= row[i-m.first[i]]
+		for k := m.first[i]; k < i; k++ {
+			x[k] -= row[k-m.first[i]] * x[i]
+		}
+	}
+	return x, nil
+}
@@ -0,0 +1,504 @@
+// Package pcdlocalizer implements a slam service that localizes in a prebuilt PCD map without mapping.
+// Point clouds from a camera or lidar are registered against the map, starting from where the odometry of
+// a movement sensor predicts the robot to be, and the map itself is served unchanged.
+package pcdlocalizer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	geo "github.com/kellydunn/golang-geo"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+	goutils "go.viam.com/utils"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/components/movementsensor"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/slam"
+	"go.viam.com/rdk/services/slam/internal/scanmatch"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/utils"
+)
+
+var model = resource.DefaultModelFamily.WithModel("pcd_localizer")
+
+const (
+	defaultUpdateRateHz = 5.
+	defaultMinRangeMM   = 100.
+	defaultMaxRangeMM   = 25000.
+	defaultResolutionMM = 50.
+	defaultMinFitness   = 0.5
+
+	// scans are matched to map points no further than this from where they are predicted to land.
+	maxCorrespondenceMM = 500.
+	// scans with fewer points than this in range are skipped.
+	minScanPoints = 20
+	// after this many scans in a row fail to match, the robot is considered lost and the next scans
+	// are searched for around the predicted pose.
+	maxConsecutiveFailures = 5
+
+	setPoseCommand = "set_pose"
+	statusCommand  = "status"
+)
+
+var (
+	// the window searched around a pose hint, or around the predicted pose once the robot is lost.
+	relocalizationWindow = scanmatch.SearchWindow{
+		LinearMM:       1000,
+		LinearStepMM:   100,
+		AngularRad:     utils.DegToRad(45),
+		AngularStepRad: utils.DegToRad(2),
+	}
+
+	errNotLocalized = errors.New("the robot has not been localized in the map yet")
+)
+
+func init() {
+	resource.RegisterService(
+		slam.API,
+		model,
+		resource.Registration[slam.Service, *Config]{Constructor: newLocalizer},
+	)
+}
+
+// PoseHint is an approximate pose of the robot in the map.
+type PoseHint struct {
+	XMM       float64 `json:"x_mm"`
+	YMM       float64 `json:"y_mm"`
+	ThetaDegs float64 `json:"theta_degs"`
+}
+
+func (h PoseHint) pose() scanmatch.Pose2 {
+	return scanmatch.Pose2{X: h.XMM, Y: h.YMM, Theta: utils.DegToRad(h.ThetaDegs)}
+}
+
+// Config is the config of the pcd_localizer slam model.
+type Config struct {
+	// Map is the path of the map, in any format pointcloud.NewFromFile reads.
+	Map    string `json:"map"`
+	Camera string `json:"camera"`
+	// MovementSensor reports the odometry of the robot. Without one, the robot is assumed to keep moving as it
+	// did between the previous two scans.
+	MovementSensor string `json:"movement_sensor,omitempty"`
+	// InitialPose is where the robot is searched for in the map when the service starts, the origin of the
+	// map by default.
+	InitialPose *PoseHint `json:"initial_pose,omitempty"`
+
+	UpdateRateHz float64 `json:"update_rate_hz,omitempty"`
+	MinRangeMM   float64 `json:"min_range_mm,omitempty"`
+	MaxRangeMM   float64 `json:"max_range_mm,omitempty"`
+	ResolutionMM float64 `json:"map_resolution_mm,omitempty"`
+	// MinFitness is the fraction of a scan that has to match the map for the scan to be trusted.
+	MinFitness float64 `json:"min_fitness,omitempty"`
+
+	// the slices of the map and of the scans that are matched, in the frame of each. Both are used whole
+	// unless their max is greater than their min.
+	MapMinZMM  float64 `json:"map_min_z_mm,omitempty"`
+	MapMaxZMM  float64 `json:"map_max_z_mm,omitempty"`
+	ScanMinZMM float64 `json:"scan_min_z_mm,omitempty"`
+	ScanMaxZMM float64 `json:"scan_max_z_mm,omitempty"`
+}
+
+// Validate validates the pcd_localizer model's configuration.
+func (cfg *Config) Validate(path string) ([]string, error) {
+	if cfg.Map == "" {
+		return nil, resource.NewConfigValidationFieldRequiredError(path, "map")
+	}
+	if cfg.Camera == "" {
+		return nil, resource.NewConfigValidationFieldRequiredError(path, "camera")
+	}
+	for name, v := range map[string]float64{
+		"update_rate_hz":    cfg.UpdateRateHz,
+		"min_range_mm":      cfg.MinRangeMM,
+		"max_range_mm":      cfg.MaxRangeMM,
+		"map_resolution_mm": cfg.ResolutionMM,
+		"min_fitness":       cfg.MinFitness,
+	} {
+		if v < 0 {
+			return nil, resource.NewConfigValidationError(path, errors.Errorf("%s cannot be negative", name))
+		}
+	}
+	if cfg.MinFitness > 1 {
+		return nil, resource.NewConfigValidationError(path, errors.New("min_fitness cannot be greater than 1"))
+	}
+	if cfg.MaxRangeMM != 0 && cfg.MaxRangeMM <= cfg.MinRangeMM {
+		return nil, resource.NewConfigValidationError(path, errors.New("max_range_mm must be greater than min_range_mm"))
+	}
+	deps := []string{cfg.Camera}
+	if cfg.MovementSensor != "" {
+		deps = append(deps, cfg.MovementSensor)
+	}
+	return deps, nil
+}
+
+func applyDefaults(cfg *Config) {
+	if cfg.UpdateRateHz == 0 {
+		cfg.UpdateRateHz = defaultUpdateRateHz
+	}
+	if cfg.MinRangeMM == 0 {
+		cfg.MinRangeMM = defaultMinRangeMM
+	}
+	if cfg.MaxRangeMM == 0 {
+		cfg.MaxRangeMM = defaultMaxRangeMM
+	}
+	if cfg.ResolutionMM == 0 {
+		cfg.ResolutionMM = defaultResolutionMM
+	}
+	if cfg.MinFitness == 0 {
+		cfg.MinFitness = defaultMinFitness
+	}
+}
+
+type localizer struct {
+	resource.Named
+	resource.AlwaysRebuild
+	logger logging.Logger
+
+	cam      camera.Camera
+	odometry movementsensor.MovementSensor
+	conf     *Config
+	scanOpts scanmatch.ScanOptions
+
+	mapIndex *scanmatch.Index
+	// mapPCD is the map served by PointCloudMap.
+	mapPCD []byte
+
+	// odomOrigin is only used by update, which reads the odometry without holding mu.
+	odomOrigin *geo.Point
+
+	mu        sync.Mutex
+	localized bool
+	pose      scanmatch.Pose2
+	// searchAround is the pose the robot is searched for around in the next scan, if it is set.
+	searchAround *scanmatch.Pose2
+	// motion is the motion between the last two scans, used when there is no odometry.
+	motion       scanmatch.Pose2
+	lastOdometry *scanmatch.Pose2
+	fitness      float64
+	failures     int
+
+	workers *goutils.StoppableWorkers
+}
+
+func newLocalizer(ctx context.Context, deps resource.Dependencies, conf resource.Config, logger logging.Logger) (slam.Service, error) {
+	newConf, err := resource.NativeConfig[*Config](conf)
+	if err != nil {
+		return nil, err
+	}
+	cam, err := camera.FromDependencies(deps, newConf.Camera)
+	if err != nil {
+		return nil, err
+	}
+	var odometry movementsensor.MovementSensor
+	if newConf.MovementSensor != "" {
+		odometry, err = movementsensor.FromDependencies(deps, newConf.MovementSensor)
+		if err != nil {
+			return nil, err
+		}
+		props, err := odometry.Properties(ctx, nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error getting properties of movement sensor %v", newConf.MovementSensor)
+		}
+		if !props.PositionSupported || !props.OrientationSupported {
+			return nil, errors.Errorf("movement sensor %v must report both position and orientation to be used as odometry",
+				newConf.MovementSensor)
+		}
+	}
+	l, err := newService(conf.ResourceName(), cam, odometry, newConf, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := time.Duration(float64(time.Second) / l.conf.UpdateRateHz)
+	l.workers = goutils.NewBackgroundStoppableWorkers(func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var lastErr error
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			err := l.update(ctx)
+			// only changes are logged so that a sensor which is persistently failing does not flood the logs.
+			if err != nil && ctx.Err() == nil && (lastErr == nil || lastErr.Error() != err.Error()) {
+				l.logger.CWarnw(ctx, "failed to localize", "error", err)
+			}
+			lastErr = err
+		}
+	})
+	return l, nil
+}
+
+// newService creates the service without starting to localize, which is left to the caller.
+func newService(
+	name resource.Name,
+	cam camera.Camera,
+	odometry movementsensor.MovementSensor,
+	conf *Config,
+	logger logging.Logger,
+) (*localizer, error) {
+	applyDefaults(conf)
+	l := &localizer{
+		Named:    name.AsNamed(),
+		logger:   logger,
+		cam:      cam,
+		odometry: odometry,
+		conf:     conf,
+		scanOpts: scanmatch.ScanOptions{
+			MinRangeMM:   conf.MinRangeMM,
+			MaxRangeMM:   conf.MaxRangeMM,
+			ResolutionMM: conf.ResolutionMM,
+			MinZMM:       conf.ScanMinZMM,
+			MaxZMM:       conf.ScanMaxZMM,
+		},
+	}
+	if err := l.loadMap(); err != nil {
+		return nil, errors.Wrapf(err, "cannot load map %v", conf.Map)
+	}
+	initial := scanmatch.Pose2{}
+	if conf.InitialPose != nil {
+		initial = conf.InitialPose.pose()
+	}
+	l.pose = initial
+	l.searchAround = &initial
+	return l, nil
+}
+
+func (l *localizer) loadMap() error {
+	cloud, err := pointcloud.NewFromFile(l.conf.Map, l.logger)
+	if err != nil {
+		return err
+	}
+	points := scanmatch.ScanFromCloud(cloud, scanmatch.ScanOptions{
+		ResolutionMM: l.conf.ResolutionMM,
+		MinZMM:       l.conf.MapMinZMM,
+		MaxZMM:       l.conf.MapMaxZMM,
+	})
+	if len(points) == 0 {
+		return errors.New("map has no points in the slice that is matched against")
+	}
+	l.mapIndex = scanmatch.NewIndex(maxCorrespondenceMM)
+	l.mapIndex.Add(points...)
+
+	// PCD maps are served as they are, so that clients see the same map as the one the service was given.
+	if filepath.Ext(l.conf.Map) == ".pcd" {
+		//nolint:gosec
+		l.mapPCD, err = os.ReadFile(l.conf.Map)
+		return err
+	}
+	var buf bytes.Buffer
+	if err := pointcloud.ToPCD(cloud, &buf, pointcloud.PCDBinary); err != nil {
+		return err
+	}
+	l.mapPCD = buf.Bytes()
+	return nil
+}
+
+// readOdometry returns the pose of the robot in the frame of its odometry, with its position relative to the
+// first position the odometry reported.
+func (l *localizer) readOdometry(ctx context.Context) (scanmatch.Pose2, error) {
+	pt, _, err := l.odometry.Position(ctx, nil)
+	if err != nil {
+		return scanmatch.Pose2{}, err
+	}
+	o, err := l.odometry.Orientation(ctx, nil)
+	if err != nil {
+		return scanmatch.Pose2{}, err
+	}
+	if pt == nil || o == nil || math.IsNaN(pt.Lat()) || math.IsNaN(pt.Lng()) {
+		return scanmatch.Pose2{}, errors.Errorf("movement sensor %v did not report a valid position and orientation",
+			l.conf.MovementSensor)
+	}
+	if l.odomOrigin == nil {
+		l.odomOrigin = pt
+	}
+	p := spatialmath.GeoPointToPoint(pt, l.odomOrigin)
+	return scanmatch.Pose2{X: p.X, Y: p.Y, Theta: o.EulerAngles().Yaw}, nil
+}
+
+// update localizes the robot with the next point cloud from the camera.
+func (l *localizer) update(ctx context.Context) error {
+	cloud, err := l.cam.NextPointCloud(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "cannot get point cloud from camera %v", l.conf.Camera)
+	}
+	scan := scanmatch.ScanFromCloud(cloud, l.scanOpts)
+
+	// the odometry is read even if the scan is unusable so that the motion in between is not lost. It is read
+	// before taking the lock, so that a slow movement sensor does not hold up Position.
+	var odom *scanmatch.Pose2
+	if l.odometry != nil {
+		pose, err := l.readOdometry(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "cannot read odometry from movement sensor %v", l.conf.MovementSensor)
+		}
+		odom = &pose
+	}
+
+	l.mu.Lock()
+	pose, motion, lastOdometry, searchAround, failures := l.pose, l.motion, l.lastOdometry, l.searchAround, l.failures
+	l.mu.Unlock()
+
+	predicted := pose.Compose(motion)
+	if odom != nil {
+		predicted = pose
+		if lastOdometry != nil {
+			// the motion reported by the odometry, in the frame of the robot, is applied to the last pose in the map.
+			predicted = pose.Compose(lastOdometry.Between(*odom))
+		}
+	}
+
+	if len(scan) < minScanPoints {
+		l.commit(searchAround, odom, func() { l.pose = predicted })
+		return errors.Errorf("scan from camera %v has only %d points in range", l.conf.Camera, len(scan))
+	}
+
+	opts := scanmatch.MatchOptions{}
+	initial := predicted
+	if searchAround != nil {
+		initial = *searchAround
+		opts.Window = relocalizationWindow
+	} else if failures >= maxConsecutiveFailures {
+		opts.Window = relocalizationWindow
+	}
+	res, err := scanmatch.Match(scan, l.mapIndex, initial, opts)
+	if err == nil && res.Fitness < l.conf.MinFitness {
+		err = errors.Errorf("only %.0f%% of the scan matches the map", 100*res.Fitness)
+	}
+	if err != nil {
+		l.commit(searchAround, odom, func() {
+			l.failures++
+			l.fitness = 0
+			if searchAround == nil {
+				l.pose = predicted
+			}
+		})
+		if searchAround != nil {
+			return errors.Wrap(errNotLocalized, err.Error())
+		}
+		return errors.Wrap(err, "scan matching failed, the pose is extrapolated from odometry")
+	}
+
+	l.commit(searchAround, odom, func() {
+		if odom == nil && searchAround == nil {
+			l.motion = pose.Between(res.Pose)
+		}
+		l.pose = res.Pose
+		l.fitness = res.Fitness
+		l.failures = 0
+		l.localized = true
+		l.searchAround = nil
+	})
+	return nil
+}
+
+// commit records the odometry read by update and applies the outcome of its scan under the lock. The outcome
+// is dropped if a pose was set while the scan was being matched, since it was computed from the old pose.
+func (l *localizer) commit(searchAround, odom *scanmatch.Pose2, apply func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if odom != nil {
+		l.lastOdometry = odom
+	}
+	if l.searchAround != searchAround {
+		return
+	}
+	apply()
+}
+
+// Position returns the pose of the camera in the map.
+func (l *localizer) Position(ctx context.Context) (spatialmath.Pose, error) {
+	_, span := trace.StartSpan(ctx, "slam::pcdlocalizer::Position")
+	defer span.End()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.localized {
+		return nil, errNotLocalized
+	}
+	return l.pose.ToPose(), nil
+}
+
+// PointCloudMap returns a callback function which will return the next chunk of the map. The map is never
+// edited, so returnEditedMap is ignored.
+func (l *localizer) PointCloudMap(ctx context.Context, returnEditedMap bool) (func() ([]byte, error), error) {
+	_, span := trace.StartSpan(ctx, "slam::pcdlocalizer::PointCloudMap")
+	defer span.End()
+	return scanmatch.Chunked(l.mapPCD), nil
+}
+
+// InternalState returns a callback function which will return the next chunk of the map, since the map is
+// all the state needed to localize again.
+func (l *localizer) InternalState(ctx context.Context) (func() ([]byte, error), error) {
+	_, span := trace.StartSpan(ctx, "slam::pcdlocalizer::InternalState")
+	defer span.End()
+	return scanmatch.Chunked(l.mapPCD), nil
+}
+
+// Properties returns that the service is localizing only, and the sensors it uses.
+func (l *localizer) Properties(ctx context.Context) (slam.Properties, error) {
+	_, span := trace.StartSpan(ctx, "slam::pcdlocalizer::Properties")
+	defer span.End()
+	sensors := []slam.SensorInfo{{Name: l.conf.Camera, Type: slam.SensorTypeCamera}}
+	if l.conf.MovementSensor != "" {
+		sensors = append(sensors, slam.SensorInfo{Name: l.conf.MovementSensor, Type: slam.SensorTypeMovementSensor})
+	}
+	return slam.Properties{
+		CloudSlam:             false,
+		MappingMode:           slam.MappingModeLocalizationOnly,
+		InternalStateFileType: ".pcd",
+		SensorInfo:            sensors,
+	}, nil
+}
+
+// DoCommand accepts a "set_pose" command with an approximate pose of the robot in the map, as a PoseHint,
+// around which the robot is searched for in the next scan. A "status" command returns whether the robot is
+// localized and how well the last scan matched the map.
+func (l *localizer) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	if raw, ok := cmd[setPoseCommand]; ok {
+		var hint PoseHint
+		data, err := json.Marshal(raw)
+		if err == nil {
+			err = json.Unmarshal(data, &hint)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s expects x_mm, y_mm and theta_degs", setPoseCommand)
+		}
+		pose := hint.pose()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.searchAround = &pose
+		l.pose = pose
+		l.localized = false
+		return map[string]interface{}{setPoseCommand: true}, nil
+	}
+	if _, ok := cmd[statusCommand]; ok {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return map[string]interface{}{
+			"localized":            l.localized,
+			"fitness":              l.fitness,
+			"consecutive_failures": l.failures,
+		}, nil
+	}
+	return nil, resource.ErrDoUnimplemented
+}
+
+// Close stops localizing.
+func (l *localizer) Close(context.Context) error {
+	// we do not close the camera or movement sensor that this service depends on.
+	if l.workers != nil {
+		l.workers.Stop()
+	}
+	return nil
+}
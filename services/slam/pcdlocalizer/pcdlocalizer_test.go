@@ -0,0 +1,221 @@
+package pcdlocalizer
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/geo/r3"
+	geo "github.com/kellydunn/golang-geo"
+	"github.com/pkg/errors"
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/components/movementsensor"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/slam"
+	"go.viam.com/rdk/services/slam/internal/scanmatch"
+	"go.viam.com/rdk/services/slam/internal/testhelper"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/testutils/inject"
+	"go.viam.com/rdk/utils"
+)
+
+const numRays = 720
+
+// writeMap writes a surveyed map of the room: its walls from the floor to 2m, along with a ceiling which
+// the lidar does not see.
+func writeMap(t *testing.T) string {
+	t.Helper()
+	cloud := pointcloud.New()
+	for _, w := range testhelper.Room() {
+		length := w.End.Sub(w.Start).Norm()
+		for d := 0.; d <= length; d += 20 {
+			p := w.Start.Add(w.End.Sub(w.Start).Mul(d / length))
+			for z := 0.; z <= 2000; z += 500 {
+				test.That(t, cloud.Set(r3.Vector{X: p.X, Y: p.Y, Z: z}, nil), test.ShouldBeNil)
+			}
+		}
+	}
+	for x := -2000.; x <= 8000; x += 250 {
+		for y := -2000.; y <= 4000; y += 250 {
+			test.That(t, cloud.Set(r3.Vector{X: x, Y: y, Z: 2500}, nil), test.ShouldBeNil)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "map.pcd")
+	f, err := os.Create(path)
+	test.That(t, err, test.ShouldBeNil)
+	defer f.Close()
+	test.That(t, pointcloud.ToPCD(cloud, f, pointcloud.PCDBinary), test.ShouldBeNil)
+	return path
+}
+
+// trajectory drives forward from start, then turns in place.
+func trajectory(start scanmatch.Pose2) []scanmatch.Pose2 {
+	var poses []scanmatch.Pose2
+	pose := start
+	for i := 0; i < 20; i++ {
+		poses = append(poses, pose)
+		pose = pose.Compose(scanmatch.Pose2{X: 100})
+	}
+	for i := 0; i < 10; i++ {
+		poses = append(poses, pose)
+		pose = pose.Compose(scanmatch.Pose2{Theta: math.Pi / 18})
+	}
+	return poses
+}
+
+// simulatedRobot returns a lidar replaying what it would see along poses, and an odometry which
+// overestimates distances and turns.
+func simulatedRobot(poses []scanmatch.Pose2) (*inject.Camera, *inject.MovementSensor) {
+	room := testhelper.Room()
+	current := 0
+	next := 0
+	cam := inject.NewCamera("lidar")
+	cam.NextPointCloudFunc = func(ctx context.Context) (pointcloud.PointCloud, error) {
+		current = min(next, len(poses)-1)
+		next++
+		p := poses[current]
+		return testhelper.LidarCloud(room, p.X, p.Y, p.Theta, numRays, defaultMaxRangeMM), nil
+	}
+
+	// the odometry starts at its own origin, in its own orientation.
+	odom := []scanmatch.Pose2{{X: 0, Y: 0, Theta: 1}}
+	for i := 1; i < len(poses); i++ {
+		step := poses[i-1].Between(poses[i])
+		step = scanmatch.Pose2{X: step.X * 1.05, Y: step.Y * 1.05, Theta: step.Theta * 1.05}
+		odom = append(odom, odom[i-1].Compose(step))
+	}
+	origin := geo.NewPoint(40.7, -74.0)
+	ms := inject.NewMovementSensor("odometry")
+	ms.PropertiesFunc = func(ctx context.Context, extra map[string]interface{}) (*movementsensor.Properties, error) {
+		return &movementsensor.Properties{PositionSupported: true, OrientationSupported: true}, nil
+	}
+	ms.PositionFunc = func(ctx context.Context, extra map[string]interface{}) (*geo.Point, float64, error) {
+		o := odom[current]
+		// bearings are measured clockwise from north, which is +Y.
+		return origin.PointAtDistanceAndBearing(math.Hypot(o.X, o.Y)*1e-6, utils.RadToDeg(math.Atan2(o.X, o.Y))), 0, nil
+	}
+	ms.OrientationFunc = func(ctx context.Context, extra map[string]interface{}) (spatialmath.Orientation, error) {
+		return &spatialmath.OrientationVector{OZ: 1, Theta: odom[current].Theta}, nil
+	}
+	return cam, ms
+}
+
+func newTestService(t *testing.T, cam *inject.Camera, ms *inject.MovementSensor, conf *Config) *localizer {
+	t.Helper()
+	conf.Camera = "lidar"
+	var odometry movementsensor.MovementSensor
+	if ms != nil {
+		conf.MovementSensor = "odometry"
+		odometry = ms
+	}
+	_, err := conf.Validate("path")
+	test.That(t, err, test.ShouldBeNil)
+	l, err := newService(slam.Named("pcd_localizer"), cam, odometry, conf, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	return l
+}
+
+func checkPosition(t *testing.T, l *localizer, truth scanmatch.Pose2) {
+	t.Helper()
+	pose, err := l.Position(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+	estimate := scanmatch.FromPose(pose)
+	test.That(t, estimate.Distance(truth), test.ShouldBeLessThan, 30)
+	test.That(t, math.Abs(scanmatch.NormalizeAngle(estimate.Theta-truth.Theta)), test.ShouldBeLessThan, utils.DegToRad(1))
+}
+
+func TestValidate(t *testing.T) {
+	_, err := (&Config{Camera: "lidar"}).Validate("path")
+	test.That(t, err, test.ShouldBeError, resource.NewConfigValidationFieldRequiredError("path", "map"))
+
+	_, err = (&Config{Map: "map.pcd"}).Validate("path")
+	test.That(t, err, test.ShouldBeError, resource.NewConfigValidationFieldRequiredError("path", "camera"))
+
+	_, err = (&Config{Map: "map.pcd", Camera: "lidar", MinFitness: 2}).Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "min_fitness cannot be greater than 1")
+
+	deps, err := (&Config{Map: "map.pcd", Camera: "lidar", MovementSensor: "odometry"}).Validate("path")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldResemble, []string{"lidar", "odometry"})
+}
+
+func TestLocalize(t *testing.T) {
+	ctx := context.Background()
+	mapPath := writeMap(t)
+	// only the walls are in the plane of the lidar.
+	slice := Config{Map: mapPath, MapMinZMM: -100, MapMaxZMM: 1000}
+	start := scanmatch.Pose2{X: 2000, Y: 1500, Theta: -0.3}
+	poses := trajectory(start)
+
+	t.Run("tracks with odometry from an initial pose", func(t *testing.T) {
+		cam, ms := simulatedRobot(poses)
+		conf := slice
+		conf.InitialPose = &PoseHint{XMM: 2300, YMM: 1300, ThetaDegs: 0}
+		l := newTestService(t, cam, ms, &conf)
+
+		_, err := l.Position(ctx)
+		test.That(t, err, test.ShouldBeError, errNotLocalized)
+		for _, truth := range poses {
+			test.That(t, l.update(ctx), test.ShouldBeNil)
+			checkPosition(t, l, truth)
+		}
+
+		status, err := l.DoCommand(ctx, map[string]interface{}{statusCommand: true})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, status["localized"], test.ShouldBeTrue)
+		test.That(t, status["fitness"], test.ShouldBeGreaterThan, 0.9)
+	})
+
+	t.Run("tracks without odometry", func(t *testing.T) {
+		cam, _ := simulatedRobot(poses)
+		conf := slice
+		conf.InitialPose = &PoseHint{XMM: start.X, YMM: start.Y, ThetaDegs: utils.RadToDeg(start.Theta)}
+		l := newTestService(t, cam, nil, &conf)
+		for _, truth := range poses {
+			test.That(t, l.update(ctx), test.ShouldBeNil)
+			checkPosition(t, l, truth)
+		}
+	})
+
+	t.Run("relocalizes from a pose hint", func(t *testing.T) {
+		cam, ms := simulatedRobot(poses)
+		conf := slice
+		l := newTestService(t, cam, ms, &conf)
+
+		// the robot is too far from the origin of the map to be found there.
+		err := l.update(ctx)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, errors.Is(err, errNotLocalized), test.ShouldBeTrue)
+
+		_, err = l.DoCommand(ctx, map[string]interface{}{setPoseCommand: map[string]interface{}{
+			"x_mm": 1500, "y_mm": 2000, "theta_degs": 10,
+		}})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, l.update(ctx), test.ShouldBeNil)
+		checkPosition(t, l, poses[1])
+
+		_, err = l.DoCommand(ctx, map[string]interface{}{setPoseCommand: "here"})
+		test.That(t, err, test.ShouldNotBeNil)
+	})
+
+	t.Run("serves the map unchanged", func(t *testing.T) {
+		cam, ms := simulatedRobot(poses)
+		l := newTestService(t, cam, ms, &Config{Map: mapPath})
+		served, err := slam.PointCloudMapFull(ctx, l, false)
+		test.That(t, err, test.ShouldBeNil)
+		expected, err := os.ReadFile(mapPath)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, served, test.ShouldResemble, expected)
+
+		props, err := l.Properties(ctx)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, props.MappingMode, test.ShouldEqual, slam.MappingModeLocalizationOnly)
+		test.That(t, len(props.SensorInfo), test.ShouldEqual, 2)
+	})
+}
@@ -4,4 +4,6 @@ package register
 import (
 	// for slam models.
 	_ "go.viam.com/rdk/services/slam/fake"
+	_ "go.viam.com/rdk/services/slam/lidar2d"
+	_ "go.viam.com/rdk/services/slam/pcdlocalizer"
 )
@@ -4,12 +4,15 @@ import (
 	"context"
 
 	"github.com/pkg/errors"
+	commonpb "go.viam.com/api/common/v1"
 	servicepb "go.viam.com/api/service/vision/v1"
 	"go.viam.com/utils/protoutils"
 	"google.golang.org/protobuf/types/known/anypb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 
 	"go.viam.com/rdk/data"
+	rprotoutils "go.viam.com/rdk/protoutils"
+	"go.viam.com/rdk/resource"
 	"go.viam.com/rdk/vision/classification"
 	"go.viam.com/rdk/vision/objectdetection"
 	"go.viam.com/rdk/vision/viscapture"
@@ -19,11 +22,15 @@ type method int64
 
 const (
 	captureAllFromCamera method = iota
+	readings
 )
 
 func (m method) String() string {
-	if m == captureAllFromCamera {
+	switch m {
+	case captureAllFromCamera:
 		return "CaptureAllFromCamera"
+	case readings:
+		return "Readings"
 	}
 
 	return "Unknown"
@@ -128,6 +135,31 @@ func newCaptureAllFromCameraCollector(resource interface{}, params data.Collecto
 	return data.NewCollector(cFunc, params)
 }
 
+// newReadingsCollector captures the readings of vision services which also produce sensor-style readings,
+// such as the counts of an analytics model.
+func newReadingsCollector(res interface{}, params data.CollectorParams) (data.Collector, error) {
+	sensorResource, ok := res.(resource.Sensor)
+	if !ok {
+		return nil, errors.Errorf("vision service %q does not produce readings", params.ComponentName)
+	}
+
+	cFunc := data.CaptureFunc(func(ctx context.Context, _ map[string]*anypb.Any) (interface{}, error) {
+		values, err := sensorResource.Readings(ctx, data.FromDMExtraMap)
+		if err != nil {
+			if errors.Is(err, data.ErrNoCaptureToStore) {
+				return nil, err
+			}
+			return nil, data.FailedToReadErr(params.ComponentName, readings.String(), err)
+		}
+		protoReadings, err := rprotoutils.ReadingGoToProto(values)
+		if err != nil {
+			return nil, err
+		}
+		return commonpb.GetReadingsResponse{Readings: protoReadings}, nil
+	})
+	return data.NewCollector(cFunc, params)
+}
+
 func additionalParamExtraction(methodParams map[string]*anypb.Any) (methodParamsDecoded, error) {
 	cameraParam := methodParams["camera_name"]
 
@@ -282,3 +282,41 @@ func newVisionService2() visionservice.Service {
 
 	return v
 }
+
+type readingsVisionService struct {
+	inject.VisionService
+}
+
+func (v *readingsVisionService) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
+	return map[string]interface{}{"zones": map[string]interface{}{"dock": map[string]interface{}{"entered": 3}}}, nil
+}
+
+func TestReadingsCollector(t *testing.T) {
+	mockClock := clk.NewMock()
+	buf := tu.MockBuffer{}
+	params := data.CollectorParams{
+		ComponentName: serviceName,
+		Interval:      captureInterval,
+		Logger:        logging.NewTestLogger(t),
+		Clock:         mockClock,
+		Target:        &buf,
+	}
+
+	_, err := visionservice.NewReadingsCollector(newVisionService(), params)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "does not produce readings")
+
+	col, err := visionservice.NewReadingsCollector(&readingsVisionService{}, params)
+	test.That(t, err, test.ShouldBeNil)
+	defer col.Close()
+	col.Collect()
+	mockClock.Add(captureInterval)
+
+	tu.Retry(func() bool {
+		return buf.Length() != 0
+	}, numRetries)
+	test.That(t, buf.Length(), test.ShouldBeGreaterThan, 0)
+	test.That(t, buf.Writes[0].GetStruct().AsMap(), test.ShouldResemble, map[string]any{
+		"readings": map[string]any{"zones": map[string]any{"dock": map[string]any{"entered": 3.0}}},
+	})
+}
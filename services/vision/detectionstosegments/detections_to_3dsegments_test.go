@@ -129,3 +129,35 @@ func Test3DSegmentsFromDetector(t *testing.T) {
 	test.That(t, err, test.ShouldNotBeNil)
 	test.That(t, err.Error(), test.ShouldContainSubstring, "does not implement")
 }
+
+func Test3DSegmentsFromMaskedDetector(t *testing.T) {
+	// the mask of the detection is only two of the pixels in its box with depth.
+	mask := image.NewAlpha(image.Rect(10, 10, 20, 20))
+	mask.SetAlpha(15, 15, color.Alpha{255})
+	mask.SetAlpha(16, 14, color.Alpha{255})
+	mask.SetAlpha(17, 17, color.Alpha{255})
+	detector := func(context.Context, image.Image) ([]objectdetection.Detection, error) {
+		return []objectdetection.Detection{objectdetection.NewInstanceDetection(image.Rect(10, 10, 20, 20), 0.5, "yes", mask, nil)}, nil
+	}
+	seg, err := segmentation.DetectionSegmenter(detector, 0, 0, 0.2)
+	test.That(t, err, test.ShouldBeNil)
+	cam := &inject.Camera{}
+	cam.PropertiesFunc = func(ctx context.Context) (camera.Properties, error) {
+		return camera.Properties{}, nil
+	}
+	cam.ImagesFunc = func(ctx context.Context) ([]camera.NamedImage, resource.ResponseMetadata, error) {
+		img := rimage.NewImage(150, 150)
+		dm := rimage.NewEmptyDepthMap(150, 150)
+		dm.Set(15, 15, rimage.Depth(3))
+		dm.Set(16, 14, rimage.Depth(10))
+		dm.Set(12, 12, rimage.Depth(7))
+		return []camera.NamedImage{{img, "color"}, {dm, "depth"}}, resource.ResponseMetadata{CapturedAt: time.Now()}, nil
+	}
+	objects, err := seg(context.Background(), cam)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, objects, test.ShouldHaveLength, 1)
+	// the pixel outside the mask and the one in it without depth are left out.
+	test.That(t, objects[0].Size(), test.ShouldEqual, 2)
+	_, ok := objects[0].At(15, 15, 3)
+	test.That(t, ok, test.ShouldBeTrue)
+}
@@ -0,0 +1,108 @@
+// Package fiducialdetector detects fiducial markers such as AprilTags and ArUco markers, labeling each
+// detection with the family and ID of its marker.
+package fiducialdetector
+
+import (
+	"context"
+	"image"
+	"math"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/robot"
+	"go.viam.com/rdk/services/vision"
+	"go.viam.com/rdk/utils"
+	"go.viam.com/rdk/vision/fiducial"
+	"go.viam.com/rdk/vision/objectdetection"
+	"go.viam.com/rdk/vision/objecttracking"
+)
+
+var model = resource.DefaultModelFamily.WithModel("fiducial_detector")
+
+// fullScoreMargin is the decision margin, in gray levels, at which a marker without wrong bits scores 1.
+const fullScoreMargin = 128
+
+func init() {
+	resource.RegisterService(vision.API, model, resource.Registration[vision.Service, *Config]{
+		DeprecatedRobotConstructor: func(
+			ctx context.Context, r any, c resource.Config, logger logging.Logger,
+		) (vision.Service, error) {
+			attrs, err := resource.NativeConfig[*Config](c)
+			if err != nil {
+				return nil, err
+			}
+			actualR, err := utils.AssertType[robot.Robot](r)
+			if err != nil {
+				return nil, err
+			}
+			return registerFiducialDetector(ctx, c.ResourceName(), attrs, actualR)
+		},
+	})
+}
+
+// Config specifies the family of markers to detect.
+type Config struct {
+	// Family is a built in family or the path of an AprilTag family's C source or an OpenCV dictionary.
+	Family string `json:"family"`
+	// MaxHamming is how many wrong bits are corrected, defaulting to as many as the family reliably can.
+	MaxHamming *int `json:"max_hamming,omitempty"`
+}
+
+// Validate ensures all parts of the config are valid.
+func (cfg *Config) Validate(path string) ([]string, error) {
+	if cfg.Family == "" {
+		return nil, resource.NewConfigValidationFieldRequiredError(path, "family")
+	}
+	if cfg.MaxHamming != nil && *cfg.MaxHamming < 0 {
+		return nil, resource.NewConfigValidationError(path, errors.New("max_hamming cannot be negative"))
+	}
+	return nil, nil
+}
+
+// registerFiducialDetector creates a new fiducial detector from the config.
+func registerFiducialDetector(
+	ctx context.Context,
+	name resource.Name,
+	conf *Config,
+	r robot.Robot,
+) (vision.Service, error) {
+	_, span := trace.StartSpan(ctx, "service::vision::registerFiducialDetector")
+	defer span.End()
+	if conf == nil {
+		return nil, errors.New("config for fiducial detector cannot be nil")
+	}
+	family, err := fiducial.LoadFamily(conf.Family)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error registering fiducial detector %q", name)
+	}
+	maxHamming := -1
+	if conf.MaxHamming != nil {
+		maxHamming = *conf.MaxHamming
+	}
+	detector, err := fiducial.NewDetector(family, maxHamming)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error registering fiducial detector %q", name)
+	}
+	return vision.NewService(name, r, nil, nil, newDetector(detector), nil)
+}
+
+// newDetector returns an object detector whose detections are the bounding boxes of markers, labeled
+// "family:id". A detection scores its decision margin as a fraction of fullScoreMargin, halved for every
+// wrong bit corrected.
+func newDetector(detector *fiducial.Detector) objectdetection.Detector {
+	return func(ctx context.Context, img image.Image) ([]objectdetection.Detection, error) {
+		_, span := trace.StartSpan(ctx, "service::vision::fiducialdetector::Detect")
+		defer span.End()
+		markers := detector.Detect(img)
+		detections := make([]objectdetection.Detection, 0, len(markers))
+		for _, m := range markers {
+			score := math.Min(m.DecisionMargin/fullScoreMargin, 1) / math.Pow(2, float64(m.Hamming))
+			detections = append(detections,
+				objectdetection.NewDetection(m.BoundingBox(), score, objecttracking.TrackLabel(m.Family, m.ID)))
+		}
+		return detections, nil
+	}
+}
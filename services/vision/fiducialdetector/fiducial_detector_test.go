@@ -0,0 +1,80 @@
+package fiducialdetector
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/services/vision"
+	"go.viam.com/rdk/testutils/inject"
+	"go.viam.com/rdk/vision/fiducial"
+	"go.viam.com/rdk/vision/objecttracking"
+)
+
+func TestConfigValidate(t *testing.T) {
+	deps, err := (&Config{Family: fiducial.ArucoOriginal}).Validate("path")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldBeEmpty)
+	_, err = (&Config{}).Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "family")
+	maxHamming := -1
+	_, err = (&Config{Family: fiducial.ArucoOriginal, MaxHamming: &maxHamming}).Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "max_hamming")
+}
+
+func TestFiducialDetector(t *testing.T) {
+	ctx := context.Background()
+	r := &inject.Robot{}
+	name := vision.Named("tags")
+
+	_, err := registerFiducialDetector(ctx, name, nil, r)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "cannot be nil")
+	_, err = registerFiducialDetector(ctx, name, &Config{Family: "tag36h11"}, r)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "unknown family")
+
+	srv, err := registerFiducialDetector(ctx, name, &Config{Family: fiducial.ArucoOriginal}, r)
+	test.That(t, err, test.ShouldBeNil)
+	props, err := srv.GetProperties(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, props.DetectionSupported, test.ShouldBeTrue)
+	test.That(t, props.ClassificationSupported, test.ShouldBeFalse)
+
+	// marker 99 drawn 10 pixels a cell at 30, 40.
+	family := fiducial.NewArucoOriginal()
+	img := image.NewGray(image.Rect(0, 0, 160, 160))
+	for i := range img.Pix {
+		img.Pix[i] = 230
+	}
+	for y := 0; y < 70; y++ {
+		for x := 0; x < 70; x++ {
+			v := uint8(20)
+			for b := range family.BitX {
+				if family.BitX[b] == x/10 && family.BitY[b] == y/10 && family.Codes[99]>>(family.NBits()-1-b)&1 == 1 {
+					v = 230
+				}
+			}
+			img.SetGray(30+x, 40+y, color.Gray{Y: v})
+		}
+	}
+	dets, err := srv.Detections(ctx, img, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, dets, test.ShouldHaveLength, 1)
+	test.That(t, dets[0].Label(), test.ShouldEqual, objecttracking.TrackLabel(fiducial.ArucoOriginal, 99))
+	test.That(t, dets[0].Score(), test.ShouldBeGreaterThan, 0.5)
+	box := dets[0].BoundingBox()
+	test.That(t, box.Min.X, test.ShouldBeBetweenOrEqual, 29, 31)
+	test.That(t, box.Min.Y, test.ShouldBeBetweenOrEqual, 39, 41)
+	test.That(t, box.Max.X, test.ShouldBeBetweenOrEqual, 99, 101)
+	test.That(t, box.Max.Y, test.ShouldBeBetweenOrEqual, 109, 111)
+
+	dets, err = srv.Detections(ctx, image.NewGray(image.Rect(0, 0, 50, 50)), nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, dets, test.ShouldBeEmpty)
+}
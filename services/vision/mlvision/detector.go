@@ -20,10 +20,17 @@ import (
 )
 
 const (
-	detectorLocationName = "location"
-	detectorCategoryName = "category"
-	detectorScoreName    = "score"
-	detectorInputName    = "image"
+	detectorLocationName  = "location"
+	detectorCategoryName  = "category"
+	detectorScoreName     = "score"
+	detectorInputName     = "image"
+	detectorMaskName      = "mask"
+	detectorKeypointsName = "keypoints"
+	// defaultMaskThreshold is the value above which a pixel of a mask is part of the object.
+	defaultMaskThreshold = 0.5
+	// defaultSoftNMSMinScore is the score below which Soft-NMS filters out detections if the config has
+	// no minimum confidence.
+	defaultSoftNMSMinScore = 0.001
 )
 
 func attemptToBuildDetector(mlm mlmodel.Service,
@@ -66,7 +73,7 @@ func attemptToBuildDetector(mlm mlmodel.Service,
 	// creates postprocessor to filter on labels and confidences
 	postprocessor := createDetectionFilter(params.DefaultConfidence, params.LabelConfidenceMap)
 
-	return func(ctx context.Context, img image.Image) ([]objectdetection.Detection, error) {
+	var detector objectdetection.Detector = func(ctx context.Context, img image.Image) ([]objectdetection.Detection, error) {
 		origW, origH := img.Bounds().Dx(), img.Bounds().Dy()
 		resizeW := inWidth
 		if resizeW == -1 {
@@ -116,8 +123,19 @@ func attemptToBuildDetector(mlm mlmodel.Service,
 			return nil, err
 		}
 
+		// masks and keypoints are optional, and left out of the tensors the detections are found in
+		maskName, keypointsName := findInstanceTensorNames(outMap, outNameMap)
+		detectionMap := outMap
+		if maskName != "" || keypointsName != "" {
+			detectionMap = ml.Tensors{}
+			for name, t := range outMap {
+				if name != maskName && name != keypointsName {
+					detectionMap[name] = t
+				}
+			}
+		}
 		// use the outNameMap to find the tensor names, or guess and cache the names
-		locationName, categoryName, scoreName, err := findDetectionTensorNames(outMap, outNameMap)
+		locationName, categoryName, scoreName, err := findDetectionTensorNames(detectionMap, outNameMap)
 		if err != nil {
 			return nil, err
 		}
@@ -162,6 +180,10 @@ func attemptToBuildDetector(mlm mlmodel.Service,
 				len(locations),
 			)
 		}
+		instances, err := readInstanceTensors(outMap, maskName, keypointsName, len(scores))
+		if err != nil {
+			return nil, err
+		}
 		detections := make([]objectdetection.Detection, 0, len(scores))
 		detectionBoxesAreProportional := false
 		for i := 0; i < len(scores); i++ {
@@ -186,20 +208,37 @@ func attemptToBuildDetector(mlm mlmodel.Service,
 			rect := image.Rect(int(xmin), int(ymin), int(xmax), int(ymax))
 			labelNum := int(utils.Clamp(categories[i], 0, math.MaxInt))
 
-			if labels == nil {
-				detections = append(detections, objectdetection.NewDetection(rect, scores[i], strconv.Itoa(labelNum)))
-			} else {
+			label := strconv.Itoa(labelNum)
+			if labels != nil {
 				if labelNum >= len(labels) {
 					return nil, errors.Errorf("cannot access label number %v from label file with %v labels", labelNum, len(labels))
 				}
-				detections = append(detections, objectdetection.NewDetection(rect, scores[i], labels[labelNum]))
+				label = labels[labelNum]
+			}
+			if instances == nil {
+				detections = append(detections, objectdetection.NewDetection(rect, scores[i], label))
+				continue
 			}
+			mask := instances.maskOf(i, rect, origW, origH, params)
+			keypoints := instances.keypointsOf(i, detectionBoxesAreProportional, origW, origH, params)
+			detections = append(detections, objectdetection.NewInstanceDetection(rect, scores[i], label, mask, keypoints))
 		}
 		if postprocessor != nil {
 			detections = postprocessor(detections)
 		}
 		return detections, nil
-	}, nil
+	}
+	if params.TileWidth > 0 && params.TileHeight > 0 {
+		detector, err = objectdetection.NewTiledDetector(detector,
+			image.Pt(params.TileWidth, params.TileHeight), params.TileOverlap, params.TileIncludeFullImage)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if nms := createNMSFilter(params); nms != nil {
+		return objectdetection.Build(nil, detector, nms)
+	}
+	return detector, nil
 }
 
 func extractCategoriesFromScores(scores []float64, nCategories int) ([]float64, []float64, error) {
@@ -454,3 +493,21 @@ func createDetectionFilter(minConf float64, labelMap map[string]float64) objectd
 	}
 	return nil
 }
+
+// createNMSFilter creates a post processor function that performs non-maximum suppression on the outputs of
+// the model, or returns nil if it is not configured. Soft-NMS filters out the detections whose scores it
+// decays below the minimum confidence.
+func createNMSFilter(params *MLModelConfig) objectdetection.Postprocessor {
+	switch {
+	case params.SoftNMSSigma > 0:
+		minScore := params.DefaultConfidence
+		if minScore == 0 {
+			minScore = defaultSoftNMSMinScore
+		}
+		return objectdetection.NewSoftNMSFilter(params.SoftNMSSigma, minScore, params.NMSClassAgnostic)
+	case params.NMSIoUThreshold > 0:
+		return objectdetection.NewNMSFilter(params.NMSIoUThreshold, params.NMSClassAgnostic)
+	default:
+		return nil
+	}
+}
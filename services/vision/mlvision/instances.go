@@ -0,0 +1,143 @@
+package mlvision
+
+import (
+	"image"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/rdk/ml"
+	"go.viam.com/rdk/utils"
+	"go.viam.com/rdk/vision/objectdetection"
+)
+
+// findInstanceTensorNames returns the names of the mask and keypoints tensors output by an instance
+// segmentation or pose model, using the nameMap if the tensors were remapped, or "" for those it doesn't have.
+func findInstanceTensorNames(outMap ml.Tensors, nameMap *sync.Map) (string, string) {
+	find := func(name string) string {
+		if mapName, ok := nameMap.Load(name); ok {
+			if mapped, ok := mapName.(string); ok {
+				if _, ok := outMap[mapped]; ok {
+					return mapped
+				}
+			}
+		}
+		if _, ok := outMap[name]; ok {
+			return name
+		}
+		return ""
+	}
+	return find(detectorMaskName), find(detectorKeypointsName)
+}
+
+// instanceTensors are the masks and keypoints of the detections output by a model.
+type instanceTensors struct {
+	// masks are one maskHeight by maskWidth mask of probabilities per detection.
+	masks                 []float64
+	maskHeight, maskWidth int
+	// keypoints are nKeypoints of x, y and optionally a score, in keypointDims values, per detection.
+	keypoints                []float64
+	nKeypoints, keypointDims int
+}
+
+// readInstanceTensors reads the mask and keypoints tensors for the given number of detections, or returns
+// nil if the model outputs neither.
+func readInstanceTensors(outMap ml.Tensors, maskName, keypointsName string, nDetections int) (*instanceTensors, error) {
+	if maskName == "" && keypointsName == "" {
+		return nil, nil
+	}
+	var it instanceTensors
+	if maskName != "" {
+		shape := outMap[maskName].Shape()
+		if len(shape) < 3 {
+			return nil, errors.Errorf("mask tensor must have at least 3 dimensions, got shape %v", shape)
+		}
+		masks, err := convertToFloat64Slice(outMap[maskName].Data())
+		if err != nil {
+			return nil, err
+		}
+		it.masks, it.maskHeight, it.maskWidth = masks, shape[len(shape)-2], shape[len(shape)-1]
+		if len(masks) != nDetections*it.maskHeight*it.maskWidth {
+			return nil, errors.Errorf("mask tensor of shape %v does not have a mask for each of %d detections", shape, nDetections)
+		}
+	}
+	if keypointsName != "" {
+		shape := outMap[keypointsName].Shape()
+		if len(shape) < 3 || (shape[len(shape)-1] != 2 && shape[len(shape)-1] != 3) {
+			return nil, errors.Errorf("keypoints tensor must have a last dimension of x, y and an optional score, got shape %v", shape)
+		}
+		keypoints, err := convertToFloat64Slice(outMap[keypointsName].Data())
+		if err != nil {
+			return nil, err
+		}
+		it.keypoints, it.nKeypoints, it.keypointDims = keypoints, shape[len(shape)-2], shape[len(shape)-1]
+		if len(keypoints) != nDetections*it.nKeypoints*it.keypointDims {
+			return nil, errors.Errorf("keypoints tensor of shape %v does not have keypoints for each of %d detections",
+				shape, nDetections)
+		}
+	}
+	return &it, nil
+}
+
+// maskOf returns the mask of the ith detection within its bounding box in an image of origW by origH, sampled
+// from a mask of the whole image or of the box.
+func (it *instanceTensors) maskOf(i int, box image.Rectangle, origW, origH int, params *MLModelConfig) *image.Alpha {
+	if it.masks == nil || box.Empty() {
+		return nil
+	}
+	threshold := params.MaskThreshold
+	if threshold == 0 {
+		threshold = defaultMaskThreshold
+	}
+	values := it.masks[i*it.maskHeight*it.maskWidth : (i+1)*it.maskHeight*it.maskWidth]
+	// the area of the image the mask covers.
+	area := image.Rect(0, 0, origW, origH)
+	if params.MasksRelativeToBox {
+		area = box
+	}
+	mask := image.NewAlpha(box)
+	for y := box.Min.Y; y < box.Max.Y; y++ {
+		my := int((float64(y-area.Min.Y) + 0.5) * float64(it.maskHeight) / float64(area.Dy()))
+		for x := box.Min.X; x < box.Max.X; x++ {
+			mx := int((float64(x-area.Min.X) + 0.5) * float64(it.maskWidth) / float64(area.Dx()))
+			if my < it.maskHeight && mx < it.maskWidth && values[my*it.maskWidth+mx] > threshold {
+				mask.Pix[mask.PixOffset(x, y)] = 255
+			}
+		}
+	}
+	return mask
+}
+
+// keypointsOf returns the keypoints of the ith detection in an image of origW by origH, which are proportional
+// to the size of the image if its bounding boxes are.
+func (it *instanceTensors) keypointsOf(i int, proportional bool, origW, origH int, params *MLModelConfig) []objectdetection.Keypoint {
+	if it.keypoints == nil {
+		return nil
+	}
+	keypoints := make([]objectdetection.Keypoint, 0, it.nKeypoints)
+	for k := 0; k < it.nKeypoints; k++ {
+		values := it.keypoints[(i*it.nKeypoints+k)*it.keypointDims:]
+		score := 1.
+		if it.keypointDims == 3 {
+			score = values[2]
+		}
+		if score < params.KeypointMinConfidence {
+			continue
+		}
+		var x, y float64
+		if proportional {
+			x = utils.Clamp(values[0], 0, 1) * float64(origW-1)
+			y = utils.Clamp(values[1], 0, 1) * float64(origH-1)
+		} else {
+			x = utils.Clamp(values[0], 0, float64(origW-1))
+			y = utils.Clamp(values[1], 0, float64(origH-1))
+		}
+		name := strconv.Itoa(k)
+		if k < len(params.KeypointNames) {
+			name = params.KeypointNames[k]
+		}
+		keypoints = append(keypoints, objectdetection.Keypoint{Name: name, Point: image.Pt(int(x), int(y)), Score: score})
+	}
+	return keypoints
+}
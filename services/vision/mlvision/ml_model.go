@@ -71,6 +71,23 @@ type MLModelConfig struct {
 	DefaultConfidence  float64            `json:"default_minimum_confidence"`
 	LabelConfidenceMap map[string]float64 `json:"label_confidences"`
 	LabelPath          string             `json:"label_path"`
+	// optional parameters to filter out overlapping detections by non-maximum suppression, with either
+	// a hard threshold of intersection over union, or decaying their scores by Soft-NMS.
+	NMSIoUThreshold  float64 `json:"nms_iou_threshold"`
+	SoftNMSSigma     float64 `json:"soft_nms_sigma"`
+	NMSClassAgnostic bool    `json:"nms_class_agnostic"`
+	// optional parameters to run the detector on overlapping tiles of the image, to find small objects
+	// in images much larger than the input of the model.
+	TileWidth            int     `json:"tile_width_px"`
+	TileHeight           int     `json:"tile_height_px"`
+	TileOverlap          float64 `json:"tile_overlap"`
+	TileIncludeFullImage bool    `json:"tile_include_full_image"`
+	// optional parameters for decoding the masks and keypoints of instances, if the model outputs them.
+	// Masks are of the whole image, unless they are relative to the bounding box of each detection.
+	MaskThreshold         float64  `json:"mask_threshold"`
+	MasksRelativeToBox    bool     `json:"masks_relative_to_box"`
+	KeypointNames         []string `json:"keypoint_names"`
+	KeypointMinConfidence float64  `json:"keypoint_min_confidence"`
 }
 
 // Validate will add the ModelName as an implicit dependency to the robot.
@@ -99,6 +116,27 @@ func (conf *MLModelConfig) Validate(path string) ([]string, error) {
 			return nil, errors.New("input_image_std_dev is not allowed to have 0 values, will cause division by 0")
 		}
 	}
+	if conf.NMSIoUThreshold < 0 || conf.NMSIoUThreshold > 1 {
+		return nil, errors.New("nms_iou_threshold must be between 0 and 1")
+	}
+	if conf.SoftNMSSigma < 0 {
+		return nil, errors.New("soft_nms_sigma cannot be negative")
+	}
+	if conf.NMSIoUThreshold != 0 && conf.SoftNMSSigma != 0 {
+		return nil, errors.New("only one of nms_iou_threshold and soft_nms_sigma can be set")
+	}
+	if conf.TileWidth < 0 || conf.TileHeight < 0 {
+		return nil, errors.New("tile_width_px and tile_height_px cannot be negative")
+	}
+	if (conf.TileWidth == 0) != (conf.TileHeight == 0) {
+		return nil, errors.New("tile_width_px and tile_height_px must be set together")
+	}
+	if conf.TileOverlap < 0 || conf.TileOverlap >= 1 {
+		return nil, errors.New("tile_overlap must be at least 0 and less than 1")
+	}
+	if conf.MaskThreshold < 0 || conf.MaskThreshold > 1 {
+		return nil, errors.New("mask_threshold must be between 0 and 1")
+	}
 	return []string{conf.ModelName}, nil
 }
 
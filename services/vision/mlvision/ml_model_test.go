@@ -2,16 +2,21 @@ package mlvision
 
 import (
 	"context"
+	"image"
 	"sync"
 	"testing"
 
 	"go.viam.com/test"
 	"go.viam.com/utils/artifact"
+	"gorgonia.org/tensor"
 
 	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/ml"
 	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/services/mlmodel"
 	"go.viam.com/rdk/testutils/inject"
 	"go.viam.com/rdk/vision/classification"
+	"go.viam.com/rdk/vision/objectdetection"
 )
 
 func BenchmarkAddMLVisionModel(b *testing.B) {
@@ -559,3 +564,159 @@ func getNClassifications(
 		test.That(t, res[0].Score(), test.ShouldNotBeNil)
 	}
 }
+
+func TestMLDetectorNMSAndTiling(t *testing.T) {
+	ctx := context.Background()
+	out := mockEffDetModel("test-model", "")
+	mlm, ok := out.(*inject.MLModelService)
+	test.That(t, ok, test.ShouldBeTrue)
+	infer := mlm.InferFunc
+	inferCount := 0
+	mlm.InferFunc = func(ctx context.Context, tensors ml.Tensors) (ml.Tensors, error) {
+		inferCount++
+		return infer(ctx, tensors)
+	}
+	pic := image.NewRGBA(image.Rect(0, 0, 640, 320))
+
+	detector, err := attemptToBuildDetector(mlm, &sync.Map{}, &sync.Map{}, &MLModelConfig{})
+	test.That(t, err, test.ShouldBeNil)
+	all, err := detector(ctx, pic)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, all, test.ShouldHaveLength, 25)
+
+	detector, err = attemptToBuildDetector(mlm, &sync.Map{}, &sync.Map{}, &MLModelConfig{NMSIoUThreshold: 0.4})
+	test.That(t, err, test.ShouldBeNil)
+	suppressed, err := detector(ctx, pic)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(suppressed), test.ShouldBeLessThan, len(all))
+	test.That(t, suppressed[0].Score(), test.ShouldAlmostEqual, 0.81640625)
+
+	detector, err = attemptToBuildDetector(mlm, &sync.Map{}, &sync.Map{},
+		&MLModelConfig{NMSIoUThreshold: 0.4, NMSClassAgnostic: true})
+	test.That(t, err, test.ShouldBeNil)
+	agnostic, err := detector(ctx, pic)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(agnostic), test.ShouldBeLessThanOrEqualTo, len(suppressed))
+
+	detector, err = attemptToBuildDetector(mlm, &sync.Map{}, &sync.Map{},
+		&MLModelConfig{SoftNMSSigma: 0.5, DefaultConfidence: 0.05})
+	test.That(t, err, test.ShouldBeNil)
+	soft, err := detector(ctx, pic)
+	test.That(t, err, test.ShouldBeNil)
+	for _, d := range soft {
+		test.That(t, d.Score(), test.ShouldBeGreaterThanOrEqualTo, 0.05)
+	}
+
+	// the model is run on each of the two tiles of the image, and on the whole of it.
+	inferCount = 0
+	detector, err = attemptToBuildDetector(mlm, &sync.Map{}, &sync.Map{},
+		&MLModelConfig{TileWidth: 320, TileHeight: 320, TileIncludeFullImage: true, NMSIoUThreshold: 0.4})
+	test.That(t, err, test.ShouldBeNil)
+	tiled, err := detector(ctx, pic)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, inferCount, test.ShouldEqual, 3)
+	test.That(t, tiled, test.ShouldNotBeEmpty)
+	for _, d := range tiled {
+		test.That(t, d.BoundingBox().In(pic.Bounds()), test.ShouldBeTrue)
+	}
+}
+
+func TestMLModelConfigValidate(t *testing.T) {
+	for _, conf := range []MLModelConfig{
+		{ModelName: "m", NMSIoUThreshold: 1.5},
+		{ModelName: "m", SoftNMSSigma: -1},
+		{ModelName: "m", NMSIoUThreshold: 0.5, SoftNMSSigma: 0.5},
+		{ModelName: "m", TileWidth: 320},
+		{ModelName: "m", TileWidth: -320, TileHeight: 320},
+		{ModelName: "m", TileWidth: 320, TileHeight: 320, TileOverlap: 1},
+	} {
+		_, err := conf.Validate("")
+		test.That(t, err, test.ShouldNotBeNil)
+	}
+	conf := MLModelConfig{ModelName: "m", NMSIoUThreshold: 0.5, TileWidth: 320, TileHeight: 320, TileOverlap: 0.2}
+	deps, err := conf.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldResemble, []string{"m"})
+}
+
+func TestMLDetectorInstances(t *testing.T) {
+	ctx := context.Background()
+	mlm := inject.NewMLModelService("seg-model")
+	mlm.MetadataFunc = func(ctx context.Context) (mlmodel.MLMetadata, error) {
+		return mlmodel.MLMetadata{
+			Inputs:  []mlmodel.TensorInfo{{Name: "image", DataType: "uint8", Shape: []int{1, 8, 8, 3}}},
+			Outputs: []mlmodel.TensorInfo{{Name: "location"}, {Name: "category"}, {Name: "score"}, {Name: "seg"}, {Name: "kps"}},
+		}, nil
+	}
+	// two detections, the first of the left half of the image and the second of the bottom right quarter,
+	// with 4 by 4 masks of the whole image and two keypoints each.
+	masks := []float32{
+		1, 1, 0, 0,
+		1, 1, 0, 0,
+		1, 0, 0, 0,
+		1, 0, 0, 0,
+
+		0, 0, 0, 0,
+		0, 0, 0, 0,
+		0, 0, 0.9, 0.9,
+		0, 0, 0.9, 0.2,
+	}
+	mlm.InferFunc = func(ctx context.Context, tensors ml.Tensors) (ml.Tensors, error) {
+		return ml.Tensors{
+			"location": tensor.New(tensor.WithShape(1, 2, 4), tensor.WithBacking([]float32{0, 0, 0.5, 1, 0.5, 0.5, 1, 1})),
+			"category": tensor.New(tensor.WithShape(1, 2), tensor.WithBacking([]float32{0, 1})),
+			"score":    tensor.New(tensor.WithShape(1, 2), tensor.WithBacking([]float32{0.9, 0.8})),
+			"seg":      tensor.New(tensor.WithShape(1, 2, 4, 4), tensor.WithBacking(masks)),
+			"kps": tensor.New(tensor.WithShape(1, 2, 2, 3), tensor.WithBacking([]float32{
+				0.25, 0.1, 0.9, 0.25, 0.9, 0.3,
+				0.75, 0.75, 0.8, 0.9, 0.9, 0.7,
+			})),
+		}, nil
+	}
+	outNameMap := &sync.Map{}
+	outNameMap.Store("mask", "seg")
+	outNameMap.Store("keypoints", "kps")
+	conf := &MLModelConfig{BoxOrder: []int{0, 1, 2, 3}, KeypointNames: []string{"top", "bottom"}, KeypointMinConfidence: 0.5}
+	detector, err := attemptToBuildDetector(mlm, &sync.Map{}, outNameMap, conf)
+	test.That(t, err, test.ShouldBeNil)
+	detections, err := detector(ctx, image.NewRGBA(image.Rect(0, 0, 81, 81)))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, detections, test.ShouldHaveLength, 2)
+
+	test.That(t, *detections[0].BoundingBox(), test.ShouldResemble, image.Rect(0, 0, 40, 80))
+	mask := objectdetection.MaskOf(detections[0])
+	test.That(t, mask, test.ShouldNotBeNil)
+	test.That(t, mask.Bounds(), test.ShouldResemble, image.Rect(0, 0, 40, 80))
+	test.That(t, mask.AlphaAt(30, 10).A, test.ShouldEqual, 255)
+	test.That(t, mask.AlphaAt(30, 70).A, test.ShouldEqual, 0)
+	test.That(t, mask.AlphaAt(10, 70).A, test.ShouldEqual, 255)
+	// the low confidence keypoint is left out.
+	test.That(t, objectdetection.KeypointsOf(detections[0]), test.ShouldResemble, []objectdetection.Keypoint{
+		{Name: "top", Point: image.Pt(20, 8), Score: float64(float32(0.9))},
+	})
+
+	mask = objectdetection.MaskOf(detections[1])
+	test.That(t, mask.Bounds(), test.ShouldResemble, image.Rect(40, 40, 80, 80))
+	test.That(t, mask.AlphaAt(45, 45).A, test.ShouldEqual, 255)
+	test.That(t, mask.AlphaAt(70, 70).A, test.ShouldEqual, 0)
+	test.That(t, objectdetection.KeypointsOf(detections[1]), test.ShouldHaveLength, 2)
+
+	// masks relative to the bounding box cover all of it.
+	conf.MasksRelativeToBox = true
+	detector, err = attemptToBuildDetector(mlm, &sync.Map{}, outNameMap, conf)
+	test.That(t, err, test.ShouldBeNil)
+	detections, err = detector(ctx, image.NewRGBA(image.Rect(0, 0, 81, 81)))
+	test.That(t, err, test.ShouldBeNil)
+	mask = objectdetection.MaskOf(detections[0])
+	test.That(t, mask.AlphaAt(15, 10).A, test.ShouldEqual, 255)
+	test.That(t, mask.AlphaAt(25, 10).A, test.ShouldEqual, 0)
+
+	// without the remapped names, the detections have no masks or keypoints, and the extra tensors are
+	// not mistaken for the detection tensors.
+	detector, err = attemptToBuildDetector(mlm, &sync.Map{}, &sync.Map{}, &MLModelConfig{BoxOrder: []int{0, 1, 2, 3}})
+	test.That(t, err, test.ShouldBeNil)
+	detections, err = detector(ctx, image.NewRGBA(image.Rect(0, 0, 81, 81)))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, detections, test.ShouldHaveLength, 2)
+	test.That(t, objectdetection.MaskOf(detections[0]), test.ShouldBeNil)
+}
@@ -0,0 +1,57 @@
+// Package motiondetector creates bounding boxes around what moves in front of a static camera, using a
+// background subtraction heuristic rather than a model.
+package motiondetector
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/robot"
+	"go.viam.com/rdk/services/vision"
+	"go.viam.com/rdk/utils"
+	objdet "go.viam.com/rdk/vision/objectdetection"
+)
+
+var model = resource.DefaultModelFamily.WithModel("motion_detector")
+
+func init() {
+	resource.RegisterService(vision.API, model, resource.Registration[vision.Service, *objdet.MotionDetectorConfig]{
+		DeprecatedRobotConstructor: func(
+			ctx context.Context, r any, c resource.Config, logger logging.Logger,
+		) (vision.Service, error) {
+			attrs, err := resource.NativeConfig[*objdet.MotionDetectorConfig](c)
+			if err != nil {
+				return nil, err
+			}
+			actualR, err := utils.AssertType[robot.Robot](r)
+			if err != nil {
+				return nil, err
+			}
+			return registerMotionDetector(ctx, c.ResourceName(), attrs, actualR)
+		},
+	})
+}
+
+// registerMotionDetector creates a new Motion Detector from the config. The background it learns is shared by
+// all the images it is given, so it should only be given the frames of one camera.
+func registerMotionDetector(
+	ctx context.Context,
+	name resource.Name,
+	conf *objdet.MotionDetectorConfig,
+	r robot.Robot,
+) (vision.Service, error) {
+	_, span := trace.StartSpan(ctx, "service::vision::registerMotionDetector")
+	defer span.End()
+	if conf == nil {
+		return nil, errors.New("object detection config for motion detector cannot be nil")
+	}
+	detector, err := objdet.NewMotionDetector(conf)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error registering motion detector %q", name)
+	}
+	return vision.NewService(name, r, nil, nil, detector, nil)
+}
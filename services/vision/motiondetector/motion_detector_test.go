@@ -0,0 +1,62 @@
+package motiondetector
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/services/vision"
+	"go.viam.com/rdk/testutils/inject"
+	"go.viam.com/rdk/vision/objectdetection"
+)
+
+func frame(square image.Rectangle) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.RGBA{30, 60, 90, 255}}, image.Point{}, draw.Src)
+	draw.Draw(img, square, &image.Uniform{color.White}, image.Point{}, draw.Src)
+	return img
+}
+
+func TestMotionDetector(t *testing.T) {
+	ctx := context.Background()
+	r := &inject.Robot{}
+	name := vision.Named("test_md")
+	srv, err := registerMotionDetector(ctx, name, &objectdetection.MotionDetectorConfig{MinArea: 10}, r)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, srv.Name(), test.ShouldResemble, name)
+
+	// Test properties. Should support detections and not classifications or object PCDs
+	props, err := srv.GetProperties(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, props.DetectionSupported, test.ShouldEqual, true)
+	test.That(t, props.ClassificationSupported, test.ShouldEqual, false)
+	test.That(t, props.ObjectPCDsSupported, test.ShouldEqual, false)
+
+	// the first frame is the background
+	det, err := srv.Detections(ctx, frame(image.Rectangle{}), nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, det, test.ShouldBeEmpty)
+
+	for x := 0; x < 40; x += 10 {
+		square := image.Rect(x, 10, x+8, 18)
+		det, err = srv.Detections(ctx, frame(square), nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, det, test.ShouldHaveLength, 1)
+		test.That(t, *det[0].BoundingBox(), test.ShouldResemble, square)
+		test.That(t, det[0].Label(), test.ShouldEqual, "motion")
+	}
+
+	// with error - bad parameters
+	conf := &objectdetection.MotionDetectorConfig{}
+	conf.LearningRate = 2
+	_, err = registerMotionDetector(ctx, name, conf, r)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "learning_rate")
+
+	// with error - nil parameters
+	_, err = registerMotionDetector(ctx, name, nil, r)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "cannot be nil")
+}
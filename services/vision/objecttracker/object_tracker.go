@@ -0,0 +1,150 @@
+// Package objecttracker tracks the objects detected by another vision service, replacing its detections
+// with ones which carry a track ID that stays the same for as long as the object is tracked.
+package objecttracker
+
+import (
+	"context"
+	"image"
+
+	"github.com/benbjohnson/clock"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/robot"
+	"go.viam.com/rdk/services/vision"
+	"go.viam.com/rdk/utils"
+	"go.viam.com/rdk/vision/objectdetection"
+	"go.viam.com/rdk/vision/objecttracking"
+)
+
+var model = resource.DefaultModelFamily.WithModel("object_tracker")
+
+func init() {
+	resource.RegisterService(vision.API, model, resource.Registration[vision.Service, *Config]{
+		DeprecatedRobotConstructor: func(
+			ctx context.Context, r any, c resource.Config, logger logging.Logger,
+		) (vision.Service, error) {
+			attrs, err := resource.NativeConfig[*Config](c)
+			if err != nil {
+				return nil, err
+			}
+			actualR, err := utils.AssertType[robot.Robot](r)
+			if err != nil {
+				return nil, err
+			}
+			return registerObjectTracker(ctx, c.ResourceName(), attrs, actualR, clock.New())
+		},
+	})
+}
+
+// Config specifies the detector whose detections are tracked and how they are tracked. Unset parameters
+// default to those of SORT.
+type Config struct {
+	DetectorName string `json:"detector_name"`
+	// MinHits is how many consecutive images a new object has to be detected in before it is reported.
+	MinHits *int `json:"min_hits,omitempty"`
+	// MaxAge is how many consecutive images an object can be missed in before its track dies.
+	MaxAge *int `json:"max_age,omitempty"`
+	// MatchThreshold is the lowest score from 0 to 1 a detection is associated to a track with.
+	MatchThreshold *float64 `json:"match_threshold,omitempty"`
+	// AppearanceWeight is how much the colors of detections contribute to their score, from 0 to 1.
+	AppearanceWeight *float64 `json:"appearance_weight,omitempty"`
+}
+
+// Validate ensures all parts of the config are valid and adds the detector as a dependency.
+func (cfg *Config) Validate(path string) ([]string, error) {
+	if cfg.DetectorName == "" {
+		return nil, resource.NewConfigValidationFieldRequiredError(path, "detector_name")
+	}
+	if err := cfg.trackingConfig().Validate(); err != nil {
+		return nil, resource.NewConfigValidationError(path, err)
+	}
+	return []string{cfg.DetectorName}, nil
+}
+
+func (cfg *Config) trackingConfig() objecttracking.Config {
+	trackingConfig := objecttracking.DefaultConfig()
+	if cfg.MinHits != nil {
+		trackingConfig.MinHits = *cfg.MinHits
+	}
+	if cfg.MaxAge != nil {
+		trackingConfig.MaxAge = *cfg.MaxAge
+	}
+	if cfg.MatchThreshold != nil {
+		trackingConfig.MatchThreshold = *cfg.MatchThreshold
+	}
+	if cfg.AppearanceWeight != nil {
+		trackingConfig.AppearanceWeight = *cfg.AppearanceWeight
+	}
+	return trackingConfig
+}
+
+// objectTracker is a vision service whose detector tracks the detections of another vision service.
+// The tracks can be retrieved or reset through DoCommand.
+type objectTracker struct {
+	vision.Service
+	tracker *objecttracking.Tracker
+}
+
+func registerObjectTracker(
+	ctx context.Context,
+	name resource.Name,
+	conf *Config,
+	r robot.Robot,
+	clk clock.Clock,
+) (vision.Service, error) {
+	_, span := trace.StartSpan(ctx, "service::vision::registerObjectTracker")
+	defer span.End()
+	if conf == nil {
+		return nil, errors.New("config for object tracker cannot be nil")
+	}
+	detectorService, err := vision.FromRobot(r, conf.DetectorName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not find necessary dependency, detector %q", conf.DetectorName)
+	}
+	tracker := objecttracking.NewTracker(conf.trackingConfig(), clk)
+	detector := func(ctx context.Context, img image.Image) ([]objectdetection.Detection, error) {
+		detections, err := detectorService.Detections(ctx, img, nil)
+		if err != nil {
+			return nil, err
+		}
+		return tracker.Update(img, detections), nil
+	}
+	svc, err := vision.NewService(name, r, nil, nil, detector, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &objectTracker{Service: svc, tracker: tracker}, nil
+}
+
+// DoCommand returns the state of every track, including their velocities, when called with "get_tracks"
+// and forgets every track when called with "reset".
+func (ot *objectTracker) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	if _, ok := cmd["reset"]; ok {
+		ot.tracker.Reset()
+		return map[string]interface{}{}, nil
+	}
+	if _, ok := cmd["get_tracks"]; ok {
+		tracks := []interface{}{}
+		for _, t := range ot.tracker.Tracks() {
+			tracks = append(tracks, map[string]interface{}{
+				"track_id":   t.ID,
+				"label":      t.Label,
+				"x_min":      t.BoundingBox.Min.X,
+				"y_min":      t.BoundingBox.Min.Y,
+				"x_max":      t.BoundingBox.Max.X,
+				"y_max":      t.BoundingBox.Max.Y,
+				"velocity_x": t.VelocityX,
+				"velocity_y": t.VelocityY,
+				"score":      t.Score,
+				"hits":       t.Hits,
+				"age":        t.Age,
+				"missed":     t.Missed,
+			})
+		}
+		return map[string]interface{}{"tracks": tracks}, nil
+	}
+	return nil, resource.ErrDoUnimplemented
+}
@@ -0,0 +1,115 @@
+package objecttracker
+
+import (
+	"context"
+	"image"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/vision"
+	"go.viam.com/rdk/testutils/inject"
+	"go.viam.com/rdk/vision/objectdetection"
+	"go.viam.com/rdk/vision/objecttracking"
+)
+
+// movingDetector detects a ball which moves right by 10 pixels every time it is called.
+type movingDetector struct {
+	x int
+}
+
+func (m *movingDetector) Detect(context.Context, image.Image) ([]objectdetection.Detection, error) {
+	det := objectdetection.NewDetection(image.Rect(m.x, 10, m.x+40, 50), 0.9, "ball")
+	m.x += 10
+	return []objectdetection.Detection{det}, nil
+}
+
+func TestConfigValidate(t *testing.T) {
+	cfg := &Config{}
+	_, err := cfg.Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "detector_name")
+
+	cfg.DetectorName = "detector"
+	deps, err := cfg.Validate("path")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldResemble, []string{"detector"})
+	test.That(t, cfg.trackingConfig(), test.ShouldResemble, objecttracking.DefaultConfig())
+
+	threshold := 1.5
+	cfg.MatchThreshold = &threshold
+	_, err = cfg.Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "match threshold")
+}
+
+func TestObjectTracker(t *testing.T) {
+	r := &inject.Robot{}
+	m := &movingDetector{}
+	name := vision.Named("testDetector")
+	svc, err := vision.NewService(name, r, nil, nil, m.Detect, nil)
+	test.That(t, err, test.ShouldBeNil)
+	r.ResourceNamesFunc = func() []resource.Name {
+		return []resource.Name{name}
+	}
+	r.ResourceByNameFunc = func(n resource.Name) (resource.Resource, error) {
+		if n.Name == "testDetector" {
+			return svc, nil
+		}
+		return nil, resource.NewNotFoundError(n)
+	}
+
+	// bad registration, no parameters
+	trackerName := vision.Named("tracker")
+	mockClock := clock.NewMock()
+	_, err = registerObjectTracker(context.Background(), trackerName, nil, r, mockClock)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "cannot be nil")
+	// bad registration, no such detector
+	_, err = registerObjectTracker(context.Background(), trackerName, &Config{DetectorName: "noDetector"}, r, mockClock)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "could not find necessary dependency")
+	// successful registration
+	minHits := 1
+	tracker, err := registerObjectTracker(
+		context.Background(), trackerName, &Config{DetectorName: "testDetector", MinHits: &minHits}, r, mockClock)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, tracker.Name(), test.ShouldResemble, trackerName)
+
+	// the ball keeps its track ID from image to image.
+	img := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	for i := 0; i < 5; i++ {
+		dets, err := tracker.Detections(context.Background(), img, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, dets, test.ShouldHaveLength, 1)
+		test.That(t, dets[0].Label(), test.ShouldEqual, objecttracking.TrackLabel("ball", 1))
+		mockClock.Add(100 * time.Millisecond)
+	}
+
+	resp, err := tracker.DoCommand(context.Background(), map[string]interface{}{"get_tracks": true})
+	test.That(t, err, test.ShouldBeNil)
+	tracks := resp["tracks"].([]interface{})
+	test.That(t, tracks, test.ShouldHaveLength, 1)
+	track := tracks[0].(map[string]interface{})
+	test.That(t, track["track_id"], test.ShouldEqual, 1)
+	test.That(t, track["label"], test.ShouldEqual, "ball")
+	test.That(t, track["velocity_x"], test.ShouldAlmostEqual, 100, 10)
+	test.That(t, track["hits"], test.ShouldEqual, 5)
+
+	// after a reset the ball is given a new track ID.
+	_, err = tracker.DoCommand(context.Background(), map[string]interface{}{"reset": true})
+	test.That(t, err, test.ShouldBeNil)
+	dets, err := tracker.Detections(context.Background(), img, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, dets, test.ShouldHaveLength, 1)
+	test.That(t, dets[0].Label(), test.ShouldEqual, objecttracking.TrackLabel("ball", 2))
+
+	_, err = tracker.DoCommand(context.Background(), map[string]interface{}{"unknown": true})
+	test.That(t, err, test.ShouldEqual, resource.ErrDoUnimplemented)
+	// does not implement classifier
+	_, err = tracker.Classifications(context.Background(), img, 1, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+}
@@ -5,5 +5,9 @@ import (
 	// for vision models.
 	_ "go.viam.com/rdk/services/vision"
 	_ "go.viam.com/rdk/services/vision/fake"
+	_ "go.viam.com/rdk/services/vision/fiducialdetector"
 	_ "go.viam.com/rdk/services/vision/mlvision"
+	_ "go.viam.com/rdk/services/vision/motiondetector"
+	_ "go.viam.com/rdk/services/vision/objecttracker"
+	_ "go.viam.com/rdk/services/vision/zoneanalytics"
 )
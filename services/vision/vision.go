@@ -35,6 +35,10 @@ func init() {
 		API:        API,
 		MethodName: captureAllFromCamera.String(),
 	}, newCaptureAllFromCameraCollector)
+	data.RegisterCollector(data.MethodMetadata{
+		API:        API,
+		MethodName: readings.String(),
+	}, newReadingsCollector)
 }
 
 // A Service implements various computer vision algorithms like detection and segmentation.
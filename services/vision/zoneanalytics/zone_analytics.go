@@ -0,0 +1,314 @@
+// Package zoneanalytics counts objects detected by another vision service entering and exiting polygon
+// zones and crossing lines drawn in image coordinates. Events are only generated for detections carrying
+// a track ID, so the wrapped vision service is usually an object tracker.
+package zoneanalytics
+
+import (
+	"context"
+	"image"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/golang/geo/r2"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+	goutils "go.viam.com/utils"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/robot"
+	"go.viam.com/rdk/services/vision"
+	"go.viam.com/rdk/utils"
+	"go.viam.com/rdk/vision/objectdetection"
+	"go.viam.com/rdk/vision/zones"
+)
+
+var model = resource.DefaultModelFamily.WithModel("zone_analytics")
+
+func init() {
+	resource.RegisterService(vision.API, model, resource.Registration[vision.Service, *Config]{
+		DeprecatedRobotConstructor: func(
+			ctx context.Context, r any, c resource.Config, logger logging.Logger,
+		) (vision.Service, error) {
+			attrs, err := resource.NativeConfig[*Config](c)
+			if err != nil {
+				return nil, err
+			}
+			actualR, err := utils.AssertType[robot.Robot](r)
+			if err != nil {
+				return nil, err
+			}
+			return registerZoneAnalytics(ctx, c.ResourceName(), attrs, actualR, clock.New(), logger)
+		},
+	})
+}
+
+// Point is a point in image coordinates, in pixels.
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+func (p Point) r2() r2.Point {
+	return r2.Point{X: p.X, Y: p.Y}
+}
+
+// ZoneConfig is a polygon zone. Only objects with one of its labels are counted, or every object if it
+// has none.
+type ZoneConfig struct {
+	Name   string   `json:"name"`
+	Points []Point  `json:"points"`
+	Labels []string `json:"labels,omitempty"`
+}
+
+// LineConfig is a line whose crossings are counted. Objects cross it forward when they move from its left
+// to its right as seen looking from start to end in the image.
+type LineConfig struct {
+	Name   string   `json:"name"`
+	Start  Point    `json:"start"`
+	End    Point    `json:"end"`
+	Labels []string `json:"labels,omitempty"`
+}
+
+// Config specifies the vision service whose detections are analyzed and the zones and lines they are
+// analyzed for.
+type Config struct {
+	DetectorName string `json:"detector_name"`
+	// CameraName is a camera whose images are analyzed continuously, at FrequencyHz. Otherwise only the
+	// images this service is asked to detect objects in are analyzed.
+	CameraName  string       `json:"camera_name,omitempty"`
+	FrequencyHz float64      `json:"frequency_hz,omitempty"`
+	Zones       []ZoneConfig `json:"zones,omitempty"`
+	Lines       []LineConfig `json:"lines,omitempty"`
+	// Anchor is the point of each bounding box which locates its object, "center" or "bottom".
+	Anchor string `json:"anchor,omitempty"`
+	// ExitTimeoutSecs is how long a tracked object can go undetected before it exits the zones it is in.
+	ExitTimeoutSecs float64 `json:"exit_timeout_secs,omitempty"`
+	// MaxEvents is how many of the latest events are kept.
+	MaxEvents int `json:"max_events,omitempty"`
+}
+
+const defaultFrequencyHz = 1.
+
+// Validate ensures all parts of the config are valid and adds the detector and camera as dependencies.
+func (cfg *Config) Validate(path string) ([]string, error) {
+	if cfg.DetectorName == "" {
+		return nil, resource.NewConfigValidationFieldRequiredError(path, "detector_name")
+	}
+	if len(cfg.Zones) == 0 && len(cfg.Lines) == 0 {
+		return nil, resource.NewConfigValidationError(path, errors.New("must have at least one zone or line"))
+	}
+	if cfg.FrequencyHz < 0 {
+		return nil, resource.NewConfigValidationError(path, errors.New("frequency_hz cannot be negative"))
+	}
+	if err := cfg.analyzerConfig().Validate(); err != nil {
+		return nil, resource.NewConfigValidationError(path, err)
+	}
+	deps := []string{cfg.DetectorName}
+	if cfg.CameraName != "" {
+		deps = append(deps, cfg.CameraName)
+	}
+	return deps, nil
+}
+
+func (cfg *Config) analyzerConfig() zones.Config {
+	analyzerConfig := zones.Config{
+		Anchor:      zones.Anchor(cfg.Anchor),
+		ExitTimeout: time.Duration(cfg.ExitTimeoutSecs * float64(time.Second)),
+		MaxEvents:   cfg.MaxEvents,
+	}
+	for _, z := range cfg.Zones {
+		zone := zones.Zone{Name: z.Name, Labels: z.Labels}
+		for _, p := range z.Points {
+			zone.Polygon = append(zone.Polygon, p.r2())
+		}
+		analyzerConfig.Zones = append(analyzerConfig.Zones, zone)
+	}
+	for _, l := range cfg.Lines {
+		analyzerConfig.Lines = append(analyzerConfig.Lines, zones.Line{
+			Name: l.Name, Start: l.Start.r2(), End: l.End.r2(), Labels: l.Labels,
+		})
+	}
+	return analyzerConfig
+}
+
+// zoneAnalytics is a vision service whose detector passes on the detections of another vision service
+// after analyzing them. Its counts and events are read through DoCommand, or as readings which the data
+// manager can capture.
+type zoneAnalytics struct {
+	vision.Service
+	analyzer *zones.Analyzer
+	workers  *goutils.StoppableWorkers
+
+	mu sync.Mutex
+	// lastRead is the sequence number of the last event returned by Readings.
+	lastRead uint64
+}
+
+func registerZoneAnalytics(
+	ctx context.Context,
+	name resource.Name,
+	conf *Config,
+	r robot.Robot,
+	clk clock.Clock,
+	logger logging.Logger,
+) (vision.Service, error) {
+	_, span := trace.StartSpan(ctx, "service::vision::registerZoneAnalytics")
+	defer span.End()
+	if conf == nil {
+		return nil, errors.New("config for zone analytics cannot be nil")
+	}
+	detectorService, err := vision.FromRobot(r, conf.DetectorName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not find necessary dependency, detector %q", conf.DetectorName)
+	}
+	za := &zoneAnalytics{analyzer: zones.NewAnalyzer(conf.analyzerConfig(), clk)}
+	detector := func(ctx context.Context, img image.Image) ([]objectdetection.Detection, error) {
+		detections, err := detectorService.Detections(ctx, img, nil)
+		if err != nil {
+			return nil, err
+		}
+		za.analyzer.Update(detections)
+		return detections, nil
+	}
+	closer := func(ctx context.Context) error {
+		if za.workers != nil {
+			za.workers.Stop()
+		}
+		return nil
+	}
+	za.Service, err = vision.NewService(name, r, closer, nil, detector, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if conf.CameraName != "" {
+		frequencyHz := conf.FrequencyHz
+		if frequencyHz == 0 {
+			frequencyHz = defaultFrequencyHz
+		}
+		interval := time.Duration(float64(time.Second) / frequencyHz)
+		za.workers = goutils.NewBackgroundStoppableWorkers(func(ctx context.Context) {
+			ticker := clk.Ticker(interval)
+			defer ticker.Stop()
+			var lastErr error
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+				_, err := za.DetectionsFromCamera(ctx, conf.CameraName, nil)
+				// only changes are logged so that a camera which is persistently failing does not flood the logs.
+				if err != nil && ctx.Err() == nil && (lastErr == nil || lastErr.Error() != err.Error()) {
+					logger.CWarnw(ctx, "failed to analyze camera", "camera", conf.CameraName, "error", err)
+				}
+				lastErr = err
+			}
+		})
+	}
+	return za, nil
+}
+
+// Readings returns the counts of every zone and line along with the events since the last time Readings
+// was called, so that capturing the readings captures every event once.
+func (za *zoneAnalytics) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
+	za.mu.Lock()
+	defer za.mu.Unlock()
+	events := za.analyzer.Events(za.lastRead)
+	if len(events) > 0 {
+		za.lastRead = events[len(events)-1].Seq
+	}
+	readings := summaryToMap(za.analyzer.Summary())
+	readings["events"] = eventsToList(events)
+	return readings, nil
+}
+
+// DoCommand returns the counts of every zone and line when called with "get_summary", the events after
+// the sequence number given as "since" when called with "get_events", and forgets every count and event
+// when called with "reset".
+func (za *zoneAnalytics) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	if _, ok := cmd["reset"]; ok {
+		za.analyzer.Reset()
+		return map[string]interface{}{}, nil
+	}
+	if _, ok := cmd["get_summary"]; ok {
+		return summaryToMap(za.analyzer.Summary()), nil
+	}
+	if args, ok := cmd["get_events"]; ok {
+		var since uint64
+		if argsMap, ok := args.(map[string]interface{}); ok {
+			if s, ok := argsMap["since"].(float64); ok && s > 0 {
+				since = uint64(s)
+			}
+		}
+		return map[string]interface{}{"events": eventsToList(za.analyzer.Events(since))}, nil
+	}
+	return nil, resource.ErrDoUnimplemented
+}
+
+func zoneCountsToMap(c zones.ZoneCounts) map[string]interface{} {
+	return map[string]interface{}{
+		"occupancy":       c.Occupancy,
+		"entered":         c.Entered,
+		"exited":          c.Exited,
+		"mean_dwell_secs": c.MeanDwell().Seconds(),
+		"max_dwell_secs":  c.MaxDwell.Seconds(),
+	}
+}
+
+func lineCountsToMap(c zones.LineCounts) map[string]interface{} {
+	return map[string]interface{}{
+		"forward":  c.Forward,
+		"backward": c.Backward,
+		"total":    c.Forward + c.Backward,
+	}
+}
+
+func summaryToMap(summary zones.Summary) map[string]interface{} {
+	zoneMap := map[string]interface{}{}
+	for name, z := range summary.Zones {
+		m := zoneCountsToMap(z.ZoneCounts)
+		byLabel := map[string]interface{}{}
+		for label, c := range z.ByLabel {
+			byLabel[label] = zoneCountsToMap(c)
+		}
+		m["by_label"] = byLabel
+		zoneMap[name] = m
+	}
+	lineMap := map[string]interface{}{}
+	for name, l := range summary.Lines {
+		m := lineCountsToMap(l.LineCounts)
+		byLabel := map[string]interface{}{}
+		for label, c := range l.ByLabel {
+			byLabel[label] = lineCountsToMap(c)
+		}
+		m["by_label"] = byLabel
+		lineMap[name] = m
+	}
+	return map[string]interface{}{"zones": zoneMap, "lines": lineMap}
+}
+
+func eventsToList(events []zones.Event) []interface{} {
+	list := make([]interface{}, 0, len(events))
+	for _, e := range events {
+		m := map[string]interface{}{
+			"seq":      e.Seq,
+			"time":     e.Time.UTC().Format(time.RFC3339Nano),
+			"type":     string(e.Type),
+			"region":   e.Region,
+			"label":    e.Label,
+			"track_id": e.TrackID,
+		}
+		switch e.Type {
+		case zones.EventExit:
+			m["dwell_secs"] = e.Dwell.Seconds()
+		case zones.EventCross:
+			m["direction"] = string(e.Direction)
+		case zones.EventEnter:
+		}
+		list = append(list, m)
+	}
+	return list
+}
@@ -0,0 +1,208 @@
+package zoneanalytics
+
+import (
+	"context"
+	"image"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/pkg/errors"
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/gostream"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/vision"
+	"go.viam.com/rdk/testutils/inject"
+	"go.viam.com/rdk/vision/objectdetection"
+	"go.viam.com/rdk/vision/objecttracking"
+)
+
+// walkingDetector detects a tracked person who walks right by 20 pixels every time it is called.
+type walkingDetector struct {
+	x int
+}
+
+func (w *walkingDetector) Detect(context.Context, image.Image) ([]objectdetection.Detection, error) {
+	det := objectdetection.NewDetection(
+		image.Rect(w.x-5, 45, w.x+5, 55), 0.9, objecttracking.TrackLabel("person", 7))
+	w.x += 20
+	return []objectdetection.Detection{det}, nil
+}
+
+func testConfig() *Config {
+	return &Config{
+		DetectorName: "testDetector",
+		Zones: []ZoneConfig{{
+			Name:   "dock",
+			Points: []Point{{X: 100, Y: 0}, {X: 140, Y: 0}, {X: 140, Y: 100}, {X: 100, Y: 100}},
+		}},
+		Lines: []LineConfig{{Name: "door", Start: Point{X: 50, Y: 100}, End: Point{X: 50, Y: 0}}},
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	cfg := testConfig()
+	deps, err := cfg.Validate("path")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldResemble, []string{"testDetector"})
+	cfg.CameraName = "cam"
+	deps, err = cfg.Validate("path")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldResemble, []string{"testDetector", "cam"})
+
+	_, err = (&Config{Zones: cfg.Zones}).Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "detector_name")
+	_, err = (&Config{DetectorName: "testDetector"}).Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "at least one zone or line")
+	cfg.Zones[0].Points = cfg.Zones[0].Points[:2]
+	_, err = cfg.Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "at least 3 points")
+}
+
+func newTestRobot(t *testing.T, cam camera.Camera) *inject.Robot {
+	t.Helper()
+	r := &inject.Robot{}
+	w := &walkingDetector{x: 10}
+	name := vision.Named("testDetector")
+	svc, err := vision.NewService(name, r, nil, nil, w.Detect, nil)
+	test.That(t, err, test.ShouldBeNil)
+	r.ResourceNamesFunc = func() []resource.Name {
+		return []resource.Name{name, camera.Named("cam")}
+	}
+	r.ResourceByNameFunc = func(n resource.Name) (resource.Resource, error) {
+		switch n.Name {
+		case "testDetector":
+			return svc, nil
+		case "cam":
+			if cam != nil {
+				return cam, nil
+			}
+		}
+		return nil, resource.NewNotFoundError(n)
+	}
+	return r
+}
+
+func TestZoneAnalytics(t *testing.T) {
+	r := newTestRobot(t, nil)
+	logger := logging.NewTestLogger(t)
+	mockClock := clock.NewMock()
+	name := vision.Named("zones")
+
+	// bad registration, no parameters
+	_, err := registerZoneAnalytics(context.Background(), name, nil, r, mockClock, logger)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "cannot be nil")
+	// bad registration, no such detector
+	cfg := testConfig()
+	cfg.DetectorName = "noDetector"
+	_, err = registerZoneAnalytics(context.Background(), name, cfg, r, mockClock, logger)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "could not find necessary dependency")
+	// successful registration
+	za, err := registerZoneAnalytics(context.Background(), name, testConfig(), r, mockClock, logger)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, za.Close(context.Background()), test.ShouldBeNil)
+	}()
+
+	// the person walks from x=10 through the door at x=50 and into the dock at x=110.
+	img := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	for i := 0; i < 6; i++ {
+		dets, err := za.Detections(context.Background(), img, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, dets, test.ShouldHaveLength, 1)
+		test.That(t, dets[0].Label(), test.ShouldEqual, objecttracking.TrackLabel("person", 7))
+		mockClock.Add(time.Second)
+	}
+
+	summary, err := za.DoCommand(context.Background(), map[string]interface{}{"get_summary": true})
+	test.That(t, err, test.ShouldBeNil)
+	dock := summary["zones"].(map[string]interface{})["dock"].(map[string]interface{})
+	test.That(t, dock["occupancy"], test.ShouldEqual, 1)
+	test.That(t, dock["entered"], test.ShouldEqual, 1)
+	test.That(t, dock["by_label"].(map[string]interface{})["person"].(map[string]interface{})["entered"], test.ShouldEqual, 1)
+	door := summary["lines"].(map[string]interface{})["door"].(map[string]interface{})
+	test.That(t, door["forward"], test.ShouldEqual, 1)
+	test.That(t, door["total"], test.ShouldEqual, 1)
+
+	resp, err := za.DoCommand(context.Background(), map[string]interface{}{"get_events": map[string]interface{}{}})
+	test.That(t, err, test.ShouldBeNil)
+	events := resp["events"].([]interface{})
+	test.That(t, events, test.ShouldHaveLength, 2)
+	cross := events[0].(map[string]interface{})
+	test.That(t, cross["type"], test.ShouldEqual, "cross")
+	test.That(t, cross["region"], test.ShouldEqual, "door")
+	test.That(t, cross["direction"], test.ShouldEqual, "forward")
+	test.That(t, cross["label"], test.ShouldEqual, "person")
+	test.That(t, cross["track_id"], test.ShouldEqual, 7)
+	test.That(t, events[1].(map[string]interface{})["type"], test.ShouldEqual, "enter")
+	resp, err = za.DoCommand(context.Background(), map[string]interface{}{"get_events": map[string]interface{}{"since": 1.0}})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp["events"], test.ShouldHaveLength, 1)
+
+	// the person walks out of the dock after 2 seconds, which readings report once.
+	sensor, ok := za.(resource.Sensor)
+	test.That(t, ok, test.ShouldBeTrue)
+	readings, err := sensor.Readings(context.Background(), nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, readings["events"], test.ShouldHaveLength, 2)
+	for i := 0; i < 2; i++ {
+		_, err = za.Detections(context.Background(), img, nil)
+		test.That(t, err, test.ShouldBeNil)
+		mockClock.Add(time.Second)
+	}
+	readings, err = sensor.Readings(context.Background(), nil)
+	test.That(t, err, test.ShouldBeNil)
+	events = readings["events"].([]interface{})
+	test.That(t, events, test.ShouldHaveLength, 1)
+	test.That(t, events[0].(map[string]interface{})["type"], test.ShouldEqual, "exit")
+	test.That(t, events[0].(map[string]interface{})["dwell_secs"], test.ShouldEqual, 2.0)
+	dock = readings["zones"].(map[string]interface{})["dock"].(map[string]interface{})
+	test.That(t, dock["exited"], test.ShouldEqual, 1)
+	test.That(t, dock["mean_dwell_secs"], test.ShouldEqual, 2.0)
+
+	_, err = za.DoCommand(context.Background(), map[string]interface{}{"reset": true})
+	test.That(t, err, test.ShouldBeNil)
+	readings, err = sensor.Readings(context.Background(), nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, readings["events"], test.ShouldBeEmpty)
+
+	_, err = za.DoCommand(context.Background(), map[string]interface{}{"unknown": true})
+	test.That(t, err, test.ShouldEqual, resource.ErrDoUnimplemented)
+}
+
+func TestZoneAnalyticsCamera(t *testing.T) {
+	cam := &inject.Camera{}
+	images := make(chan struct{}, 10)
+	cam.StreamFunc = func(ctx context.Context, errHandlers ...gostream.ErrorHandler) (gostream.VideoStream, error) {
+		images <- struct{}{}
+		return nil, errors.New("no stream")
+	}
+	r := newTestRobot(t, cam)
+	mockClock := clock.NewMock()
+	cfg := testConfig()
+	cfg.CameraName = "cam"
+	cfg.FrequencyHz = 2
+	za, err := registerZoneAnalytics(context.Background(), vision.Named("zones"), cfg, r, mockClock, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+
+	// the camera is read every half second, once the worker has started waiting on its ticker.
+	read := false
+	for i := 0; i < 100 && !read; i++ {
+		mockClock.Add(500 * time.Millisecond)
+		select {
+		case <-images:
+			read = true
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	test.That(t, read, test.ShouldBeTrue)
+	test.That(t, za.Close(context.Background()), test.ShouldBeNil)
+}
@@ -0,0 +1,190 @@
+package fiducial
+
+import (
+	"image"
+	"math"
+
+	"github.com/golang/geo/r2"
+	"github.com/pkg/errors"
+
+	"go.viam.com/rdk/rimage/transform"
+)
+
+// minCharucoCorners is how many corners of a ChArUco board must be found in an image to calibrate with it.
+const minCharucoCorners = 6
+
+// CharucoBoard is a calibration target which is a checkerboard with a marker in each of its white squares,
+// so that its corners can be identified even when the board is partly out of view. The top left square is
+// white, and so are the squares whose row and column have the same parity. Markers are numbered row by row
+// from the top left, as OpenCV lays out its ChArUco boards.
+type CharucoBoard struct {
+	SquaresX     int
+	SquaresY     int
+	SquareSizeMM float64
+	MarkerSizeMM float64
+	detector     *Detector
+	// squares is the square of each marker.
+	squares []image.Point
+}
+
+// NewCharucoBoard returns a ChArUco board of squaresX by squaresY squares whose markers are of family.
+func NewCharucoBoard(squaresX, squaresY int, squareSizeMM, markerSizeMM float64, family *Family) (*CharucoBoard, error) {
+	if squaresX < 3 || squaresY < 3 {
+		return nil, errors.Errorf("ChArUco board must be at least 3x3 squares, is %dx%d", squaresX, squaresY)
+	}
+	if squareSizeMM <= 0 || markerSizeMM <= 0 || markerSizeMM >= squareSizeMM {
+		return nil, errors.New("ChArUco markers must be positive and smaller than its squares")
+	}
+	b := &CharucoBoard{SquaresX: squaresX, SquaresY: squaresY, SquareSizeMM: squareSizeMM, MarkerSizeMM: markerSizeMM}
+	for y := 0; y < squaresY; y++ {
+		for x := 0; x < squaresX; x++ {
+			if x%2 == y%2 {
+				b.squares = append(b.squares, image.Pt(x, y))
+			}
+		}
+	}
+	if len(b.squares) > len(family.Codes) {
+		return nil, errors.Errorf("ChArUco board needs %d markers but family %q has %d",
+			len(b.squares), family.Name, len(family.Codes))
+	}
+	detector, err := NewDetector(family, -1)
+	if err != nil {
+		return nil, err
+	}
+	b.detector = detector
+	return b, nil
+}
+
+// CornerID returns the ID of the inner corner of the board at the top left of square x, y, numbered row by
+// row from the top left.
+func (b *CharucoBoard) CornerID(x, y int) int {
+	return (y-1)*(b.SquaresX-1) + x - 1
+}
+
+// ObjectPoint returns the position of the corner with the given ID on the board, in mm, with x to the right
+// and y down from its top left.
+func (b *CharucoBoard) ObjectPoint(id int) r2.Point {
+	return r2.Point{
+		X: float64(id%(b.SquaresX-1)+1) * b.SquareSizeMM,
+		Y: float64(id/(b.SquaresX-1)+1) * b.SquareSizeMM,
+	}
+}
+
+// FindCorners finds the markers of the board in img, and the inner corners of the board next to them,
+// refined to sub-pixel accuracy. Corners are listed by ID, and each is in the center of its pixel's
+// coordinates as transform.Checkerboard corners are. transform.ErrCornersNotFound is returned if too few
+// corners are found.
+func (b *CharucoBoard) FindCorners(img image.Image) (transform.CalibrationView, error) {
+	// the homography from the board to the image of each marker found.
+	markers := map[image.Point]homography{}
+	half := b.MarkerSizeMM / 2
+	for _, det := range b.detector.Detect(img) {
+		if det.ID >= len(b.squares) {
+			continue
+		}
+		sq := b.squares[det.ID]
+		center := r2.Point{X: (float64(sq.X) + 0.5) * b.SquareSizeMM, Y: (float64(sq.Y) + 0.5) * b.SquareSizeMM}
+		board := [4]r2.Point{
+			center.Add(r2.Point{X: -half, Y: -half}), center.Add(r2.Point{X: half, Y: -half}),
+			center.Add(r2.Point{X: half, Y: half}), center.Add(r2.Point{X: -half, Y: half}),
+		}
+		var corners [4]r2.Point
+		for i, c := range det.Corners {
+			corners[i] = c.Sub(r2.Point{X: 0.5, Y: 0.5})
+		}
+		h, err := computeHomography(board, corners)
+		if err != nil {
+			continue
+		}
+		markers[sq] = h
+	}
+
+	// each inner corner is predicted by the markers in the squares next to it.
+	var ids []int
+	var predicted []r2.Point
+	squarePx := math.Inf(1)
+	bounds := img.Bounds()
+	for y := 1; y < b.SquaresY; y++ {
+		for x := 1; x < b.SquaresX; x++ {
+			corner := r2.Point{X: float64(x) * b.SquareSizeMM, Y: float64(y) * b.SquareSizeMM}
+			var sum r2.Point
+			n := 0
+			for _, sq := range []image.Point{{x - 1, y - 1}, {x, y - 1}, {x - 1, y}, {x, y}} {
+				h, ok := markers[sq]
+				if !ok {
+					continue
+				}
+				p := h.apply(corner)
+				sum = sum.Add(p)
+				n++
+				squarePx = math.Min(squarePx, h.apply(corner.Add(r2.Point{X: b.SquareSizeMM})).Sub(p).Norm())
+			}
+			if n == 0 {
+				continue
+			}
+			p := sum.Mul(1 / float64(n))
+			if p.X < 0 || p.Y < 0 || p.X > float64(bounds.Dx()-1) || p.Y > float64(bounds.Dy()-1) {
+				continue
+			}
+			ids = append(ids, b.CornerID(x, y))
+			predicted = append(predicted, p)
+		}
+	}
+	if len(predicted) < minCharucoCorners {
+		return transform.CalibrationView{}, transform.ErrCornersNotFound
+	}
+
+	radius := max(2, min(5, int(squarePx/4)))
+	refined := transform.RefineCorners(img, predicted, radius)
+	var view transform.CalibrationView
+	for i, p := range refined {
+		// a corner which refinement moved far from its prediction was not where it was predicted.
+		if p.Sub(predicted[i]).Norm() > float64(radius)/2 {
+			continue
+		}
+		view.ImagePoints = append(view.ImagePoints, p)
+		view.ObjectPoints = append(view.ObjectPoints, b.ObjectPoint(ids[i]))
+	}
+	if len(view.ImagePoints) < minCharucoCorners {
+		return transform.CalibrationView{}, transform.ErrCornersNotFound
+	}
+	return view, nil
+}
+
+// CalibrationTargetConfig describes a calibration target.
+type CalibrationTargetConfig struct {
+	// Type is "checkerboard" or "charuco".
+	Type string `json:"type"`
+	// Cols and Rows are how many inner corners a checkerboard has across and down, but how many squares a
+	// ChArUco board has, as each is usually described.
+	Cols         int     `json:"cols"`
+	Rows         int     `json:"rows"`
+	SquareSizeMM float64 `json:"square_size_mm"`
+	// MarkerSizeMM and Dictionary are the size and family of the markers of a ChArUco board. The dictionary
+	// is a built in family or the path of one, as LoadFamily accepts.
+	MarkerSizeMM float64 `json:"marker_size_mm,omitempty"`
+	Dictionary   string  `json:"dictionary,omitempty"`
+}
+
+// NewCalibrationTarget returns the calibration target the config describes.
+func NewCalibrationTarget(conf *CalibrationTargetConfig) (transform.CalibrationTarget, error) {
+	switch conf.Type {
+	case "checkerboard", "":
+		cb := &transform.Checkerboard{Cols: conf.Cols, Rows: conf.Rows, SquareSizeMM: conf.SquareSizeMM}
+		if err := cb.CheckValid(); err != nil {
+			return nil, err
+		}
+		return cb, nil
+	case "charuco":
+		if conf.Dictionary == "" {
+			return nil, errors.New("ChArUco board must have a dictionary")
+		}
+		family, err := LoadFamily(conf.Dictionary)
+		if err != nil {
+			return nil, err
+		}
+		return NewCharucoBoard(conf.Cols, conf.Rows, conf.SquareSizeMM, conf.MarkerSizeMM, family)
+	default:
+		return nil, errors.Errorf("unknown calibration target type %q, which must be checkerboard or charuco", conf.Type)
+	}
+}
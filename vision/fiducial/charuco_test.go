@@ -0,0 +1,153 @@
+package fiducial
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
+	"go.viam.com/test"
+	"gonum.org/v1/gonum/mat"
+
+	"go.viam.com/rdk/rimage/transform"
+)
+
+// renderCharuco draws a ChArUco board as the test camera would see it with the given rotation and the
+// center of the board at translation, tracing rays through 4x4 points of every pixel, whose center is at
+// its coordinates.
+func renderCharuco(t *testing.T, b *CharucoBoard, rotation *mat.Dense, translation r3.Vector) *image.Gray {
+	t.Helper()
+	family := b.detector.Family()
+	center := r2.Point{X: float64(b.SquaresX) * b.SquareSizeMM / 2, Y: float64(b.SquaresY) * b.SquareSizeMM / 2}
+	boardCorners := [4]r2.Point{
+		{X: 0, Y: 0}, {X: 2 * center.X, Y: 0}, {X: 2 * center.X, Y: 2 * center.Y}, {X: 0, Y: 2 * center.Y},
+	}
+	var normalized [4]r2.Point
+	for i, p := range boardCorners {
+		normalized[i] = project(rotation, translation, p.Sub(center))
+	}
+	toBoard, err := computeHomography(normalized, boardCorners)
+	test.That(t, err, test.ShouldBeNil)
+
+	w := float64(family.WidthAtBorder)
+	shade := func(p r2.Point) float64 {
+		sx, sy := int(math.Floor(p.X/b.SquareSizeMM)), int(math.Floor(p.Y/b.SquareSizeMM))
+		if p.X < 0 || p.Y < 0 || sx >= b.SquaresX || sy >= b.SquaresY {
+			return 230
+		}
+		if sx%2 != sy%2 {
+			return 20
+		}
+		id := -1
+		for i, sq := range b.squares {
+			if sq == image.Pt(sx, sy) {
+				id = i
+			}
+		}
+		u := (p.X - (float64(sx)+0.5)*b.SquareSizeMM + b.MarkerSizeMM/2) / b.MarkerSizeMM
+		v := (p.Y - (float64(sy)+0.5)*b.SquareSizeMM + b.MarkerSizeMM/2) / b.MarkerSizeMM
+		if u < 0 || u >= 1 || v < 0 || v >= 1 {
+			return 230
+		}
+		cx, cy := int(u*w), int(v*w)
+		for bit := range family.BitX {
+			if family.BitX[bit] == cx && family.BitY[bit] == cy && family.Codes[id]>>(family.NBits()-1-bit)&1 == 1 {
+				return 230
+			}
+		}
+		return 20
+	}
+
+	img := image.NewGray(image.Rect(0, 0, testIntrinsics.Width, testIntrinsics.Height))
+	for y := 0; y < testIntrinsics.Height; y++ {
+		for x := 0; x < testIntrinsics.Width; x++ {
+			sum := 0.0
+			for s := 0; s < 16; s++ {
+				n := r2.Point{
+					X: (float64(x) - 0.375 + 0.25*float64(s%4) - testIntrinsics.Ppx) / testIntrinsics.Fx,
+					Y: (float64(y) - 0.375 + 0.25*float64(s/4) - testIntrinsics.Ppy) / testIntrinsics.Fy,
+				}
+				sum += shade(toBoard.apply(n))
+			}
+			img.SetGray(x, y, color.Gray{Y: uint8(sum / 16)})
+		}
+	}
+	return img
+}
+
+func TestCharucoBoard(t *testing.T) {
+	family := NewArucoOriginal()
+	b, err := NewCharucoBoard(5, 4, 40, 28, family)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, b.squares, test.ShouldHaveLength, 10)
+	test.That(t, b.squares[1], test.ShouldResemble, image.Pt(2, 0))
+	test.That(t, b.squares[3], test.ShouldResemble, image.Pt(1, 1))
+	test.That(t, b.CornerID(1, 1), test.ShouldEqual, 0)
+	test.That(t, b.CornerID(4, 3), test.ShouldEqual, 11)
+	test.That(t, b.ObjectPoint(5), test.ShouldResemble, r2.Point{X: 80, Y: 80})
+
+	truth := func(p r2.Point, rotation *mat.Dense, translation r3.Vector) r2.Point {
+		q := project(rotation, translation, p.Sub(r2.Point{X: 100, Y: 80}))
+		return r2.Point{X: q.X*testIntrinsics.Fx + testIntrinsics.Ppx, Y: q.Y*testIntrinsics.Fy + testIntrinsics.Ppy}
+	}
+
+	t.Run("whole board", func(t *testing.T) {
+		rotation, translation := rotationAbout(r3.Vector{X: 1, Y: -0.5, Z: 0.3}, 25), r3.Vector{X: 10, Y: -5, Z: 450}
+		view, err := b.FindCorners(renderCharuco(t, b, rotation, translation))
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, view.ImagePoints, test.ShouldHaveLength, 12)
+		for i, p := range view.ImagePoints {
+			test.That(t, view.ObjectPoints[i], test.ShouldResemble, b.ObjectPoint(i))
+			test.That(t, p.Sub(truth(view.ObjectPoints[i], rotation, translation)).Norm(), test.ShouldBeLessThan, 0.3)
+		}
+	})
+
+	t.Run("part of the board", func(t *testing.T) {
+		// the left of the board is out of view.
+		rotation, translation := rotationAbout(r3.Vector{Z: 1}, 5), r3.Vector{X: -180, Z: 400}
+		view, err := b.FindCorners(renderCharuco(t, b, rotation, translation))
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, len(view.ImagePoints), test.ShouldBeBetweenOrEqual, minCharucoCorners, 9)
+		for i, p := range view.ImagePoints {
+			test.That(t, p.Sub(truth(view.ObjectPoints[i], rotation, translation)).Norm(), test.ShouldBeLessThan, 0.3)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := b.FindCorners(image.NewGray(image.Rect(0, 0, 100, 100)))
+		test.That(t, err, test.ShouldBeError, transform.ErrCornersNotFound)
+	})
+
+	_, err = NewCharucoBoard(2, 4, 40, 28, family)
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = NewCharucoBoard(5, 4, 40, 40, family)
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = NewCharucoBoard(50, 50, 40, 28, family)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "needs 1250 markers")
+}
+
+func TestNewCalibrationTarget(t *testing.T) {
+	target, err := NewCalibrationTarget(&CalibrationTargetConfig{Cols: 9, Rows: 6, SquareSizeMM: 25})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, target, test.ShouldResemble, &transform.Checkerboard{Cols: 9, Rows: 6, SquareSizeMM: 25})
+	_, err = NewCalibrationTarget(&CalibrationTargetConfig{Type: "checkerboard", Cols: 9, Rows: 6})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	target, err = NewCalibrationTarget(&CalibrationTargetConfig{
+		Type: "charuco", Cols: 5, Rows: 4, SquareSizeMM: 40, MarkerSizeMM: 28, Dictionary: ArucoOriginal,
+	})
+	test.That(t, err, test.ShouldBeNil)
+	board, ok := target.(*CharucoBoard)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, board.SquaresX, test.ShouldEqual, 5)
+	_, err = NewCalibrationTarget(&CalibrationTargetConfig{Type: "charuco", Cols: 5, Rows: 4, SquareSizeMM: 40, MarkerSizeMM: 28})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "dictionary")
+
+	_, err = NewCalibrationTarget(&CalibrationTargetConfig{Type: "circles"})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "unknown calibration target type")
+}
@@ -0,0 +1,667 @@
+// Package fiducial detects square fiducial markers, such as AprilTags and ArUco markers, in images and
+// estimates their pose relative to the camera.
+//
+// Markers are found as dark quadrilaterals: the image is thresholded against the contrast around every
+// pixel, and the convex hull of every dark connected component which is shaped like a quadrilateral is
+// reduced to its four corners. The edges between the corners are then refined to sub-pixel precision by
+// fitting lines through the strongest gradient across them, and the cells within are read and matched
+// against the codes of a family in every rotation.
+package fiducial
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/bits"
+	"slices"
+
+	"github.com/golang/geo/r2"
+	"github.com/pkg/errors"
+)
+
+const (
+	// tileSize is the width of the tiles the contrast around each pixel is measured over.
+	tileSize = 4
+	// minContrast is the smallest difference in brightness between black and white.
+	minContrast = 20
+	// minSidePx is the shortest side of a marker, in pixels.
+	minSidePx = 10
+	// minQuadFill is the smallest fraction of the convex hull of a component its quadrilateral must cover.
+	minQuadFill = 0.8
+	// maxHullVertices bounds the vertices of a simplified convex hull which is still a quadrilateral.
+	maxHullVertices = 40
+	// edgeSearchPx is how far from a quadrilateral's edge the strongest gradient is searched for.
+	edgeSearchPx = 2.5
+	// maxCornerShiftPx bounds how far refinement can move a corner.
+	maxCornerShiftPx = 3
+	// minBorderFraction is the smallest fraction of border cells which must be black.
+	minBorderFraction = 0.85
+)
+
+// Detection is a marker found in an image.
+type Detection struct {
+	Family string
+	ID     int
+	// Hamming is how many bits of the code read from the image were wrong.
+	Hamming int
+	// DecisionMargin is the average difference between the brightness of the data cells and the threshold
+	// between black and white, from 0 to 255. Low margins are less reliable.
+	DecisionMargin float64
+	// Corners are the outer corners of the black border with sub-pixel precision, starting at the top left
+	// of the marker as printed and going clockwise: top left, top right, bottom right and bottom left.
+	Corners [4]r2.Point
+}
+
+// Center returns the center of the marker, where its diagonals intersect.
+func (d Detection) Center() r2.Point {
+	p, ok := intersect(line{d.Corners[0], d.Corners[2].Sub(d.Corners[0])}, line{d.Corners[1], d.Corners[3].Sub(d.Corners[1])})
+	if !ok {
+		return d.Corners[0].Add(d.Corners[2]).Mul(0.5)
+	}
+	return p
+}
+
+// BoundingBox returns the smallest rectangle of pixels containing the marker.
+func (d Detection) BoundingBox() image.Rectangle {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, c := range d.Corners {
+		minX, minY = math.Min(minX, c.X), math.Min(minY, c.Y)
+		maxX, maxY = math.Max(maxX, c.X), math.Max(maxY, c.Y)
+	}
+	return image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY)))
+}
+
+// Detector finds the markers of a family in images.
+type Detector struct {
+	family     *Family
+	maxHamming int
+	// cells is the cell index of every bit in each of the four rotations of a marker.
+	cells [4][]int
+}
+
+// NewDetector returns a Detector for the markers of family which corrects up to maxHamming wrong bits.
+// A negative maxHamming corrects as many as the family reliably can, up to 2.
+func NewDetector(family *Family, maxHamming int) (*Detector, error) {
+	if err := family.Validate(); err != nil {
+		return nil, err
+	}
+	if maxHamming < 0 {
+		maxHamming = min(max(family.MinHamming-1, 0)/2, 2)
+	}
+	d := &Detector{family: family, maxHamming: maxHamming}
+	w := family.WidthAtBorder
+	for r := 0; r < 4; r++ {
+		d.cells[r] = make([]int, family.NBits())
+		for i := range family.BitX {
+			x, y := family.BitX[i], family.BitY[i]
+			for k := 0; k < r; k++ {
+				x, y = w-1-y, x
+			}
+			d.cells[r][i] = y*w + x
+		}
+	}
+	return d, nil
+}
+
+// Family returns the family of markers the detector finds.
+func (d *Detector) Family() *Family {
+	return d.family
+}
+
+// Detect returns the markers found in img. Each ID is found at most once, and detections are sorted by ID.
+func (d *Detector) Detect(img image.Image) []Detection {
+	g := toGray(img)
+	best := map[int]Detection{}
+	for _, quad := range findQuads(g) {
+		quad = refineQuad(g, quad)
+		det, ok := d.decode(g, quad)
+		if !ok {
+			continue
+		}
+		if prev, ok := best[det.ID]; ok &&
+			(prev.Hamming < det.Hamming || (prev.Hamming == det.Hamming && prev.DecisionMargin >= det.DecisionMargin)) {
+			continue
+		}
+		best[det.ID] = det
+	}
+	detections := make([]Detection, 0, len(best))
+	for _, det := range best {
+		detections = append(detections, det)
+	}
+	slices.SortFunc(detections, func(a, b Detection) int { return a.ID - b.ID })
+	return detections
+}
+
+// decode reads the cells within quad and matches them against the codes of the family.
+func (d *Detector) decode(g grayImage, quad [4]r2.Point) (Detection, bool) {
+	w := d.family.WidthAtBorder
+	h, err := computeHomography(unitSquare, quad)
+	if err != nil {
+		return Detection{}, false
+	}
+	cell := func(x, y int) float64 {
+		return g.sample(h.apply(r2.Point{X: (float64(x) + 0.5) / float64(w), Y: (float64(y) + 0.5) / float64(w)}))
+	}
+
+	// the black border is the ring of outermost cells, and the margin around it the ring just outside.
+	var black, white []float64
+	for i := 0; i < w; i++ {
+		black = append(black, cell(i, 0), cell(i, w-1))
+		white = append(white, cell(i, -1), cell(i, w))
+		if i > 0 && i < w-1 {
+			black = append(black, cell(0, i), cell(w-1, i))
+		}
+		white = append(white, cell(-1, i), cell(w, i))
+	}
+	blackLevel, whiteLevel := mean(black), mean(white)
+	if whiteLevel-blackLevel < minContrast {
+		return Detection{}, false
+	}
+	threshold := (blackLevel + whiteLevel) / 2
+	dark := 0
+	for _, v := range black {
+		if v < threshold {
+			dark++
+		}
+	}
+	if float64(dark) < minBorderFraction*float64(len(black)) {
+		return Detection{}, false
+	}
+
+	values := make([]float64, w*w)
+	for y := 1; y < w-1; y++ {
+		for x := 1; x < w-1; x++ {
+			values[y*w+x] = cell(x, y)
+		}
+	}
+	margin := 0.0
+	for _, c := range d.cells[0] {
+		margin += math.Abs(values[c] - threshold)
+	}
+	margin /= float64(len(d.cells[0]))
+
+	bestID, bestHamming, bestRotation := -1, d.maxHamming+1, 0
+	for r := 0; r < 4; r++ {
+		var code uint64
+		for _, c := range d.cells[r] {
+			code <<= 1
+			if values[c] > threshold {
+				code |= 1
+			}
+		}
+		for id, want := range d.family.Codes {
+			if hamming := bits.OnesCount64(code ^ want); hamming < bestHamming {
+				bestID, bestHamming, bestRotation = id, hamming, r
+			}
+		}
+	}
+	if bestID < 0 {
+		return Detection{}, false
+	}
+	det := Detection{Family: d.family.Name, ID: bestID, Hamming: bestHamming, DecisionMargin: margin}
+	// reading the marker rotated r times clockwise means its top left corner is r corners further round.
+	for i := range det.Corners {
+		det.Corners[i] = quad[(i+bestRotation)%4]
+	}
+	return det, true
+}
+
+func mean(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// grayImage is the brightness of every pixel of an image.
+type grayImage struct {
+	width, height int
+	pix           []uint8
+}
+
+func toGray(img image.Image) grayImage {
+	b := img.Bounds()
+	g := grayImage{width: b.Dx(), height: b.Dy(), pix: make([]uint8, b.Dx()*b.Dy())}
+	if gray, ok := img.(*image.Gray); ok {
+		for y := 0; y < g.height; y++ {
+			copy(g.pix[y*g.width:(y+1)*g.width], gray.Pix[(y+b.Min.Y-gray.Rect.Min.Y)*gray.Stride+b.Min.X-gray.Rect.Min.X:])
+		}
+		return g
+	}
+	for y := 0; y < g.height; y++ {
+		for x := 0; x < g.width; x++ {
+			g.pix[y*g.width+x] = color.GrayModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray).Y
+		}
+	}
+	return g
+}
+
+func (g grayImage) at(x, y int) float64 {
+	x = min(max(x, 0), g.width-1)
+	y = min(max(y, 0), g.height-1)
+	return float64(g.pix[y*g.width+x])
+}
+
+// sample returns the brightness at p by bilinear interpolation, the center of pixel (x, y) being at
+// (x+0.5, y+0.5).
+func (g grayImage) sample(p r2.Point) float64 {
+	x, y := p.X-0.5, p.Y-0.5
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := x-x0, y-y0
+	ix, iy := int(x0), int(y0)
+	return (1-fy)*((1-fx)*g.at(ix, iy)+fx*g.at(ix+1, iy)) + fy*((1-fx)*g.at(ix, iy+1)+fx*g.at(ix+1, iy+1))
+}
+
+// threshold returns which pixels are dark compared to the pixels around them. Pixels without enough
+// contrast around them are never dark.
+func threshold(g grayImage) []bool {
+	tw, th := (g.width+tileSize-1)/tileSize, (g.height+tileSize-1)/tileSize
+	tileMin, tileMax := make([]uint8, tw*th), make([]uint8, tw*th)
+	for i := range tileMin {
+		tileMin[i] = math.MaxUint8
+	}
+	for y := 0; y < g.height; y++ {
+		for x := 0; x < g.width; x++ {
+			v, t := g.pix[y*g.width+x], (y/tileSize)*tw+x/tileSize
+			tileMin[t], tileMax[t] = min(tileMin[t], v), max(tileMax[t], v)
+		}
+	}
+	// the contrast around each tile includes its neighbors, so that edges on tile boundaries are seen.
+	lo, hi := make([]uint8, tw*th), make([]uint8, tw*th)
+	for ty := 0; ty < th; ty++ {
+		for tx := 0; tx < tw; tx++ {
+			l, h := uint8(math.MaxUint8), uint8(0)
+			for ny := max(ty-1, 0); ny <= min(ty+1, th-1); ny++ {
+				for nx := max(tx-1, 0); nx <= min(tx+1, tw-1); nx++ {
+					l, h = min(l, tileMin[ny*tw+nx]), max(h, tileMax[ny*tw+nx])
+				}
+			}
+			lo[ty*tw+tx], hi[ty*tw+tx] = l, h
+		}
+	}
+	dark := make([]bool, len(g.pix))
+	for y := 0; y < g.height; y++ {
+		for x := 0; x < g.width; x++ {
+			t := (y/tileSize)*tw + x/tileSize
+			if int(hi[t])-int(lo[t]) < minContrast {
+				continue
+			}
+			dark[y*g.width+x] = 2*int(g.pix[y*g.width+x]) < int(lo[t])+int(hi[t])
+		}
+	}
+	return dark
+}
+
+// unionFind is a disjoint set forest over the pixels of an image.
+type unionFind []int32
+
+func (u unionFind) find(i int32) int32 {
+	for u[i] != i {
+		u[i] = u[u[i]]
+		i = u[i]
+	}
+	return i
+}
+
+func (u unionFind) union(a, b int32) {
+	ra, rb := u.find(a), u.find(b)
+	if ra != rb {
+		u[max(ra, rb)] = min(ra, rb)
+	}
+}
+
+// component is a connected set of dark pixels, described by the extent of each of its rows.
+type component struct {
+	minX, minY, maxX, maxY int
+	rowMin, rowMax         []int
+}
+
+// findQuads returns the corners of every dark component shaped like a quadrilateral, ordered clockwise.
+func findQuads(g grayImage) [][4]r2.Point {
+	dark := threshold(g)
+	w, h := g.width, g.height
+	u := make(unionFind, len(dark))
+	for i := range u {
+		u[i] = int32(i)
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			if !dark[i] {
+				continue
+			}
+			if x > 0 && dark[i-1] {
+				u.union(int32(i), int32(i-1))
+			}
+			if y > 0 {
+				for dx := -1; dx <= 1; dx++ {
+					if x+dx >= 0 && x+dx < w && dark[i-w+dx] {
+						u.union(int32(i), int32(i-w+dx))
+					}
+				}
+			}
+		}
+	}
+
+	components := map[int32]*component{}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			if !dark[i] {
+				continue
+			}
+			root := u.find(int32(i))
+			c, ok := components[root]
+			if !ok {
+				c = &component{minX: x, minY: y, maxX: x, maxY: y}
+				components[root] = c
+			}
+			c.minX, c.maxX, c.maxY = min(c.minX, x), max(c.maxX, x), y
+			for len(c.rowMin) <= y-c.minY {
+				c.rowMin, c.rowMax = append(c.rowMin, x), append(c.rowMax, x)
+			}
+			r := y - c.minY
+			c.rowMin[r], c.rowMax[r] = min(c.rowMin[r], x), max(c.rowMax[r], x)
+		}
+	}
+
+	var quads [][4]r2.Point
+	for _, c := range components {
+		if c.maxX-c.minX+1 < minSidePx || c.maxY-c.minY+1 < minSidePx ||
+			c.minX == 0 || c.minY == 0 || c.maxX == w-1 || c.maxY == h-1 {
+			continue
+		}
+		if quad, ok := c.quad(); ok {
+			quads = append(quads, quad)
+		}
+	}
+	return quads
+}
+
+// quad returns the quadrilateral covering the most of the component's convex hull, if the component is
+// shaped like one.
+func (c *component) quad() ([4]r2.Point, bool) {
+	points := make([]r2.Point, 0, 4*len(c.rowMin))
+	for r := range c.rowMin {
+		y := float64(c.minY + r)
+		x0, x1 := float64(c.rowMin[r]), float64(c.rowMax[r]+1)
+		points = append(points, r2.Point{X: x0, Y: y}, r2.Point{X: x0, Y: y + 1}, r2.Point{X: x1, Y: y}, r2.Point{X: x1, Y: y + 1})
+	}
+	hull := convexHull(points)
+	hullArea := polygonArea(hull)
+	hull = simplifyHull(hull)
+	if len(hull) < 4 || len(hull) > maxHullVertices {
+		return [4]r2.Point{}, false
+	}
+
+	// the largest quadrilateral is found by trying every diagonal, with the vertex furthest from it on
+	// either side.
+	var best [4]r2.Point
+	bestArea := 0.0
+	n := len(hull)
+	for i := 0; i < n; i++ {
+		for k := i + 2; k < n; k++ {
+			a, b := 0.0, 0.0
+			var j, l int
+			for m := i + 1; m < k; m++ {
+				if area := triangleArea(hull[i], hull[m], hull[k]); area > a {
+					a, j = area, m
+				}
+			}
+			for m := k + 1; m < n+i; m++ {
+				if area := triangleArea(hull[k], hull[m%n], hull[i]); area > b {
+					b, l = area, m%n
+				}
+			}
+			if a+b > bestArea && a > 0 && b > 0 {
+				bestArea = a + b
+				best = [4]r2.Point{hull[i], hull[j], hull[k], hull[l]}
+			}
+		}
+	}
+	if bestArea < minQuadFill*hullArea {
+		return [4]r2.Point{}, false
+	}
+	for i := range best {
+		if best[i].Sub(best[(i+1)%4]).Norm() < minSidePx/2 {
+			return [4]r2.Point{}, false
+		}
+	}
+	if polygonArea(best[:]) < 0 {
+		best[1], best[3] = best[3], best[1]
+	}
+	return best, true
+}
+
+// convexHull returns the convex hull of points by Andrew's monotone chain, with a positive area.
+func convexHull(points []r2.Point) []r2.Point {
+	points = slices.Clone(points)
+	slices.SortFunc(points, func(a, b r2.Point) int {
+		if a.X != b.X {
+			return cmpFloat(a.X, b.X)
+		}
+		return cmpFloat(a.Y, b.Y)
+	})
+	points = slices.Compact(points)
+	if len(points) < 3 {
+		return points
+	}
+	hull := make([]r2.Point, 0, 2*len(points))
+	for pass := 0; pass < 2; pass++ {
+		start := len(hull)
+		for _, p := range points {
+			for len(hull) >= start+2 && hull[len(hull)-1].Sub(hull[len(hull)-2]).Cross(p.Sub(hull[len(hull)-2])) <= 0 {
+				hull = hull[:len(hull)-1]
+			}
+			hull = append(hull, p)
+		}
+		hull = hull[:len(hull)-1]
+		slices.Reverse(points)
+	}
+	return hull
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// simplifyHull removes the vertices of a convex hull which are within a pixel of the line between their
+// neighbors, which are the steps of pixelated edges.
+func simplifyHull(hull []r2.Point) []r2.Point {
+	hull = slices.Clone(hull)
+	for len(hull) > 4 {
+		bestI, bestHeight := -1, 1.0
+		for i := range hull {
+			prev, next := hull[(i+len(hull)-1)%len(hull)], hull[(i+1)%len(hull)]
+			base := next.Sub(prev).Norm()
+			if base == 0 {
+				bestI = i
+				break
+			}
+			if height := 2 * triangleArea(prev, hull[i], next) / base; height < bestHeight {
+				bestI, bestHeight = i, height
+			}
+		}
+		if bestI < 0 {
+			break
+		}
+		hull = slices.Delete(hull, bestI, bestI+1)
+	}
+	return hull
+}
+
+func triangleArea(a, b, c r2.Point) float64 {
+	return math.Abs(b.Sub(a).Cross(c.Sub(a))) / 2
+}
+
+// polygonArea returns the signed area of a polygon, which is positive when its vertices go clockwise in
+// image coordinates.
+func polygonArea(polygon []r2.Point) float64 {
+	area := 0.0
+	for i := range polygon {
+		area += polygon[i].Cross(polygon[(i+1)%len(polygon)])
+	}
+	return area / 2
+}
+
+// line is the line through a point in a direction.
+type line struct {
+	point, direction r2.Point
+}
+
+func intersect(a, b line) (r2.Point, bool) {
+	det := a.direction.Cross(b.direction)
+	if math.Abs(det) < 1e-9 {
+		return r2.Point{}, false
+	}
+	t := b.point.Sub(a.point).Cross(b.direction) / det
+	return a.point.Add(a.direction.Mul(t)), true
+}
+
+// refineQuad moves the corners of a quadrilateral to the intersections of lines fit through the strongest
+// gradient across each of its edges, where brightness goes from the dark border to the light margin.
+func refineQuad(g grayImage, quad [4]r2.Point) [4]r2.Point {
+	var lines [4]line
+	for i := range quad {
+		a, b := quad[i], quad[(i+1)%4]
+		l, ok := refineEdge(g, a, b)
+		if !ok {
+			return quad
+		}
+		lines[i] = l
+	}
+	refined := quad
+	for i := range quad {
+		p, ok := intersect(lines[(i+3)%4], lines[i])
+		if !ok || p.Sub(quad[i]).Norm() > maxCornerShiftPx {
+			return quad
+		}
+		refined[i] = p
+	}
+	return refined
+}
+
+func refineEdge(g grayImage, a, b r2.Point) (line, bool) {
+	d := b.Sub(a)
+	length := d.Norm()
+	// the outward normal of an edge of a quadrilateral going clockwise in image coordinates.
+	normal := r2.Point{X: d.Y, Y: -d.X}.Mul(1 / length)
+	samples := min(max(int(length/2), 4), 64)
+	const step = 0.5
+	steps := int(2 * edgeSearchPx / step)
+
+	var points []r2.Point
+	var weights []float64
+	for s := 0; s < samples; s++ {
+		// the ends of the edge are left out, where the neighboring edges blur it.
+		t := 0.1 + 0.8*(float64(s)+0.5)/float64(samples)
+		p := a.Add(d.Mul(t))
+		gradients := make([]float64, steps)
+		for k := range gradients {
+			offset := -edgeSearchPx + (float64(k)+0.5)*step
+			gradients[k] = g.sample(p.Add(normal.Mul(offset+step/2))) - g.sample(p.Add(normal.Mul(offset-step/2)))
+		}
+		k := 0
+		for i := range gradients {
+			if gradients[i] > gradients[k] {
+				k = i
+			}
+		}
+		if gradients[k] <= 0 {
+			continue
+		}
+		// the peak is interpolated between its neighbors by a parabola.
+		offset := -edgeSearchPx + (float64(k)+0.5)*step
+		if k > 0 && k < steps-1 {
+			l, c, r := gradients[k-1], gradients[k], gradients[k+1]
+			if denom := l - 2*c + r; denom < 0 {
+				offset += step * 0.5 * (l - r) / denom
+			}
+		}
+		points = append(points, p.Add(normal.Mul(offset)))
+		weights = append(weights, gradients[k])
+	}
+	if len(points) < 3 {
+		return line{}, false
+	}
+	return fitLine(points, weights), true
+}
+
+// fitLine returns the line minimizing the weighted squared distance to points.
+func fitLine(points []r2.Point, weights []float64) line {
+	var total float64
+	var centroid r2.Point
+	for i, p := range points {
+		centroid = centroid.Add(p.Mul(weights[i]))
+		total += weights[i]
+	}
+	centroid = centroid.Mul(1 / total)
+	var sxx, sxy, syy float64
+	for i, p := range points {
+		q := p.Sub(centroid)
+		sxx += weights[i] * q.X * q.X
+		sxy += weights[i] * q.X * q.Y
+		syy += weights[i] * q.Y * q.Y
+	}
+	// the direction is the principal axis of the covariance of the points.
+	angle := 0.5 * math.Atan2(2*sxy, sxx-syy)
+	return line{point: centroid, direction: r2.Point{X: math.Cos(angle), Y: math.Sin(angle)}}
+}
+
+// homography maps points by a 3x3 matrix in row major order.
+type homography [9]float64
+
+var unitSquare = [4]r2.Point{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 1}, {X: 0, Y: 1}}
+
+func (h homography) apply(p r2.Point) r2.Point {
+	w := h[6]*p.X + h[7]*p.Y + h[8]
+	return r2.Point{X: (h[0]*p.X + h[1]*p.Y + h[2]) / w, Y: (h[3]*p.X + h[4]*p.Y + h[5]) / w}
+}
+
+// computeHomography returns the homography mapping each of src to the corresponding point of dst.
+func computeHomography(src, dst [4]r2.Point) (homography, error) {
+	// the 8 unknowns of the homography, whose last element is 1, solve a linear system of 8 equations.
+	var a [8][9]float64
+	for i := range src {
+		s, d := src[i], dst[i]
+		a[2*i] = [9]float64{s.X, s.Y, 1, 0, 0, 0, -d.X * s.X, -d.X * s.Y, d.X}
+		a[2*i+1] = [9]float64{0, 0, 0, s.X, s.Y, 1, -d.Y * s.X, -d.Y * s.Y, d.Y}
+	}
+	// gaussian elimination with partial pivoting.
+	for col := 0; col < 8; col++ {
+		pivot := col
+		for row := col + 1; row < 8; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return homography{}, errors.New("points are degenerate")
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		for row := 0; row < 8; row++ {
+			if row == col {
+				continue
+			}
+			f := a[row][col] / a[col][col]
+			for k := col; k < 9; k++ {
+				a[row][k] -= f * a[col][k]
+			}
+		}
+	}
+	var h homography
+	for i := 0; i < 8; i++ {
+		h[i] = a[i][8] / a[i][i]
+	}
+	h[8] = 1
+	return h, nil
+}
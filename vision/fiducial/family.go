@@ -0,0 +1,270 @@
+package fiducial
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.viam.com/utils"
+)
+
+// Family is a family of fiducial markers: square markers with a black border around a grid of black and
+// white cells encoding one of the family's codes.
+type Family struct {
+	Name string
+	// Codes are the codes of the markers, indexed by marker ID. The bits of a code are read from white
+	// cells as 1 and black cells as 0, most significant bit first.
+	Codes []uint64
+	// BitX and BitY are the cell of each bit of a code, most significant first, in a grid of
+	// WidthAtBorder cells across the outside of the black border, which makes up its outermost cells.
+	BitX []int
+	BitY []int
+	// WidthAtBorder is how many cells wide the marker is, from the outside of its black border.
+	WidthAtBorder int
+	// MinHamming is the smallest hamming distance between two codes of the family in any rotation.
+	MinHamming int
+}
+
+// NBits returns how many bits a code of the family has.
+func (f *Family) NBits() int {
+	return len(f.BitX)
+}
+
+// Validate ensures the family is well formed.
+func (f *Family) Validate() error {
+	if len(f.Codes) == 0 {
+		return errors.Errorf("family %q has no codes", f.Name)
+	}
+	if len(f.BitX) == 0 || len(f.BitX) != len(f.BitY) {
+		return errors.Errorf("family %q must have the same number of bit x and y cells", f.Name)
+	}
+	if len(f.BitX) > 64 {
+		return errors.Errorf("family %q has %d bits but at most 64 are supported", f.Name, len(f.BitX))
+	}
+	if f.WidthAtBorder < 3 {
+		return errors.Errorf("family %q must be at least 3 cells wide", f.Name)
+	}
+	for i := range f.BitX {
+		if f.BitX[i] < 1 || f.BitX[i] > f.WidthAtBorder-2 || f.BitY[i] < 1 || f.BitY[i] > f.WidthAtBorder-2 {
+			return errors.Errorf("bit %d of family %q is not within its border", i, f.Name)
+		}
+	}
+	return nil
+}
+
+// ArucoOriginal is the name of the dictionary of the original ArUco library.
+const ArucoOriginal = "aruco_original"
+
+// arucoOriginalWords are the words each row of an original ArUco marker is one of, white cells being 1.
+// Each word encodes two bits of the marker ID in its second and fourth cells.
+var arucoOriginalWords = [4][5]uint64{
+	{1, 0, 0, 0, 0},
+	{1, 0, 1, 1, 1},
+	{0, 1, 0, 0, 1},
+	{0, 1, 1, 1, 0},
+}
+
+// NewArucoOriginal returns the 1024 marker dictionary of the original ArUco library, whose 5x5 markers
+// encode their 10 bit ID two bits per row.
+func NewArucoOriginal() *Family {
+	f := &Family{Name: ArucoOriginal, WidthAtBorder: 7, MinHamming: 1}
+	for y := 0; y < 5; y++ {
+		for x := 0; x < 5; x++ {
+			f.BitX = append(f.BitX, x+1)
+			f.BitY = append(f.BitY, y+1)
+		}
+	}
+	for id := 0; id < 1024; id++ {
+		var code uint64
+		for row := 0; row < 5; row++ {
+			word := arucoOriginalWords[(id>>(2*(4-row)))&3]
+			for _, bit := range word {
+				code = code<<1 | bit
+			}
+		}
+		f.Codes = append(f.Codes, code)
+	}
+	return f
+}
+
+var (
+	cFamilyName     = regexp.MustCompile(`name\s*=\s*strdup\("([^"]+)"\)`)
+	cFamilyInt      = regexp.MustCompile(`tf->(h|d|nbits|black_border|width_at_border)\s*=\s*(\d+)\s*;`)
+	cFamilyReversed = regexp.MustCompile(`tf->reversed_border\s*=\s*(true|1)\s*;`)
+	cFamilyCode     = regexp.MustCompile(`codes\[(\d+)\]\s*=\s*(0x[0-9a-fA-F]+)`)
+	cFamilyBit      = regexp.MustCompile(`bit_(x|y)\[(\d+)\]\s*=\s*(\d+)\s*;`)
+	cFamilyCodeData = regexp.MustCompile(`(?s)codedata\[\d*\]\s*=\s*\{(.*?)\}`)
+	cHexLiteral     = regexp.MustCompile(`0x[0-9a-fA-F]+`)
+)
+
+// ParseAprilTagFamily parses the C source of an AprilTag family, such as tag36h11.c or tag25h9.c from the
+// AprilTag library. Both the sources of AprilTag 3, which list the cell of every bit, and the older
+// sources of AprilTag 2, whose bits are laid out row by row, are supported. Families whose border is
+// reversed, with white cells around black ones, are not.
+func ParseAprilTagFamily(r io.Reader) (*Family, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	text := string(src)
+	f := &Family{}
+	if m := cFamilyName.FindStringSubmatch(text); m != nil {
+		f.Name = m[1]
+	}
+	if cFamilyReversed.MatchString(text) {
+		return nil, errors.Errorf("family %q has a reversed border, which is not supported", f.Name)
+	}
+	ints := map[string]int{}
+	for _, m := range cFamilyInt.FindAllStringSubmatch(text, -1) {
+		ints[m[1]], _ = strconv.Atoi(m[2])
+	}
+	f.MinHamming = ints["h"]
+
+	if m := cFamilyCodeData.FindStringSubmatch(text); m != nil {
+		for _, lit := range cHexLiteral.FindAllString(m[1], -1) {
+			code, err := strconv.ParseUint(lit[2:], 16, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid code %s", lit)
+			}
+			f.Codes = append(f.Codes, code)
+		}
+	} else {
+		codes := map[int]uint64{}
+		for _, m := range cFamilyCode.FindAllStringSubmatch(text, -1) {
+			i, _ := strconv.Atoi(m[1])
+			code, err := strconv.ParseUint(m[2][2:], 16, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid code %s", m[2])
+			}
+			codes[i] = code
+		}
+		f.Codes = make([]uint64, len(codes))
+		for i, code := range codes {
+			if i >= len(codes) {
+				return nil, errors.Errorf("codes of family %q are not numbered consecutively", f.Name)
+			}
+			f.Codes[i] = code
+		}
+	}
+
+	if bits := cFamilyBit.FindAllStringSubmatch(text, -1); len(bits) > 0 {
+		f.BitX = make([]int, ints["nbits"])
+		f.BitY = make([]int, ints["nbits"])
+		for _, m := range bits {
+			i, _ := strconv.Atoi(m[2])
+			v, _ := strconv.Atoi(m[3])
+			if i >= len(f.BitX) {
+				return nil, errors.Errorf("bit %d of family %q is beyond its %d bits", i, f.Name, len(f.BitX))
+			}
+			if m[1] == "x" {
+				f.BitX[i] = v
+			} else {
+				f.BitY[i] = v
+			}
+		}
+		f.WidthAtBorder = ints["width_at_border"]
+	} else {
+		// AprilTag 2 families are d by d cells within a black border, read row by row.
+		d, border := ints["d"], ints["black_border"]
+		for i := 0; i < d*d; i++ {
+			f.BitX = append(f.BitX, border+i%d)
+			f.BitY = append(f.BitY, border+i/d)
+		}
+		f.WidthAtBorder = d + 2*border
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// ParseOpenCVDictionary parses an ArUco dictionary written by OpenCV's Dictionary::writeDictionary, which
+// lists the cells of every marker row by row, white cells being 1.
+func ParseOpenCVDictionary(r io.Reader) (*Family, error) {
+	fields := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || strings.HasPrefix(key, "%") {
+			continue
+		}
+		fields[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"'`)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	nMarkers, err := strconv.Atoi(fields["nmarkers"])
+	if err != nil {
+		return nil, errors.Wrap(err, "dictionary must have nmarkers")
+	}
+	markerSize, err := strconv.Atoi(fields["markersize"])
+	if err != nil {
+		return nil, errors.Wrap(err, "dictionary must have markersize")
+	}
+	maxCorrection, _ := strconv.Atoi(fields["maxCorrectionBits"])
+
+	f := &Family{WidthAtBorder: markerSize + 2, MinHamming: 2*maxCorrection + 1}
+	for y := 0; y < markerSize; y++ {
+		for x := 0; x < markerSize; x++ {
+			f.BitX = append(f.BitX, x+1)
+			f.BitY = append(f.BitY, y+1)
+		}
+	}
+	for i := 0; i < nMarkers; i++ {
+		bits, ok := fields["marker_"+strconv.Itoa(i)]
+		if !ok {
+			return nil, errors.Errorf("dictionary is missing marker %d", i)
+		}
+		if len(bits) != markerSize*markerSize {
+			return nil, errors.Errorf("marker %d has %d bits instead of %d", i, len(bits), markerSize*markerSize)
+		}
+		code, err := strconv.ParseUint(bits, 2, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid marker %d", i)
+		}
+		f.Codes = append(f.Codes, code)
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// LoadFamily returns the built in family with the given name, or else parses the family at the given path:
+// the C source of an AprilTag family if it ends in .c, or an OpenCV ArUco dictionary if it ends in .yml or
+// .yaml. Families loaded from files are named after the file unless the file names them.
+func LoadFamily(nameOrPath string) (*Family, error) {
+	if nameOrPath == ArucoOriginal {
+		return NewArucoOriginal(), nil
+	}
+	var parse func(io.Reader) (*Family, error)
+	switch ext := filepath.Ext(nameOrPath); ext {
+	case ".c":
+		parse = ParseAprilTagFamily
+	case ".yml", ".yaml":
+		parse = ParseOpenCVDictionary
+	default:
+		return nil, errors.Errorf(
+			"unknown family %q, which must be %q or the path of an AprilTag family's C source or an OpenCV dictionary",
+			nameOrPath, ArucoOriginal)
+	}
+	//nolint:gosec
+	file, err := os.Open(nameOrPath)
+	if err != nil {
+		return nil, err
+	}
+	defer utils.UncheckedErrorFunc(file.Close)
+	f, err := parse(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot parse family %q", nameOrPath)
+	}
+	if f.Name == "" {
+		f.Name = strings.TrimSuffix(filepath.Base(nameOrPath), filepath.Ext(nameOrPath))
+	}
+	return f, nil
+}
@@ -0,0 +1,263 @@
+package fiducial
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
+	"go.viam.com/test"
+	"gonum.org/v1/gonum/mat"
+
+	"go.viam.com/rdk/rimage/transform"
+	"go.viam.com/rdk/spatialmath"
+)
+
+var testIntrinsics = &transform.PinholeCameraIntrinsics{Width: 640, Height: 480, Fx: 600, Fy: 600, Ppx: 320, Ppy: 240}
+
+// marker is a marker of a family placed in front of the test camera.
+type marker struct {
+	id          int
+	sizeMM      float64
+	rotation    *mat.Dense
+	translation r3.Vector
+}
+
+func (m marker) corners(distortion transform.Distorter) [4]r2.Point {
+	half := m.sizeMM / 2
+	var corners [4]r2.Point
+	for i, p := range []r2.Point{{X: -half, Y: -half}, {X: half, Y: -half}, {X: half, Y: half}, {X: -half, Y: half}} {
+		q := project(m.rotation, m.translation, p)
+		if distortion != nil {
+			q.X, q.Y = distortion.Transform(q.X, q.Y)
+		}
+		corners[i] = r2.Point{X: q.X*testIntrinsics.Fx + testIntrinsics.Ppx, Y: q.Y*testIntrinsics.Fy + testIntrinsics.Ppy}
+	}
+	return corners
+}
+
+// render draws the markers as the test camera would see them, tracing a ray through 4x4 points of every
+// pixel to the plane of each marker.
+func render(t *testing.T, family *Family, distortion transform.Distorter, markers ...marker) *image.Gray {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, testIntrinsics.Width, testIntrinsics.Height))
+	w := family.WidthAtBorder
+	var inverses []homography
+	for _, m := range markers {
+		half := m.sizeMM / 2
+		object := [4]r2.Point{{X: -half, Y: -half}, {X: half, Y: -half}, {X: half, Y: half}, {X: -half, Y: half}}
+		var normalized [4]r2.Point
+		for i, p := range object {
+			normalized[i] = project(m.rotation, m.translation, p)
+		}
+		h, err := computeHomography(normalized, [4]r2.Point{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 1}, {X: 0, Y: 1}})
+		test.That(t, err, test.ShouldBeNil)
+		inverses = append(inverses, h)
+	}
+	for y := 0; y < testIntrinsics.Height; y++ {
+		for x := 0; x < testIntrinsics.Width; x++ {
+			sum := 0.0
+			for sy := 0; sy < 4; sy++ {
+				for sx := 0; sx < 4; sx++ {
+					p := r2.Point{X: float64(x) + (float64(sx)+0.5)/4, Y: float64(y) + (float64(sy)+0.5)/4}
+					n := undistort(p, testIntrinsics, distortion)
+					v := 230.0
+					for i, m := range markers {
+						u := inverses[i].apply(n)
+						if u.X < 0 || u.X >= 1 || u.Y < 0 || u.Y >= 1 {
+							continue
+						}
+						cx, cy := int(u.X*float64(w)), int(u.Y*float64(w))
+						v = 20
+						for b := range family.BitX {
+							if family.BitX[b] == cx && family.BitY[b] == cy && family.Codes[m.id]>>(family.NBits()-1-b)&1 == 1 {
+								v = 230
+							}
+						}
+					}
+					sum += v
+				}
+			}
+			img.SetGray(x, y, color.Gray{Y: uint8(sum / 16)})
+		}
+	}
+	return img
+}
+
+func rotationAbout(axis r3.Vector, degrees float64) *mat.Dense {
+	return rodrigues(axis.Normalize().Mul(degrees * math.Pi / 180))
+}
+
+func TestArucoOriginal(t *testing.T) {
+	f := NewArucoOriginal()
+	test.That(t, f.Validate(), test.ShouldBeNil)
+	test.That(t, f.Codes, test.ShouldHaveLength, 1024)
+	test.That(t, f.NBits(), test.ShouldEqual, 25)
+	// every row of marker 0 is the first word, and of marker 1023 the last.
+	test.That(t, f.Codes[0], test.ShouldEqual, uint64(0b10000_10000_10000_10000_10000))
+	test.That(t, f.Codes[1023], test.ShouldEqual, uint64(0b01110_01110_01110_01110_01110))
+	test.That(t, f.Codes[0b01_10_11_00_01], test.ShouldEqual, uint64(0b10111_01001_01110_10000_10111))
+}
+
+func TestParseAprilTagFamily(t *testing.T) {
+	// a made up family with the layout of a 4x4 AprilTag 3 family.
+	var src strings.Builder
+	src.WriteString("apriltag_family_t *tagtest_create()\n{\n   tf->name = strdup(\"tagtest\");\n   tf->h = 5;\n")
+	src.WriteString("   tf->ncodes = 2;\n   tf->codes[0] = 0x000000000000d7e5UL;\n   tf->codes[1] = 0x0000000000002b1aUL;\n")
+	src.WriteString("   tf->nbits = 16;\n")
+	for i := 0; i < 16; i++ {
+		src.WriteString("   tf->bit_x[" + itoa(i) + "] = " + itoa(2+i%4) + ";\n")
+		src.WriteString("   tf->bit_y[" + itoa(i) + "] = " + itoa(2+i/4) + ";\n")
+	}
+	src.WriteString("   tf->width_at_border = 8;\n   tf->total_width = 10;\n   tf->reversed_border = false;\n   return tf;\n}\n")
+	f, err := ParseAprilTagFamily(strings.NewReader(src.String()))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, f.Name, test.ShouldEqual, "tagtest")
+	test.That(t, f.Codes, test.ShouldResemble, []uint64{0xd7e5, 0x2b1a})
+	test.That(t, f.MinHamming, test.ShouldEqual, 5)
+	test.That(t, f.WidthAtBorder, test.ShouldEqual, 8)
+	test.That(t, f.BitX[5], test.ShouldEqual, 3)
+	test.That(t, f.BitY[5], test.ShouldEqual, 3)
+
+	// the same family in the format of AprilTag 2, laid out row by row.
+	f2, err := ParseAprilTagFamily(strings.NewReader(`
+static uint64_t codedata[2] = {
+   0x000000000000d7e5UL,
+   0x0000000000002b1aUL,
+};
+apriltag_family_t *tagtest_create()
+{
+   apriltag_family_t *tf = calloc(1, sizeof(apriltag_family_t));
+   tf->black_border = 1;
+   tf->d = 4;
+   tf->h = 5;
+   tf->ncodes = 2;
+   tf->codes = codedata;
+   return tf;
+}`))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, f2.Codes, test.ShouldResemble, f.Codes)
+	test.That(t, f2.WidthAtBorder, test.ShouldEqual, 6)
+	test.That(t, f2.BitX[5], test.ShouldEqual, 2)
+	test.That(t, f2.BitY[5], test.ShouldEqual, 2)
+
+	_, err = ParseAprilTagFamily(strings.NewReader(src.String() + "tf->reversed_border = true;"))
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "reversed border")
+}
+
+func itoa(i int) string {
+	return string(rune('0'+i/10)) + string(rune('0'+i%10))
+}
+
+func TestParseOpenCVDictionary(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dict_test.yml")
+	err := os.WriteFile(path, []byte(`%YAML:1.0
+---
+nmarkers: 2
+markersize: 4
+maxCorrectionBits: 1
+marker_0: "1011010011100101"
+marker_1: "0100101100011010"
+`), 0o600)
+	test.That(t, err, test.ShouldBeNil)
+	f, err := LoadFamily(path)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, f.Name, test.ShouldEqual, "dict_test")
+	test.That(t, f.Codes, test.ShouldResemble, []uint64{0b1011010011100101, 0b0100101100011010})
+	test.That(t, f.WidthAtBorder, test.ShouldEqual, 6)
+	test.That(t, f.MinHamming, test.ShouldEqual, 3)
+
+	_, err = LoadFamily("tag36h11")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "unknown family")
+	f, err = LoadFamily(ArucoOriginal)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, f.Codes, test.ShouldHaveLength, 1024)
+}
+
+func TestDetect(t *testing.T) {
+	family := NewArucoOriginal()
+	detector, err := NewDetector(family, -1)
+	test.That(t, err, test.ShouldBeNil)
+
+	markers := []marker{
+		// head on and rotated within the image.
+		{id: 37, sizeMM: 80, rotation: rotationAbout(r3.Vector{Z: 1}, 30), translation: r3.Vector{X: -90, Y: -40, Z: 500}},
+		// tilted away from the camera.
+		{
+			id: 1000, sizeMM: 80,
+			rotation:    rotationAbout(r3.Vector{X: 1, Y: 1}, 40),
+			translation: r3.Vector{X: 100, Y: 50, Z: 450},
+		},
+	}
+	img := render(t, family, nil, markers...)
+	detections := detector.Detect(img)
+	test.That(t, detections, test.ShouldHaveLength, 2)
+	for i, det := range detections {
+		test.That(t, det.ID, test.ShouldEqual, markers[i].id)
+		test.That(t, det.Family, test.ShouldEqual, ArucoOriginal)
+		test.That(t, det.Hamming, test.ShouldEqual, 0)
+		test.That(t, det.DecisionMargin, test.ShouldBeGreaterThan, 50)
+		for c, want := range markers[i].corners(nil) {
+			test.That(t, det.Corners[c].Sub(want).Norm(), test.ShouldBeLessThan, 0.3)
+		}
+		test.That(t, det.BoundingBox().Overlaps(image.Rectangle{Min: image.Pt(int(det.Center().X), int(det.Center().Y)),
+			Max: image.Pt(int(det.Center().X)+1, int(det.Center().Y)+1)}), test.ShouldBeTrue)
+	}
+
+	// an empty image has no markers, nor does one whose marker is cut off by the edge of the image.
+	test.That(t, detector.Detect(image.NewGray(image.Rect(0, 0, 100, 100))), test.ShouldBeEmpty)
+	cutOff := marker{id: 3, sizeMM: 200, rotation: rotationAbout(r3.Vector{Z: 1}, 0), translation: r3.Vector{X: 320, Z: 500}}
+	test.That(t, detector.Detect(render(t, family, nil, cutOff)), test.ShouldBeEmpty)
+}
+
+func TestEstimatePose(t *testing.T) {
+	family := NewArucoOriginal()
+	detector, err := NewDetector(family, -1)
+	test.That(t, err, test.ShouldBeNil)
+	distortion := &transform.BrownConrady{RadialK1: -0.2, RadialK2: 0.05, TangentialP1: 0.001, TangentialP2: -0.001}
+
+	for _, tc := range []struct {
+		name       string
+		distortion transform.Distorter
+	}{
+		{"no distortion", nil},
+		{"brown conrady distortion", distortion},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := marker{
+				id: 512, sizeMM: 100,
+				rotation:    rotationAbout(r3.Vector{X: 0.3, Y: 1, Z: 0.2}, 35),
+				translation: r3.Vector{X: 60, Y: -30, Z: 700},
+			}
+			detections := detector.Detect(render(t, family, tc.distortion, m))
+			test.That(t, detections, test.ShouldHaveLength, 1)
+
+			pose, rms, err := EstimatePose(detections[0].Corners, m.sizeMM, testIntrinsics, tc.distortion)
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, rms, test.ShouldBeLessThan, 0.5)
+			test.That(t, pose.Point().Sub(m.translation).Norm(), test.ShouldBeLessThan, 5)
+			// the pose moves points of the marker to where the camera sees them.
+			corner := r3.Vector{X: m.sizeMM / 2, Y: -m.sizeMM / 2}
+			var rotated mat.VecDense
+			rotated.MulVec(m.rotation, mat.NewVecDense(3, []float64{corner.X, corner.Y, corner.Z}))
+			want := r3.Vector{X: rotated.AtVec(0), Y: rotated.AtVec(1), Z: rotated.AtVec(2)}.Add(m.translation)
+			got := spatialmath.Compose(pose, spatialmath.NewPoseFromPoint(corner)).Point()
+			test.That(t, got.Sub(want).Norm(), test.ShouldBeLessThan, 5)
+			wantOrientation, err := spatialmath.NewRotationMatrix(mat.DenseCopyOf(m.rotation.T()).RawMatrix().Data)
+			test.That(t, err, test.ShouldBeNil)
+			diff := spatialmath.OrientationBetween(pose.Orientation(), wantOrientation).AxisAngles()
+			test.That(t, math.Abs(diff.Theta)*180/math.Pi, test.ShouldBeLessThan, 1.5)
+		})
+	}
+
+	_, _, err = EstimatePose([4]r2.Point{}, 100, &transform.PinholeCameraIntrinsics{}, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+}
@@ -0,0 +1,194 @@
+package fiducial
+
+import (
+	"math"
+
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
+	"github.com/pkg/errors"
+	"gonum.org/v1/gonum/mat"
+
+	"go.viam.com/rdk/rimage/transform"
+	"go.viam.com/rdk/spatialmath"
+)
+
+const (
+	undistortIterations = 20
+	refineIterations    = 20
+)
+
+// EstimatePose returns the pose of a marker whose corners were detected in an image, relative to the
+// camera which took it, along with the root mean square of the distances between its corners and where
+// the pose projects them, in pixels. The size of the marker is the width of the outside of its black
+// border, in mm, and its pose is in mm too.
+//
+// The frame of the marker is at its center with x to the right of the marker as printed, y to its bottom
+// and z into it, so that a marker seen head on is oriented the same way as the camera, whose frame has x
+// to the right of the image, y to its bottom and z forward. Distortion may be nil.
+func EstimatePose(
+	corners [4]r2.Point,
+	sizeMM float64,
+	intrinsics *transform.PinholeCameraIntrinsics,
+	distortion transform.Distorter,
+) (spatialmath.Pose, float64, error) {
+	if err := intrinsics.CheckValid(); err != nil {
+		return nil, 0, err
+	}
+	if sizeMM <= 0 {
+		return nil, 0, errors.New("marker size must be positive")
+	}
+	var observed [4]r2.Point
+	for i, c := range corners {
+		observed[i] = undistort(c, intrinsics, distortion)
+	}
+	half := sizeMM / 2
+	object := [4]r2.Point{{X: -half, Y: -half}, {X: half, Y: -half}, {X: half, Y: half}, {X: -half, Y: half}}
+
+	// the homography from the plane of the marker to normalized image coordinates is its rotation and
+	// translation up to scale.
+	h, err := computeHomography(object, observed)
+	if err != nil {
+		return nil, 0, err
+	}
+	h1 := r3.Vector{X: h[0], Y: h[3], Z: h[6]}
+	h2 := r3.Vector{X: h[1], Y: h[4], Z: h[7]}
+	h3 := r3.Vector{X: h[2], Y: h[5], Z: h[8]}
+	scale := 2 / (h1.Norm() + h2.Norm())
+	if h3.Z < 0 {
+		scale = -scale
+	}
+	c1, c2 := h1.Mul(scale), h2.Mul(scale)
+	rotation := orthonormalize(c1, c2, c1.Cross(c2))
+	translation := h3.Mul(scale)
+
+	rotation, translation = refinePose(rotation, translation, object, observed)
+
+	var sumSq float64
+	for i, p := range object {
+		projected := project(rotation, translation, p)
+		u := r2.Point{X: projected.X*intrinsics.Fx + intrinsics.Ppx, Y: projected.Y*intrinsics.Fy + intrinsics.Ppy}
+		if distortion != nil {
+			x, y := distortion.Transform(projected.X, projected.Y)
+			u = r2.Point{X: x*intrinsics.Fx + intrinsics.Ppx, Y: y*intrinsics.Fy + intrinsics.Ppy}
+		}
+		diff := u.Sub(corners[i])
+		sumSq += diff.Dot(diff)
+	}
+
+	// a spatialmath.RotationMatrix holds the inverse of the rotation its orientation composes poses with.
+	orientation, err := spatialmath.NewRotationMatrix([]float64{
+		rotation.At(0, 0), rotation.At(1, 0), rotation.At(2, 0),
+		rotation.At(0, 1), rotation.At(1, 1), rotation.At(2, 1),
+		rotation.At(0, 2), rotation.At(1, 2), rotation.At(2, 2),
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return spatialmath.NewPose(translation, orientation), math.Sqrt(sumSq / 4), nil
+}
+
+// undistort returns the normalized image coordinates of a pixel, removing distortion by fixed point
+// iteration.
+func undistort(p r2.Point, intrinsics *transform.PinholeCameraIntrinsics, distortion transform.Distorter) r2.Point {
+	distorted := r2.Point{X: (p.X - intrinsics.Ppx) / intrinsics.Fx, Y: (p.Y - intrinsics.Ppy) / intrinsics.Fy}
+	if distortion == nil {
+		return distorted
+	}
+	undistorted := distorted
+	for i := 0; i < undistortIterations; i++ {
+		x, y := distortion.Transform(undistorted.X, undistorted.Y)
+		undistorted = undistorted.Add(distorted.Sub(r2.Point{X: x, Y: y}))
+	}
+	return undistorted
+}
+
+// orthonormalize returns the rotation matrix closest to the matrix with the given columns.
+func orthonormalize(c1, c2, c3 r3.Vector) *mat.Dense {
+	m := mat.NewDense(3, 3, []float64{c1.X, c2.X, c3.X, c1.Y, c2.Y, c3.Y, c1.Z, c2.Z, c3.Z})
+	var svd mat.SVD
+	svd.Factorize(m, mat.SVDFull)
+	var u, v, r mat.Dense
+	svd.UTo(&u)
+	svd.VTo(&v)
+	r.Mul(&u, v.T())
+	if mat.Det(&r) < 0 {
+		for i := 0; i < 3; i++ {
+			u.Set(i, 2, -u.At(i, 2))
+		}
+		r.Mul(&u, v.T())
+	}
+	return &r
+}
+
+func project(rotation *mat.Dense, translation r3.Vector, p r2.Point) r2.Point {
+	x := rotation.At(0, 0)*p.X + rotation.At(0, 1)*p.Y + translation.X
+	y := rotation.At(1, 0)*p.X + rotation.At(1, 1)*p.Y + translation.Y
+	z := rotation.At(2, 0)*p.X + rotation.At(2, 1)*p.Y + translation.Z
+	return r2.Point{X: x / z, Y: y / z}
+}
+
+// rodrigues returns the rotation matrix of a rotation vector.
+func rodrigues(w r3.Vector) *mat.Dense {
+	theta := w.Norm()
+	if theta < 1e-12 {
+		return mat.NewDense(3, 3, []float64{1, -w.Z, w.Y, w.Z, 1, -w.X, -w.Y, w.X, 1})
+	}
+	k := w.Mul(1 / theta)
+	s, c := math.Sin(theta), math.Cos(theta)
+	return mat.NewDense(3, 3, []float64{
+		c + k.X*k.X*(1-c), k.X*k.Y*(1-c) - k.Z*s, k.X*k.Z*(1-c) + k.Y*s,
+		k.Y*k.X*(1-c) + k.Z*s, c + k.Y*k.Y*(1-c), k.Y*k.Z*(1-c) - k.X*s,
+		k.Z*k.X*(1-c) - k.Y*s, k.Z*k.Y*(1-c) + k.X*s, c + k.Z*k.Z*(1-c),
+	})
+}
+
+// refinePose minimizes the distances between the observed corners and where the pose projects them by
+// Gauss-Newton iteration, perturbing the rotation by a rotation vector.
+func refinePose(rotation *mat.Dense, translation r3.Vector, object, observed [4]r2.Point) (*mat.Dense, r3.Vector) {
+	residuals := func(params []float64) []float64 {
+		var r mat.Dense
+		r.Mul(rodrigues(r3.Vector{X: params[0], Y: params[1], Z: params[2]}), rotation)
+		t := translation.Add(r3.Vector{X: params[3], Y: params[4], Z: params[5]})
+		res := make([]float64, 0, 8)
+		for i, p := range object {
+			q := project(&r, t, p)
+			res = append(res, q.X-observed[i].X, q.Y-observed[i].Y)
+		}
+		return res
+	}
+	const eps = 1e-7
+	for iter := 0; iter < refineIterations; iter++ {
+		zero := make([]float64, 6)
+		r0 := residuals(zero)
+		jacobian := mat.NewDense(8, 6, nil)
+		for j := 0; j < 6; j++ {
+			params := make([]float64, 6)
+			step := eps
+			if j >= 3 {
+				step = eps * math.Max(1, translation.Norm())
+			}
+			params[j] = step
+			rj := residuals(params)
+			for i := range rj {
+				jacobian.Set(i, j, (rj[i]-r0[i])/step)
+			}
+		}
+		var jtj mat.Dense
+		jtj.Mul(jacobian.T(), jacobian)
+		var jtr mat.VecDense
+		jtr.MulVec(jacobian.T(), mat.NewVecDense(8, r0))
+		var delta mat.VecDense
+		if err := delta.SolveVec(&jtj, &jtr); err != nil {
+			break
+		}
+		params := []float64{-delta.AtVec(0), -delta.AtVec(1), -delta.AtVec(2), -delta.AtVec(3), -delta.AtVec(4), -delta.AtVec(5)}
+		var next mat.Dense
+		next.Mul(rodrigues(r3.Vector{X: params[0], Y: params[1], Z: params[2]}), rotation)
+		rotation = &next
+		translation = translation.Add(r3.Vector{X: params[3], Y: params[4], Z: params[5]})
+		if mat.Norm(&delta, 2) < 1e-10 {
+			break
+		}
+	}
+	return rotation, translation
+}
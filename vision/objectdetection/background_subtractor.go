@@ -0,0 +1,250 @@
+package objectdetection
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// the background models a BackgroundSubtractor can use.
+const (
+	// BackgroundRunningAverage models the background as the running average of each pixel, and takes pixels
+	// differing from it by more than the threshold in gray levels as foreground.
+	BackgroundRunningAverage = "running_average"
+	// BackgroundMOG models each pixel as a mixture of gaussians, some of which are the background, and takes
+	// pixels more than the threshold in standard deviations from all of those as foreground. It tolerates
+	// backgrounds which change back and forth, like leaves or flickering light, better than the running
+	// average.
+	BackgroundMOG = "mog"
+)
+
+const (
+	defaultBackgroundLearningRate        = 0.05
+	defaultRunningAverageThreshold       = 25.
+	defaultMOGThreshold                  = 2.5
+	defaultMOGGaussians                  = 3
+	defaultMOGBackgroundRatio            = 0.7
+	mogInitialVariance                   = 15. * 15.
+	mogMinVariance                       = 2. * 2.
+	foregroundValue                uint8 = 255
+)
+
+// BackgroundSubtractorConfig specifies how a BackgroundSubtractor models the background.
+type BackgroundSubtractorConfig struct {
+	// Method is BackgroundRunningAverage, the default, or BackgroundMOG.
+	Method string `json:"method,omitempty"`
+	// LearningRate is how much each frame changes the background, between 0 and 1. Higher rates take objects
+	// which stop moving into the background sooner.
+	LearningRate float64 `json:"learning_rate,omitempty"`
+	// Threshold is how far from the background a pixel has to be to be foreground, in gray levels for the
+	// running average and in standard deviations for the mixture of gaussians.
+	Threshold float64 `json:"threshold,omitempty"`
+	// Gaussians is how many gaussians model each pixel in the mixture of gaussians.
+	Gaussians int `json:"gaussians,omitempty"`
+	// BackgroundRatio is how much of the weight of the mixture of gaussians of a pixel is the background.
+	BackgroundRatio float64 `json:"background_ratio,omitempty"`
+}
+
+// BackgroundSubtractor separates what moves in a sequence of frames from a background learned from the
+// frames before. It is safe to use from multiple goroutines, but the frames given to it from all of them
+// are taken as one sequence.
+type BackgroundSubtractor struct {
+	method          string
+	learningRate    float64
+	threshold       float64
+	gaussians       int
+	backgroundRatio float64
+
+	mu     sync.Mutex
+	bounds image.Rectangle
+	// mean is the running average of each pixel.
+	mean []float64
+	// mixtures are the gaussians of each pixel, most likely to be the background first.
+	mixtures [][]gaussian
+}
+
+// gaussian is one gaussian of the mixture modeling a pixel.
+type gaussian struct {
+	weight, mean, variance float64
+}
+
+// rank is how likely the gaussian is to be the background, which is more the more often and the more
+// consistently it is seen.
+func (g gaussian) rank() float64 {
+	if g.weight == 0 {
+		return 0
+	}
+	return g.weight / math.Sqrt(g.variance)
+}
+
+// NewBackgroundSubtractor returns a BackgroundSubtractor with the config, with defaults for what is unset.
+func NewBackgroundSubtractor(cfg *BackgroundSubtractorConfig) (*BackgroundSubtractor, error) {
+	if cfg == nil {
+		cfg = &BackgroundSubtractorConfig{}
+	}
+	bs := &BackgroundSubtractor{
+		method:          cfg.Method,
+		learningRate:    cfg.LearningRate,
+		threshold:       cfg.Threshold,
+		gaussians:       cfg.Gaussians,
+		backgroundRatio: cfg.BackgroundRatio,
+	}
+	if bs.method == "" {
+		bs.method = BackgroundRunningAverage
+	}
+	if bs.method != BackgroundRunningAverage && bs.method != BackgroundMOG {
+		return nil, errors.Errorf("background method must be %q or %q, got %q", BackgroundRunningAverage, BackgroundMOG, bs.method)
+	}
+	if bs.learningRate < 0 || bs.learningRate > 1 {
+		return nil, errors.Errorf("learning_rate must be between 0 and 1, got %v", bs.learningRate)
+	}
+	if bs.learningRate == 0 {
+		bs.learningRate = defaultBackgroundLearningRate
+	}
+	if bs.threshold < 0 {
+		return nil, errors.Errorf("threshold cannot be negative, got %v", bs.threshold)
+	}
+	if bs.threshold == 0 {
+		bs.threshold = defaultRunningAverageThreshold
+		if bs.method == BackgroundMOG {
+			bs.threshold = defaultMOGThreshold
+		}
+	}
+	if bs.gaussians < 0 {
+		return nil, errors.Errorf("gaussians cannot be negative, got %v", bs.gaussians)
+	}
+	if bs.gaussians == 0 {
+		bs.gaussians = defaultMOGGaussians
+	}
+	if bs.backgroundRatio < 0 || bs.backgroundRatio > 1 {
+		return nil, errors.Errorf("background_ratio must be between 0 and 1, got %v", bs.backgroundRatio)
+	}
+	if bs.backgroundRatio == 0 {
+		bs.backgroundRatio = defaultMOGBackgroundRatio
+	}
+	return bs, nil
+}
+
+// Apply returns the foreground mask of the frame, in which moving pixels are white, and learns the frame into
+// the background. The first frame, and the first after the size of the frames changes, starts a new
+// background and has no foreground.
+func (bs *BackgroundSubtractor) Apply(img image.Image) *image.Gray {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	bounds := img.Bounds()
+	mask := image.NewGray(bounds)
+	if bounds != bs.bounds || (bs.mean == nil && bs.mixtures == nil) {
+		bs.start(img)
+		return mask
+	}
+	i := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			v := luminance(img.At(x, y))
+			var foreground bool
+			if bs.method == BackgroundMOG {
+				foreground = bs.applyMixture(bs.mixtures[i], v)
+			} else {
+				foreground = math.Abs(v-bs.mean[i]) > bs.threshold
+				bs.mean[i] += bs.learningRate * (v - bs.mean[i])
+			}
+			if foreground {
+				mask.Pix[mask.PixOffset(x, y)] = foregroundValue
+			}
+			i++
+		}
+	}
+	return mask
+}
+
+// Reset forgets the background, so that the next frame starts a new one.
+func (bs *BackgroundSubtractor) Reset() {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	bs.bounds = image.Rectangle{}
+	bs.mean, bs.mixtures = nil, nil
+}
+
+// start starts the background from a frame. Must be called with mu held.
+func (bs *BackgroundSubtractor) start(img image.Image) {
+	bounds := img.Bounds()
+	bs.bounds = bounds
+	bs.mean, bs.mixtures = nil, nil
+	n := bounds.Dx() * bounds.Dy()
+	if bs.method == BackgroundMOG {
+		bs.mixtures = make([][]gaussian, 0, n)
+		all := make([]gaussian, n*bs.gaussians)
+		for i := 0; i < n; i++ {
+			bs.mixtures = append(bs.mixtures, all[i*bs.gaussians:(i+1)*bs.gaussians])
+		}
+	} else {
+		bs.mean = make([]float64, 0, n)
+	}
+	i := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			v := luminance(img.At(x, y))
+			if bs.method == BackgroundMOG {
+				bs.mixtures[i][0] = gaussian{1, v, mogInitialVariance}
+			} else {
+				bs.mean = append(bs.mean, v)
+			}
+			i++
+		}
+	}
+}
+
+// applyMixture learns the value into the mixture of gaussians of a pixel, returning whether it is foreground.
+// This is the model of Stauffer and Grimson, with the learning rate used for the gaussians as well as for
+// their weights.
+func (bs *BackgroundSubtractor) applyMixture(mixture []gaussian, v float64) bool {
+	// the gaussians are kept in order, so the background is the first of them up to the background ratio.
+	matched, background := -1, 0
+	var cumulative float64
+	for k := range mixture {
+		g := &mixture[k]
+		if g.weight == 0 {
+			break
+		}
+		if cumulative < bs.backgroundRatio {
+			background = k + 1
+		}
+		cumulative += g.weight
+		if d := v - g.mean; matched < 0 && d*d < bs.threshold*bs.threshold*g.variance {
+			matched = k
+		}
+	}
+	for k := range mixture {
+		mixture[k].weight *= 1 - bs.learningRate
+	}
+	if matched >= 0 {
+		g := &mixture[matched]
+		g.weight += bs.learningRate
+		d := v - g.mean
+		g.mean += bs.learningRate * d
+		g.variance = math.Max(mogMinVariance, g.variance+bs.learningRate*(d*d-g.variance))
+	} else {
+		// the least likely gaussian is replaced by one around the value.
+		mixture[len(mixture)-1] = gaussian{bs.learningRate, v, mogInitialVariance}
+	}
+	var total float64
+	for _, g := range mixture {
+		total += g.weight
+	}
+	for k := range mixture {
+		mixture[k].weight /= total
+	}
+	sort.SliceStable(mixture, func(i, j int) bool {
+		return mixture[i].rank() > mixture[j].rank()
+	})
+	return matched < 0 || matched >= background
+}
+
+// luminance returns the luminance of the color from 0 to 255.
+func luminance(c color.Color) float64 {
+	return float64(color.GrayModel.Convert(c).(color.Gray).Y)
+}
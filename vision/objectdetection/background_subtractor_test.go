@@ -0,0 +1,178 @@
+package objectdetection
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+// motionFrame returns a frame of a static gradient background with a bright square at the rectangle.
+func motionFrame(square image.Rectangle) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 80, 60))
+	for y := 0; y < 60; y++ {
+		for x := 0; x < 80; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 40, 255})
+		}
+	}
+	draw.Draw(img, square, &image.Uniform{color.RGBA{250, 250, 250, 255}}, image.Point{}, draw.Src)
+	return img
+}
+
+// foreground returns the bounds of the foreground of the mask and how many pixels are in it.
+func foreground(mask *image.Gray) (image.Rectangle, int) {
+	var bounds image.Rectangle
+	count := 0
+	for y := mask.Rect.Min.Y; y < mask.Rect.Max.Y; y++ {
+		for x := mask.Rect.Min.X; x < mask.Rect.Max.X; x++ {
+			if mask.GrayAt(x, y).Y != 0 {
+				bounds = bounds.Union(image.Rect(x, y, x+1, y+1))
+				count++
+			}
+		}
+	}
+	return bounds, count
+}
+
+func TestBackgroundSubtractor(t *testing.T) {
+	for _, method := range []string{BackgroundRunningAverage, BackgroundMOG} {
+		t.Run(method, func(t *testing.T) {
+			bs, err := NewBackgroundSubtractor(&BackgroundSubtractorConfig{Method: method})
+			test.That(t, err, test.ShouldBeNil)
+
+			// the background is learned from the first frames, which have no foreground.
+			empty := motionFrame(image.Rectangle{})
+			for i := 0; i < 10; i++ {
+				mask := bs.Apply(empty)
+				test.That(t, mask.Bounds(), test.ShouldResemble, empty.Bounds())
+				_, count := foreground(mask)
+				test.That(t, count, test.ShouldEqual, 0)
+			}
+
+			// a square moving across the background is foreground wherever it is.
+			for x := 10; x < 50; x += 10 {
+				square := image.Rect(x, 20, x+10, 30)
+				bounds, count := foreground(bs.Apply(motionFrame(square)))
+				test.That(t, bounds, test.ShouldResemble, square)
+				test.That(t, count, test.ShouldEqual, 100)
+			}
+
+			// a square which stops becomes part of the background.
+			square := image.Rect(60, 40, 70, 50)
+			_, count := foreground(bs.Apply(motionFrame(square)))
+			test.That(t, count, test.ShouldEqual, 100)
+			for i := 0; i < 200; i++ {
+				bs.Apply(motionFrame(square))
+			}
+			_, count = foreground(bs.Apply(motionFrame(square)))
+			test.That(t, count, test.ShouldEqual, 0)
+
+			// a new size of frame starts a new background.
+			small := image.NewRGBA(image.Rect(0, 0, 10, 10))
+			_, count = foreground(bs.Apply(small))
+			test.That(t, count, test.ShouldEqual, 0)
+
+			bs.Reset()
+			_, count = foreground(bs.Apply(motionFrame(image.Rect(0, 0, 10, 10))))
+			test.That(t, count, test.ShouldEqual, 0)
+		})
+	}
+}
+
+func TestBackgroundSubtractorMOGFlicker(t *testing.T) {
+	// a region flickering between two brightnesses is learned as background by the mixture of gaussians,
+	// but keeps being foreground to the running average.
+	flicker := image.Rect(0, 0, 20, 20)
+	frame := func(i int) image.Image {
+		if i%2 == 0 {
+			return motionFrame(image.Rectangle{})
+		}
+		return motionFrame(flicker)
+	}
+	mog, err := NewBackgroundSubtractor(&BackgroundSubtractorConfig{Method: BackgroundMOG, LearningRate: 0.1})
+	test.That(t, err, test.ShouldBeNil)
+	average, err := NewBackgroundSubtractor(&BackgroundSubtractorConfig{Method: BackgroundRunningAverage, LearningRate: 0.1})
+	test.That(t, err, test.ShouldBeNil)
+	for i := 0; i < 100; i++ {
+		mog.Apply(frame(i))
+		average.Apply(frame(i))
+	}
+	for i := 100; i < 102; i++ {
+		_, count := foreground(mog.Apply(frame(i)))
+		test.That(t, count, test.ShouldEqual, 0)
+		_, count = foreground(average.Apply(frame(i)))
+		test.That(t, count, test.ShouldEqual, 400)
+	}
+}
+
+func TestBackgroundSubtractorConfig(t *testing.T) {
+	for _, cfg := range []BackgroundSubtractorConfig{
+		{Method: "knn"},
+		{LearningRate: -0.1},
+		{LearningRate: 1.5},
+		{Threshold: -1},
+		{Gaussians: -1},
+		{BackgroundRatio: 2},
+	} {
+		_, err := NewBackgroundSubtractor(&cfg)
+		test.That(t, err, test.ShouldNotBeNil)
+	}
+	bs, err := NewBackgroundSubtractor(nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, bs.method, test.ShouldEqual, BackgroundRunningAverage)
+	test.That(t, bs.threshold, test.ShouldEqual, defaultRunningAverageThreshold)
+	bs, err = NewBackgroundSubtractor(&BackgroundSubtractorConfig{Method: BackgroundMOG})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, bs.threshold, test.ShouldEqual, defaultMOGThreshold)
+}
+
+func TestMotionDetector(t *testing.T) {
+	det, err := NewMotionDetector(&MotionDetectorConfig{MinArea: 20, Label: "mover"})
+	test.That(t, err, test.ShouldBeNil)
+	ctx := context.Background()
+
+	dets, err := det(ctx, motionFrame(image.Rectangle{}))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, dets, test.ShouldBeEmpty)
+
+	// a large and a small square move in, and only the one larger than the minimum area is detected.
+	img := motionFrame(image.Rect(10, 10, 30, 25))
+	draw.Draw(img, image.Rect(60, 50, 63, 53), &image.Uniform{color.White}, image.Point{}, draw.Src)
+	dets, err = det(ctx, img)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, dets, test.ShouldHaveLength, 1)
+	test.That(t, *dets[0].BoundingBox(), test.ShouldResemble, image.Rect(10, 10, 30, 25))
+	test.That(t, dets[0].Label(), test.ShouldEqual, "mover")
+	mask := MaskOf(dets[0])
+	test.That(t, mask, test.ShouldNotBeNil)
+	test.That(t, mask.Bounds(), test.ShouldResemble, image.Rect(10, 10, 30, 25))
+	test.That(t, mask.AlphaAt(15, 15).A, test.ShouldEqual, 255)
+
+	cfg := &MotionDetectorConfig{MinArea: -1}
+	_, err = cfg.Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	cfg = &MotionDetectorConfig{Method: "knn"}
+	_, err = cfg.Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "knn")
+}
+
+func TestForegroundDetections(t *testing.T) {
+	// masks need not be at the origin.
+	mask := image.NewGray(image.Rect(100, 100, 120, 120))
+	for y := 105; y < 108; y++ {
+		for x := 110; x < 115; x++ {
+			mask.SetGray(x, y, color.Gray{255})
+		}
+	}
+	mask.SetGray(100, 119, color.Gray{255})
+	dets, err := ForegroundDetections(context.Background(), mask, "moving")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, dets, test.ShouldHaveLength, 2)
+	boxes := []image.Rectangle{*dets[0].BoundingBox(), *dets[1].BoundingBox()}
+	test.That(t, boxes, test.ShouldContain, image.Rect(110, 105, 115, 108))
+	test.That(t, boxes, test.ShouldContain, image.Rect(100, 119, 101, 120))
+}
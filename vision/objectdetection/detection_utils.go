@@ -12,19 +12,25 @@ import (
 	"go.viam.com/rdk/rimage"
 )
 
-// Overlay returns a color image with the bounding boxes overlaid on the original image.
+// Overlay returns a color image with the bounding boxes overlaid on the original image, along with the masks
+// and keypoints of detections which have them.
 func Overlay(img image.Image, dets []Detection) (image.Image, error) {
 	bounds := img.Bounds()
 	boxOverlay := gg.NewContext(bounds.Dx(), bounds.Dy())
+	resultImg := image.NewNRGBA(bounds) // to keep the original image intact
+	draw.Draw(resultImg, bounds, img, image.Point{}, draw.Src)
+	maskColor := image.NewUniform(color.NRGBA{255, 0, 0, 100})
 	for _, det := range dets {
 		if !det.BoundingBox().In(bounds) {
 			return nil, errors.Errorf("bounding box (%v) does not fit in image (%v)", det.BoundingBox(), bounds)
 		}
+		if mask := MaskOf(det); mask != nil {
+			draw.DrawMask(resultImg, mask.Bounds(), maskColor, image.Point{}, mask, mask.Bounds().Min, draw.Over)
+		}
 		drawDetection(boxOverlay, det)
+		drawKeypoints(boxOverlay, KeypointsOf(det))
 	}
 	overlayImg := boxOverlay.Image()
-	resultImg := image.NewNRGBA(bounds) // to keep the original image intact
-	draw.Draw(resultImg, bounds, img, image.Point{}, draw.Src)
 	draw.DrawMask(resultImg, bounds, overlayImg, image.Point{}, overlayImg, image.Point{}, draw.Over)
 	return resultImg, nil
 }
@@ -38,6 +44,15 @@ func drawDetection(img *gg.Context, d Detection) {
 	rimage.DrawString(img, text, image.Point{box.Min.X, box.Min.Y}, red, 30)
 }
 
+// drawKeypoints overlays a dot on each keypoint.
+func drawKeypoints(img *gg.Context, keypoints []Keypoint) {
+	img.SetColor(color.NRGBA{0, 255, 0, 255})
+	for _, kp := range keypoints {
+		img.DrawCircle(float64(kp.Point.X), float64(kp.Point.Y), 4)
+		img.Fill()
+	}
+}
+
 // OverlayText writes a string in the top of the image.
 func OverlayText(img image.Image, text string) image.Image {
 	gimg := gg.NewContextForImage(img)
@@ -0,0 +1,132 @@
+package objectdetection
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Keypoint is a named point on a detected object, such as a joint of a person, and the confidence that it
+// is there.
+type Keypoint struct {
+	Name  string
+	Point image.Point
+	Score float64
+}
+
+// InstanceDetection is a detection of one instance of an object, which also has the mask of the pixels of
+// the object and named keypoints on it, either of which can be empty.
+type InstanceDetection interface {
+	Detection
+	// Mask returns the mask of the pixels of the object in the coordinates of the image, whose bounds are the
+	// bounding box, or nil if there is none. Pixels of the object are opaque.
+	Mask() *image.Alpha
+	Keypoints() []Keypoint
+}
+
+// NewInstanceDetection creates a 2D detection with a mask and keypoints. The mask is cropped to the
+// bounding box.
+func NewInstanceDetection(
+	boundingBox image.Rectangle,
+	score float64,
+	label string,
+	mask *image.Alpha,
+	keypoints []Keypoint,
+) Detection {
+	if mask != nil && mask.Bounds() != boundingBox {
+		cropped := image.NewAlpha(boundingBox)
+		draw.Draw(cropped, boundingBox, mask, boundingBox.Min, draw.Src)
+		mask = cropped
+	}
+	return &instanceDetection{detection2D{boundingBox, score, label}, mask, keypoints}
+}
+
+// instanceDetection is a simple struct for storing 2D detections with masks and keypoints.
+type instanceDetection struct {
+	detection2D
+	mask      *image.Alpha
+	keypoints []Keypoint
+}
+
+// Mask returns the mask of the pixels of the object.
+func (d *instanceDetection) Mask() *image.Alpha {
+	return d.mask
+}
+
+// Keypoints returns the keypoints of the object.
+func (d *instanceDetection) Keypoints() []Keypoint {
+	return d.keypoints
+}
+
+// MaskOf returns the mask of the detection if it is an InstanceDetection, or nil.
+func MaskOf(d Detection) *image.Alpha {
+	if instance, ok := d.(InstanceDetection); ok {
+		return instance.Mask()
+	}
+	return nil
+}
+
+// KeypointsOf returns the keypoints of the detection if it is an InstanceDetection, or nil.
+func KeypointsOf(d Detection) []Keypoint {
+	if instance, ok := d.(InstanceDetection); ok {
+		return instance.Keypoints()
+	}
+	return nil
+}
+
+// withScore returns a copy of the detection with a different score, keeping its mask and keypoints.
+func withScore(d Detection, score float64) Detection {
+	mask, keypoints := MaskOf(d), KeypointsOf(d)
+	if mask == nil && keypoints == nil {
+		return NewDetection(*d.BoundingBox(), score, d.Label())
+	}
+	return NewInstanceDetection(*d.BoundingBox(), score, d.Label(), mask, keypoints)
+}
+
+// translated returns a copy of the detection moved by offset, along with its mask and keypoints, and
+// cropped to bounds.
+func translated(d Detection, offset image.Point, bounds image.Rectangle) Detection {
+	mask, keypoints := MaskOf(d), KeypointsOf(d)
+	box := d.BoundingBox().Add(offset).Intersect(bounds)
+	if mask == nil && keypoints == nil {
+		return NewDetection(box, d.Score(), d.Label())
+	}
+	if mask != nil {
+		mask = &image.Alpha{Pix: mask.Pix, Stride: mask.Stride, Rect: mask.Rect.Add(offset)}
+	}
+	moved := make([]Keypoint, len(keypoints))
+	for i, kp := range keypoints {
+		moved[i] = Keypoint{kp.Name, kp.Point.Add(offset), kp.Score}
+	}
+	return NewInstanceDetection(box, d.Score(), d.Label(), mask, moved)
+}
+
+// merged returns the detection grown to box, with the masks of the detections merged into it combined and
+// the most confident of their keypoints of each name.
+func merged(d Detection, box image.Rectangle, others []Detection) Detection {
+	all := append([]Detection{d}, others...)
+	var mask *image.Alpha
+	var keypoints []Keypoint
+	names := map[string]int{}
+	for _, det := range all {
+		if m := MaskOf(det); m != nil {
+			if mask == nil {
+				mask = image.NewAlpha(box)
+			}
+			draw.Draw(mask, m.Bounds(), m, m.Bounds().Min, draw.Over)
+		}
+		for _, kp := range KeypointsOf(det) {
+			i, ok := names[kp.Name]
+			switch {
+			case !ok:
+				names[kp.Name] = len(keypoints)
+				keypoints = append(keypoints, kp)
+			case kp.Score > keypoints[i].Score:
+				keypoints[i] = kp
+			}
+		}
+	}
+	if mask == nil && keypoints == nil {
+		return NewDetection(box, d.Score(), d.Label())
+	}
+	return NewInstanceDetection(box, d.Score(), d.Label(), mask, keypoints)
+}
@@ -0,0 +1,95 @@
+package objectdetection
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestInstanceDetection(t *testing.T) {
+	// a mask larger than the box is cropped to it.
+	mask := image.NewAlpha(image.Rect(0, 0, 40, 40))
+	for y := 10; y < 30; y++ {
+		for x := 10; x < 30; x++ {
+			mask.SetAlpha(x, y, color.Alpha{255})
+		}
+	}
+	keypoints := []Keypoint{{"head", image.Pt(20, 12), 0.9}, {"foot", image.Pt(20, 28), 0.4}}
+	d := NewInstanceDetection(image.Rect(10, 10, 30, 30), 0.8, "person", mask, keypoints)
+	test.That(t, MaskOf(d).Bounds(), test.ShouldResemble, image.Rect(10, 10, 30, 30))
+	test.That(t, MaskOf(d).AlphaAt(15, 15).A, test.ShouldEqual, 255)
+	test.That(t, KeypointsOf(d), test.ShouldResemble, keypoints)
+	plain := NewDetection(image.Rect(10, 10, 30, 30), 0.8, "person")
+	test.That(t, MaskOf(plain), test.ShouldBeNil)
+	test.That(t, KeypointsOf(plain), test.ShouldBeNil)
+
+	// postprocessors keep the masks and keypoints of the detections they change.
+	rescored := withScore(d, 0.5)
+	test.That(t, rescored.Score(), test.ShouldEqual, 0.5)
+	test.That(t, MaskOf(rescored), test.ShouldResemble, MaskOf(d))
+	moved := translated(d, image.Pt(100, 50), image.Rect(0, 0, 200, 200))
+	test.That(t, *moved.BoundingBox(), test.ShouldResemble, image.Rect(110, 60, 130, 80))
+	test.That(t, MaskOf(moved).Bounds(), test.ShouldResemble, image.Rect(110, 60, 130, 80))
+	test.That(t, MaskOf(moved).AlphaAt(115, 65).A, test.ShouldEqual, 255)
+	test.That(t, KeypointsOf(moved)[0].Point, test.ShouldResemble, image.Pt(120, 62))
+
+	other := NewInstanceDetection(image.Rect(25, 10, 40, 30), 0.6, "person", image.NewAlpha(image.Rect(25, 10, 40, 30)),
+		[]Keypoint{{"foot", image.Pt(30, 29), 0.7}, {"hand", image.Pt(35, 20), 0.5}})
+	m := merged(d, image.Rect(10, 10, 40, 30), []Detection{other})
+	test.That(t, m.Score(), test.ShouldEqual, 0.8)
+	test.That(t, MaskOf(m).Bounds(), test.ShouldResemble, image.Rect(10, 10, 40, 30))
+	test.That(t, MaskOf(m).AlphaAt(29, 20).A, test.ShouldEqual, 255)
+	test.That(t, MaskOf(m).AlphaAt(35, 20).A, test.ShouldEqual, 0)
+	test.That(t, KeypointsOf(m), test.ShouldResemble, []Keypoint{
+		{"head", image.Pt(20, 12), 0.9}, {"foot", image.Pt(30, 29), 0.7}, {"hand", image.Pt(35, 20), 0.5},
+	})
+}
+
+func TestTiledInstanceDetector(t *testing.T) {
+	// a detector which finds one object filling the middle of each tile.
+	det := func(ctx context.Context, img image.Image) ([]Detection, error) {
+		box := image.Rect(10, 10, img.Bounds().Dx()-10, img.Bounds().Dy()-10)
+		mask := image.NewAlpha(box)
+		mask.SetAlpha(box.Min.X, box.Min.Y, color.Alpha{255})
+		return []Detection{NewInstanceDetection(box, 0.9, "object", mask, []Keypoint{{"corner", box.Min, 1}})}, nil
+	}
+	tiled, err := NewTiledDetector(det, image.Pt(50, 50), 0, false)
+	test.That(t, err, test.ShouldBeNil)
+	got, err := tiled(context.Background(), image.NewRGBA(image.Rect(0, 0, 100, 50)))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, got, test.ShouldHaveLength, 2)
+	for _, d := range got {
+		mask := MaskOf(d)
+		test.That(t, mask.Bounds(), test.ShouldResemble, *d.BoundingBox())
+		test.That(t, mask.AlphaAt(d.BoundingBox().Min.X, d.BoundingBox().Min.Y).A, test.ShouldEqual, 255)
+		test.That(t, KeypointsOf(d)[0].Point, test.ShouldResemble, d.BoundingBox().Min)
+	}
+	test.That(t, got[1].BoundingBox().Min.X-got[0].BoundingBox().Min.X, test.ShouldBeIn, []int{50, -50})
+}
+
+func TestOverlayInstances(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 200, 200))
+	box := image.Rect(20, 20, 180, 180)
+	// the mask is away from the label in the top left of the box.
+	mask := image.NewAlpha(box)
+	for y := 120; y < 140; y++ {
+		for x := 120; x < 140; x++ {
+			mask.SetAlpha(x, y, color.Alpha{255})
+		}
+	}
+	d := NewInstanceDetection(box, 0.9, "a", mask, []Keypoint{{"k", image.Pt(60, 150), 1}})
+	out, err := Overlay(img, []Detection{d})
+	test.That(t, err, test.ShouldBeNil)
+	r, g, b, _ := out.At(130, 130).RGBA()
+	test.That(t, r, test.ShouldBeGreaterThan, 0)
+	test.That(t, g, test.ShouldEqual, 0)
+	test.That(t, b, test.ShouldEqual, 0)
+	// outside the mask within the box the image is unchanged.
+	r, _, _, _ = out.At(100, 130).RGBA()
+	test.That(t, r, test.ShouldEqual, 0)
+	_, g, _, _ = out.At(60, 150).RGBA()
+	test.That(t, g, test.ShouldBeGreaterThan, 0)
+}
@@ -0,0 +1,108 @@
+package objectdetection
+
+import (
+	"context"
+	"image"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/rdk/resource"
+)
+
+const (
+	defaultMotionMinArea = 100
+	defaultMotionLabel   = "motion"
+)
+
+// MotionDetectorConfig specifies the fields necessary for creating a motion detector.
+type MotionDetectorConfig struct {
+	// the fields of the BackgroundSubtractorConfig of the background.
+	Method          string  `json:"method,omitempty"`
+	LearningRate    float64 `json:"learning_rate,omitempty"`
+	Threshold       float64 `json:"threshold,omitempty"`
+	Gaussians       int     `json:"gaussians,omitempty"`
+	BackgroundRatio float64 `json:"background_ratio,omitempty"`
+	// MinArea is the smallest area in pixels of a moving region to be detected, 100 by default.
+	MinArea int    `json:"min_area_px,omitempty"`
+	Label   string `json:"label,omitempty"`
+}
+
+// Validate ensures all parts of the config are valid.
+func (cfg *MotionDetectorConfig) Validate(path string) ([]string, error) {
+	if _, err := NewBackgroundSubtractor(cfg.background()); err != nil {
+		return nil, resource.NewConfigValidationError(path, err)
+	}
+	if cfg.MinArea < 0 {
+		return nil, resource.NewConfigValidationError(path, errors.New("min_area_px cannot be negative"))
+	}
+	return nil, nil
+}
+
+func (cfg *MotionDetectorConfig) background() *BackgroundSubtractorConfig {
+	return &BackgroundSubtractorConfig{
+		Method:          cfg.Method,
+		LearningRate:    cfg.LearningRate,
+		Threshold:       cfg.Threshold,
+		Gaussians:       cfg.Gaussians,
+		BackgroundRatio: cfg.BackgroundRatio,
+	}
+}
+
+// NewMotionDetector is a detector that finds what moves between the images it is given, by subtracting a
+// background learned from them, without a model. Each connected region of foreground is one detection, with
+// its foreground as its mask. The images it is given must be consecutive frames of a static camera, and the
+// first has no detections.
+func NewMotionDetector(cfg *MotionDetectorConfig) (Detector, error) {
+	if cfg == nil {
+		cfg = &MotionDetectorConfig{}
+	}
+	subtractor, err := NewBackgroundSubtractor(cfg.background())
+	if err != nil {
+		return nil, err
+	}
+	label := cfg.Label
+	if label == "" {
+		label = defaultMotionLabel
+	}
+	minArea := cfg.MinArea
+	if minArea == 0 {
+		minArea = defaultMotionMinArea
+	}
+	det := func(ctx context.Context, img image.Image) ([]Detection, error) {
+		return ForegroundDetections(ctx, subtractor.Apply(img), label)
+	}
+	filtered, err := Build(nil, det, NewAreaFilter(minArea))
+	if err != nil {
+		return nil, err
+	}
+	return Build(nil, filtered, SortByArea())
+}
+
+// ForegroundDetections returns a detection of each connected region of the foreground of a mask, such as
+// one returned by a BackgroundSubtractor, with the foreground within its bounding box as its mask.
+func ForegroundDetections(ctx context.Context, mask *image.Gray, label string) ([]Detection, error) {
+	// the connected components are found in a copy of the mask at the origin, as they assume it is.
+	origin := mask.Rect.Min
+	atOrigin := &image.Gray{Pix: mask.Pix, Stride: mask.Stride, Rect: mask.Rect.Sub(origin)}
+	ccd := connectedComponentDetector{
+		valid: func(img image.Image, pt image.Point) bool {
+			return atOrigin.GrayAt(pt.X, pt.Y).Y != 0
+		},
+		label: label,
+	}
+	components, err := ccd.Inference(ctx, atOrigin)
+	if err != nil {
+		return nil, err
+	}
+	detections := make([]Detection, 0, len(components))
+	for _, c := range components {
+		// the boxes of connected components exclude their last row and column.
+		box := image.Rectangle{c.BoundingBox().Min, c.BoundingBox().Max.Add(image.Pt(1, 1))}.Add(origin)
+		alpha := image.NewAlpha(box)
+		for y := box.Min.Y; y < box.Max.Y; y++ {
+			copy(alpha.Pix[alpha.PixOffset(box.Min.X, y):], mask.Pix[mask.PixOffset(box.Min.X, y):mask.PixOffset(box.Max.X, y)])
+		}
+		detections = append(detections, NewInstanceDetection(box, 1, label, alpha, nil))
+	}
+	return detections, nil
+}
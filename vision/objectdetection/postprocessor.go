@@ -1,6 +1,8 @@
 package objectdetection
 
 import (
+	"image"
+	"math"
 	"sort"
 	"strings"
 )
@@ -84,3 +86,92 @@ func SortByArea() Postprocessor {
 		return in
 	}
 }
+
+// NewNMSFilter returns a function that performs non-maximum suppression, filtering out detections whose
+// intersection over union with a detection of higher score is above iouThreshold, so that only the best of
+// the boxes a model finds around one object is kept. Only detections with the same label suppress each
+// other, unless classAgnostic is true.
+func NewNMSFilter(iouThreshold float64, classAgnostic bool) Postprocessor {
+	return func(in []Detection) []Detection {
+		sorted := sortedByScore(in)
+		out := make([]Detection, 0, len(in))
+		for _, d := range sorted {
+			suppressed := false
+			for _, kept := range out {
+				if (classAgnostic || strings.EqualFold(kept.Label(), d.Label())) &&
+					IoU(*kept.BoundingBox(), *d.BoundingBox()) > iouThreshold {
+					suppressed = true
+					break
+				}
+			}
+			if !suppressed {
+				out = append(out, d)
+			}
+		}
+		return out
+	}
+}
+
+// NewSoftNMSFilter returns a function that performs Gaussian Soft-NMS, from Bodla et al., "Improving Object
+// Detection With One Line of Code". Rather than filtering out detections which overlap a detection of higher
+// score, their scores are decayed by exp(-IoU^2 / sigma), so that overlapping objects are still found, and
+// detections whose scores fall below minScore are filtered out. Only detections with the same label decay
+// each other, unless classAgnostic is true.
+func NewSoftNMSFilter(sigma, minScore float64, classAgnostic bool) Postprocessor {
+	return func(in []Detection) []Detection {
+		remaining := sortedByScore(in)
+		scores := make([]float64, len(remaining))
+		for i, d := range remaining {
+			scores[i] = d.Score()
+		}
+		out := make([]Detection, 0, len(in))
+		for len(remaining) > 0 {
+			best := 0
+			for i := range scores {
+				if scores[i] > scores[best] {
+					best = i
+				}
+			}
+			d, score := remaining[best], scores[best]
+			if score < minScore {
+				break
+			}
+			remaining = append(remaining[:best], remaining[best+1:]...)
+			scores = append(scores[:best], scores[best+1:]...)
+			if score != d.Score() {
+				d = withScore(d, score)
+			}
+			out = append(out, d)
+			for i, r := range remaining {
+				if classAgnostic || strings.EqualFold(r.Label(), d.Label()) {
+					overlap := IoU(*d.BoundingBox(), *r.BoundingBox())
+					scores[i] *= math.Exp(-overlap * overlap / sigma)
+				}
+			}
+		}
+		return out
+	}
+}
+
+// sortedByScore returns a copy of the detections sorted by score, highest first.
+func sortedByScore(in []Detection) []Detection {
+	sorted := append([]Detection(nil), in...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Score() > sorted[j].Score()
+	})
+	return sorted
+}
+
+// IoU returns the intersection over union of two boxes.
+func IoU(a, b image.Rectangle) float64 {
+	intersection := a.Intersect(b)
+	if intersection.Empty() {
+		return 0
+	}
+	inter := float64(intersection.Dx() * intersection.Dy())
+	union := float64(a.Dx()*a.Dy()+b.Dx()*b.Dy()) - inter
+	if union <= 0 {
+		return 0
+	}
+	return inter / union
+}
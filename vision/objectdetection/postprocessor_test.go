@@ -2,6 +2,7 @@ package objectdetection
 
 import (
 	"image"
+	"math"
 	"testing"
 
 	"go.viam.com/test"
@@ -71,3 +72,42 @@ func TestPostprocessors(t *testing.T) {
 	test.That(t, labelList, test.ShouldContain, "C")
 	test.That(t, labelList, test.ShouldContain, "D")
 }
+
+func TestNMSPostprocessors(t *testing.T) {
+	d := []Detection{
+		NewDetection(image.Rect(10, 10, 110, 110), 0.8, "cat"),
+		NewDetection(image.Rect(0, 0, 100, 100), 0.9, "cat"),
+		NewDetection(image.Rect(5, 5, 105, 105), 0.7, "dog"),
+		NewDetection(image.Rect(300, 300, 350, 350), 0.6, "Cat"),
+	}
+	labels := func(got []Detection) []string {
+		out := make([]string, 0, len(got))
+		for _, g := range got {
+			out = append(out, g.Label())
+		}
+		return out
+	}
+
+	got := NewNMSFilter(0.5, false)(d)
+	test.That(t, labels(got), test.ShouldResemble, []string{"cat", "dog", "Cat"})
+	test.That(t, got[0].Score(), test.ShouldEqual, 0.9)
+	got = NewNMSFilter(0.5, true)(d)
+	test.That(t, labels(got), test.ShouldResemble, []string{"cat", "Cat"})
+	got = NewNMSFilter(0.9, true)(d)
+	test.That(t, got, test.ShouldHaveLength, 4)
+
+	// soft-NMS keeps the overlapping cat with a lower score.
+	got = NewSoftNMSFilter(0.5, 0.3, false)(d)
+	test.That(t, labels(got), test.ShouldResemble, []string{"cat", "dog", "Cat", "cat"})
+	test.That(t, *got[3].BoundingBox(), test.ShouldResemble, image.Rect(10, 10, 110, 110))
+	test.That(t, got[3].Score(), test.ShouldAlmostEqual, 0.8*math.Exp(-IoU(*d[0].BoundingBox(), *d[1].BoundingBox())*
+		IoU(*d[0].BoundingBox(), *d[1].BoundingBox())/0.5))
+	// the dog overlaps both cats, and is decayed out by them when classes are ignored.
+	got = NewSoftNMSFilter(0.5, 0.3, true)(d)
+	test.That(t, labels(got), test.ShouldResemble, []string{"cat", "Cat", "cat"})
+	// the input is not changed.
+	test.That(t, d[0].Score(), test.ShouldEqual, 0.8)
+
+	test.That(t, IoU(image.Rect(0, 0, 10, 10), image.Rect(5, 0, 15, 10)), test.ShouldAlmostEqual, 1./3)
+	test.That(t, IoU(image.Rect(0, 0, 10, 10), image.Rect(20, 20, 30, 30)), test.ShouldEqual, 0)
+}
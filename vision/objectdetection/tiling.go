@@ -0,0 +1,136 @@
+package objectdetection
+
+import (
+	"context"
+	"image"
+	"image/draw"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// tileMergeThreshold is how much of the smaller of two boxes from different tiles must be covered by the
+// other for them to be taken as parts of the same object, cut by the border between the tiles.
+const tileMergeThreshold = 0.5
+
+// NewTiledDetector returns a detector which runs det on tiles of the image of tileSize, rather than on the
+// whole image, to find objects too small to be found in the whole image once it is shrunk to the input size
+// of a model. Neighboring tiles overlap by the given fraction of their size, so that objects on the border
+// between two tiles are found whole in at least one of them. Boxes of the same label found in different tiles
+// which mostly overlap are merged into one box around both with the higher score. If includeFullImage is
+// true, det is also run on the whole image to find objects larger than a tile.
+func NewTiledDetector(det Detector, tileSize image.Point, overlap float64, includeFullImage bool) (Detector, error) {
+	if det == nil {
+		return nil, errors.New("must have a Detector to tile")
+	}
+	if tileSize.X <= 0 || tileSize.Y <= 0 {
+		return nil, errors.Errorf("tile size must be positive, got %v", tileSize)
+	}
+	if overlap < 0 || overlap >= 1 {
+		return nil, errors.Errorf("tile overlap must be at least 0 and less than 1, got %v", overlap)
+	}
+	return func(ctx context.Context, img image.Image) ([]Detection, error) {
+		var found []tileDetection
+		tiles := imageTiles(img.Bounds(), tileSize, overlap)
+		if includeFullImage && len(tiles) > 1 {
+			tiles = append(tiles, img.Bounds())
+		}
+		for i, tile := range tiles {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			crop := image.NewRGBA(image.Rect(0, 0, tile.Dx(), tile.Dy()))
+			draw.Draw(crop, crop.Bounds(), img, tile.Min, draw.Src)
+			detections, err := det(ctx, crop)
+			if err != nil {
+				return nil, err
+			}
+			for _, d := range detections {
+				found = append(found, tileDetection{translated(d, tile.Min, img.Bounds()), i})
+			}
+		}
+		return mergeTileDetections(found), nil
+	}, nil
+}
+
+// imageTiles returns the tiles of tileSize, or smaller if the image is, covering bounds with at least the
+// given overlap between neighbors.
+func imageTiles(bounds image.Rectangle, tileSize image.Point, overlap float64) []image.Rectangle {
+	starts := func(size, tile int) []int {
+		if size <= tile {
+			return []int{0}
+		}
+		step := max(1, tile-int(overlap*float64(tile)))
+		var out []int
+		for start := 0; start+tile < size; start += step {
+			out = append(out, start)
+		}
+		// the last tile is against the far edge.
+		return append(out, size-tile)
+	}
+	var tiles []image.Rectangle
+	for _, y := range starts(bounds.Dy(), tileSize.Y) {
+		for _, x := range starts(bounds.Dx(), tileSize.X) {
+			tile := image.Rect(x, y, x+tileSize.X, y+tileSize.Y).Add(bounds.Min)
+			tiles = append(tiles, tile.Intersect(bounds))
+		}
+	}
+	return tiles
+}
+
+// tileDetection is a detection and the index of the tile it was found in.
+type tileDetection struct {
+	Detection
+	tile int
+}
+
+// mergeTileDetections greedily merges each detection, highest score first, with the lower scoring
+// detections of the same label from other tiles that mostly overlap it, or what it has been merged with,
+// combining their masks and keypoints.
+func mergeTileDetections(found []tileDetection) []Detection {
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].Score() > found[j].Score()
+	})
+	isMerged := make([]bool, len(found))
+	out := make([]Detection, 0, len(found))
+	for i, d := range found {
+		if isMerged[i] {
+			continue
+		}
+		box := *d.BoundingBox()
+		var others []Detection
+		// merging grows the box, which can make it overlap boxes passed over before.
+		for grown := true; grown; {
+			grown = false
+			for j := i + 1; j < len(found); j++ {
+				other := found[j]
+				if isMerged[j] || other.tile == d.tile || !strings.EqualFold(other.Label(), d.Label()) {
+					continue
+				}
+				if intersectionOverSmaller(box, *other.BoundingBox()) >= tileMergeThreshold {
+					box = box.Union(*other.BoundingBox())
+					others = append(others, other.Detection)
+					isMerged[j] = true
+					grown = true
+				}
+			}
+		}
+		if len(others) == 0 {
+			out = append(out, d.Detection)
+		} else {
+			out = append(out, merged(d.Detection, box, others))
+		}
+	}
+	return out
+}
+
+// intersectionOverSmaller returns the area of the intersection of two boxes over the area of the smaller.
+func intersectionOverSmaller(a, b image.Rectangle) float64 {
+	intersection := a.Intersect(b)
+	if intersection.Empty() {
+		return 0
+	}
+	smaller := min(a.Dx()*a.Dy(), b.Dx()*b.Dy())
+	return float64(intersection.Dx()*intersection.Dy()) / float64(smaller)
+}
@@ -0,0 +1,104 @@
+package objectdetection
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/pkg/errors"
+	"go.viam.com/test"
+)
+
+// brightDetector detects the bright pixels of an image as one object, and records the sizes of the images it
+// is run on.
+type brightDetector struct {
+	sizes []image.Point
+}
+
+func (b *brightDetector) detect(ctx context.Context, img image.Image) ([]Detection, error) {
+	if img.Bounds().Min != (image.Point{}) {
+		return nil, errors.New("image does not start at the origin")
+	}
+	b.sizes = append(b.sizes, img.Bounds().Size())
+	box := image.Rectangle{}
+	for y := 0; y < img.Bounds().Dy(); y++ {
+		for x := 0; x < img.Bounds().Dx(); x++ {
+			if r, _, _, _ := img.At(x, y).RGBA(); r > 0 {
+				box = box.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	if box.Empty() {
+		return nil, nil
+	}
+	return []Detection{NewDetection(box, 0.9, "bright")}, nil
+}
+
+func TestTiledDetector(t *testing.T) {
+	ctx := context.Background()
+	// a larger image, so that the image tiled does not start at the origin.
+	full := image.NewRGBA(image.Rect(0, 0, 250, 150))
+	for y := 80; y < 100; y++ {
+		for x := 140; x < 160; x++ {
+			full.Set(x, y, color.White)
+		}
+	}
+	img := full.SubImage(image.Rect(50, 40, 250, 140))
+
+	_, err := NewTiledDetector(nil, image.Pt(100, 100), 0, false)
+	test.That(t, err, test.ShouldNotBeNil)
+	b := &brightDetector{}
+	_, err = NewTiledDetector(b.detect, image.Pt(0, 100), 0, false)
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = NewTiledDetector(b.detect, image.Pt(100, 100), 1, false)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	// the square is on the border between the first two tiles, and whole in the second.
+	det, err := NewTiledDetector(b.detect, image.Pt(100, 100), 0.2, false)
+	test.That(t, err, test.ShouldBeNil)
+	got, err := det(ctx, img)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, b.sizes, test.ShouldResemble, []image.Point{{100, 100}, {100, 100}, {100, 100}})
+	test.That(t, got, test.ShouldHaveLength, 1)
+	test.That(t, *got[0].BoundingBox(), test.ShouldResemble, image.Rect(140, 80, 160, 100))
+	test.That(t, got[0].Score(), test.ShouldEqual, 0.9)
+
+	// without overlap, the pieces of the square are merged with the whole of it found in the full image.
+	b.sizes = nil
+	det, err = NewTiledDetector(b.detect, image.Pt(100, 60), 0, true)
+	test.That(t, err, test.ShouldBeNil)
+	got, err = det(ctx, img)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, b.sizes, test.ShouldHaveLength, 5)
+	test.That(t, b.sizes[1], test.ShouldResemble, image.Pt(100, 60))
+	test.That(t, b.sizes[4], test.ShouldResemble, image.Pt(200, 100))
+	test.That(t, got, test.ShouldHaveLength, 1)
+	test.That(t, *got[0].BoundingBox(), test.ShouldResemble, image.Rect(140, 80, 160, 100))
+
+	// an image smaller than a tile is one tile.
+	b.sizes = nil
+	got, err = det(ctx, full.SubImage(image.Rect(130, 70, 170, 110)))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, b.sizes, test.ShouldResemble, []image.Point{{40, 40}})
+	test.That(t, got, test.ShouldHaveLength, 1)
+
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = det(cancelCtx, img)
+	test.That(t, err, test.ShouldBeError, context.Canceled)
+}
+
+func TestImageTiles(t *testing.T) {
+	tiles := imageTiles(image.Rect(0, 0, 3840, 2160), image.Pt(640, 640), 0.25)
+	covered := image.Rectangle{}
+	for _, tile := range tiles {
+		test.That(t, tile.Size(), test.ShouldResemble, image.Pt(640, 640))
+		covered = covered.Union(tile)
+	}
+	test.That(t, covered, test.ShouldResemble, image.Rect(0, 0, 3840, 2160))
+	// 480 pixel steps, with the last tiles against the right and bottom edges.
+	test.That(t, tiles, test.ShouldHaveLength, 8*5)
+	test.That(t, tiles[1].Min, test.ShouldResemble, image.Pt(480, 0))
+	test.That(t, tiles[len(tiles)-1].Min, test.ShouldResemble, image.Pt(3200, 1520))
+}
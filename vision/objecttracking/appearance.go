@@ -0,0 +1,67 @@
+package objecttracking
+
+import (
+	"image"
+	"math"
+)
+
+const (
+	// binsPerChannel is how many bins each color channel is quantized to in appearance histograms.
+	binsPerChannel = 4
+	// maxAppearanceSamples bounds how many pixels are sampled along each side of a box.
+	maxAppearanceSamples = 32
+	// appearanceMomentum is how much of the appearance of a track is kept when it is updated.
+	appearanceMomentum = 0.8
+)
+
+// histogram is the normalized color histogram of the pixels within a box.
+type histogram []float64
+
+// colorHistogram returns the color histogram of the pixels of img within box.
+func colorHistogram(img image.Image, box image.Rectangle) histogram {
+	box = box.Intersect(img.Bounds())
+	if box.Empty() {
+		return nil
+	}
+	h := make(histogram, binsPerChannel*binsPerChannel*binsPerChannel)
+	stepX := max(1, box.Dx()/maxAppearanceSamples)
+	stepY := max(1, box.Dy()/maxAppearanceSamples)
+	total := 0.0
+	for y := box.Min.Y; y < box.Max.Y; y += stepY {
+		for x := box.Min.X; x < box.Max.X; x += stepX {
+			r, g, b, _ := img.At(x, y).RGBA()
+			bin := func(c uint32) int { return int(c>>8) * binsPerChannel / 256 }
+			h[(bin(r)*binsPerChannel+bin(g))*binsPerChannel+bin(b)]++
+			total++
+		}
+	}
+	for i := range h {
+		h[i] /= total
+	}
+	return h
+}
+
+// similarity returns the Bhattacharyya coefficient of two histograms, from 0 for histograms which don't
+// overlap to 1 for identical ones.
+func (h histogram) similarity(other histogram) float64 {
+	if len(h) != len(other) {
+		return 0
+	}
+	coefficient := 0.0
+	for i := range h {
+		coefficient += math.Sqrt(h[i] * other[i])
+	}
+	return math.Min(coefficient, 1)
+}
+
+// blend returns the appearance of a track updated with the appearance of a detection.
+func (h histogram) blend(other histogram) histogram {
+	if len(h) != len(other) {
+		return other
+	}
+	blended := make(histogram, len(h))
+	for i := range h {
+		blended[i] = appearanceMomentum*h[i] + (1-appearanceMomentum)*other[i]
+	}
+	return blended
+}
@@ -0,0 +1,90 @@
+package objecttracking
+
+import "math"
+
+// assign returns the assignment of rows to columns of cost which minimizes the total cost, found with the
+// Hungarian algorithm. Rows which are assigned no column are -1.
+func assign(cost [][]float64) []int {
+	rows := len(cost)
+	if rows == 0 {
+		return nil
+	}
+	cols := len(cost[0])
+	// the matrix is padded to be square with costs greater than any real cost, so that padded assignments
+	// are never preferred.
+	n := max(rows, cols)
+	padding := 1.0
+	for _, row := range cost {
+		for _, c := range row {
+			padding = math.Max(padding, c+1)
+		}
+	}
+	at := func(i, j int) float64 {
+		if i < rows && j < cols {
+			return cost[i][j]
+		}
+		return padding
+	}
+
+	// potentials u of rows and v of columns, and the row p assigned to each column, all 1-indexed with
+	// column 0 used as the starting point of each augmenting path.
+	u := make([]float64, n+1)
+	v := make([]float64, n+1)
+	p := make([]int, n+1)
+	way := make([]int, n+1)
+	for i := 1; i <= n; i++ {
+		p[0] = i
+		j0 := 0
+		minv := make([]float64, n+1)
+		used := make([]bool, n+1)
+		for j := range minv {
+			minv[j] = math.Inf(1)
+		}
+		for {
+			used[j0] = true
+			i0, delta, j1 := p[j0], math.Inf(1), 0
+			for j := 1; j <= n; j++ {
+				if used[j] {
+					continue
+				}
+				cur := at(i0-1, j-1) - u[i0] - v[j]
+				if cur < minv[j] {
+					minv[j] = cur
+					way[j] = j0
+				}
+				if minv[j] < delta {
+					delta = minv[j]
+					j1 = j
+				}
+			}
+			for j := 0; j <= n; j++ {
+				if used[j] {
+					u[p[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+			j0 = j1
+			if p[j0] == 0 {
+				break
+			}
+		}
+		for j0 != 0 {
+			j1 := way[j0]
+			p[j0] = p[j1]
+			j0 = j1
+		}
+	}
+
+	assignment := make([]int, rows)
+	for i := range assignment {
+		assignment[i] = -1
+	}
+	for j := 1; j <= n; j++ {
+		if i := p[j] - 1; i < rows && j-1 < cols {
+			assignment[i] = j - 1
+		}
+	}
+	return assignment
+}
@@ -0,0 +1,139 @@
+package objecttracking
+
+import (
+	"image"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// boxFilter is the constant velocity Kalman filter SORT uses to track a bounding box. Its state is the
+// center u, v of the box, its area s and aspect ratio r, and the rates of change of u, v and s in pixels
+// per second; the aspect ratio is assumed to be constant.
+type boxFilter struct {
+	x *mat.VecDense
+	p *mat.Dense
+	q *mat.Dense
+	r *mat.Dense
+	h *mat.Dense
+}
+
+const (
+	stateSize       = 7
+	measurementSize = 4
+)
+
+func newBoxFilter(box image.Rectangle) *boxFilter {
+	h := mat.NewDense(measurementSize, stateSize, nil)
+	for i := 0; i < measurementSize; i++ {
+		h.Set(i, i, 1)
+	}
+	// the initial velocities are unobserved, so they are given a high uncertainty.
+	p := mat.NewDense(stateSize, stateSize, nil)
+	for i, v := range []float64{10, 10, 10, 10, 1e4, 1e4, 1e4} {
+		p.Set(i, i, v)
+	}
+	q := mat.NewDense(stateSize, stateSize, nil)
+	for i, v := range []float64{1, 1, 1, 1, 0.01, 0.01, 0.0001} {
+		q.Set(i, i, v)
+	}
+	r := mat.NewDense(measurementSize, measurementSize, nil)
+	for i, v := range []float64{1, 1, 10, 10} {
+		r.Set(i, i, v)
+	}
+	f := &boxFilter{x: mat.NewVecDense(stateSize, nil), p: p, q: q, r: r, h: h}
+	z := boxToMeasurement(box)
+	for i := 0; i < measurementSize; i++ {
+		f.x.SetVec(i, z.AtVec(i))
+	}
+	return f
+}
+
+// predict advances the state by dt seconds.
+func (f *boxFilter) predict(dt float64) {
+	// keep the area from becoming negative.
+	if f.x.AtVec(2)+dt*f.x.AtVec(6) <= 0 {
+		f.x.SetVec(6, 0)
+	}
+	transition := mat.NewDense(stateSize, stateSize, nil)
+	for i := 0; i < stateSize; i++ {
+		transition.Set(i, i, 1)
+	}
+	transition.Set(0, 4, dt)
+	transition.Set(1, 5, dt)
+	transition.Set(2, 6, dt)
+
+	var x mat.VecDense
+	x.MulVec(transition, f.x)
+	f.x = &x
+	var p mat.Dense
+	p.Product(transition, f.p, transition.T())
+	p.Add(&p, f.q)
+	f.p = &p
+}
+
+// update corrects the state with a detected box.
+func (f *boxFilter) update(box image.Rectangle) {
+	z := boxToMeasurement(box)
+	var y mat.VecDense
+	y.MulVec(f.h, f.x)
+	y.SubVec(z, &y)
+
+	var s mat.Dense
+	s.Product(f.h, f.p, f.h.T())
+	s.Add(&s, f.r)
+	var sInv mat.Dense
+	if err := sInv.Inverse(&s); err != nil {
+		return
+	}
+	var k mat.Dense
+	k.Product(f.p, f.h.T(), &sInv)
+
+	var correction mat.VecDense
+	correction.MulVec(&k, &y)
+	f.x.AddVec(f.x, &correction)
+
+	var kh mat.Dense
+	kh.Mul(&k, f.h)
+	identity := mat.NewDense(stateSize, stateSize, nil)
+	for i := 0; i < stateSize; i++ {
+		identity.Set(i, i, 1)
+	}
+	identity.Sub(identity, &kh)
+	var p mat.Dense
+	p.Mul(identity, f.p)
+	f.p = &p
+}
+
+// box returns the estimated bounding box.
+func (f *boxFilter) box() image.Rectangle {
+	u, v, s, r := f.x.AtVec(0), f.x.AtVec(1), math.Max(f.x.AtVec(2), 0), math.Max(f.x.AtVec(3), 0)
+	w := math.Sqrt(s * r)
+	h := 0.0
+	if w > 0 {
+		h = s / w
+	}
+	return image.Rect(
+		int(math.Round(u-w/2)), int(math.Round(v-h/2)),
+		int(math.Round(u+w/2)), int(math.Round(v+h/2)),
+	)
+}
+
+// velocity returns the estimated velocity of the center of the box in pixels per second.
+func (f *boxFilter) velocity() (float64, float64) {
+	return f.x.AtVec(4), f.x.AtVec(5)
+}
+
+func boxToMeasurement(box image.Rectangle) *mat.VecDense {
+	w, h := float64(box.Dx()), float64(box.Dy())
+	r := 0.0
+	if h > 0 {
+		r = w / h
+	}
+	return mat.NewVecDense(measurementSize, []float64{
+		float64(box.Min.X) + w/2,
+		float64(box.Min.Y) + h/2,
+		w * h,
+		r,
+	})
+}
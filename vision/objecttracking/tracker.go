@@ -0,0 +1,337 @@
+// Package objecttracking follows objects detected in consecutive images, assigning each a track ID which
+// stays the same for as long as the object is tracked.
+//
+// Tracking follows SORT (Simple Online and Realtime Tracking): the bounding box of every track is
+// predicted into the next image by a constant velocity Kalman filter, and detections are assigned to
+// tracks by the Hungarian algorithm, scored by how much their boxes overlap and, optionally, how similar
+// the colors within them are.
+package objecttracking
+
+import (
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/pkg/errors"
+
+	"go.viam.com/rdk/vision/objectdetection"
+)
+
+// Config describes how detections are associated to tracks and when tracks are born and die.
+type Config struct {
+	// MinHits is how many consecutive images a new object has to be detected in before its track is
+	// reported, which suppresses false detections.
+	MinHits int
+	// MaxAge is how many consecutive images a track survives without being detected in, which keeps
+	// the track ID of an object which is briefly occluded or missed.
+	MaxAge int
+	// MatchThreshold is the lowest score a detection and track are associated with, from 0 to 1.
+	MatchThreshold float64
+	// AppearanceWeight is how much the similarity of the colors within the boxes of a detection and track
+	// contributes to their score, from 0 to 1, with the overlap of their boxes contributing the rest.
+	AppearanceWeight float64
+}
+
+// DefaultConfig returns the configuration of SORT, with appearance contributing a quarter of the score.
+func DefaultConfig() Config {
+	return Config{MinHits: 3, MaxAge: 5, MatchThreshold: 0.3, AppearanceWeight: 0.25}
+}
+
+// Validate ensures the configuration is within range.
+func (cfg Config) Validate() error {
+	if cfg.MinHits < 0 {
+		return errors.New("min hits cannot be negative")
+	}
+	if cfg.MaxAge < 0 {
+		return errors.New("max age cannot be negative")
+	}
+	if cfg.MatchThreshold < 0 || cfg.MatchThreshold > 1 {
+		return errors.New("match threshold must be between 0 and 1")
+	}
+	if cfg.AppearanceWeight < 0 || cfg.AppearanceWeight > 1 {
+		return errors.New("appearance weight must be between 0 and 1")
+	}
+	return nil
+}
+
+// Track is the state of a tracked object.
+type Track struct {
+	ID    int
+	Label string
+	// BoundingBox is the box estimated by the Kalman filter.
+	BoundingBox image.Rectangle
+	// VelocityX and VelocityY are the estimated velocity of the center of the box in pixels per second.
+	VelocityX float64
+	VelocityY float64
+	// Score is the score of the detection the track was last updated with.
+	Score float64
+	// Hits is how many images the object has been detected in, and Age how many images it has been
+	// tracked for.
+	Hits int
+	Age  int
+	// Missed is how many consecutive images the object hasn't been detected in.
+	Missed int
+}
+
+// TrackedDetection is a detection of a tracked object. Its label is the label of the object followed by
+// its track ID, as formatted by TrackLabel, so that the track ID survives being sent through the vision
+// service API.
+type TrackedDetection interface {
+	objectdetection.Detection
+	TrackID() int
+	ClassLabel() string
+	Velocity() (float64, float64)
+}
+
+// TrackLabel returns the label of the detection of the tracked object with the given label and track ID.
+func TrackLabel(label string, id int) string {
+	return fmt.Sprintf("%s:%d", label, id)
+}
+
+// ParseTrackLabel returns the label and track ID of the label of a tracked detection.
+func ParseTrackLabel(trackLabel string) (string, int, bool) {
+	i := strings.LastIndex(trackLabel, ":")
+	if i < 0 {
+		return "", 0, false
+	}
+	id, err := strconv.Atoi(trackLabel[i+1:])
+	if err != nil {
+		return "", 0, false
+	}
+	return trackLabel[:i], id, true
+}
+
+type trackedDetection struct {
+	Track
+}
+
+func (d *trackedDetection) BoundingBox() *image.Rectangle {
+	return &d.Track.BoundingBox
+}
+
+func (d *trackedDetection) Score() float64 {
+	return d.Track.Score
+}
+
+func (d *trackedDetection) Label() string {
+	return TrackLabel(d.Track.Label, d.ID)
+}
+
+func (d *trackedDetection) TrackID() int {
+	return d.ID
+}
+
+func (d *trackedDetection) ClassLabel() string {
+	return d.Track.Label
+}
+
+func (d *trackedDetection) Velocity() (float64, float64) {
+	return d.VelocityX, d.VelocityY
+}
+
+func (d *trackedDetection) String() string {
+	return fmt.Sprintf("Label: %s, Score: %.2f, Box: %v, Velocity: (%.1f, %.1f)",
+		d.Label(), d.Track.Score, d.Track.BoundingBox, d.VelocityX, d.VelocityY)
+}
+
+type track struct {
+	id         int
+	label      string
+	score      float64
+	filter     *boxFilter
+	appearance histogram
+	hits       int
+	streak     int
+	confirmed  bool
+	age        int
+	missed     int
+}
+
+func (t *track) state() Track {
+	vx, vy := t.filter.velocity()
+	return Track{
+		ID:          t.id,
+		Label:       t.label,
+		BoundingBox: t.filter.box(),
+		VelocityX:   vx,
+		VelocityY:   vy,
+		Score:       t.score,
+		Hits:        t.hits,
+		Age:         t.age,
+		Missed:      t.missed,
+	}
+}
+
+// Tracker tracks the objects detected in consecutive images of a single camera.
+type Tracker struct {
+	cfg   Config
+	clock clock.Clock
+
+	mu         sync.Mutex
+	tracks     []*track
+	nextID     int
+	frames     int
+	lastUpdate time.Time
+}
+
+// NewTracker returns a Tracker which tracks objects as described by cfg, measuring the time between
+// images with clk.
+func NewTracker(cfg Config, clk clock.Clock) *Tracker {
+	return &Tracker{cfg: cfg, clock: clk, nextID: 1}
+}
+
+// Update associates the detections of the next image to the tracked objects and returns the detections
+// of the objects which are tracked, as TrackedDetections. The image is only used to compare the
+// appearance of detections and may be nil if the appearance weight is 0.
+func (t *Tracker) Update(img image.Image, detections []objectdetection.Detection) []objectdetection.Detection {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.clock.Now()
+	dt := 0.0
+	if !t.lastUpdate.IsZero() {
+		dt = now.Sub(t.lastUpdate).Seconds()
+	}
+	t.lastUpdate = now
+	t.frames++
+
+	for _, tr := range t.tracks {
+		tr.filter.predict(dt)
+		tr.age++
+	}
+
+	var appearances []histogram
+	if t.cfg.AppearanceWeight > 0 && img != nil {
+		appearances = make([]histogram, len(detections))
+		for i, d := range detections {
+			appearances[i] = colorHistogram(img, *d.BoundingBox())
+		}
+	}
+
+	matchedTracks := make([]bool, len(t.tracks))
+	matchedDetections := make([]bool, len(detections))
+	if len(detections) > 0 && len(t.tracks) > 0 {
+		cost := make([][]float64, len(detections))
+		for i, d := range detections {
+			cost[i] = make([]float64, len(t.tracks))
+			for j, tr := range t.tracks {
+				cost[i][j] = 1 - t.score(d, appearances, i, tr)
+			}
+		}
+		for i, j := range assign(cost) {
+			if j < 0 || 1-cost[i][j] < t.cfg.MatchThreshold || 1-cost[i][j] <= 0 {
+				continue
+			}
+			matchedDetections[i], matchedTracks[j] = true, true
+			tr := t.tracks[j]
+			tr.filter.update(*detections[i].BoundingBox())
+			tr.score = detections[i].Score()
+			tr.hits++
+			tr.streak++
+			tr.confirmed = tr.confirmed || tr.streak >= t.cfg.MinHits
+			tr.missed = 0
+			if appearances != nil {
+				tr.appearance = tr.appearance.blend(appearances[i])
+			}
+		}
+	}
+
+	// tracks which weren't matched are missed, and die once they have been missed for too long.
+	alive := t.tracks[:0]
+	for j, tr := range t.tracks {
+		if !matchedTracks[j] {
+			tr.missed++
+			tr.streak = 0
+		}
+		if tr.missed <= t.cfg.MaxAge {
+			alive = append(alive, tr)
+		}
+	}
+	t.tracks = alive
+
+	// detections which weren't matched are the birth of new tracks.
+	for i, d := range detections {
+		if matchedDetections[i] {
+			continue
+		}
+		tr := &track{
+			id:     t.nextID,
+			label:  d.Label(),
+			score:  d.Score(),
+			filter: newBoxFilter(*d.BoundingBox()),
+			hits:   1,
+			streak: 1,
+			age:    1,
+		}
+		if appearances != nil {
+			tr.appearance = appearances[i]
+		}
+		t.nextID++
+		t.tracks = append(t.tracks, tr)
+	}
+
+	// like SORT, only tracks detected in this image are reported, once they've been detected in enough
+	// consecutive images or while tracking is starting up. Unlike SORT, a track which has been reported
+	// once is reported again as soon as it is detected after being missed.
+	var tracked []objectdetection.Detection
+	for _, tr := range t.tracks {
+		if tr.missed == 0 && (tr.confirmed || tr.streak >= t.cfg.MinHits || t.frames <= t.cfg.MinHits) {
+			tracked = append(tracked, &trackedDetection{tr.state()})
+		}
+	}
+	return tracked
+}
+
+// score returns how likely the ith detection is of the object of a track, from 0 to 1. Detections are
+// only associated to tracks with the same label whose predicted box overlaps theirs.
+func (t *Tracker) score(d objectdetection.Detection, appearances []histogram, i int, tr *track) float64 {
+	if d.Label() != tr.label {
+		return 0
+	}
+	overlap := iou(*d.BoundingBox(), tr.filter.box())
+	if overlap <= 0 {
+		return 0
+	}
+	if appearances == nil || tr.appearance == nil {
+		return overlap
+	}
+	return (1-t.cfg.AppearanceWeight)*overlap + t.cfg.AppearanceWeight*appearances[i].similarity(tr.appearance)
+}
+
+// Tracks returns the state of every track which is alive, including those which haven't been reported yet.
+func (t *Tracker) Tracks() []Track {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tracks := make([]Track, 0, len(t.tracks))
+	for _, tr := range t.tracks {
+		tracks = append(tracks, tr.state())
+	}
+	return tracks
+}
+
+// Reset forgets every track. Track IDs keep increasing so that they are never reused.
+func (t *Tracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tracks = nil
+	t.frames = 0
+	t.lastUpdate = time.Time{}
+}
+
+// iou returns the intersection over union of two boxes.
+func iou(a, b image.Rectangle) float64 {
+	intersection := a.Intersect(b)
+	if intersection.Empty() {
+		return 0
+	}
+	inter := float64(intersection.Dx() * intersection.Dy())
+	union := float64(a.Dx()*a.Dy()+b.Dx()*b.Dy()) - inter
+	if union <= 0 {
+		return 0
+	}
+	return inter / union
+}
@@ -0,0 +1,174 @@
+package objecttracking
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/vision/objectdetection"
+)
+
+func TestAssign(t *testing.T) {
+	test.That(t, assign(nil), test.ShouldBeNil)
+	test.That(t, assign([][]float64{
+		{4, 1, 3},
+		{2, 0, 5},
+		{3, 2, 2},
+	}), test.ShouldResemble, []int{1, 0, 2})
+	// more rows than columns leaves the rows which cost the most unassigned.
+	test.That(t, assign([][]float64{{0.9}, {0.1}, {0.5}}), test.ShouldResemble, []int{-1, 0, -1})
+	test.That(t, assign([][]float64{{0.5, 0.1, 0.9}}), test.ShouldResemble, []int{1})
+}
+
+func TestParseTrackLabel(t *testing.T) {
+	label, id, ok := ParseTrackLabel(TrackLabel("traffic:light", 12))
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, label, test.ShouldEqual, "traffic:light")
+	test.That(t, id, test.ShouldEqual, 12)
+	_, _, ok = ParseTrackLabel("person")
+	test.That(t, ok, test.ShouldBeFalse)
+}
+
+func trackIDs(t *testing.T, detections []objectdetection.Detection) map[string]int {
+	t.Helper()
+	ids := map[string]int{}
+	for _, d := range detections {
+		tracked, ok := d.(TrackedDetection)
+		test.That(t, ok, test.ShouldBeTrue)
+		ids[tracked.ClassLabel()] = tracked.TrackID()
+	}
+	return ids
+}
+
+func TestTracker(t *testing.T) {
+	mockClock := clock.NewMock()
+	cfg := DefaultConfig()
+	cfg.AppearanceWeight = 0
+	tracker := NewTracker(cfg, mockClock)
+
+	// a person walking right at 100 pixels per second and a parked car, at 10 images per second.
+	frame := func(i int) []objectdetection.Detection {
+		x := 10 * i
+		return []objectdetection.Detection{
+			objectdetection.NewDetection(image.Rect(x, 100, x+50, 200), 0.9, "person"),
+			objectdetection.NewDetection(image.Rect(300, 300, 400, 350), 0.8, "car"),
+		}
+	}
+	var ids map[string]int
+	for i := 0; i < 20; i++ {
+		detections := tracker.Update(nil, frame(i))
+		test.That(t, detections, test.ShouldHaveLength, 2)
+		if i == 0 {
+			ids = trackIDs(t, detections)
+			test.That(t, ids["person"], test.ShouldNotEqual, ids["car"])
+		}
+		test.That(t, trackIDs(t, detections), test.ShouldResemble, ids)
+		mockClock.Add(100 * time.Millisecond)
+	}
+	for _, track := range tracker.Tracks() {
+		switch track.Label {
+		case "person":
+			test.That(t, track.VelocityX, test.ShouldAlmostEqual, 100, 5)
+			test.That(t, track.VelocityY, test.ShouldAlmostEqual, 0, 5)
+			test.That(t, track.Hits, test.ShouldEqual, 20)
+		case "car":
+			test.That(t, track.VelocityX, test.ShouldAlmostEqual, 0, 1)
+		}
+	}
+
+	// the person is missed, but keeps their ID when detected again where they are predicted to be.
+	for i := 20; i < 23; i++ {
+		detections := tracker.Update(nil, frame(i)[1:])
+		test.That(t, trackIDs(t, detections), test.ShouldResemble, map[string]int{"car": ids["car"]})
+		mockClock.Add(100 * time.Millisecond)
+	}
+	test.That(t, trackIDs(t, tracker.Update(nil, frame(23))), test.ShouldResemble, ids)
+	mockClock.Add(100 * time.Millisecond)
+
+	// once missed for longer than the max age the track dies.
+	for i := 24; i < 24+cfg.MaxAge+1; i++ {
+		tracker.Update(nil, frame(i)[1:])
+		mockClock.Add(100 * time.Millisecond)
+	}
+	test.That(t, tracker.Tracks(), test.ShouldHaveLength, 1)
+
+	// a new person is born, but only reported once detected in enough consecutive images.
+	for i := 0; i < cfg.MinHits; i++ {
+		detections := tracker.Update(nil, frame(i))
+		if i < cfg.MinHits-1 {
+			test.That(t, detections, test.ShouldHaveLength, 1)
+		} else {
+			test.That(t, detections, test.ShouldHaveLength, 2)
+			test.That(t, trackIDs(t, detections)["person"], test.ShouldBeGreaterThan, ids["car"])
+		}
+		mockClock.Add(100 * time.Millisecond)
+	}
+
+	tracker.Reset()
+	test.That(t, tracker.Tracks(), test.ShouldBeEmpty)
+}
+
+func TestTrackerAppearance(t *testing.T) {
+	// a red and a blue object which swap places between images, so that only their colors tell them apart.
+	img := func(redLeft bool) image.Image {
+		im := image.NewRGBA(image.Rect(0, 0, 200, 100))
+		left, right := color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}
+		if !redLeft {
+			left, right = right, left
+		}
+		for y := 0; y < 100; y++ {
+			for x := 0; x < 200; x++ {
+				if x < 100 {
+					im.Set(x, y, left)
+				} else {
+					im.Set(x, y, right)
+				}
+			}
+		}
+		return im
+	}
+	detections := []objectdetection.Detection{
+		objectdetection.NewDetection(image.Rect(0, 0, 100, 100), 0.9, "ball"),
+		objectdetection.NewDetection(image.Rect(100, 0, 200, 100), 0.9, "ball"),
+	}
+
+	cfg := Config{MinHits: 0, MaxAge: 1, MatchThreshold: 0.01, AppearanceWeight: 1}
+	tracker := NewTracker(cfg, clock.NewMock())
+	first := tracker.Update(img(true), detections)
+	second := tracker.Update(img(false), detections)
+	test.That(t, first, test.ShouldHaveLength, 2)
+	test.That(t, second, test.ShouldHaveLength, 2)
+	// the track IDs of the objects on the left and right of the image.
+	ids := func(detections []objectdetection.Detection) (int, int) {
+		var left, right int
+		for _, d := range detections {
+			if box := d.BoundingBox(); box.Min.X+box.Max.X < 200 {
+				left = d.(TrackedDetection).TrackID()
+			} else {
+				right = d.(TrackedDetection).TrackID()
+			}
+		}
+		return left, right
+	}
+	// boxes which don't overlap are never associated, so the objects are reborn rather than swapped.
+	left, right := ids(second)
+	test.That(t, left, test.ShouldBeGreaterThan, 2)
+	test.That(t, right, test.ShouldBeGreaterThan, 2)
+
+	// when the boxes overlap the colors decide which track each detection belongs to.
+	tracker = NewTracker(cfg, clock.NewMock())
+	wide := []objectdetection.Detection{
+		objectdetection.NewDetection(image.Rect(0, 0, 120, 100), 0.9, "ball"),
+		objectdetection.NewDetection(image.Rect(80, 0, 200, 100), 0.9, "ball"),
+	}
+	first = tracker.Update(img(true), wide)
+	second = tracker.Update(img(false), wide)
+	firstLeft, firstRight := ids(first)
+	left, right = ids(second)
+	test.That(t, left, test.ShouldEqual, firstRight)
+	test.That(t, right, test.ShouldEqual, firstLeft)
+}
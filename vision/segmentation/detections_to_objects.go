@@ -3,6 +3,7 @@ package segmentation
 import (
 	"context"
 	"image"
+	"image/color"
 
 	"github.com/go-viper/mapstructure/v2"
 	"github.com/pkg/errors"
@@ -148,9 +149,43 @@ func detectionToPointCloud(
 	if bb == nil {
 		return nil, errors.New("detection bounding box cannot be nil")
 	}
+	if mask := objectdetection.MaskOf(d); mask != nil {
+		return maskToPointCloud(mask, im, dm, proj)
+	}
 	pc, err := proj.RGBDToPointCloud(im, dm, *bb)
 	if err != nil {
 		return nil, err
 	}
 	return pc, nil
 }
+
+// maskToPointCloud projects only the pixels of the mask of a detection which have a depth, rather than all
+// of its bounding box, so that the background around the object is left out.
+func maskToPointCloud(
+	mask *image.Alpha,
+	im *rimage.Image, dm *rimage.DepthMap,
+	proj transform.Projector,
+) (pointcloud.PointCloud, error) {
+	if im.Bounds() != dm.Bounds() {
+		return nil, errors.Errorf("rgb image and depth map are not the same size img(%v) != depth(%v)", im.Bounds(), dm.Bounds())
+	}
+	bounds := mask.Bounds().Intersect(im.Bounds())
+	pc := pointcloud.New()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			z := dm.GetDepth(x, y)
+			if mask.AlphaAt(x, y).A < 128 || z == 0 {
+				continue
+			}
+			pt, err := proj.ImagePointTo3DPoint(image.Pt(x, y), z)
+			if err != nil {
+				return nil, err
+			}
+			r, g, b := im.GetXY(x, y).RGB255()
+			if err := pc.Set(pt, pointcloud.NewColoredData(color.NRGBA{r, g, b, 255})); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return pc, nil
+}
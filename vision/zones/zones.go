@@ -0,0 +1,461 @@
+// Package zones turns the detections of tracked objects into events about polygon zones and lines drawn
+// in image coordinates: objects entering and exiting zones, how long they dwelled in them, and objects
+// crossing lines.
+//
+// Events need to know which detections in consecutive images are of the same object, so they are only
+// generated for detections carrying a track ID, as made by objecttracking. Detections without one are
+// only counted towards how many objects currently occupy each zone.
+package zones
+
+import (
+	"cmp"
+	"image"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/golang/geo/r2"
+	"github.com/pkg/errors"
+
+	"go.viam.com/rdk/vision/objectdetection"
+	"go.viam.com/rdk/vision/objecttracking"
+)
+
+// Anchor is the point of a bounding box which decides where the detected object is.
+type Anchor string
+
+const (
+	// AnchorCenter is the center of the box.
+	AnchorCenter Anchor = "center"
+	// AnchorBottom is the middle of the bottom edge of the box, which is where objects standing on the
+	// ground touch it.
+	AnchorBottom Anchor = "bottom"
+)
+
+// Zone is a polygon within which objects are counted. Only objects with one of its labels are counted,
+// or every object if it has none.
+type Zone struct {
+	Name    string
+	Polygon []r2.Point
+	Labels  []string
+}
+
+// Line is a line segment whose crossings are counted. Objects cross it forward when they move from its
+// left to its right as seen looking from Start to End in the image, and backward otherwise. Only objects
+// with one of its labels are counted, or every object if it has none.
+type Line struct {
+	Name   string
+	Start  r2.Point
+	End    r2.Point
+	Labels []string
+}
+
+// Config describes the zones and lines analyzed and how objects are located.
+type Config struct {
+	Zones  []Zone
+	Lines  []Line
+	Anchor Anchor
+	// ExitTimeout is how long a tracked object can go undetected before it is considered to have exited
+	// every zone it was in.
+	ExitTimeout time.Duration
+	// MaxEvents is how many of the latest events are kept.
+	MaxEvents int
+}
+
+const (
+	defaultExitTimeout = 2 * time.Second
+	defaultMaxEvents   = 1000
+)
+
+// Validate ensures the zones and lines are well formed and have unique names.
+func (cfg Config) Validate() error {
+	names := map[string]bool{}
+	checkName := func(name string) error {
+		if name == "" {
+			return errors.New("zones and lines must have a name")
+		}
+		if names[name] {
+			return errors.Errorf("zone or line name %q is used more than once", name)
+		}
+		names[name] = true
+		return nil
+	}
+	for _, z := range cfg.Zones {
+		if err := checkName(z.Name); err != nil {
+			return err
+		}
+		if len(z.Polygon) < 3 {
+			return errors.Errorf("zone %q must have at least 3 points", z.Name)
+		}
+	}
+	for _, l := range cfg.Lines {
+		if err := checkName(l.Name); err != nil {
+			return err
+		}
+		if l.Start == l.End {
+			return errors.Errorf("line %q must have distinct start and end points", l.Name)
+		}
+	}
+	switch cfg.Anchor {
+	case "", AnchorCenter, AnchorBottom:
+	default:
+		return errors.Errorf("anchor must be %q or %q, not %q", AnchorCenter, AnchorBottom, cfg.Anchor)
+	}
+	if cfg.ExitTimeout < 0 {
+		return errors.New("exit timeout cannot be negative")
+	}
+	if cfg.MaxEvents < 0 {
+		return errors.New("max events cannot be negative")
+	}
+	return nil
+}
+
+// Contains returns whether p is within the zone.
+func (z Zone) Contains(p r2.Point) bool {
+	inside := false
+	for i, j := 0, len(z.Polygon)-1; i < len(z.Polygon); j, i = i, i+1 {
+		a, b := z.Polygon[i], z.Polygon[j]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < (b.X-a.X)*(p.Y-a.Y)/(b.Y-a.Y)+a.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// side returns which side of the line p is on: positive on its right, negative on its left and 0 on it.
+func (l Line) side(p r2.Point) float64 {
+	return l.End.Sub(l.Start).Cross(p.Sub(l.Start))
+}
+
+// crossed returns whether moving from p to q crosses the line segment, given that p and q are on
+// opposite sides of it.
+func (l Line) crossed(p, q r2.Point) bool {
+	// the segment from p to q intersects the line where it divides it in the ratio of their distances.
+	sp, sq := l.side(p), l.side(q)
+	at := p.Add(q.Sub(p).Mul(sp / (sp - sq)))
+	d := l.End.Sub(l.Start)
+	t := at.Sub(l.Start).Dot(d) / d.Dot(d)
+	return t >= 0 && t <= 1
+}
+
+func matchesLabel(labels []string, label string) bool {
+	return len(labels) == 0 || slices.Contains(labels, label)
+}
+
+// EventType is the kind of an Event.
+type EventType string
+
+const (
+	// EventEnter is an object entering a zone.
+	EventEnter EventType = "enter"
+	// EventExit is an object exiting a zone, either by leaving it or by no longer being detected.
+	EventExit EventType = "exit"
+	// EventCross is an object crossing a line.
+	EventCross EventType = "cross"
+)
+
+// Direction is which way an object crossed a line.
+type Direction string
+
+const (
+	// Forward is crossing from the left of a line to its right.
+	Forward Direction = "forward"
+	// Backward is crossing from the right of a line to its left.
+	Backward Direction = "backward"
+)
+
+// Event is something a tracked object did in a zone or across a line.
+type Event struct {
+	// Seq increases by one with every event, so that readers can ask for the events after the last one
+	// they read.
+	Seq     uint64
+	Time    time.Time
+	Type    EventType
+	Region  string
+	Label   string
+	TrackID int
+	// Dwell is how long the object was in the zone, for exit events.
+	Dwell time.Duration
+	// Direction is which way the object crossed the line, for cross events.
+	Direction Direction
+}
+
+// ZoneCounts counts what objects did in a zone.
+type ZoneCounts struct {
+	// Occupancy is how many objects were in the zone in the latest image.
+	Occupancy int
+	Entered   int
+	Exited    int
+	// TotalDwell and MaxDwell are the total and longest time objects which exited the zone were in it.
+	TotalDwell time.Duration
+	MaxDwell   time.Duration
+}
+
+// MeanDwell returns how long objects which exited the zone were in it on average.
+func (c ZoneCounts) MeanDwell() time.Duration {
+	if c.Exited == 0 {
+		return 0
+	}
+	return c.TotalDwell / time.Duration(c.Exited)
+}
+
+// LineCounts counts how many objects crossed a line in each direction.
+type LineCounts struct {
+	Forward  int
+	Backward int
+}
+
+// ZoneSummary is the counts of a zone for every object and by label.
+type ZoneSummary struct {
+	ZoneCounts
+	ByLabel map[string]ZoneCounts
+}
+
+// LineSummary is the counts of a line for every object and by label.
+type LineSummary struct {
+	LineCounts
+	ByLabel map[string]LineCounts
+}
+
+// Summary is the counts of every zone and line, by name.
+type Summary struct {
+	Zones map[string]ZoneSummary
+	Lines map[string]LineSummary
+}
+
+// object is the state of a tracked object.
+type object struct {
+	label    string
+	lastSeen time.Time
+	// entered is when the object entered each zone it is in.
+	entered map[string]time.Time
+	// sides is the last point the object was seen at which was off each line, and which side it was on.
+	sides map[string]lineSide
+}
+
+type lineSide struct {
+	point r2.Point
+	side  float64
+}
+
+// Analyzer generates events and counts from the detections of consecutive images.
+type Analyzer struct {
+	cfg   Config
+	clock clock.Clock
+
+	mu      sync.Mutex
+	objects map[int]*object
+	zones   map[string]*ZoneSummary
+	lines   map[string]*LineSummary
+	events  []Event
+	nextSeq uint64
+}
+
+// NewAnalyzer returns an Analyzer for the zones and lines of cfg, which timestamps events with clk.
+func NewAnalyzer(cfg Config, clk clock.Clock) *Analyzer {
+	if cfg.Anchor == "" {
+		cfg.Anchor = AnchorCenter
+	}
+	if cfg.ExitTimeout == 0 {
+		cfg.ExitTimeout = defaultExitTimeout
+	}
+	if cfg.MaxEvents == 0 {
+		cfg.MaxEvents = defaultMaxEvents
+	}
+	a := &Analyzer{cfg: cfg, clock: clk}
+	a.reset()
+	return a
+}
+
+func (a *Analyzer) reset() {
+	a.objects = map[int]*object{}
+	a.zones = map[string]*ZoneSummary{}
+	for _, z := range a.cfg.Zones {
+		a.zones[z.Name] = &ZoneSummary{ByLabel: map[string]ZoneCounts{}}
+	}
+	a.lines = map[string]*LineSummary{}
+	for _, l := range a.cfg.Lines {
+		a.lines[l.Name] = &LineSummary{ByLabel: map[string]LineCounts{}}
+	}
+	a.events = nil
+}
+
+func (a *Analyzer) anchor(box image.Rectangle) r2.Point {
+	x := float64(box.Min.X+box.Max.X) / 2
+	if a.cfg.Anchor == AnchorBottom {
+		return r2.Point{X: x, Y: float64(box.Max.Y)}
+	}
+	return r2.Point{X: x, Y: float64(box.Min.Y+box.Max.Y) / 2}
+}
+
+// Update analyzes the detections of the next image, returning the events they caused.
+func (a *Analyzer) Update(detections []objectdetection.Detection) []Event {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := a.clock.Now()
+	since := a.nextSeq
+
+	occupancy := map[string]map[string]int{}
+	for _, z := range a.cfg.Zones {
+		occupancy[z.Name] = map[string]int{}
+	}
+	for _, d := range detections {
+		label, id, tracked := objecttracking.ParseTrackLabel(d.Label())
+		if !tracked {
+			label = d.Label()
+		}
+		p := a.anchor(*d.BoundingBox())
+		for _, z := range a.cfg.Zones {
+			if matchesLabel(z.Labels, label) && z.Contains(p) {
+				occupancy[z.Name][label]++
+			}
+		}
+		if tracked {
+			a.updateObject(now, id, label, p)
+		}
+	}
+	for name, summary := range a.zones {
+		summary.Occupancy = 0
+		for label, counts := range summary.ByLabel {
+			counts.Occupancy = occupancy[name][label]
+			summary.ByLabel[label] = counts
+		}
+		for label, n := range occupancy[name] {
+			counts := summary.ByLabel[label]
+			counts.Occupancy = n
+			summary.ByLabel[label] = counts
+			summary.Occupancy += n
+		}
+	}
+
+	// objects which haven't been detected for too long exit every zone they were in when last detected.
+	for id, obj := range a.objects {
+		if now.Sub(obj.lastSeen) <= a.cfg.ExitTimeout {
+			continue
+		}
+		for _, z := range a.cfg.Zones {
+			if entered, ok := obj.entered[z.Name]; ok {
+				a.exit(now, z.Name, id, obj.label, obj.lastSeen.Sub(entered))
+			}
+		}
+		delete(a.objects, id)
+	}
+	if len(a.events) > a.cfg.MaxEvents {
+		a.events = slices.Clone(a.events[len(a.events)-a.cfg.MaxEvents:])
+	}
+	return a.eventsSince(since)
+}
+
+func (a *Analyzer) updateObject(now time.Time, id int, label string, p r2.Point) {
+	obj, ok := a.objects[id]
+	if !ok {
+		obj = &object{label: label, entered: map[string]time.Time{}, sides: map[string]lineSide{}}
+		a.objects[id] = obj
+	}
+	obj.lastSeen = now
+
+	for _, z := range a.cfg.Zones {
+		if !matchesLabel(z.Labels, label) {
+			continue
+		}
+		entered, wasIn := obj.entered[z.Name]
+		switch isIn := z.Contains(p); {
+		case isIn && !wasIn:
+			obj.entered[z.Name] = now
+			summary := a.zones[z.Name]
+			summary.Entered++
+			counts := summary.ByLabel[label]
+			counts.Entered++
+			summary.ByLabel[label] = counts
+			a.emit(Event{Time: now, Type: EventEnter, Region: z.Name, Label: label, TrackID: id})
+		case !isIn && wasIn:
+			delete(obj.entered, z.Name)
+			a.exit(now, z.Name, id, label, now.Sub(entered))
+		}
+	}
+
+	for _, l := range a.cfg.Lines {
+		if !matchesLabel(l.Labels, label) {
+			continue
+		}
+		side := l.side(p)
+		if side == 0 {
+			continue
+		}
+		last, seen := obj.sides[l.Name]
+		obj.sides[l.Name] = lineSide{point: p, side: side}
+		if !seen || (last.side > 0) == (side > 0) || !l.crossed(last.point, p) {
+			continue
+		}
+		summary := a.lines[l.Name]
+		counts := summary.ByLabel[label]
+		direction := Forward
+		if side > 0 {
+			summary.Forward++
+			counts.Forward++
+		} else {
+			direction = Backward
+			summary.Backward++
+			counts.Backward++
+		}
+		summary.ByLabel[label] = counts
+		a.emit(Event{Time: now, Type: EventCross, Region: l.Name, Label: label, TrackID: id, Direction: direction})
+	}
+}
+
+func (a *Analyzer) exit(now time.Time, zone string, id int, label string, dwell time.Duration) {
+	summary := a.zones[zone]
+	summary.Exited++
+	summary.TotalDwell += dwell
+	summary.MaxDwell = max(summary.MaxDwell, dwell)
+	counts := summary.ByLabel[label]
+	counts.Exited++
+	counts.TotalDwell += dwell
+	counts.MaxDwell = max(counts.MaxDwell, dwell)
+	summary.ByLabel[label] = counts
+	a.emit(Event{Time: now, Type: EventExit, Region: zone, Label: label, TrackID: id, Dwell: dwell})
+}
+
+func (a *Analyzer) emit(e Event) {
+	a.nextSeq++
+	e.Seq = a.nextSeq
+	a.events = append(a.events, e)
+}
+
+// Events returns the kept events whose sequence number is greater than since, oldest first.
+func (a *Analyzer) Events(since uint64) []Event {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.eventsSince(since)
+}
+
+func (a *Analyzer) eventsSince(since uint64) []Event {
+	i, _ := slices.BinarySearchFunc(a.events, since+1, func(e Event, seq uint64) int {
+		return cmp.Compare(e.Seq, seq)
+	})
+	return slices.Clone(a.events[i:])
+}
+
+// Summary returns the counts of every zone and line.
+func (a *Analyzer) Summary() Summary {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	summary := Summary{Zones: map[string]ZoneSummary{}, Lines: map[string]LineSummary{}}
+	for name, z := range a.zones {
+		summary.Zones[name] = ZoneSummary{ZoneCounts: z.ZoneCounts, ByLabel: maps.Clone(z.ByLabel)}
+	}
+	for name, l := range a.lines {
+		summary.Lines[name] = LineSummary{LineCounts: l.LineCounts, ByLabel: maps.Clone(l.ByLabel)}
+	}
+	return summary
+}
+
+// Reset forgets every tracked object, count and event. Sequence numbers keep increasing so that readers
+// never mistake new events for ones they have already read.
+func (a *Analyzer) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.reset()
+}
@@ -0,0 +1,159 @@
+package zones
+
+import (
+	"image"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/golang/geo/r2"
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/vision/objectdetection"
+	"go.viam.com/rdk/vision/objecttracking"
+)
+
+func TestValidate(t *testing.T) {
+	square := []r2.Point{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}}
+	test.That(t, Config{Zones: []Zone{{Name: "a", Polygon: square}}}.Validate(), test.ShouldBeNil)
+	err := Config{Zones: []Zone{{Name: "a", Polygon: square[:2]}}}.Validate()
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "at least 3 points")
+	err = Config{
+		Zones: []Zone{{Name: "a", Polygon: square}},
+		Lines: []Line{{Name: "a", Start: r2.Point{X: 0, Y: 0}, End: r2.Point{X: 1, Y: 0}}},
+	}.Validate()
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "more than once")
+	err = Config{Lines: []Line{{Name: "b"}}}.Validate()
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "distinct")
+	err = Config{Anchor: "top"}.Validate()
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "anchor")
+}
+
+func TestContains(t *testing.T) {
+	// a concave L shaped zone.
+	zone := Zone{Polygon: []r2.Point{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 4}, {X: 4, Y: 4}, {X: 4, Y: 10}, {X: 0, Y: 10}}}
+	test.That(t, zone.Contains(r2.Point{X: 2, Y: 2}), test.ShouldBeTrue)
+	test.That(t, zone.Contains(r2.Point{X: 8, Y: 2}), test.ShouldBeTrue)
+	test.That(t, zone.Contains(r2.Point{X: 2, Y: 8}), test.ShouldBeTrue)
+	test.That(t, zone.Contains(r2.Point{X: 8, Y: 8}), test.ShouldBeFalse)
+	test.That(t, zone.Contains(r2.Point{X: -1, Y: 2}), test.ShouldBeFalse)
+}
+
+func box(id int, label string, x, y int) objectdetection.Detection {
+	return objectdetection.NewDetection(image.Rect(x-5, y-5, x+5, y+5), 0.9, objecttracking.TrackLabel(label, id))
+}
+
+func TestAnalyzer(t *testing.T) {
+	mockClock := clock.NewMock()
+	analyzer := NewAnalyzer(Config{
+		Zones: []Zone{
+			{Name: "dock", Polygon: []r2.Point{{X: 100, Y: 0}, {X: 200, Y: 0}, {X: 200, Y: 100}, {X: 100, Y: 100}}},
+			{
+				Name:    "forklifts",
+				Polygon: []r2.Point{{X: 100, Y: 0}, {X: 200, Y: 0}, {X: 200, Y: 100}, {X: 100, Y: 100}},
+				Labels:  []string{"forklift"},
+			},
+		},
+		// a vertical line pointing down, whose left is to the right in the image.
+		Lines: []Line{{Name: "door", Start: r2.Point{X: 50, Y: 0}, End: r2.Point{X: 50, Y: 100}}},
+	}, mockClock)
+
+	// a person walks right through the door, into the dock, and stays there for 3 seconds.
+	test.That(t, analyzer.Update([]objectdetection.Detection{box(1, "person", 40, 50)}), test.ShouldBeEmpty)
+	mockClock.Add(time.Second)
+	events := analyzer.Update([]objectdetection.Detection{box(1, "person", 60, 50)})
+	test.That(t, events, test.ShouldHaveLength, 1)
+	test.That(t, events[0].Type, test.ShouldEqual, EventCross)
+	test.That(t, events[0].Region, test.ShouldEqual, "door")
+	test.That(t, events[0].Direction, test.ShouldEqual, Backward)
+	test.That(t, events[0].Label, test.ShouldEqual, "person")
+	test.That(t, events[0].TrackID, test.ShouldEqual, 1)
+	mockClock.Add(time.Second)
+	events = analyzer.Update([]objectdetection.Detection{
+		box(1, "person", 150, 50),
+		// detections without a track ID only count towards occupancy.
+		objectdetection.NewDetection(image.Rect(140, 40, 160, 60), 0.9, "forklift"),
+	})
+	test.That(t, events, test.ShouldHaveLength, 1)
+	test.That(t, events[0].Type, test.ShouldEqual, EventEnter)
+	test.That(t, events[0].Region, test.ShouldEqual, "dock")
+	summary := analyzer.Summary()
+	test.That(t, summary.Zones["dock"].Occupancy, test.ShouldEqual, 2)
+	test.That(t, summary.Zones["dock"].ByLabel["forklift"].Occupancy, test.ShouldEqual, 1)
+	test.That(t, summary.Zones["forklifts"].Occupancy, test.ShouldEqual, 1)
+	test.That(t, summary.Zones["forklifts"].Entered, test.ShouldEqual, 0)
+
+	mockClock.Add(3 * time.Second)
+	events = analyzer.Update([]objectdetection.Detection{box(1, "person", 150, 150)})
+	test.That(t, events, test.ShouldHaveLength, 1)
+	test.That(t, events[0].Type, test.ShouldEqual, EventExit)
+	test.That(t, events[0].Dwell, test.ShouldEqual, 3*time.Second)
+
+	// crossing back through the door, but below its end, isn't a crossing.
+	mockClock.Add(time.Second)
+	test.That(t, analyzer.Update([]objectdetection.Detection{box(1, "person", 40, 150)}), test.ShouldBeEmpty)
+
+	// a forklift enters the dock and is lost, exiting once it hasn't been detected for long enough.
+	mockClock.Add(time.Second)
+	events = analyzer.Update([]objectdetection.Detection{box(2, "forklift", 150, 50)})
+	test.That(t, events, test.ShouldHaveLength, 2)
+	mockClock.Add(time.Second)
+	test.That(t, analyzer.Update(nil), test.ShouldBeEmpty)
+	mockClock.Add(2 * time.Second)
+	events = analyzer.Update(nil)
+	test.That(t, events, test.ShouldHaveLength, 2)
+	for _, e := range events {
+		test.That(t, e.Type, test.ShouldEqual, EventExit)
+		test.That(t, e.Dwell, test.ShouldEqual, 0)
+	}
+
+	summary = analyzer.Summary()
+	test.That(t, summary.Zones["dock"].Entered, test.ShouldEqual, 2)
+	test.That(t, summary.Zones["dock"].Exited, test.ShouldEqual, 2)
+	test.That(t, summary.Zones["dock"].Occupancy, test.ShouldEqual, 0)
+	test.That(t, summary.Zones["dock"].MaxDwell, test.ShouldEqual, 3*time.Second)
+	test.That(t, summary.Zones["dock"].MeanDwell(), test.ShouldEqual, 1500*time.Millisecond)
+	test.That(t, summary.Zones["dock"].ByLabel["person"].Entered, test.ShouldEqual, 1)
+	test.That(t, summary.Zones["forklifts"].Entered, test.ShouldEqual, 1)
+	test.That(t, summary.Zones["forklifts"].ByLabel, test.ShouldNotContainKey, "person")
+	test.That(t, summary.Lines["door"].Backward, test.ShouldEqual, 1)
+	test.That(t, summary.Lines["door"].Forward, test.ShouldEqual, 0)
+
+	all := analyzer.Events(0)
+	test.That(t, all, test.ShouldHaveLength, 7)
+	test.That(t, analyzer.Events(all[4].Seq), test.ShouldResemble, all[5:])
+
+	analyzer.Reset()
+	test.That(t, analyzer.Events(0), test.ShouldBeEmpty)
+	test.That(t, analyzer.Summary().Zones["dock"].Entered, test.ShouldEqual, 0)
+	mockClock.Add(time.Second)
+	analyzer.Update([]objectdetection.Detection{box(3, "person", 150, 50)})
+	test.That(t, analyzer.Events(0)[0].Seq, test.ShouldEqual, all[6].Seq+1)
+}
+
+func TestAnalyzerMaxEvents(t *testing.T) {
+	mockClock := clock.NewMock()
+	analyzer := NewAnalyzer(Config{
+		Lines:     []Line{{Name: "line", Start: r2.Point{X: 50, Y: 0}, End: r2.Point{X: 50, Y: 100}}},
+		MaxEvents: 2,
+		Anchor:    AnchorBottom,
+	}, mockClock)
+	for i := 0; i < 5; i++ {
+		x := 40
+		if i%2 == 1 {
+			x = 60
+		}
+		analyzer.Update([]objectdetection.Detection{box(1, "person", x, 90)})
+		mockClock.Add(time.Second)
+	}
+	events := analyzer.Events(0)
+	test.That(t, events, test.ShouldHaveLength, 2)
+	test.That(t, events[0].Seq, test.ShouldEqual, 3)
+	test.That(t, events[0].Direction, test.ShouldEqual, Backward)
+	test.That(t, events[1].Direction, test.ShouldEqual, Forward)
+	test.That(t, analyzer.Summary().Lines["line"].LineCounts, test.ShouldResemble, LineCounts{Forward: 2, Backward: 2})
+}